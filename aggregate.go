@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// runAggregate signs the sha256 of a message with several keys at once,
+// giving one signature that verifies against the sum of their public keys.
+// Every key is needed here, which is what MuSig and FROST avoid.
+func runAggregate(args []string) {
+	var privateKeys stringList
+
+	fs := newFlagSet("aggregate")
+	fs.Var(&privateKeys, "privkey", "private key to sign with, repeated for each signer")
+	messagePtr := fs.String("message", "", "message to be signed")
+	inPtr := fs.String("in", "", "file to sign instead of -message")
+	parseFlags(fs, args)
+
+	if len(privateKeys) < 2 {
+		failf("aggregate needs at least two -privkey")
+		exit(2)
+	}
+
+	ds := []*big.Int{}
+	var sum *schnorr.Point
+	for _, k := range privateKeys {
+		d, err := readPrivateKeyHex(k)
+		if err != nil {
+			fail(err)
+			exit(2)
+		}
+		ds = append(ds, d)
+		if P := schnorr.ScalarBaseMult(d); sum == nil {
+			sum = P
+		} else {
+			sum = sum.Add(P)
+		}
+	}
+	publickey, err := sum.PublicKey()
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+
+	digest, err := messageDigest(*messagePtr, *inPtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	signature, err := schnorr.AggregateSignatures(ds, digest)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	if ok, err := schnorr.Verify(publickey, digest, signature); !ok {
+		failf("aggregate signature has failed validation: %v", err)
+		exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "aggregate public key %s\n", hex.EncodeToString(publickey[:]))
+	fmt.Printf("%x\n", signature)
+	pubkey := hex.EncodeToString(publickey[:])
+	recordResult(signOutput{Signature: fmt.Sprintf("%x", signature), PublicKey: pubkey, Fingerprint: fingerprintHex(pubkey)})
+}
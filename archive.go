@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/archive"
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/truststore"
+)
+
+func runPack(args []string) {
+	var annotations stringList
+
+	fs := newFlagSet("pack")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign the manifest with, prompted for if empty")
+	dirPtr := fs.String("dir", "", "directory to pack")
+	outputPtr := fs.String("output", "", "archive file to write")
+	fs.Var(&annotations, "a", "signed annotation in the form key=value, such as a build id or commit, can be repeated")
+	validPtr := fs.Duration("valid", 0, "make the manifest signature expire this long from now, never if 0")
+	parseFlags(fs, args)
+
+	signed, err := parseAnnotations(annotations)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	privateKey, err := secretPrivateKey(prompt.New(), *privateKeyPtr, "Private key (hex or WIF): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, ok := new(big.Int).SetString(privateKey, 16)
+	if !ok {
+		failf("private key is not hex")
+		return
+	}
+
+	f, err := os.Create(*outputPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	opts := []envelope.Option{envelope.WithAnnotations(signed)}
+	if *validPtr > 0 {
+		opts = append(opts, envelope.WithExpiry(time.Now().Add(*validPtr)))
+	}
+	m, err := archive.Pack(f, *dirPtr, d, opts...)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*outputPtr)
+		fail(err)
+		return
+	}
+	fmt.Printf("packed %d entries into %s\n", len(m.Entries), *outputPtr)
+}
+
+func runUnpack(args []string) {
+	var annotations stringList
+
+	fs := newFlagSet("unpack")
+	pubKeyPtr := fs.String("pubkey", "", "public key the archive must be signed by")
+	archivePtr := fs.String("archive", "", "archive file to unpack")
+	dirPtr := fs.String("dir", ".", "directory to unpack into")
+	fs.Var(&annotations, "a", "annotation the archive must carry, key=value, can be repeated")
+	trustPtr := fs.String("trust", "", "trust store the signing key must be trusted in")
+	firstUsePtr := fs.Bool("tofu", false, "with -trust, pin a key not yet in the store with a warning")
+	minTrustPtr := fs.String("min-trust", "marginal", "with -trust, least trust level accepted")
+	parseFlags(fs, args)
+
+	required, err := parseAnnotations(annotations)
+	if err != nil {
+		fail(err)
+		return
+	}
+	opts := []envelope.VerifyOption{envelope.RequireAnnotations(required)}
+	var trust *truststore.Store
+	if *trustPtr != "" {
+		if trust, err = openTrustStore(*trustPtr, *firstUsePtr, *minTrustPtr); err != nil {
+			fail(err)
+			return
+		}
+		opts = append(opts, envelope.RequireTrusted(trust))
+	}
+
+	var pk [33]byte
+	pkBytes, err := hex.DecodeString(*pubKeyPtr)
+	if err != nil || len(pkBytes) != 33 {
+		failf("public key must be 33 hex encoded bytes")
+		return
+	}
+	copy(pk[:], pkBytes)
+
+	f, err := os.Open(*archivePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer f.Close()
+
+	m, err := archive.Unpack(f, *dirPtr, pk, opts...)
+	if err != nil {
+		fail(err)
+		printVerified("Signature", false)
+		return
+	}
+	if trust != nil {
+		if err := trust.Save(); err != nil {
+			fail(err)
+		}
+	}
+	fmt.Printf("unpacked %d entries into %s\n", len(m.Entries), *dirPtr)
+	printAnnotations(m.Annotations)
+	printVerified("Signature", true)
+}
+
+func runInspect(args []string) {
+	fs := newFlagSet("inspect")
+	archivePtr := fs.String("archive", "", "archive file to inspect")
+	templatePtr := fs.String("template", "", "Go text/template to print the contents with, such as '{{.Fingerprint}} {{.Annotations.build}}'")
+	parseFlags(fs, args)
+
+	tmpl, err := outputTemplate(*templatePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	f, err := os.Open(*archivePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer f.Close()
+
+	e, err := archive.Inspect(f)
+	if err != nil {
+		fail(err)
+		return
+	}
+	out := inspectOutput{
+		PayloadType: e.PayloadType,
+		PublicKey:   e.PublicKey,
+		Fingerprint: fingerprintHex(e.PublicKey),
+		Sequence:    e.Sequence,
+		Expires:     e.Expires,
+		Annotations: e.Annotations,
+	}
+	recordResult(out)
+	if tmpl != nil {
+		if err := printTemplate(tmpl, out); err != nil {
+			fail(err)
+		}
+		return
+	}
+	fmt.Printf("payload type  %s\n", e.PayloadType)
+	fmt.Printf("signed by     %s\n", e.PublicKey)
+	if e.Sequence != nil {
+		fmt.Printf("sequence      %d\n", *e.Sequence)
+	}
+	if e.Expires != nil {
+		fmt.Printf("expires       %s\n", e.Expires.Format(time.RFC3339))
+	}
+	printAnnotations(e.Annotations)
+	fmt.Println("not verified, use unpack -pubkey to check the signature")
+}
+
+// printAnnotations lists annotations sorted by key
+func printAnnotations(annotations map[string]string) {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("annotation    %s=%s\n", k, annotations[k])
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/attest"
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/keystore"
+)
+
+//
+// Key usage attestation reports for compliance reviews, see pkg/attest.
+// report counts what each key signed from the daemon's audit logs and signs
+// the result; verify checks a report and prints it.
+//
+
+func runAttest(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go attest <report|verify> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "report":
+		attestReport(args[1:])
+	case "verify":
+		attestVerify(args[1:])
+	default:
+		unknownCommand("attest", args)
+	}
+}
+
+func attestReport(args []string) {
+	var logs, keystores stringList
+
+	fs := newFlagSet("attest report")
+	fs.Var(&logs, "log", "audit log of daemon -audit or -watch-audit, can be repeated")
+	fs.Var(&keystores, "keystores", "directory of keystore files whose keys are expected to sign, named by file, can be repeated")
+	fromPtr := fs.String("from", "", "start of the period, a date or RFC 3339 time, -period before -to if empty")
+	toPtr := fs.String("to", "", "end of the period, not included, now if empty")
+	periodPtr := fs.Duration("period", 30*24*time.Hour, "length of the period when -from is empty")
+	digestsPtr := fs.Bool("digests", false, "list every digest signed, not only their hash")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign the report with, prompted for if empty")
+	privateKeyFilePtr := fs.String("privkey-file", "", "PEM, encrypted keystore or Ethereum keystore file of the private key instead of -privkey")
+	outputPtr := fs.String("output", "", "file to write the signed report to, stdout if empty")
+	parseFlags(fs, args)
+
+	if len(logs) == 0 {
+		failf("usage: schnorr-go attest report -log audit.jsonl [-keystores dir] [-from date] [-to date] -output report.json")
+		exit(2)
+	}
+	to, from := time.Now(), time.Time{}
+	var err error
+	if *toPtr != "" {
+		if to, err = parseReportTime(*toPtr); err != nil {
+			fail(err)
+			exit(2)
+		}
+	}
+	if *fromPtr != "" {
+		if from, err = parseReportTime(*fromPtr); err != nil {
+			fail(err)
+			exit(2)
+		}
+	} else {
+		from = to.Add(-*periodPtr)
+	}
+	if !from.Before(to) {
+		failf("-from must be before -to")
+		exit(2)
+	}
+
+	b := attest.NewBuilder(from, to)
+	b.IncludeDigests = *digestsPtr
+	for _, dir := range keystores {
+		if err := addKnownKeys(b, dir); err != nil {
+			fail(err)
+			exit(1)
+		}
+	}
+	for _, path := range logs {
+		f, err := os.Open(path)
+		if err != nil {
+			fail(err)
+			exit(1)
+		}
+		err = b.Read(f)
+		f.Close()
+		if err != nil {
+			failf("%s: %v", path, err)
+			exit(1)
+		}
+	}
+	report := b.Report(time.Now())
+
+	privateKey, err := privateKeyFlag(*privateKeyPtr, *privateKeyFilePtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	d, err := readPrivateKeyHex(privateKey)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	e, err := attest.Sign(d, report)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+		exit(1)
+	}
+	for _, finding := range report.Findings {
+		fmt.Fprintf(os.Stderr, "finding: %s\n", finding)
+	}
+}
+
+// addKnownKeys adds the public key of every keystore file in the directory,
+// named by the file
+func addKnownKeys(b *attest.Builder, dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !keystore.IsKeystore(data) {
+			continue
+		}
+		ks, err := keystore.Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		b.Known(ks.PublicKey, strings.TrimSuffix(filepath.Base(path), ".json"))
+	}
+	return nil
+}
+
+// parseReportTime reads a date, taken as midnight UTC, or an RFC 3339 time
+func parseReportTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date or RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+func attestVerify(args []string) {
+	fs := newFlagSet("attest verify")
+	inPtr := fs.String("in", "", "signed report to verify")
+	pubKeyPtr := fs.String("pubkey", "", "public key the report must be signed by")
+	pubKeyFilePtr := fs.String("pubkey-file", "", "PKIX PEM file of the public key instead of -pubkey")
+	parseFlags(fs, args)
+
+	pubkey, err := publicKeyFlag(*pubKeyPtr, *pubKeyFilePtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	pk, err := parsePublicKeyHex(pubkey)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	data, err := os.ReadFile(*inPtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	e := new(envelope.Envelope)
+	if err := json.Unmarshal(data, e); err != nil {
+		failf("%s is not a signed report: %v", *inPtr, err)
+		exit(2)
+	}
+	report, err := attest.Open(e, pk)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+
+	fmt.Printf("report of %s to %s, generated %s\n", report.From.Format(time.RFC3339), report.To.Format(time.RFC3339), report.Generated.Format(time.RFC3339))
+	for _, u := range report.Keys {
+		fmt.Printf("%s %s: %d signed, %d refused\n", u.PublicKey, u.Name, u.Signed, u.Refused)
+		clients := make([]string, 0, len(u.Clients))
+		for client := range u.Clients {
+			clients = append(clients, client)
+		}
+		sort.Strings(clients)
+		for _, client := range clients {
+			fmt.Printf("    client %s: %d\n", client, u.Clients[client])
+		}
+	}
+	for _, finding := range report.Findings {
+		fmt.Printf("finding: %s\n", finding)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Audited signing between a verifier and a signer, see pkg/schnorr. The
+// verifier runs salt, the signer nonce then sign, and anyone holding the
+// proof can run verify.
+//
+
+type auditProofFile struct {
+	NonceCommitment string `json:"nonce_commitment"`
+	Salt            string `json:"salt"`
+}
+
+func runAudit(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go audit <salt|nonce|sign|verify> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "salt":
+		auditSalt(args[1:])
+	case "nonce":
+		auditNonce(args[1:])
+	case "sign":
+		auditSign(args[1:])
+	case "verify":
+		auditVerify(args[1:])
+	default:
+		unknownCommand("audit", args)
+	}
+}
+
+func auditSalt(args []string) {
+	fs := newFlagSet("audit salt")
+	parseFlags(fs, args)
+
+	var salt [32]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		fail(err)
+		return
+	}
+	commitment := schnorr.SaltCommitment(salt)
+	fmt.Printf("salt:       %x (keep until the signer sends its nonce)\n", salt)
+	fmt.Printf("commitment: %x\n", commitment)
+}
+
+func auditNonce(args []string) {
+	fs := newFlagSet("audit nonce")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, prompted for if empty")
+	messagePtr := fs.String("message", "", "message to be signed, its sha256 is what is signed")
+	commitmentPtr := fs.String("commitment", "", "salt commitment from the verifier")
+	parseFlags(fs, args)
+
+	var commitment [32]byte
+	if err := decodeHexInto(commitment[:], *commitmentPtr, "commitment"); err != nil {
+		fail(err)
+		return
+	}
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	digest, _ := messageDigest(*messagePtr, "")
+	r0, err := schnorr.AuditNonceCommit(d, digest, commitment)
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("%x\n", r0)
+}
+
+func auditSign(args []string) {
+	fs := newFlagSet("audit sign")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, prompted for if empty")
+	messagePtr := fs.String("message", "", "message to be signed, its sha256 is what is signed")
+	saltPtr := fs.String("salt", "", "salt revealed by the verifier")
+	proofPtr := fs.String("proof", "", "file to write the audit proof to, stdout if empty")
+	parseFlags(fs, args)
+
+	var salt [32]byte
+	if err := decodeHexInto(salt[:], *saltPtr, "salt"); err != nil {
+		fail(err)
+		return
+	}
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	digest, _ := messageDigest(*messagePtr, "")
+	sig, proof, err := schnorr.SignAudited(d, digest, salt)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	data, err := json.MarshalIndent(auditProofFile{
+		NonceCommitment: hex.EncodeToString(proof.NonceCommitment[:]),
+		Salt:            hex.EncodeToString(proof.Salt[:]),
+	}, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("%x\n", sig)
+	if err := writeOrPrint(*proofPtr, data); err != nil {
+		fail(err)
+	}
+}
+
+func auditVerify(args []string) {
+	fs := newFlagSet("audit verify")
+	pubKeyPtr := fs.String("pubkey", "", "public key to verify the signature with")
+	messagePtr := fs.String("message", "", "message that was signed")
+	signaturePtr := fs.String("sig", "", "signature to verify")
+	proofPtr := fs.String("proof", "", "audit proof file from audit sign")
+	noncePtr := fs.String("nonce", "", "nonce commitment the signer sent before the salt was revealed")
+	parseFlags(fs, args)
+
+	pk, err := parsePublicKeyHex(*pubKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var sig [64]byte
+	if err := decodeHexInto(sig[:], *signaturePtr, "signature"); err != nil {
+		fail(err)
+		return
+	}
+	data, err := os.ReadFile(*proofPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	file := auditProofFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		fail(err)
+		return
+	}
+	proof := &schnorr.AuditProof{}
+	if err := decodeHexInto(proof.NonceCommitment[:], file.NonceCommitment, "nonce commitment"); err != nil {
+		fail(err)
+		return
+	}
+	if err := decodeHexInto(proof.Salt[:], file.Salt, "salt"); err != nil {
+		fail(err)
+		return
+	}
+
+	if *noncePtr != "" && *noncePtr != file.NonceCommitment {
+		fmt.Println("proof's nonce commitment is not the one the signer sent first")
+		printVerified("Signature", false)
+		return
+	}
+	digest, _ := messageDigest(*messagePtr, "")
+	ok, err := schnorr.VerifyAudited(pk, digest, sig, proof)
+	if err != nil {
+		fail(err)
+	}
+	if *noncePtr == "" && ok {
+		fmt.Println("nonce commitment not checked, pass -nonce with the one received before the salt")
+	}
+	printVerified("Signature", ok)
+}
+
+func decodeHexInto(out []byte, s, name string) error {
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != len(out) {
+		return fmt.Errorf("%s is not %d bytes of hex", name, len(out))
+	}
+	copy(out, raw)
+	return nil
+}
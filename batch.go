@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ryohare/schnorr-go/pkg/hooks"
+	"github.com/ryohare/schnorr-go/pkg/msgtemplate"
+	"github.com/ryohare/schnorr-go/pkg/textnorm"
+)
+
+//
+// sign -batch signs everything a manifest lists with one key load, instead
+// of a process per message each given the key again:
+//
+//	{"items": [
+//	  {"id": "greeting", "message": "hello"},
+//	  {"file": "dist/app.tar"}
+//	]}
+//
+// Files are relative to the manifest. The results map each item to its
+// signature, or to why it wasn't signed, in the manifest's order.
+//
+
+// batchManifest is what sign -batch reads
+type batchManifest struct {
+	Items []batchItem `json:"items"`
+}
+
+// batchItem is a message or a file to sign, with an id to find its result
+// by, the file or its index if not given
+type batchItem struct {
+	ID      string  `json:"id,omitempty"`
+	Message *string `json:"message,omitempty"`
+	File    string  `json:"file,omitempty"`
+	// path is File resolved against the manifest's directory
+	path string
+}
+
+// batchResults is what sign -batch writes
+type batchResults struct {
+	PublicKey string        `json:"publicKey"`
+	Signed    int           `json:"signed"`
+	Failed    int           `json:"failed"`
+	Results   []batchResult `json:"results"`
+}
+
+type batchResult struct {
+	ID        string `json:"id"`
+	File      string `json:"file,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// readBatchManifest reads the manifest, checking every item is a message
+// or a file and ids are unique, and resolves files against its directory
+func readBatchManifest(path string) ([]batchItem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest batchManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("manifest %s is not json: %v", path, err)
+	}
+	if len(manifest.Items) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no items", path)
+	}
+	ids := map[string]bool{}
+	for i := range manifest.Items {
+		item := &manifest.Items[i]
+		if (item.Message == nil) == (item.File == "") {
+			return nil, fmt.Errorf("item %d of the manifest must have a message or a file", i)
+		}
+		item.path = item.File
+		if item.File != "" && !filepath.IsAbs(item.File) {
+			item.path = filepath.Join(filepath.Dir(path), item.File)
+		}
+		if item.ID == "" {
+			item.ID = item.File
+			if item.ID == "" {
+				item.ID = fmt.Sprint(i)
+			}
+		}
+		if ids[item.ID] {
+			return nil, fmt.Errorf("item id %q is in the manifest twice", item.ID)
+		}
+		ids[item.ID] = true
+	}
+	return manifest.Items, nil
+}
+
+// signBatch signs the manifest's items and writes the results to output,
+// stdout if empty. Items that fail don't stop the others, and make it
+// return false.
+func signBatch(manifest, output string, text *textnorm.Options, privateKeyFlag string, hookSet *hooks.Set, templates *msgtemplate.Policy) bool {
+	items, err := readBatchManifest(manifest)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	signer, err := newMessageSigner(privateKeyFlag, text, hookSet, templates)
+	if err != nil {
+		fail(err)
+		return false
+	}
+
+	results := batchResults{PublicKey: hex.EncodeToString(signer.publicKey()), Results: []batchResult{}}
+	for _, item := range items {
+		result := batchResult{ID: item.ID, File: item.File}
+		message := ""
+		if item.Message != nil {
+			message = *item.Message
+		}
+		signature, _, err := signer.sign(message, item.path)
+		if err != nil {
+			result.Error = err.Error()
+			results.Failed++
+		} else {
+			result.Signature = hex.EncodeToString(signature)
+			results.Signed++
+		}
+		results.Results = append(results.Results, result)
+	}
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		fail(err)
+		return false
+	}
+	if err := writeOrPrint(output, data); err != nil {
+		fail(err)
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "signed %d of %d items\n", results.Signed, len(items))
+	return results.Failed == 0
+}
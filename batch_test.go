@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/dirverify"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestReadBatchManifest(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		wantIDs []string
+		wantErr string
+	}{
+		{
+			name:    "messages and files",
+			content: `{"items": [{"id": "greeting", "message": "hello"}, {"file": "a.txt"}, {"message": ""}]}`,
+			wantIDs: []string{"greeting", "a.txt", "2"},
+		},
+		{
+			name:    "neither message nor file",
+			content: `{"items": [{"id": "greeting"}]}`,
+			wantErr: "must have a message or a file",
+		},
+		{
+			name:    "message and file",
+			content: `{"items": [{"message": "hello", "file": "a.txt"}]}`,
+			wantErr: "must have a message or a file",
+		},
+		{
+			name:    "duplicate ids",
+			content: `{"items": [{"file": "a.txt"}, {"id": "a.txt", "message": "hello"}]}`,
+			wantErr: `item id "a.txt" is in the manifest twice`,
+		},
+		{
+			name:    "no items",
+			content: `{"items": []}`,
+			wantErr: "lists no items",
+		},
+		{
+			name:    "not json",
+			content: `items: []`,
+			wantErr: "is not json",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			dir := t.TempDir()
+			path := writeTestFile(dir, "manifest.json", tt.content, t)
+
+			// when
+			items, err := readBatchManifest(path)
+
+			// then
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readBatchManifest() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error from readBatchManifest: %v", err)
+			}
+			var ids []string
+			for _, item := range items {
+				ids = append(ids, item.ID)
+				if item.File != "" && item.path != filepath.Join(dir, item.File) {
+					t.Fatalf("readBatchManifest() resolved %s to %s, want it relative to the manifest", item.File, item.path)
+				}
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Fatalf("readBatchManifest() ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestSignBatch(t *testing.T) {
+	// given a manifest of messages and files, one of them missing
+	dir := t.TempDir()
+	writeTestFile(dir, "a.txt", "file contents\n", t)
+	if err := os.Mkdir(filepath.Join(dir, "dist"), 0755); err != nil {
+		t.Fatalf("Unexpected error from os.Mkdir: %v", err)
+	}
+	writeTestFile(dir, filepath.Join("dist", "app.tar"), "not really a tarball", t)
+	manifest := writeTestFile(dir, "manifest.json", `{"items": [
+		{"id": "greeting", "message": "hello"},
+		{"file": "a.txt"},
+		{"id": "empty", "message": ""},
+		{"file": "missing.txt"},
+		{"id": "app", "file": "dist/app.tar"}
+	]}`, t)
+	output := filepath.Join(dir, "results.json")
+
+	// when
+	ok := signBatch(manifest, output, nil, testPrivateKey, nil, nil)
+
+	// then
+	if ok {
+		t.Fatalf("signBatch() = true, want false as an item failed")
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Unexpected error from os.ReadFile: %v", err)
+	}
+	var results batchResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatalf("Unexpected error from json.Unmarshal: %v", err)
+	}
+	pubkey, _ := signTestMessage("", t)
+	if results.PublicKey != pubkey {
+		t.Fatalf("signBatch() publicKey = %s, want %s", results.PublicKey, pubkey)
+	}
+	if results.Signed != 4 || results.Failed != 1 {
+		t.Fatalf("signBatch() signed %d and failed %d, want 4 and 1", results.Signed, results.Failed)
+	}
+	if len(results.Results) != 5 {
+		t.Fatalf("signBatch() wrote %d results, want 5", len(results.Results))
+	}
+
+	for i, want := range []struct {
+		id, file, message string
+		failed            bool
+	}{
+		{id: "greeting", message: "hello"},
+		{id: "a.txt", file: "a.txt"},
+		{id: "empty", message: ""},
+		{id: "missing.txt", file: "missing.txt", failed: true},
+		{id: "app", file: "dist/app.tar"},
+	} {
+		r := results.Results[i]
+		if r.ID != want.id || r.File != want.file {
+			t.Fatalf("signBatch() result %d is %s %s, want %s %s", i, r.ID, r.File, want.id, want.file)
+		}
+		if want.failed {
+			if r.Error == "" || r.Signature != "" {
+				t.Fatalf("signBatch() result %s = %+v, want an error and no signature", r.ID, r)
+			}
+			continue
+		}
+		if r.Error != "" {
+			t.Fatalf("Unexpected error signing %s: %s", r.ID, r.Error)
+		}
+
+		in := ""
+		if want.file != "" {
+			in = filepath.Join(dir, want.file)
+		}
+		verified, err := verifyMessage(results.PublicKey, want.message, in, nil, r.Signature)
+		if err != nil {
+			t.Fatalf("Unexpected error from verifyMessage(%s): %v", r.ID, err)
+		}
+		if !verified {
+			t.Fatalf("signature of %s does not verify", r.ID)
+		}
+	}
+
+	t.Run("The results verify as a batch", func(t *testing.T) {
+		// every item but the one sign -batch failed on
+		if got := runVerifyBatch(output, manifest, string(schnorr.SchemeDecred), "blake256", nil, 0, true, nil); got != 1 {
+			t.Fatalf("runVerifyBatch() = %d, want 1 for the missing file alone", got)
+		}
+		items, err := readVerifyBatch(output, manifest)
+		if err != nil {
+			t.Fatalf("Unexpected error from readVerifyBatch: %v", err)
+		}
+		newHash, _ := verifyBatchHash("blake256")
+		for i, r := range verifyBatch(items, schnorr.SchemeDecred, newHash, nil, 0) {
+			if (r.Status == dirverify.StatusOK) != (items[i].ID != "missing.txt") {
+				t.Fatalf("verifyBatch() result for %s = %s %v", items[i].ID, r.Status, r.Err)
+			}
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	bip340 "github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	dcrschnorr "github.com/decred/dcrd/dcrec/secp256k1/v4/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/halfagg"
+	"github.com/ryohare/schnorr-go/pkg/p256"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// benchCase is one operation of one scheme. op runs the operation i and
+// returns how many signatures it covered so batches can be compared per
+// signature.
+type benchCase struct {
+	scheme string
+	op     string
+	run    func(i int) (int, error)
+}
+
+type benchResult struct {
+	benchCase
+	ops       int
+	sigs      int
+	elapsed   time.Duration
+	latencies []time.Duration
+	err       error
+}
+
+func runBench(args []string) {
+	fs := newFlagSet("bench")
+	durationPtr := fs.Duration("duration", time.Second, "how long to run each operation")
+	schemePtr := fs.String("scheme", "all", "comma separated schemes to run: legacy, bip340, dcrd, p256")
+	batchPtr := fs.Int("batch", 64, "number of signatures per batch verification")
+	parseFlags(fs, args)
+
+	if *batchPtr < 1 {
+		failf("-batch must be at least 1")
+		return
+	}
+
+	cases, err := benchCases(*batchPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	wanted := map[string]bool{}
+	for _, s := range strings.Split(*schemePtr, ",") {
+		wanted[strings.TrimSpace(s)] = true
+	}
+
+	fmt.Printf("%s %s/%s, %d CPUs, GOMAXPROCS=%d\n\n", runtime.Version(), runtime.GOOS, runtime.GOARCH, runtime.NumCPU(), runtime.GOMAXPROCS(0))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "scheme\top\tops/s\tsigs/s\tmean\tp50\tp99\t")
+
+	for _, c := range cases {
+		if !wanted["all"] && !wanted[c.scheme] {
+			continue
+		}
+
+		r := measure(c, *durationPtr)
+		if r.err != nil {
+			fmt.Fprintf(w, "%s\t%s\terror: %v\t\t\t\t\t\n", c.scheme, c.op, r.err)
+			continue
+		}
+
+		secs := r.elapsed.Seconds()
+		fmt.Fprintf(w, "%s\t%s\t%.0f\t%.0f\t%v\t%v\t%v\t\n",
+			c.scheme, c.op,
+			float64(r.ops)/secs, float64(r.sigs)/secs,
+			(r.elapsed / time.Duration(r.ops)).Round(time.Microsecond/10),
+			percentile(r.latencies, 0.50), percentile(r.latencies, 0.99))
+	}
+
+	w.Flush()
+}
+
+func measure(c benchCase, duration time.Duration) benchResult {
+	r := benchResult{benchCase: c}
+
+	start := time.Now()
+	for i := 0; time.Since(start) < duration; i++ {
+		opStart := time.Now()
+		n, err := c.run(i)
+		r.latencies = append(r.latencies, time.Since(opStart))
+		if err != nil {
+			r.err = err
+			return r
+		}
+		r.ops++
+		r.sigs += n
+	}
+	r.elapsed = time.Since(start)
+
+	return r
+}
+
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(float64(len(sorted)-1)*p)].Round(time.Microsecond / 10)
+}
+
+// benchCases prepares keys, messages and signatures up front so only the
+// operation itself is timed
+func benchCases(batch int) ([]benchCase, error) {
+	const keys = 16
+
+	privs := make([]*big.Int, keys)
+	pubs := make([][33]byte, keys)
+	msgs := make([][32]byte, keys)
+	for i := range privs {
+		seed := sha256.Sum256([]byte(fmt.Sprintf("bench key %d", i)))
+		privs[i] = new(big.Int).Mod(new(big.Int).SetBytes(seed[:]), schnorr.Curve.N)
+		msgs[i] = sha256.Sum256(seed[:])
+
+		px, py := schnorr.Curve.ScalarBaseMult(schnorr.GetBigIntBytesImmutable(privs[i]))
+		copy(pubs[i][:], schnorr.Marshal(schnorr.Curve, px, py))
+	}
+
+	// legacy
+	legacySigs := make([][64]byte, keys)
+	for i := range privs {
+		sig, err := schnorr.Sign(privs[i], msgs[i])
+		if err != nil {
+			return nil, err
+		}
+		legacySigs[i] = sig
+	}
+
+	// bip340
+	btcPrivs := make([]*btcec.PrivateKey, keys)
+	btcPubs := make([]*btcec.PublicKey, keys)
+	bip340Sigs := make([]*bip340.Signature, keys)
+	for i := range privs {
+		btcPrivs[i], btcPubs[i] = btcec.PrivKeyFromBytes(schnorr.GetBigIntBytesImmutable(privs[i]))
+		sig, err := bip340.Sign(btcPrivs[i], msgs[i][:])
+		if err != nil {
+			return nil, err
+		}
+		bip340Sigs[i] = sig
+	}
+
+	pms := make([]halfagg.PubKeyMsg, batch)
+	batchSigs := make([][64]byte, batch)
+	for i := range pms {
+		copy(pms[i].PubKey[:], bip340.SerializePubKey(btcPubs[i%keys]))
+		pms[i].Msg = msgs[i%keys]
+		copy(batchSigs[i][:], bip340Sigs[i%keys].Serialize())
+	}
+	aggsig, err := halfagg.Aggregate(pms, batchSigs)
+	if err != nil {
+		return nil, err
+	}
+
+	// dcrd
+	dcrPrivs := make([]*secp256k1.PrivateKey, keys)
+	dcrSigs := make([]*dcrschnorr.Signature, keys)
+	for i := range privs {
+		dcrPrivs[i] = secp256k1.PrivKeyFromBytes(schnorr.GetBigIntBytesImmutable(privs[i]))
+		sig, err := dcrschnorr.Sign(dcrPrivs[i], msgs[i][:])
+		if err != nil {
+			return nil, err
+		}
+		dcrSigs[i] = sig
+	}
+
+	// p256
+	p256Privs := make([]*big.Int, keys)
+	p256Pubs := make([][33]byte, keys)
+	p256Sigs := make([][64]byte, keys)
+	for i := range privs {
+		p256Privs[i] = new(big.Int).Mod(privs[i], p256.Curve.Params().N)
+		pub, err := p256.PublicKey(p256Privs[i])
+		if err != nil {
+			return nil, err
+		}
+		p256Pubs[i] = pub
+		sig, err := p256.Sign(p256Privs[i], msgs[i])
+		if err != nil {
+			return nil, err
+		}
+		p256Sigs[i] = sig
+	}
+
+	verified := func(ok bool, err error) (int, error) {
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, fmt.Errorf("verification failed")
+		}
+		return 1, nil
+	}
+
+	return []benchCase{
+		{"legacy", "sign", func(i int) (int, error) {
+			_, err := schnorr.Sign(privs[i%keys], msgs[i%keys])
+			return 1, err
+		}},
+		{"legacy", "verify", func(i int) (int, error) {
+			return verified(schnorr.Verify(pubs[i%keys], msgs[i%keys], legacySigs[i%keys]))
+		}},
+		{"legacy", fmt.Sprintf("batch-verify(%d)", batch), func(i int) (int, error) {
+			for j := 0; j < batch; j++ {
+				if _, err := verified(schnorr.Verify(pubs[j%keys], msgs[j%keys], legacySigs[j%keys])); err != nil {
+					return 0, err
+				}
+			}
+			return batch, nil
+		}},
+		{"bip340", "sign", func(i int) (int, error) {
+			_, err := bip340.Sign(btcPrivs[i%keys], msgs[i%keys][:])
+			return 1, err
+		}},
+		{"bip340", "verify", func(i int) (int, error) {
+			return verified(bip340Sigs[i%keys].Verify(msgs[i%keys][:], btcPubs[i%keys]), nil)
+		}},
+		{"bip340", fmt.Sprintf("batch-verify(%d)", batch), func(i int) (int, error) {
+			ok, err := halfagg.Verify(pms, aggsig)
+			if _, err := verified(ok, err); err != nil {
+				return 0, err
+			}
+			return batch, nil
+		}},
+		{"dcrd", "sign", func(i int) (int, error) {
+			_, err := dcrschnorr.Sign(dcrPrivs[i%keys], msgs[i%keys][:])
+			return 1, err
+		}},
+		{"dcrd", "verify", func(i int) (int, error) {
+			return verified(dcrSigs[i%keys].Verify(msgs[i%keys][:], dcrPrivs[i%keys].PubKey()), nil)
+		}},
+		{"p256", "sign", func(i int) (int, error) {
+			_, err := p256.Sign(p256Privs[i%keys], msgs[i%keys])
+			return 1, err
+		}},
+		{"p256", "verify", func(i int) (int, error) {
+			return verified(p256.Verify(p256Pubs[i%keys], msgs[i%keys], p256Sigs[i%keys]))
+		}},
+	}, nil
+}
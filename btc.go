@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/btctx"
+	"github.com/ryohare/schnorr-go/pkg/descriptor"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	sg "github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func runBTC(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go btc <sign-tx|sign-digest> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "sign-tx":
+		btcSignTx(args[1:])
+	case "sign-digest":
+		btcSignDigest(args[1:])
+	default:
+		unknownCommand("btc", args)
+	}
+}
+
+// parsePrevout reads value:scriptpubkeyhex, the value in satoshis
+func parsePrevout(s string) (*btctx.TxOut, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("prevout %q is not value:scriptpubkey", s)
+	}
+	value, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("prevout %q has a bad value", s)
+	}
+	script, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("prevout %q has a bad script: %v", s, err)
+	}
+	return &btctx.TxOut{Value: value, PkScript: script}, nil
+}
+
+func btcSignTx(args []string) {
+	var prevoutFlags stringList
+
+	fs := newFlagSet("btc sign-tx")
+	txPtr := fs.String("tx", "", "raw transaction in hex")
+	fs.Var(&prevoutFlags, "prevout", "value:scriptpubkey of the output each input spends, in input order, repeated for every input")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, prompted for if empty")
+	descriptorPtr := fs.String("descriptor", "", "tr() descriptor of the outputs to sign for, tr(key) if empty")
+	sigHashPtr := fs.String("sighash", "default", "default, all, none or single, optionally with |anyonecanpay")
+	inputPtr := fs.Int("input", -1, "input to sign, every input spending the descriptor's output if negative")
+	parseFlags(fs, args)
+
+	raw, err := hex.DecodeString(*txPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	tx, err := btctx.ParseTx(raw)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	prevouts := []*btctx.TxOut{}
+	for _, s := range prevoutFlags {
+		out, err := parsePrevout(s)
+		if err != nil {
+			fail(err)
+			return
+		}
+		prevouts = append(prevouts, out)
+	}
+	if len(prevouts) != len(tx.Inputs) {
+		failf("transaction has %d inputs, got %d prevouts", len(tx.Inputs), len(prevouts))
+		return
+	}
+
+	hashType, err := btctx.ParseSigHashType(*sigHashPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	privateKey, err := secretPrivateKey(prompt.New(), *privateKeyPtr, "Private key (hex or WIF): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	keyBytes, err := hex.DecodeString(privateKey)
+	if err != nil || len(keyBytes) != 32 {
+		failf("private key is not 32 bytes of hex")
+		return
+	}
+	key, _ := btcec.PrivKeyFromBytes(keyBytes)
+
+	desc := *descriptorPtr
+	if desc == "" {
+		desc = "tr(" + hex.EncodeToString(schnorr.SerializePubKey(key.PubKey())) + ")"
+	}
+	d, err := descriptor.Parse(desc)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	inputs := []int{*inputPtr}
+	if *inputPtr < 0 {
+		script, err := d.ScriptPubKey()
+		if err != nil {
+			fail(err)
+			return
+		}
+		inputs = nil
+		for i, out := range prevouts {
+			if bytes.Equal(out.PkScript, script) {
+				inputs = append(inputs, i)
+			}
+		}
+		if len(inputs) == 0 {
+			failf("no input spends the descriptor's output")
+			return
+		}
+	}
+
+	for _, i := range inputs {
+		result, err := btctx.SignInput(tx, prevouts, i, d, []*btcec.PrivateKey{key}, hashType)
+		if err != nil {
+			failf("input %d: %v", i, err)
+			return
+		}
+		path := "key path"
+		if !result.KeyPath {
+			path = "script path " + result.Leaf.String()
+		}
+		fmt.Printf("signed input %d on the %s\n", i, path)
+	}
+
+	fmt.Println(hex.EncodeToString(tx.Serialize()))
+}
+
+// btcSignDigest signs a sighash computed elsewhere, such as by a wallet
+// building the transaction, with BIP-340, by default for the key's P2TR
+// output key as key path spends are
+func btcSignDigest(args []string) {
+	fs := newFlagSet("btc sign-digest")
+	digestPtr := fs.String("digest", "", "32 byte sighash to sign, in hex")
+	privateKeyPtr := fs.String("privkey", "", "internal private key to sign with, prompted for if empty")
+	tweakPtr := fs.Bool("tweak", true, "apply the BIP-341 taproot tweak to the key before signing")
+	merkleRootPtr := fs.String("merkle-root", "", "with -tweak, merkle root of the output's script tree in hex, none for a key path only output")
+	parseFlags(fs, args)
+
+	digest, err := hex.DecodeString(*digestPtr)
+	if err != nil || len(digest) != 32 {
+		failf("-digest must be 32 bytes of hex")
+		return
+	}
+	merkleRoot, err := hex.DecodeString(*merkleRootPtr)
+	if err != nil {
+		failf("-merkle-root is not hex")
+		return
+	}
+	if len(merkleRoot) > 0 && !*tweakPtr {
+		failf("-merkle-root needs -tweak")
+		return
+	}
+
+	privateKey, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	key, err := sg.NewPrivateKey(privateKey)
+	if err != nil {
+		fail(err)
+		return
+	}
+	d := privateKey
+	publickey := key.PublicKey().SerializeXOnly()
+	if *tweakPtr {
+		if d, err = sg.TweakPrivateKey(privateKey, merkleRoot); err != nil {
+			fail(err)
+			return
+		}
+		if publickey, _, err = sg.TweakPublicKey(publickey, merkleRoot); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	var message [32]byte
+	copy(message[:], digest)
+	signature, err := sg.SignScheme(sg.SchemeBIP340, d, message)
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("output key %x\n", publickey)
+	fmt.Printf("%x\n", signature)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/bundle"
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/nostr"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/revocation"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Offline verification bundles. The signer gathers the delegations and the
+// revocations it knows of, a timestamping key may stamp the result, and the
+// verifier needs nothing but the bundle and the keys it trusts.
+//
+
+func runBundle(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go bundle <delegate|create|stamp|verify> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "delegate":
+		bundleDelegate(args[1:])
+	case "create":
+		bundleCreate(args[1:])
+	case "stamp":
+		bundleStamp(args[1:])
+	case "verify":
+		bundleVerify(args[1:])
+	default:
+		unknownCommand("bundle", args)
+	}
+}
+
+func bundleDelegate(args []string) {
+	fs := newFlagSet("bundle delegate")
+	privateKeyPtr := fs.String("privkey", "", "private key delegating, prompted for if empty")
+	toPtr := fs.String("to", "", "public key being delegated to")
+	validPtr := fs.Duration("valid", 365*24*time.Hour, "how long the delegation lasts from now")
+	commentPtr := fs.String("comment", "", "free text kept in the delegation")
+	outputPtr := fs.String("output", "", "file to write the delegation to, stdout if empty")
+	parseFlags(fs, args)
+
+	to, err := parsePublicKeyHex(*toPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	now := time.Now()
+	e, err := bundle.Delegate(d, to, now, now.Add(*validPtr), *commentPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+	}
+}
+
+func bundleCreate(args []string) {
+	var delegations stringList
+
+	fs := newFlagSet("bundle create")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, prompted for if empty")
+	messagePtr := fs.String("message", "", "message to be signed, its sha256 is what is signed")
+	filePtr := fs.String("file", "", "file to be signed instead of -message")
+	fs.Var(&delegations, "delegation", "delegation file, from the root down to the signing key, repeated for each link")
+	revocationsPtr := fs.String("revocations", "", "directory of published revocation certificates to snapshot")
+	outputPtr := fs.String("output", "", "file to write the bundle to, stdout if empty")
+	parseFlags(fs, args)
+
+	digest, err := messageDigest(*messagePtr, *filePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	signature, err := schnorr.Sign(d, digest)
+	if err != nil {
+		fail(err)
+		return
+	}
+	publickey, _ := schnorr.ScalarBaseMult(d).PublicKey()
+	b := bundle.New(digest, signature, publickey)
+
+	for _, path := range delegations {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fail(err)
+			return
+		}
+		e := new(envelope.Envelope)
+		if err := json.Unmarshal(data, e); err != nil {
+			failf("%s: %v", path, err)
+			return
+		}
+		b.Delegations = append(b.Delegations, e)
+	}
+
+	if *revocationsPtr != "" {
+		revoked := revocation.NewSet()
+		if err := revoked.LoadDir(*revocationsPtr); err != nil {
+			fail(err)
+			return
+		}
+		if err := b.SetRevocations(revoked.Certificates(), time.Now()); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+	}
+}
+
+func bundleStamp(args []string) {
+	fs := newFlagSet("bundle stamp")
+	privateKeyPtr := fs.String("privkey", "", "timestamping private key, prompted for if empty")
+	bundlePtr := fs.String("bundle", "", "bundle file to stamp")
+	outputPtr := fs.String("output", "", "file to write the stamped bundle to, stdout if empty")
+	parseFlags(fs, args)
+
+	b, err := readBundle(*bundlePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if b.Timestamp, err = bundle.Stamp(d, b, time.Now()); err != nil {
+		fail(err)
+		return
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+	}
+}
+
+func bundleVerify(args []string) {
+	var roots, timestampKeys stringList
+
+	fs := newFlagSet("bundle verify")
+	bundlePtr := fs.String("bundle", "", "bundle file to verify")
+	fs.Var(&roots, "root", "public key trusted to sign or delegate, can be repeated")
+	fs.Var(&timestampKeys, "timestamp-key", "public key trusted to timestamp, can be repeated")
+	requireTimestampPtr := fs.Bool("require-timestamp", false, "refuse bundles without a trusted timestamp")
+	messagePtr := fs.String("message", "", "message the bundle must be a signature of")
+	filePtr := fs.String("file", "", "file the bundle must be a signature of, instead of -message")
+	parseFlags(fs, args)
+
+	b, err := readBundle(*bundlePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	policy := bundle.Policy{RequireTimestamp: *requireTimestampPtr}
+	for _, list := range []struct {
+		keys []string
+		out  *[][33]byte
+	}{{roots, &policy.Roots}, {timestampKeys, &policy.TimestampKeys}} {
+		for _, s := range list.keys {
+			pk, err := parsePublicKeyHex(s)
+			if err != nil {
+				fail(err)
+				return
+			}
+			*list.out = append(*list.out, pk)
+		}
+	}
+
+	res, err := bundle.Verify(b, policy)
+	if err == nil && (*messagePtr != "" || *filePtr != "") {
+		var digest [32]byte
+		if digest, err = messageDigest(*messagePtr, *filePtr); err == nil && digest != res.Digest {
+			err = fmt.Errorf("bundle is for a different message")
+		}
+	}
+	if err != nil {
+		fail(err)
+		printVerified("Signature", false)
+		return
+	}
+
+	fmt.Printf("signed by %x under root %x\n", res.PublicKey, res.Root)
+	if res.SignedAt != nil {
+		fmt.Printf("timestamped %s\n", res.SignedAt.Format(time.RFC3339))
+	}
+	if b.RevocationsAsOf != nil {
+		fmt.Printf("not revoked as of %s\n", b.RevocationsAsOf.Format(time.RFC3339))
+	}
+	printVerified("Signature", true)
+}
+
+func readBundle(path string) (*bundle.Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return bundle.Parse(data)
+}
+
+// messageDigest is the sha256 of the file if given, else of the message
+func messageDigest(message, path string) ([32]byte, error) {
+	if path == "" {
+		return sha256.Sum256([]byte(message)), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+func readPrivateKeyHex(flagValue string) (*big.Int, error) {
+	privateKey, err := secretPrivateKey(prompt.New(), flagValue, "Private key (hex or WIF): ")
+	if err != nil {
+		return nil, err
+	}
+	key, err := schnorr.ParsePrivateKeyHex(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return key.D(), nil
+}
+
+func parsePublicKeyHex(s string) ([33]byte, error) {
+	s, err := nostr.PublicKeyHex(s)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	pk, err := schnorr.ParsePublicKeyHex(s)
+	if err != nil {
+		return [33]byte{}, fmt.Errorf("public key must be 32 or 33 hex encoded bytes on the curve: %v", err)
+	}
+	return pk.Serialize(), nil
+}
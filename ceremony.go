@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/ceremony"
+	"github.com/ryohare/schnorr-go/pkg/frost"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+)
+
+// shareFile is what a FROST participant keeps from key generation
+type shareFile struct {
+	KeyShare         *frost.KeyShare         `json:"key_share"`
+	PublicKeyPackage *frost.PublicKeyPackage `json:"public_key_package"`
+}
+
+func runCeremony(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go ceremony <musig|frost|simulate> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "musig":
+		ceremonyMuSig(args[1:])
+	case "frost":
+		ceremonyFROST(args[1:])
+	case "simulate":
+		ceremonySimulate(args[1:])
+	default:
+		unknownCommand("ceremony", args)
+	}
+}
+
+func ceremonyMuSig(args []string) {
+	fs := newFlagSet("ceremony musig")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, prompted for if empty")
+	messagePtr := fs.String("message", "", "message to be signed, its sha256 is what is signed")
+	parseFlags(fs, args)
+
+	p := prompt.New()
+	privateKey, err := secretPrivateKey(p, *privateKeyPtr, "Private key (hex or WIF): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, ok := new(big.Int).SetString(privateKey, 16)
+	if !ok {
+		failf("private key is not hex")
+		return
+	}
+
+	digest := sha256.Sum256([]byte(*messagePtr))
+	if _, err := ceremony.New(p, 4).RunMuSig(d, digest[:]); err != nil {
+		fail(err)
+	}
+}
+
+func ceremonyFROST(args []string) {
+	fs := newFlagSet("ceremony frost")
+	sharePtr := fs.String("share", "", "json file with the key_share and public_key_package")
+	messagePtr := fs.String("message", "", "message to be signed, its sha256 is what is signed")
+	parseFlags(fs, args)
+
+	data, err := os.ReadFile(*sharePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	share := shareFile{}
+	if err := json.Unmarshal(data, &share); err != nil {
+		fail(err)
+		return
+	}
+	if share.KeyShare == nil || share.PublicKeyPackage == nil {
+		failf("share file needs a key_share and a public_key_package")
+		return
+	}
+
+	digest := sha256.Sum256([]byte(*messagePtr))
+	if _, err := ceremony.New(prompt.New(), 4).RunFROST(share.KeyShare, share.PublicKeyPackage, digest[:]); err != nil {
+		fail(err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/chunked"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+)
+
+func runChunked(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go chunked <sign|verify> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "sign":
+		chunkedSign(args[1:])
+	case "verify":
+		chunkedVerify(args[1:])
+	default:
+		unknownCommand("chunked", args)
+	}
+}
+
+func chunkedSign(args []string) {
+	fs := newFlagSet("chunked sign")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign the manifest with, prompted for if empty")
+	filePtr := fs.String("file", "", "file to sign")
+	chunkSizePtr := fs.Int64("chunk-size", chunked.DefaultChunkSize, "chunk size in bytes")
+	workersPtr := fs.Int("workers", 0, "number of chunks hashed at once, all cpus if 0")
+	outputPtr := fs.String("output", "", "file to write the manifest to, stdout if empty")
+	parseFlags(fs, args)
+
+	privateKey, err := secretPrivateKey(prompt.New(), *privateKeyPtr, "Private key (hex or WIF): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, ok := new(big.Int).SetString(privateKey, 16)
+	if !ok {
+		failf("private key is not hex")
+		return
+	}
+
+	f, err := os.Open(*filePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	m, err := chunked.Sign(d, f, info.Size(), *chunkSizePtr, *workersPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+	}
+}
+
+func chunkedVerify(args []string) {
+	fs := newFlagSet("chunked verify")
+	pubKeyPtr := fs.String("pubkey", "", "public key to verify the manifest with")
+	filePtr := fs.String("file", "", "file to verify")
+	manifestPtr := fs.String("manifest", "", "manifest file")
+	workersPtr := fs.Int("workers", 0, "number of chunks hashed at once, all cpus if 0")
+	parseFlags(fs, args)
+
+	var pk [33]byte
+	pkBytes, err := hex.DecodeString(*pubKeyPtr)
+	if err != nil || len(pkBytes) != 33 {
+		failf("public key must be 33 hex encoded bytes")
+		return
+	}
+	copy(pk[:], pkBytes)
+
+	data, err := os.ReadFile(*manifestPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	m := new(chunked.Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		fail(err)
+		return
+	}
+	if err := chunked.Verify(m, pk); err != nil {
+		fail(err)
+		printVerified("Signature", false)
+		return
+	}
+
+	f, err := os.Open(*filePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	bad, err := m.VerifyReader(f, info.Size(), *workersPtr)
+	if err != nil {
+		fail(err)
+		printVerified("Signature", false)
+		return
+	}
+	for _, i := range bad {
+		fmt.Printf("chunk %d (bytes %d-%d) does not match\n", i, int64(i)*m.ChunkSize, int64(i+1)*m.ChunkSize-1)
+	}
+	printVerified("Signature", len(bad) == 0)
+}
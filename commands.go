@@ -0,0 +1,103 @@
+package main
+
+import "fmt"
+
+//
+// The subcommands, each with its own flag set. `schnorr-go help <command>`
+// prints a command's flags, or the commands of a group, and the bare -sign
+// and -verify flags of the first releases are kept working on top of the
+// sign and verify commands.
+//
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+	// subcommands is set for commands that are a group of commands, each
+	// with its own flags
+	subcommands bool
+}
+
+// commands is filled in by init, as help refers back to it
+var commands []command
+
+func init() {
+	commands = []command{
+		{"sign", "sign a message", runSign, false},
+		{"verify", "verify a message signature, or files against their .sig sidecars", runVerify, false},
+		{"aggregate", "sign a message with several keys as one signature", runAggregate, false},
+		{"keygen", "make a private key from mixed entropy sources", runKeygen, false},
+		{"restore", "restore a private key from its BIP-39 phrase", runRestore, false},
+		{"inspect", "list the contents and signer of a signed archive", runInspect, false},
+		{"pack", "pack a directory into a signed archive", runPack, false},
+		{"unpack", "verify and unpack a signed archive", runUnpack, false},
+		{"chunked", "sign and verify large files in chunks", runChunked, true},
+		{"bundle", "build and check offline verification bundles", runBundle, true},
+		{"cosign", "sign container images", runCosign, true},
+		{"revoke", "revoke a key", runRevoke, true},
+		{"migrate", "link a legacy key to a BIP-340 identity", runMigrate, true},
+		{"keys", "publish and fetch keys by address, export and import key files, and derive BIP-32 keys", runKeys, true},
+		{"trust", "manage the trust store", runTrust, true},
+		{"expiry", "check and renew expiring signatures", runExpiry, true},
+		{"audit", "sign with nonces an auditor can check", runAudit, true},
+		{"contract", "commit data into a key or a signature nonce", runContract, true},
+		{"attest", "sign reports of what each key signed, from the audit logs", runAttest, true},
+		{"siglog", "compress audit logs of signatures by one key", runSiglog, true},
+		{"seal", "seal signed documents to a FROST group key", runSeal, true},
+		{"s3", "sign and verify objects in S3-compatible buckets", runS3, true},
+		{"dkg", "run a distributed key generation", runDKG, true},
+		{"frost", "FROST threshold signing", runFROST, true},
+		{"ceremony", "walk through a manual MuSig2 or FROST ceremony, or rehearse one", runCeremony, true},
+		{"twoparty", "two-party signing with a server", runTwoParty, true},
+		{"pipe", "sign and verify json requests read line by line from stdin", runPipe, false},
+		{"daemon", "serve the signing api, ceremonies and drop directory signing", runDaemon, false},
+		{"btc", "sign bitcoin transactions", runBTC, true},
+		{"psbt", "sign the taproot inputs of a psbt", runPSBT, true},
+		{"nostr", "nostr events", runNostr, true},
+		{"lnurl", "LNURL-auth", runLNURL, true},
+		{"dpop", "DPoP proofs", runDPoP, true},
+		{"vectors", "print reproducible test vectors, or generate a conformance suite", runVectors, true},
+		{"bench", "benchmark signing and verification", runBench, false},
+		{"help", "show the flags of a command", runHelp, false},
+	}
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func usage() {
+	fmt.Println("usage: schnorr-go <command> [flags]")
+	fmt.Println()
+	fmt.Println("commands:")
+	for _, c := range commands {
+		fmt.Printf("  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Println()
+	fmt.Println("schnorr-go help <command> shows the flags of a command, and any")
+	fmt.Println("command given -json prints its outcome as json")
+}
+
+func runHelp(args []string) {
+	if len(args) == 0 {
+		usage()
+		return
+	}
+	c := findCommand(args[0])
+	if c == nil || c.name == "help" {
+		failf("unknown command %q", args[0])
+		exit(2)
+	}
+	if c.subcommands && len(args) == 1 {
+		// the group prints its commands when given none; vectors, which
+		// runs bare, tells help's nil from an empty command line
+		c.run(nil)
+		return
+	}
+	c.run(append(args[1:], "-h"))
+}
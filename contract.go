@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Pay-to-contract and sign-to-contract commitments, see pkg/schnorr. tweak
+// and verify-key commit data into a key, sign and verify commit it into a
+// signature's nonce.
+//
+
+type contractProofFile struct {
+	NonceCommitment string `json:"nonce_commitment"`
+}
+
+func runContract(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go contract <tweak|verify-key|sign|verify> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "tweak":
+		contractTweak(args[1:])
+	case "verify-key":
+		contractVerifyKey(args[1:])
+	case "sign":
+		contractSign(args[1:])
+	case "verify":
+		contractVerify(args[1:])
+	default:
+		unknownCommand("contract", args)
+	}
+}
+
+// contractData is the data committed to, given as -data or -data-file
+func contractData(data, path string) ([]byte, error) {
+	switch {
+	case data != "" && path != "":
+		return nil, fmt.Errorf("pass -data or -data-file, not both")
+	case path != "":
+		return os.ReadFile(path)
+	case data != "":
+		return []byte(data), nil
+	}
+	return nil, fmt.Errorf("pass the data committed to with -data or -data-file")
+}
+
+func contractTweak(args []string) {
+	fs := newFlagSet("contract tweak")
+	pubKeyPtr := fs.String("pubkey", "", "public key to tweak")
+	privateKeyPtr := fs.String("privkey", "", "private key to tweak instead, prompted for if neither is given")
+	outPtr := fs.String("out", "", "file to write the tweaked private key to, when tweaking a private key")
+	dataPtr := fs.String("data", "", "data to commit to")
+	dataFilePtr := fs.String("data-file", "", "file of data to commit to")
+	parseFlags(fs, args)
+
+	data, err := contractData(*dataPtr, *dataFilePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if *pubKeyPtr != "" {
+		pk, err := parsePublicKeyHex(*pubKeyPtr)
+		if err != nil {
+			fail(err)
+			return
+		}
+		tweaked, err := schnorr.PayToContractPublicKey(pk, data)
+		if err != nil {
+			fail(err)
+			return
+		}
+		fmt.Printf("%x\n", tweaked)
+		recordKey(fmt.Sprintf("%x", tweaked), "", "")
+		return
+	}
+
+	// the tweaked private key spends what was paid to the contract, so it
+	// goes to a file like any other private key
+	if *outPtr == "" {
+		failf("tweaking a private key needs -out for the tweaked key")
+		return
+	}
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	tweaked, err := schnorr.PayToContractPrivateKey(d, data)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := os.WriteFile(*outPtr, []byte(hex.EncodeToString(schnorr.GetBigIntBytesImmutable(tweaked))+"\n"), 0600); err != nil {
+		fail(err)
+		return
+	}
+	pk, err := schnorr.ScalarBaseMult(tweaked).PublicKey()
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("%x\n", pk)
+	recordKey(fmt.Sprintf("%x", pk), *outPtr, "")
+}
+
+func contractVerifyKey(args []string) {
+	fs := newFlagSet("contract verify-key")
+	pubKeyPtr := fs.String("pubkey", "", "public key before the tweak")
+	tweakedPtr := fs.String("tweaked", "", "public key said to commit to the data")
+	dataPtr := fs.String("data", "", "data committed to")
+	dataFilePtr := fs.String("data-file", "", "file of data committed to")
+	parseFlags(fs, args)
+
+	data, err := contractData(*dataPtr, *dataFilePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	pk, err := parsePublicKeyHex(*pubKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	tweaked, err := parsePublicKeyHex(*tweakedPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	ok, err := schnorr.VerifyPayToContract(pk, tweaked, data)
+	if err != nil {
+		fail(err)
+	}
+	printVerified("Commitment", ok)
+}
+
+func contractSign(args []string) {
+	fs := newFlagSet("contract sign")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, prompted for if empty")
+	messagePtr := fs.String("message", "", "message to be signed, its sha256 is what is signed")
+	dataPtr := fs.String("data", "", "data the signature commits to")
+	dataFilePtr := fs.String("data-file", "", "file of data the signature commits to")
+	proofPtr := fs.String("proof", "", "file to write the commitment proof to, stdout if empty")
+	parseFlags(fs, args)
+
+	data, err := contractData(*dataPtr, *dataFilePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	digest, _ := messageDigest(*messagePtr, "")
+	sig, proof, err := schnorr.SignToContract(d, digest, data)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	out, err := json.MarshalIndent(contractProofFile{
+		NonceCommitment: hex.EncodeToString(proof.NonceCommitment[:]),
+	}, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("%x\n", sig)
+	if err := writeOrPrint(*proofPtr, out); err != nil {
+		fail(err)
+		return
+	}
+	if pk, err := schnorr.ScalarBaseMult(d).PublicKey(); err == nil {
+		publickey := hex.EncodeToString(pk[:])
+		recordResult(signOutput{Signature: fmt.Sprintf("%x", sig), PublicKey: publickey, Fingerprint: fingerprintHex(publickey)})
+	}
+}
+
+func contractVerify(args []string) {
+	fs := newFlagSet("contract verify")
+	pubKeyPtr := fs.String("pubkey", "", "public key to verify the signature with")
+	messagePtr := fs.String("message", "", "message that was signed")
+	signaturePtr := fs.String("sig", "", "signature to verify")
+	dataPtr := fs.String("data", "", "data the signature commits to")
+	dataFilePtr := fs.String("data-file", "", "file of data the signature commits to")
+	proofPtr := fs.String("proof", "", "commitment proof file from contract sign")
+	parseFlags(fs, args)
+
+	data, err := contractData(*dataPtr, *dataFilePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	pk, err := parsePublicKeyHex(*pubKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var sig [64]byte
+	if err := decodeHexInto(sig[:], *signaturePtr, "signature"); err != nil {
+		fail(err)
+		return
+	}
+	raw, err := os.ReadFile(*proofPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	file := contractProofFile{}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		fail(err)
+		return
+	}
+	proof := &schnorr.ContractProof{}
+	if err := decodeHexInto(proof.NonceCommitment[:], file.NonceCommitment, "nonce commitment"); err != nil {
+		fail(err)
+		return
+	}
+
+	digest, _ := messageDigest(*messagePtr, "")
+	ok, err := schnorr.VerifySignToContract(pk, digest, sig, data, proof)
+	if err != nil {
+		fail(err)
+	}
+	printVerified("Signature", ok)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/cosign"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+)
+
+func runCosign(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go cosign <sign|verify> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "sign":
+		cosignSign(args[1:])
+	case "verify":
+		cosignVerify(args[1:])
+	default:
+		unknownCommand("cosign", args)
+	}
+}
+
+func parseAnnotations(annotations stringList) (map[string]string, error) {
+	parsed := map[string]string{}
+	for _, a := range annotations {
+		k, v, found := strings.Cut(a, "=")
+		if !found {
+			return nil, fmt.Errorf("annotation %q is not in the form key=value", a)
+		}
+		parsed[k] = v
+	}
+	return parsed, nil
+}
+
+func cosignSign(args []string) {
+	var annotations stringList
+
+	fs := newFlagSet("cosign sign")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign the image with, prompted for if empty")
+	imagePtr := fs.String("image", "", "image to sign, pinned by digest: repo@sha256:...")
+	payloadPtr := fs.String("output-payload", "", "file to write the payload to, stdout if empty")
+	signaturePtr := fs.String("output-signature", "", "file to write the base64 signature to, stdout if empty")
+	fs.Var(&annotations, "a", "annotation in the form key=value, can be repeated")
+	parseFlags(fs, args)
+
+	privateKey, err := secretPrivateKey(prompt.New(), *privateKeyPtr, "Private key (hex or WIF): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	d, ok := new(big.Int).SetString(privateKey, 16)
+	if !ok {
+		failf("private key is not hex")
+		return
+	}
+
+	optional, err := parseAnnotations(annotations)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	reference, digest, err := cosign.ParseImage(*imagePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	p, err := cosign.NewPayload(reference, digest, optional)
+	if err != nil {
+		fail(err)
+		return
+	}
+	payload, err := p.Marshal()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	sig, err := cosign.Sign(d, payload)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if err := writeOrPrint(*payloadPtr, payload); err != nil {
+		fail(err)
+		return
+	}
+	if err := writeOrPrint(*signaturePtr, []byte(sig)); err != nil {
+		fail(err)
+	}
+}
+
+func cosignVerify(args []string) {
+	var annotations stringList
+
+	fs := newFlagSet("cosign verify")
+	pubKeyPtr := fs.String("pubkey", "", "public key to verify the signature with")
+	imagePtr := fs.String("image", "", "image the signature must be for: repo@sha256:...")
+	payloadPtr := fs.String("payload", "", "payload file")
+	signaturePtr := fs.String("signature", "", "base64 signature file")
+	fs.Var(&annotations, "a", "annotation the payload must carry, key=value, can be repeated")
+	parseFlags(fs, args)
+
+	var pk [33]byte
+	pkBytes, err := hex.DecodeString(*pubKeyPtr)
+	if err != nil || len(pkBytes) != 33 {
+		failf("public key must be 33 hex encoded bytes")
+		return
+	}
+	copy(pk[:], pkBytes)
+
+	required, err := parseAnnotations(annotations)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	reference, digest, err := cosign.ParseImage(*imagePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	payload, err := os.ReadFile(*payloadPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	sig, err := os.ReadFile(*signaturePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	p, err := cosign.Verify(pk, payload, string(sig))
+	if err == nil {
+		err = p.Matches(reference, digest, required)
+	}
+	if err != nil {
+		fail(err)
+		printVerified("Signature", false)
+		return
+	}
+	printVerified("Signature", true)
+}
+
+func writeOrPrint(path string, data []byte) error {
+	if path == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	return os.WriteFile(path, data, 0644)
+}
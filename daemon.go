@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/ryohare/schnorr-go/pkg/cluster"
+	"github.com/ryohare/schnorr-go/pkg/coordinator"
+	"github.com/ryohare/schnorr-go/pkg/hooks"
+	"github.com/ryohare/schnorr-go/pkg/metrics"
+	"github.com/ryohare/schnorr-go/pkg/msgtemplate"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/ratelimit"
+	"github.com/ryohare/schnorr-go/pkg/store"
+	"github.com/ryohare/schnorr-go/pkg/twoparty"
+	"github.com/ryohare/schnorr-go/pkg/vault"
+	"github.com/ryohare/schnorr-go/pkg/watch"
+)
+
+func runDaemon(args []string) {
+	var include, exclude, keyOverrides, preSign, postSign stringList
+
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	listenPtr := fs.String("listen", "127.0.0.1:8200", "address to listen on")
+	tokenPtr := fs.String("token", "", "token for the signing api, which is off without one")
+	mountPtr := fs.String("mount", vault.DefaultMount, "path the signing api is mounted at")
+	coordinatorPtr := fs.Bool("coordinator", false, "host MuSig and FROST signing ceremonies")
+	roundTimeoutPtr := fs.Duration("round-timeout", coordinator.DefaultRoundTimeout, "time participants have for each ceremony round")
+	watchPtr := fs.String("watch", "", "drop directory whose new files are signed with .sig sidecars")
+	watchKeyPtr := fs.String("watch-key", "", "private key for -watch, prompted for if empty")
+	watchAuditPtr := fs.String("watch-audit", "", "audit log for -watch, .schnorr-audit.jsonl in the directory if empty")
+	watchMaxSizePtr := fs.Int64("watch-max-size", 0, "largest file -watch signs in bytes, no limit if 0")
+	watchSettlePtr := fs.Duration("watch-settle", watch.DefaultSettleTime, "time a file must be left unmodified before -watch signs it")
+	fs.Var(&include, "watch-include", "glob of file names -watch signs, can be repeated, all files if not given")
+	fs.Var(&exclude, "watch-exclude", "glob of file names -watch never signs, can be repeated")
+	keyLimitPtr := fs.String("key-limit", "", "rate:burst:daily limit on every key of the signing api, such as 5:20:10000")
+	clientLimitPtr := fs.String("client-limit", "", "rate:burst:daily limit on every client of the signing api")
+	fs.Var(&keyOverrides, "key-limit-for", "name=rate:burst:daily limit for one key instead of -key-limit, can be repeated")
+	twoPartyPtr := fs.String("twoparty", "", "directory to keep the server halves of two-party keys in, or state for the -state store, serves the two-party api if set")
+	twoPartyTokenPtr := fs.String("twoparty-token", "", "token clients need for the two-party api")
+	maxKeyAgePtr := fs.Duration("max-key-age", 0, "age at which signing api key versions expire and must be rotated, never if 0")
+	metricsListenPtr := fs.String("metrics-listen", "", "address to serve keystore metrics for Prometheus on at /metrics, off if empty")
+	fs.Var(&preSign, "pre-sign", "program to run before the signing api signs, which may refuse or change the digest, can be repeated")
+	fs.Var(&postSign, "post-sign", "program to run after the signing api signs, which may withhold the signature, can be repeated")
+	templatesPtr := fs.String("templates", "", "message templates restricting what the signing api's keys sign, by key name")
+	idempotencyTTLPtr := fs.Duration("idempotency-ttl", vault.DefaultIdempotencyTTL, "time the signing api replays the signature of a request to retries with its Idempotency-Key")
+	auditPtr := fs.String("audit", "", "file to append the signing api's signatures and refusals to as json lines, the audit log in -state if empty and -state is set")
+	tenantsPtr := fs.String("tenants", "", "json file of tenants, each with a signing api of its own behind its token, see the README")
+	principalHeaderPtr := fs.String("principal-header", "", "header naming the client for -client-limit, set by an authenticating proxy, the client address if empty")
+	statePtr := fs.String("state", "", "store for state shared by replicas, signing api keys included: dir:<path>, memory and files given by the flags above if empty")
+	clusterPtr := fs.String("cluster", "", "url other replicas reach this one at, runs it as a replica electing a leader through -state")
+	leaseTTLPtr := fs.Duration("lease-ttl", cluster.DefaultTTL, "time the leader of a -cluster has to renew its lease before another replica takes over")
+	fs.Parse(args)
+
+	var state store.Store
+	if *statePtr != "" {
+		var err error
+		if state, err = store.Open(*statePtr); err != nil {
+			fail(err)
+			exit(2)
+		}
+		defer state.Close()
+		fmt.Printf("state in %s\n", *statePtr)
+	}
+
+	// the signing api and the coordinator are served by the leader, which
+	// alone holds ceremony nonces and counts signatures against the limits
+	var elector *cluster.Elector
+	leading := func(h http.Handler) http.Handler { return h }
+	if *clusterPtr != "" {
+		if state == nil {
+			failf("-cluster needs -state")
+			return
+		}
+		var err error
+		if elector, err = cluster.NewElector(state, "daemon", *clusterPtr); err != nil {
+			fail(err)
+			return
+		}
+		elector.TTL = *leaseTTLPtr
+		elector.Notify = func(holder string, err error) {
+			switch {
+			case err != nil:
+				fmt.Printf("lease renewal failed: %v\n", err)
+			case holder == "":
+				fmt.Println("no leader")
+			default:
+				fmt.Printf("leader is %s\n", holder)
+			}
+		}
+		leading = elector.Handler
+	}
+
+	var watcher *watch.Watcher
+	if *watchPtr != "" {
+		key, err := secretPrivateKey(prompt.New(), *watchKeyPtr, "Private key for -watch (hex or WIF): ")
+		if err != nil {
+			fail(err)
+			return
+		}
+		d, ok := new(big.Int).SetString(key, 16)
+		if !ok {
+			failf("private key is not hex")
+			return
+		}
+
+		var audit io.Writer
+		auditPath := *watchAuditPtr
+		if auditPath == "" && state != nil {
+			audit, auditPath = store.LogWriter(context.Background(), state, "watch-audit"), "watch-audit in the state store"
+		} else {
+			if auditPath == "" {
+				auditPath = filepath.Join(*watchPtr, ".schnorr-audit.jsonl")
+			}
+			f, err := os.OpenFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				fail(err)
+				return
+			}
+			defer f.Close()
+			audit = f
+		}
+
+		policy := watch.Policy{Include: include, Exclude: exclude, MaxSize: *watchMaxSizePtr, SettleTime: *watchSettlePtr}
+		watcher, err = watch.NewWatcher(*watchPtr, d, policy, audit)
+		if err != nil {
+			fail(err)
+			return
+		}
+		watcher.Notify = func(e watch.AuditEntry) {
+			fmt.Printf("%s %s %s\n", e.Outcome, e.Path, e.Reason)
+		}
+		fmt.Printf("signing new files in %s, audit log %s\n", *watchPtr, auditPath)
+	}
+
+	mux := http.NewServeMux()
+
+	// the client -client-limit counts against, the address if nil
+	var principal func(r *http.Request) string
+	if header := *principalHeaderPtr; header != "" {
+		principal = func(r *http.Request) string {
+			return r.Header.Get(header)
+		}
+	}
+
+	var engines []*vault.Engine
+	if *tokenPtr != "" {
+		engine := vault.NewEngine(*mountPtr, *tokenPtr)
+		if state != nil {
+			engine.Storage = &vault.StateStorage{State: state}
+			engine.Idempotency = state
+		}
+		engine.IdempotencyTTL = *idempotencyTTLPtr
+		if *auditPtr != "" {
+			f, err := os.OpenFile(*auditPtr, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				fail(err)
+				return
+			}
+			defer f.Close()
+			engine.Audit = f
+		} else if state != nil {
+			engine.Audit = store.LogWriter(context.Background(), state, "signing-audit")
+		}
+		engine.MaxAge = *maxKeyAgePtr
+		hookSet, err := hooks.NewSet(preSign, postSign)
+		if err != nil {
+			fail(err)
+			return
+		}
+		engine.Hooks = hookSet
+		if *templatesPtr != "" {
+			if engine.Templates, err = msgtemplate.Load(*templatesPtr); err != nil {
+				fail(err)
+				return
+			}
+		}
+		if *keyLimitPtr != "" || *clientLimitPtr != "" || len(keyOverrides) > 0 {
+			limiter, err := newLimiter(*keyLimitPtr, *clientLimitPtr, keyOverrides)
+			if err != nil {
+				fail(err)
+				return
+			}
+			engine.Limiter = limiter
+			engine.Principal = principal
+		}
+		engines = append(engines, engine)
+
+		if *metricsListenPtr != "" {
+			// on its own listener, as scrapers have no token
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle("/metrics", metrics.Handler(engine.Metrics))
+			go func() {
+				if err := http.ListenAndServe(*metricsListenPtr, metricsMux); err != nil {
+					fmt.Printf("metrics stopped: %v\n", err)
+				}
+			}()
+			fmt.Printf("keystore metrics on http://%s/metrics\n", *metricsListenPtr)
+		}
+	} else if *metricsListenPtr != "" {
+		failf("-metrics-listen needs the signing api, pass -token")
+		return
+	}
+
+	if *tenantsPtr != "" {
+		tenants, closers, err := loadTenants(*tenantsPtr, *mountPtr, state, principal)
+		if err != nil {
+			fail(err)
+			return
+		}
+		for _, c := range closers {
+			defer c.Close()
+		}
+		// the -token keystore is the default tenant
+		if len(engines) == 1 {
+			engines[0].Tenant = "default"
+		}
+		engines = append(engines, tenants...)
+	}
+
+	if len(engines) == 1 && engines[0].Tenant == "" {
+		mux.Handle("/v1/"+*mountPtr+"/", leading(engines[0]))
+		fmt.Printf("signing api on /v1/%s/\n", *mountPtr)
+	} else if len(engines) > 0 {
+		router, err := vault.NewTenants(engines...)
+		if err != nil {
+			fail(err)
+			return
+		}
+		mux.Handle("/v1/"+*mountPtr+"/", leading(router))
+		fmt.Printf("signing api on /v1/%s/ for %d tenants\n", *mountPtr, len(engines))
+	}
+
+	if *coordinatorPtr {
+		c := coordinator.New(*roundTimeoutPtr)
+		c.Publish = func(status coordinator.Status) {
+			fmt.Printf("session %s signed %s: %s\n", status.ID, status.Message, status.Signature)
+		}
+		mux.Handle(coordinator.Prefix, leading(c))
+		mux.Handle(coordinator.Prefix+"/", leading(c))
+		fmt.Printf("ceremony coordinator on %s/\n", coordinator.Prefix)
+	}
+
+	if *twoPartyPtr != "" {
+		var shares twoparty.ShareStore
+		if *twoPartyPtr == "state" {
+			if state == nil {
+				failf("-twoparty state needs -state")
+				return
+			}
+			shares = &twoparty.StateShareStore{State: state}
+		} else {
+			dirStore, err := twoparty.NewDirShareStore(*twoPartyPtr)
+			if err != nil {
+				fail(err)
+				return
+			}
+			shares = dirStore
+		}
+		server := twoparty.NewServer(shares)
+		server.State = state
+		server.Token = *twoPartyTokenPtr
+		mux.Handle(twoparty.Prefix+"/", server)
+		fmt.Printf("two-party signing on %s/, shares in %s\n", twoparty.Prefix, *twoPartyPtr)
+	}
+
+	if len(engines) == 0 && !*coordinatorPtr && *twoPartyPtr == "" {
+		if watcher == nil {
+			failf("nothing to serve, pass -token or -tenants for the signing api, -coordinator, -twoparty and/or -watch")
+			return
+		}
+		if err := watcher.Run(context.Background()); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	if watcher != nil {
+		go func() {
+			if err := watcher.Run(context.Background()); err != nil {
+				fmt.Printf("watch stopped: %v\n", err)
+			}
+		}()
+	}
+
+	server := &http.Server{Addr: *listenPtr, Handler: mux}
+	if elector != nil {
+		// give up the lease on the way out, so another replica takes over
+		// without waiting for it to expire
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			elector.Run(ctx)
+			server.Shutdown(context.Background())
+		}()
+		fmt.Printf("replica %s of a cluster, lease %s\n", *clusterPtr, *leaseTTLPtr)
+	}
+
+	fmt.Printf("listening on %s\n", *listenPtr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fail(err)
+	}
+}
+
+// newLimiter builds the signing api limits from the daemon flags
+func newLimiter(key, client string, overrides []string) (*ratelimit.Limiter, error) {
+	var keyLimit, clientLimit ratelimit.Limit
+	var err error
+	if key != "" {
+		if keyLimit, err = ratelimit.ParseLimit(key); err != nil {
+			return nil, err
+		}
+	}
+	if client != "" {
+		if clientLimit, err = ratelimit.ParseLimit(client); err != nil {
+			return nil, err
+		}
+	}
+
+	limiter := ratelimit.New(keyLimit, clientLimit)
+	for _, o := range overrides {
+		parts := strings.SplitN(o, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("key limit %q is not name=rate:burst:daily", o)
+		}
+		limit, err := ratelimit.ParseLimit(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		limiter.KeyOverrides[parts[0]] = limit
+	}
+	return limiter, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/decred/dcrd/crypto/blake256"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/textnorm"
+)
+
+// detectScheme verifies under every scheme, hashing the message or the file
+// in with blake256 as sign does and with sha256 as most other tools do
+func detectScheme(publickey, message, in string, text *textnorm.Options, signature string) {
+	pk, err := hex.DecodeString(publickey)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	sigBytes, err := readSignatureArg(signature)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var sig [64]byte
+	if len(sigBytes) != len(sig) {
+		failf("signature is %d bytes, want %d", len(sigBytes), len(sig))
+		return
+	}
+	copy(sig[:], sigBytes)
+
+	// both hashes in one pass, as stdin can only be read once
+	blake, sha := blake256.New(), sha256.New()
+	if err := hashInput(message, in, text, blake, sha); err != nil {
+		fail(err)
+		return
+	}
+	digests := []struct {
+		name string
+		h    hash.Hash
+	}{
+		{"blake256", blake},
+		{"sha256", sha},
+	}
+	for _, d := range digests {
+		var digest [32]byte
+		copy(digest[:], d.h.Sum(nil))
+		scheme, err := schnorr.DetectScheme(pk, digest, sig)
+		if err == nil {
+			fmt.Printf("Signature Verified? true (%s, %s message hash)\n", scheme, d.name)
+			return
+		}
+	}
+	printVerified("Signature", false)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/frost"
+)
+
+func runDKG(args []string) {
+	if len(args) == 0 || args[0] != "verify" {
+		printUsage(args, "usage: schnorr-go dkg verify -transcript <file> [-transcript <file>...]")
+		return
+	}
+	dkgVerify(args[1:])
+}
+
+// dkgVerify re-verifies key generation transcripts, and with several from
+// the same ceremony checks every participant saw the same round 1 and the
+// same key
+func dkgVerify(args []string) {
+	var transcripts stringList
+
+	fs := newFlagSet("dkg verify")
+	fs.Var(&transcripts, "transcript", "transcript file of one participant, can be repeated")
+	parseFlags(fs, args)
+
+	if len(transcripts) == 0 {
+		failf("no -transcript given")
+		return
+	}
+
+	var groupKey [33]byte
+	for i, path := range transcripts {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fail(err)
+			return
+		}
+		t := new(frost.DKGTranscript)
+		if err := json.Unmarshal(data, t); err != nil {
+			failf("%s: %v", path, err)
+			return
+		}
+
+		pkg, err := frost.VerifyDKGTranscript(t)
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			printVerified("Transcript", false)
+			return
+		}
+		key, err := pkg.GroupKey.PublicKey()
+		if err != nil {
+			fail(err)
+			return
+		}
+		if i > 0 && key != groupKey {
+			fmt.Printf("%s: group key %x differs from %x in %s\n", path, key, groupKey, transcripts[0])
+			printVerified("Transcript", false)
+			return
+		}
+		groupKey = key
+
+		fmt.Printf("%s: participant %d, %d round 1 packages, %d messages, %d checks passed, %s to %s\n",
+			path, t.Participant, len(t.Round1), len(t.Messages), len(t.Checks), t.Started.Format("15:04:05"), t.Finished.Format("15:04:05"))
+	}
+
+	fmt.Printf("group key %x\n", groupKey)
+	printVerified("Transcript", true)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/dpop"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func runDPoP(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go dpop <proof|thumbprint> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "proof":
+		dpopProof(args[1:])
+	case "thumbprint":
+		dpopThumbprint(args[1:])
+	default:
+		unknownCommand("dpop", args)
+	}
+}
+
+func dpopProof(args []string) {
+	fs := newFlagSet("dpop proof")
+	privateKeyPtr := fs.String("privkey", "", "private key the proof is signed with, prompted for if empty")
+	methodPtr := fs.String("method", "POST", "http method of the request")
+	urlPtr := fs.String("url", "", "url of the request")
+	tokenPtr := fs.String("token", "", "access token sent with the request, binds the proof to it")
+	noncePtr := fs.String("nonce", "", "nonce from the server's DPoP-Nonce header")
+	parseFlags(fs, args)
+
+	privateKey, err := secretPrivateKey(prompt.New(), *privateKeyPtr, "Private key (hex or WIF): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, ok := new(big.Int).SetString(privateKey, 16)
+	if !ok {
+		failf("private key is not hex")
+		return
+	}
+
+	opts := []dpop.Option{}
+	if *tokenPtr != "" {
+		opts = append(opts, dpop.WithAccessToken(*tokenPtr))
+	}
+	if *noncePtr != "" {
+		opts = append(opts, dpop.WithNonce(*noncePtr))
+	}
+
+	proof, err := dpop.NewProof(d, *methodPtr, *urlPtr, opts...)
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Println(proof)
+	recordResult(dpopResult{Proof: proof})
+}
+
+func dpopThumbprint(args []string) {
+	fs := newFlagSet("dpop thumbprint")
+	publicKeyPtr := fs.String("pubkey", "", "public key to print the jkt of")
+	parseFlags(fs, args)
+
+	raw, err := hex.DecodeString(*publicKeyPtr)
+	if err != nil || len(raw) != 33 {
+		failf("public key is not 33 bytes of hex")
+		return
+	}
+	var publickey [33]byte
+	copy(publickey[:], raw)
+	if _, err := schnorr.ParsePoint(publickey); err != nil {
+		fail(err)
+		return
+	}
+
+	jkt, err := dpop.Thumbprint(publickey)
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Println(jkt)
+	recordResult(dpopResult{Thumbprint: jkt})
+}
+
+// dpopResult is the result of dpop proof and thumbprint
+type dpopResult struct {
+	Proof      string `json:"proof,omitempty"`
+	Thumbprint string `json:"thumbprint,omitempty"`
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/expiry"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/vault"
+)
+
+func runExpiry(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go expiry <check|renew> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "check":
+		expiryCheck(args[1:])
+	case "renew":
+		expiryRenew(args[1:])
+	default:
+		unknownCommand("expiry", args)
+	}
+}
+
+// scanExpiry finds the envelopes in the directory or listed in the manifest
+func scanExpiry(dir, manifest string) ([]expiry.Item, error) {
+	switch {
+	case dir != "" && manifest != "":
+		return nil, fmt.Errorf("give -dir or -manifest, not both")
+	case manifest != "":
+		return expiry.ScanManifest(manifest)
+	case dir != "":
+		return expiry.ScanDir(dir)
+	}
+	return nil, fmt.Errorf("-dir or -manifest is needed")
+}
+
+// expiryCheck lists envelopes by status and exits non-zero if any have
+// expired or expire within the window, for running from cron or CI
+func expiryCheck(args []string) {
+	fs := newFlagSet("expiry check")
+	dirPtr := fs.String("dir", "", "directory to look for envelopes in")
+	manifestPtr := fs.String("manifest", "", "file listing envelope paths, one per line")
+	withinPtr := fs.Duration("within", 30*24*time.Hour, "warn about envelopes expiring within this long")
+	quietPtr := fs.Bool("quiet", false, "only print envelopes which need renewing")
+	parseFlags(fs, args)
+
+	items, err := scanExpiry(*dirPtr, *manifestPtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+
+	now, due := time.Now(), 0
+	for _, it := range items {
+		status := it.Status(now, *withinPtr)
+		if status == expiry.StatusExpiring || status == expiry.StatusExpired {
+			due++
+		} else if *quietPtr {
+			continue
+		}
+		if it.Envelope.Expires != nil {
+			fmt.Printf("%-10s %s %s\n", status, it.Envelope.Expires.Format(time.RFC3339), it.Path)
+		} else {
+			fmt.Printf("%-10s %-20s %s\n", status, "-", it.Path)
+		}
+	}
+	fmt.Printf("\n%d envelopes, %d to renew\n", len(items), due)
+	if due > 0 {
+		exit(1)
+	}
+}
+
+func expiryRenew(args []string) {
+	fs := newFlagSet("expiry renew")
+	dirPtr := fs.String("dir", "", "directory to look for envelopes in")
+	manifestPtr := fs.String("manifest", "", "file listing envelope paths, one per line")
+	withinPtr := fs.Duration("within", 30*24*time.Hour, "renew envelopes expiring within this long")
+	validPtr := fs.Duration("valid", 90*24*time.Hour, "how long renewed envelopes last from now")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, prompted for if empty and no -vault-key")
+	serverPtr := fs.String("server", "http://127.0.0.1:8200", "address of the signing daemon, for -vault-key")
+	tokenPtr := fs.String("token", "", "token for the signing daemon, for -vault-key")
+	mountPtr := fs.String("mount", vault.DefaultMount, "path the signing api is mounted at, for -vault-key")
+	vaultKeyPtr := fs.String("vault-key", "", "sign with the latest version of this daemon key instead of -privkey")
+	dryRunPtr := fs.Bool("dry-run", false, "list what would be renewed without signing")
+	parseFlags(fs, args)
+
+	items, err := scanExpiry(*dirPtr, *manifestPtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	now := time.Now()
+	due := []expiry.Item{}
+	for _, it := range items {
+		if status := it.Status(now, *withinPtr); status == expiry.StatusExpiring || status == expiry.StatusExpired {
+			due = append(due, it)
+		}
+	}
+	if len(due) == 0 || *dryRunPtr {
+		for _, it := range due {
+			fmt.Printf("would renew %s\n", it.Path)
+		}
+		fmt.Printf("%d envelopes to renew\n", len(due))
+		return
+	}
+
+	var sign envelope.SignFunc
+	if *vaultKeyPtr != "" {
+		c := vault.NewClient(*serverPtr, *tokenPtr, *mountPtr)
+		sign = func(digest [32]byte) ([64]byte, [33]byte, error) {
+			ctx := context.Background()
+			sig, version, err := c.SignVersion(ctx, *vaultKeyPtr, digest, 0)
+			if err != nil {
+				return sig, [33]byte{}, err
+			}
+			keys, _, err := c.PublicKeys(ctx, *vaultKeyPtr)
+			return sig, keys[version], err
+		}
+	} else {
+		d, err := readPrivateKeyHex(*privateKeyPtr)
+		if err != nil {
+			fail(err)
+			exit(2)
+		}
+		publickey, _ := schnorr.ScalarBaseMult(d).PublicKey()
+		sign = func(digest [32]byte) ([64]byte, [33]byte, error) {
+			sig, err := schnorr.Sign(d, digest)
+			return sig, publickey, err
+		}
+	}
+
+	expires, failed := now.Add(*validPtr), 0
+	for _, it := range due {
+		renewed, err := expiry.Renew(it, sign, expires)
+		if err != nil {
+			fmt.Printf("failed     %s: %v\n", it.Path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("renewed    %s until %s by %s\n", it.Path, renewed.Expires.Format(time.RFC3339), renewed.PublicKey)
+	}
+	fmt.Printf("\n%d renewed, %d failed\n", len(due)-failed, failed)
+	if failed > 0 {
+		exit(1)
+	}
+}
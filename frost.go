@@ -0,0 +1,323 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	bip340 "github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/frost"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// FROST threshold signing without a coordinator, for participants who pass
+// the round messages around themselves. Commitments and signature shares
+// are written as <id>:<hex> so they can be pasted into the next command.
+//
+
+func runFROST(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go frost <deal|commit|sign|aggregate> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "deal":
+		frostDeal(args[1:])
+	case "commit":
+		frostCommit(args[1:])
+	case "sign":
+		frostSign(args[1:])
+	case "aggregate":
+		frostAggregate(args[1:])
+	default:
+		unknownCommand("frost", args)
+	}
+}
+
+func frostDeal(args []string) {
+	fs := newFlagSet("frost deal")
+	thresholdPtr := fs.Int("t", 2, "number of participants needed to sign")
+	countPtr := fs.Int("n", 3, "number of participants")
+	splitPtr := fs.Bool("split", false, "split an existing private key, prompted for, instead of a fresh one")
+	dirPtr := fs.String("dir", ".", "directory to write share-<id>.json and public.json to")
+	parseFlags(fs, args)
+
+	var secret *big.Int
+	if *splitPtr {
+		privateKey, err := secretPrivateKey(prompt.New(), "", "Private key (hex or WIF): ")
+		if err != nil {
+			fail(err)
+			return
+		}
+		d, ok := new(big.Int).SetString(privateKey, 16)
+		if !ok {
+			failf("private key is not hex")
+			return
+		}
+		secret = d
+	} else {
+		d, err := rand.Int(rand.Reader, new(big.Int).Sub(schnorr.Curve.N, big.NewInt(1)))
+		if err != nil {
+			fail(err)
+			return
+		}
+		secret = d.Add(d, big.NewInt(1))
+	}
+
+	shares, pkg, err := frost.Deal(secret, frost.ThresholdPolicy(*thresholdPtr, *countPtr))
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := os.MkdirAll(*dirPtr, 0700); err != nil {
+		fail(err)
+		return
+	}
+
+	for _, ks := range shares {
+		data, err := json.MarshalIndent(shareFile{KeyShare: ks, PublicKeyPackage: pkg}, "", "  ")
+		if err != nil {
+			fail(err)
+			return
+		}
+		if err := os.WriteFile(filepath.Join(*dirPtr, fmt.Sprintf("share-%d.json", ks.ID)), data, 0600); err != nil {
+			fail(err)
+			return
+		}
+	}
+	data, err := json.MarshalIndent(pkg, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(*dirPtr, "public.json"), data, 0644); err != nil {
+		fail(err)
+		return
+	}
+
+	x, _ := pkg.GroupKey.XOnly()
+	fmt.Printf("dealt %d shares of a %d-of-%d key into %s, hand each participant its share file and forget the rest\n", len(shares), *thresholdPtr, *countPtr, *dirPtr)
+	fmt.Printf("group key: %x\n", x)
+}
+
+func frostCommit(args []string) {
+	fs := newFlagSet("frost commit")
+	sharePtr := fs.String("share", "", "json file with the key_share and public_key_package")
+	noncesPtr := fs.String("nonces", "", "file to keep the secret nonces in until frost sign")
+	parseFlags(fs, args)
+
+	share, err := readShareFile(*sharePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if *noncesPtr == "" {
+		failf("-nonces is needed to keep the secret nonces for frost sign")
+		return
+	}
+
+	nonces, commitment, err := frost.Commit(share.KeyShare.ID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	data, err := json.Marshal(nonces)
+	if err != nil {
+		fail(err)
+		return
+	}
+	f, err := os.OpenFile(*noncesPtr, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fail(err)
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	b, err := commitment.Bytes()
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("%d:%x\n", commitment.ID, b)
+}
+
+func frostSign(args []string) {
+	var commitments stringList
+
+	fs := newFlagSet("frost sign")
+	sharePtr := fs.String("share", "", "json file with the key_share and public_key_package")
+	noncesPtr := fs.String("nonces", "", "secret nonces from frost commit, deleted once used")
+	messagePtr := fs.String("message", "", "message to be signed, its sha256 is what is signed")
+	fs.Var(&commitments, "commitment", "id:hex commitment of a signer, this one included, repeated for each signer")
+	parseFlags(fs, args)
+
+	share, err := readShareFile(*sharePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	data, err := os.ReadFile(*noncesPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	nonces := new(frost.SigningNonces)
+	if err := json.Unmarshal(data, nonces); err != nil {
+		fail(err)
+		return
+	}
+
+	sp, err := signingPackage(share.PublicKeyPackage, commitments, *messagePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	// the nonces are gone before the share is shown, so they can't sign twice
+	if err := os.Remove(*noncesPtr); err != nil {
+		fail(err)
+		return
+	}
+	z, err := sp.Sign(share.KeyShare, nonces)
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("%d:%x\n", share.KeyShare.ID, z)
+}
+
+func frostAggregate(args []string) {
+	var commitments, signatureShares stringList
+
+	fs := newFlagSet("frost aggregate")
+	publicPtr := fs.String("public", "", "public.json from frost deal, or any share file")
+	messagePtr := fs.String("message", "", "message that was signed")
+	fs.Var(&commitments, "commitment", "id:hex commitment of a signer, repeated for each signer")
+	fs.Var(&signatureShares, "signature-share", "id:hex signature share from frost sign, repeated for each signer")
+	parseFlags(fs, args)
+
+	pkg, err := readPublicKeyPackage(*publicPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	sp, err := signingPackage(pkg, commitments, *messagePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	zs := map[uint32][32]byte{}
+	for _, s := range signatureShares {
+		id, raw, err := parseIDHex(s, 32)
+		if err != nil {
+			fail(err)
+			return
+		}
+		var z [32]byte
+		copy(z[:], raw)
+		zs[id] = z
+	}
+
+	signature, err := sp.Aggregate(zs)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	x, _ := pkg.GroupKey.XOnly()
+	pubKey, _ := bip340.ParsePubKey(x[:])
+	sig, _ := bip340.ParseSignature(signature[:])
+	digest := sha256.Sum256([]byte(*messagePtr))
+	fmt.Printf("%x\n", signature)
+	printVerified("Signature", sig.Verify(digest[:], pubKey))
+}
+
+func readShareFile(path string) (*shareFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	share := &shareFile{}
+	if err := json.Unmarshal(data, share); err != nil {
+		return nil, err
+	}
+	if share.KeyShare == nil || share.PublicKeyPackage == nil {
+		return nil, fmt.Errorf("share file needs a key_share and a public_key_package")
+	}
+	return share, nil
+}
+
+// readPublicKeyPackage reads public.json from frost deal, or the package
+// out of any share file
+func readPublicKeyPackage(path string) (*frost.PublicKeyPackage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	share := shareFile{}
+	if json.Unmarshal(data, &share) == nil && share.PublicKeyPackage != nil {
+		return share.PublicKeyPackage, nil
+	}
+	pkg := new(frost.PublicKeyPackage)
+	if err := json.Unmarshal(data, pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// signingPackage parses the id:hex commitments and builds the signing
+// package over the sha256 of the message
+func signingPackage(pkg *frost.PublicKeyPackage, commitments []string, message string) (*frost.SigningPackage, error) {
+	parsed := []frost.SigningCommitment{}
+	for _, s := range commitments {
+		id, raw, err := parseIDHex(s, 66)
+		if err != nil {
+			return nil, err
+		}
+		var b [66]byte
+		copy(b[:], raw)
+		c, err := frost.ParseSigningCommitment(id, b)
+		if err != nil {
+			return nil, fmt.Errorf("commitment of %d: %v", id, err)
+		}
+		parsed = append(parsed, c)
+	}
+
+	digest := sha256.Sum256([]byte(message))
+	return frost.NewSigningPackage(pkg, parsed, digest[:])
+}
+
+func parseIDHex(s string, size int) (uint32, []byte, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return 0, nil, fmt.Errorf("%q is not in the form id:hex", s)
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%q does not start with a participant id", s)
+	}
+	raw, err := hex.DecodeString(parts[1])
+	if err != nil || len(raw) != size {
+		return 0, nil, fmt.Errorf("value for participant %d is not %d bytes of hex", id, size)
+	}
+	return uint32(id), raw, nil
+}
@@ -5,6 +5,13 @@ go 1.18
 require github.com/btcsuite/btcd/btcec/v2 v2.3.0
 
 require (
+	github.com/btcsuite/btcd/chaincfg/chainhash v1.0.1
 	github.com/decred/dcrd/crypto/blake256 v1.0.0
-	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1 // indirect
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1
+	golang.org/x/net v0.1.0
+	golang.org/x/term v0.1.0
+	golang.org/x/text v0.4.0
+	google.golang.org/protobuf v1.28.1
 )
+
+require golang.org/x/sys v0.1.0 // indirect
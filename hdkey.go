@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/hdkey"
+	"github.com/ryohare/schnorr-go/pkg/keyformat"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// BIP-32 keys, see pkg/hdkey: -derivation-path picks the signing key out of
+// an xprv given to sign, or out of the phrase given to restore, and keys
+// derive writes the key at a path as hex, PEM or another xprv or xpub.
+//
+
+// derivedKeyFlag returns the hex of the key at the path from the xprv
+// given with -privkey, or prompted for if empty
+func derivedKeyFlag(xprv, path string) (string, error) {
+	s, err := prompt.New().SecretFlag(xprv, "Extended private key (xprv): ")
+	if err != nil {
+		return "", err
+	}
+	k, err := hdkey.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if !k.IsPrivate() {
+		return "", fmt.Errorf("signing needs an xprv, not an xpub")
+	}
+	if k, err = k.Derive(path); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%064x", k.PrivateKey()), nil
+}
+
+func keysDerive(args []string) {
+	fs := newFlagSet("keys derive")
+	keyPtr := fs.String("key", "", "xprv or xpub to derive from, prompted for if empty")
+	pathPtr := fs.String("derivation-path", "", "path of the key to derive, such as m/86'/0'/0'/0/0, or 0/5 from an account key")
+	outputPtr := fs.String("output", "", "file to write the key to, stdout if empty")
+	formatPtr := fs.String("format", "hex", "hex, pem for a PKCS#8 file, keystore or ethereum for one encrypted under a passphrase, or xprv for the extended key")
+	pubOutPtr := fs.String("pubout", "", "file to write the public key to as a PKIX PEM file")
+	parseFlags(fs, args)
+
+	if *pathPtr == "" {
+		failf("usage: schnorr-go keys derive -key xprv... -derivation-path m/86'/0'/0'/0/0")
+		exit(2)
+	}
+	s, err := prompt.New().SecretFlag(*keyPtr, "Extended key (xprv or xpub): ")
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	k, err := hdkey.Parse(s)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	if k, err = k.Derive(*pathPtr); err != nil {
+		fail(err)
+		exit(1)
+	}
+
+	if err := writeDerivedKey(k, *formatPtr, *outputPtr, *pubOutPtr); err != nil {
+		fail(err)
+		exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "public key: %x\n", k.PublicKey())
+	recordKey(fmt.Sprintf("%x", k.PublicKey()), *outputPtr, *pubOutPtr)
+}
+
+// writeDerivedKey writes the key as writeKeyFiles does, or as an xprv or
+// xpub. An xpub has no private key, so hex and pem write its public key.
+func writeDerivedKey(k *hdkey.Key, format, output, pubout string) error {
+	if k.IsPrivate() && format != "xprv" {
+		key, err := schnorr.NewPrivateKey(k.PrivateKey())
+		if err != nil {
+			return err
+		}
+		return writeKeyFiles(key, format, output, pubout)
+	}
+
+	var data []byte
+	switch format {
+	case "xprv":
+		data = []byte(k.String())
+	case "hex":
+		data = []byte(fmt.Sprintf("%x", k.PublicKey()))
+	case "pem":
+		pemPub, err := keyformat.EncodePEMPublicKey(k.PublicKey())
+		if err != nil {
+			return err
+		}
+		data = pemPub
+	default:
+		return fmt.Errorf("unknown key format %q, want hex, pem, keystore, ethereum or xprv", format)
+	}
+	if output == "" {
+		fmt.Println(strings.TrimSuffix(string(data), "\n"))
+	} else if err := os.WriteFile(output, data, 0600); err != nil {
+		return err
+	}
+
+	if pubout == "" {
+		return nil
+	}
+	pemPub, err := keyformat.EncodePEMPublicKey(k.PublicKey())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(pubout, pemPub, 0644)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//
+// -json, given to any command, prints its outcome as one json object for
+// programs driving the tool, rather than the lines meant for people:
+//
+//	{"command":"verify","ok":false,"exitCode":1,"results":[...],"output":[...],"error":"..."}
+//
+// -json is a flag of every command's flag set, so it's only ever taken
+// where a flag is, never as the value of another. The command runs as it
+// always does, with what it prints on stdout collected instead: output is
+// those lines, or the json printed if it printed json. results are the
+// values the command recorded with recordResult, such as the -template
+// values of sign, verify and inspect, and error is what it failed with, or
+// the last line it printed if it exited non-zero without an error. Prompts
+// and progress still go to stderr. pipe and daemon, which run until
+// stopped, don't take it, and ceremony simulate's -json prints its events
+// as json lines.
+//
+// Commands end through fail and exit rather than os.Exit, so the outcome is
+// printed whichever way they end, and tests run them as main does.
+//
+
+// jsonOutput is what -json prints
+type jsonOutput struct {
+	Command  string            `json:"command"`
+	OK       bool              `json:"ok"`
+	ExitCode int               `json:"exitCode"`
+	Results  []json.RawMessage `json:"results,omitempty"`
+	Output   interface{}       `json:"output,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// outcome is what the running command has done so far
+type outcome struct {
+	command string
+	code    int
+	err     error
+	results []json.RawMessage
+
+	// json is set by -json, which swaps stdout for a pipe read into output
+	// until the command ends
+	json   bool
+	stdout *os.File
+	done   chan struct{}
+	output bytes.Buffer
+}
+
+// current is the outcome of the command running
+var current = &outcome{}
+
+// exitCode is what exit panics with, for runCommand to recover
+type exitCode int
+
+// runCommand runs the command and returns the code to exit with, printing
+// its outcome as json if it was given -json
+func runCommand(c *command, args []string) (code int) {
+	current = &outcome{command: c.name}
+	if c.subcommands && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		current.command += " " + args[0]
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(exitCode)
+			if !ok {
+				current.finish(2)
+				panic(r)
+			}
+			code = int(e)
+		}
+		code = current.finish(code)
+	}()
+	c.run(args)
+	return 0
+}
+
+// exit ends the command with the code
+func exit(code int) {
+	panic(exitCode(code))
+}
+
+// fail prints err and makes it the command's error, so the command exits
+// 1 when it returns unless it exits with another code
+func fail(err error) {
+	fmt.Println(err)
+	current.fail(err)
+}
+
+// failf is fail with a formatted error
+func failf(format string, a ...interface{}) {
+	fail(fmt.Errorf(format, a...))
+}
+
+// printUsage prints a group's usage, which is its help with no arguments
+// and otherwise the group being run wrong, so it exits 2
+func printUsage(args []string, usage string) {
+	if args == nil {
+		fmt.Println(usage)
+		return
+	}
+	usageError(args, errors.New(usage))
+}
+
+// unknownCommand fails a group run with a command it doesn't have
+func unknownCommand(group string, args []string) {
+	usageError(args, fmt.Errorf("unknown %s command %q", group, args[0]))
+}
+
+// usageError exits 2 with err. No flag set has seen the arguments, none
+// being flags' values, so -json among them is taken as -json.
+func usageError(args []string, err error) {
+	for _, arg := range args {
+		switch arg {
+		case "-json", "--json", "-json=true", "--json=true":
+			if err := current.collect(); err != nil {
+				fail(err)
+				exit(2)
+			}
+		}
+	}
+	fail(err)
+	exit(2)
+}
+
+func (o *outcome) fail(err error) {
+	o.err = err
+	if o.code == 0 {
+		o.code = 1
+	}
+}
+
+// recordResult records a result of the command, printed with -json
+func recordResult(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	current.results = append(current.results, data)
+}
+
+// keyResult is the result of the commands that make, restore, export or
+// derive a key
+type keyResult struct {
+	PublicKey   string `json:"publicKey"`
+	Fingerprint string `json:"fingerprint"`
+	// Output and PubOut are the files the key and the public key were
+	// written to, if they weren't printed
+	Output string `json:"output,omitempty"`
+	PubOut string `json:"pubout,omitempty"`
+}
+
+// recordKey records the key's keyResult
+func recordKey(publickey, output, pubout string) {
+	recordResult(keyResult{PublicKey: publickey, Fingerprint: fingerprintHex(publickey), Output: output, PubOut: pubout})
+}
+
+// printVerified prints whether what was verified, recording it as a result,
+// and makes the command exit 1 if it wasn't
+func printVerified(what string, verified bool) {
+	fmt.Println(what+" Verified?", verified)
+	recordResult(verifyOutput{Verified: verified})
+	if !verified && current.code == 0 {
+		current.code = 1
+	}
+}
+
+// newFlagSet is a command's flag set, with -json. Bad flags end the command
+// through parseFlags.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Var(jsonFlag{}, "json", "print the outcome as one json object")
+	return fs
+}
+
+// parseFlags parses the command's flags, ending it with 2 for a bad flag
+// and 0 for -h, as flag.ExitOnError would
+func parseFlags(fs *flag.FlagSet, args []string) {
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		exit(0)
+	}
+	if err != nil {
+		current.fail(err)
+		exit(2)
+	}
+}
+
+// jsonFlag is -json, collecting the command's output once it's set
+type jsonFlag struct{}
+
+func (jsonFlag) String() string   { return "false" }
+func (jsonFlag) IsBoolFlag() bool { return true }
+
+func (jsonFlag) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if err != nil || !on {
+		return err
+	}
+	return current.collect()
+}
+
+// collect swaps stdout for a pipe read into the output
+func (o *outcome) collect() error {
+	if o.json {
+		return nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	o.json, o.stdout, o.done = true, os.Stdout, make(chan struct{})
+	os.Stdout = w
+	go func() {
+		io.Copy(&o.output, r)
+		r.Close()
+		close(o.done)
+	}()
+	return nil
+}
+
+// finish returns the code the command exits with, 1 rather than 0 if it
+// failed, and prints the outcome if it's collected
+func (o *outcome) finish(code int) int {
+	if code == 0 {
+		code = o.code
+	}
+	if !o.json {
+		return code
+	}
+	os.Stdout.Close()
+	os.Stdout = o.stdout
+	<-o.done
+	o.json = false
+
+	out := jsonOutput{Command: o.command, OK: code == 0, ExitCode: code, Results: o.results}
+	printed := o.output.String()
+	if trimmed := bytes.TrimSpace(o.output.Bytes()); len(trimmed) > 0 {
+		if json.Valid(trimmed) {
+			out.Output = json.RawMessage(trimmed)
+		} else {
+			out.Output = strings.Split(strings.TrimRight(printed, "\n"), "\n")
+		}
+	}
+	switch {
+	case o.err != nil:
+		out.Error = o.err.Error()
+	case code != 0:
+		out.Error = lastLine(printed)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.Encode(out)
+	return code
+}
+
+// lastLine is the last line the command printed, for the error of one that
+// failed without giving one
+func lastLine(printed string) string {
+	lines := strings.Split(strings.TrimSpace(printed), "\n")
+	if line := strings.TrimSpace(lines[len(lines)-1]); line != "" {
+		return line
+	}
+	return "exited unsuccessfully"
+}
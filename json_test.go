@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/nostr"
+)
+
+// runTestCommand runs the command line as main does, returning the exit
+// code and what was printed on stdout
+func runTestCommand(t *testing.T, args ...string) (int, string) {
+	c := findCommand(args[0])
+	if c == nil {
+		t.Fatalf("no command %q", args[0])
+	}
+	return runTestArgs(t, c, args[1:])
+}
+
+// runTestLegacy runs the legacy flags as main does
+func runTestLegacy(t *testing.T, args ...string) (int, string) {
+	return runTestArgs(t, &command{name: "schnorr-go", run: runLegacy}, args)
+}
+
+func runTestArgs(t *testing.T, c *command, args []string) (int, string) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unexpected error from os.Pipe: %v", err)
+	}
+	printed := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		printed <- string(data)
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	code := runCommand(c, args)
+	os.Stdout = stdout
+	w.Close()
+	return code, <-printed
+}
+
+func TestJSONOutput(t *testing.T) {
+	pubkey, sig := signTestMessage("hello", t)
+
+	for _, tt := range []struct {
+		name        string
+		args        []string
+		wantCode    int
+		wantError   string
+		wantResults int
+	}{
+		{
+			name:      "a key that isn't hex",
+			args:      []string{"keys", "export", "-json", "-privkey", "zz"},
+			wantCode:  1,
+			wantError: "private key is not hex",
+		},
+		{
+			name:      "a missing flag",
+			args:      []string{"nostr", "fetch", "-json"},
+			wantCode:  1,
+			wantError: "at least one -relay is required",
+		},
+		{
+			name:      "a group without its command",
+			args:      []string{"contract", "-json"},
+			wantCode:  2,
+			wantError: `unknown contract command "-json"`,
+		},
+		{
+			name:      "an unknown command of a group",
+			args:      []string{"lnurl", "sign", "-json"},
+			wantCode:  2,
+			wantError: "usage: schnorr-go lnurl auth",
+		},
+		{
+			name:      "an unknown flag",
+			args:      []string{"verify", "-json", "-signature", sig},
+			wantCode:  2,
+			wantError: "flag provided but not defined: -signature",
+		},
+		{
+			name:        "a signature that doesn't verify",
+			args:        []string{"verify", "-json", "-pubkey", pubkey, "-message", "goodbye", "-sig", sig},
+			wantCode:    1,
+			wantError:   "Signature Verified? false",
+			wantResults: 1,
+		},
+		{
+			name:        "a signature that verifies",
+			args:        []string{"verify", "-json", "-pubkey", pubkey, "-message", "hello", "-sig", sig},
+			wantResults: 1,
+		},
+		{
+			name:        "a key made",
+			args:        []string{"keygen", "-json"},
+			wantResults: 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			code, printed := runTestCommand(t, tt.args...)
+
+			// then
+			var out jsonOutput
+			if err := json.Unmarshal([]byte(printed), &out); err != nil {
+				t.Fatalf("Unexpected error from json.Unmarshal(%q): %v", printed, err)
+			}
+			if code != tt.wantCode || out.ExitCode != tt.wantCode || out.OK != (tt.wantCode == 0) {
+				t.Fatalf("%s = exit %d, %+v, want exit %d", strings.Join(tt.args, " "), code, out, tt.wantCode)
+			}
+			if !strings.Contains(out.Error, tt.wantError) || (tt.wantError == "") != (out.Error == "") {
+				t.Fatalf("%s error = %q, want %q", strings.Join(tt.args, " "), out.Error, tt.wantError)
+			}
+			if len(out.Results) != tt.wantResults {
+				t.Fatalf("%s results = %s, want %d", strings.Join(tt.args, " "), out.Results, tt.wantResults)
+			}
+		})
+	}
+}
+
+func TestJSONResultsAreLabelled(t *testing.T) {
+	// when
+	_, printed := runTestCommand(t, "keygen", "-json")
+
+	// then
+	var out struct {
+		Results []keyResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(printed), &out); err != nil {
+		t.Fatalf("Unexpected error from json.Unmarshal(%q): %v", printed, err)
+	}
+	if len(out.Results) != 1 || len(out.Results[0].PublicKey) != 66 || out.Results[0].Fingerprint != fingerprintHex(out.Results[0].PublicKey) {
+		t.Fatalf("keygen -json results = %+v, want the public key and its fingerprint", out.Results)
+	}
+}
+
+func TestJSONFlagValue(t *testing.T) {
+	// given -json as the value of -message, not a flag
+	args := []string{"sign", "-message", "-json", "-privkey", testPrivateKey}
+
+	// when
+	code, printed := runTestCommand(t, args...)
+
+	// then the message -json is signed and printed as usual
+	if code != 0 {
+		t.Fatalf("%s = exit %d, want 0", strings.Join(args, " "), code)
+	}
+	pubkey, _ := signTestMessage("-json", t)
+	verified, err := verifyMessage(pubkey, "-json", "", nil, strings.TrimSpace(printed))
+	if err != nil {
+		t.Fatalf("Unexpected error from verifyMessage(%q): %v", printed, err)
+	}
+	if !verified {
+		t.Fatalf("%s printed %q, want a signature of -json", strings.Join(args, " "), printed)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "help of a group", args: []string{"help", "contract"}, want: 0},
+		{name: "help of a command", args: []string{"help", "sign"}, want: 0},
+		{name: "a group without its command", args: []string{"contract"}, want: 2},
+		{name: "an unknown command of a group", args: []string{"keys", "bogus"}, want: 2},
+		{name: "a missing flag", args: []string{"contract", "tweak"}, want: 1},
+		{name: "a key that isn't hex", args: []string{"keys", "export", "-privkey", "zz"}, want: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			code, _ := runTestCommand(t, tt.args...)
+
+			// then
+			if code != tt.want {
+				t.Fatalf("%s = exit %d, want %d", strings.Join(tt.args, " "), code, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONNostrDecode(t *testing.T) {
+	// given
+	pubkey, _ := signTestMessage("hello", t)
+	npub, err := nostr.EncodePublicKey(pubkey[2:])
+	if err != nil {
+		t.Fatalf("Unexpected error from nostr.EncodePublicKey: %v", err)
+	}
+
+	// when
+	code, printed := runTestCommand(t, "nostr", "decode", "-json", npub)
+
+	// then
+	var out struct {
+		jsonOutput
+		Results []nostrKeyResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(printed), &out); err != nil {
+		t.Fatalf("Unexpected error from json.Unmarshal(%q): %v", printed, err)
+	}
+	if code != 0 || !out.OK || out.Command != "nostr decode" {
+		t.Fatalf("nostr decode -json = exit %d, %+v, want exit 0", code, out)
+	}
+	if len(out.Results) != 1 || out.Results[0].Hex != pubkey[2:] {
+		t.Fatalf("nostr decode -json results = %+v, want %s", out.Results, pubkey[2:])
+	}
+}
+
+func TestJSONLegacyFlags(t *testing.T) {
+	pubkey, sig := signTestMessage("hello", t)
+
+	for _, tt := range []struct {
+		name      string
+		args      []string
+		wantCode  int
+		wantError string
+	}{
+		{
+			name: "-sign",
+			args: []string{"-sign", "-json", "-message", "hello", "-privkey", testPrivateKey},
+		},
+		{
+			name: "-verify",
+			args: []string{"-verify", "-json", "-pubkey", pubkey, "-message", "hello", "-sig", sig},
+		},
+		{
+			name:      "-verify of another message",
+			args:      []string{"-verify", "-json", "-pubkey", pubkey, "-message", "goodbye", "-sig", sig},
+			wantCode:  1,
+			wantError: "Signature Verified? false",
+		},
+		{
+			name:      "an unknown flag",
+			args:      []string{"-json", "-signature", sig},
+			wantCode:  2,
+			wantError: "flag provided but not defined: -signature",
+		},
+		{
+			name:      "neither -sign nor -verify",
+			args:      []string{"-json"},
+			wantCode:  2,
+			wantError: "give a command, or -sign or -verify",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			code, printed := runTestLegacy(t, tt.args...)
+
+			// then
+			var out jsonOutput
+			if err := json.Unmarshal([]byte(printed), &out); err != nil {
+				t.Fatalf("Unexpected error from json.Unmarshal(%q): %v", printed, err)
+			}
+			if code != tt.wantCode || out.ExitCode != tt.wantCode || out.OK != (tt.wantCode == 0) {
+				t.Fatalf("%s = exit %d, %+v, want exit %d", strings.Join(tt.args, " "), code, out, tt.wantCode)
+			}
+			if !strings.Contains(out.Error, tt.wantError) || (tt.wantError == "") != (out.Error == "") {
+				t.Fatalf("%s error = %q, want %q", strings.Join(tt.args, " "), out.Error, tt.wantError)
+			}
+			if tt.wantCode == 0 && len(out.Results) != 1 {
+				t.Fatalf("%s results = %s, want 1", strings.Join(tt.args, " "), out.Results)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/keyformat"
+	"github.com/ryohare/schnorr-go/pkg/keystore"
+	"github.com/ryohare/schnorr-go/pkg/nostr"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Keys in PEM files from openssl and other tools, or in keystore files
+// encrypted under a passphrase, this tool's, see pkg/keystore, or Ethereum
+// wallets' version 3 files, given with -privkey-file and -pubkey-file in
+// place of hex, and Bitcoin wallets' WIF strings or nostr's npub and nsec,
+// given with -privkey and -pubkey. They are turned into the hex the rest of
+// the tool takes, so every path signing and verifying accepts them. keygen
+// -format, keys export and keys import write them.
+//
+
+// privateKeyFlag returns the -privkey hex, or that of the key in the
+// -privkey-file
+func privateKeyFlag(privkey, file string) (string, error) {
+	if file == "" {
+		return privkey, nil
+	}
+	if privkey != "" {
+		return "", fmt.Errorf("give -privkey or -privkey-file, not both")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	if keystore.IsKeystore(data) {
+		d, err := decryptKeystore(prompt.New(), data, file)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%064x", d), nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		passphrase, err := prompt.New().Secret(fmt.Sprintf("Passphrase for %s: ", file))
+		if err != nil {
+			return "", err
+		}
+		key, err := keyformat.ParseEthereumKeystore(data, passphrase)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", file, err)
+		}
+		return fmt.Sprintf("%064x", key.PrivateKey), nil
+	}
+	key, err := keyformat.ParsePEMPrivateKey(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", file, err)
+	}
+	return fmt.Sprintf("%064x", key.PrivateKey), nil
+}
+
+// secretPrivateKey returns the private key flag, or the key prompted for
+// if it was left empty, as hex; WIF keys are converted
+func secretPrivateKey(p *prompt.Prompter, value, text string) (string, error) {
+	s, err := p.SecretFlag(value, text)
+	if err != nil {
+		return "", err
+	}
+	return privateKeyHex(s)
+}
+
+// privateKeyHex converts a WIF private key or a nostr nsec to hex, and
+// returns anything else as it is. WIF strings are 51 or 52 base58 characters starting with
+// 5, K or L on mainnet and 9 or c on testnet, so they can't be taken for
+// the 64 characters of a hex key.
+func privateKeyHex(s string) (string, error) {
+	if strings.HasPrefix(strings.ToLower(s), "nsec1") {
+		d, err := nostr.DecodePrivateKey(s)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(d), nil
+	}
+	if len(s) != 51 && len(s) != 52 || !strings.ContainsAny(s[:1], "5KL9c") {
+		return s, nil
+	}
+	key, err := keyformat.ParseWIF(s)
+	if err != nil {
+		return "", err
+	}
+	if !key.Compressed {
+		fmt.Fprintln(os.Stderr, "note: the WIF key is marked uncompressed, but its public key is used compressed here")
+	}
+	return fmt.Sprintf("%064x", key.PrivateKey), nil
+}
+
+// publicKeyFlag returns the -pubkey hex, converted from an npub if need
+// be, or that of the key in the -pubkey-file
+func publicKeyFlag(pubkey, file string) (string, error) {
+	if file == "" {
+		return nostr.PublicKeyHex(pubkey)
+	}
+	if pubkey != "" {
+		return "", fmt.Errorf("give -pubkey or -pubkey-file, not both")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	publickey, err := keyformat.ParsePEMPublicKey(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", file, err)
+	}
+	return fmt.Sprintf("%x", publickey), nil
+}
+
+// writeKeyFiles writes the private key to output, stdout if empty, in hex,
+// as a PKCS#8 PEM file, or as this tool's or an Ethereum keystore under a
+// new passphrase, and the public key to pubout as a PKIX PEM file if given
+func writeKeyFiles(key *schnorr.PrivateKey, format, output, pubout string) error {
+	var data []byte
+	switch format {
+	case "hex":
+		data = []byte(fmt.Sprintf("%x", key.Serialize()))
+	case "pem":
+		pemKey, err := keyformat.EncodePEMPrivateKey(key.D())
+		if err != nil {
+			return err
+		}
+		data = pemKey
+	case "keystore":
+		passphrase, err := prompt.New().NewPassphrase("Keystore passphrase: ")
+		if err != nil {
+			return err
+		}
+		ks, err := keystore.Encrypt(key.D(), passphrase, keystore.DefaultParams)
+		if err != nil {
+			return err
+		}
+		if data, err = ks.Marshal(); err != nil {
+			return err
+		}
+	case "ethereum":
+		passphrase, err := prompt.New().NewPassphrase("Keystore passphrase: ")
+		if err != nil {
+			return err
+		}
+		if data, err = keyformat.EncryptEthereumKeystore(key.D(), passphrase, keyformat.EthereumStandard); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown key format %q, want hex, pem, keystore or ethereum", format)
+	}
+	if output == "" {
+		fmt.Println(strings.TrimSuffix(string(data), "\n"))
+	} else if err := os.WriteFile(output, data, 0600); err != nil {
+		return err
+	}
+
+	if pubout == "" {
+		return nil
+	}
+	pemPub, err := keyformat.EncodePEMPublicKey(key.PublicKey().Serialize())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(pubout, pemPub, 0644)
+}
+
+// keysExport writes an existing hex key out as PEM files for tools that
+// take them, as an encrypted keystore, ours or Ethereum's, or as WIF for
+// Bitcoin wallets
+func keysExport(args []string) {
+	fs := newFlagSet("keys export")
+	privateKeyPtr := fs.String("privkey", "", "private key to export, prompted for if empty")
+	formatPtr := fs.String("format", "pem", "pem for a PKCS#8 file, keystore for one encrypted under a passphrase, ethereum for a wallet's version 3 keystore, or wif")
+	networkPtr := fs.String("network", "mainnet", "with -format wif, the network whose version byte to use, mainnet or testnet")
+	uncompressedPtr := fs.Bool("uncompressed", false, "with -format wif, mark the key for an uncompressed public key, as very old wallets want")
+	outputPtr := fs.String("output", "", "file to write the private key to, stdout if empty")
+	pubOutPtr := fs.String("pubout", "", "file to write the PKIX public key to")
+	publicOnlyPtr := fs.Bool("public-only", false, "only write the public key, to -pubout or stdout")
+	parseFlags(fs, args)
+
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	key, err := schnorr.NewPrivateKey(d)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if *publicOnlyPtr {
+		pemPub, err := keyformat.EncodePEMPublicKey(key.PublicKey().Serialize())
+		if err != nil {
+			fail(err)
+			return
+		}
+		if *pubOutPtr == "" {
+			fmt.Print(string(pemPub))
+		} else if err := os.WriteFile(*pubOutPtr, pemPub, 0644); err != nil {
+			fail(err)
+			return
+		}
+		recordKey(key.PublicKey().String(), "", *pubOutPtr)
+		return
+	}
+	if *formatPtr == "wif" {
+		wif, err := keyformat.EncodeWIF(key.D(), *networkPtr, !*uncompressedPtr)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if *outputPtr == "" {
+			fmt.Println(wif)
+		} else if err := os.WriteFile(*outputPtr, []byte(wif+"\n"), 0600); err != nil {
+			fail(err)
+			return
+		}
+		recordKey(key.PublicKey().String(), *outputPtr, "")
+		return
+	}
+	if *formatPtr != "pem" && *formatPtr != "keystore" && *formatPtr != "ethereum" {
+		failf("unknown key format %q, want pem, keystore, ethereum or wif", *formatPtr)
+		return
+	}
+	if err := writeKeyFiles(key, *formatPtr, *outputPtr, *pubOutPtr); err != nil {
+		fail(err)
+		return
+	}
+	recordKey(key.PublicKey().String(), *outputPtr, *pubOutPtr)
+}
+
+// keysImport encrypts a key kept in any format keyformat.Import reads into
+// a keystore, so it can be signed with from -privkey-file
+func keysImport(args []string) {
+	fs := newFlagSet("keys import")
+	inPtr := fs.String("in", "", "key file to import: WIF, PEM, a keystore or hex, the key is prompted for if empty")
+	outputPtr := fs.String("output", "", "file to write the keystore to")
+	removePtr := fs.Bool("remove", false, "delete the -in file once the keystore is written")
+	parseFlags(fs, args)
+
+	if *outputPtr == "" {
+		failf("usage: schnorr-go keys import [-in key.pem] -output key.json")
+		exit(2)
+	}
+	p := prompt.New()
+	var data []byte
+	if *inPtr == "" {
+		secret, err := p.Secret("Private key (hex or WIF): ")
+		if err != nil {
+			fail(err)
+			exit(2)
+		}
+		data = secret
+	} else {
+		var err error
+		if data, err = os.ReadFile(*inPtr); err != nil {
+			fail(err)
+			exit(2)
+		}
+	}
+
+	// keystores, this tool's or Ethereum's, are json; importing one of ours
+	// changes its passphrase
+	var passphrase []byte
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		var err error
+		if passphrase, err = p.Secret("Passphrase of the keystore to import: "); err != nil {
+			fail(err)
+			exit(2)
+		}
+	}
+	imported, err := keyformat.Import(data, passphrase)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	key, err := schnorr.NewPrivateKey(imported.PrivateKey)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	if err := writeKeyFiles(key, "keystore", *outputPtr, ""); err != nil {
+		fail(err)
+		exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "imported %s key, public key: %s\n", imported.Format, key.PublicKey())
+
+	if *removePtr && *inPtr != "" {
+		if err := os.Remove(*inPtr); err != nil {
+			fail(err)
+			exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "removed %s\n", *inPtr)
+	}
+}
+
+// decryptKeystore asks for the passphrase of the keystore file and returns
+// its private key
+func decryptKeystore(p *prompt.Prompter, data []byte, file string) (*big.Int, error) {
+	ks, err := keystore.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	passphrase, err := p.Secret(fmt.Sprintf("Passphrase for %s: ", file))
+	if err != nil {
+		return nil, err
+	}
+	d, err := ks.Decrypt(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/entropy"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"golang.org/x/term"
+)
+
+// keystrokes is how many key presses the keyboard source times
+const keystrokes = 64
+
+// runKeygen makes a private key from one or more entropy sources mixed
+// together, printing the private key, or with -mnemonic the phrase it is
+// restored from, to -output and the public key
+func runKeygen(args []string) {
+	var sources stringList
+
+	fs := newFlagSet("keygen")
+	fs.Var(&sources, "entropy", "system, dice, keyboard or device:<path>, repeated to mix several, system if not given")
+	outputPtr := fs.String("output", "", "file to write the private key to, stdout if empty")
+	formatPtr := fs.String("format", "hex", "hex, pem for a PKCS#8 file, keystore for one encrypted under a passphrase, or ethereum for a wallet's version 3 keystore")
+	pubOutPtr := fs.String("pubout", "", "file to write the public key to as a PKIX PEM file")
+	mnemonicPtr := fs.Bool("mnemonic", false, "write a BIP-39 phrase to back the key up on paper instead of the key, see restore")
+	wordsPtr := fs.Int("words", 24, "with -mnemonic, 12 or 24 words, or 15, 18 or 21")
+	passphrasePtr := fs.Bool("passphrase", false, "with -mnemonic, ask for a passphrase the phrase is restored with")
+	pathPtr := fs.String("derivation-path", "m", "with -mnemonic, BIP-32 path of the key the phrase stands for, such as m/86'/0'/0'/0/0")
+	parseFlags(fs, args)
+
+	if *formatPtr != "hex" && *formatPtr != "pem" && *formatPtr != "keystore" && *formatPtr != "ethereum" {
+		failf("unknown key format %q, want hex, pem, keystore or ethereum", *formatPtr)
+		return
+	}
+
+	if len(sources) == 0 {
+		sources = stringList{"system"}
+	}
+
+	p := prompt.New()
+	collected := []entropy.Source{}
+	for _, name := range sources {
+		switch {
+		case name == "system":
+			collected = append(collected, entropy.System(32))
+		case strings.HasPrefix(name, "device:"):
+			collected = append(collected, entropy.Device(strings.TrimPrefix(name, "device:"), 32))
+		case name == "dice":
+			rolls, err := p.Line("Dice rolls, digits 1 to 6, 100 for a key on dice alone: ")
+			if err != nil {
+				fail(err)
+				return
+			}
+			s, err := entropy.Dice(rolls)
+			if err != nil {
+				fail(err)
+				return
+			}
+			collected = append(collected, s)
+		case name == "keyboard":
+			events, err := timeKeystrokes(p)
+			if err != nil {
+				fail(err)
+				return
+			}
+			collected = append(collected, entropy.Timings(events))
+		default:
+			failf("unknown entropy source %q", name)
+			return
+		}
+	}
+
+	pool, err := entropy.Mix(collected...)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if *mnemonicPtr {
+		key, err := generateMnemonic(p, pool, *wordsPtr, *passphrasePtr, *pathPtr, *outputPtr, *pubOutPtr)
+		if err != nil {
+			fail(err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "entropy from %s\n", strings.Join(pool.Sources(), ", "))
+		fmt.Fprintf(os.Stderr, "public key: %s\n", key.PublicKey())
+		recordKey(key.PublicKey().String(), *outputPtr, *pubOutPtr)
+		return
+	}
+	key, err := schnorr.GeneratePrivateKey(pool)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if err := writeKeyFiles(key, *formatPtr, *outputPtr, *pubOutPtr); err != nil {
+		fail(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "entropy from %s\n", strings.Join(pool.Sources(), ", "))
+	fmt.Fprintf(os.Stderr, "public key: %s\n", key.PublicKey())
+	recordKey(key.PublicKey().String(), *outputPtr, *pubOutPtr)
+}
+
+// timeKeystrokes records when each key is pressed, with the terminal in raw
+// mode so presses arrive one at a time
+func timeKeystrokes(p *prompt.Prompter) ([]time.Time, error) {
+	if !p.Interactive() {
+		return nil, fmt.Errorf("%w: the keyboard source needs someone typing", prompt.ErrNotInteractive)
+	}
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(fd, state)
+
+	fmt.Fprintf(p.Out, "Type %d random keys\r\n", keystrokes)
+	events := make([]time.Time, 0, keystrokes)
+	b := make([]byte, 1)
+	for len(events) < keystrokes {
+		if _, err := os.Stdin.Read(b); err != nil {
+			return nil, err
+		}
+		if b[0] == 3 {
+			return nil, fmt.Errorf("interrupted")
+		}
+		events = append(events, time.Now())
+		fmt.Fprint(p.Out, ".")
+	}
+	fmt.Fprint(p.Out, "\r\n")
+	return events, nil
+}
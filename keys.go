@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ryohare/schnorr-go/pkg/keydir"
+)
+
+func runKeys(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go keys <publish|fetch|export|import|derive> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "publish":
+		keysPublish(args[1:])
+	case "fetch":
+		keysFetch(args[1:])
+	case "export":
+		keysExport(args[1:])
+	case "import":
+		keysImport(args[1:])
+	case "derive":
+		keysDerive(args[1:])
+	default:
+		unknownCommand("keys", args)
+	}
+}
+
+func keysPublish(args []string) {
+	fs := newFlagSet("keys publish")
+	privateKeyPtr := fs.String("privkey", "", "private key to publish the public key of, prompted for if empty")
+	addressPtr := fs.String("address", "", "address to publish the key for, name@domain")
+	dirPtr := fs.String("dir", ".", "document root of the domain's web server")
+	parseFlags(fs, args)
+
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	e, err := keydir.Publish(d, *addressPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	file, err := keydir.WriteDir(*dirPtr, e)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	url, _ := keydir.URL(*addressPtr)
+	publickey, _ := e.Key()
+	fmt.Printf("wrote %s, serve it at %s\n", filepath.ToSlash(file), url)
+	fmt.Printf("fingerprint: %s\n", keydir.Fingerprint(publickey))
+}
+
+func keysFetch(args []string) {
+	fs := newFlagSet("keys fetch")
+	addressPtr := fs.String("address", "", "address to fetch the key of, name@domain")
+	fingerprintPtr := fs.String("fingerprint", "", "fingerprint the key must have, learnt out of band")
+	pinsPtr := fs.String("pins", "", "file of pinned fingerprints, a new address is pinned on first fetch")
+	baseURLPtr := fs.String("base-url", "", "fetch from this server instead of https://<domain>")
+	parseFlags(fs, args)
+
+	publickey, err := fetchKey(*addressPtr, *fingerprintPtr, *pinsPtr, *baseURLPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("%x\n", publickey)
+	fmt.Printf("fingerprint: %s\n", keydir.Fingerprint(publickey))
+}
+
+// fetchKey fetches the address's published key and checks it against the
+// fingerprint and the pins, pinning it if it is new
+func fetchKey(address, fingerprint, pinsPath, baseURL string) ([33]byte, error) {
+	c := keydir.NewClient()
+	c.BaseURL = baseURL
+	publickey, err := c.Fetch(context.Background(), address)
+	if err != nil {
+		return publickey, err
+	}
+	if fingerprint != "" && fingerprint != keydir.Fingerprint(publickey) {
+		return publickey, fmt.Errorf("%w: %s has fingerprint %s, want %s", keydir.ErrPinMismatch, address, keydir.Fingerprint(publickey), fingerprint)
+	}
+	if pinsPath == "" {
+		return publickey, nil
+	}
+
+	pins, err := keydir.LoadPins(pinsPath)
+	if err != nil {
+		return publickey, err
+	}
+	added, err := pins.Check(address, publickey)
+	if err != nil {
+		return publickey, err
+	}
+	if added {
+		fmt.Fprintf(os.Stderr, "pinned %s to %s\n", address, keydir.Fingerprint(publickey))
+		return publickey, pins.Save()
+	}
+	return publickey, nil
+}
+
+// keyFromFlags is the -pubkey given, or else the key fetched for -address
+func keyFromFlags(pubkey, address, fingerprint, pinsPath string) ([33]byte, error) {
+	if pubkey != "" || address == "" {
+		return parsePublicKeyHex(pubkey)
+	}
+	publickey, err := fetchKey(address, fingerprint, pinsPath, "")
+	if errors.Is(err, keydir.ErrPinMismatch) {
+		return publickey, fmt.Errorf("%v, the published key has changed since it was pinned", err)
+	}
+	return publickey, err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/hdkey"
+	"github.com/ryohare/schnorr-go/pkg/lnurl"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+)
+
+func runLNURL(args []string) {
+	if len(args) == 0 || args[0] != "auth" {
+		printUsage(args, "usage: schnorr-go lnurl auth [flags]")
+		return
+	}
+
+	fs := newFlagSet("lnurl auth")
+	privateKeyPtr := fs.String("privkey", "", "xprv of the wallet's BIP-32 root the per domain linking keys are derived from, prompted for if empty")
+	lnurlPtr := fs.String("lnurl", "", "lnurl-auth request to sign")
+	sendPtr := fs.Bool("send", false, "call the callback url instead of just printing it")
+	parseFlags(fs, args[1:])
+
+	privateKey, err := prompt.New().SecretFlag(*privateKeyPtr, "Wallet root key (xprv): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	root, err := hdkey.Parse(privateKey)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	req, err := lnurl.ParseAuth(*lnurlPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	callback, err := req.Callback(root)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	fmt.Println(callback.String())
+	result := lnurlResult{Domain: req.Domain, Callback: callback.String()}
+	if !*sendPtr {
+		recordResult(result)
+		return
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(callback.String())
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// services answer with {"status":"OK"} or {"status":"ERROR","reason":...}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	status := struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}{}
+	if err := json.Unmarshal(body, &status); err != nil {
+		failf("unexpected response (%s): %s", resp.Status, body)
+		return
+	}
+
+	result.Authenticated = status.Status == "OK"
+	recordResult(result)
+	if result.Authenticated {
+		fmt.Printf("Authenticated to %s\n", req.Domain)
+	} else {
+		failf("authentication to %s failed: %s", req.Domain, status.Reason)
+	}
+}
+
+// lnurlResult is the result of lnurl auth
+type lnurlResult struct {
+	Domain   string `json:"domain"`
+	Callback string `json:"callback"`
+	// Authenticated is whether the service accepted the callback, with -send
+	Authenticated bool `json:"authenticated"`
+}
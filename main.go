@@ -2,89 +2,84 @@ package main
 
 import (
 	"encoding/hex"
-	"flag"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
-	"github.com/decred/dcrd/crypto/blake256"
-	"github.com/decred/dcrd/dcrec/secp256k1/v4"
-	"github.com/decred/dcrd/dcrec/secp256k1/v4/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/hooks"
 )
 
 func main() {
 
-	// message to be signed is being passed in
-	signPtr := flag.Bool("sign", false, "flag for signing a message")
-	verifyPtr := flag.Bool("verify", false, "flag for verifying a signature")
-	messagePtr := flag.String("message", "", "message to be signed")
-	pubKeyPtr := flag.String("pubkey", "", "public key to verify the signature with")
-	privateKeyPtr := flag.String("privkey", "", "private key to sign the message with")
-	signaturePtr := flag.String("sig", "", "signature to verify")
-	flag.Parse()
-
-	if *signPtr {
-
-		// fmt.Printf("Signing message %s\n", *messagePtr)
-		// Decode a hex-encoded private key.
-		// pkBytes, err := hex.DecodeString("22a47fa09a223f2aa079edf85a7c2d4f8720ee63e502ee2869afab7de234b80c")
-		pkBytes, err := hex.DecodeString(*privateKeyPtr)
-		if err != nil {
-			fmt.Println(err)
-			return
+	// subcommands are dispatched before the legacy flags are parsed
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		c := findCommand(os.Args[1])
+		if c == nil {
+			fmt.Printf("unknown command %q\n\n", os.Args[1])
+			usage()
+			os.Exit(2)
 		}
-		privKey := secp256k1.PrivKeyFromBytes(pkBytes)
-
-		// Sign a message using the private key.
-		message := *messagePtr
-		messageHash := blake256.Sum256([]byte(message))
-		signature, err := schnorr.Sign(privKey, messageHash[:])
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-
-		// Serialize and display the signature.
-		// fmt.Printf("Serialized Signature: %x\n", signature.Serialize())
-		fmt.Printf("%x\n", signature.Serialize())
+		os.Exit(runCommand(c, os.Args[2:]))
+	}
+	os.Exit(runCommand(&command{name: "schnorr-go", run: runLegacy}, os.Args[1:]))
+}
 
-		// Verify the signature for the message using the public key.
-		pubKey := privKey.PubKey()
-		verified := signature.Verify(messageHash[:], pubKey)
+// runLegacy runs the flags of the first releases, kept for scripts written
+// against them
+func runLegacy(args []string) {
+	fs := newFlagSet("schnorr-go")
+	signPtr := fs.Bool("sign", false, "same as the sign command")
+	verifyPtr := fs.Bool("verify", false, "same as the verify command with -sig")
+	messagePtr := fs.String("message", "", "message to be signed")
+	pubKeyPtr := fs.String("pubkey", "", "public key to verify the signature with")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign the message with, prompted for if empty")
+	pubKeyFilePtr := fs.String("pubkey-file", "", "PKIX PEM file of the public key instead of -pubkey")
+	privateKeyFilePtr := fs.String("privkey-file", "", "PEM, encrypted keystore or Ethereum keystore file of the private key instead of -privkey")
+	signaturePtr := fs.String("sig", "", "signature to verify")
+	detectPtr := fs.Bool("detect", false, "with -verify, try every scheme and message hash and report which matched")
+	var preSign, postSign stringList
+	fs.Var(&preSign, "pre-sign", "program to run before -sign, which may refuse or change the message, can be repeated")
+	fs.Var(&postSign, "post-sign", "program to run after -sign, which may withhold the signature, can be repeated")
+	fs.Usage = usage
+	parseFlags(fs, args)
 
-		if !verified {
-			fmt.Println("signing has failed validation")
-		}
-	} else if *verifyPtr {
-		// Decode hex-encoded serialized public key.
-		pubKeyBytes, err := hex.DecodeString(*pubKeyPtr)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
+	pubkey, err := publicKeyFlag(*pubKeyPtr, *pubKeyFilePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	privateKey, err := privateKeyFlag(*privateKeyPtr, *privateKeyFilePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
 
-		pubKey, err := schnorr.ParsePubKey(pubKeyBytes)
+	switch {
+	case *signPtr:
+		hookSet, err := hooks.NewSet(preSign, postSign)
 		if err != nil {
-			fmt.Println(err)
+			fail(err)
 			return
 		}
-
-		// Decode hex-encoded serialized signature.
-		sigBytes, err := hex.DecodeString(*signaturePtr)
-		if err != nil {
-			fmt.Println(err)
-			return
+		signature, publickey := signMessage(*messagePtr, "", nil, privateKey, hookSet, nil)
+		if signature == nil {
+			exit(1)
 		}
-		signature, err := schnorr.ParseSignature(sigBytes)
+		fmt.Printf("%x\n", signature)
+		recordResult(signOutput{Signature: hex.EncodeToString(signature), PublicKey: hex.EncodeToString(publickey), Fingerprint: fingerprintHex(hex.EncodeToString(publickey))})
+	case *verifyPtr && *detectPtr:
+		detectScheme(pubkey, *messagePtr, "", nil, *signaturePtr)
+	case *verifyPtr:
+		verified, err := verifyMessage(pubkey, *messagePtr, "", nil, *signaturePtr)
 		if err != nil {
-			fmt.Println(err)
+			fail(err)
 			return
 		}
-
-		// Verify the signature for the message using the public key.
-		message := *messagePtr
-		messageHash := blake256.Sum256([]byte(message))
-		verified := signature.Verify(messageHash[:], pubKey)
-		fmt.Println("Signature Verified?", verified)
-	} else {
-		flag.PrintDefaults()
+		printVerified("Signature", verified)
+	default:
+		usage()
+		current.fail(errors.New("give a command, or -sign or -verify"))
+		exit(2)
 	}
 }
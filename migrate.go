@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/migration"
+	"github.com/ryohare/schnorr-go/pkg/nostr"
+)
+
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go migrate <link|verify> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "link":
+		migrateLink(args[1:])
+	case "verify":
+		migrateVerify(args[1:])
+	default:
+		unknownCommand("migrate", args)
+	}
+}
+
+func migrateLink(args []string) {
+	fs := newFlagSet("migrate link")
+	privateKeyPtr := fs.String("privkey", "", "legacy private key, prompted for if empty")
+	bip340KeyPtr := fs.String("bip340-privkey", "", "private key of the BIP-340 identity, the legacy key's own if empty")
+	outputPtr := fs.String("output", "", "file to write the statement to, stdout if empty")
+	parseFlags(fs, args)
+
+	legacy, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	bip340 := legacy
+	if *bip340KeyPtr != "" {
+		if bip340, err = readPrivateKeyHex(*bip340KeyPtr); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	statement, err := migration.Link(legacy, bip340)
+	if err != nil {
+		fail(err)
+		return
+	}
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+	}
+}
+
+func migrateVerify(args []string) {
+	fs := newFlagSet("migrate verify")
+	statementPtr := fs.String("statement", "", "statement file from migrate link")
+	legacyKeyPtr := fs.String("legacy-key", "", "legacy public key the statement must link, any if empty")
+	bip340KeyPtr := fs.String("bip340-key", "", "x-only public key or npub the statement must link to, any if empty")
+	parseFlags(fs, args)
+
+	data, err := os.ReadFile(*statementPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	statement := new(migration.Statement)
+	if err := json.Unmarshal(data, statement); err != nil {
+		failf("statement is not json: %v", err)
+		return
+	}
+	legacy, bip340, err := migration.Verify(statement)
+	if err != nil {
+		fail(err)
+		printVerified("Statement", false)
+		return
+	}
+
+	if *legacyKeyPtr != "" {
+		want, err := parsePublicKeyHex(*legacyKeyPtr)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if want != legacy {
+			fmt.Printf("statement links legacy key %x, not %x\n", legacy, want)
+			printVerified("Statement", false)
+			return
+		}
+	}
+	if *bip340KeyPtr != "" {
+		want, err := nostr.PublicKeyHex(*bip340KeyPtr)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if want != hex.EncodeToString(bip340[:]) {
+			fmt.Printf("statement links to bip340 key %x, not %s\n", bip340, want)
+			printVerified("Statement", false)
+			return
+		}
+	}
+
+	fmt.Printf("legacy key %x (%s)\n", legacy, migration.Fingerprint(legacy[:]))
+	fmt.Printf("bip340 key %x (%s)\n", bip340, migration.Fingerprint(bip340[:]))
+	printVerified("Statement", true)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/bip39"
+	"github.com/ryohare/schnorr-go/pkg/hdkey"
+	"github.com/ryohare/schnorr-go/pkg/keyformat"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// BIP-39 phrases, see pkg/bip39: keygen -mnemonic writes a phrase instead
+// of a key, and restore turns the phrase, and its passphrase if it has one,
+// back into the key. Both take the BIP-32 -derivation-path of the key the
+// phrase stands for, the master key if not given.
+//
+
+// generateMnemonic writes a new phrase with entropy from random to output,
+// stdout if empty, and returns the key it restores to at the derivation
+// path
+func generateMnemonic(p *prompt.Prompter, random io.Reader, words int, askPassphrase bool, path, output, pubout string) (*schnorr.PrivateKey, error) {
+	mnemonic, err := bip39.Generate(random, words)
+	if err != nil {
+		return nil, err
+	}
+	passphrase := ""
+	if askPassphrase {
+		b, err := p.NewPassphrase("Passphrase for the phrase: ")
+		if err != nil {
+			return nil, err
+		}
+		passphrase = string(b)
+	}
+	d, err := bip39.DerivedKey(mnemonic, passphrase, path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := schnorr.NewPrivateKey(d)
+	if err != nil {
+		return nil, err
+	}
+
+	if output == "" {
+		fmt.Println(mnemonic)
+	} else if err := os.WriteFile(output, []byte(mnemonic+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	if pubout != "" {
+		pemPub, err := keyformat.EncodePEMPublicKey(key.PublicKey().Serialize())
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(pubout, pemPub, 0644); err != nil {
+			return nil, err
+		}
+	}
+	if askPassphrase {
+		fmt.Fprintln(os.Stderr, "the phrase restores to this key only with the passphrase, back both up")
+	}
+	return key, nil
+}
+
+// runRestore prints the key of a BIP-39 phrase, the master key or the one
+// at -derivation-path
+func runRestore(args []string) {
+	fs := newFlagSet("restore")
+	mnemonicPtr := fs.String("mnemonic", "", "the phrase, prompted for if empty")
+	passphrasePtr := fs.Bool("passphrase", false, "ask for the passphrase the phrase was made with")
+	outputPtr := fs.String("output", "", "file to write the private key to, stdout if empty")
+	formatPtr := fs.String("format", "hex", "hex, pem for a PKCS#8 file, keystore for one encrypted under a passphrase, or ethereum for a wallet's version 3 keystore")
+	pubOutPtr := fs.String("pubout", "", "file to write the public key to as a PKIX PEM file")
+	pathPtr := fs.String("derivation-path", "m", "BIP-32 path of the key to restore, such as m/86'/0'/0'/0/0")
+	parseFlags(fs, args)
+
+	if _, err := hdkey.ParsePath(*pathPtr); err != nil {
+		fail(err)
+		exit(2)
+	}
+	p := prompt.New()
+	mnemonic, err := p.SecretFlag(*mnemonicPtr, "Phrase: ")
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	passphrase := ""
+	if *passphrasePtr {
+		b, err := p.Secret("Passphrase: ")
+		if err != nil {
+			fail(err)
+			exit(2)
+		}
+		passphrase = string(b)
+	}
+
+	d, err := bip39.DerivedKey(mnemonic, passphrase, *pathPtr)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	key, err := schnorr.NewPrivateKey(d)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	if err := writeKeyFiles(key, *formatPtr, *outputPtr, *pubOutPtr); err != nil {
+		fail(err)
+		exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "public key: %s\n", key.PublicKey())
+	recordKey(key.PublicKey().String(), *outputPtr, *pubOutPtr)
+}
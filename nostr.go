@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/nostr"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+)
+
+// stringList is a flag which can be given multiple times
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func runNostr(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go nostr <publish|sign-event|fetch|encode|decode> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "publish":
+		nostrPublish(args[1:])
+	case "sign-event":
+		nostrSignEvent(args[1:])
+	case "fetch":
+		nostrFetch(args[1:])
+	case "encode":
+		nostrEncode(args[1:])
+	case "decode":
+		nostrDecode(args[1:])
+	default:
+		unknownCommand("nostr", args)
+	}
+}
+
+func nostrPublish(args []string) {
+	var relays, tags stringList
+
+	fs := newFlagSet("nostr publish")
+	privateKeyPtr := fs.String("privkey", "", "private key, hex or nsec, to sign the event with, prompted for if empty")
+	contentPtr := fs.String("content", "", "content of the event")
+	kindPtr := fs.Int("kind", 1, "kind of the event")
+	timeoutPtr := fs.Duration("timeout", nostr.DefaultTimeout, "how long to wait on each relay")
+	fs.Var(&relays, "relay", "relay url to publish to, can be repeated")
+	fs.Var(&tags, "tag", "tag in the form name=value[,value...], can be repeated")
+	parseFlags(fs, args)
+
+	if len(relays) == 0 {
+		failf("at least one -relay is required")
+		return
+	}
+
+	privateKey, err := secretPrivateKey(prompt.New(), *privateKeyPtr, "Private key (hex or nsec): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	pkBytes, err := hex.DecodeString(privateKey)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	eventTags := [][]string{}
+	for _, tag := range tags {
+		name, values, found := strings.Cut(tag, "=")
+		if !found {
+			failf("tag %q is not in the form name=value", tag)
+			return
+		}
+		eventTags = append(eventTags, append([]string{name}, strings.Split(values, ",")...))
+	}
+
+	ev := nostr.NewEvent(*kindPtr, *contentPtr, eventTags)
+	if err := ev.Sign(pkBytes); err != nil {
+		fail(err)
+		return
+	}
+
+	out, _ := json.Marshal(ev)
+	fmt.Println(string(out))
+	recordResult(ev)
+
+	// a relay that can't be reached or refuses the event is reported and the
+	// rest tried, failing only if none accepted it
+	accepted := 0
+	for _, url := range relays {
+		relay, err := nostr.Connect(url, *timeoutPtr)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		result, err := relay.Publish(ev)
+		relay.Close()
+
+		for _, notice := range result.Notices {
+			fmt.Printf("%s NOTICE: %s\n", url, notice)
+		}
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		if result.Accepted {
+			fmt.Printf("%s OK %s\n", url, result.Message)
+			accepted++
+		} else {
+			fmt.Printf("%s REJECTED %s\n", url, result.Message)
+		}
+	}
+	if accepted == 0 {
+		failf("no relay accepted the event")
+	}
+}
+
+// nostrSignEvent signs an event given as json, filling in its pubkey, id
+// and sig, and prints it for a client or another tool to publish
+func nostrSignEvent(args []string) {
+	fs := newFlagSet("nostr sign-event")
+	privateKeyPtr := fs.String("privkey", "", "private key, hex or nsec, to sign the event with, prompted for if empty")
+	eventPtr := fs.String("event", "", "event to sign as json, such as {\"kind\":1,\"content\":\"gm\"}")
+	inPtr := fs.String("in", "", "file of the event instead of -event, - for stdin")
+	parseFlags(fs, args)
+
+	var data []byte
+	switch {
+	case *eventPtr != "" && *inPtr != "":
+		failf("give -event or -in, not both")
+		exit(2)
+	case *eventPtr != "":
+		data = []byte(*eventPtr)
+	case *inPtr == "-":
+		if *privateKeyPtr == "" {
+			failf("stdin holds the event, so the private key must be given with -privkey")
+			exit(2)
+		}
+		var err error
+		if data, err = io.ReadAll(os.Stdin); err != nil {
+			fail(err)
+			exit(1)
+		}
+	case *inPtr != "":
+		var err error
+		if data, err = os.ReadFile(*inPtr); err != nil {
+			fail(err)
+			exit(1)
+		}
+	default:
+		failf("usage: schnorr-go nostr sign-event [-privkey key] <-event json|-in file>")
+		exit(2)
+	}
+
+	ev, err := nostr.ParseEvent(data)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	privateKey, err := secretPrivateKey(prompt.New(), *privateKeyPtr, "Private key (hex or nsec): ")
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	pkBytes, err := hex.DecodeString(privateKey)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	// an event naming its author may only be signed by them
+	if ev.PubKey != "" {
+		pubkey, err := nostr.PublicKey(pkBytes)
+		if err != nil {
+			fail(err)
+			exit(2)
+		}
+		if !strings.EqualFold(ev.PubKey, pubkey) {
+			failf("event is by %s, not the key's %s", ev.PubKey, pubkey)
+			exit(1)
+		}
+	}
+	if err := ev.Sign(pkBytes); err != nil {
+		fail(err)
+		exit(1)
+	}
+
+	out, _ := json.Marshal(ev)
+	fmt.Println(string(out))
+	recordResult(ev)
+}
+
+func nostrFetch(args []string) {
+	var relays, ids, authors stringList
+
+	fs := newFlagSet("nostr fetch")
+	kindPtr := fs.Int("kind", -1, "only fetch events of this kind")
+	limitPtr := fs.Int("limit", 20, "maximum number of events to fetch from each relay")
+	sincePtr := fs.Duration("since", 0, "only fetch events newer than this")
+	verifyPtr := fs.Bool("verify", false, "verify the id and signature of each event")
+	timeoutPtr := fs.Duration("timeout", nostr.DefaultTimeout, "how long to wait on each relay")
+	fs.Var(&relays, "relay", "relay url to fetch from, can be repeated")
+	fs.Var(&ids, "id", "event id to fetch, can be repeated")
+	fs.Var(&authors, "author", "author pubkey, hex or npub, to fetch events for, can be repeated")
+	parseFlags(fs, args)
+
+	if len(relays) == 0 {
+		failf("at least one -relay is required")
+		return
+	}
+	for i, author := range authors {
+		pubkey, err := nostr.PublicKeyHex(author)
+		if err != nil {
+			fail(err)
+			return
+		}
+		authors[i] = pubkey
+	}
+
+	filter := nostr.Filter{IDs: ids, Authors: authors, Limit: *limitPtr}
+	if *kindPtr >= 0 {
+		filter.Kinds = []int{*kindPtr}
+	}
+	if *sincePtr > 0 {
+		filter.Since = time.Now().Add(-*sincePtr).Unix()
+	}
+
+	// as with publish, one relay failing doesn't stop the others
+	answered := 0
+	for _, url := range relays {
+		relay, err := nostr.Connect(url, *timeoutPtr)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		events, err := relay.Fetch(filter)
+		for _, notice := range relay.Notices() {
+			fmt.Printf("%s NOTICE: %s\n", url, notice)
+		}
+		relay.Close()
+
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			answered++
+		}
+
+		for _, ev := range events {
+			out, _ := json.Marshal(ev)
+			fmt.Println(string(out))
+			recordResult(ev)
+
+			if *verifyPtr {
+				verified, err := ev.Verify()
+				if err != nil {
+					fmt.Printf("Event %s Verified? false (%v)\n", ev.ID, err)
+				} else {
+					fmt.Printf("Event %s Verified? %v\n", ev.ID, verified)
+				}
+				if err != nil || !verified {
+					current.code = 1
+				}
+			}
+		}
+	}
+	if answered == 0 {
+		failf("no relay answered")
+	}
+}
+
+// nostrEncode prints the npub of a public key, or the nsec and npub of a
+// private key, for pasting into nostr clients
+func nostrEncode(args []string) {
+	fs := newFlagSet("nostr encode")
+	pubKeyPtr := fs.String("pubkey", "", "hex x-only or compressed public key to encode as an npub")
+	privateKeyPtr := fs.String("privkey", "", "private key to encode as an nsec, prompted for if neither is given")
+	parseFlags(fs, args)
+
+	if *pubKeyPtr != "" {
+		pubkey := *pubKeyPtr
+		if len(pubkey) == 66 {
+			pubkey = pubkey[2:]
+		}
+		npub, err := nostr.EncodePublicKey(pubkey)
+		if err != nil {
+			fail(err)
+			return
+		}
+		fmt.Println(npub)
+		recordResult(nostrKeyResult{NPub: npub})
+		return
+	}
+
+	privateKey, err := secretPrivateKey(prompt.New(), *privateKeyPtr, "Private key (hex, WIF or nsec): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	pkBytes, err := hex.DecodeString(privateKey)
+	if err != nil {
+		fail(err)
+		return
+	}
+	nsec, err := nostr.EncodePrivateKey(pkBytes)
+	if err != nil {
+		fail(err)
+		return
+	}
+	pubkey, err := nostr.PublicKey(pkBytes)
+	if err != nil {
+		fail(err)
+		return
+	}
+	npub, _ := nostr.EncodePublicKey(pubkey)
+	fmt.Println(nsec)
+	fmt.Println(npub)
+	recordResult(nostrKeyResult{NPub: npub, NSec: nsec})
+}
+
+// nostrKeyResult is the result of nostr encode and decode
+type nostrKeyResult struct {
+	NPub string `json:"npub,omitempty"`
+	NSec string `json:"nsec,omitempty"`
+	Hex  string `json:"hex,omitempty"`
+}
+
+// nostrDecode prints the hex key of an npub or nsec
+func nostrDecode(args []string) {
+	fs := newFlagSet("nostr decode")
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		failf("usage: schnorr-go nostr decode <npub|nsec>")
+		exit(2)
+	}
+	key := fs.Arg(0)
+
+	switch {
+	case strings.HasPrefix(strings.ToLower(key), "nsec1"):
+		d, err := nostr.DecodePrivateKey(key)
+		if err != nil {
+			fail(err)
+			return
+		}
+		fmt.Printf("%x\n", d)
+		recordResult(nostrKeyResult{Hex: fmt.Sprintf("%x", d)})
+	default:
+		pubkey, err := nostr.DecodePublicKey(key)
+		if err != nil {
+			fail(err)
+			return
+		}
+		fmt.Println(pubkey)
+		recordResult(nostrKeyResult{Hex: pubkey})
+	}
+}
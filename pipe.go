@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/decred/dcrd/crypto/blake256"
+	"github.com/ryohare/schnorr-go/pkg/pipe"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// The tool as a long-running filter, see pkg/pipe: json requests on stdin,
+// one response per line on stdout. By default messages are signed as the
+// sign command signs them, so either verifies the other's signatures.
+//
+
+func runPipe(args []string) {
+	fs := flag.NewFlagSet("pipe", flag.ExitOnError)
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, only verify requests are answered if neither it nor -privkey-file is given")
+	privateKeyFilePtr := fs.String("privkey-file", "", "PEM, encrypted keystore or Ethereum keystore file of the private key instead of -privkey; a passphrase is read from the first line of stdin unless it's a terminal")
+	schemePtr := fs.String("scheme", string(schnorr.SchemeDecred), "scheme of requests that don't name one: legacy, bip340 or ec-schnorr-dcrv0")
+	hashPtr := fs.String("hash", "blake256", "hash of messages, blake256 as sign uses or sha256")
+	fs.Parse(args)
+
+	scheme, err := schnorr.ParseScheme(*schemePtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	s := &pipe.Server{Scheme: scheme}
+	switch *hashPtr {
+	case "blake256":
+		s.Hash = blake256.Sum256
+	case "sha256":
+		s.Hash = sha256.Sum256
+	default:
+		failf("unknown hash %q, want blake256 or sha256", *hashPtr)
+		exit(2)
+	}
+
+	privateKey, err := privateKeyFlag(*privateKeyPtr, *privateKeyFilePtr)
+	if err == nil {
+		privateKey, err = privateKeyHex(privateKey)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(2)
+	}
+	if privateKey != "" {
+		d, ok := new(big.Int).SetString(privateKey, 16)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "private key is not hex")
+			exit(2)
+		}
+		s.PrivateKey = d
+	}
+
+	// stdout carries the responses, so errors go to stderr
+	if err := s.Serve(prompt.Stdin(), os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+}
@@ -0,0 +1,336 @@
+package archive
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+)
+
+//
+// A signed archive is a tar file whose first member is an envelope over a
+// manifest of every other member: its path, mode, size and sha256. Unpack
+// refuses the archive unless the envelope verifies, every member matches
+// its manifest entry and nothing is missing or extra.
+//
+
+// ManifestName is the name of the first member of the archive
+const ManifestName = ".schnorr-manifest.json"
+
+// PayloadType is the envelope payload type of the manifest
+const PayloadType = "application/vnd.schnorr-go.archive-manifest+json"
+
+// Entry is one member of the archive. Directories have no size or digest.
+type Entry struct {
+	Path   string      `json:"path"`
+	Dir    bool        `json:"dir,omitempty"`
+	Mode   fs.FileMode `json:"mode"`
+	Size   int64       `json:"size,omitempty"`
+	SHA256 string      `json:"sha256,omitempty"`
+}
+
+// Manifest lists the members of the archive in the order they are stored.
+// Annotations are the signed annotations of the envelope around it, filled
+// in by ReadManifest, rather than part of the manifest itself.
+type Manifest struct {
+	Entries     []Entry           `json:"entries"`
+	Annotations map[string]string `json:"-"`
+}
+
+// walk lists the tree under dir in sorted order, hashing every file
+func walk(dir string) (*Manifest, error) {
+	m := &Manifest{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		if rel == ManifestName {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		e := Entry{Path: filepath.ToSlash(rel), Mode: info.Mode().Perm()}
+		switch {
+		case info.IsDir():
+			e.Dir = true
+		case info.Mode().IsRegular():
+			digest, err := hashFile(p)
+			if err != nil {
+				return err
+			}
+			e.Size = info.Size()
+			e.SHA256 = hex.EncodeToString(digest[:])
+		default:
+			return fmt.Errorf("%s is not a regular file or directory", p)
+		}
+		m.Entries = append(m.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(m.Entries, func(i, j int) bool { return m.Entries[i].Path < m.Entries[j].Path })
+	return m, nil
+}
+
+func hashFile(p string) ([32]byte, error) {
+	var digest [32]byte
+	f, err := os.Open(p)
+	if err != nil {
+		return digest, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return digest, err
+	}
+	copy(digest[:], h.Sum(nil))
+	return digest, nil
+}
+
+// SignManifest lists and hashes the tree under dir, as Pack does, and
+// returns the manifest signed in an envelope. A manifest left in the top of
+// a tree is skipped, so the tree can be signed in place and checked again.
+// The options, such as annotations, are passed on to envelope.Sign.
+func SignManifest(dir string, privatekey *big.Int, opts ...envelope.Option) (*Manifest, []byte, error) {
+	m, err := walk(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	e, err := envelope.Sign(privatekey, PayloadType, payload, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.Annotations = e.Annotations
+	signed, err := json.Marshal(e)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, signed, nil
+}
+
+// ReadManifest verifies a signed manifest against publickey, with any extra
+// checks given
+func ReadManifest(signed []byte, publickey [33]byte, opts ...envelope.VerifyOption) (*Manifest, error) {
+	e := new(envelope.Envelope)
+	if err := json.Unmarshal(signed, e); err != nil {
+		return nil, fmt.Errorf("manifest is not an envelope: %v", err)
+	}
+	if e.PayloadType != PayloadType {
+		return nil, fmt.Errorf("unexpected manifest payload type %q", e.PayloadType)
+	}
+	if err := envelope.Verify(e, publickey, opts...); err != nil {
+		return nil, err
+	}
+	m := new(Manifest)
+	if err := json.Unmarshal(e.Payload, m); err != nil {
+		return nil, fmt.Errorf("manifest is not valid json: %v", err)
+	}
+	m.Annotations = e.Annotations
+	for _, entry := range m.Entries {
+		if _, err := clean(entry.Path); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Pack writes dir as a signed archive. Timestamps and owners are left out so
+// the same tree always packs to the same bytes.
+func Pack(w io.Writer, dir string, privatekey *big.Int, opts ...envelope.Option) (*Manifest, error) {
+	m, signed, err := SignManifest(dir, privatekey, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{Name: ManifestName, Mode: 0644, Size: int64(len(signed)), Typeflag: tar.TypeReg, Format: tar.FormatPAX}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(signed); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range m.Entries {
+		hdr := &tar.Header{Name: entry.Path, Mode: int64(entry.Mode), Format: tar.FormatPAX}
+		if entry.Dir {
+			hdr.Name += "/"
+			hdr.Typeflag = tar.TypeDir
+			if err := tw.WriteHeader(hdr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = entry.Size
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.CopyN(tw, f, entry.Size)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s changed while packing: %v", entry.Path, err)
+		}
+	}
+
+	return m, tw.Close()
+}
+
+// clean rejects paths which would land outside the destination
+func clean(name string) (string, error) {
+	p := path.Clean(strings.TrimSuffix(name, "/"))
+	if p == "." || path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("unsafe path %q in archive", name)
+	}
+	return p, nil
+}
+
+// Unpack verifies the archive against publickey and extracts it into dir.
+// Each file is written under a temporary name and only renamed into place
+// once its digest matches, so a tampered archive leaves no tampered files
+// behind, though members before the bad one are kept.
+func Unpack(r io.Reader, dir string, publickey [33]byte, opts ...envelope.VerifyOption) (*Manifest, error) {
+	tr := tar.NewReader(r)
+	signed, err := readManifestMember(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ReadManifest(signed, publickey, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	for i, entry := range m.Entries {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("archive ends before %s", entry.Path)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := clean(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if name != entry.Path {
+			return nil, fmt.Errorf("member %d is %q, manifest has %q", i+1, name, entry.Path)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(entry.Path))
+
+		if entry.Dir {
+			if hdr.Typeflag != tar.TypeDir {
+				return nil, fmt.Errorf("%s should be a directory", entry.Path)
+			}
+			if err := os.MkdirAll(target, entry.Mode.Perm()|0700); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if hdr.Typeflag != tar.TypeReg || hdr.Size != entry.Size {
+			return nil, fmt.Errorf("%s is not the %d byte file in the manifest", entry.Path, entry.Size)
+		}
+		if err := extract(tr, target, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if hdr, err := tr.Next(); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s is not in the manifest", hdr.Name)
+	}
+	return m, nil
+}
+
+// readManifestMember reads the signed manifest at the start of the archive
+func readManifestMember(tr *tar.Reader) ([]byte, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %v", err)
+	}
+	if hdr.Name != ManifestName {
+		return nil, fmt.Errorf("first member is %q, want %s", hdr.Name, ManifestName)
+	}
+	return io.ReadAll(io.LimitReader(tr, 64<<20))
+}
+
+// Inspect returns the envelope around the manifest of an archive without
+// verifying anything, for looking at who claims to have signed it and its
+// annotations. Nothing in it can be trusted until ReadManifest or Unpack
+// has verified it.
+func Inspect(r io.Reader) (*envelope.Envelope, error) {
+	signed, err := readManifestMember(tar.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	e := new(envelope.Envelope)
+	if err := json.Unmarshal(signed, e); err != nil {
+		return nil, fmt.Errorf("manifest is not an envelope: %v", err)
+	}
+	return e, nil
+}
+
+func extract(r io.Reader, target string, entry Entry) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".unpack-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	_, err = io.CopyN(io.MultiWriter(tmp, h), r, entry.Size)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != entry.SHA256 {
+		return fmt.Errorf("%s does not match its digest in the manifest", entry.Path)
+	}
+
+	if err := os.Chmod(tmp.Name(), entry.Mode.Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
@@ -0,0 +1,138 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func tree(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"README":         "hello",
+		"bin/run.sh":     "#!/bin/sh\necho hi\n",
+		"docs/a/b/c.txt": "deep",
+		"docs/empty.txt": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("Unexpected error from MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("Unexpected error from WriteFile: %v", err)
+		}
+	}
+	os.Chmod(filepath.Join(dir, "bin/run.sh"), 0755)
+	os.Mkdir(filepath.Join(dir, "empty"), 0755)
+	return dir
+}
+
+func TestPackUnpack(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("archive"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	src := tree(t)
+
+	// when
+	buf := new(bytes.Buffer)
+	if _, err := Pack(buf, src, keys[0].PrivateKey); err != nil {
+		t.Fatalf("Unexpected error from Pack: %v", err)
+	}
+	dst := t.TempDir()
+	m, err := Unpack(bytes.NewReader(buf.Bytes()), dst, keys[0].PublicKey)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from Unpack: %v", err)
+	}
+	if len(m.Entries) != 9 {
+		t.Fatalf("len(Entries) = %d, want 9", len(m.Entries))
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "docs/a/b/c.txt"))
+	if err != nil || string(data) != "deep" {
+		t.Fatalf("unpacked c.txt = %q, %v, want deep", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dst, "bin/run.sh"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("unpacked run.sh mode = %v, %v, want 0755", info.Mode(), err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "empty")); err != nil || !info.IsDir() {
+		t.Fatalf("unpacked empty directory = %v, want a directory", err)
+	}
+
+	t.Run("Annotations are signed and read back", func(t *testing.T) {
+		annotated := new(bytes.Buffer)
+		if _, err := Pack(annotated, src, keys[0].PrivateKey, envelope.WithAnnotations(map[string]string{"build": "42"})); err != nil {
+			t.Fatalf("Unexpected error from Pack: %v", err)
+		}
+
+		e, err := Inspect(bytes.NewReader(annotated.Bytes()))
+		if err != nil || e.Annotations["build"] != "42" {
+			t.Fatalf("Inspect() = %v, %v, want the build annotation", e, err)
+		}
+		m, err := Unpack(bytes.NewReader(annotated.Bytes()), t.TempDir(), keys[0].PublicKey, envelope.RequireAnnotations(map[string]string{"build": "42"}))
+		if err != nil || m.Annotations["build"] != "42" {
+			t.Fatalf("Unpack() annotations = %v, %v, want the build annotation", m, err)
+		}
+		if _, err := Unpack(bytes.NewReader(buf.Bytes()), t.TempDir(), keys[0].PublicKey, envelope.RequireAnnotations(map[string]string{"build": "42"})); err == nil {
+			t.Fatalf("Unpack() of an archive without the annotation = nil error, want error")
+		}
+	})
+
+	t.Run("Packing is reproducible", func(t *testing.T) {
+		again := new(bytes.Buffer)
+		if _, err := Pack(again, src, keys[0].PrivateKey); err != nil {
+			t.Fatalf("Unexpected error from Pack: %v", err)
+		}
+		if !bytes.Equal(again.Bytes(), buf.Bytes()) {
+			t.Fatalf("Pack() twice gave different archives")
+		}
+	})
+
+	t.Run("Wrong key fails", func(t *testing.T) {
+		if _, err := Unpack(bytes.NewReader(buf.Bytes()), t.TempDir(), keys[1].PublicKey); err == nil {
+			t.Fatalf("Unpack() with another key = nil, want error")
+		}
+	})
+
+	t.Run("Tampered file fails", func(t *testing.T) {
+		tampered := bytes.Replace(buf.Bytes(), []byte("deep"), []byte("DEEP"), 1)
+		out := t.TempDir()
+		if _, err := Unpack(bytes.NewReader(tampered), out, keys[0].PublicKey); err == nil {
+			t.Fatalf("Unpack() of a tampered archive = nil, want error")
+		}
+		if _, err := os.Stat(filepath.Join(out, "docs/a/b/c.txt")); !os.IsNotExist(err) {
+			t.Fatalf("tampered file was left behind: %v", err)
+		}
+	})
+
+	t.Run("Extra member fails", func(t *testing.T) {
+		extended := new(bytes.Buffer)
+		tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+		tw := tar.NewWriter(extended)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			tw.WriteHeader(hdr)
+			io.Copy(tw, tr)
+		}
+		tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+		tw.Write([]byte("x"))
+		tw.Close()
+
+		if _, err := Unpack(extended, t.TempDir(), keys[0].PublicKey); err == nil {
+			t.Fatalf("Unpack() with a member outside the manifest = nil, want error")
+		}
+	})
+}
@@ -0,0 +1,292 @@
+package attest
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+)
+
+//
+// Key usage attestation: a report of what each key signed over a period,
+// counted from the audit logs of the daemon, both the signing api's and
+// -watch's, and signed as an envelope by whoever runs the review. It lists
+// per key the signatures and refusals, the clients that asked, the days
+// they were made on, and a digest over everything signed, so the report
+// can later be checked against the digests without carrying them all.
+//
+// Findings point out what a reviewer should look at: keys that aren't in
+// the keystores the report was made against, refused requests, and days
+// with far more signatures than a key's usual.
+//
+
+// PayloadType is the payload type of signed reports
+const PayloadType = "application/vnd.schnorr-go.attestation+json"
+
+// burstFactor and burstMin decide when a day is unusually busy for a key:
+// more than burstFactor times its mean over the other days, and at least
+// burstMin signatures
+const (
+	burstFactor = 3
+	burstMin    = 10
+)
+
+// Entry is a line of an audit log, with the fields of the signing api's and
+// of -watch's that a report uses
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Outcome   string    `json:"outcome"`
+	PublicKey string    `json:"publicKey"`
+	Key       string    `json:"key"`
+	Client    string    `json:"client"`
+	Digest    string    `json:"digest"`
+	// BLAKE256 and Path are the file -watch signed, SHA256 its digest in
+	// logs from before -watch signed as sign -detach does
+	BLAKE256 string `json:"blake256"`
+	SHA256   string `json:"sha256"`
+	Path     string `json:"path"`
+}
+
+// Report is the usage of every key over [From, To)
+type Report struct {
+	From      time.Time   `json:"from"`
+	To        time.Time   `json:"to"`
+	Generated time.Time   `json:"generated"`
+	Keys      []*KeyUsage `json:"keys"`
+	Findings  []string    `json:"findings,omitempty"`
+}
+
+// KeyUsage is what one key did over the period
+type KeyUsage struct {
+	PublicKey string `json:"publicKey"`
+	// Name is the key's in the keystores, or the signing api's name for
+	// it, Known whether it is in the keystores at all
+	Name    string         `json:"name,omitempty"`
+	Known   bool           `json:"known"`
+	Signed  int            `json:"signed"`
+	Refused int            `json:"refused"`
+	First   *time.Time     `json:"first,omitempty"`
+	Last    *time.Time     `json:"last,omitempty"`
+	Clients map[string]int `json:"clients,omitempty"`
+	Days    map[string]int `json:"days,omitempty"`
+	// DigestsSHA256 is the sha256 of the sorted hex digests signed, one
+	// per line, Digests the digests themselves if asked for
+	DigestsSHA256 string   `json:"digestsSha256,omitempty"`
+	Digests       []string `json:"digests,omitempty"`
+}
+
+// Builder gathers audit log entries into a report
+type Builder struct {
+	From, To time.Time
+	// IncludeDigests lists every digest in the report, not only their hash
+	IncludeDigests bool
+
+	known   map[string]string
+	usage   map[string]*KeyUsage
+	digests map[string][]string
+	// names are the signing api's names of public keys, for refusals,
+	// which are logged without the public key
+	names map[string]string
+}
+
+// NewBuilder reports on entries from from up to to
+func NewBuilder(from, to time.Time) *Builder {
+	return &Builder{
+		From:    from,
+		To:      to,
+		known:   map[string]string{},
+		usage:   map[string]*KeyUsage{},
+		digests: map[string][]string{},
+		names:   map[string]string{},
+	}
+}
+
+// Known adds a key from the keystores, so it's reported even if unused and
+// its use isn't a finding
+func (b *Builder) Known(publickey, name string) {
+	b.known[publickey] = name
+	b.key(publickey)
+}
+
+func (b *Builder) key(id string) *KeyUsage {
+	u, ok := b.usage[id]
+	if !ok {
+		u = &KeyUsage{PublicKey: id, Clients: map[string]int{}, Days: map[string]int{}}
+		b.usage[id] = u
+	}
+	return u
+}
+
+// Add counts an entry, if it's in the period. Refusals of the signing api
+// name the key but not its public key, so they are counted against the
+// public key it signed with under that name.
+func (b *Builder) Add(e Entry) {
+	if e.Time.Before(b.From) || !e.Time.Before(b.To) {
+		return
+	}
+	// -watch's skipped and failed files weren't asked of the key
+	if e.Outcome != "signed" && e.Outcome != "refused" {
+		return
+	}
+	if e.PublicKey == "" && e.Key == "" {
+		return
+	}
+	id := e.PublicKey
+	if id == "" {
+		if id = b.names[e.Key]; id == "" {
+			id = "key " + e.Key
+		}
+	} else if e.Key != "" {
+		b.names[e.Key] = id
+	}
+
+	u := b.key(id)
+	if e.Outcome == "refused" {
+		u.Refused++
+		return
+	}
+	u.Signed++
+	if u.Name == "" {
+		u.Name = e.Key
+	}
+	t := e.Time.UTC()
+	if u.First == nil || t.Before(*u.First) {
+		u.First = &t
+	}
+	if u.Last == nil || t.After(*u.Last) {
+		u.Last = &t
+	}
+	client := e.Client
+	if client == "" && e.Path != "" {
+		client = "watch"
+	}
+	if client != "" {
+		u.Clients[client]++
+	}
+	u.Days[t.Format("2006-01-02")]++
+	digest := e.Digest
+	if digest == "" {
+		digest = e.BLAKE256
+	}
+	if digest == "" {
+		digest = e.SHA256
+	}
+	if digest != "" {
+		b.digests[id] = append(b.digests[id], digest)
+	}
+}
+
+// Read adds the entries of an audit log of json lines. Lines that aren't
+// entries are an error, as a report over a log it couldn't read whole
+// would attest to less than it claims.
+func (b *Builder) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("line %d is not an audit entry: %v", n, err)
+		}
+		b.Add(e)
+	}
+	return scanner.Err()
+}
+
+// Report returns the report generated at now, keys sorted by public key
+func (b *Builder) Report(now time.Time) *Report {
+	report := &Report{From: b.From.UTC(), To: b.To.UTC(), Generated: now.UTC(), Keys: []*KeyUsage{}}
+	for id, u := range b.usage {
+		if name, ok := b.known[id]; ok {
+			u.Known = true
+			if name != "" {
+				u.Name = name
+			}
+		}
+		if digests := b.digests[id]; len(digests) > 0 {
+			sorted := append([]string{}, digests...)
+			sort.Strings(sorted)
+			h := sha256.New()
+			for _, d := range sorted {
+				io.WriteString(h, d+"\n")
+			}
+			u.DigestsSHA256 = hex.EncodeToString(h.Sum(nil))
+			if b.IncludeDigests {
+				u.Digests = sorted
+			}
+		}
+		report.Keys = append(report.Keys, u)
+	}
+	sort.Slice(report.Keys, func(i, j int) bool { return report.Keys[i].PublicKey < report.Keys[j].PublicKey })
+
+	for _, u := range report.Keys {
+		report.Findings = append(report.Findings, findings(u, len(b.known) > 0)...)
+	}
+	return report
+}
+
+// findings are what's unusual about the key's usage
+func findings(u *KeyUsage, haveKeystores bool) []string {
+	var found []string
+	label := u.PublicKey
+	if u.Name != "" {
+		label = fmt.Sprintf("%s (%s)", u.Name, u.PublicKey)
+	}
+	if haveKeystores && !u.Known && u.Signed > 0 {
+		found = append(found, fmt.Sprintf("%s signed %d times but is not in the keystores", label, u.Signed))
+	}
+	if u.Refused > 0 {
+		found = append(found, fmt.Sprintf("%s had %d requests refused", label, u.Refused))
+	}
+
+	days := make([]string, 0, len(u.Days))
+	for day := range u.Days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	if len(days) < 2 {
+		return found
+	}
+	for _, day := range days {
+		n := u.Days[day]
+		mean := float64(u.Signed-n) / float64(len(days)-1)
+		if n >= burstMin && float64(n) > burstFactor*mean {
+			found = append(found, fmt.Sprintf("%s signed %d times on %s, against %.1f a day otherwise", label, n, day, mean))
+		}
+	}
+	return found
+}
+
+// Sign signs the report as an envelope
+func Sign(privatekey *big.Int, report *Report) (*envelope.Envelope, error) {
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Sign(privatekey, PayloadType, payload)
+}
+
+// Open verifies a signed report against the public key of its signer and
+// returns it
+func Open(e *envelope.Envelope, publickey [33]byte) (*Report, error) {
+	if e.PayloadType != PayloadType {
+		return nil, fmt.Errorf("envelope holds %s, not an attestation report", e.PayloadType)
+	}
+	if err := envelope.Verify(e, publickey); err != nil {
+		return nil, err
+	}
+	report := new(Report)
+	if err := json.Unmarshal(e.Payload, report); err != nil {
+		return nil, fmt.Errorf("report is not json: %v", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,86 @@
+package attest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestReport(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("attest"), 3)
+	known := fmt.Sprintf("%x", keys[0].PublicKey)
+	unknown := fmt.Sprintf("%x", keys[1].PublicKey)
+	idle := fmt.Sprintf("%x", keys[2].PublicKey)
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// given a log with a quiet key that's busy one day, a refusal, a key
+	// the keystores don't have, -watch's entries and one out of the period
+	var log strings.Builder
+	line := func(t time.Time, outcome, publickey, client, digest string) {
+		fmt.Fprintf(&log, `{"time":%q,"outcome":%q,"key":"release:v1","publicKey":%q,"client":%q,"digest":%q}`+"\n", t.Format(time.RFC3339), outcome, publickey, client, digest)
+	}
+	for day := 0; day < 5; day++ {
+		line(from.Add(time.Duration(day)*24*time.Hour), "signed", known, "ci", fmt.Sprintf("%064x", day))
+	}
+	for i := 0; i < 20; i++ {
+		line(from.Add(6*24*time.Hour+time.Duration(i)*time.Minute), "signed", known, "laptop", fmt.Sprintf("%064x", 100+i))
+	}
+	fmt.Fprintf(&log, `{"time":%q,"outcome":"refused","reason":"over the limit","key":"release:v1","client":"laptop"}`+"\n", from.Add(7*24*time.Hour).Format(time.RFC3339))
+	fmt.Fprintf(&log, `{"time":%q,"path":"dist/app.tar","outcome":"signed","sha256":"%064x","publicKey":%q}`+"\n", from.Add(time.Hour).Format(time.RFC3339), 1, unknown)
+	fmt.Fprintf(&log, `{"time":%q,"path":"dist/notes.txt","outcome":"skipped","reason":"excluded"}`+"\n", from.Add(time.Hour).Format(time.RFC3339))
+	line(from.Add(-time.Hour), "signed", known, "ci", fmt.Sprintf("%064x", 999))
+
+	// when
+	b := NewBuilder(from, from.AddDate(0, 1, 0))
+	b.Known(known, "release")
+	b.Known(idle, "spare")
+	if err := b.Read(strings.NewReader(log.String())); err != nil {
+		t.Fatalf("Unexpected error from Read: %v", err)
+	}
+	report := b.Report(from.AddDate(0, 1, 0))
+
+	// then
+	usage := map[string]*KeyUsage{}
+	for _, u := range report.Keys {
+		usage[u.PublicKey] = u
+	}
+	if u := usage[known]; u == nil || u.Signed != 25 || u.Refused != 1 || u.Clients["ci"] != 5 || u.Clients["laptop"] != 20 || u.Name != "release" || u.DigestsSHA256 == "" {
+		t.Fatalf("usage of the known key = %+v, want 25 signed by ci and laptop and 1 refused", u)
+	}
+	if u := usage[unknown]; u == nil || u.Known || u.Signed != 1 || u.Clients["watch"] != 1 {
+		t.Fatalf("usage of the unknown key = %+v, want 1 signed by watch", u)
+	}
+	if u := usage[idle]; u == nil || !u.Known || u.Signed != 0 {
+		t.Fatalf("usage of the idle key = %+v, want it listed unused", u)
+	}
+
+	findings := strings.Join(report.Findings, "\n")
+	for _, want := range []string{"not in the keystores", "1 requests refused", "20 times on 2026-03-07"} {
+		if !strings.Contains(findings, want) {
+			t.Fatalf("Findings = %v, want one with %q", report.Findings, want)
+		}
+	}
+
+	t.Run("Signed reports open under the signer's key", func(t *testing.T) {
+		e, err := Sign(keys[2].PrivateKey, report)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		opened, err := Open(e, keys[2].PublicKey)
+		if err != nil || len(opened.Keys) != 3 {
+			t.Fatalf("Open() = %v, %v, want the report", opened, err)
+		}
+		if _, err := Open(e, keys[0].PublicKey); err == nil {
+			t.Fatalf("Open() under another key succeeded, want an error")
+		}
+	})
+
+	t.Run("A log with lines that aren't entries is refused", func(t *testing.T) {
+		if err := NewBuilder(from, from).Read(strings.NewReader("{}\nnot json\n")); err == nil {
+			t.Fatalf("Read() succeeded, want an error")
+		}
+	})
+}
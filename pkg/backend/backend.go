@@ -0,0 +1,14 @@
+package backend
+
+import "context"
+
+// Backend is somewhere keys are held outside of this process. It only hands
+// out public keys and signatures, the private keys never leave it.
+type Backend interface {
+	// PublicKey returns the compressed public key of the named key
+	PublicKey(ctx context.Context, name string) ([33]byte, error)
+
+	// Sign signs the 32 byte message with the named key, producing the same
+	// signatures as schnorr.Sign
+	Sign(ctx context.Context, name string, message [32]byte) ([64]byte, error)
+}
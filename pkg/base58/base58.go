@@ -0,0 +1,85 @@
+package base58
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+//
+// Bitcoin's base58, and base58check with its double sha256 checksum, as
+// WIF keys and BIP-32 extended keys are written.
+//
+
+const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Encode writes data in base58, each leading zero byte as a 1
+func Encode(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	out := []byte{}
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, '1')
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// Decode reads a base58 string
+func Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		i := strings.IndexRune(alphabet, c)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+func checksum(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:4]
+}
+
+// CheckEncode appends the double sha256 checksum and encodes
+func CheckEncode(data []byte) string {
+	return Encode(append(append([]byte{}, data...), checksum(data)...))
+}
+
+// CheckDecode decodes and strips a valid checksum
+func CheckDecode(s string) ([]byte, error) {
+	raw, err := Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 4 {
+		return nil, fmt.Errorf("base58check string is too short")
+	}
+	data, sum := raw[:len(raw)-4], raw[len(raw)-4:]
+	if string(checksum(data)) != string(sum) {
+		return nil, fmt.Errorf("base58check checksum mismatch")
+	}
+	return data, nil
+}
@@ -0,0 +1,162 @@
+package bech32
+
+import (
+	"fmt"
+	"strings"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0173.mediawiki
+//
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	ret := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		ret = append(ret, hrp[i]>>5)
+	}
+	ret = append(ret, 0)
+	for i := 0; i < len(hrp); i++ {
+		ret = append(ret, hrp[i]&31)
+	}
+	return ret
+}
+
+func createChecksum(hrp string, data []byte) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := polymod(values) ^ 1
+
+	ret := make([]byte, 6)
+	for i := 0; i < 6; i++ {
+		ret[i] = byte((mod >> uint(5*(5-i))) & 31)
+	}
+	return ret
+}
+
+// Encode encodes the 5 bit groups in data with the human readable part
+func Encode(hrp string, data []byte) (string, error) {
+	if len(hrp) == 0 {
+		return "", fmt.Errorf("human readable part is empty")
+	}
+
+	hrp = strings.ToLower(hrp)
+	combined := append(append([]byte{}, data...), createChecksum(hrp, data)...)
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, b := range combined {
+		if int(b) >= len(charset) {
+			return "", fmt.Errorf("data value %d is not a 5 bit group", b)
+		}
+		sb.WriteByte(charset[b])
+	}
+	return sb.String(), nil
+}
+
+// Decode splits a bech32 string into its human readable part and the 5 bit
+// groups of the data. There is no length limit since LNURLs and nostr
+// entities are longer than the 90 characters BIP-173 allows.
+func Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, fmt.Errorf("bech32 string has mixed case")
+	}
+	s = strings.ToLower(s)
+
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, fmt.Errorf("bech32 separator is missing or misplaced")
+	}
+
+	hrp := s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("invalid character in human readable part")
+		}
+	}
+
+	data := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		d := strings.IndexByte(charset, s[i])
+		if d < 0 {
+			return "", nil, fmt.Errorf("invalid bech32 character %q", s[i])
+		}
+		data = append(data, byte(d))
+	}
+
+	if polymod(append(hrpExpand(hrp), data...)) != 1 {
+		return "", nil, fmt.Errorf("invalid bech32 checksum")
+	}
+
+	return hrp, data[:len(data)-6], nil
+}
+
+// ConvertBits regroups data from groups of fromBits to groups of toBits
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc, bits := uint32(0), uint(0)
+	maxv := uint32(1)<<toBits - 1
+	ret := []byte{}
+
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data range %d", v)
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			ret = append(ret, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			ret = append(ret, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+
+	return ret, nil
+}
+
+// EncodeBytes converts the bytes to 5 bit groups and encodes them
+func EncodeBytes(hrp string, data []byte) (string, error) {
+	converted, err := ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return Encode(hrp, converted)
+}
+
+// DecodeBytes decodes the string and converts the data back to bytes
+func DecodeBytes(s string) (string, []byte, error) {
+	hrp, data, err := Decode(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	converted, err := ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, converted, nil
+}
@@ -0,0 +1,73 @@
+package bech32
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodeValid(t *testing.T) {
+	// valid checksums from BIP-173
+	valid := []string{
+		"A12UEL5L",
+		"a12uel5l",
+		"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	}
+
+	for _, s := range valid {
+		hrp, data, err := Decode(s)
+		if err != nil {
+			t.Fatalf("Unexpected error from Decode(%s): %v", s, err)
+		}
+
+		observed, err := Encode(hrp, data)
+		if err != nil {
+			t.Fatalf("Unexpected error from Encode(%s, %x): %v", hrp, data, err)
+		}
+
+		// then
+		if observed != strings.ToLower(s) {
+			t.Fatalf("Encode(Decode(%s)) = %s", s, observed)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	invalid := []string{
+		"pzry9x0s0muk",
+		"1pzry9x0s0muk",
+		"x1b4n0q5v",
+		"li1dgmt3",
+		"A1G7SGD8",
+		"10a06t8",
+		"1qzzfhee",
+		"a12UEL5L",
+	}
+
+	for _, s := range invalid {
+		if _, _, err := Decode(s); err == nil {
+			t.Fatalf("Decode(%s) succeeded, want error", s)
+		}
+	}
+}
+
+func TestBytesRoundTrip(t *testing.T) {
+	data := []byte("https://service.com/api?q=3fc3645b439ce8e7f2553a69e5267081d96dcd340693afabe04be7b0ccd178df")
+
+	encoded, err := EncodeBytes("lnurl", data)
+	if err != nil {
+		t.Fatalf("Unexpected error from EncodeBytes: %v", err)
+	}
+
+	hrp, decoded, err := DecodeBytes(strings.ToUpper(encoded))
+	if err != nil {
+		t.Fatalf("Unexpected error from DecodeBytes(%s): %v", encoded, err)
+	}
+
+	// then
+	if hrp != "lnurl" || !bytes.Equal(decoded, data) {
+		t.Fatalf("DecodeBytes(%s) = %s, %s, want lnurl, %s", encoded, hrp, decoded, data)
+	}
+}
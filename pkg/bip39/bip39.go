@@ -0,0 +1,160 @@
+package bip39
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/hdkey"
+	"github.com/ryohare/schnorr-go/pkg/kdf"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+//
+// Mnemonic phrases for keys people back up on paper. The phrase encodes
+// 128 to 256 bits of entropy and a checksum in words from the English
+// list; the seed is PBKDF2 over the phrase and an optional passphrase,
+// and the signing key is the BIP-32 master key of the seed, so a phrase
+// restores here to the same key as in any BIP-32 wallet's m, or to the key
+// at a derivation path with DerivedKey.
+//
+// BIP-39 normalizes phrase and passphrase to NFKD. The English words need
+// none and there's no Unicode normalization in the standard library, so
+// passphrases must be ASCII.
+//
+
+//go:embed english.txt
+var english string
+
+// Words is the English word list, in order
+var Words = strings.Split(strings.TrimSpace(english), "\n")
+
+var wordIndex = func() map[string]int {
+	m := make(map[string]int, len(Words))
+	for i, w := range Words {
+		m[w] = i
+	}
+	return m
+}()
+
+// Generate returns a new phrase of 12, 15, 18, 21 or 24 words with entropy
+// from random
+func Generate(random io.Reader, words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", fmt.Errorf("a phrase is 12, 15, 18, 21 or 24 words, not %d", words)
+	}
+	entropy := make([]byte, words/3*4)
+	if _, err := io.ReadFull(random, entropy); err != nil {
+		return "", err
+	}
+	return NewMnemonic(entropy)
+}
+
+// NewMnemonic encodes 16 to 32 bytes of entropy, a multiple of 4, as a
+// phrase
+func NewMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("entropy must be 16 to 32 bytes in steps of 4, got %d", len(entropy))
+	}
+
+	// the checksum is the first bits of the sha256, one per 32 bits
+	checksumBits := uint(len(entropy) / 4)
+	sum := sha256.Sum256(entropy)
+	n := new(big.Int).SetBytes(entropy)
+	n.Lsh(n, checksumBits)
+	n.Or(n, big.NewInt(int64(sum[0]>>(8-checksumBits))))
+
+	words := make([]string, (len(entropy)*8+int(checksumBits))/11)
+	mask := big.NewInt(2047)
+	index := new(big.Int)
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = Words[index.And(n, mask).Int64()]
+		n.Rsh(n, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// Entropy checks the phrase's words and checksum and returns the entropy
+// it encodes
+func Entropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("a phrase is 12, 15, 18, 21 or 24 words, got %d", len(words))
+	}
+
+	n := new(big.Int)
+	for i, w := range words {
+		index, ok := wordIndex[strings.ToLower(w)]
+		if !ok {
+			return nil, fmt.Errorf("word %d, %q, is not in the word list", i+1, w)
+		}
+		n.Lsh(n, 11)
+		n.Or(n, big.NewInt(int64(index)))
+	}
+
+	checksumBits := uint(len(words) / 3)
+	checksum := byte(new(big.Int).And(n, big.NewInt(1<<checksumBits-1)).Int64())
+	n.Rsh(n, checksumBits)
+	entropy := make([]byte, len(words)/3*4)
+	n.FillBytes(entropy)
+
+	sum := sha256.Sum256(entropy)
+	if sum[0]>>(8-checksumBits) != checksum {
+		return nil, fmt.Errorf("the phrase's checksum is wrong, check the words and their order")
+	}
+	return entropy, nil
+}
+
+// Seed checks the phrase and derives its 64 byte seed with the passphrase,
+// which may be empty
+func Seed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := Entropy(mnemonic); err != nil {
+		return nil, err
+	}
+	for _, r := range passphrase {
+		if r > 0x7f {
+			return nil, fmt.Errorf("passphrase must be ascii, as it isn't normalized")
+		}
+	}
+	phrase := strings.ToLower(strings.Join(strings.Fields(mnemonic), " "))
+	return kdf.PBKDF2(sha512.New, []byte(phrase), []byte("mnemonic"+passphrase), 2048, 64), nil
+}
+
+// MasterKey returns the BIP-32 master private key and chain code of the
+// seed
+func MasterKey(seed []byte) (*big.Int, [32]byte, error) {
+	k, err := hdkey.NewMaster(seed)
+	if err != nil {
+		return nil, [32]byte{}, err
+	}
+	return k.PrivateKey(), k.ChainCode, nil
+}
+
+// PrivateKey is the signing key of the phrase and passphrase, the master
+// key of its seed
+func PrivateKey(mnemonic, passphrase string) (*big.Int, error) {
+	return DerivedKey(mnemonic, passphrase, "m")
+}
+
+// DerivedKey is the key at the BIP-32 derivation path, such as
+// m/86'/0'/0'/0/0, from the master key of the phrase's seed
+func DerivedKey(mnemonic, passphrase, path string) (*big.Int, error) {
+	seed, err := Seed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	master, err := hdkey.NewMaster(seed)
+	if err != nil {
+		return nil, err
+	}
+	k, err := master.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.PrivateKey(), nil
+}
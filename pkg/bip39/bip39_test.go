@@ -0,0 +1,129 @@
+package bip39
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// from the test vectors of the reference implementation, all with the
+// passphrase TREZOR
+var vectors = []struct {
+	entropy  string
+	mnemonic string
+	seed     string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		"808080808080808080808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter always",
+		"107d7c02a5aa6f38c58083ff74f04c607c2d2c0ecc55501dadd72d025b751bc27fe913ffb796f841c49b1d33b610cf0e91d3aa239027f5e99fe4ce9e5088cd65",
+	},
+	{
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		"dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+	},
+	{
+		"3e141609b97933b66a060dcddc71fad1d91677db872031e85f4c015c5e7e8982",
+		"dignity pass list indicate nasty swamp pool script soccer toe leaf photo multiply desk host tomato cradle drill spread actor shine dismiss champion exotic",
+		"ff7f3184df8696d8bef94b6c03114dbee0ef89ff938712301d27ed8336ca89ef9635da20af07d4175f2bf5f3de130f39c9d9e8dd0472489c19b1a020a940da67",
+	},
+	{
+		"18ab19a9f54a9274f03e5209a2ac8a91",
+		"board flee heavy tunnel powder denial science ski answer betray cargo cat",
+		"6eff1bb21562918509c73cb990260db07c0ce34ff0e3cc4a8cb3276129fbcb300bddfe005831350efd633909f476c45c88253276d9fd0df6ef48609e8bb7dca8",
+	},
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.mnemonic, func(t *testing.T) {
+			entropy, _ := hex.DecodeString(v.entropy)
+
+			// when
+			mnemonic, err := NewMnemonic(entropy)
+			if err != nil {
+				t.Fatalf("Unexpected error from NewMnemonic: %v", err)
+			}
+			back, err := Entropy(mnemonic)
+			if err != nil {
+				t.Fatalf("Unexpected error from Entropy: %v", err)
+			}
+			seed, err := Seed(mnemonic, "TREZOR")
+			if err != nil {
+				t.Fatalf("Unexpected error from Seed: %v", err)
+			}
+
+			// then
+			if mnemonic != v.mnemonic {
+				t.Fatalf("NewMnemonic(%s) = %s, want %s", v.entropy, mnemonic, v.mnemonic)
+			}
+			if hex.EncodeToString(back) != v.entropy {
+				t.Fatalf("Entropy() = %x, want %s", back, v.entropy)
+			}
+			if hex.EncodeToString(seed) != v.seed {
+				t.Fatalf("Seed() = %x, want %s", seed, v.seed)
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, mnemonic := range []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow yellow",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice caged above",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo why",
+	} {
+		if _, err := Seed(mnemonic, ""); err == nil {
+			t.Fatalf("Seed(%s) succeeded, want error", mnemonic)
+		}
+	}
+	if _, err := Seed(vectors[0].mnemonic, "pässword"); err == nil {
+		t.Fatalf("Seed() with a non-ascii passphrase succeeded, want error")
+	}
+}
+
+func TestMasterKey(t *testing.T) {
+	// BIP-32 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	// when
+	d, chainCode, err := MasterKey(seed)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from MasterKey: %v", err)
+	}
+	if want := "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"; d.Text(16) != want {
+		t.Fatalf("MasterKey() key = %x, want %s", d, want)
+	}
+	if want := "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508"; hex.EncodeToString(chainCode[:]) != want {
+		t.Fatalf("MasterKey() chain code = %x, want %s", chainCode, want)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, words := range []int{12, 24} {
+		mnemonic, err := Generate(rand.Reader, words)
+		if err != nil {
+			t.Fatalf("Unexpected error from Generate: %v", err)
+		}
+		if _, err := PrivateKey(mnemonic, "passphrase"); err != nil {
+			t.Fatalf("Unexpected error from PrivateKey(%s): %v", mnemonic, err)
+		}
+	}
+	if _, err := Generate(rand.Reader, 13); err == nil {
+		t.Fatalf("Generate(13) succeeded, want error")
+	}
+}
@@ -0,0 +1,302 @@
+package btctx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/descriptor"
+)
+
+func testTx(t *testing.T) (*Tx, []*TxOut, *descriptor.Descriptor, *btcec.PrivateKey) {
+	key, _ := btcec.NewPrivateKey()
+	d, err := descriptor.Parse("tr(" + hex.EncodeToString(schnorr.SerializePubKey(key.PubKey())) + ")")
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	script, err := d.ScriptPubKey()
+	if err != nil {
+		t.Fatalf("Unexpected error from ScriptPubKey: %v", err)
+	}
+
+	tx := &Tx{Version: 2}
+	prevouts := []*TxOut{}
+	for i := 0; i < 2; i++ {
+		tx.Inputs = append(tx.Inputs, &TxIn{PrevOut: OutPoint{Hash: [32]byte{byte(i + 1)}, Index: uint32(i)}, Sequence: 0xfffffffd})
+		prevouts = append(prevouts, &TxOut{Value: 50000, PkScript: script})
+	}
+	tx.Outputs = append(tx.Outputs, &TxOut{Value: 90000, PkScript: script})
+	return tx, prevouts, d, key
+}
+
+func TestParseTx(t *testing.T) {
+	// given
+	tx, _, _, _ := testTx(t)
+	tx.Inputs[0].Witness = [][]byte{bytes.Repeat([]byte{1}, 64)}
+
+	// when
+	observed, err := ParseTx(tx.Serialize())
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseTx: %v", err)
+	}
+
+	// then
+	if !bytes.Equal(observed.Serialize(), tx.Serialize()) {
+		t.Fatalf("ParseTx(Serialize()) = %x, want %x", observed.Serialize(), tx.Serialize())
+	}
+	if observed.TxID() != tx.TxID() {
+		t.Fatalf("TxID() changed by the witness round trip")
+	}
+
+	if _, err := ParseTx(tx.Serialize()[:20]); err == nil {
+		t.Fatalf("ParseTx of a truncated transaction succeeded, want error")
+	}
+}
+
+func TestTaprootSigHash(t *testing.T) {
+	tx, prevouts, _, _ := testTx(t)
+
+	sighash := func(tx *Tx, index int, hashType SigHashType) [32]byte {
+		h, err := TaprootSigHash(tx, prevouts, index, hashType, nil, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error from TaprootSigHash: %v", err)
+		}
+		return h
+	}
+
+	t.Run("ALL commits to the outputs, NONE does not", func(t *testing.T) {
+		changed, _, _, _ := testTx(t)
+		changed.Outputs[0].Value--
+		changed.Inputs = tx.Inputs
+
+		if sighash(tx, 0, SigHashAll) == sighash(changed, 0, SigHashAll) {
+			t.Fatalf("SigHashAll did not change with the outputs")
+		}
+		if sighash(tx, 0, SigHashNone) != sighash(changed, 0, SigHashNone) {
+			t.Fatalf("SigHashNone changed with the outputs")
+		}
+		if sighash(tx, 0, SigHashDefault) == sighash(tx, 0, SigHashAll) {
+			t.Fatalf("SigHashDefault and SigHashAll hash the same, the hash type must be committed")
+		}
+	})
+
+	t.Run("ANYONECANPAY ignores the other inputs", func(t *testing.T) {
+		other := *tx
+		other.Inputs = []*TxIn{tx.Inputs[0], {PrevOut: OutPoint{Index: 7}}}
+
+		if sighash(tx, 0, SigHashAll|SigHashAnyoneCanPay) != sighash(&other, 0, SigHashAll|SigHashAnyoneCanPay) {
+			t.Fatalf("SigHashAnyoneCanPay changed with another input")
+		}
+		if sighash(tx, 0, SigHashAll) == sighash(&other, 0, SigHashAll) {
+			t.Fatalf("SigHashAll did not change with another input")
+		}
+	})
+
+	t.Run("SINGLE needs a matching output", func(t *testing.T) {
+		if _, err := TaprootSigHash(tx, prevouts, 1, SigHashSingle, nil, nil); err == nil {
+			t.Fatalf("SigHashSingle without a matching output succeeded, want error")
+		}
+		if _, err := TaprootSigHash(tx, prevouts, 0, 0x04, nil, nil); err == nil {
+			t.Fatalf("TaprootSigHash with hash type 4 succeeded, want error")
+		}
+	})
+}
+
+// bip341KeyPathTx is the unsigned transaction of the keyPathSpending case
+// of the BIP-341 wallet test vectors, spending bip341Prevouts
+const bip341KeyPathTx = "02000000097de20cbff686da83a54981d2b9bab3586f4ca7e48f57f5b55963115f3b334e9c010000000000000000d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd990000000000fffffffff8e1f583384333689228c5d28eac13366be082dc57441760d957275419a418420000000000fffffffff0689180aa63b30cb162a73c6d2a38b7eeda2a83ece74310fda0843ad604853b0100000000feffffffaa5202bdf6d8ccd2ee0f0202afbbb7461d9264a25e5bfd3c5a52ee1239e0ba6c0000000000feffffff956149bdc66faa968eb2be2d2faa29718acbfe3941215893a2a3446d32acd050000000000000000000e664b9773b88c09c32cb70a2a3e4da0ced63b7ba3b22f848531bbb1d5d5f4c94010000000000000000e9aa6b8e6c9de67619e6a3924ae25696bb7b694bb677a632a74ef7eadfd4eabf0000000000ffffffffa778eb6a263dc090464cd125c466b5a99667720b1c110468831d058aa1b82af10100000000ffffffff0200ca9a3b000000001976a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac807840cb0000000020ac9a87f5594be208f8532db38cff670c450ed2fea8fcdefcc9a663f78bab962b0065cd1d"
+
+var bip341Prevouts = []struct {
+	script string
+	value  int64
+}{
+	{"512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343", 420000000},
+	{"5120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3", 462000000},
+	{"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", 294000000},
+	{"5120e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e", 504000000},
+	{"512091b64d5324723a985170e4dc5a0f84c041804f2cd12660fa5dec09fc21783605", 630000000},
+	{"00147dd65592d0ab2fe0d0257d571abf032cd9db93dc", 378000000},
+	{"512075169f4001aa68f15bbed28b218df1d0a62cbbcf1188c6665110c293c907b831", 672000000},
+	{"5120712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5", 546000000},
+	{"512077e30a5522dd9f894c3f8b8bd4c4b2cf82ca7da8a3ea6a239655c39c050ab220", 588000000},
+}
+
+func TestTaprootSigHashVectors(t *testing.T) {
+	// given the BIP-341 key path spending vectors
+	raw, _ := hex.DecodeString(bip341KeyPathTx)
+	tx, err := ParseTx(raw)
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseTx: %v", err)
+	}
+	prevouts := []*TxOut{}
+	for _, p := range bip341Prevouts {
+		script, _ := hex.DecodeString(p.script)
+		prevouts = append(prevouts, &TxOut{Value: p.value, PkScript: script})
+	}
+
+	for _, tt := range []struct {
+		index    int
+		hashType SigHashType
+		want     string
+	}{
+		{0, SigHashSingle, "2514a6272f85cfa0f45eb907fcb0d121b808ed37c6ea160a5a9046ed5526d555"},
+		{1, SigHashSingle | SigHashAnyoneCanPay, "325a644af47e8a5a2591cda0ab0723978537318f10e6a63d4eed783b96a71a4d"},
+		{3, SigHashAll, "bf013ea93474aa67815b1b6cc441d23b64fa310911d991e713cd34c7f5d46669"},
+		{4, SigHashDefault, "4f900a0bae3f1446fd48490c2958b5a023228f01661cda3496a11da502a7f7ef"},
+		{6, SigHashNone, "15f25c298eb5cdc7eb1d638dd2d45c97c4c59dcaec6679cfc16ad84f30876b85"},
+		{7, SigHashNone | SigHashAnyoneCanPay, "cd292de50313804dabe4685e83f923d2969577191a3e1d2882220dca88cbeb10"},
+		{8, SigHashAll | SigHashAnyoneCanPay, "cccb739eca6c13a8a89e6e5cd317ffe55669bbda23f2fd37b0f18755e008edd2"},
+	} {
+		// when
+		sighash, err := TaprootSigHash(tx, prevouts, tt.index, tt.hashType, nil, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error from TaprootSigHash: %v", err)
+		}
+
+		// then
+		if got := hex.EncodeToString(sighash[:]); got != tt.want {
+			t.Fatalf("TaprootSigHash(input %d, 0x%02x) = %s, want %s", tt.index, byte(tt.hashType), got, tt.want)
+		}
+	}
+}
+
+func TestSignInput(t *testing.T) {
+	for _, hashType := range []SigHashType{SigHashDefault, SigHashAll, SigHashSingle | SigHashAnyoneCanPay} {
+		// given
+		tx, prevouts, d, key := testTx(t)
+
+		// when
+		_, err := SignInput(tx, prevouts, 0, d, []*btcec.PrivateKey{key}, hashType)
+		if err != nil {
+			t.Fatalf("Unexpected error from SignInput: %v", err)
+		}
+
+		// then
+		witness := tx.Inputs[0].Witness
+		if len(witness) != 1 {
+			t.Fatalf("Witness = %x, want a single signature", witness)
+		}
+		sigBytes := witness[0]
+		if hashType != SigHashDefault {
+			if len(sigBytes) != 65 || SigHashType(sigBytes[64]) != hashType {
+				t.Fatalf("signature %x does not end in hash type %x", sigBytes, hashType)
+			}
+			sigBytes = sigBytes[:64]
+		} else if len(sigBytes) != 64 {
+			t.Fatalf("signature %x has a hash type byte with SigHashDefault", sigBytes)
+		}
+
+		msg, _ := TaprootSigHash(tx, prevouts, 0, hashType, nil, nil)
+		q, _ := d.OutputKey()
+		sig, err := schnorr.ParseSignature(sigBytes)
+		if err != nil {
+			t.Fatalf("Unexpected error from ParseSignature: %v", err)
+		}
+		if !sig.Verify(msg[:], q) {
+			t.Fatalf("signature with hash type %x does not verify against the output key", hashType)
+		}
+	}
+
+	t.Run("Wrong prevout", func(t *testing.T) {
+		tx, prevouts, d, key := testTx(t)
+		prevouts[0] = &TxOut{Value: 50000, PkScript: []byte{0x51}}
+		if _, err := SignInput(tx, prevouts, 0, d, []*btcec.PrivateKey{key}, SigHashDefault); err == nil {
+			t.Fatalf("SignInput of an input the descriptor does not describe succeeded, want error")
+		}
+	})
+}
+
+// testPSBT wraps the transaction in a PSBT with the utxo of every input
+func testPSBT(tx *Tx, prevouts []*TxOut) *PSBT {
+	p := &PSBT{Tx: tx, Global: PSBTMap{{Key: []byte{psbtGlobalUnsignedTx}, Value: tx.serialize(false)}}}
+	for _, out := range prevouts {
+		p.Inputs = append(p.Inputs, PSBTMap{{Key: []byte{psbtInWitnessUTXO}, Value: out.append(nil)}})
+	}
+	for range tx.Outputs {
+		p.Outputs = append(p.Outputs, PSBTMap{})
+	}
+	return p
+}
+
+func TestSignPSBT(t *testing.T) {
+	// given a PSBT spending two key path outputs of the key, read back
+	// from its serialization
+	tx, prevouts, d, key := testTx(t)
+	p, err := ParsePSBT(testPSBT(tx, prevouts).Serialize())
+	if err != nil {
+		t.Fatalf("Unexpected error from ParsePSBT: %v", err)
+	}
+	p.Inputs[1].Set([]byte{psbtInSigHashType}, []byte{byte(SigHashAll), 0, 0, 0})
+
+	// when
+	signed, err := SignPSBT(p, key)
+	if err != nil {
+		t.Fatalf("Unexpected error from SignPSBT: %v", err)
+	}
+
+	// then
+	if len(signed) != 2 {
+		t.Fatalf("SignPSBT() signed %d inputs, want 2", len(signed))
+	}
+	observed, err := ParsePSBT(p.Serialize())
+	if err != nil {
+		t.Fatalf("Unexpected error from ParsePSBT: %v", err)
+	}
+	q, _ := d.OutputKey()
+	for i, hashType := range []SigHashType{SigHashDefault, SigHashAll} {
+		sigBytes, ok := observed.Inputs[i].Get(psbtInTapKeySig)
+		if !ok {
+			t.Fatalf("input %d has no key path signature", i)
+		}
+		if hashType != SigHashDefault {
+			sigBytes = sigBytes[:64]
+		}
+		msg, _ := TaprootSigHash(tx, prevouts, i, hashType, nil, nil)
+		sig, err := schnorr.ParseSignature(sigBytes)
+		if err != nil || !sig.Verify(msg[:], q) {
+			t.Fatalf("input %d signature does not verify against the output key: %v", i, err)
+		}
+	}
+
+	t.Run("Script path", func(t *testing.T) {
+		other, _ := btcec.NewPrivateKey()
+		internal := hex.EncodeToString(schnorr.SerializePubKey(key.PubKey()))
+		desc, err := descriptor.Parse("tr(" + internal + ",pk(" + hex.EncodeToString(schnorr.SerializePubKey(other.PubKey())) + "))")
+		if err != nil {
+			t.Fatalf("Unexpected error from Parse: %v", err)
+		}
+		script, _ := desc.ScriptPubKey()
+		for _, out := range prevouts {
+			out.PkScript = script
+		}
+		leaf := desc.Leaves()[0]
+		controlBlock, _ := desc.ControlBlock(leaf)
+		p := testPSBT(tx, prevouts)
+		p.Inputs[0].Set(append([]byte{psbtInTapLeafScript}, controlBlock...), append(append([]byte{}, leaf.Script...), leaf.Version))
+
+		signed, err := SignPSBT(p, other)
+		if err != nil || len(signed) != 1 || signed[0].LeafHash == nil {
+			t.Fatalf("SignPSBT() = %v, %v, want a script path signature", signed, err)
+		}
+		leafHash := leaf.Hash()
+		sigs := p.Inputs[0].OfType(psbtInTapScriptSig)
+		if len(sigs) != 1 || !bytes.Equal(sigs[0].Key[33:], leafHash[:]) {
+			t.Fatalf("script signatures = %x, want one for the leaf", sigs)
+		}
+		msg, _ := TaprootSigHash(tx, prevouts, 0, SigHashDefault, leafHash[:], nil)
+		sig, err := schnorr.ParseSignature(sigs[0].Value)
+		if err != nil || !sig.Verify(msg[:], other.PubKey()) {
+			t.Fatalf("script path signature does not verify: %v", err)
+		}
+	})
+
+	t.Run("Inputs without their utxo", func(t *testing.T) {
+		p := testPSBT(tx, prevouts)
+		p.Inputs[1] = PSBTMap{}
+		if _, err := SignPSBT(p, key); err == nil {
+			t.Fatalf("SignPSBT() without every utxo succeeded, want an error")
+		}
+	})
+}
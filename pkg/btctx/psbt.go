@@ -0,0 +1,335 @@
+package btctx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcschnorr "github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/descriptor"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0174.mediawiki
+// https://github.com/bitcoin/bips/blob/master/bip-0371.mediawiki
+//
+// Partially signed transactions, version 0, as far as a taproot signer
+// needs: the unsigned transaction, the outputs its inputs spend, and the
+// BIP-371 taproot fields, to which signatures are added. Every other field
+// is kept as it was read, so a PSBT passes through with only the
+// signatures added.
+//
+
+// psbtMagic starts every PSBT
+var psbtMagic = []byte{'p', 's', 'b', 't', 0xff}
+
+// Key types of the fields used here
+const (
+	psbtGlobalUnsignedTx = 0x00
+	psbtInNonWitnessUTXO = 0x00
+	psbtInWitnessUTXO    = 0x01
+	psbtInSigHashType    = 0x03
+	psbtInFinalWitness   = 0x08
+	psbtInTapKeySig      = 0x13
+	psbtInTapScriptSig   = 0x14
+	psbtInTapLeafScript  = 0x15
+	psbtInTapInternalKey = 0x17
+	psbtInTapMerkleRoot  = 0x18
+)
+
+// psbtMaxFields caps the fields read into a map, as maxItems does counts
+const psbtMaxFields = 1 << 16
+
+// PSBTField is one key-value pair of a PSBT map. The first byte of the key
+// is its type.
+type PSBTField struct {
+	Key, Value []byte
+}
+
+// PSBTMap is the fields of the global map or of an input or output, in the
+// order read
+type PSBTMap []PSBTField
+
+// Get returns the value under the key
+func (m PSBTMap) Get(key ...byte) ([]byte, bool) {
+	for _, f := range m {
+		if bytes.Equal(f.Key, key) {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// OfType returns the fields whose key is of the type
+func (m PSBTMap) OfType(keyType byte) []PSBTField {
+	var fields []PSBTField
+	for _, f := range m {
+		if len(f.Key) > 0 && f.Key[0] == keyType {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// Set replaces the value under the key, or adds the field
+func (m *PSBTMap) Set(key, value []byte) {
+	for i, f := range *m {
+		if bytes.Equal(f.Key, key) {
+			(*m)[i].Value = value
+			return
+		}
+	}
+	*m = append(*m, PSBTField{Key: key, Value: value})
+}
+
+// PSBT is a parsed partially signed transaction
+type PSBT struct {
+	Tx      *Tx
+	Global  PSBTMap
+	Inputs  []PSBTMap
+	Outputs []PSBTMap
+}
+
+func readPSBTMap(r *bytes.Reader) (PSBTMap, error) {
+	var m PSBTMap
+	seen := map[string]bool{}
+	for {
+		key, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			return m, nil
+		}
+		if seen[string(key)] {
+			return nil, fmt.Errorf("duplicate key %x", key)
+		}
+		seen[string(key)] = true
+		value, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(m) >= psbtMaxFields {
+			return nil, fmt.Errorf("more than %d fields in a map", psbtMaxFields)
+		}
+		m = append(m, PSBTField{Key: key, Value: value})
+	}
+}
+
+func (m PSBTMap) append(b []byte) []byte {
+	for _, f := range m {
+		b = appendBytes(b, f.Key)
+		b = appendBytes(b, f.Value)
+	}
+	return append(b, 0)
+}
+
+// ParsePSBT decodes a PSBT in its binary serialization
+func ParsePSBT(raw []byte) (*PSBT, error) {
+	if !bytes.HasPrefix(raw, psbtMagic) {
+		return nil, fmt.Errorf("not a psbt, the magic bytes are missing")
+	}
+	r := bytes.NewReader(raw[len(psbtMagic):])
+	p := &PSBT{}
+
+	var err error
+	if p.Global, err = readPSBTMap(r); err != nil {
+		return nil, fmt.Errorf("global map: %v", err)
+	}
+	unsigned, ok := p.Global.Get(psbtGlobalUnsignedTx)
+	if !ok {
+		return nil, fmt.Errorf("psbt has no unsigned transaction, version 2 psbts are not supported")
+	}
+	if p.Tx, err = ParseTx(unsigned); err != nil {
+		return nil, fmt.Errorf("unsigned transaction: %v", err)
+	}
+	for i, in := range p.Tx.Inputs {
+		if len(in.ScriptSig) > 0 || len(in.Witness) > 0 {
+			return nil, fmt.Errorf("input %d of the unsigned transaction is signed", i)
+		}
+	}
+
+	for i := range p.Tx.Inputs {
+		m, err := readPSBTMap(r)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		p.Inputs = append(p.Inputs, m)
+	}
+	for i := range p.Tx.Outputs {
+		m, err := readPSBTMap(r)
+		if err != nil {
+			return nil, fmt.Errorf("output %d: %v", i, err)
+		}
+		p.Outputs = append(p.Outputs, m)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after the psbt", r.Len())
+	}
+	return p, nil
+}
+
+// Serialize encodes the PSBT
+func (p *PSBT) Serialize() []byte {
+	b := append([]byte{}, psbtMagic...)
+	b = p.Global.append(b)
+	for _, m := range p.Inputs {
+		b = m.append(b)
+	}
+	for _, m := range p.Outputs {
+		b = m.append(b)
+	}
+	return b
+}
+
+// Prevout returns the output input index spends, from its witness utxo or
+// else its full previous transaction
+func (p *PSBT) Prevout(index int) (*TxOut, error) {
+	m := p.Inputs[index]
+	if value, ok := m.Get(psbtInWitnessUTXO); ok {
+		r := bytes.NewReader(value)
+		var u64 [8]byte
+		if _, err := io.ReadFull(r, u64[:]); err != nil {
+			return nil, fmt.Errorf("input %d witness utxo: %v", index, err)
+		}
+		script, err := readBytes(r)
+		if err != nil || r.Len() != 0 {
+			return nil, fmt.Errorf("input %d witness utxo is malformed", index)
+		}
+		return &TxOut{Value: int64(binary.LittleEndian.Uint64(u64[:])), PkScript: script}, nil
+	}
+	if value, ok := m.Get(psbtInNonWitnessUTXO); ok {
+		prev, err := ParseTx(value)
+		if err != nil {
+			return nil, fmt.Errorf("input %d previous transaction: %v", index, err)
+		}
+		outpoint := p.Tx.Inputs[index].PrevOut
+		if prev.TxID() != outpoint.Hash || int(outpoint.Index) >= len(prev.Outputs) {
+			return nil, fmt.Errorf("input %d previous transaction is not the one it spends", index)
+		}
+		return prev.Outputs[outpoint.Index], nil
+	}
+	return nil, fmt.Errorf("input %d has no utxo", index)
+}
+
+// PSBTSignature is a signature SignPSBT added
+type PSBTSignature struct {
+	Input int
+	// LeafHash is nil for a key path signature
+	LeafHash []byte
+}
+
+// SignPSBT adds the key's signatures to every taproot input it can sign:
+// the key path when the key is the input's internal key, or the output
+// key itself, and the script path for each leaf script with the key in it.
+// Inputs that already have a final witness are left alone. Taproot
+// signatures commit to what every input spends, so each input must carry
+// its utxo.
+func SignPSBT(p *PSBT, key *btcec.PrivateKey) ([]PSBTSignature, error) {
+	prevouts := make([]*TxOut, len(p.Inputs))
+	for i := range p.Inputs {
+		out, err := p.Prevout(i)
+		if err != nil {
+			return nil, err
+		}
+		prevouts[i] = out
+	}
+	var xonly [32]byte
+	copy(xonly[:], btcschnorr.SerializePubKey(key.PubKey()))
+	d := schnorr.PrivateKeyFromBTCEC(key)
+
+	var signed []PSBTSignature
+	for i, m := range p.Inputs {
+		script := prevouts[i].PkScript
+		if len(script) != 34 || script[0] != 0x51 || script[1] != 0x20 {
+			continue
+		}
+		if _, final := m.Get(psbtInFinalWitness); final {
+			continue
+		}
+		hashType := SigHashDefault
+		if value, ok := m.Get(psbtInSigHashType); ok {
+			if len(value) != 4 {
+				return nil, fmt.Errorf("input %d sighash type is malformed", i)
+			}
+			hashType = SigHashType(binary.LittleEndian.Uint32(value))
+		}
+		withType := func(sig [64]byte) []byte {
+			if hashType == SigHashDefault {
+				return sig[:]
+			}
+			return append(sig[:], byte(hashType))
+		}
+
+		// key path, OP_1 <32 byte output key>
+		var outputKey [32]byte
+		copy(outputKey[:], script[2:])
+		tweaked, err := keyPathKey(m, d, xonly, outputKey)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		if tweaked != nil {
+			sighash, err := TaprootSigHash(p.Tx, prevouts, i, hashType, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: %v", i, err)
+			}
+			sig, err := schnorr.SignScheme(schnorr.SchemeBIP340, tweaked, sighash)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: %v", i, err)
+			}
+			p.Inputs[i].Set([]byte{psbtInTapKeySig}, withType(sig))
+			signed = append(signed, PSBTSignature{Input: i})
+		}
+
+		// script path, every leaf pushing the key
+		push := append([]byte{0x20}, xonly[:]...)
+		for _, f := range m.OfType(psbtInTapLeafScript) {
+			if len(f.Value) < 1 {
+				return nil, fmt.Errorf("input %d leaf script is malformed", i)
+			}
+			leafScript, version := f.Value[:len(f.Value)-1], f.Value[len(f.Value)-1]
+			if !bytes.Contains(leafScript, push) {
+				continue
+			}
+			leafHash := (&descriptor.Leaf{Version: version, Script: leafScript}).Hash()
+			sighash, err := TaprootSigHash(p.Tx, prevouts, i, hashType, leafHash[:], nil)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: %v", i, err)
+			}
+			sig, err := schnorr.SignScheme(schnorr.SchemeBIP340, d, sighash)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: %v", i, err)
+			}
+			key := append(append([]byte{psbtInTapScriptSig}, xonly[:]...), leafHash[:]...)
+			p.Inputs[i].Set(key, withType(sig))
+			signed = append(signed, PSBTSignature{Input: i, LeafHash: leafHash[:]})
+		}
+	}
+	return signed, nil
+}
+
+// keyPathKey returns the private key that signs for the output key, or nil
+// if the key can't. An output key that is the key itself is signed for
+// untweaked, otherwise the key must be the internal key and the output key
+// its tweak by the merkle root, if there is one.
+func keyPathKey(m PSBTMap, d *big.Int, xonly, outputKey [32]byte) (*big.Int, error) {
+	if xonly == outputKey {
+		return d, nil
+	}
+	if internal, ok := m.Get(psbtInTapInternalKey); ok && !bytes.Equal(internal, xonly[:]) {
+		return nil, nil
+	}
+	merkleRoot, _ := m.Get(psbtInTapMerkleRoot)
+	tweakedKey, _, err := schnorr.TweakPublicKey(xonly, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	if tweakedKey != outputKey {
+		return nil, nil
+	}
+	return schnorr.TweakPrivateKey(d, merkleRoot)
+}
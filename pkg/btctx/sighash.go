@@ -0,0 +1,150 @@
+package btctx
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0341.mediawiki#common-signature-message
+//
+
+// SigHashType says which parts of the transaction a signature commits to
+type SigHashType byte
+
+const (
+	// SigHashDefault commits to everything like SigHashAll, and leaves the
+	// hash type byte off the signature
+	SigHashDefault      SigHashType = 0x00
+	SigHashAll          SigHashType = 0x01
+	SigHashNone         SigHashType = 0x02
+	SigHashSingle       SigHashType = 0x03
+	SigHashAnyoneCanPay SigHashType = 0x80
+)
+
+// Valid is true for the hash types BIP-341 allows
+func (t SigHashType) Valid() bool {
+	switch t &^ SigHashAnyoneCanPay {
+	case SigHashAll, SigHashNone, SigHashSingle:
+		return true
+	case SigHashDefault:
+		return t == SigHashDefault
+	}
+	return false
+}
+
+// ParseSigHashType reads names like "all" or "single|anyonecanpay"
+func ParseSigHashType(s string) (SigHashType, error) {
+	names := map[string]SigHashType{
+		"default":             SigHashDefault,
+		"all":                 SigHashAll,
+		"none":                SigHashNone,
+		"single":              SigHashSingle,
+		"all|anyonecanpay":    SigHashAll | SigHashAnyoneCanPay,
+		"none|anyonecanpay":   SigHashNone | SigHashAnyoneCanPay,
+		"single|anyonecanpay": SigHashSingle | SigHashAnyoneCanPay,
+	}
+	t, ok := names[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown sighash type %q", s)
+	}
+	return t, nil
+}
+
+func sha256Of(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+// TaprootSigHash is the BIP-341 signature hash of input index. prevouts
+// are the outputs every input spends, in input order, since taproot
+// signatures commit to all their amounts and scripts. leafHash is nil for a
+// key path spend and the TapLeaf hash of the script for a script path one.
+func TaprootSigHash(tx *Tx, prevouts []*TxOut, index int, hashType SigHashType, leafHash []byte, annex []byte) ([32]byte, error) {
+	var sighash [32]byte
+
+	if !hashType.Valid() {
+		return sighash, fmt.Errorf("invalid sighash type 0x%02x", byte(hashType))
+	}
+	if index < 0 || index >= len(tx.Inputs) {
+		return sighash, fmt.Errorf("input %d out of range, there are %d", index, len(tx.Inputs))
+	}
+	if len(prevouts) != len(tx.Inputs) {
+		return sighash, fmt.Errorf("got %d prevouts for %d inputs", len(prevouts), len(tx.Inputs))
+	}
+	output := hashType & 3
+	anyoneCanPay := hashType&SigHashAnyoneCanPay != 0
+	if output == SigHashSingle && index >= len(tx.Outputs) {
+		return sighash, fmt.Errorf("sighash single for input %d without a matching output", index)
+	}
+	if leafHash != nil && len(leafHash) != 32 {
+		return sighash, fmt.Errorf("leaf hash must be 32 bytes")
+	}
+	if annex != nil && (len(annex) == 0 || annex[0] != 0x50) {
+		return sighash, fmt.Errorf("annex must start with 0x50")
+	}
+
+	// epoch 0
+	msg := []byte{0x00, byte(hashType)}
+	msg = appendUint32LE(msg, uint32(tx.Version))
+	msg = appendUint32LE(msg, tx.LockTime)
+
+	if !anyoneCanPay {
+		var outpoints, amounts, scripts, sequences []byte
+		for i, in := range tx.Inputs {
+			outpoints = append(outpoints, in.PrevOut.Hash[:]...)
+			outpoints = appendUint32LE(outpoints, in.PrevOut.Index)
+			amounts = appendUint64LE(amounts, uint64(prevouts[i].Value))
+			scripts = appendBytes(scripts, prevouts[i].PkScript)
+			sequences = appendUint32LE(sequences, in.Sequence)
+		}
+		msg = append(msg, sha256Of(outpoints)...)
+		msg = append(msg, sha256Of(amounts)...)
+		msg = append(msg, sha256Of(scripts)...)
+		msg = append(msg, sha256Of(sequences)...)
+	}
+
+	if output != SigHashNone && output != SigHashSingle {
+		var outputs []byte
+		for _, out := range tx.Outputs {
+			outputs = out.append(outputs)
+		}
+		msg = append(msg, sha256Of(outputs)...)
+	}
+
+	spendType := byte(0)
+	if leafHash != nil {
+		spendType |= 2
+	}
+	if annex != nil {
+		spendType |= 1
+	}
+	msg = append(msg, spendType)
+
+	if anyoneCanPay {
+		in := tx.Inputs[index]
+		msg = append(msg, in.PrevOut.Hash[:]...)
+		msg = appendUint32LE(msg, in.PrevOut.Index)
+		msg = prevouts[index].append(msg)
+		msg = appendUint32LE(msg, in.Sequence)
+	} else {
+		msg = appendUint32LE(msg, uint32(index))
+	}
+
+	if annex != nil {
+		msg = append(msg, sha256Of(appendBytes(nil, annex))...)
+	}
+	if output == SigHashSingle {
+		msg = append(msg, sha256Of(tx.Outputs[index].append(nil))...)
+	}
+
+	if leafHash != nil {
+		// key version 0 and no OP_CODESEPARATOR executed
+		msg = append(msg, leafHash...)
+		msg = append(msg, 0x00, 0xff, 0xff, 0xff, 0xff)
+	}
+
+	return *chainhash.TaggedHash([]byte("TapSighash"), msg), nil
+}
@@ -0,0 +1,68 @@
+package btctx
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ryohare/schnorr-go/pkg/descriptor"
+)
+
+// SignInput signs input index, which spends the output of the descriptor,
+// with whichever keys can, preferring the key path, and sets the input's
+// witness. Only the script path needs more than one signature, and all of
+// them must be among keys.
+func SignInput(tx *Tx, prevouts []*TxOut, index int, desc *descriptor.Descriptor, keys []*btcec.PrivateKey, hashType SigHashType) (*descriptor.SignResult, error) {
+	if index < 0 || index >= len(tx.Inputs) || len(prevouts) != len(tx.Inputs) {
+		return nil, fmt.Errorf("input %d out of range or prevouts missing", index)
+	}
+	script, err := desc.ScriptPubKey()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(prevouts[index].PkScript, script) {
+		return nil, fmt.Errorf("input %d spends %x, not the descriptor's output %x", index, prevouts[index].PkScript, script)
+	}
+
+	result, err := desc.Sign(keys, func(leaf *descriptor.Leaf) ([]byte, error) {
+		var leafHash []byte
+		if leaf != nil {
+			h := leaf.Hash()
+			leafHash = h[:]
+		}
+		sighash, err := TaprootSigHash(tx, prevouts, index, hashType, leafHash, nil)
+		return sighash[:], err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	withType := func(sig []byte) []byte {
+		if hashType == SigHashDefault {
+			return sig
+		}
+		return append(append([]byte{}, sig...), byte(hashType))
+	}
+
+	in := tx.Inputs[index]
+	if result.KeyPath {
+		for _, sig := range result.Signatures {
+			in.Witness = [][]byte{withType(sig)}
+		}
+		return result, nil
+	}
+
+	// the script checks the keys in order, so the first key's signature
+	// has to be on top of the stack, that is last in the witness
+	witness := [][]byte{}
+	for i := len(result.Leaf.Keys) - 1; i >= 0; i-- {
+		sig, ok := result.Signatures[result.Leaf.Keys[i].XOnly]
+		if !ok {
+			witness = append(witness, nil)
+			continue
+		}
+		witness = append(witness, withType(sig))
+	}
+	in.Witness = append(witness, result.Leaf.Script, result.ControlBlock)
+	return result, nil
+}
@@ -0,0 +1,262 @@
+package btctx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+//
+// Just enough of bitcoin transactions to sign taproot inputs: parsing and
+// serializing with and without witnesses, and the BIP-341 signature hash.
+//
+
+// OutPoint is the output an input spends. Hash is in internal byte order,
+// reversed from how txids are displayed.
+type OutPoint struct {
+	Hash  [32]byte
+	Index uint32
+}
+
+type TxIn struct {
+	PrevOut   OutPoint
+	ScriptSig []byte
+	Sequence  uint32
+	Witness   [][]byte
+}
+
+type TxOut struct {
+	Value    int64
+	PkScript []byte
+}
+
+type Tx struct {
+	Version  int32
+	Inputs   []*TxIn
+	Outputs  []*TxOut
+	LockTime uint32
+}
+
+// maxItems caps counts read from untrusted input so a bad length can't
+// make us allocate gigabytes
+const maxItems = 1 << 20
+
+func readCompactSize(r io.Reader) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return 0, err
+	}
+	switch b[0] {
+	case 0xfd:
+		if _, err := io.ReadFull(r, b[:2]); err != nil {
+			return 0, err
+		}
+		return uint64(binary.LittleEndian.Uint16(b[:2])), nil
+	case 0xfe:
+		if _, err := io.ReadFull(r, b[:4]); err != nil {
+			return 0, err
+		}
+		return uint64(binary.LittleEndian.Uint32(b[:4])), nil
+	case 0xff:
+		if _, err := io.ReadFull(r, b[:8]); err != nil {
+			return 0, err
+		}
+		return binary.LittleEndian.Uint64(b[:8]), nil
+	}
+	return uint64(b[0]), nil
+}
+
+func appendCompactSize(b []byte, n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return append(b, byte(n))
+	case n <= 0xffff:
+		return append(append(b, 0xfd), byte(n), byte(n>>8))
+	case n <= 0xffffffff:
+		return append(append(b, 0xfe), byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	b = append(b, 0xff)
+	return appendUint64LE(b, n)
+}
+
+func readBytes(r io.Reader) ([]byte, error) {
+	n, err := readCompactSize(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxItems*4 {
+		return nil, fmt.Errorf("item of %d bytes is too large", n)
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
+
+func appendBytes(b, data []byte) []byte {
+	return append(appendCompactSize(b, uint64(len(data))), data...)
+}
+
+// ParseTx decodes a transaction in the network serialization, with or
+// without witnesses
+func ParseTx(raw []byte) (*Tx, error) {
+	r := bytes.NewReader(raw)
+	tx := &Tx{}
+
+	var u32 [4]byte
+	if _, err := io.ReadFull(r, u32[:]); err != nil {
+		return nil, fmt.Errorf("reading version: %v", err)
+	}
+	tx.Version = int32(binary.LittleEndian.Uint32(u32[:]))
+
+	count, err := readCompactSize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// segwit marker and flag
+	segwit := false
+	if count == 0 {
+		flag, err := r.ReadByte()
+		if err != nil || flag != 1 {
+			return nil, fmt.Errorf("invalid segwit flag")
+		}
+		segwit = true
+		if count, err = readCompactSize(r); err != nil {
+			return nil, err
+		}
+	}
+	if count > maxItems {
+		return nil, fmt.Errorf("%d inputs is too many", count)
+	}
+
+	for i := uint64(0); i < count; i++ {
+		in := &TxIn{}
+		if _, err := io.ReadFull(r, in.PrevOut.Hash[:]); err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		if _, err := io.ReadFull(r, u32[:]); err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		in.PrevOut.Index = binary.LittleEndian.Uint32(u32[:])
+		if in.ScriptSig, err = readBytes(r); err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		if _, err := io.ReadFull(r, u32[:]); err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		in.Sequence = binary.LittleEndian.Uint32(u32[:])
+		tx.Inputs = append(tx.Inputs, in)
+	}
+
+	if count, err = readCompactSize(r); err != nil {
+		return nil, err
+	}
+	if count > maxItems {
+		return nil, fmt.Errorf("%d outputs is too many", count)
+	}
+	for i := uint64(0); i < count; i++ {
+		out := &TxOut{}
+		var u64 [8]byte
+		if _, err := io.ReadFull(r, u64[:]); err != nil {
+			return nil, fmt.Errorf("output %d: %v", i, err)
+		}
+		out.Value = int64(binary.LittleEndian.Uint64(u64[:]))
+		if out.PkScript, err = readBytes(r); err != nil {
+			return nil, fmt.Errorf("output %d: %v", i, err)
+		}
+		tx.Outputs = append(tx.Outputs, out)
+	}
+
+	if segwit {
+		for i, in := range tx.Inputs {
+			items, err := readCompactSize(r)
+			if err != nil || items > maxItems {
+				return nil, fmt.Errorf("input %d witness: invalid item count", i)
+			}
+			for j := uint64(0); j < items; j++ {
+				item, err := readBytes(r)
+				if err != nil {
+					return nil, fmt.Errorf("input %d witness: %v", i, err)
+				}
+				in.Witness = append(in.Witness, item)
+			}
+		}
+	}
+
+	if _, err := io.ReadFull(r, u32[:]); err != nil {
+		return nil, fmt.Errorf("reading lock time: %v", err)
+	}
+	tx.LockTime = binary.LittleEndian.Uint32(u32[:])
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after the transaction", r.Len())
+	}
+	return tx, nil
+}
+
+func (tx *Tx) hasWitness() bool {
+	for _, in := range tx.Inputs {
+		if len(in.Witness) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (tx *Tx) serialize(witness bool) []byte {
+	b := appendUint32LE(nil, uint32(tx.Version))
+	if witness {
+		b = append(b, 0, 1)
+	}
+
+	b = appendCompactSize(b, uint64(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		b = append(b, in.PrevOut.Hash[:]...)
+		b = appendUint32LE(b, in.PrevOut.Index)
+		b = appendBytes(b, in.ScriptSig)
+		b = appendUint32LE(b, in.Sequence)
+	}
+
+	b = appendCompactSize(b, uint64(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		b = out.append(b)
+	}
+
+	if witness {
+		for _, in := range tx.Inputs {
+			b = appendCompactSize(b, uint64(len(in.Witness)))
+			for _, item := range in.Witness {
+				b = appendBytes(b, item)
+			}
+		}
+	}
+
+	return appendUint32LE(b, tx.LockTime)
+}
+
+func (out *TxOut) append(b []byte) []byte {
+	b = appendUint64LE(b, uint64(out.Value))
+	return appendBytes(b, out.PkScript)
+}
+
+// Serialize encodes the transaction, with witnesses if any input has one
+func (tx *Tx) Serialize() []byte {
+	return tx.serialize(tx.hasWitness())
+}
+
+// TxID is the double sha256 of the transaction without witnesses, in
+// internal byte order
+func (tx *Tx) TxID() [32]byte {
+	first := sha256.Sum256(tx.serialize(false))
+	return sha256.Sum256(first[:])
+}
+
+func appendUint32LE(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64LE(b []byte, v uint64) []byte {
+	return appendUint32LE(appendUint32LE(b, uint32(v)), uint32(v>>32))
+}
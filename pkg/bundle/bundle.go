@@ -0,0 +1,260 @@
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/revocation"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// An offline verification bundle: a signature with everything needed to
+// check it on a machine with no network. Besides the digest, signature and
+// key it may carry the delegations from a trusted root down to the key, the
+// revocation certificates known when it was made, and a timestamp from a
+// timestamping key. Only the roots and timestamping keys come from the
+// verifier; everything in the bundle is checked against them.
+//
+
+// Version is the bundle format version
+const Version = 1
+
+// Bundle is one signature and its evidence. Binary fields are hex.
+type Bundle struct {
+	Version     int                  `json:"version"`
+	Digest      string               `json:"digest"`
+	Signature   string               `json:"signature"`
+	PublicKey   string               `json:"public_key"`
+	Delegations []*envelope.Envelope `json:"delegations,omitempty"`
+	// Revocations is the snapshot of revocation certificates taken at
+	// RevocationsAsOf
+	Revocations     []*envelope.Envelope `json:"revocations,omitempty"`
+	RevocationsAsOf *time.Time           `json:"revocations_as_of,omitempty"`
+	Timestamp       *envelope.Envelope   `json:"timestamp,omitempty"`
+}
+
+// New starts a bundle for the signature over digest
+func New(digest [32]byte, signature [64]byte, publickey [33]byte) *Bundle {
+	return &Bundle{
+		Version:   Version,
+		Digest:    hex.EncodeToString(digest[:]),
+		Signature: hex.EncodeToString(signature[:]),
+		PublicKey: hex.EncodeToString(publickey[:]),
+	}
+}
+
+// SetRevocations snapshots the revocation certificates. Each must verify.
+func (b *Bundle) SetRevocations(certs []*envelope.Envelope, asOf time.Time) error {
+	for i, cert := range certs {
+		if _, _, err := revocation.Verify(cert); err != nil {
+			return fmt.Errorf("revocation %d: %v", i, err)
+		}
+	}
+	asOf = asOf.UTC()
+	b.Revocations, b.RevocationsAsOf = certs, &asOf
+	return nil
+}
+
+// Parse reads a bundle from its json form
+func Parse(data []byte) (*Bundle, error) {
+	b := new(Bundle)
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	if b.Version != Version {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	return b, nil
+}
+
+func (b *Bundle) decode() (digest [32]byte, signature [64]byte, publickey [33]byte, err error) {
+	for _, f := range []struct {
+		name  string
+		value string
+		out   []byte
+	}{
+		{"digest", b.Digest, digest[:]},
+		{"signature", b.Signature, signature[:]},
+		{"public key", b.PublicKey, publickey[:]},
+	} {
+		raw, decodeErr := hex.DecodeString(f.value)
+		if decodeErr != nil || len(raw) != len(f.out) {
+			return digest, signature, publickey, fmt.Errorf("%s is not %d bytes of hex", f.name, len(f.out))
+		}
+		copy(f.out, raw)
+	}
+	return digest, signature, publickey, nil
+}
+
+// stampDigest is what a timestamp covers: the key, digest and signature
+func (b *Bundle) stampDigest() ([32]byte, error) {
+	digest, signature, publickey, err := b.decode()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	h := sha256.New()
+	h.Write(publickey[:])
+	h.Write(digest[:])
+	h.Write(signature[:])
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out, nil
+}
+
+// Policy is what the verifier trusts
+type Policy struct {
+	// Roots are the keys trusted to sign, directly or by delegation
+	Roots [][33]byte
+	// TimestampKeys are the keys trusted to timestamp
+	TimestampKeys [][33]byte
+	// RequireTimestamp refuses bundles without a trusted timestamp
+	RequireTimestamp bool
+	// Now is the time delegations are checked at when there is no
+	// timestamp, time.Now if nil
+	Now func() time.Time
+}
+
+// Result is what a verified bundle established
+type Result struct {
+	Digest    [32]byte
+	PublicKey [33]byte
+	Root      [33]byte
+	// SignedAt is the timestamp's time, nil without one
+	SignedAt *time.Time
+	// Revoked names the first revoked key found in the chain, if any, with
+	// the statement revoking it. The signature is refused when it is set.
+	Revoked *revocation.Statement
+}
+
+// Verify checks the bundle offline: the signature, the delegation chain
+// from one of the roots, the timestamp and the revocation snapshot. It
+// returns the result along with the error where the bundle got far enough
+// to have one, so a revoked key is reported as such.
+func Verify(b *Bundle, policy Policy) (*Result, error) {
+	digest, signature, publickey, err := b.decode()
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Digest: digest, PublicKey: publickey}
+
+	if ok, err := schnorr.Verify(publickey, digest, signature); !ok {
+		return nil, fmt.Errorf("signature verification failed: %v", err)
+	}
+
+	at := time.Now()
+	if policy.Now != nil {
+		at = policy.Now()
+	}
+	if b.Timestamp != nil {
+		signedAt, err := b.verifyTimestamp(policy.TimestampKeys)
+		if err != nil {
+			return nil, err
+		}
+		res.SignedAt, at = &signedAt, signedAt
+	} else if policy.RequireTimestamp {
+		return nil, fmt.Errorf("bundle has no timestamp")
+	}
+
+	chain, err := b.verifyChain(policy.Roots, publickey, at)
+	if err != nil {
+		return nil, err
+	}
+	res.Root = chain[0]
+
+	revoked := revocation.NewSet()
+	for i, cert := range b.Revocations {
+		if err := revoked.Add(cert); err != nil {
+			return nil, fmt.Errorf("revocation %d: %v", i, err)
+		}
+	}
+	for _, key := range chain {
+		if s := revoked.Revoked(key); s != nil {
+			res.Revoked = s
+			return res, fmt.Errorf("%w: %x (%s)", envelope.ErrRevoked, key, s.Reason)
+		}
+	}
+
+	return res, nil
+}
+
+// verifyTimestamp checks the timestamp is over this signature and signed by
+// a trusted timestamping key, and returns its time
+func (b *Bundle) verifyTimestamp(keys [][33]byte) (time.Time, error) {
+	if b.Timestamp.PayloadType != TimestampPayloadType {
+		return time.Time{}, fmt.Errorf("not a timestamp: payload type %q", b.Timestamp.PayloadType)
+	}
+	stamper, err := b.Timestamp.Key()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !contains(keys, stamper) {
+		return time.Time{}, fmt.Errorf("timestamp is by untrusted key %x", stamper)
+	}
+	if err := envelope.Verify(b.Timestamp, stamper); err != nil {
+		return time.Time{}, fmt.Errorf("timestamp: %v", err)
+	}
+
+	ts := new(Timestamp)
+	if err := json.Unmarshal(b.Timestamp.Payload, ts); err != nil {
+		return time.Time{}, fmt.Errorf("timestamp is not valid json: %v", err)
+	}
+	expected, err := b.stampDigest()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ts.Digest != hex.EncodeToString(expected[:]) {
+		return time.Time{}, fmt.Errorf("timestamp is for a different signature")
+	}
+	return ts.Time, nil
+}
+
+// verifyChain walks the delegations from a root down to the signing key,
+// each valid at the time given, and returns the keys from root to signer
+func (b *Bundle) verifyChain(roots [][33]byte, publickey [33]byte, at time.Time) ([][33]byte, error) {
+	if len(b.Delegations) == 0 {
+		if !contains(roots, publickey) {
+			return nil, fmt.Errorf("%x is not a trusted root and has no delegation", publickey)
+		}
+		return [][33]byte{publickey}, nil
+	}
+
+	issuer, err := b.Delegations[0].Key()
+	if err != nil {
+		return nil, err
+	}
+	if !contains(roots, issuer) {
+		return nil, fmt.Errorf("delegation chain starts at %x, which is not a trusted root", issuer)
+	}
+
+	chain := [][33]byte{issuer}
+	for i, e := range b.Delegations {
+		d, delegate, err := readDelegation(e, issuer)
+		if err != nil {
+			return nil, fmt.Errorf("delegation %d: %v", i, err)
+		}
+		if at.Before(d.NotBefore) || at.After(d.NotAfter) {
+			return nil, fmt.Errorf("delegation %d to %x is valid from %s to %s, not at %s", i, delegate,
+				d.NotBefore.Format(time.RFC3339), d.NotAfter.Format(time.RFC3339), at.UTC().Format(time.RFC3339))
+		}
+		chain = append(chain, delegate)
+		issuer = delegate
+	}
+	if issuer != publickey {
+		return nil, fmt.Errorf("delegation chain ends at %x, not the signing key %x", issuer, publickey)
+	}
+	return chain, nil
+}
+
+func contains(keys [][33]byte, key [33]byte) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
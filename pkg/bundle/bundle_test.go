@@ -0,0 +1,130 @@
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/revocation"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestBundle(t *testing.T) {
+	keys, err := schnorr.GenerateTestKeys([]byte("bundle"), 4)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	root, intermediate, signer, stamper := keys[0], keys[1], keys[2], keys[3]
+	signedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	newBundle := func(t *testing.T) *Bundle {
+		digest := sha256.Sum256([]byte("release 1.0"))
+		sig, err := schnorr.Sign(signer.PrivateKey, digest)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		b := New(digest, sig, signer.PublicKey)
+		for _, link := range []struct{ from, to schnorr.TestKey }{{root, intermediate}, {intermediate, signer}} {
+			d, err := Delegate(link.from.PrivateKey, link.to.PublicKey, signedAt.AddDate(0, -1, 0), signedAt.AddDate(0, 1, 0), "")
+			if err != nil {
+				t.Fatalf("Unexpected error from Delegate: %v", err)
+			}
+			b.Delegations = append(b.Delegations, d)
+		}
+		b.Timestamp, err = Stamp(stamper.PrivateKey, b, signedAt)
+		if err != nil {
+			t.Fatalf("Unexpected error from Stamp: %v", err)
+		}
+		return b
+	}
+	policy := Policy{
+		Roots:         [][33]byte{root.PublicKey},
+		TimestampKeys: [][33]byte{stamper.PublicKey},
+		Now:           func() time.Time { return signedAt.AddDate(1, 0, 0) },
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		// given
+		data, err := json.Marshal(newBundle(t))
+		if err != nil {
+			t.Fatalf("Unexpected error from Marshal: %v", err)
+		}
+
+		// when
+		b, err := Parse(data)
+		if err != nil {
+			t.Fatalf("Unexpected error from Parse: %v", err)
+		}
+		res, err := Verify(b, policy)
+
+		// then
+		if err != nil {
+			t.Fatalf("Unexpected error from Verify: %v", err)
+		}
+		if res.Root != root.PublicKey || res.PublicKey != signer.PublicKey {
+			t.Fatalf("Verify() = %+v, want root %x and signer %x", res, root.PublicKey, signer.PublicKey)
+		}
+		if res.SignedAt == nil || !res.SignedAt.Equal(signedAt) {
+			t.Fatalf("SignedAt = %v, want %v", res.SignedAt, signedAt)
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		// given
+		b := newBundle(t)
+		cert, err := revocation.Generate(intermediate.PrivateKey, revocation.ReasonCompromised, "")
+		if err != nil {
+			t.Fatalf("Unexpected error from Generate: %v", err)
+		}
+		if err := b.SetRevocations([]*envelope.Envelope{cert}, signedAt); err != nil {
+			t.Fatalf("Unexpected error from SetRevocations: %v", err)
+		}
+
+		// when
+		res, err := Verify(b, policy)
+
+		// then
+		if !errors.Is(err, envelope.ErrRevoked) {
+			t.Fatalf("Verify() error = %v, want %v", err, envelope.ErrRevoked)
+		}
+		if res == nil || res.Revoked == nil || res.Revoked.Reason != revocation.ReasonCompromised {
+			t.Fatalf("Verify() = %+v, want the compromised statement", res)
+		}
+	})
+
+	for _, tc := range []struct {
+		name   string
+		tamper func(b *Bundle, p *Policy)
+	}{
+		{"untrusted root", func(b *Bundle, p *Policy) { p.Roots = [][33]byte{stamper.PublicKey} }},
+		{"untrusted timestamp", func(b *Bundle, p *Policy) { p.TimestampKeys = nil }},
+		{"missing timestamp", func(b *Bundle, p *Policy) { b.Timestamp, p.RequireTimestamp = nil, true }},
+		{"broken chain", func(b *Bundle, p *Policy) { b.Delegations = b.Delegations[1:] }},
+		{"short chain", func(b *Bundle, p *Policy) { b.Delegations = b.Delegations[:1] }},
+		{"expired delegation", func(b *Bundle, p *Policy) { b.Timestamp = nil }},
+		{"other signature", func(b *Bundle, p *Policy) {
+			digest := sha256.Sum256([]byte("release 2.0"))
+			sig, _ := schnorr.Sign(signer.PrivateKey, digest)
+			stamp := New(digest, sig, signer.PublicKey)
+			b.Timestamp, _ = Stamp(stamper.PrivateKey, stamp, signedAt)
+		}},
+		{"bad signature", func(b *Bundle, p *Policy) { b.Digest = b.Digest[2:] + "00" }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			b, p := newBundle(t), policy
+
+			// when
+			tc.tamper(b, &p)
+			_, err := Verify(b, p)
+
+			// then
+			if err == nil {
+				t.Fatalf("Verify() succeeded, want an error")
+			}
+		})
+	}
+}
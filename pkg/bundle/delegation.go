@@ -0,0 +1,87 @@
+package bundle
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+)
+
+// DelegationPayloadType is the envelope payload type of a delegation
+const DelegationPayloadType = "application/vnd.schnorr-go.delegation+json"
+
+// TimestampPayloadType is the envelope payload type of a timestamp
+const TimestampPayloadType = "application/vnd.schnorr-go.timestamp+json"
+
+// Delegation is a key vouching for another key to sign on its behalf for a
+// while, so a long lived root can stay offline while day to day keys sign
+type Delegation struct {
+	Delegate  string    `json:"delegate"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	Comment   string    `json:"comment,omitempty"`
+}
+
+// Delegate signs a delegation to the delegate key, valid between notBefore
+// and notAfter
+func Delegate(privatekey *big.Int, delegate [33]byte, notBefore, notAfter time.Time, comment string) (*envelope.Envelope, error) {
+	if !notAfter.After(notBefore) {
+		return nil, fmt.Errorf("delegation ends before it starts")
+	}
+	payload, err := json.Marshal(Delegation{
+		Delegate:  hex.EncodeToString(delegate[:]),
+		NotBefore: notBefore.UTC(),
+		NotAfter:  notAfter.UTC(),
+		Comment:   comment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Sign(privatekey, DelegationPayloadType, payload)
+}
+
+// readDelegation checks the delegation is signed by issuer and returns it
+// with the delegate key
+func readDelegation(e *envelope.Envelope, issuer [33]byte) (*Delegation, [33]byte, error) {
+	var delegate [33]byte
+	if e.PayloadType != DelegationPayloadType {
+		return nil, delegate, fmt.Errorf("not a delegation: payload type %q", e.PayloadType)
+	}
+	if err := envelope.Verify(e, issuer); err != nil {
+		return nil, delegate, err
+	}
+	d := new(Delegation)
+	if err := json.Unmarshal(e.Payload, d); err != nil {
+		return nil, delegate, fmt.Errorf("delegation is not valid json: %v", err)
+	}
+	raw, err := hex.DecodeString(d.Delegate)
+	if err != nil || len(raw) != 33 {
+		return nil, delegate, fmt.Errorf("delegate is not 33 bytes of hex")
+	}
+	copy(delegate[:], raw)
+	return d, delegate, nil
+}
+
+// Timestamp is a timestamping key's statement that it saw a signature at a
+// time, which pins the signature before any later revocation or expiry
+type Timestamp struct {
+	Digest string    `json:"digest"`
+	Time   time.Time `json:"time"`
+}
+
+// Stamp signs a timestamp over the bundle's public key, digest and
+// signature at the given time
+func Stamp(privatekey *big.Int, b *Bundle, at time.Time) (*envelope.Envelope, error) {
+	digest, err := b.stampDigest()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(Timestamp{Digest: hex.EncodeToString(digest[:]), Time: at.UTC()})
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Sign(privatekey, TimestampPayloadType, payload)
+}
@@ -0,0 +1,78 @@
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// Canonicalizer turns a structured value into the one byte encoding that is
+// signed, so that two parties holding the same data always sign and verify
+// the same bytes
+type Canonicalizer interface {
+	// Name identifies the encoding and is mixed into the signed hash so a
+	// signature over one encoding can never verify as another
+	Name() string
+	Canonicalize(v interface{}) ([]byte, error)
+}
+
+// Digest canonicalizes v and hashes it with a tag naming the canonicalizer
+func Digest(v interface{}, c Canonicalizer) ([32]byte, error) {
+	data, err := c.Canonicalize(v)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("%s: %v", c.Name(), err)
+	}
+	return schnorr.TaggedHash("schnorr-go/structured/"+c.Name(), data), nil
+}
+
+// SignStructured signs the canonical form of v
+func SignStructured(privatekey *big.Int, v interface{}, c Canonicalizer) ([64]byte, error) {
+	digest, err := Digest(v, c)
+	if err != nil {
+		return [64]byte{}, err
+	}
+	return schnorr.Sign(privatekey, digest)
+}
+
+// VerifyStructured verifies a signature made by SignStructured
+func VerifyStructured(publickey [33]byte, v interface{}, signature [64]byte, c Canonicalizer) (bool, error) {
+	digest, err := Digest(v, c)
+	if err != nil {
+		return false, err
+	}
+	return schnorr.Verify(publickey, digest, signature)
+}
+
+// normalize turns v into the generic values encoding/json decodes into, with
+// numbers kept as json.Number. Raw JSON in []byte or json.RawMessage is
+// parsed rather than marshaled.
+func normalize(v interface{}) (interface{}, error) {
+	var data []byte
+
+	switch raw := v.(type) {
+	case json.RawMessage:
+		data = raw
+	case []byte:
+		data = raw
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the json value")
+	}
+	return out, nil
+}
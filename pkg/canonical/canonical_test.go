@@ -0,0 +1,127 @@
+package canonical
+
+import (
+	"encoding/hex"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestJCS(t *testing.T) {
+	// example from RFC 8785 section 3.2.2
+	input := []byte(`{
+		"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+		"literals": [null, true, false]
+	}`)
+
+	observed, err := JCS{}.Canonicalize(input)
+	if err != nil {
+		t.Fatalf("Unexpected error from Canonicalize: %v", err)
+	}
+
+	// then
+	expected := `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+	if string(observed) != expected {
+		t.Fatalf("Canonicalize() = %s, want %s", observed, expected)
+	}
+
+	t.Run("Structs and raw json agree", func(t *testing.T) {
+		a, _ := JCS{}.Canonicalize(struct {
+			B int    `json:"b"`
+			A string `json:"a"`
+		}{1, "x"})
+		b, _ := JCS{}.Canonicalize([]byte(`{"a":"x","b":1.0}`))
+		if string(a) != string(b) {
+			t.Fatalf("struct = %s, raw = %s", a, b)
+		}
+	})
+}
+
+func TestCBOR(t *testing.T) {
+	// examples from RFC 8949 appendix A
+	testCases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{0, "00"},
+		{1000000, "1a000f4240"},
+		{-1000, "3903e7"},
+		{1.5, "f93e00"},
+		{100000.0, "fa47c35000"},
+		{1.1, "fb3ff199999999999a"},
+		{5.960464477539063e-8, "f90001"},
+		{math.Inf(-1), "f9fc00"},
+		{math.NaN(), "f97e00"},
+		{"IETF", "6449455446"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{[]interface{}{1, []interface{}{2, 3}}, "8201820203"},
+		{map[string]interface{}{"b": []interface{}{2, 3}, "a": 1}, "a26161016162820203"},
+		// shorter keys sort first
+		{map[string]interface{}{"aa": 1, "b": 2}, "a261620262616101"},
+	}
+
+	for _, test := range testCases {
+		observed, err := CBOR{}.Canonicalize(test.value)
+		if err != nil {
+			t.Fatalf("Unexpected error from Canonicalize(%v): %v", test.value, err)
+		}
+
+		// then
+		if hex.EncodeToString(observed) != test.expected {
+			t.Fatalf("Canonicalize(%v) = %x, want %s", test.value, observed, test.expected)
+		}
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{"z": 1, "a": "x", "m": []interface{}{true}})
+	if err != nil {
+		t.Fatalf("Unexpected error from structpb.NewStruct: %v", err)
+	}
+
+	a, err := Protobuf{}.Canonicalize(msg)
+	if err != nil {
+		t.Fatalf("Unexpected error from Canonicalize: %v", err)
+	}
+
+	// then map entries are always in the same order
+	for i := 0; i < 10; i++ {
+		b, _ := Protobuf{}.Canonicalize(msg)
+		if string(a) != string(b) {
+			t.Fatalf("Canonicalize is not deterministic")
+		}
+	}
+
+	if _, err := (Protobuf{}).Canonicalize("not a message"); err == nil {
+		t.Fatalf("Canonicalize of a string succeeded, want error")
+	}
+}
+
+func TestSignStructured(t *testing.T) {
+	d, _ := new(big.Int).SetString("B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF", 16)
+	Px, Py := schnorr.Curve.ScalarBaseMult(d.Bytes())
+	var pk [33]byte
+	copy(pk[:], schnorr.Marshal(schnorr.Curve, Px, Py))
+
+	payload := map[string]interface{}{"amount": 5, "to": "bob"}
+
+	sig, err := SignStructured(d, payload, JCS{})
+	if err != nil {
+		t.Fatalf("Unexpected error from SignStructured: %v", err)
+	}
+
+	// then the same data in another layout still verifies
+	reordered := []byte(`{ "to": "bob", "amount": 5.0 }`)
+	if ok, err := VerifyStructured(pk, reordered, sig, JCS{}); !ok || err != nil {
+		t.Fatalf("VerifyStructured() = %v, %v, want true", ok, err)
+	}
+
+	// but not under another canonicalizer
+	if ok, _ := VerifyStructured(pk, payload, sig, CBOR{}); ok {
+		t.Fatalf("VerifyStructured() with CBOR = true, want false")
+	}
+}
@@ -0,0 +1,225 @@
+package canonical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+//
+// Core deterministic encoding of CBOR
+// https://www.rfc-editor.org/rfc/rfc8949#section-4.2.1
+//
+
+const (
+	majorUnsigned = 0
+	majorNegative = 1
+	majorBytes    = 2
+	majorText     = 3
+	majorArray    = 4
+	majorMap      = 5
+	majorSimple   = 7
+)
+
+// CBOR canonicalizes to deterministically encoded CBOR. Generic values (nil,
+// bools, numbers, strings, []byte, []interface{} and maps with string keys)
+// are encoded directly, with []byte as a byte string. Anything else is
+// converted through encoding/json first.
+type CBOR struct{}
+
+func (CBOR) Name() string {
+	return "cbor"
+}
+
+func (CBOR) Canonicalize(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := writeCBOR(buf, v, true); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCBOR(buf *bytes.Buffer, v interface{}, top bool) error {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if value {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case int:
+		writeCBORInt(buf, int64(value))
+	case int8:
+		writeCBORInt(buf, int64(value))
+	case int16:
+		writeCBORInt(buf, int64(value))
+	case int32:
+		writeCBORInt(buf, int64(value))
+	case int64:
+		writeCBORInt(buf, value)
+	case uint:
+		writeCBORHead(buf, majorUnsigned, uint64(value))
+	case uint8:
+		writeCBORHead(buf, majorUnsigned, uint64(value))
+	case uint16:
+		writeCBORHead(buf, majorUnsigned, uint64(value))
+	case uint32:
+		writeCBORHead(buf, majorUnsigned, uint64(value))
+	case uint64:
+		writeCBORHead(buf, majorUnsigned, value)
+	case float32:
+		writeCBORFloat(buf, float64(value))
+	case float64:
+		writeCBORFloat(buf, value)
+	case json.Number:
+		if i, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+			writeCBORInt(buf, i)
+		} else if u, err := strconv.ParseUint(string(value), 10, 64); err == nil {
+			writeCBORHead(buf, majorUnsigned, u)
+		} else if f, err := strconv.ParseFloat(string(value), 64); err == nil {
+			writeCBORFloat(buf, f)
+		} else {
+			return fmt.Errorf("invalid number %q", value)
+		}
+	case string:
+		writeCBORHead(buf, majorText, uint64(len(value)))
+		buf.WriteString(value)
+	case []byte:
+		writeCBORHead(buf, majorBytes, uint64(len(value)))
+		buf.Write(value)
+	case []interface{}:
+		writeCBORHead(buf, majorArray, uint64(len(value)))
+		for _, e := range value {
+			if err := writeCBOR(buf, e, false); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// keys are sorted by the bytewise order of their encodings
+		type entry struct {
+			key   []byte
+			value interface{}
+		}
+		entries := make([]entry, 0, len(value))
+		for k, e := range value {
+			kb := new(bytes.Buffer)
+			writeCBORHead(kb, majorText, uint64(len(k)))
+			kb.WriteString(k)
+			entries = append(entries, entry{kb.Bytes(), e})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].key, entries[j].key) < 0
+		})
+
+		writeCBORHead(buf, majorMap, uint64(len(entries)))
+		for _, e := range entries {
+			buf.Write(e.key)
+			if err := writeCBOR(buf, e.value, false); err != nil {
+				return err
+			}
+		}
+	default:
+		if !top {
+			return fmt.Errorf("unsupported cbor value %T", v)
+		}
+		generic, err := normalize(v)
+		if err != nil {
+			return err
+		}
+		return writeCBOR(buf, generic, false)
+	}
+	return nil
+}
+
+// writeCBORHead writes the major type with the shortest argument encoding
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	m := major << 5
+	switch {
+	case n < 24:
+		buf.WriteByte(m | byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{m | 24, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(m | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(m | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(m | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func writeCBORInt(buf *bytes.Buffer, i int64) {
+	if i >= 0 {
+		writeCBORHead(buf, majorUnsigned, uint64(i))
+	} else {
+		writeCBORHead(buf, majorNegative, uint64(-1-i))
+	}
+}
+
+// writeCBORFloat uses the shortest of half, single and double precision that
+// keeps the exact value, and the single canonical NaN
+func writeCBORFloat(buf *bytes.Buffer, f float64) {
+	if math.IsNaN(f) {
+		buf.Write([]byte{majorSimple<<5 | 25, 0x7e, 0x00})
+		return
+	}
+
+	f32 := float32(f)
+	if float64(f32) != f {
+		buf.WriteByte(majorSimple<<5 | 27)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		return
+	}
+
+	if h, ok := float16Bits(f32); ok {
+		buf.WriteByte(majorSimple<<5 | 25)
+		binary.Write(buf, binary.BigEndian, h)
+		return
+	}
+
+	buf.WriteByte(majorSimple<<5 | 26)
+	binary.Write(buf, binary.BigEndian, math.Float32bits(f32))
+}
+
+// float16Bits converts to IEEE 754 half precision if that is lossless
+func float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	switch {
+	case exp == 0xff:
+		return sign | 0x7c00, mant == 0
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		return 0, false
+	}
+
+	e := exp - 127
+	switch {
+	case e >= -14 && e <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), true
+	case e >= -24 && e < -14:
+		full := 1<<23 | mant
+		shift := uint(-14-e) + 13
+		if full&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(full>>shift), true
+	}
+	return 0, false
+}
@@ -0,0 +1,178 @@
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+//
+// JSON Canonicalization Scheme
+// https://www.rfc-editor.org/rfc/rfc8785
+//
+
+// JCS canonicalizes JSON per RFC 8785. Raw JSON may be passed as []byte or
+// json.RawMessage, anything else is run through json.Marshal first.
+type JCS struct{}
+
+func (JCS) Name() string {
+	return "jcs"
+}
+
+func (JCS) Canonicalize(v interface{}) ([]byte, error) {
+	value, err := normalize(v)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeJCS(buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJCS(buf *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	case json.Number:
+		f, err := strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return err
+		}
+		s, err := formatES6(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case string:
+		writeJCSString(buf, value)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJCS(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		// members are sorted by the UTF-16 code units of their names
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJCSString(buf, k)
+			buf.WriteByte(':')
+			if err := writeJCS(buf, value[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported json value %T", v)
+	}
+	return nil
+}
+
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeJCSString escapes only what JSON requires, with the short escapes
+// where they exist and lowercase \u00xx otherwise
+func writeJCSString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// formatES6 formats a number the way ECMAScript's Number.prototype.toString
+// does, which is what RFC 8785 section 3.2.2.3 requires
+func formatES6(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("%v cannot be represented in json", f)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+
+	// shortest round tripping digits and the decimal exponent
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp, _ := strings.Cut(e, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	x, _ := strconv.Atoi(exp)
+
+	k, n := len(digits), x+1
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+
+	out := digits[:1]
+	if k > 1 {
+		out += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return sign + out + "e+" + strconv.Itoa(n-1), nil
+	}
+	return sign + out + "e" + strconv.Itoa(n-1), nil
+}
@@ -0,0 +1,24 @@
+package canonical
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Protobuf canonicalizes protocol buffer messages with deterministic
+// marshaling, which fixes the field and map entry order. Unknown fields are
+// kept as they were received, so both sides should use the same schema.
+type Protobuf struct{}
+
+func (Protobuf) Name() string {
+	return "protobuf"
+}
+
+func (Protobuf) Canonicalize(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a proto.Message", v)
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+}
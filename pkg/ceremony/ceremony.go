@@ -0,0 +1,360 @@
+package ceremony
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/frost"
+	"github.com/ryohare/schnorr-go/pkg/musig"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Guided manual ceremonies, where the round messages are pasted between
+// participants by hand or read out over a call. Every key and message is
+// shown with a short fingerprint to compare out loud, every pasted value
+// is checked as it is entered, and a bad partial signature names the
+// participant who sent it.
+//
+
+// Fingerprint is the first 8 bytes of sha256 of the data in groups of four
+// hex digits, short enough to read out
+func Fingerprint(data []byte) string {
+	h := sha256.Sum256(data)
+	s := hex.EncodeToString(h[:8])
+	return strings.Join([]string{s[0:4], s[4:8], s[8:12], s[12:16]}, " ")
+}
+
+// TUI walks one participant through a ceremony on the prompter
+type TUI struct {
+	p     *prompt.Prompter
+	total int
+	step  int
+}
+
+func New(p *prompt.Prompter, steps int) *TUI {
+	return &TUI{p: p, total: steps}
+}
+
+func (t *TUI) printf(format string, args ...interface{}) {
+	fmt.Fprintf(t.p.Out, format, args...)
+}
+
+// next starts the next step of the ceremony
+func (t *TUI) next(title string) {
+	t.step++
+	t.printf("\n== Step %d/%d: %s ==\n", t.step, t.total, title)
+}
+
+// show prints a value for the participant to send on
+func (t *TUI) show(label, value string) {
+	t.printf("\n%s:\n\n    %s\n\n", label, value)
+}
+
+// ask reads a value, checking it with parse. A person at a terminal gets to
+// try again, a script gets the error.
+func (t *TUI) ask(question string, parse func(string) error) error {
+	for {
+		answer, err := t.p.Line(question)
+		if err != nil {
+			return err
+		}
+		err = parse(strings.TrimSpace(answer))
+		if err == nil {
+			return nil
+		}
+		if !t.p.Interactive() {
+			return err
+		}
+		t.printf("  %v, try again\n", err)
+	}
+}
+
+// confirm asks the participant to check something against the others
+func (t *TUI) confirm(question string) error {
+	if !t.p.Interactive() {
+		return nil
+	}
+	ok, err := t.p.Confirm(question, false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("ceremony aborted")
+	}
+	return nil
+}
+
+func parseHex(s string, n int) ([]byte, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != n {
+		return nil, fmt.Errorf("want %d bytes of hex", n)
+	}
+	return raw, nil
+}
+
+// RunMuSig is a MuSig2 ceremony for msg. The cosigners' keys are sorted, so
+// everyone ends up with the same aggregate key whatever order they were
+// pasted in.
+func (t *TUI) RunMuSig(privatekey *big.Int, msg []byte) ([64]byte, error) {
+	var signature [64]byte
+
+	own, err := schnorr.ScalarBaseMult(privatekey).PublicKey()
+	if err != nil {
+		return signature, err
+	}
+
+	t.next("Keys")
+	t.show("Your public key, send it to every cosigner", hex.EncodeToString(own[:]))
+	t.printf("Fingerprint %s\n\n", Fingerprint(own[:]))
+
+	count := 0
+	if err := t.ask("Number of cosigners, not counting you: ", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return fmt.Errorf("want a number of at least 1")
+		}
+		count = n
+		return nil
+	}); err != nil {
+		return signature, err
+	}
+
+	keys := [][33]byte{own}
+	for i := 0; i < count; i++ {
+		if err := t.ask(fmt.Sprintf("Public key of cosigner %d: ", i+1), func(s string) error {
+			raw, err := parseHex(s, 33)
+			if err != nil {
+				return err
+			}
+			var key [33]byte
+			copy(key[:], raw)
+			if _, err := schnorr.ParsePoint(key); err != nil {
+				return err
+			}
+			for _, k := range keys {
+				if k == key {
+					return fmt.Errorf("key was already entered")
+				}
+			}
+			keys = append(keys, key)
+			t.printf("  fingerprint %s\n", Fingerprint(key[:]))
+			return nil
+		}); err != nil {
+			return signature, err
+		}
+	}
+	keys = musig.SortKeys(keys)
+
+	ctx, err := musig.AggregateKeys(keys)
+	if err != nil {
+		return signature, err
+	}
+	aggKey, _ := ctx.XOnly()
+	t.printf("\nAggregate key %x\nFingerprint   %s\nMessage       %s\n\n", aggKey, Fingerprint(aggKey[:]), Fingerprint(msg))
+	if err := t.confirm("Do the aggregate key and message fingerprints match everyone else's?"); err != nil {
+		return signature, err
+	}
+
+	t.next("Nonces")
+	secnonce, pubnonce, err := musig.NonceGen(privatekey, own, ctx, msg)
+	if err != nil {
+		return signature, err
+	}
+	t.show("Your nonce, send it to every cosigner", hex.EncodeToString(pubnonce[:]))
+
+	pubnonces := map[[33]byte]musig.PubNonce{own: pubnonce}
+	for _, key := range keys {
+		if key == own {
+			continue
+		}
+		if err := t.ask(fmt.Sprintf("Nonce from %s: ", Fingerprint(key[:])), func(s string) error {
+			raw, err := parseHex(s, 66)
+			if err != nil {
+				return err
+			}
+			var n musig.PubNonce
+			copy(n[:], raw)
+			if _, err := musig.AggregateNonces([]musig.PubNonce{n}); err != nil {
+				return err
+			}
+			pubnonces[key] = n
+			return nil
+		}); err != nil {
+			return signature, err
+		}
+	}
+
+	all := []musig.PubNonce{}
+	for _, key := range keys {
+		all = append(all, pubnonces[key])
+	}
+	aggnonce, err := musig.AggregateNonces(all)
+	if err != nil {
+		return signature, err
+	}
+	session, err := musig.NewSession(ctx, aggnonce, msg)
+	if err != nil {
+		return signature, err
+	}
+
+	t.next("Partial signatures")
+	psig, err := session.Sign(secnonce, privatekey)
+	if err != nil {
+		return signature, err
+	}
+	t.show("Your partial signature, send it to every cosigner", hex.EncodeToString(psig[:]))
+
+	psigs := [][32]byte{psig}
+	for _, key := range keys {
+		if key == own {
+			continue
+		}
+		if err := t.ask(fmt.Sprintf("Partial signature from %s: ", Fingerprint(key[:])), func(s string) error {
+			raw, err := parseHex(s, 32)
+			if err != nil {
+				return err
+			}
+			var p [32]byte
+			copy(p[:], raw)
+			if err := session.VerifyPartial(p, pubnonces[key], key); err != nil {
+				return fmt.Errorf("partial signature from %s is invalid", Fingerprint(key[:]))
+			}
+			psigs = append(psigs, p)
+			return nil
+		}); err != nil {
+			return signature, err
+		}
+	}
+
+	t.next("Signature")
+	signature, err = session.Combine(psigs)
+	if err != nil {
+		return signature, err
+	}
+	t.show("Signature", hex.EncodeToString(signature[:]))
+	return signature, nil
+}
+
+// RunFROST is a FROST ceremony for msg with the participant's key share
+func (t *TUI) RunFROST(ks *frost.KeyShare, pkg *frost.PublicKeyPackage, msg []byte) ([64]byte, error) {
+	var signature [64]byte
+
+	if err := pkg.VerifyKeyShare(ks); err != nil {
+		return signature, err
+	}
+
+	t.next("Signers")
+	groupKey, _ := pkg.GroupKey.XOnly()
+	t.printf("You are participant %d\nGroup key   %x\nFingerprint %s\nMessage     %s\n\n", ks.ID, groupKey, Fingerprint(groupKey[:]), Fingerprint(msg))
+
+	signers := []uint32{}
+	if err := t.ask("Ids of everyone signing, including you, separated by commas: ", func(s string) error {
+		signers = signers[:0]
+		seen := map[uint32]bool{}
+		for _, field := range strings.Split(s, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+			if err != nil {
+				return fmt.Errorf("%q is not an id", field)
+			}
+			if _, ok := pkg.Indices[uint32(id)]; !ok || seen[uint32(id)] {
+				return fmt.Errorf("%d is not a participant or is repeated", id)
+			}
+			seen[uint32(id)] = true
+			signers = append(signers, uint32(id))
+		}
+		if !seen[ks.ID] {
+			return fmt.Errorf("you, participant %d, are not in the list", ks.ID)
+		}
+		return nil
+	}); err != nil {
+		return signature, err
+	}
+
+	for _, id := range signers {
+		share, _ := pkg.VerifyingShare(pkg.Indices[id][0]).PublicKey()
+		t.printf("  participant %d fingerprint %s\n", id, Fingerprint(share[:]))
+	}
+	t.printf("\n")
+	if err := t.confirm("Do the group key, message and participant fingerprints match everyone else's?"); err != nil {
+		return signature, err
+	}
+
+	t.next("Commitments")
+	nonces, own, err := frost.Commit(ks.ID)
+	if err != nil {
+		return signature, err
+	}
+	ownBytes, _ := own.Bytes()
+	t.show("Your commitment, send it to every signer", hex.EncodeToString(ownBytes[:]))
+
+	commitments := []frost.SigningCommitment{own}
+	for _, id := range signers {
+		if id == ks.ID {
+			continue
+		}
+		if err := t.ask(fmt.Sprintf("Commitment from participant %d: ", id), func(s string) error {
+			raw, err := parseHex(s, 66)
+			if err != nil {
+				return err
+			}
+			var b [66]byte
+			copy(b[:], raw)
+			c, err := frost.ParseSigningCommitment(id, b)
+			if err != nil {
+				return err
+			}
+			commitments = append(commitments, c)
+			return nil
+		}); err != nil {
+			return signature, err
+		}
+	}
+
+	sp, err := frost.NewSigningPackage(pkg, commitments, msg)
+	if err != nil {
+		return signature, err
+	}
+
+	t.next("Signature shares")
+	z, err := sp.Sign(ks, nonces)
+	if err != nil {
+		return signature, err
+	}
+	t.show("Your signature share, send it to every signer", hex.EncodeToString(z[:]))
+
+	shares := map[uint32][32]byte{ks.ID: z}
+	for _, id := range signers {
+		if id == ks.ID {
+			continue
+		}
+		if err := t.ask(fmt.Sprintf("Signature share from participant %d: ", id), func(s string) error {
+			raw, err := parseHex(s, 32)
+			if err != nil {
+				return err
+			}
+			var share [32]byte
+			copy(share[:], raw)
+			if err := sp.VerifyShare(id, share); err != nil {
+				return err
+			}
+			shares[id] = share
+			return nil
+		}); err != nil {
+			return signature, err
+		}
+	}
+
+	t.next("Signature")
+	signature, err = sp.Aggregate(shares)
+	if err != nil {
+		return signature, err
+	}
+	t.show("Signature", hex.EncodeToString(signature[:]))
+	return signature, nil
+}
@@ -0,0 +1,152 @@
+package ceremony
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/frost"
+	"github.com/ryohare/schnorr-go/pkg/musig"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	schnorrgo "github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// participant is a TUI whose shown values are pasted into the other
+// participant's prompts, after the answers given up front
+type participant struct {
+	tui   *TUI
+	in    *io.PipeWriter
+	out   *io.PipeReader
+	first string
+}
+
+func pair(steps int, first string) (*participant, *participant) {
+	mk := func() *participant {
+		inR, inW := io.Pipe()
+		outR, outW := io.Pipe()
+		return &participant{tui: New(&prompt.Prompter{In: inR, Out: outW}, steps), in: inW, out: outR, first: first}
+	}
+	a, b := mk(), mk()
+	// pasted values are queued so neither side blocks on the other's output
+	relay := func(from, to *participant) {
+		queue := make(chan string, 16)
+		queue <- from.first
+		go func() {
+			for s := range queue {
+				io.WriteString(to.in, s)
+			}
+		}()
+		scanner := bufio.NewScanner(from.out)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "    ") {
+				queue <- strings.TrimSpace(scanner.Text()) + "\n"
+			}
+		}
+		close(queue)
+	}
+	go relay(a, b)
+	go relay(b, a)
+	return a, b
+}
+
+type result struct {
+	sig [64]byte
+	err error
+}
+
+func TestMuSig(t *testing.T) {
+	// given
+	keys, err := schnorrgo.GenerateTestKeys([]byte("ceremony"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	digest := sha256.Sum256([]byte("pay 1 btc to carol"))
+	msg := digest[:]
+	a, b := pair(4, "1\n")
+
+	// when
+	results := make(chan result, 2)
+	for i, p := range []*participant{a, b} {
+		go func(p *participant, priv *big.Int) {
+			sig, err := p.tui.RunMuSig(priv, msg)
+			results <- result{sig, err}
+		}(p, keys[i].PrivateKey)
+	}
+	r1, r2 := <-results, <-results
+
+	// then
+	if r1.err != nil || r2.err != nil {
+		t.Fatalf("Unexpected error from RunMuSig: %v, %v", r1.err, r2.err)
+	}
+	if r1.sig != r2.sig {
+		t.Fatalf("RunMuSig() = %x and %x, want the same signature", r1.sig, r2.sig)
+	}
+
+	// the cosigners' keys are sorted, whichever order they came in
+	ctx, err := musig.AggregateKeys(musig.SortKeys([][33]byte{keys[0].PublicKey, keys[1].PublicKey}))
+	if err != nil {
+		t.Fatalf("Unexpected error from AggregateKeys: %v", err)
+	}
+	xonly, _ := ctx.XOnly()
+	pk, _ := schnorr.ParsePubKey(xonly[:])
+	sig, err := schnorr.ParseSignature(r1.sig[:])
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseSignature: %v", err)
+	}
+	if !sig.Verify(msg, pk) {
+		t.Fatalf("RunMuSig() signature does not verify against the aggregate key")
+	}
+}
+
+func TestFROST(t *testing.T) {
+	// given
+	policy := frost.ThresholdPolicy(2, 3)
+	secret := big.NewInt(0xc0ffee)
+	shares, pkg, err := frost.Deal(secret, policy)
+	if err != nil {
+		t.Fatalf("Unexpected error from Deal: %v", err)
+	}
+	msg := []byte("rotate the vault key")
+	a, b := pair(4, "1,3\n")
+
+	// when
+	results := make(chan result, 2)
+	for _, p := range []struct {
+		p  *participant
+		ks *frost.KeyShare
+	}{{a, shares[0]}, {b, shares[2]}} {
+		go func(p *participant, ks *frost.KeyShare) {
+			sig, err := p.tui.RunFROST(ks, pkg, msg)
+			results <- result{sig, err}
+		}(p.p, p.ks)
+	}
+	r1, r2 := <-results, <-results
+
+	// then
+	if r1.err != nil || r2.err != nil {
+		t.Fatalf("Unexpected error from RunFROST: %v, %v", r1.err, r2.err)
+	}
+	if r1.sig != r2.sig {
+		t.Fatalf("RunFROST() = %x and %x, want the same signature", r1.sig, r2.sig)
+	}
+}
+
+func TestPastedGarbage(t *testing.T) {
+	keys, _ := schnorrgo.GenerateTestKeys([]byte("ceremony"), 1)
+	tui := New(&prompt.Prompter{In: strings.NewReader("1\nnot a key\n"), Out: io.Discard}, 4)
+
+	if _, err := tui.RunMuSig(keys[0].PrivateKey, []byte("hi")); err == nil {
+		t.Fatalf("RunMuSig() with a bad key from a pipe = nil error, want error")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fp := Fingerprint([]byte("abc"))
+	if fp != "ba78 16bf 8f01 cfea" {
+		t.Fatalf("Fingerprint() = %q, want the first 8 bytes of sha256", fp)
+	}
+}
@@ -0,0 +1,324 @@
+package chunked
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Signing inputs too big to hash in one pass. The input is cut into fixed
+// size chunks which are hashed in parallel, the chunk hashes are the leaves
+// of a Merkle tree and only the root is signed. The manifest lists every
+// chunk hash, so a downloader can check each chunk as it arrives, resume
+// from the first bad one, or check a single chunk with a Merkle proof.
+//
+
+// DefaultChunkSize is 4 MiB
+const DefaultChunkSize int64 = 4 << 20
+
+// Manifest is a signed Merkle root over the chunks of an input. Hashes, the
+// key and the signature are hex in json.
+type Manifest struct {
+	Size      int64    `json:"size"`
+	ChunkSize int64    `json:"chunkSize"`
+	Chunks    []string `json:"chunks"`
+	Root      string   `json:"root"`
+	PublicKey string   `json:"publicKey"`
+	Signature string   `json:"signature"`
+}
+
+// LeafHash is the hash of chunk index. The index is hashed in so chunks
+// can't be reordered.
+func LeafHash(index uint64, data []byte) [32]byte {
+	return schnorr.TaggedHash("schnorr-go/chunked/leaf", appendUint64(nil, index), data)
+}
+
+func nodeHash(left, right [32]byte) [32]byte {
+	return schnorr.TaggedHash("schnorr-go/chunked/node", left[:], right[:])
+}
+
+// Root is the Merkle root of the leaves. A node without a sibling moves up a
+// level unchanged.
+func Root(leaves [][32]byte) [32]byte {
+	if len(leaves) == 0 {
+		return schnorr.TaggedHash("schnorr-go/chunked/leaf")
+	}
+
+	level := append([][32]byte{}, leaves...)
+	for len(level) > 1 {
+		next := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, nodeHash(level[i], level[i+1]))
+			}
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// Proof is the sibling hashes from leaf index up to the root, skipping
+// levels where the node has no sibling
+func Proof(leaves [][32]byte, index int) ([][32]byte, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("chunk %d out of range, there are %d", index, len(leaves))
+	}
+
+	proof := [][32]byte{}
+	level := append([][32]byte{}, leaves...)
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+
+		next := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, nodeHash(level[i], level[i+1]))
+			}
+		}
+		level = next
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyProof checks that leaf is chunk index of the count chunks under root
+func VerifyProof(root [32]byte, count, index int, leaf [32]byte, proof [][32]byte) bool {
+	if index < 0 || index >= count {
+		return false
+	}
+
+	h := leaf
+	for width := count; width > 1; width = (width + 1) / 2 {
+		sibling := index ^ 1
+		if sibling < width {
+			if len(proof) == 0 {
+				return false
+			}
+			if index%2 == 0 {
+				h = nodeHash(h, proof[0])
+			} else {
+				h = nodeHash(proof[0], h)
+			}
+			proof = proof[1:]
+		}
+		index /= 2
+	}
+	return len(proof) == 0 && h == root
+}
+
+// chunkCount is how many chunks size bytes take, at least one so empty
+// inputs still have a leaf
+func chunkCount(size, chunkSize int64) int {
+	if size == 0 {
+		return 1
+	}
+	return int((size + chunkSize - 1) / chunkSize)
+}
+
+// Hash hashes the size bytes of r in chunks of chunkSize with the given
+// number of workers, all cpus if 0
+func Hash(r io.ReaderAt, size, chunkSize int64, workers int) ([][32]byte, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("chunk size must be positive")
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	leaves := make([][32]byte, chunkCount(size, chunkSize))
+	indexes := make(chan int)
+	errs := make(chan error, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, chunkSize)
+			for i := range indexes {
+				offset := int64(i) * chunkSize
+				n := chunkSize
+				if offset+n > size {
+					n = size - offset
+				}
+				if _, err := io.ReadFull(io.NewSectionReader(r, offset, n), buf[:n]); err != nil {
+					errs <- fmt.Errorf("chunk %d: %v", i, err)
+					return
+				}
+				leaves[i] = LeafHash(uint64(i), buf[:n])
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for i := range leaves {
+		select {
+		case indexes <- i:
+		case err = <-errs:
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	return leaves, err
+}
+
+// Sign hashes r and signs the root
+func Sign(privatekey *big.Int, r io.ReaderAt, size, chunkSize int64, workers int) (*Manifest, error) {
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	leaves, err := Hash(r, size, chunkSize, workers)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Size: size, ChunkSize: chunkSize}
+	for _, leaf := range leaves {
+		m.Chunks = append(m.Chunks, hex.EncodeToString(leaf[:]))
+	}
+	root := Root(leaves)
+	m.Root = hex.EncodeToString(root[:])
+
+	publickey, err := schnorr.ScalarBaseMult(privatekey).PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	m.PublicKey = hex.EncodeToString(publickey[:])
+
+	sig, err := schnorr.Sign(privatekey, m.digest(root))
+	if err != nil {
+		return nil, err
+	}
+	m.Signature = hex.EncodeToString(sig[:])
+
+	return m, nil
+}
+
+// digest is what the signature covers: the sizes and the root. The chunk
+// hashes are covered through the root.
+func (m *Manifest) digest(root [32]byte) [32]byte {
+	data := appendUint64(nil, uint64(m.Size))
+	data = appendUint64(data, uint64(m.ChunkSize))
+	return schnorr.TaggedHash("schnorr-go/chunked/manifest", data, root[:])
+}
+
+// Leaves returns the chunk hashes listed in the manifest
+func (m *Manifest) Leaves() ([][32]byte, error) {
+	leaves := make([][32]byte, len(m.Chunks))
+	for i, c := range m.Chunks {
+		raw, err := hex.DecodeString(c)
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("chunk %d hash is not 32 bytes of hex", i)
+		}
+		copy(leaves[i][:], raw)
+	}
+	return leaves, nil
+}
+
+// Verify checks the manifest is signed by publickey and that its chunk
+// hashes add up to the signed root. The chunks themselves are checked with
+// VerifyChunk or VerifyReader.
+func Verify(m *Manifest, publickey [33]byte) error {
+	if m.Size < 0 || m.ChunkSize <= 0 {
+		return fmt.Errorf("invalid size %d or chunk size %d", m.Size, m.ChunkSize)
+	}
+	if len(m.Chunks) != chunkCount(m.Size, m.ChunkSize) {
+		return fmt.Errorf("manifest lists %d chunks, want %d for %d bytes", len(m.Chunks), chunkCount(m.Size, m.ChunkSize), m.Size)
+	}
+
+	if m.PublicKey != hex.EncodeToString(publickey[:]) {
+		return fmt.Errorf("manifest is signed by %s, want %x", m.PublicKey, publickey)
+	}
+
+	leaves, err := m.Leaves()
+	if err != nil {
+		return err
+	}
+	root := Root(leaves)
+	if m.Root != hex.EncodeToString(root[:]) {
+		return fmt.Errorf("chunk hashes do not add up to the root")
+	}
+
+	raw, err := hex.DecodeString(m.Signature)
+	if err != nil || len(raw) != 64 {
+		return fmt.Errorf("signature is not 64 bytes of hex")
+	}
+	var sig [64]byte
+	copy(sig[:], raw)
+
+	if ok, err := schnorr.Verify(publickey, m.digest(root), sig); !ok {
+		return fmt.Errorf("signature verification failed: %v", err)
+	}
+	return nil
+}
+
+// VerifyChunk checks chunk index against a verified manifest
+func (m *Manifest) VerifyChunk(index int, data []byte) error {
+	if index < 0 || index >= len(m.Chunks) {
+		return fmt.Errorf("chunk %d out of range, there are %d", index, len(m.Chunks))
+	}
+
+	want := m.ChunkSize
+	if last := m.Size - int64(index)*m.ChunkSize; last < want {
+		want = last
+	}
+	if int64(len(data)) != want {
+		return fmt.Errorf("chunk %d is %d bytes, want %d", index, len(data), want)
+	}
+
+	leaf := LeafHash(uint64(index), data)
+	if m.Chunks[index] != hex.EncodeToString(leaf[:]) {
+		return fmt.Errorf("chunk %d does not match the manifest", index)
+	}
+	return nil
+}
+
+// VerifyReader hashes r against a verified manifest and returns the
+// indexes of the chunks which don't match, in order
+func (m *Manifest) VerifyReader(r io.ReaderAt, size int64, workers int) ([]int, error) {
+	if size != m.Size {
+		return nil, fmt.Errorf("input is %d bytes, manifest is for %d", size, m.Size)
+	}
+
+	leaves, err := Hash(r, size, m.ChunkSize, workers)
+	if err != nil {
+		return nil, err
+	}
+
+	bad := []int{}
+	for i, leaf := range leaves {
+		if m.Chunks[i] != hex.EncodeToString(leaf[:]) {
+			bad = append(bad, i)
+		}
+	}
+	return bad, nil
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	for i := 7; i >= 0; i-- {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return b
+}
@@ -0,0 +1,108 @@
+package chunked
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestSignVerify(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("chunked"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+
+	// when
+	m, err := Sign(keys[0].PrivateKey, bytes.NewReader(data), int64(len(data)), 1024, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	// then
+	if len(m.Chunks) != 16 {
+		t.Fatalf("len(Chunks) = %d, want 16", len(m.Chunks))
+	}
+	if err := Verify(m, keys[0].PublicKey); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+	if err := m.VerifyChunk(15, data[15*1024:]); err != nil {
+		t.Fatalf("VerifyChunk() on the short last chunk = %v, want nil", err)
+	}
+
+	t.Run("Worker count does not change the manifest", func(t *testing.T) {
+		one, err := Sign(keys[0].PrivateKey, bytes.NewReader(data), int64(len(data)), 1024, 1)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		if one.Root != m.Root || one.Signature != m.Signature {
+			t.Fatalf("Sign() with 1 worker = %s, want %s", one.Root, m.Root)
+		}
+	})
+
+	t.Run("Corrupt chunks are found", func(t *testing.T) {
+		corrupt := append([]byte{}, data...)
+		corrupt[3*1024+7] ^= 1
+		corrupt[9*1024] ^= 1
+		bad, err := m.VerifyReader(bytes.NewReader(corrupt), int64(len(corrupt)), 3)
+		if err != nil {
+			t.Fatalf("Unexpected error from VerifyReader: %v", err)
+		}
+		if len(bad) != 2 || bad[0] != 3 || bad[1] != 9 {
+			t.Fatalf("VerifyReader() = %v, want [3 9]", bad)
+		}
+		if err := m.VerifyChunk(3, corrupt[3*1024:4*1024]); err == nil {
+			t.Fatalf("VerifyChunk() on a corrupt chunk = nil, want error")
+		}
+	})
+
+	t.Run("Swapped chunk hashes fail", func(t *testing.T) {
+		tampered := *m
+		tampered.Chunks = append([]string{}, m.Chunks...)
+		tampered.Chunks[0], tampered.Chunks[1] = tampered.Chunks[1], tampered.Chunks[0]
+		if err := Verify(&tampered, keys[0].PublicKey); err == nil {
+			t.Fatalf("Verify() with reordered chunks = nil, want error")
+		}
+	})
+
+	t.Run("Wrong key fails", func(t *testing.T) {
+		if err := Verify(m, keys[1].PublicKey); err == nil {
+			t.Fatalf("Verify() with another key = nil, want error")
+		}
+	})
+
+	t.Run("Empty input", func(t *testing.T) {
+		empty, err := Sign(keys[0].PrivateKey, bytes.NewReader(nil), 0, 1024, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		if err := Verify(empty, keys[0].PublicKey); err != nil {
+			t.Fatalf("Verify() on empty input = %v, want nil", err)
+		}
+	})
+}
+
+func TestProof(t *testing.T) {
+	for _, count := range []int{1, 2, 3, 7, 8} {
+		leaves := make([][32]byte, count)
+		for i := range leaves {
+			leaves[i] = LeafHash(uint64(i), []byte{byte(i)})
+		}
+		root := Root(leaves)
+
+		for i := range leaves {
+			proof, err := Proof(leaves, i)
+			if err != nil {
+				t.Fatalf("Unexpected error from Proof(%d of %d): %v", i, count, err)
+			}
+			if !VerifyProof(root, count, i, leaves[i], proof) {
+				t.Fatalf("VerifyProof(%d of %d) = false, want true", i, count)
+			}
+			if count > 1 && VerifyProof(root, count, (i+1)%count, leaves[i], proof) {
+				t.Fatalf("VerifyProof(%d of %d) at the wrong index = true, want false", i, count)
+			}
+		}
+	}
+}
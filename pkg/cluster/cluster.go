@@ -0,0 +1,252 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/store"
+)
+
+//
+// Running several replicas of the daemon with no single point of failure.
+// The replicas share a state store, and in it a lease: a value naming the
+// replica that holds it, which expires unless that replica renews it. The
+// holder is the leader, and serves what can't be spread over replicas, such
+// as ceremonies whose nonces are only in its memory; the others forward
+// those requests to it. When the leader goes, its lease expires and another
+// replica takes it.
+//
+// Without fencing, a leader paused for longer than the lease, by a stalled
+// process or a partition, may briefly act alongside its successor. It stops
+// acting as leader once its own copy of the lease runs out, which is never
+// later than the store's, so that only happens when the replicas' clocks
+// disagree by more than the time left on the lease when it was last renewed.
+//
+
+// DefaultTTL is how long a lease lasts without being renewed
+const DefaultTTL = 15 * time.Second
+
+// bucket is the state store bucket of the leases
+const bucket = "cluster-leases"
+
+// ForwardedHeader is set on requests a replica forwards to the leader,
+// naming the replica
+const ForwardedHeader = "X-Schnorr-Forwarded-By"
+
+// Elector campaigns for the lease named Name. ID is what the lease names
+// this replica by, and is the URL other replicas forward requests to.
+type Elector struct {
+	State store.Store
+	Name  string
+	ID    string
+	TTL   time.Duration
+
+	// Notify, if set, is called when the holder of the lease changes, ""
+	// when there is none this replica knows of, and when a round of the
+	// campaign fails
+	Notify func(holder string, err error)
+
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+	proxies map[string]*httputil.ReverseProxy
+	now     func() time.Time
+}
+
+// NewElector campaigns for the lease through the state store
+func NewElector(state store.Store, name, id string) (*Elector, error) {
+	u, err := url.Parse(id)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("replica id %q is not the http or https url other replicas reach it at", id)
+	}
+	return &Elector{
+		State:   state,
+		Name:    name,
+		ID:      id,
+		TTL:     DefaultTTL,
+		proxies: map[string]*httputil.ReverseProxy{},
+		now:     time.Now,
+	}, nil
+}
+
+// Run campaigns until the context is done, renewing the lease three times
+// each TTL while this replica holds it, then gives it up
+func (e *Elector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.TTL / 3)
+	defer ticker.Stop()
+	for {
+		if err := e.step(ctx); err != nil && e.Notify != nil {
+			e.Notify(e.Leader(), err)
+		}
+		select {
+		case <-ctx.Done():
+			e.Resign(context.Background())
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// lease is the value of a lease in the store. The store drops it once it
+// expires, but replicas that don't hold it need to know when that is.
+type lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// step takes the lease if no replica holds it, or renews it if this one
+// does, and records who does until when
+func (e *Elector) step(ctx context.Context) error {
+	expires := e.now().Add(e.TTL)
+	mine, err := json.Marshal(lease{Holder: e.ID, Expires: expires})
+	if err != nil {
+		return err
+	}
+
+	err = e.State.Create(ctx, bucket, e.Name, mine, expires)
+	if err != store.ErrExists {
+		if err != nil {
+			return err
+		}
+		e.set(e.ID, expires)
+		return nil
+	}
+	value, current, err := e.current(ctx)
+	if err == nil && current.Holder == e.ID {
+		// renew it, as it's ours
+		err = e.State.Swap(ctx, bucket, e.Name, value, mine, expires)
+		if err == nil {
+			e.set(e.ID, expires)
+			return nil
+		}
+		if err == store.ErrChanged {
+			_, current, err = e.current(ctx)
+		}
+	}
+	switch err {
+	case nil:
+		e.set(current.Holder, current.Expires)
+		return nil
+	case store.ErrNotFound:
+		// it expired since, and is taken next round
+		e.set("", time.Time{})
+		return nil
+	}
+	return err
+}
+
+// current reads the lease as it is in the store. A lease written before
+// leases carried their expiry only names its holder, and is taken to last
+// a TTL from now.
+func (e *Elector) current(ctx context.Context) ([]byte, lease, error) {
+	value, err := e.State.Get(ctx, bucket, e.Name)
+	if err != nil {
+		return nil, lease{}, err
+	}
+	var l lease
+	if json.Unmarshal(value, &l) != nil || l.Holder == "" {
+		l = lease{Holder: string(value), Expires: e.now().Add(e.TTL)}
+	}
+	return value, l, nil
+}
+
+// set records the holder, which is taken to hold the lease until expires
+func (e *Elector) set(holder string, expires time.Time) {
+	e.mu.Lock()
+	changed := e.holder != holder
+	e.holder, e.expires = holder, expires
+	e.mu.Unlock()
+	if changed && e.Notify != nil {
+		e.Notify(holder, nil)
+	}
+}
+
+// Leader returns the replica holding the lease, "" if none is known to
+func (e *Elector) Leader() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.now().Before(e.expires) {
+		return ""
+	}
+	return e.holder
+}
+
+// IsLeader reports whether this replica holds the lease
+func (e *Elector) IsLeader() bool {
+	return e.Leader() == e.ID
+}
+
+// Resign gives up the lease if this replica holds it, so another can take
+// it without waiting for it to expire
+func (e *Elector) Resign(ctx context.Context) error {
+	if !e.IsLeader() {
+		return nil
+	}
+	e.set("", time.Time{})
+	value, current, err := e.current(ctx)
+	if err == store.ErrNotFound || err == nil && current.Holder != e.ID {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// an expired lease, as Delete would remove a successor's
+	past := e.now().Add(-time.Second)
+	resigned, err := json.Marshal(lease{Holder: e.ID, Expires: past})
+	if err != nil {
+		return err
+	}
+	err = e.State.Swap(ctx, bucket, e.Name, value, resigned, past)
+	if err == store.ErrChanged {
+		return nil
+	}
+	return err
+}
+
+// Handler serves requests with next on the leader and forwards them to the
+// leader on the other replicas. Without a leader they're answered with 503,
+// as are requests forwarded by a replica that took this one for the leader,
+// rather than forwarding them again.
+func (e *Elector) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leader := e.Leader()
+		if leader == e.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if leader == "" || r.Header.Get(ForwardedHeader) != "" {
+			w.Header().Set("Retry-After", fmt.Sprint(int(e.TTL.Seconds())))
+			http.Error(w, "no leader, try again once one is elected", http.StatusServiceUnavailable)
+			return
+		}
+		proxy, err := e.proxy(leader)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		r.Header.Set(ForwardedHeader, e.ID)
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// proxy returns the reverse proxy to the replica
+func (e *Elector) proxy(id string) (*httputil.ReverseProxy, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if proxy, ok := e.proxies[id]; ok {
+		return proxy, nil
+	}
+	u, err := url.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("leader %q is not a url: %v", id, err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	e.proxies[id] = proxy
+	return proxy, nil
+}
@@ -0,0 +1,152 @@
+package cluster
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/store"
+)
+
+func newTestElector(t *testing.T, state store.Store, id string) *Elector {
+	e, err := NewElector(state, "signer", id)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewElector: %v", err)
+	}
+	e.TTL = 100 * time.Millisecond
+	return e
+}
+
+func TestElector(t *testing.T) {
+	// given two replicas sharing a store
+	ctx := context.Background()
+	state := store.NewMemory()
+	a := newTestElector(t, state, "http://a:8200")
+	b := newTestElector(t, state, "http://b:8200")
+
+	// when
+	if err := a.step(ctx); err != nil {
+		t.Fatalf("Unexpected error from step: %v", err)
+	}
+	if err := b.step(ctx); err != nil {
+		t.Fatalf("Unexpected error from step: %v", err)
+	}
+
+	// then
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("IsLeader() = %v, %v, want only the first replica to lead", a.IsLeader(), b.IsLeader())
+	}
+	if leader := b.Leader(); leader != a.ID {
+		t.Fatalf("Leader() = %q, want %q", leader, a.ID)
+	}
+
+	t.Run("Followers take the lease's expiry from the store", func(t *testing.T) {
+		later := newTestElector(t, state, "http://c:8200")
+		later.now = func() time.Time { return time.Now().Add(a.TTL / 2) }
+		later.step(ctx)
+		if later.holder != a.ID || !later.expires.Equal(a.expires) {
+			t.Fatalf("lease recorded = %s until %v, want %s until %v", later.holder, later.expires, a.ID, a.expires)
+		}
+	})
+
+	t.Run("Renewal keeps the lease", func(t *testing.T) {
+		a.step(ctx)
+		b.step(ctx)
+		if !a.IsLeader() || b.IsLeader() {
+			t.Fatalf("IsLeader() after renewal = %v, %v, want only the first replica to lead", a.IsLeader(), b.IsLeader())
+		}
+	})
+
+	t.Run("Expired lease is taken over", func(t *testing.T) {
+		time.Sleep(2 * a.TTL)
+		if a.IsLeader() {
+			t.Fatalf("IsLeader() of an expired lease = true, want false")
+		}
+		b.step(ctx)
+		a.step(ctx)
+		if a.IsLeader() || !b.IsLeader() {
+			t.Fatalf("IsLeader() = %v, %v, want only the second replica to lead", a.IsLeader(), b.IsLeader())
+		}
+	})
+
+	t.Run("Resign hands the lease on", func(t *testing.T) {
+		if err := b.Resign(ctx); err != nil {
+			t.Fatalf("Unexpected error from Resign: %v", err)
+		}
+		a.step(ctx)
+		if !a.IsLeader() {
+			t.Fatalf("IsLeader() after the leader resigned = false, want true")
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	// given a leader and a follower serving the same handler
+	ctx := context.Background()
+	state := store.NewMemory()
+	served := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+		})
+	}
+
+	var leader, follower *Elector
+	leaderServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leader.Handler(served("leader")).ServeHTTP(w, r)
+	}))
+	defer leaderServer.Close()
+	followerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		follower.Handler(served("follower")).ServeHTTP(w, r)
+	}))
+	defer followerServer.Close()
+	leader = newTestElector(t, state, leaderServer.URL)
+	follower = newTestElector(t, state, followerServer.URL)
+	leader.TTL, follower.TTL = time.Minute, time.Minute
+
+	get := func(url string, header http.Header) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error from Get: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("No leader", func(t *testing.T) {
+		if status, _ := get(followerServer.URL, nil); status != http.StatusServiceUnavailable {
+			t.Fatalf("status with no leader = %d, want 503", status)
+		}
+	})
+
+	leader.step(ctx)
+	follower.step(ctx)
+
+	t.Run("Follower forwards to the leader", func(t *testing.T) {
+		if status, body := get(followerServer.URL, nil); status != http.StatusOK || body != "leader" {
+			t.Fatalf("follower answered %d %q, want 200 leader", status, body)
+		}
+	})
+
+	t.Run("Forwarded requests aren't forwarded again", func(t *testing.T) {
+		header := http.Header{ForwardedHeader: {"http://c:8200"}}
+		if status, _ := get(followerServer.URL, header); status != http.StatusServiceUnavailable {
+			t.Fatalf("status of a forwarded request = %d, want 503", status)
+		}
+	})
+}
+
+func TestNewElector(t *testing.T) {
+	for _, id := range []string{"", "replica-1", "ftp://a", "http://"} {
+		if _, err := NewElector(store.NewMemory(), "signer", id); err == nil {
+			t.Fatalf("NewElector(%q) = nil error, want one", id)
+		}
+	}
+}
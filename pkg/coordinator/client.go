@@ -0,0 +1,130 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultPollInterval is how often Wait asks for the session status
+const DefaultPollInterval = time.Second
+
+// Client is a participant's or organizer's view of a coordinator
+type Client struct {
+	Address      string
+	HTTP         *http.Client
+	PollInterval time.Duration
+}
+
+func NewClient(address string) *Client {
+	return &Client{
+		Address:      strings.TrimSuffix(address, "/"),
+		HTTP:         &http.Client{Timeout: 30 * time.Second},
+		PollInterval: DefaultPollInterval,
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (Status, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return Status{}, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.Address+Prefix+path, reader)
+	if err != nil {
+		return Status{}, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return Status{}, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Status{}, err
+	}
+
+	if resp.StatusCode >= 400 {
+		errResp := struct {
+			Errors []string `json:"errors"`
+		}{}
+		json.Unmarshal(data, &errResp)
+		return Status{}, fmt.Errorf("coordinator %s %s: %s %s", method, path, resp.Status, strings.Join(errResp.Errors, "; "))
+	}
+
+	out := struct {
+		Data Status `json:"data"`
+	}{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return Status{}, err
+	}
+	return out.Data, nil
+}
+
+// Create starts a session
+func (c *Client) Create(ctx context.Context, req CreateRequest) (Status, error) {
+	return c.do(ctx, http.MethodPost, "/", req)
+}
+
+// Status reads the session
+func (c *Client) Status(ctx context.Context, id string) (Status, error) {
+	return c.do(ctx, http.MethodGet, "/"+id, nil)
+}
+
+func (c *Client) Register(ctx context.Context, id, participant string) (Status, error) {
+	return c.do(ctx, http.MethodPost, "/"+id+"/register", map[string]string{"participant": participant})
+}
+
+func (c *Client) SubmitNonce(ctx context.Context, id, participant string, nonce [66]byte) (Status, error) {
+	return c.do(ctx, http.MethodPost, "/"+id+"/nonce", map[string]string{
+		"participant": participant,
+		"nonce":       hex.EncodeToString(nonce[:]),
+	})
+}
+
+func (c *Client) SubmitPartial(ctx context.Context, id, participant string, partial [32]byte) (Status, error) {
+	return c.do(ctx, http.MethodPost, "/"+id+"/partial", map[string]string{
+		"participant": participant,
+		"partial":     hex.EncodeToString(partial[:]),
+	})
+}
+
+// Wait polls until the session reaches the state or a later one. A failed
+// session is returned as an error.
+func (c *Client) Wait(ctx context.Context, id string, state State) (Status, error) {
+	order := map[State]int{StateRegistering: 0, StateNonces: 1, StatePartials: 2, StateComplete: 3}
+
+	for {
+		status, err := c.Status(ctx, id)
+		if err != nil {
+			return status, err
+		}
+		if status.State == StateFailed {
+			return status, fmt.Errorf("session %s failed: %s", id, status.Error)
+		}
+		if order[status.State] >= order[state] {
+			return status, nil
+		}
+
+		select {
+		case <-time.After(c.PollInterval):
+		case <-ctx.Done():
+			return status, ctx.Err()
+		}
+	}
+}
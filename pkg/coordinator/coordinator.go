@@ -0,0 +1,408 @@
+package coordinator
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/frost"
+)
+
+// DefaultRoundTimeout is how long participants have to finish each round
+const DefaultRoundTimeout = 5 * time.Minute
+
+// State is where a session is in the ceremony
+type State string
+
+const (
+	StateRegistering State = "registering"
+	StateNonces      State = "nonces"
+	StatePartials    State = "partials"
+	StateComplete    State = "complete"
+	StateFailed      State = "failed"
+)
+
+// CreateRequest starts a session. MuSig sessions list the signers' keys,
+// FROST sessions give the public key package and the ids of the signers.
+type CreateRequest struct {
+	Protocol         string                  `json:"protocol"`
+	Message          string                  `json:"message"`
+	Keys             []string                `json:"keys,omitempty"`
+	PublicKeyPackage *frost.PublicKeyPackage `json:"public_key_package,omitempty"`
+	Signers          []uint32                `json:"signers,omitempty"`
+}
+
+// Status is the public view of a session. Participants are hex keys for
+// MuSig and ids for FROST. Nonces are published once every participant has
+// sent one, along with the aggregate nonce for MuSig.
+type Status struct {
+	ID           string            `json:"id"`
+	Protocol     string            `json:"protocol"`
+	Message      string            `json:"message"`
+	State        State             `json:"state"`
+	Deadline     time.Time         `json:"deadline"`
+	Participants []string          `json:"participants"`
+	Registered   []string          `json:"registered"`
+	Nonces       map[string]string `json:"nonces,omitempty"`
+	AggNonce     string            `json:"aggnonce,omitempty"`
+	Signature    string            `json:"signature,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	Blamed       []string          `json:"blamed,omitempty"`
+}
+
+type session struct {
+	status     Status
+	protocol   protocol
+	registered map[string]bool
+	nonces     map[string][66]byte
+	partials   map[string][32]byte
+}
+
+// Coordinator hosts signing sessions. It sequences the rounds, fails a
+// session when a round runs past its deadline, checks every contribution
+// as it arrives and publishes the final signature. It answers under
+// /v1/ceremonies with:
+//
+//	POST /                   create a session from a CreateRequest
+//	GET  /<id>               read the session Status
+//	POST /<id>/register      {"participant"}
+//	POST /<id>/nonce         {"participant", "nonce": hex(66 bytes)}
+//	POST /<id>/partial       {"participant", "partial": hex(32 bytes)}
+type Coordinator struct {
+	RoundTimeout time.Duration
+
+	// Publish, if set, is called with every session that completes
+	Publish func(Status)
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func New(roundTimeout time.Duration) *Coordinator {
+	if roundTimeout <= 0 {
+		roundTimeout = DefaultRoundTimeout
+	}
+	return &Coordinator{RoundTimeout: roundTimeout, sessions: map[string]*session{}}
+}
+
+// Prefix is the path the coordinator answers under
+const Prefix = "/v1/ceremonies"
+
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, Prefix), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodPost:
+		c.handleCreate(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		c.handleStatus(w, parts[0])
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		c.handleSubmit(w, r, parts[0], parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "no handler for route")
+	}
+}
+
+// Create starts a new session and returns its status
+func (c *Coordinator) Create(req CreateRequest) (Status, error) {
+	msg, err := hex.DecodeString(req.Message)
+	if err != nil {
+		return Status{}, fmt.Errorf("message is not hex: %v", err)
+	}
+
+	var p protocol
+	switch req.Protocol {
+	case "musig":
+		p, err = newMuSig(req.Keys, msg)
+	case "frost":
+		p, err = newFROST(req.PublicKeyPackage, req.Signers, msg)
+	default:
+		err = fmt.Errorf("unknown protocol %q, want musig or frost", req.Protocol)
+	}
+	if err != nil {
+		return Status{}, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Status{}, err
+	}
+
+	s := &session{
+		status: Status{
+			ID:           hex.EncodeToString(id),
+			Protocol:     req.Protocol,
+			Message:      req.Message,
+			State:        StateRegistering,
+			Deadline:     time.Now().Add(c.RoundTimeout),
+			Participants: p.participants(),
+			Registered:   []string{},
+		},
+		protocol:   p,
+		registered: map[string]bool{},
+		nonces:     map[string][66]byte{},
+		partials:   map[string][32]byte{},
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions[s.status.ID] = s
+	return s.status, nil
+}
+
+// Status returns the current status of the session
+func (c *Coordinator) Status(id string) (Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.sessions[id]
+	if !ok {
+		return Status{}, fmt.Errorf("session %s not found", id)
+	}
+	c.expire(s)
+	return s.status, nil
+}
+
+// expire fails the session if the current round is past its deadline,
+// blaming whoever had not sent their part
+func (c *Coordinator) expire(s *session) {
+	if s.status.State == StateComplete || s.status.State == StateFailed || time.Now().Before(s.status.Deadline) {
+		return
+	}
+
+	missing := []string{}
+	for _, p := range s.status.Participants {
+		done := false
+		switch s.status.State {
+		case StateRegistering:
+			done = s.registered[p]
+		case StateNonces:
+			_, done = s.nonces[p]
+		case StatePartials:
+			_, done = s.partials[p]
+		}
+		if !done {
+			missing = append(missing, p)
+		}
+	}
+
+	c.fail(s, fmt.Sprintf("%s round timed out", s.status.State), missing)
+}
+
+func (c *Coordinator) fail(s *session, reason string, blamed []string) {
+	s.status.State = StateFailed
+	s.status.Error = reason
+	s.status.Blamed = blamed
+}
+
+func (c *Coordinator) isParticipant(s *session, participant string) bool {
+	for _, p := range s.status.Participants {
+		if p == participant {
+			return true
+		}
+	}
+	return false
+}
+
+// Register marks the participant as present, starting the nonce round once
+// everyone is
+func (c *Coordinator) Register(id, participant string) (Status, error) {
+	return c.submit(id, participant, StateRegistering, func(s *session) error {
+		s.registered[participant] = true
+		s.status.Registered = sortedKeys(s.registered)
+		if len(s.registered) == len(s.status.Participants) {
+			c.advance(s, StateNonces)
+		}
+		return nil
+	})
+}
+
+// SubmitNonce records a first round nonce, starting the signing round once
+// every participant has sent one
+func (c *Coordinator) SubmitNonce(id, participant string, nonce [66]byte) (Status, error) {
+	return c.submit(id, participant, StateNonces, func(s *session) error {
+		if _, ok := s.nonces[participant]; ok {
+			return fmt.Errorf("participant %s already sent a nonce", participant)
+		}
+		if err := s.protocol.checkNonce(participant, nonce); err != nil {
+			return fmt.Errorf("invalid nonce: %v", err)
+		}
+		s.nonces[participant] = nonce
+
+		if len(s.nonces) < len(s.status.Participants) {
+			return nil
+		}
+
+		aggnonce, err := s.protocol.startSigning(s.nonces)
+		if err != nil {
+			c.fail(s, err.Error(), nil)
+			return nil
+		}
+		s.status.Nonces = map[string]string{}
+		for p, n := range s.nonces {
+			s.status.Nonces[p] = hex.EncodeToString(n[:])
+		}
+		s.status.AggNonce = aggnonce
+		c.advance(s, StatePartials)
+		return nil
+	})
+}
+
+// SubmitPartial records a partial signature. A bad one fails the session
+// and blames the participant. Once every participant has sent one the
+// final signature is published.
+func (c *Coordinator) SubmitPartial(id, participant string, partial [32]byte) (Status, error) {
+	return c.submit(id, participant, StatePartials, func(s *session) error {
+		if _, ok := s.partials[participant]; ok {
+			return fmt.Errorf("participant %s already sent a partial signature", participant)
+		}
+		if err := s.protocol.verifyPartial(participant, partial); err != nil {
+			c.fail(s, err.Error(), []string{participant})
+			return err
+		}
+		s.partials[participant] = partial
+
+		if len(s.partials) < len(s.status.Participants) {
+			return nil
+		}
+
+		sig, err := s.protocol.combine(s.partials)
+		if err != nil {
+			c.fail(s, err.Error(), nil)
+			return nil
+		}
+		s.status.Signature = hex.EncodeToString(sig[:])
+		c.advance(s, StateComplete)
+		if c.Publish != nil {
+			go c.Publish(s.status)
+		}
+		return nil
+	})
+}
+
+func (c *Coordinator) submit(id, participant string, state State, f func(*session) error) (Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.sessions[id]
+	if !ok {
+		return Status{}, fmt.Errorf("session %s not found", id)
+	}
+	c.expire(s)
+
+	if !c.isParticipant(s, participant) {
+		return s.status, fmt.Errorf("%s is not a participant of session %s", participant, id)
+	}
+	if s.status.State != state {
+		return s.status, fmt.Errorf("session %s is in the %s round, not %s", id, s.status.State, state)
+	}
+
+	err := f(s)
+	return s.status, err
+}
+
+func (c *Coordinator) advance(s *session, state State) {
+	s.status.State = state
+	s.status.Deadline = time.Now().Add(c.RoundTimeout)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := []string{}
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (c *Coordinator) handleCreate(w http.ResponseWriter, r *http.Request) {
+	req := CreateRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status, err := c.Create(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeData(w, status)
+}
+
+func (c *Coordinator) handleStatus(w http.ResponseWriter, id string) {
+	status, err := c.Status(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeData(w, status)
+}
+
+func (c *Coordinator) handleSubmit(w http.ResponseWriter, r *http.Request, id, action string) {
+	req := struct {
+		Participant string `json:"participant"`
+		Nonce       string `json:"nonce"`
+		Partial     string `json:"partial"`
+	}{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var status Status
+	var err error
+	switch action {
+	case "register":
+		status, err = c.Register(id, req.Participant)
+	case "nonce":
+		var nonce [66]byte
+		raw, decodeErr := hex.DecodeString(req.Nonce)
+		if decodeErr != nil || len(raw) != 66 {
+			writeError(w, http.StatusBadRequest, "nonce must be 66 bytes of hex")
+			return
+		}
+		copy(nonce[:], raw)
+		status, err = c.SubmitNonce(id, req.Participant, nonce)
+	case "partial":
+		var partial [32]byte
+		raw, decodeErr := hex.DecodeString(req.Partial)
+		if decodeErr != nil || len(raw) != 32 {
+			writeError(w, http.StatusBadRequest, "partial must be 32 bytes of hex")
+			return
+		}
+		copy(partial[:], raw)
+		status, err = c.SubmitPartial(id, req.Participant, partial)
+	default:
+		writeError(w, http.StatusNotFound, "no handler for route")
+		return
+	}
+
+	if err != nil {
+		if status.ID == "" {
+			writeError(w, http.StatusNotFound, err.Error())
+		} else {
+			writeError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+	writeData(w, status)
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]string{"errors": {msg}})
+}
@@ -0,0 +1,223 @@
+package coordinator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/frost"
+	"github.com/ryohare/schnorr-go/pkg/musig"
+	sg "github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func setup(t *testing.T, timeout time.Duration) (*Client, func()) {
+	server := httptest.NewServer(New(timeout))
+	client := NewClient(server.URL)
+	client.PollInterval = 10 * time.Millisecond
+	return client, server.Close
+}
+
+func checkSignature(t *testing.T, status Status, xonly [32]byte, msg []byte) {
+	raw, _ := hex.DecodeString(status.Signature)
+	sig, err := schnorr.ParseSignature(raw)
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseSignature(%s): %v", status.Signature, err)
+	}
+	pubKey, _ := schnorr.ParsePubKey(xonly[:])
+	if !sig.Verify(msg, pubKey) {
+		t.Fatalf("Signature = %s, does not verify", status.Signature)
+	}
+}
+
+func TestMuSigSession(t *testing.T) {
+	// given
+	client, stop := setup(t, 0)
+	defer stop()
+	ctx := context.Background()
+
+	keys, err := sg.GenerateTestKeys([]byte("coordinator"), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	publickeys, hexKeys := [][33]byte{}, []string{}
+	for _, key := range keys {
+		publickeys = append(publickeys, key.PublicKey)
+		hexKeys = append(hexKeys, hex.EncodeToString(key.PublicKey[:]))
+	}
+	keyAgg, _ := musig.AggregateKeys(publickeys)
+	msg := sha256.Sum256([]byte("ceremony"))
+
+	// when
+	status, err := client.Create(ctx, CreateRequest{Protocol: "musig", Message: hex.EncodeToString(msg[:]), Keys: hexKeys})
+	if err != nil {
+		t.Fatalf("Unexpected error from Create: %v", err)
+	}
+	id := status.ID
+
+	errs := make(chan error, len(keys))
+	for i, key := range keys {
+		go func(key sg.TestKey, participant string) {
+			errs <- func() error {
+				if _, err := client.Register(ctx, id, participant); err != nil {
+					return err
+				}
+				if _, err := client.Wait(ctx, id, StateNonces); err != nil {
+					return err
+				}
+
+				secnonce, pubnonce, err := musig.NonceGen(key.PrivateKey, key.PublicKey, keyAgg, msg[:])
+				if err != nil {
+					return err
+				}
+				if _, err := client.SubmitNonce(ctx, id, participant, pubnonce); err != nil {
+					return err
+				}
+				status, err := client.Wait(ctx, id, StatePartials)
+				if err != nil {
+					return err
+				}
+
+				var aggnonce musig.PubNonce
+				raw, _ := hex.DecodeString(status.AggNonce)
+				copy(aggnonce[:], raw)
+				session, err := musig.NewSession(keyAgg, aggnonce, msg[:])
+				if err != nil {
+					return err
+				}
+				psig, err := session.Sign(secnonce, key.PrivateKey)
+				if err != nil {
+					return err
+				}
+				_, err = client.SubmitPartial(ctx, id, participant, psig)
+				return err
+			}()
+		}(key, hexKeys[i])
+	}
+	for range keys {
+		if err := <-errs; err != nil {
+			t.Fatalf("Unexpected error from participant: %v", err)
+		}
+	}
+
+	// then
+	status, err = client.Wait(ctx, id, StateComplete)
+	if err != nil {
+		t.Fatalf("Unexpected error from Wait: %v", err)
+	}
+	xonly, _ := keyAgg.XOnly()
+	checkSignature(t, status, xonly, msg[:])
+}
+
+func TestFROSTSession(t *testing.T) {
+	// given
+	client, stop := setup(t, 0)
+	defer stop()
+	ctx := context.Background()
+
+	keys, _ := sg.GenerateTestKeys([]byte("coordinator"), 1)
+	shares, pkg, err := frost.Deal(keys[0].PrivateKey, frost.ThresholdPolicy(2, 3))
+	if err != nil {
+		t.Fatalf("Unexpected error from Deal: %v", err)
+	}
+	msg := sha256.Sum256([]byte("ceremony"))
+
+	// when 1 and 3 sign, one after the other
+	status, err := client.Create(ctx, CreateRequest{Protocol: "frost", Message: hex.EncodeToString(msg[:]), PublicKeyPackage: pkg, Signers: []uint32{1, 3}})
+	if err != nil {
+		t.Fatalf("Unexpected error from Create: %v", err)
+	}
+	id := status.ID
+
+	signers := map[string]*frost.KeyShare{"1": shares[0], "3": shares[2]}
+	nonces := map[string]*frost.SigningNonces{}
+	for participant := range signers {
+		if _, err := client.Register(ctx, id, participant); err != nil {
+			t.Fatalf("Unexpected error from Register: %v", err)
+		}
+	}
+	for participant, ks := range signers {
+		n, c, _ := frost.Commit(ks.ID)
+		nonces[participant] = n
+		b, _ := c.Bytes()
+		if _, err := client.SubmitNonce(ctx, id, participant, b); err != nil {
+			t.Fatalf("Unexpected error from SubmitNonce: %v", err)
+		}
+	}
+
+	status, err = client.Wait(ctx, id, StatePartials)
+	if err != nil {
+		t.Fatalf("Unexpected error from Wait: %v", err)
+	}
+	commitments := []frost.SigningCommitment{}
+	for participant, nonce := range status.Nonces {
+		var b [66]byte
+		raw, _ := hex.DecodeString(nonce)
+		copy(b[:], raw)
+		c, _ := frost.ParseSigningCommitment(signers[participant].ID, b)
+		commitments = append(commitments, c)
+	}
+	sp, err := frost.NewSigningPackage(pkg, commitments, msg[:])
+	if err != nil {
+		t.Fatalf("Unexpected error from NewSigningPackage: %v", err)
+	}
+	for participant, ks := range signers {
+		z, _ := sp.Sign(ks, nonces[participant])
+		if status, err = client.SubmitPartial(ctx, id, participant, z); err != nil {
+			t.Fatalf("Unexpected error from SubmitPartial: %v", err)
+		}
+	}
+
+	// then
+	if status.State != StateComplete {
+		t.Fatalf("State = %s, want complete", status.State)
+	}
+	xonly, _ := pkg.GroupKey.XOnly()
+	checkSignature(t, status, xonly, msg[:])
+}
+
+func TestFailures(t *testing.T) {
+	keys, _ := sg.GenerateTestKeys([]byte("coordinator"), 2)
+	hexKeys := []string{hex.EncodeToString(keys[0].PublicKey[:]), hex.EncodeToString(keys[1].PublicKey[:])}
+	ctx := context.Background()
+
+	t.Run("Round timeout blames the absent", func(t *testing.T) {
+		client, stop := setup(t, 50*time.Millisecond)
+		defer stop()
+
+		status, err := client.Create(ctx, CreateRequest{Protocol: "musig", Message: "00", Keys: hexKeys})
+		if err != nil {
+			t.Fatalf("Unexpected error from Create: %v", err)
+		}
+		client.Register(ctx, status.ID, hexKeys[0])
+
+		status, err = client.Wait(ctx, status.ID, StateNonces)
+		if err == nil || len(status.Blamed) != 1 || status.Blamed[0] != hexKeys[1] {
+			t.Fatalf("Wait() = %v, %v, want the second key blamed", status.Blamed, err)
+		}
+	})
+
+	t.Run("Outsiders are refused", func(t *testing.T) {
+		client, stop := setup(t, 0)
+		defer stop()
+
+		status, _ := client.Create(ctx, CreateRequest{Protocol: "musig", Message: "00", Keys: hexKeys})
+		_, err := client.Register(ctx, status.ID, strings.Repeat("02", 33))
+		if err == nil {
+			t.Fatalf("Register() of an outsider = nil error, want error")
+		}
+	})
+
+	t.Run("Unknown protocol", func(t *testing.T) {
+		client, stop := setup(t, 0)
+		defer stop()
+
+		if _, err := client.Create(ctx, CreateRequest{Protocol: "bls", Message: "00"}); err == nil {
+			t.Fatalf("Create() with protocol bls = nil error, want error")
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package coordinator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/ryohare/schnorr-go/pkg/frost"
+	"github.com/ryohare/schnorr-go/pkg/musig"
+)
+
+// protocol is what differs between MuSig and FROST for the coordinator.
+// Everything it handles is public, the coordinator never sees a secret.
+type protocol interface {
+	participants() []string
+
+	// checkNonce validates a first round nonce or commitment
+	checkNonce(participant string, nonce [66]byte) error
+
+	// startSigning is called with every nonce once the first round is done
+	// and returns the aggregate nonce to publish, if the protocol has one
+	startSigning(nonces map[string][66]byte) (string, error)
+
+	// verifyPartial checks a second round signature share
+	verifyPartial(participant string, partial [32]byte) error
+
+	// combine produces the final signature
+	combine(partials map[string][32]byte) ([64]byte, error)
+}
+
+type musigProtocol struct {
+	keys    [][33]byte
+	ctx     *musig.KeyAggContext
+	msg     []byte
+	nonces  map[string][66]byte
+	session *musig.Session
+}
+
+func newMuSig(keys []string, msg []byte) (*musigProtocol, error) {
+	p := &musigProtocol{msg: msg}
+	for _, k := range keys {
+		raw, err := hex.DecodeString(k)
+		if err != nil || len(raw) != 33 {
+			return nil, fmt.Errorf("key %q is not 33 bytes of hex", k)
+		}
+		var key [33]byte
+		copy(key[:], raw)
+		p.keys = append(p.keys, key)
+	}
+
+	var err error
+	p.ctx, err = musig.AggregateKeys(p.keys)
+	return p, err
+}
+
+func (p *musigProtocol) participants() []string {
+	out := []string{}
+	seen := map[[33]byte]bool{}
+	for _, key := range p.keys {
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, hex.EncodeToString(key[:]))
+		}
+	}
+	return out
+}
+
+func (p *musigProtocol) checkNonce(participant string, nonce [66]byte) error {
+	_, err := musig.AggregateNonces([]musig.PubNonce{nonce})
+	return err
+}
+
+func (p *musigProtocol) startSigning(nonces map[string][66]byte) (string, error) {
+	p.nonces = nonces
+
+	all := []musig.PubNonce{}
+	for _, n := range nonces {
+		all = append(all, n)
+	}
+	aggnonce, err := musig.AggregateNonces(all)
+	if err != nil {
+		return "", err
+	}
+
+	p.session, err = musig.NewSession(p.ctx, aggnonce, p.msg)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(aggnonce[:]), nil
+}
+
+func (p *musigProtocol) key(participant string) [33]byte {
+	var key [33]byte
+	raw, _ := hex.DecodeString(participant)
+	copy(key[:], raw)
+	return key
+}
+
+func (p *musigProtocol) verifyPartial(participant string, partial [32]byte) error {
+	return p.session.VerifyPartial(partial, p.nonces[participant], p.key(participant))
+}
+
+func (p *musigProtocol) combine(partials map[string][32]byte) ([64]byte, error) {
+	psigs, pubnonces, keys := [][32]byte{}, []musig.PubNonce{}, [][33]byte{}
+	for participant, psig := range partials {
+		psigs = append(psigs, psig)
+		pubnonces = append(pubnonces, p.nonces[participant])
+		keys = append(keys, p.key(participant))
+	}
+	return p.session.CombineVerified(psigs, pubnonces, keys)
+}
+
+type frostProtocol struct {
+	pkg     *frost.PublicKeyPackage
+	signers []uint32
+	msg     []byte
+	sp      *frost.SigningPackage
+}
+
+func newFROST(pkg *frost.PublicKeyPackage, signers []uint32, msg []byte) (*frostProtocol, error) {
+	if pkg == nil {
+		return nil, fmt.Errorf("frost sessions need a public key package")
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no signers given")
+	}
+	for _, id := range signers {
+		if _, ok := pkg.Indices[id]; !ok {
+			return nil, fmt.Errorf("participant %d is not part of the key", id)
+		}
+	}
+	return &frostProtocol{pkg: pkg, signers: signers, msg: msg}, nil
+}
+
+func (p *frostProtocol) participants() []string {
+	out := []string{}
+	for _, id := range p.signers {
+		out = append(out, strconv.FormatUint(uint64(id), 10))
+	}
+	return out
+}
+
+func (p *frostProtocol) id(participant string) uint32 {
+	id, _ := strconv.ParseUint(participant, 10, 32)
+	return uint32(id)
+}
+
+func (p *frostProtocol) checkNonce(participant string, nonce [66]byte) error {
+	_, err := frost.ParseSigningCommitment(p.id(participant), nonce)
+	return err
+}
+
+func (p *frostProtocol) startSigning(nonces map[string][66]byte) (string, error) {
+	commitments := []frost.SigningCommitment{}
+	for participant, n := range nonces {
+		c, err := frost.ParseSigningCommitment(p.id(participant), n)
+		if err != nil {
+			return "", err
+		}
+		commitments = append(commitments, c)
+	}
+
+	var err error
+	p.sp, err = frost.NewSigningPackage(p.pkg, commitments, p.msg)
+	return "", err
+}
+
+func (p *frostProtocol) verifyPartial(participant string, partial [32]byte) error {
+	return p.sp.VerifyShare(p.id(participant), partial)
+}
+
+func (p *frostProtocol) combine(partials map[string][32]byte) ([64]byte, error) {
+	shares := map[uint32][32]byte{}
+	for participant, z := range partials {
+		shares[p.id(participant)] = z
+	}
+	return p.sp.Aggregate(shares)
+}
@@ -0,0 +1,140 @@
+package cosign
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Simple signing payloads in the layout cosign uses
+// https://github.com/containers/image/blob/main/docs/containers-signature.5.md
+// https://github.com/sigstore/cosign/blob/main/specs/SIGNATURE_SPEC.md
+//
+
+// SignatureType is the critical.type cosign writes and expects
+const SignatureType = "cosign container image signature"
+
+type Identity struct {
+	DockerReference string `json:"docker-reference"`
+}
+
+type Image struct {
+	DockerManifestDigest string `json:"docker-manifest-digest"`
+}
+
+type Critical struct {
+	Identity Identity `json:"identity"`
+	Image    Image    `json:"image"`
+	Type     string   `json:"type"`
+}
+
+// Payload is the simple signing document. Optional carries the annotations.
+type Payload struct {
+	Critical Critical          `json:"critical"`
+	Optional map[string]string `json:"optional"`
+}
+
+// ParseImage splits registry/repo@sha256:<hex> into the reference and digest
+func ParseImage(image string) (string, string, error) {
+	reference, digest, found := strings.Cut(image, "@")
+	if !found {
+		return "", "", fmt.Errorf("image %q must be pinned by digest, like repo@sha256:...", image)
+	}
+	if err := checkDigest(digest); err != nil {
+		return "", "", err
+	}
+	return reference, digest, nil
+}
+
+func checkDigest(digest string) error {
+	algorithm, h, _ := strings.Cut(digest, ":")
+	raw, err := hex.DecodeString(h)
+	if algorithm != "sha256" || err != nil || len(raw) != 32 {
+		return fmt.Errorf("digest %q is not sha256:<64 hex characters>", digest)
+	}
+	return nil
+}
+
+// NewPayload builds the payload for the image manifest digest
+func NewPayload(reference, digest string, annotations map[string]string) (*Payload, error) {
+	if reference == "" {
+		return nil, fmt.Errorf("docker reference is empty")
+	}
+	if err := checkDigest(digest); err != nil {
+		return nil, err
+	}
+
+	return &Payload{
+		Critical: Critical{
+			Identity: Identity{DockerReference: reference},
+			Image:    Image{DockerManifestDigest: digest},
+			Type:     SignatureType,
+		},
+		Optional: annotations,
+	}, nil
+}
+
+// Marshal returns the payload bytes that get signed and published
+func (p *Payload) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// Sign signs sha256(payload) and returns the base64 signature blob
+func Sign(privatekey *big.Int, payload []byte) (string, error) {
+	sig, err := schnorr.Sign(privatekey, sha256.Sum256(payload))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig[:]), nil
+}
+
+// Verify checks the base64 signature blob over the payload bytes and
+// returns the parsed payload. Callers still have to check that the payload
+// is for the image they care about, see Payload.Matches.
+func Verify(publickey [33]byte, payload []byte, signature string) (*Payload, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(raw) != 64 {
+		return nil, fmt.Errorf("signature is not 64 bytes of base64")
+	}
+
+	var sig [64]byte
+	copy(sig[:], raw)
+
+	ok, err := schnorr.Verify(publickey, sha256.Sum256(payload), sig)
+	if !ok {
+		return nil, fmt.Errorf("signature verification failed: %v", err)
+	}
+
+	p := new(Payload)
+	if err := json.Unmarshal(payload, p); err != nil {
+		return nil, fmt.Errorf("payload is not valid json: %v", err)
+	}
+	if p.Critical.Type != SignatureType {
+		return nil, fmt.Errorf("unexpected payload type %q", p.Critical.Type)
+	}
+	return p, nil
+}
+
+// Matches checks the payload is for the digest, the reference when one is
+// given, and carries every one of the annotations
+func (p *Payload) Matches(reference, digest string, annotations map[string]string) error {
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("payload is for digest %s, not %s", p.Critical.Image.DockerManifestDigest, digest)
+	}
+	if reference != "" && p.Critical.Identity.DockerReference != reference {
+		return fmt.Errorf("payload is for %s, not %s", p.Critical.Identity.DockerReference, reference)
+	}
+	for k, v := range annotations {
+		if p.Optional[k] != v {
+			return fmt.Errorf("annotation %s is %q, want %q", k, p.Optional[k], v)
+		}
+	}
+	return nil
+}
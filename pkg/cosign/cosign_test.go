@@ -0,0 +1,68 @@
+package cosign
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+const testDigest = "sha256:5a0bd5ce7a2e6b81da1b9c1e1a0bfb4a3bd2ae7d1c1cbeb3e1e6e0e6a8b8c0d1"
+
+func TestSignVerify(t *testing.T) {
+	d, _ := new(big.Int).SetString("5e591f62ea55b029326e8f2736a0bc2d0ca2552bcc001ebf6966561a6a63a06c", 16)
+	Px, Py := schnorr.Curve.ScalarBaseMult(d.Bytes())
+	var pk [33]byte
+	copy(pk[:], schnorr.Marshal(schnorr.Curve, Px, Py))
+
+	reference, digest, err := ParseImage("ghcr.io/ryohare/app@" + testDigest)
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseImage: %v", err)
+	}
+
+	p, err := NewPayload(reference, digest, map[string]string{"commit": "abc123"})
+	if err != nil {
+		t.Fatalf("Unexpected error from NewPayload: %v", err)
+	}
+	payload, _ := p.Marshal()
+
+	// then the layout matches what cosign writes
+	if !strings.Contains(string(payload), `"critical":{"identity":{"docker-reference":"ghcr.io/ryohare/app"},"image":{"docker-manifest-digest":"`+testDigest+`"},"type":"cosign container image signature"}`) {
+		t.Fatalf("payload = %s", payload)
+	}
+
+	// when
+	sig, err := Sign(d, payload)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	verified, err := Verify(pk, payload, sig)
+	if err != nil {
+		t.Fatalf("Unexpected error from Verify: %v", err)
+	}
+	if err := verified.Matches(reference, digest, map[string]string{"commit": "abc123"}); err != nil {
+		t.Fatalf("Unexpected error from Matches: %v", err)
+	}
+
+	t.Run("Other digest does not match", func(t *testing.T) {
+		other := "sha256:" + strings.Repeat("0", 64)
+		if err := verified.Matches("", other, nil); err == nil {
+			t.Fatalf("Matches(%s) succeeded, want error", other)
+		}
+	})
+
+	t.Run("Modified payload fails", func(t *testing.T) {
+		modified := []byte(strings.Replace(string(payload), "abc123", "evil", 1))
+		if _, err := Verify(pk, modified, sig); err == nil {
+			t.Fatalf("Verify of a modified payload succeeded, want error")
+		}
+	})
+
+	t.Run("Tags are not digests", func(t *testing.T) {
+		if _, _, err := ParseImage("ghcr.io/ryohare/app:latest"); err == nil {
+			t.Fatalf("ParseImage of a tag succeeded, want error")
+		}
+	})
+}
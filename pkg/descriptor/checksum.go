@@ -0,0 +1,94 @@
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0380.mediawiki#checksum
+//
+
+const (
+	inputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+var checksumGenerator = [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+
+func checksumPolymod(symbols []uint64) uint64 {
+	chk := uint64(1)
+	for _, value := range symbols {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ value
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= checksumGenerator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func checksumExpand(s string) ([]uint64, error) {
+	symbols := []uint64{}
+	groups := []uint64{}
+
+	for _, c := range s {
+		v := strings.IndexRune(inputCharset, c)
+		if v < 0 {
+			return nil, fmt.Errorf("invalid character %q in descriptor", c)
+		}
+
+		symbols = append(symbols, uint64(v&31))
+		groups = append(groups, uint64(v>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = groups[:0]
+		}
+	}
+
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+
+	return symbols, nil
+}
+
+// Checksum computes the 8 character checksum of a descriptor
+func Checksum(desc string) (string, error) {
+	symbols, err := checksumExpand(desc)
+	if err != nil {
+		return "", err
+	}
+
+	symbols = append(symbols, 0, 0, 0, 0, 0, 0, 0, 0)
+	c := checksumPolymod(symbols) ^ 1
+
+	var sb strings.Builder
+	for i := 0; i < 8; i++ {
+		sb.WriteByte(checksumCharset[(c>>(5*(7-uint(i))))&31])
+	}
+	return sb.String(), nil
+}
+
+// splitChecksum removes and validates a trailing #checksum if there is one
+func splitChecksum(desc string) (string, error) {
+	pos := strings.LastIndexByte(desc, '#')
+	if pos < 0 {
+		return desc, nil
+	}
+
+	body, sum := desc[:pos], desc[pos+1:]
+	expected, err := Checksum(body)
+	if err != nil {
+		return "", err
+	}
+	if sum != expected {
+		return "", fmt.Errorf("descriptor checksum %q does not match, expected %q", sum, expected)
+	}
+	return body, nil
+}
@@ -0,0 +1,338 @@
+package descriptor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0386.mediawiki
+//
+
+const (
+	opChecksig    = 0xac
+	opChecksigAdd = 0xba
+	opNumEqual    = 0x9c
+)
+
+// Key is a key expression with its optional [fingerprint/path] origin
+type Key struct {
+	XOnly  [32]byte
+	Origin string
+}
+
+func (k Key) String() string {
+	return k.Origin + hex.EncodeToString(k.XOnly[:])
+}
+
+// Leaf is a tapscript leaf, either pk(KEY) or multi_a(k,KEY,...)
+type Leaf struct {
+	Version   byte
+	Script    []byte
+	Keys      []Key
+	Threshold int
+	expr      string
+}
+
+func (l *Leaf) String() string {
+	return l.expr
+}
+
+// Tree is either a leaf or a branch with two children
+type Tree struct {
+	Leaf        *Leaf
+	Left, Right *Tree
+}
+
+func (t *Tree) String() string {
+	if t.Leaf != nil {
+		return t.Leaf.String()
+	}
+	return "{" + t.Left.String() + "," + t.Right.String() + "}"
+}
+
+// Descriptor is a parsed tr(KEY) or tr(KEY,TREE) output descriptor
+type Descriptor struct {
+	Internal Key
+	Tree     *Tree
+}
+
+// String returns the descriptor with its checksum
+func (d *Descriptor) String() string {
+	body := "tr(" + d.Internal.String()
+	if d.Tree != nil {
+		body += "," + d.Tree.String()
+	}
+	body += ")"
+
+	sum, _ := Checksum(body)
+	return body + "#" + sum
+}
+
+// Leaves returns the script leaves in depth first order
+func (d *Descriptor) Leaves() []*Leaf {
+	if d.Tree == nil {
+		return nil
+	}
+	return d.Tree.leaves()
+}
+
+// MerkleRoot returns the root of the script tree, or nil without one
+func (d *Descriptor) MerkleRoot() []byte {
+	if d.Tree == nil {
+		return nil
+	}
+	root := d.Tree.Hash()
+	return root[:]
+}
+
+// OutputKey computes the tweaked taproot output key Q
+func (d *Descriptor) OutputKey() (*btcec.PublicKey, error) {
+	return tweakPublicKey(d.Internal.XOnly, d.MerkleRoot())
+}
+
+// ScriptPubKey returns the segwit v1 output script OP_1 <Q>
+func (d *Descriptor) ScriptPubKey() ([]byte, error) {
+	Q, err := d.OutputKey()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{0x51, 0x20}, schnorr.SerializePubKey(Q)...), nil
+}
+
+// ControlBlock builds the witness control block for spending the leaf
+func (d *Descriptor) ControlBlock(leaf *Leaf) ([]byte, error) {
+	if d.Tree == nil {
+		return nil, fmt.Errorf("descriptor has no script tree")
+	}
+
+	path, ok := d.Tree.path(leaf)
+	if !ok {
+		return nil, fmt.Errorf("leaf %s is not part of the descriptor", leaf)
+	}
+
+	Q, err := d.OutputKey()
+	if err != nil {
+		return nil, err
+	}
+
+	parity := Q.SerializeCompressed()[0] & 1
+	cb := append([]byte{leaf.Version | parity}, d.Internal.XOnly[:]...)
+	for _, h := range path {
+		cb = append(cb, h[:]...)
+	}
+	return cb, nil
+}
+
+// Parse parses a taproot descriptor, checking the checksum if present
+func Parse(desc string) (*Descriptor, error) {
+	body, err := splitChecksum(strings.TrimSpace(desc))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{s: body}
+	if err := p.expect("tr("); err != nil {
+		return nil, err
+	}
+
+	d := new(Descriptor)
+	if d.Internal, err = p.key(); err != nil {
+		return nil, err
+	}
+
+	if p.consume(",") {
+		if d.Tree, err = p.tree(0); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("unexpected trailing data %q", p.s[p.pos:])
+	}
+
+	return d, nil
+}
+
+// maxDepth is the deepest script tree BIP-341 allows
+const maxDepth = 128
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) consume(token string) bool {
+	if strings.HasPrefix(p.s[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(token string) error {
+	if !p.consume(token) {
+		return fmt.Errorf("expected %q at position %d", token, p.pos)
+	}
+	return nil
+}
+
+// until returns everything up to the next delimiter
+func (p *parser) until(delims string) string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(delims, rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *parser) tree(depth int) (*Tree, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("script tree is deeper than %d", maxDepth)
+	}
+
+	if p.consume("{") {
+		left, err := p.tree(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		right, err := p.tree(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+		return &Tree{Left: left, Right: right}, nil
+	}
+
+	leaf, err := p.leaf()
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{Leaf: leaf}, nil
+}
+
+func (p *parser) leaf() (*Leaf, error) {
+	start := p.pos
+	leaf := &Leaf{Version: LeafVersionTapscript}
+
+	switch {
+	case p.consume("pk("):
+		k, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		leaf.Keys = []Key{k}
+		leaf.Threshold = 1
+		leaf.Script = append(append([]byte{0x20}, k.XOnly[:]...), opChecksig)
+	case p.consume("multi_a("):
+		threshold, err := strconv.Atoi(p.until(","))
+		if err != nil {
+			return nil, fmt.Errorf("invalid multi_a threshold: %v", err)
+		}
+		for p.consume(",") {
+			k, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			leaf.Keys = append(leaf.Keys, k)
+		}
+		if threshold < 1 || threshold > len(leaf.Keys) || len(leaf.Keys) > 999 {
+			return nil, fmt.Errorf("multi_a threshold %d is out of range for %d keys", threshold, len(leaf.Keys))
+		}
+		leaf.Threshold = threshold
+		leaf.Script = multiAScript(threshold, leaf.Keys)
+	default:
+		return nil, fmt.Errorf("unsupported script expression at position %d", p.pos)
+	}
+
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	leaf.expr = p.s[start:p.pos]
+	return leaf, nil
+}
+
+func (p *parser) key() (Key, error) {
+	var k Key
+
+	if p.consume("[") {
+		origin := p.until("]")
+		if err := p.expect("]"); err != nil {
+			return k, err
+		}
+		k.Origin = "[" + origin + "]"
+	}
+
+	s := p.until(",)}")
+	if strings.Contains(s, "pub") || strings.Contains(s, "prv") {
+		return k, fmt.Errorf("extended keys are not supported, use hex keys")
+	}
+
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return k, fmt.Errorf("invalid key %q: %v", s, err)
+	}
+
+	switch len(raw) {
+	case 32:
+		if _, err := schnorr.ParsePubKey(raw); err != nil {
+			return k, err
+		}
+		copy(k.XOnly[:], raw)
+	case 33:
+		if _, err := btcec.ParsePubKey(raw); err != nil {
+			return k, err
+		}
+		copy(k.XOnly[:], raw[1:])
+	default:
+		return k, fmt.Errorf("key %q must be 32 or 33 bytes", s)
+	}
+
+	return k, nil
+}
+
+func multiAScript(threshold int, keys []Key) []byte {
+	script := []byte{}
+	for i, k := range keys {
+		script = append(script, 0x20)
+		script = append(script, k.XOnly[:]...)
+		if i == 0 {
+			script = append(script, opChecksig)
+		} else {
+			script = append(script, opChecksigAdd)
+		}
+	}
+	script = append(script, pushNumber(threshold)...)
+	return append(script, opNumEqual)
+}
+
+// pushNumber returns the minimal push of a small positive number
+func pushNumber(n int) []byte {
+	if n <= 16 {
+		return []byte{0x50 + byte(n)}
+	}
+
+	num := []byte{}
+	for n > 0 {
+		num = append(num, byte(n&0xff))
+		n >>= 8
+	}
+	if num[len(num)-1]&0x80 != 0 {
+		num = append(num, 0)
+	}
+	return append([]byte{byte(len(num))}, num...)
+}
@@ -0,0 +1,159 @@
+package descriptor
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+func TestChecksum(t *testing.T) {
+	// example from BIP-380
+	observed, err := Checksum("raw(deadbeef)")
+	if err != nil {
+		t.Fatalf("Unexpected error from Checksum: %v", err)
+	}
+
+	// then
+	if observed != "89f8spxm" {
+		t.Fatalf("Checksum(raw(deadbeef)) = %s, want 89f8spxm", observed)
+	}
+}
+
+func TestOutputKey(t *testing.T) {
+	// BIP-341 wallet test vectors
+	testCases := []struct {
+		desc     string
+		output   string
+		leafHash string
+	}{
+		{
+			"tr(d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d)",
+			"53a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343",
+			"",
+		},
+		{
+			"tr(187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27,pk(d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8))",
+			"147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+			"5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21",
+		},
+	}
+
+	for _, test := range testCases {
+		// given
+		d, err := Parse(test.desc)
+		if err != nil {
+			t.Fatalf("Unexpected error from Parse(%s): %v", test.desc, err)
+		}
+
+		// when
+		Q, err := d.OutputKey()
+		if err != nil {
+			t.Fatalf("Unexpected error from OutputKey(%s): %v", test.desc, err)
+		}
+
+		// then
+		observed := hex.EncodeToString(schnorr.SerializePubKey(Q))
+		if observed != test.output {
+			t.Fatalf("OutputKey(%s) = %s, want %s", test.desc, observed, test.output)
+		}
+
+		if test.leafHash != "" {
+			h := d.Leaves()[0].Hash()
+			if hex.EncodeToString(h[:]) != test.leafHash {
+				t.Fatalf("leaf hash of %s = %x, want %s", test.desc, h, test.leafHash)
+			}
+		}
+
+		// round trip through the checksummed form
+		again, err := Parse(d.String())
+		if err != nil {
+			t.Fatalf("Unexpected error from Parse(%s): %v", d, err)
+		}
+		if again.String() != d.String() {
+			t.Fatalf("Parse(%s).String() = %s", d, again)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	invalid := []string{
+		"tr(d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d)#00000000",
+		"wpkh(02d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d)",
+		"tr(xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8)",
+		"tr(d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d,{pk(d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d)})",
+		"tr(d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d,multi_a(3,d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d))",
+	}
+
+	for _, desc := range invalid {
+		if _, err := Parse(desc); err == nil {
+			t.Fatalf("Parse(%s) succeeded, want error", desc)
+		}
+	}
+}
+
+func TestSignRouter(t *testing.T) {
+	internal, _ := btcec.NewPrivateKey()
+	alice, _ := btcec.NewPrivateKey()
+	bob, _ := btcec.NewPrivateKey()
+	carol, _ := btcec.NewPrivateKey()
+
+	x := func(k *btcec.PrivateKey) string {
+		return hex.EncodeToString(schnorr.SerializePubKey(k.PubKey()))
+	}
+
+	desc := "tr(" + x(internal) + ",{pk(" + x(alice) + "),multi_a(2," + x(bob) + "," + x(carol) + ")})"
+	d, err := Parse(desc)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse(%s): %v", desc, err)
+	}
+
+	sigHash := func(leaf *Leaf) ([]byte, error) {
+		if leaf == nil {
+			return make([]byte, 32), nil
+		}
+		h := leaf.Hash()
+		return h[:], nil
+	}
+
+	t.Run("Internal key signs on the key path", func(t *testing.T) {
+		result, err := d.Sign([]*btcec.PrivateKey{alice, internal}, sigHash)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		if !result.KeyPath || len(result.Signatures) != 1 {
+			t.Fatalf("Sign() = %+v, want a key path signature", result)
+		}
+	})
+
+	t.Run("Leaf keys sign on the script path", func(t *testing.T) {
+		result, err := d.Sign([]*btcec.PrivateKey{carol, bob}, sigHash)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		if result.KeyPath || result.Leaf != d.Leaves()[1] || len(result.Signatures) != 2 {
+			t.Fatalf("Sign() = %+v, want the multi_a leaf", result)
+		}
+
+		// control block is version|parity, internal key and one sibling
+		if len(result.ControlBlock) != 1+32+32 || result.ControlBlock[0]&0xfe != LeafVersionTapscript {
+			t.Fatalf("ControlBlock = %x", result.ControlBlock)
+		}
+
+		msg, _ := sigHash(result.Leaf)
+		for key, sigBytes := range result.Signatures {
+			pubKey, _ := schnorr.ParsePubKey(key[:])
+			sig, _ := schnorr.ParseSignature(sigBytes)
+			if !sig.Verify(msg, pubKey) {
+				t.Fatalf("script path signature by %x does not verify", key)
+			}
+		}
+	})
+
+	t.Run("Not enough keys", func(t *testing.T) {
+		if _, err := d.Sign([]*btcec.PrivateKey{bob}, sigHash); err == nil {
+			t.Fatalf("Sign with one of two multi_a keys succeeded, want error")
+		}
+	})
+}
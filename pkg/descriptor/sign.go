@@ -0,0 +1,112 @@
+package descriptor
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// SigHashFunc returns the message to sign, either for the key path when
+// leaf is nil or for a script path spend of leaf
+type SigHashFunc func(leaf *Leaf) ([]byte, error)
+
+// SignResult is what the router produced for an output. For the key path
+// Signatures holds a single entry under the output key.
+type SignResult struct {
+	KeyPath      bool
+	Leaf         *Leaf
+	ControlBlock []byte
+	Signatures   map[[32]byte][]byte
+}
+
+// Sign picks the spending path for the descriptor output that the given keys
+// can satisfy, preferring the key path, and produces the BIP-340 signatures
+func (d *Descriptor) Sign(keys []*btcec.PrivateKey, sigHash SigHashFunc) (*SignResult, error) {
+	held := map[[32]byte]*btcec.PrivateKey{}
+	for _, k := range keys {
+		var x [32]byte
+		copy(x[:], schnorr.SerializePubKey(k.PubKey()))
+		held[x] = k
+	}
+
+	// key path: we hold the internal key, sign with it tweaked
+	if privKey, ok := held[d.Internal.XOnly]; ok {
+		return d.signKeyPath(privKey, sigHash)
+	}
+
+	// script path: the first leaf we have enough keys for
+	for _, leaf := range d.Leaves() {
+		signers := []*btcec.PrivateKey{}
+		for _, k := range leaf.Keys {
+			if privKey, ok := held[k.XOnly]; ok {
+				signers = append(signers, privKey)
+			}
+		}
+		if len(signers) < leaf.Threshold {
+			continue
+		}
+
+		return d.signScriptPath(leaf, signers[:leaf.Threshold], sigHash)
+	}
+
+	return nil, fmt.Errorf("none of the keys can spend %s", d)
+}
+
+func (d *Descriptor) signKeyPath(privKey *btcec.PrivateKey, sigHash SigHashFunc) (*SignResult, error) {
+	msg, err := sigHash(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tweaked, err := tweakPrivateKey(privKey, d.MerkleRoot())
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := schnorr.Sign(tweaked, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// make sure the tweak we applied matches the output the descriptor
+	// describes before handing the signature back
+	Q, err := d.OutputKey()
+	if err != nil {
+		return nil, err
+	}
+	if !sig.Verify(msg, Q) {
+		return nil, fmt.Errorf("key path signature does not verify against the output key")
+	}
+
+	var q [32]byte
+	copy(q[:], schnorr.SerializePubKey(Q))
+
+	return &SignResult{KeyPath: true, Signatures: map[[32]byte][]byte{q: sig.Serialize()}}, nil
+}
+
+func (d *Descriptor) signScriptPath(leaf *Leaf, signers []*btcec.PrivateKey, sigHash SigHashFunc) (*SignResult, error) {
+	msg, err := sigHash(leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	cb, err := d.ControlBlock(leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SignResult{Leaf: leaf, ControlBlock: cb, Signatures: map[[32]byte][]byte{}}
+	for _, privKey := range signers {
+		sig, err := schnorr.Sign(privKey, msg)
+		if err != nil {
+			return nil, err
+		}
+
+		var x [32]byte
+		copy(x[:], schnorr.SerializePubKey(privKey.PubKey()))
+		result.Signatures[x] = sig.Serialize()
+	}
+
+	return result, nil
+}
@@ -0,0 +1,125 @@
+package descriptor
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	sg "github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0341.mediawiki
+//
+
+// LeafVersionTapscript is the leaf version of BIP-342 scripts
+const LeafVersionTapscript = 0xc0
+
+var (
+	tagTapLeaf   = []byte("TapLeaf")
+	tagTapBranch = []byte("TapBranch")
+)
+
+// Hash computes the tagged TapLeaf hash of the leaf
+func (l *Leaf) Hash() [32]byte {
+	return *chainhash.TaggedHash(tagTapLeaf, []byte{l.Version}, compactSize(len(l.Script)), l.Script)
+}
+
+// Hash computes the hash of the subtree, the leaf hash for leaves and the
+// TapBranch hash of the sorted children otherwise
+func (t *Tree) Hash() [32]byte {
+	if t.Leaf != nil {
+		return t.Leaf.Hash()
+	}
+
+	left, right := t.Left.Hash(), t.Right.Hash()
+	if bytes.Compare(left[:], right[:]) > 0 {
+		left, right = right, left
+	}
+	return *chainhash.TaggedHash(tagTapBranch, left[:], right[:])
+}
+
+// path returns the sibling hashes from the leaf up to the root, and false
+// if the leaf is not in the tree
+func (t *Tree) path(leaf *Leaf) ([][32]byte, bool) {
+	if t.Leaf != nil {
+		return nil, t.Leaf == leaf
+	}
+
+	if p, ok := t.Left.path(leaf); ok {
+		return append(p, t.Right.Hash()), true
+	}
+	if p, ok := t.Right.path(leaf); ok {
+		return append(p, t.Left.Hash()), true
+	}
+	return nil, false
+}
+
+func (t *Tree) leaves() []*Leaf {
+	if t.Leaf != nil {
+		return []*Leaf{t.Leaf}
+	}
+	return append(t.Left.leaves(), t.Right.leaves()...)
+}
+
+// tweakPublicKey computes Q = lift_x(P) + t·G
+func tweakPublicKey(internal [32]byte, merkleRoot []byte) (*btcec.PublicKey, error) {
+	P, err := schnorr.ParsePubKey(internal[:])
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := sg.TapTweak(internal, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var p, tG, q btcec.JacobianPoint
+	P.AsJacobian(&p)
+	btcec.ScalarBaseMultNonConst(&t, &tG)
+	btcec.AddNonConst(&p, &tG, &q)
+	if (q.X.IsZero() && q.Y.IsZero()) || q.Z.IsZero() {
+		return nil, fmt.Errorf("tweaked key is the point at infinity")
+	}
+
+	q.ToAffine()
+	return btcec.NewPublicKey(&q.X, &q.Y), nil
+}
+
+// tweakPrivateKey returns the key that signs for Q on the key path
+func tweakPrivateKey(privKey *btcec.PrivateKey, merkleRoot []byte) (*btcec.PrivateKey, error) {
+	d := privKey.Key
+
+	// BIP-340 keys are implicitly even, so an odd internal key is negated
+	pubBytes := privKey.PubKey().SerializeCompressed()
+	if pubBytes[0] == 0x03 {
+		d.Negate()
+	}
+
+	var internal [32]byte
+	copy(internal[:], pubBytes[1:])
+
+	t, err := sg.TapTweak(internal, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Add(&t)
+	if d.IsZero() {
+		return nil, fmt.Errorf("tweaked private key is zero")
+	}
+	return btcec.PrivKeyFromScalar(&d), nil
+}
+
+func compactSize(n int) []byte {
+	switch {
+	case n < 0xfd:
+		return []byte{byte(n)}
+	case n <= 0xffff:
+		return []byte{0xfd, byte(n), byte(n >> 8)}
+	default:
+		return []byte{0xfe, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}
+	}
+}
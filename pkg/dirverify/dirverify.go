@@ -0,0 +1,268 @@
+package dirverify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/archive"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/sigfile"
+)
+
+//
+// Checking a whole tree of signed files, such as an artifact mirror or a
+// backup. A tree is signed either file by file, with a sidecar next to each
+// one in the sigfile format sign -detach writes, or all at once with a
+// signed archive manifest at the top of the tree.
+//
+
+// SidecarExt is appended to a file's name to get its sidecar
+const SidecarExt = sigfile.Ext
+
+// Status is the outcome for one path
+type Status string
+
+const (
+	// StatusOK is a file whose signature or manifest digest checks out
+	StatusOK Status = "ok"
+
+	// StatusBadSignature is a file whose sidecar does not verify
+	StatusBadSignature Status = "bad signature"
+
+	// StatusModified is a file whose digest differs from the manifest
+	StatusModified Status = "modified"
+
+	// StatusUnsigned is a file with no sidecar or manifest entry
+	StatusUnsigned Status = "unsigned"
+
+	// StatusMissing is a signed file which is not in the tree
+	StatusMissing Status = "missing"
+
+	// StatusError is a file that could not be read
+	StatusError Status = "error"
+)
+
+// Result is the status of one path, relative to the tree with forward
+// slashes
+type Result struct {
+	Path   string
+	Status Status
+	Err    error
+}
+
+// OK is true if every result is StatusOK
+func OK(results []Result) bool {
+	for _, r := range results {
+		if r.Status != StatusOK {
+			return false
+		}
+	}
+	return true
+}
+
+// Summary counts the results by status
+func Summary(results []Result) map[Status]int {
+	counts := map[Status]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	return counts
+}
+
+func hashFile(p string) ([32]byte, error) {
+	var digest [32]byte
+	f, err := os.Open(p)
+	if err != nil {
+		return digest, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return digest, err
+	}
+	copy(digest[:], h.Sum(nil))
+	return digest, nil
+}
+
+// SignFile writes the sidecar for the file at p, signing it as sign -detach
+// does
+func SignFile(privatekey *big.Int, p string) error {
+	digest, err := sigfile.Digest(p)
+	if err != nil {
+		return err
+	}
+	sig, err := schnorr.SignScheme(sigfile.Scheme, privatekey, digest)
+	if err != nil {
+		return err
+	}
+	return WriteSidecar(p, sig)
+}
+
+// WriteSidecar writes sig as the sidecar of the file at p. It is written
+// under a temporary name and renamed, so a verifier never sees half of it.
+func WriteSidecar(p string, sig [64]byte) error {
+	tmp := filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+SidecarExt+".tmp")
+	if err := os.WriteFile(tmp, sigfile.Encode(sig[:], false), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p+SidecarExt)
+}
+
+// VerifyFile checks the file at p against its sidecar
+func VerifyFile(publickey [33]byte, p string) Result {
+	r := Result{Path: filepath.ToSlash(p)}
+
+	sig, err := sigfile.Read(p)
+	if os.IsNotExist(err) {
+		r.Status = StatusUnsigned
+		return r
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		r.Status, r.Err = StatusError, err
+		return r
+	}
+	if err != nil {
+		r.Status, r.Err = StatusBadSignature, fmt.Errorf("sidecar: %v", err)
+		return r
+	}
+
+	digest, err := sigfile.Digest(p)
+	if err != nil {
+		r.Status, r.Err = StatusError, err
+		return r
+	}
+	if ok, err := schnorr.VerifyScheme(sigfile.Scheme, publickey[:], digest, sig); !ok {
+		if err == nil {
+			err = fmt.Errorf("signature does not verify")
+		}
+		r.Status, r.Err = StatusBadSignature, err
+		return r
+	}
+
+	r.Status = StatusOK
+	return r
+}
+
+// files lists the regular files under dir, relative and sorted
+func files(dir string) ([]string, error) {
+	list := []string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		list = append(list, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(list)
+	return list, err
+}
+
+// VerifyTree checks every file under dir. With an archive manifest at the
+// top of the tree the files are checked against it, otherwise each file
+// against its sidecar. The error is for problems with the tree as a whole,
+// such as a manifest that does not verify.
+func VerifyTree(dir string, publickey [33]byte) ([]Result, error) {
+	signed, err := os.ReadFile(filepath.Join(dir, archive.ManifestName))
+	if err == nil {
+		return verifyManifest(dir, signed, publickey)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	list, err := files(dir)
+	if err != nil {
+		return nil, err
+	}
+	present := map[string]bool{}
+	for _, name := range list {
+		present[name] = true
+	}
+
+	results := []Result{}
+	for _, name := range list {
+		if strings.HasSuffix(name, SidecarExt) {
+			// a sidecar whose file is gone
+			if target := strings.TrimSuffix(name, SidecarExt); !present[target] {
+				results = append(results, Result{Path: target, Status: StatusMissing})
+			}
+			continue
+		}
+
+		r := VerifyFile(publickey, filepath.Join(dir, filepath.FromSlash(name)))
+		r.Path = name
+		results = append(results, r)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+	return results, nil
+}
+
+func verifyManifest(dir string, signed []byte, publickey [33]byte) ([]Result, error) {
+	m, err := archive.ReadManifest(signed, publickey)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := files(dir)
+	if err != nil {
+		return nil, err
+	}
+	present := map[string]bool{}
+	for _, name := range list {
+		present[name] = true
+	}
+
+	listed := map[string]bool{archive.ManifestName: true}
+	results := []Result{}
+	for _, entry := range m.Entries {
+		if entry.Dir {
+			continue
+		}
+		listed[entry.Path] = true
+
+		r := Result{Path: entry.Path}
+		if !present[entry.Path] {
+			r.Status = StatusMissing
+			results = append(results, r)
+			continue
+		}
+		digest, err := hashFile(filepath.Join(dir, filepath.FromSlash(entry.Path)))
+		switch {
+		case err != nil:
+			r.Status, r.Err = StatusError, err
+		case hex.EncodeToString(digest[:]) != entry.SHA256:
+			r.Status = StatusModified
+		default:
+			r.Status = StatusOK
+		}
+		results = append(results, r)
+	}
+
+	for _, name := range list {
+		if !listed[name] {
+			results = append(results, Result{Path: name, Status: StatusUnsigned})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+	return results, nil
+}
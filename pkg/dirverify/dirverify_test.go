@@ -0,0 +1,124 @@
+package dirverify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/archive"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func write(t *testing.T, dir, name, content string) string {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatalf("Unexpected error from MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("Unexpected error from WriteFile: %v", err)
+	}
+	return p
+}
+
+func statuses(results []Result) map[string]Status {
+	m := map[string]Status{}
+	for _, r := range results {
+		m[r.Path] = r.Status
+	}
+	return m
+}
+
+func TestSidecars(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("dirverify"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"a.tar.gz", "sub/b.iso", "sub/deep/c.bin", "gone.txt"} {
+		if err := SignFile(keys[0].PrivateKey, write(t, dir, name, name)); err != nil {
+			t.Fatalf("Unexpected error from SignFile: %v", err)
+		}
+	}
+	write(t, dir, "sub/b.iso", "tampered")
+	write(t, dir, "unsigned.txt", "hello")
+	os.Remove(filepath.Join(dir, "gone.txt"))
+	SignFile(keys[1].PrivateKey, filepath.Join(dir, "sub/deep/c.bin"))
+
+	// when
+	results, err := VerifyTree(dir, keys[0].PublicKey)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from VerifyTree: %v", err)
+	}
+	want := map[string]Status{
+		"a.tar.gz":       StatusOK,
+		"gone.txt":       StatusMissing,
+		"sub/b.iso":      StatusBadSignature,
+		"sub/deep/c.bin": StatusBadSignature,
+		"unsigned.txt":   StatusUnsigned,
+	}
+	got := statuses(results)
+	if len(got) != len(want) {
+		t.Fatalf("VerifyTree() = %v, want %v", got, want)
+	}
+	for path, status := range want {
+		if got[path] != status {
+			t.Fatalf("VerifyTree() %s = %q, want %q", path, got[path], status)
+		}
+	}
+	if OK(results) {
+		t.Fatalf("OK() = true, want false")
+	}
+	if counts := Summary(results); counts[StatusBadSignature] != 2 {
+		t.Fatalf("Summary() = %v, want 2 bad signatures", counts)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("dirverify"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	dir := t.TempDir()
+	write(t, dir, "x/one", "1")
+	write(t, dir, "two", "2")
+	_, signed, err := archive.SignManifest(dir, keys[0].PrivateKey)
+	if err != nil {
+		t.Fatalf("Unexpected error from SignManifest: %v", err)
+	}
+	write(t, dir, archive.ManifestName, string(signed))
+
+	// when
+	results, err := VerifyTree(dir, keys[0].PublicKey)
+
+	// then
+	if err != nil || !OK(results) || len(results) != 2 {
+		t.Fatalf("VerifyTree() = %v, %v, want two ok files", results, err)
+	}
+
+	t.Run("Changes are reported", func(t *testing.T) {
+		write(t, dir, "two", "22")
+		write(t, dir, "three", "3")
+		got := statuses(mustVerify(t, dir, keys[0].PublicKey))
+		if got["two"] != StatusModified || got["three"] != StatusUnsigned || got["x/one"] != StatusOK {
+			t.Fatalf("VerifyTree() = %v, want two modified and three unsigned", got)
+		}
+	})
+
+	t.Run("Wrong key fails", func(t *testing.T) {
+		if _, err := VerifyTree(dir, keys[1].PublicKey); err == nil {
+			t.Fatalf("VerifyTree() with another key = nil, want error")
+		}
+	})
+}
+
+func mustVerify(t *testing.T, dir string, publickey [33]byte) []Result {
+	results, err := VerifyTree(dir, publickey)
+	if err != nil {
+		t.Fatalf("Unexpected error from VerifyTree: %v", err)
+	}
+	return results
+}
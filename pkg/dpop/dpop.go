@@ -0,0 +1,346 @@
+package dpop
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/store"
+)
+
+//
+// https://www.rfc-editor.org/rfc/rfc9449.html proofs signed with the Schnorr
+// keys of this repo. JOSE has no algorithm for them, so proofs carry
+// Algorithm, named after ES256K, and only servers using this package will
+// accept them.
+//
+
+// Algorithm is the alg header of the proofs
+const Algorithm = "SS256K"
+
+// Type is the typ header of the proofs
+const Type = "dpop+jwt"
+
+// Header is the JOSE header of a proof
+type Header struct {
+	Typ string `json:"typ"`
+	Alg string `json:"alg"`
+	JWK *JWK   `json:"jwk"`
+}
+
+// Claims are the claims of a proof. Ath is the hash of the access token the
+// proof is sent with and Nonce the last nonce the server handed out.
+type Claims struct {
+	JTI   string `json:"jti"`
+	HTM   string `json:"htm"`
+	HTU   string `json:"htu"`
+	IAT   int64  `json:"iat"`
+	Ath   string `json:"ath,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// Option sets an optional claim on a proof
+type Option func(*Claims)
+
+// WithAccessToken binds the proof to the access token it is sent with
+func WithAccessToken(token string) Option {
+	return func(c *Claims) {
+		c.Ath = AccessTokenHash(token)
+	}
+}
+
+// WithNonce includes a nonce the server handed out in a DPoP-Nonce header
+func WithNonce(nonce string) Option {
+	return func(c *Claims) {
+		c.Nonce = nonce
+	}
+}
+
+// withTime sets iat, for tests
+func withTime(t time.Time) Option {
+	return func(c *Claims) {
+		c.IAT = t.Unix()
+	}
+}
+
+// AccessTokenHash is the ath claim for the token
+func AccessTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// normalizeHTU drops the query and fragment and lowercases the scheme and
+// host, which is how htu is compared
+func normalizeHTU(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return "", fmt.Errorf("htu %q is not an absolute url", uri)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String(), nil
+}
+
+// NewProof makes a proof for a request with the method to the uri, signed
+// with the private key
+func NewProof(privatekey *big.Int, method, uri string, opts ...Option) (string, error) {
+	publickey, err := schnorr.ScalarBaseMult(privatekey).PublicKey()
+	if err != nil {
+		return "", err
+	}
+	jwk, err := NewJWK(publickey)
+	if err != nil {
+		return "", err
+	}
+	htu, err := normalizeHTU(uri)
+	if err != nil {
+		return "", err
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	claims := &Claims{
+		JTI: base64.RawURLEncoding.EncodeToString(jti),
+		HTM: method,
+		HTU: htu,
+		IAT: time.Now().Unix(),
+	}
+	for _, opt := range opts {
+		opt(claims)
+	}
+
+	header, err := json.Marshal(&Header{Typ: Type, Alg: Algorithm, JWK: jwk})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := schnorr.Sign(privatekey, sha256.Sum256([]byte(signingInput)))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig[:]), nil
+}
+
+// Proof is a validated proof
+type Proof struct {
+	Header    Header
+	Claims    Claims
+	PublicKey [33]byte
+	JKT       string
+}
+
+// Parse decodes a proof and checks its signature against the key in its
+// header, nothing else
+func Parse(proof string) (*Proof, error) {
+	parts := strings.Split(proof, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("proof is not a compact jws")
+	}
+
+	p := &Proof{}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("proof header: %v", err)
+	}
+	if err := json.Unmarshal(header, &p.Header); err != nil {
+		return nil, fmt.Errorf("proof header: %v", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("proof claims: %v", err)
+	}
+	if err := json.Unmarshal(payload, &p.Claims); err != nil {
+		return nil, fmt.Errorf("proof claims: %v", err)
+	}
+
+	if p.Header.Typ != Type {
+		return nil, fmt.Errorf("proof typ is %q, want %s", p.Header.Typ, Type)
+	}
+	if p.Header.Alg != Algorithm {
+		return nil, fmt.Errorf("proof alg is %q, want %s", p.Header.Alg, Algorithm)
+	}
+	if p.Header.JWK == nil {
+		return nil, fmt.Errorf("proof has no jwk")
+	}
+	// a private key in the header would have a "d" member, which the JWK
+	// type drops, so check the raw header as well
+	var raw struct {
+		JWK map[string]interface{} `json:"jwk"`
+	}
+	if err := json.Unmarshal(header, &raw); err != nil || raw.JWK["d"] != nil {
+		return nil, fmt.Errorf("proof jwk must be a public key")
+	}
+	if p.PublicKey, err = p.Header.JWK.PublicKey(); err != nil {
+		return nil, err
+	}
+	p.JKT = p.Header.JWK.Thumbprint()
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return nil, fmt.Errorf("proof signature is not 64 bytes of base64url")
+	}
+	var signature [64]byte
+	copy(signature[:], sig)
+	ok, err := schnorr.Verify(p.PublicKey, sha256.Sum256([]byte(parts[0]+"."+parts[1])), signature)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("proof signature does not verify")
+	}
+	return p, nil
+}
+
+// ErrReplay is returned for a proof whose jti has been seen before
+var ErrReplay = errors.New("proof has already been used")
+
+// ReplayCache remembers the jti of every accepted proof until it expires.
+// Use must check and record atomically, so the same proof presented twice
+// at once is only accepted once.
+type ReplayCache interface {
+	Use(jti string, expires time.Time) error
+}
+
+// MemoryReplayCache keeps the jtis in memory
+type MemoryReplayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	now  func() time.Time
+}
+
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{seen: map[string]time.Time{}, now: time.Now}
+}
+
+func (c *MemoryReplayCache) Use(jti string, expires time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for id, until := range c.seen {
+		if now.After(until) {
+			delete(c.seen, id)
+		}
+	}
+	if _, ok := c.seen[jti]; ok {
+		return fmt.Errorf("%w: jti %s", ErrReplay, jti)
+	}
+	c.seen[jti] = expires
+	return nil
+}
+
+// StateReplayCache keeps the jtis in a daemon state store, so a proof
+// accepted by one replica sharing it is refused by the others
+type StateReplayCache struct {
+	State store.Store
+}
+
+// jtiBucket is the state store bucket of the jtis
+const jtiBucket = "dpop-jti"
+
+func (c *StateReplayCache) Use(jti string, expires time.Time) error {
+	err := c.State.Create(context.Background(), jtiBucket, jti, nil, expires)
+	if err == store.ErrExists {
+		return fmt.Errorf("%w: jti %s", ErrReplay, jti)
+	}
+	return err
+}
+
+// Request is what a proof is checked against. AccessToken, JKT and Nonce
+// are only checked when set; JKT is the cnf.jkt of the access token.
+type Request struct {
+	Method      string
+	URI         string
+	AccessToken string
+	JKT         string
+	Nonce       string
+}
+
+// Validator checks proofs. A proof is accepted if its iat is within Window
+// of now, and its jti is then remembered for as long as the proof would be
+// accepted.
+type Validator struct {
+	Window time.Duration
+	Replay ReplayCache
+	Now    func() time.Time
+}
+
+// NewValidator accepts proofs issued within a minute either side of now
+func NewValidator() *Validator {
+	return &Validator{Window: time.Minute, Replay: NewMemoryReplayCache(), Now: time.Now}
+}
+
+// Validate checks the proof sent with a request, per section 4.3 of the RFC
+func (v *Validator) Validate(proof string, req Request) (*Proof, error) {
+	p, err := Parse(proof)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Claims.JTI == "" {
+		return nil, fmt.Errorf("proof has no jti")
+	}
+	if p.Claims.HTM != req.Method {
+		return nil, fmt.Errorf("proof is for %s, request is %s", p.Claims.HTM, req.Method)
+	}
+	htu, err := normalizeHTU(req.URI)
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := normalizeHTU(p.Claims.HTU)
+	if err != nil || claimed != htu {
+		return nil, fmt.Errorf("proof is for %s, request is to %s", p.Claims.HTU, htu)
+	}
+
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	iat := time.Unix(p.Claims.IAT, 0)
+	if d := now().Sub(iat); d > v.Window || d < -v.Window {
+		return nil, fmt.Errorf("proof was issued at %s, outside the %s window", iat.UTC().Format(time.RFC3339), v.Window)
+	}
+
+	if req.AccessToken != "" {
+		if subtle.ConstantTimeCompare([]byte(p.Claims.Ath), []byte(AccessTokenHash(req.AccessToken))) != 1 {
+			return nil, fmt.Errorf("proof is not bound to the access token")
+		}
+	}
+	if req.JKT != "" && p.JKT != req.JKT {
+		return nil, fmt.Errorf("proof key %s is not the one the access token is bound to", p.JKT)
+	}
+	if req.Nonce != "" && p.Claims.Nonce != req.Nonce {
+		return nil, fmt.Errorf("proof does not carry the current nonce")
+	}
+
+	// only remember proofs that passed every other check, so garbage can't
+	// fill the cache
+	if v.Replay != nil {
+		if err := v.Replay.Use(p.Claims.JTI, iat.Add(v.Window)); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,115 @@
+package dpop
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/store"
+)
+
+func TestJWK(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("dpop"), 4)
+	for _, k := range keys {
+		// given
+		jwk, err := NewJWK(k.PublicKey)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewJWK: %v", err)
+		}
+
+		// when
+		observed, err := jwk.PublicKey()
+
+		// then
+		if err != nil {
+			t.Fatalf("Unexpected error from PublicKey: %v", err)
+		}
+		if observed != k.PublicKey {
+			t.Fatalf("PublicKey() = %x, want %x", observed, k.PublicKey)
+		}
+		if len(jwk.Thumbprint()) != 43 {
+			t.Fatalf("Thumbprint() = %s, want 43 characters of base64url", jwk.Thumbprint())
+		}
+	}
+
+	bad := &JWK{Kty: "EC", Crv: "secp256k1", X: strings.Repeat("A", 43), Y: strings.Repeat("A", 43)}
+	if _, err := bad.PublicKey(); err == nil {
+		t.Fatalf("PublicKey of a point off the curve succeeded, want error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("dpop"), 2)
+	now := time.Unix(1700000000, 0)
+	jkt, _ := Thumbprint(keys[0].PublicKey)
+	req := Request{Method: "POST", URI: "https://Server.example.com/token?x=1", AccessToken: "token", JKT: jkt, Nonce: "n-1"}
+
+	proof := func(t *testing.T, privatekey int, method, uri string, opts ...Option) string {
+		opts = append([]Option{withTime(now)}, opts...)
+		p, err := NewProof(keys[privatekey].PrivateKey, method, uri, opts...)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewProof: %v", err)
+		}
+		return p
+	}
+	validator := func() *Validator {
+		clock := func() time.Time { return now.Add(10 * time.Second) }
+		cache := NewMemoryReplayCache()
+		cache.now = clock
+		return &Validator{Window: time.Minute, Replay: cache, Now: clock}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		v := validator()
+		p := proof(t, 0, "POST", "https://server.example.com/token", WithAccessToken("token"), WithNonce("n-1"))
+
+		observed, err := v.Validate(p, req)
+		if err != nil {
+			t.Fatalf("Unexpected error from Validate: %v", err)
+		}
+		if observed.JKT != jkt || observed.PublicKey != keys[0].PublicKey {
+			t.Fatalf("Validate() = %+v, want key %x", observed, keys[0].PublicKey)
+		}
+
+		if _, err := v.Validate(p, req); !errors.Is(err, ErrReplay) {
+			t.Fatalf("Validate of a replayed proof = %v, want ErrReplay", err)
+		}
+	})
+
+	t.Run("Replayed to another replica", func(t *testing.T) {
+		state := store.NewMemory()
+		first := &Validator{Window: time.Minute, Replay: &StateReplayCache{State: state}}
+		second := &Validator{Window: time.Minute, Replay: &StateReplayCache{State: state}}
+		p, err := NewProof(keys[0].PrivateKey, "POST", "https://server.example.com/token", WithAccessToken("token"), WithNonce("n-1"))
+		if err != nil {
+			t.Fatalf("Unexpected error from NewProof: %v", err)
+		}
+
+		if _, err := first.Validate(p, req); err != nil {
+			t.Fatalf("Unexpected error from Validate: %v", err)
+		}
+		if _, err := second.Validate(p, req); !errors.Is(err, ErrReplay) {
+			t.Fatalf("Validate of a proof replayed to another replica = %v, want ErrReplay", err)
+		}
+	})
+
+	failures := map[string]string{
+		"Wrong method":  proof(t, 0, "GET", "https://server.example.com/token", WithAccessToken("token"), WithNonce("n-1")),
+		"Wrong uri":     proof(t, 0, "POST", "https://server.example.com/other", WithAccessToken("token"), WithNonce("n-1")),
+		"Wrong token":   proof(t, 0, "POST", "https://server.example.com/token", WithAccessToken("other"), WithNonce("n-1")),
+		"Wrong key":     proof(t, 1, "POST", "https://server.example.com/token", WithAccessToken("token"), WithNonce("n-1")),
+		"Stale nonce":   proof(t, 0, "POST", "https://server.example.com/token", WithAccessToken("token"), WithNonce("n-0")),
+		"Too old":       proof(t, 0, "POST", "https://server.example.com/token", WithAccessToken("token"), WithNonce("n-1"), withTime(now.Add(-time.Hour))),
+		"Bad signature": proof(t, 0, "POST", "https://server.example.com/token", WithAccessToken("token"), WithNonce("n-1")) + "A",
+		"Not a jws":     "abc.def",
+	}
+	for name, p := range failures {
+		t.Run(name, func(t *testing.T) {
+			if _, err := validator().Validate(p, req); err == nil {
+				t.Fatalf("Validate succeeded, want error")
+			}
+		})
+	}
+}
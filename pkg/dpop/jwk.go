@@ -0,0 +1,79 @@
+package dpop
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// JWK is the public JSON Web Key of a secp256k1 key, as registered for
+// ES256K in RFC 8812
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWK returns the JWK of the compressed public key
+func NewJWK(publickey [33]byte) (*JWK, error) {
+	p, err := schnorr.ParsePoint(publickey)
+	if err != nil {
+		return nil, err
+	}
+	return &JWK{
+		Kty: "EC",
+		Crv: "secp256k1",
+		X:   base64.RawURLEncoding.EncodeToString(pad32(p.X().Bytes())),
+		Y:   base64.RawURLEncoding.EncodeToString(pad32(p.Y().Bytes())),
+	}, nil
+}
+
+func pad32(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+// PublicKey returns the compressed public key of the JWK, checking the point
+// is on the curve
+func (k *JWK) PublicKey() ([33]byte, error) {
+	var publickey [33]byte
+	if k.Kty != "EC" || k.Crv != "secp256k1" {
+		return publickey, fmt.Errorf("jwk is %s %s, want EC secp256k1", k.Kty, k.Crv)
+	}
+	x, errX := base64.RawURLEncoding.DecodeString(k.X)
+	y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		return publickey, fmt.Errorf("jwk coordinates are not 32 bytes of base64url")
+	}
+
+	publickey[0] = 0x02 | y[31]&1
+	copy(publickey[1:], x)
+	p, err := schnorr.ParsePoint(publickey)
+	if err != nil {
+		return publickey, err
+	}
+	if string(pad32(p.Y().Bytes())) != string(y) {
+		return publickey, fmt.Errorf("jwk is not a point on the curve")
+	}
+	return publickey, nil
+}
+
+// Thumbprint is the RFC 7638 thumbprint of the JWK, the jkt an access token
+// is bound to: the base64url sha256 of the required members in
+// lexicographic order with no whitespace
+func (k *JWK) Thumbprint() string {
+	canonical := fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k.Crv, k.Kty, k.X, k.Y)
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Thumbprint is the jkt of the public key
+func Thumbprint(publickey [33]byte) (string, error) {
+	jwk, err := NewJWK(publickey)
+	if err != nil {
+		return "", err
+	}
+	return jwk.Thumbprint(), nil
+}
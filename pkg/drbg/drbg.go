@@ -0,0 +1,103 @@
+package drbg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+//
+// HMAC_DRBG from NIST SP 800-90A section 10.1.2 using SHA-256, without
+// reseeding or prediction resistance. The same seed always produces the
+// same stream, which is the whole point: it must never be used for keys
+// that protect anything.
+//
+// https://nvlpubs.nist.gov/nistpubs/SpecialPublications/NIST.SP.800-90Ar1.pdf
+//
+
+// maxRequest is the most bytes a single generate call may return
+const maxRequest = 1 << 16
+
+// DRBG is a deterministic random bit generator
+type DRBG struct {
+	k [32]byte
+	v [32]byte
+}
+
+// New instantiates the generator with seed as the entropy input and
+// personalization as the personalization string
+func New(seed, personalization []byte) *DRBG {
+	d := new(DRBG)
+	for i := range d.v {
+		d.v[i] = 0x01
+	}
+
+	d.update(append(append([]byte{}, seed...), personalization...))
+	return d
+}
+
+func (d *DRBG) hmac(data ...[]byte) [32]byte {
+	mac := hmac.New(sha256.New, d.k[:])
+	for _, b := range data {
+		mac.Write(b)
+	}
+
+	var out [32]byte
+	copy(out[:], mac.Sum(nil))
+	return out
+}
+
+func (d *DRBG) update(provided []byte) {
+	d.k = d.hmac(d.v[:], []byte{0x00}, provided)
+	d.v = d.hmac(d.v[:])
+
+	if len(provided) == 0 {
+		return
+	}
+
+	d.k = d.hmac(d.v[:], []byte{0x01}, provided)
+	d.v = d.hmac(d.v[:])
+}
+
+// Generate returns n pseudo random bytes
+func (d *DRBG) Generate(n int) ([]byte, error) {
+	if n > maxRequest {
+		return nil, fmt.Errorf("request of %d bytes is larger than %d", n, maxRequest)
+	}
+
+	out := make([]byte, 0, n+32)
+	for len(out) < n {
+		d.v = d.hmac(d.v[:])
+		out = append(out, d.v[:]...)
+	}
+
+	d.update(nil)
+	return out[:n], nil
+}
+
+// Read fills p from a single generate call, so the stream depends on the
+// size of the reads and callers wanting reproducible output must read the
+// same sizes in the same order
+func (d *DRBG) Read(p []byte) (int, error) {
+	out, err := d.Generate(len(p))
+	if err != nil {
+		return 0, err
+	}
+	return copy(p, out), nil
+}
+
+// Scalar draws a value in [1, n-1] by rejection sampling 32 byte outputs
+func (d *DRBG) Scalar(n *big.Int) (*big.Int, error) {
+	for {
+		b, err := d.Generate(32)
+		if err != nil {
+			return nil, err
+		}
+
+		k := new(big.Int).SetBytes(b)
+		if k.Sign() > 0 && k.Cmp(n) < 0 {
+			return k, nil
+		}
+	}
+}
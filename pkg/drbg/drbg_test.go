@@ -0,0 +1,49 @@
+package drbg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	// RFC 6979 A.1 runs HMAC_DRBG over the same construction, its first
+	// block for the key and message below is the known k candidate
+	x, _ := hex.DecodeString("009a4d6792295a7f730fc3f2b49cbc0f62e862272f")
+	h1, _ := hex.DecodeString("01795edf0d54db760f156d0dac04c0322b3a204224")
+
+	d := New(x, h1)
+	observed, err := d.Generate(21)
+	if err != nil {
+		t.Fatalf("Unexpected error from Generate: %v", err)
+	}
+
+	// then
+	expected := "9305a46de7ff8eb107194debd3fd48aa20d5e7656c"
+	if hex.EncodeToString(observed) != expected {
+		t.Fatalf("Generate(21) = %x, want %s", observed, expected)
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	a, b := New([]byte("seed"), nil), New([]byte("seed"), nil)
+	c := New([]byte("other seed"), nil)
+
+	for i := 0; i < 3; i++ {
+		x, _ := a.Generate(64)
+		y, _ := b.Generate(64)
+		z, _ := c.Generate(64)
+
+		// then
+		if !bytes.Equal(x, y) {
+			t.Fatalf("same seed gave %x and %x", x, y)
+		}
+		if bytes.Equal(x, z) {
+			t.Fatalf("different seeds gave the same output %x", x)
+		}
+	}
+
+	if _, err := a.Generate(maxRequest + 1); err == nil {
+		t.Fatalf("Generate(%d) succeeded, want error", maxRequest+1)
+	}
+}
@@ -0,0 +1,182 @@
+package entropy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Mixing entropy from several sources for key generation, for users who
+// don't want to rest a key on a single RNG. Every source's output is
+// hashed, with its name and length, into one 32 byte seed:
+//
+//	seed = TaggedHash("schnorr-go/entropy", len(name) name len(data) data ...)
+//
+// and the pool's output is HMAC-SHA256 in counter mode under the seed:
+//
+//	block i = HMAC-SHA256(seed, "schnorr-go/entropy/expand" || uint64(i))
+//
+// With SHA-256 as a random oracle the seed is unpredictable as long as any
+// one source is, and unlike XOR-ing the sources, a bad source that could
+// see the others still can't cancel them out. Sources are mixed in the
+// order given.
+//
+
+// Source is one input to the pool
+type Source interface {
+	// Name labels the source in the seed and in messages
+	Name() string
+	// Collect returns the source's output
+	Collect() ([]byte, error)
+}
+
+type source struct {
+	name    string
+	collect func() ([]byte, error)
+}
+
+func (s source) Name() string             { return s.name }
+func (s source) Collect() ([]byte, error) { return s.collect() }
+
+// System reads n bytes from crypto/rand
+func System(n int) Source {
+	return source{name: "system", collect: func() ([]byte, error) {
+		b := make([]byte, n)
+		_, err := io.ReadFull(rand.Reader, b)
+		return b, err
+	}}
+}
+
+// Device reads n bytes from a device or file, such as /dev/hwrng for a
+// hardware RNG
+func Device(path string, n int) Source {
+	return source{name: "device:" + path, collect: func() ([]byte, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		b := make([]byte, n)
+		if _, err := io.ReadFull(f, b); err != nil {
+			return nil, fmt.Errorf("reading %d bytes from %s: %v", n, path, err)
+		}
+		return b, nil
+	}}
+}
+
+// DiceBits is the entropy of one fair six-sided roll
+var DiceBits = math.Log2(6)
+
+// Dice takes rolls of a six-sided die as digits 1 to 6, ignoring spaces.
+// Real dice are only fair enough if rolled properly; 100 rolls are about
+// 258 bits.
+func Dice(rolls string) (Source, error) {
+	digits := []byte{}
+	for _, r := range rolls {
+		switch {
+		case r >= '1' && r <= '6':
+			digits = append(digits, byte(r))
+		case strings.ContainsRune(" \t\r\n,", r):
+		default:
+			return nil, fmt.Errorf("dice rolls must be digits 1 to 6, got %q", r)
+		}
+	}
+	if len(digits) == 0 {
+		return nil, fmt.Errorf("no dice rolls given")
+	}
+	return source{name: "dice", collect: func() ([]byte, error) {
+		return digits, nil
+	}}, nil
+}
+
+// Timings takes the times of keystrokes or other events a person makes.
+// Only the low bits of each interval are unpredictable, so this is a
+// supplement to other sources rather than one to rely on.
+func Timings(events []time.Time) Source {
+	return source{name: "keyboard", collect: func() ([]byte, error) {
+		if len(events) < 2 {
+			return nil, fmt.Errorf("need at least two events to time")
+		}
+		b := make([]byte, 0, 8*len(events))
+		for i := 1; i < len(events); i++ {
+			b = appendUint64(b, uint64(events[i].Sub(events[i-1]).Nanoseconds()))
+		}
+		return b, nil
+	}}
+}
+
+// Pool is the mixed output of the sources, as a reader that never runs
+// dry. Pass it to schnorr.GeneratePrivateKey.
+type Pool struct {
+	names   []string
+	seed    [32]byte
+	counter uint64
+	buf     []byte
+}
+
+// Mix collects every source and mixes them into a pool
+func Mix(sources ...Source) (*Pool, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no entropy sources")
+	}
+
+	p := &Pool{}
+	data := []byte{}
+	for _, s := range sources {
+		b, err := s.Collect()
+		if err != nil {
+			return nil, fmt.Errorf("entropy source %s: %v", s.Name(), err)
+		}
+		if len(b) == 0 {
+			return nil, fmt.Errorf("entropy source %s gave nothing", s.Name())
+		}
+		data = appendField(data, []byte(s.Name()))
+		data = appendField(data, b)
+		p.names = append(p.names, s.Name())
+	}
+	p.seed = schnorr.TaggedHash("schnorr-go/entropy", data)
+	return p, nil
+}
+
+func appendField(b, field []byte) []byte {
+	b = appendUint64(b, uint64(len(field)))
+	return append(b, field...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// Sources names the sources mixed in, in order
+func (p *Pool) Sources() []string {
+	return append([]string{}, p.names...)
+}
+
+func (p *Pool) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		if len(p.buf) == 0 {
+			mac := hmac.New(sha256.New, p.seed[:])
+			mac.Write([]byte("schnorr-go/entropy/expand"))
+			mac.Write(appendUint64(nil, p.counter))
+			p.buf = mac.Sum(nil)
+			p.counter++
+		}
+		c := copy(b[n:], p.buf)
+		p.buf = p.buf[c:]
+		n += c
+	}
+	return n, nil
+}
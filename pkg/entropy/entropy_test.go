@@ -0,0 +1,86 @@
+package entropy
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestMix(t *testing.T) {
+	// given
+	device := filepath.Join(t.TempDir(), "hwrng")
+	if err := os.WriteFile(device, bytes.Repeat([]byte{0xa5}, 64), 0600); err != nil {
+		t.Fatalf("Unexpected error from WriteFile: %v", err)
+	}
+	dice, err := Dice("3 1 6 6 2, 4 5 1")
+	if err != nil {
+		t.Fatalf("Unexpected error from Dice: %v", err)
+	}
+	start := time.Unix(1700000000, 0)
+	keyboard := Timings([]time.Time{start, start.Add(131 * time.Millisecond), start.Add(207 * time.Millisecond)})
+	mix := func(sources ...Source) []byte {
+		p, err := Mix(sources...)
+		if err != nil {
+			t.Fatalf("Unexpected error from Mix: %v", err)
+		}
+		out := make([]byte, 100)
+		if _, err := io.ReadFull(p, out); err != nil {
+			t.Fatalf("Unexpected error from Read: %v", err)
+		}
+		return out
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		// when
+		a := mix(dice, keyboard, Device(device, 32))
+		b := mix(dice, keyboard, Device(device, 32))
+
+		// then
+		if !bytes.Equal(a, b) {
+			t.Fatalf("Mix() of the same inputs differs")
+		}
+		if bytes.Equal(a, mix(keyboard, dice, Device(device, 32))) {
+			t.Fatalf("Mix() ignores the order of the sources")
+		}
+		if bytes.Equal(a, mix(dice, keyboard, Device(device, 31))) {
+			t.Fatalf("Mix() ignores part of a source")
+		}
+	})
+
+	t.Run("system", func(t *testing.T) {
+		// when
+		p, err := Mix(System(32), dice)
+		if err != nil {
+			t.Fatalf("Unexpected error from Mix: %v", err)
+		}
+		_, err = schnorr.GeneratePrivateKey(p)
+
+		// then
+		if err != nil {
+			t.Fatalf("Unexpected error from GeneratePrivateKey: %v", err)
+		}
+		if got := p.Sources(); len(got) != 2 || got[0] != "system" || got[1] != "dice" {
+			t.Fatalf("Sources() = %v, want [system dice]", got)
+		}
+		if bytes.Equal(mix(System(32), dice), mix(System(32), dice)) {
+			t.Fatalf("Mix() with the system source repeated itself")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := Dice("1 2 7"); err == nil {
+			t.Fatalf("Dice() accepted a 7")
+		}
+		if _, err := Mix(Device(filepath.Join(t.TempDir(), "missing"), 32)); err == nil {
+			t.Fatalf("Mix() succeeded with a missing device")
+		}
+		if _, err := Mix(Timings(nil)); err == nil {
+			t.Fatalf("Mix() succeeded with no key presses")
+		}
+	})
+}
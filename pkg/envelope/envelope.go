@@ -0,0 +1,319 @@
+package envelope
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// Envelope is a payload signed together with its type and any optional
+// fields. Payload is base64 and the key and signature are hex in json.
+type Envelope struct {
+	PayloadType string            `json:"payloadType"`
+	Payload     []byte            `json:"payload"`
+	Sequence    *uint64           `json:"sequence,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Expires     *time.Time        `json:"expires,omitempty"`
+	PublicKey   string            `json:"publicKey"`
+	Signature   string            `json:"signature"`
+}
+
+// Option sets an optional field on an envelope before it is signed
+type Option func(*Envelope)
+
+// WithSequence stamps the envelope with a sequence number. The signer must
+// use a larger number for every envelope it signs with the key, so verifiers
+// tracking the numbers can reject replays.
+func WithSequence(seq uint64) Option {
+	return func(e *Envelope) {
+		e.Sequence = &seq
+	}
+}
+
+// WithAnnotations attaches key-value metadata, such as a build id, commit or
+// builder identity, which the signature covers along with the payload. It
+// can be given more than once; later values win.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(e *Envelope) {
+		if e.Annotations == nil {
+			e.Annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			e.Annotations[k] = v
+		}
+	}
+}
+
+// WithExpiry makes the envelope expire at t, to the second. Verify refuses
+// it after then, so attestations which must be refreshed periodically are
+// renewed rather than trusted forever.
+func WithExpiry(t time.Time) Option {
+	return func(e *Envelope) {
+		t = t.UTC().Truncate(time.Second)
+		e.Expires = &t
+	}
+}
+
+// SignFunc signs a digest with a key held elsewhere, such as in Vault, and
+// returns the signature and the public key it verifies under
+type SignFunc func(digest [32]byte) ([64]byte, [33]byte, error)
+
+// Sign wraps the payload in an envelope signed with the private key
+func Sign(privatekey *big.Int, payloadType string, payload []byte, opts ...Option) (*Envelope, error) {
+	publickey, err := schnorr.ScalarBaseMult(privatekey).PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return SignWith(func(digest [32]byte) ([64]byte, [33]byte, error) {
+		sig, err := schnorr.Sign(privatekey, digest)
+		return sig, publickey, err
+	}, payloadType, payload, opts...)
+}
+
+// SignWith is Sign with the signing done by sign
+func SignWith(sign SignFunc, payloadType string, payload []byte, opts ...Option) (*Envelope, error) {
+	e := &Envelope{
+		PayloadType: payloadType,
+		Payload:     append([]byte{}, payload...),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, e.sign(sign)
+}
+
+// Renew signs the envelope's payload, type, annotations and sequence again
+// with sign, then applies opts, typically a later WithExpiry
+func Renew(old *Envelope, sign SignFunc, opts ...Option) (*Envelope, error) {
+	e := &Envelope{
+		PayloadType: old.PayloadType,
+		Payload:     append([]byte{}, old.Payload...),
+	}
+	if old.Sequence != nil {
+		seq := *old.Sequence
+		e.Sequence = &seq
+	}
+	if len(old.Annotations) > 0 {
+		WithAnnotations(old.Annotations)(e)
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, e.sign(sign)
+}
+
+func (e *Envelope) sign(sign SignFunc) error {
+	for k := range e.Annotations {
+		if k == "" {
+			return fmt.Errorf("annotation keys must not be empty")
+		}
+	}
+
+	sig, publickey, err := sign(e.Digest())
+	if err != nil {
+		return err
+	}
+	e.PublicKey = hex.EncodeToString(publickey[:])
+	e.Signature = hex.EncodeToString(sig[:])
+	return nil
+}
+
+// Digest is the message the signature covers: a tagged hash over every
+// signed field, each prefixed with its name and length. Optional fields are
+// only included when set, so adding new ones does not change the digest of
+// envelopes which don't use them.
+func (e *Envelope) Digest() [32]byte {
+	data := appendField(nil, "payloadType", []byte(e.PayloadType))
+	data = appendField(data, "payload", e.Payload)
+	if e.Sequence != nil {
+		data = appendField(data, "sequence", appendUint64(nil, *e.Sequence))
+	}
+	if len(e.Annotations) > 0 {
+		keys := make([]string, 0, len(e.Annotations))
+		for k := range e.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		annotations := []byte{}
+		for _, k := range keys {
+			annotations = appendField(annotations, k, []byte(e.Annotations[k]))
+		}
+		data = appendField(data, "annotations", annotations)
+	}
+	if e.Expires != nil {
+		data = appendField(data, "expires", appendUint64(nil, uint64(e.Expires.Unix())))
+	}
+	return schnorr.TaggedHash("schnorr-go/envelope", data)
+}
+
+func appendField(b []byte, name string, value []byte) []byte {
+	b = appendUint64(b, uint64(len(name)))
+	b = append(b, name...)
+	b = appendUint64(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	for i := 7; i >= 0; i-- {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return b
+}
+
+// Key returns the public key the envelope claims to be signed by
+func (e *Envelope) Key() ([33]byte, error) {
+	var publickey [33]byte
+	raw, err := hex.DecodeString(e.PublicKey)
+	if err != nil || len(raw) != 33 {
+		return publickey, fmt.Errorf("public key is not 33 bytes of hex")
+	}
+	copy(publickey[:], raw)
+	return publickey, nil
+}
+
+// VerifyOption adds a check to Verify
+type VerifyOption func(*verifier)
+
+type verifier struct {
+	sequences   SequenceStore
+	revocations Revocations
+	annotations map[string]string
+	trust       Trust
+	journal     *Journal
+	now         time.Time
+}
+
+// Revocations says whether a key has been revoked
+type Revocations interface {
+	IsRevoked(publickey [33]byte) bool
+}
+
+// ErrRevoked is returned for envelopes signed by a revoked key
+var ErrRevoked = errors.New("key has been revoked")
+
+// RejectRevoked rejects envelopes signed by a revoked key, however valid
+// the signature
+func RejectRevoked(revocations Revocations) VerifyOption {
+	return func(v *verifier) {
+		v.revocations = revocations
+	}
+}
+
+// Trust says whether a key is trusted, returning why not if it isn't
+type Trust interface {
+	Trusted(publickey [33]byte) error
+}
+
+// RequireTrusted rejects envelopes whose key the trust store refuses. The
+// store is only asked once the signature has verified, so a forged envelope
+// never gets a key pinned.
+func RequireTrusted(trust Trust) VerifyOption {
+	return func(v *verifier) {
+		v.trust = trust
+	}
+}
+
+// ErrExpired is returned for envelopes past their expiry
+var ErrExpired = errors.New("envelope has expired")
+
+// VerifyAt checks expiry at t rather than now, such as the time a
+// timestamp proves the envelope was seen
+func VerifyAt(t time.Time) VerifyOption {
+	return func(v *verifier) {
+		v.now = t
+	}
+}
+
+// RequireSequence rejects envelopes without a sequence number or with one
+// that is not above the high-water mark the store has for the key. The mark
+// is only advanced once the signature has verified.
+func RequireSequence(store SequenceStore) VerifyOption {
+	return func(v *verifier) {
+		v.sequences = store
+	}
+}
+
+// RequireAnnotations rejects envelopes which do not carry every one of the
+// annotations with the same value. Other annotations are allowed.
+func RequireAnnotations(annotations map[string]string) VerifyOption {
+	return func(v *verifier) {
+		v.annotations = annotations
+	}
+}
+
+// Verify checks the signature of the envelope against the public key it
+// carries, which must be the expected one
+func Verify(e *Envelope, publickey [33]byte, opts ...VerifyOption) error {
+	v := &verifier{now: time.Now()}
+	for _, opt := range opts {
+		opt(v)
+	}
+	err := v.verify(e, publickey)
+	if v.journal != nil {
+		return v.record(e, publickey, err)
+	}
+	return err
+}
+
+func (v *verifier) verify(e *Envelope, publickey [33]byte) error {
+	key, err := e.Key()
+	if err != nil {
+		return err
+	}
+	if key != publickey {
+		return fmt.Errorf("envelope is signed by %x, want %x", key, publickey)
+	}
+	if v.revocations != nil && v.revocations.IsRevoked(publickey) {
+		return fmt.Errorf("%w: %x", ErrRevoked, publickey)
+	}
+
+	raw, err := hex.DecodeString(e.Signature)
+	if err != nil || len(raw) != 64 {
+		return fmt.Errorf("signature is not 64 bytes of hex")
+	}
+	var sig [64]byte
+	copy(sig[:], raw)
+
+	if v.sequences != nil && e.Sequence == nil {
+		return fmt.Errorf("envelope has no sequence number")
+	}
+
+	ok, err := schnorr.Verify(publickey, e.Digest(), sig)
+	if !ok {
+		return fmt.Errorf("signature verification failed: %v", err)
+	}
+
+	if e.Expires != nil && v.now.After(*e.Expires) {
+		return fmt.Errorf("%w: at %s", ErrExpired, e.Expires.Format(time.RFC3339))
+	}
+
+	if v.trust != nil {
+		if err := v.trust.Trusted(publickey); err != nil {
+			return err
+		}
+	}
+
+	for k, want := range v.annotations {
+		got, ok := e.Annotations[k]
+		if !ok {
+			return fmt.Errorf("envelope has no %s annotation", k)
+		}
+		if got != want {
+			return fmt.Errorf("envelope annotation %s is %q, want %q", k, got, want)
+		}
+	}
+
+	if v.sequences != nil {
+		if err := v.sequences.Advance(publickey, *e.Sequence); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func testKey(t *testing.T) schnorr.TestKey {
+	keys, err := schnorr.GenerateTestKeys([]byte("envelope"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	return keys[0]
+}
+
+func TestSignVerify(t *testing.T) {
+	// given
+	key := testKey(t)
+
+	// when
+	e, err := Sign(key.PrivateKey, "text/plain", []byte("restart the service"))
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	// then
+	if err := Verify(e, key.PublicKey); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+
+	t.Run("Round trips through json", func(t *testing.T) {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("Unexpected error from json.Marshal: %v", err)
+		}
+		decoded := new(Envelope)
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatalf("Unexpected error from json.Unmarshal: %v", err)
+		}
+		if err := Verify(decoded, key.PublicKey); err != nil {
+			t.Fatalf("Verify() after round trip = %v, want nil", err)
+		}
+	})
+
+	t.Run("Tampered payload type fails", func(t *testing.T) {
+		tampered := *e
+		tampered.PayloadType = "application/json"
+		if err := Verify(&tampered, key.PublicKey); err == nil {
+			t.Fatalf("Verify() with changed payload type = nil, want error")
+		}
+	})
+
+	t.Run("Sequence is required", func(t *testing.T) {
+		if err := Verify(e, key.PublicKey, RequireSequence(NewMemorySequenceStore())); err == nil {
+			t.Fatalf("Verify() without a sequence = nil, want error")
+		}
+	})
+}
+
+func TestSequence(t *testing.T) {
+	// given
+	key := testKey(t)
+	store := NewMemorySequenceStore()
+
+	sign := func(seq uint64) *Envelope {
+		e, err := Sign(key.PrivateKey, "command", []byte("transfer"), WithSequence(seq))
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		return e
+	}
+	first, second := sign(1), sign(5)
+
+	// when
+	if err := Verify(first, key.PublicKey, RequireSequence(store)); err != nil {
+		t.Fatalf("Verify(1) = %v, want nil", err)
+	}
+	if err := Verify(second, key.PublicKey, RequireSequence(store)); err != nil {
+		t.Fatalf("Verify(5) = %v, want nil", err)
+	}
+
+	// then
+	if mark, ok, _ := store.HighWaterMark(key.PublicKey); !ok || mark != 5 {
+		t.Fatalf("HighWaterMark() = %d, %v, want 5, true", mark, ok)
+	}
+	for _, e := range []*Envelope{first, second, sign(3)} {
+		if err := Verify(e, key.PublicKey, RequireSequence(store)); !errors.Is(err, ErrReplay) {
+			t.Fatalf("Verify(%d) = %v, want ErrReplay", *e.Sequence, err)
+		}
+	}
+
+	t.Run("Changed sequence fails the signature", func(t *testing.T) {
+		tampered := *sign(6)
+		seq := uint64(7)
+		tampered.Sequence = &seq
+		if err := Verify(&tampered, key.PublicKey, RequireSequence(store)); err == nil || errors.Is(err, ErrReplay) {
+			t.Fatalf("Verify() with changed sequence = %v, want signature error", err)
+		}
+		if mark, _, _ := store.HighWaterMark(key.PublicKey); mark != 5 {
+			t.Fatalf("HighWaterMark() = %d after a bad signature, want 5", mark)
+		}
+	})
+}
+
+func TestAnnotations(t *testing.T) {
+	// given
+	key := testKey(t)
+	plain, _ := Sign(key.PrivateKey, "text/plain", []byte("release"))
+
+	// when
+	e, err := Sign(key.PrivateKey, "text/plain", []byte("release"),
+		WithAnnotations(map[string]string{"build": "42"}),
+		WithAnnotations(map[string]string{"commit": "abc123", "builder": "ci"}))
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	// then
+	if len(e.Annotations) != 3 {
+		t.Fatalf("Annotations = %v, want 3", e.Annotations)
+	}
+	if e.Digest() == plain.Digest() {
+		t.Fatalf("annotations are not covered by the digest")
+	}
+	if err := Verify(e, key.PublicKey, RequireAnnotations(map[string]string{"build": "42", "commit": "abc123"})); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+
+	t.Run("Missing or different annotations fail", func(t *testing.T) {
+		if err := Verify(e, key.PublicKey, RequireAnnotations(map[string]string{"build": "43"})); err == nil {
+			t.Fatalf("Verify() with a different annotation = nil, want error")
+		}
+		if err := Verify(plain, key.PublicKey, RequireAnnotations(map[string]string{"build": "42"})); err == nil {
+			t.Fatalf("Verify() without the annotation = nil, want error")
+		}
+	})
+
+	t.Run("Changed annotation fails the signature", func(t *testing.T) {
+		tampered := *e
+		tampered.Annotations = map[string]string{"build": "43", "commit": "abc123", "builder": "ci"}
+		if err := Verify(&tampered, key.PublicKey); err == nil {
+			t.Fatalf("Verify() with a changed annotation = nil, want error")
+		}
+	})
+
+	t.Run("Empty keys are refused", func(t *testing.T) {
+		if _, err := Sign(key.PrivateKey, "text/plain", nil, WithAnnotations(map[string]string{"": "x"})); err == nil {
+			t.Fatalf("Sign() with an empty annotation key = nil error, want error")
+		}
+	})
+}
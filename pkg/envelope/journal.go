@@ -0,0 +1,245 @@
+package envelope
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// A verifier's journal of every envelope it was asked to verify: the key,
+// the digest, whether it was accepted and the checks in force at the time,
+// as json lines. Each entry carries the hash of the one before, so an entry
+// can't be changed, dropped or inserted without breaking every hash after
+// it. The chain only proves itself, though, so the application
+// periodically anchors its head with a signature of its own; from then on
+// the entries up to the anchor can't be rewritten without its key.
+//
+
+// AnchorPayloadType is the payload type of journal anchors
+const AnchorPayloadType = "application/vnd.schnorr-go.journal-anchor+json"
+
+// genesis is the prev of the first entry
+var genesis = strings.Repeat("0", 64)
+
+// JournalEntry is one verification
+type JournalEntry struct {
+	Index       uint64    `json:"index"`
+	Time        time.Time `json:"time"`
+	PublicKey   string    `json:"publicKey"`
+	PayloadType string    `json:"payloadType"`
+	Digest      string    `json:"digest"`
+	Sequence    *uint64   `json:"sequence,omitempty"`
+	Accepted    bool      `json:"accepted"`
+	Error       string    `json:"error,omitempty"`
+	Policy      Policy    `json:"policy"`
+	// Prev is the hash of the previous entry, Hash of this one with Hash
+	// left empty
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+// Policy is the checks a verification was asked for
+type Policy struct {
+	RejectRevoked   bool              `json:"rejectRevoked,omitempty"`
+	RequireTrusted  bool              `json:"requireTrusted,omitempty"`
+	RequireSequence bool              `json:"requireSequence,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	At              time.Time         `json:"at"`
+}
+
+// JournalAnchor is what an anchor signs: the number of entries and the
+// hash of the last
+type JournalAnchor struct {
+	Entries uint64    `json:"entries"`
+	Head    string    `json:"head"`
+	Time    time.Time `json:"time"`
+}
+
+// hash is the entry's hash, over its json with Hash empty
+func (entry *JournalEntry) hash() (string, error) {
+	unhashed := *entry
+	unhashed.Hash = ""
+	data, err := json.Marshal(unhashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Journal appends entries to a json lines log. It is safe for concurrent
+// use.
+type Journal struct {
+	mu      sync.Mutex
+	w       io.Writer
+	entries uint64
+	head    string
+	now     func() time.Time
+}
+
+// NewJournal starts a new journal written to w
+func NewJournal(w io.Writer) *Journal {
+	return &Journal{w: w, head: genesis, now: time.Now}
+}
+
+// ResumeJournal continues the journal read from r, checking it, with the
+// new entries written to w, which is usually the same file opened for
+// appending
+func ResumeJournal(r io.Reader, w io.Writer) (*Journal, error) {
+	entries, err := ReadJournal(r)
+	if err != nil {
+		return nil, err
+	}
+	j := NewJournal(w)
+	if n := len(entries); n > 0 {
+		j.entries, j.head = uint64(n), entries[n-1].Hash
+	}
+	return j, nil
+}
+
+// Head returns the number of entries and the hash of the last
+func (j *Journal) Head() (uint64, string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.entries, j.head
+}
+
+// Record chains the entry onto the journal and writes it, setting its
+// index, time, prev and hash
+func (j *Journal) Record(entry *JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry.Index = j.entries
+	entry.Time = j.now().UTC()
+	entry.Prev = j.head
+	var err error
+	if entry.Hash, err = entry.hash(); err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	j.entries++
+	j.head = entry.Hash
+	return nil
+}
+
+// Anchor signs the journal's head as it is now. Anchors are kept by the
+// application, away from the journal, and checked with VerifyAnchor.
+func (j *Journal) Anchor(sign SignFunc) (*Envelope, error) {
+	entries, head := j.Head()
+	payload, err := json.Marshal(JournalAnchor{Entries: entries, Head: head, Time: j.now().UTC()})
+	if err != nil {
+		return nil, err
+	}
+	return SignWith(sign, AnchorPayloadType, payload)
+}
+
+// ReadJournal reads a journal and checks its chain, returning the entries
+func ReadJournal(r io.Reader) ([]*JournalEntry, error) {
+	var entries []*JournalEntry
+	head := genesis
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := new(JournalEntry)
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("journal entry %d is not json: %v", len(entries), err)
+		}
+		if entry.Index != uint64(len(entries)) || entry.Prev != head {
+			return nil, fmt.Errorf("journal entry %d does not follow entry %d", entry.Index, len(entries)-1)
+		}
+		hash, err := entry.hash()
+		if err != nil {
+			return nil, err
+		}
+		if hash != entry.Hash {
+			return nil, fmt.Errorf("journal entry %d has been changed", entry.Index)
+		}
+		entries = append(entries, entry)
+		head = entry.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// VerifyAnchor checks the anchor is signed by the key and covers the
+// entries, as read by ReadJournal: the journal holds at least as many
+// entries as were anchored and the last of them is the anchored head.
+func VerifyAnchor(entries []*JournalEntry, anchor *Envelope, publickey [33]byte) (*JournalAnchor, error) {
+	if anchor.PayloadType != AnchorPayloadType {
+		return nil, fmt.Errorf("envelope holds %s, not a journal anchor", anchor.PayloadType)
+	}
+	if err := Verify(anchor, publickey); err != nil {
+		return nil, err
+	}
+	a := new(JournalAnchor)
+	if err := json.Unmarshal(anchor.Payload, a); err != nil {
+		return nil, fmt.Errorf("journal anchor is not json: %v", err)
+	}
+	if a.Entries > uint64(len(entries)) {
+		return nil, fmt.Errorf("anchor covers %d entries, the journal has %d", a.Entries, len(entries))
+	}
+	head := genesis
+	if a.Entries > 0 {
+		head = entries[a.Entries-1].Hash
+	}
+	if head != a.Head {
+		return nil, fmt.Errorf("the journal's first %d entries are not the ones anchored", a.Entries)
+	}
+	return a, nil
+}
+
+// RecordTo records every verification in the journal, accepted or not,
+// with the checks asked for. An accepted envelope that can't be recorded
+// is refused, so nothing is accepted without the evidence.
+func RecordTo(j *Journal) VerifyOption {
+	return func(v *verifier) {
+		v.journal = j
+	}
+}
+
+// record writes the verification of e against publickey, and its outcome
+func (v *verifier) record(e *Envelope, publickey [33]byte, result error) error {
+	digest := e.Digest()
+	entry := &JournalEntry{
+		PublicKey:   hex.EncodeToString(publickey[:]),
+		PayloadType: e.PayloadType,
+		Digest:      hex.EncodeToString(digest[:]),
+		Sequence:    e.Sequence,
+		Accepted:    result == nil,
+		Policy: Policy{
+			RejectRevoked:   v.revocations != nil,
+			RequireTrusted:  v.trust != nil,
+			RequireSequence: v.sequences != nil,
+			Annotations:     v.annotations,
+			At:              v.now.UTC(),
+		},
+	}
+	if result != nil {
+		entry.Error = result.Error()
+	}
+	if err := v.journal.Record(entry); err != nil {
+		if result != nil {
+			return result
+		}
+		return fmt.Errorf("recording the verification in the journal: %v", err)
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package envelope
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestJournal(t *testing.T) {
+	key := testKey(t)
+	anchorKeys, _ := schnorr.GenerateTestKeys([]byte("journal anchor"), 1)
+	anchorKey := anchorKeys[0]
+	anchorSign := func(digest [32]byte) ([64]byte, [33]byte, error) {
+		sig, err := schnorr.Sign(anchorKey.PrivateKey, digest)
+		return sig, anchorKey.PublicKey, err
+	}
+
+	// given a journal of an accepted envelope and a refused one
+	var log bytes.Buffer
+	journal := NewJournal(&log)
+	good, _ := Sign(key.PrivateKey, "text/plain", []byte("restart the service"), WithSequence(1))
+	forged := *good
+	forged.Payload = []byte("stop the service")
+
+	// when
+	if err := Verify(good, key.PublicKey, RecordTo(journal), RequireSequence(NewMemorySequenceStore())); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+	if err := Verify(&forged, key.PublicKey, RecordTo(journal)); err == nil {
+		t.Fatalf("Verify() of a forged envelope = nil, want an error")
+	}
+	anchor, err := journal.Anchor(anchorSign)
+	if err != nil {
+		t.Fatalf("Unexpected error from Anchor: %v", err)
+	}
+
+	// then
+	entries, err := ReadJournal(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error from ReadJournal: %v", err)
+	}
+	if len(entries) != 2 || !entries[0].Accepted || entries[1].Accepted || entries[1].Error == "" {
+		t.Fatalf("ReadJournal() = %+v, want an accepted entry then a refused one", entries)
+	}
+	if !entries[0].Policy.RequireSequence || entries[1].Policy.RequireSequence {
+		t.Fatalf("policies = %+v, %+v, want the sequence check recorded for the first only", entries[0].Policy, entries[1].Policy)
+	}
+	if a, err := VerifyAnchor(entries, anchor, anchorKey.PublicKey); err != nil || a.Entries != 2 {
+		t.Fatalf("VerifyAnchor() = %+v, %v, want 2 entries anchored", a, err)
+	}
+
+	t.Run("Changed entries break the chain", func(t *testing.T) {
+		changed := strings.Replace(log.String(), `"accepted":false`, `"accepted":true`, 1)
+		if _, err := ReadJournal(strings.NewReader(changed)); err == nil {
+			t.Fatalf("ReadJournal() of a changed journal succeeded, want an error")
+		}
+		lines := strings.SplitAfter(log.String(), "\n")
+		if _, err := ReadJournal(strings.NewReader(lines[1])); err == nil {
+			t.Fatalf("ReadJournal() without the first entry succeeded, want an error")
+		}
+	})
+
+	t.Run("A journal rewritten since the anchor is refused", func(t *testing.T) {
+		var rewritten bytes.Buffer
+		other := NewJournal(&rewritten)
+		Verify(good, key.PublicKey, RecordTo(other))
+		Verify(good, key.PublicKey, RecordTo(other))
+		entries, err := ReadJournal(&rewritten)
+		if err != nil {
+			t.Fatalf("Unexpected error from ReadJournal: %v", err)
+		}
+		if _, err := VerifyAnchor(entries, anchor, anchorKey.PublicKey); err == nil {
+			t.Fatalf("VerifyAnchor() of another journal succeeded, want an error")
+		}
+	})
+
+	t.Run("Resumed journals continue the chain", func(t *testing.T) {
+		resumed, err := ResumeJournal(bytes.NewReader(log.Bytes()), &log)
+		if err != nil {
+			t.Fatalf("Unexpected error from ResumeJournal: %v", err)
+		}
+		Verify(good, key.PublicKey, RecordTo(resumed))
+		entries, err := ReadJournal(bytes.NewReader(log.Bytes()))
+		if err != nil || len(entries) != 3 {
+			t.Fatalf("ReadJournal() = %d entries, %v, want 3", len(entries), err)
+		}
+	})
+}
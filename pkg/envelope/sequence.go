@@ -0,0 +1,53 @@
+package envelope
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrReplay is returned when an envelope's sequence number is not above the
+// high-water mark for its key
+var ErrReplay = errors.New("sequence number has already been used")
+
+// SequenceStore tracks the highest sequence number accepted for each key.
+// Advance must check and move the mark atomically, so two copies of the
+// same envelope verified at once can't both be accepted.
+type SequenceStore interface {
+	// HighWaterMark returns the highest accepted number for the key, false
+	// if none has been seen
+	HighWaterMark(publickey [33]byte) (uint64, bool, error)
+
+	// Advance moves the mark to seq, failing with ErrReplay unless seq is
+	// above the current mark
+	Advance(publickey [33]byte, seq uint64) error
+}
+
+// MemorySequenceStore keeps the marks in memory
+type MemorySequenceStore struct {
+	mu    sync.Mutex
+	marks map[[33]byte]uint64
+}
+
+func NewMemorySequenceStore() *MemorySequenceStore {
+	return &MemorySequenceStore{marks: map[[33]byte]uint64{}}
+}
+
+func (s *MemorySequenceStore) HighWaterMark(publickey [33]byte) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mark, ok := s.marks[publickey]
+	return mark, ok, nil
+}
+
+func (s *MemorySequenceStore) Advance(publickey [33]byte, seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if mark, ok := s.marks[publickey]; ok && seq <= mark {
+		return fmt.Errorf("%w: got %d, high-water mark is %d", ErrReplay, seq, mark)
+	}
+	s.marks[publickey] = seq
+	return nil
+}
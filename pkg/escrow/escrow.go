@@ -0,0 +1,396 @@
+package escrow
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/transcript"
+)
+
+//
+// Verifiable escrow of a private key (or key share) x for the public key
+// X = x*G, encrypted to an escrow public key Y.
+//
+// Every bit b_i of x is encrypted with exponential ElGamal as
+// (A_i, B_i) = (r_i*G, b_i*G + r_i*Y), with a proof that each pair encrypts
+// either 0 or 1. Summing with weights 2^i gives sum(A) = R*G and
+// sum(B) = X + R*Y for R = sum(2^i r_i), and a proof that both share the same
+// R ties the bits to X. Anyone can check the proofs, only the escrow key can
+// open the bits, and the bits being 0 or 1 means opening is always cheap.
+//
+
+// Bits is the number of encrypted bits, enough for any scalar
+const Bits = 256
+
+var G = schnorr.ScalarBaseMult(big.NewInt(1))
+
+// BitCiphertext is the encryption of one bit with the proof it is 0 or 1
+type BitCiphertext struct {
+	A, B   *schnorr.Point
+	C0, S0 *big.Int
+	C1, S1 *big.Int
+}
+
+// Escrow is an encrypted private key with its proof of correctness. Context
+// is bound into the proofs, so an escrow made for one purpose cannot be
+// presented as one for another.
+type Escrow struct {
+	EscrowKey *schnorr.Point
+	PublicKey *schnorr.Point
+	Context   []byte
+	Bits      []BitCiphertext
+	C, S      *big.Int
+}
+
+func randomScalar() (*big.Int, error) {
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(schnorr.Curve.N, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+func mod(k *big.Int) *big.Int {
+	return k.Mod(k, schnorr.Curve.N)
+}
+
+func (e *Escrow) transcript() *transcript.Transcript {
+	t := transcript.New("schnorr-go/escrow/v1")
+	t.AppendMessage("context", e.Context)
+	t.AppendPoint("Y", e.EscrowKey)
+	t.AppendPoint("X", e.PublicKey)
+	return t
+}
+
+// Encrypt escrows the private key to escrowKey
+func Encrypt(escrowKey [33]byte, privatekey *big.Int, context []byte) (*Escrow, error) {
+	if privatekey.Sign() <= 0 || privatekey.Cmp(schnorr.Curve.N) >= 0 {
+		return nil, fmt.Errorf("private key is not in the range 1..n-1")
+	}
+
+	Y, err := schnorr.ParsePoint(escrowKey)
+	if err != nil {
+		return nil, fmt.Errorf("escrow key: %v", err)
+	}
+
+	e := &Escrow{
+		EscrowKey: Y,
+		PublicKey: schnorr.ScalarBaseMult(privatekey),
+		Context:   append([]byte{}, context...),
+		Bits:      make([]BitCiphertext, Bits),
+	}
+
+	t := e.transcript()
+	R := new(big.Int)
+
+	for i := range e.Bits {
+		r, err := randomScalar()
+		if err != nil {
+			return nil, err
+		}
+
+		b := privatekey.Bit(i)
+		bit := &e.Bits[i]
+		bit.A = schnorr.ScalarBaseMult(r)
+		bit.B = Y.Mul(r)
+		if b == 1 {
+			bit.B = bit.B.Add(G)
+		}
+
+		if err := bit.prove(t, Y, r, b); err != nil {
+			return nil, err
+		}
+
+		R.Add(R, new(big.Int).Lsh(r, uint(i)))
+	}
+	mod(R)
+
+	// prove log_G(sum 2^i A_i) == log_Y(sum 2^i B_i - X) == R
+	sumA, sumB := e.weightedSums()
+	k, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	t.AppendPoint("T1", schnorr.ScalarBaseMult(k))
+	t.AppendPoint("T2", Y.Mul(k))
+	t.AppendPoint("sumA", sumA)
+	t.AppendPoint("sumB", sumB)
+
+	e.C = t.ChallengeScalar("c")
+	e.S = mod(new(big.Int).Add(k, new(big.Int).Mul(e.C, R)))
+
+	return e, nil
+}
+
+// prove makes the 1 out of 2 proof that (A, B - b*G) = (r*G, r*Y) for b in
+// {0, 1}, simulating the branch that is not true
+func (bit *BitCiphertext) prove(t *transcript.Transcript, Y *schnorr.Point, r *big.Int, b uint) error {
+	cSim, err := randomScalar()
+	if err != nil {
+		return err
+	}
+	sSim, err := randomScalar()
+	if err != nil {
+		return err
+	}
+	k, err := randomScalar()
+	if err != nil {
+		return err
+	}
+
+	// the statement of the other branch
+	other := bit.B
+	if b == 0 {
+		other = bit.B.Sub(G)
+	}
+
+	simT1 := schnorr.ScalarBaseMult(sSim).Sub(bit.A.Mul(cSim))
+	simT2 := Y.Mul(sSim).Sub(other.Mul(cSim))
+	realT1 := schnorr.ScalarBaseMult(k)
+	realT2 := Y.Mul(k)
+
+	var t0, t1, t2, t3 *schnorr.Point
+	if b == 0 {
+		t0, t1, t2, t3 = realT1, realT2, simT1, simT2
+	} else {
+		t0, t1, t2, t3 = simT1, simT2, realT1, realT2
+	}
+
+	c := bit.challenge(t, t0, t1, t2, t3)
+	cReal := mod(new(big.Int).Sub(c, cSim))
+	sReal := mod(new(big.Int).Add(k, new(big.Int).Mul(cReal, r)))
+
+	if b == 0 {
+		bit.C0, bit.S0, bit.C1, bit.S1 = cReal, sReal, cSim, sSim
+	} else {
+		bit.C0, bit.S0, bit.C1, bit.S1 = cSim, sSim, cReal, sReal
+	}
+	return nil
+}
+
+func (bit *BitCiphertext) challenge(t *transcript.Transcript, t0, t1, t2, t3 *schnorr.Point) *big.Int {
+	t.AppendPoint("A", bit.A)
+	t.AppendPoint("B", bit.B)
+	t.AppendPoint("T0_1", t0)
+	t.AppendPoint("T0_2", t1)
+	t.AppendPoint("T1_1", t2)
+	t.AppendPoint("T1_2", t3)
+	return t.ChallengeScalar("c")
+}
+
+func (bit *BitCiphertext) verify(t *transcript.Transcript, Y *schnorr.Point) error {
+	for _, k := range []*big.Int{bit.C0, bit.S0, bit.C1, bit.S1} {
+		if k == nil || k.Sign() < 0 || k.Cmp(schnorr.Curve.N) >= 0 {
+			return fmt.Errorf("proof scalar out of range")
+		}
+	}
+	if bit.A == nil || bit.B == nil || bit.A.IsInfinity() {
+		return fmt.Errorf("invalid ciphertext")
+	}
+
+	// branch 0: (A, B), branch 1: (A, B - G)
+	B1 := bit.B.Sub(G)
+	t0 := schnorr.ScalarBaseMult(bit.S0).Sub(bit.A.Mul(bit.C0))
+	t1 := Y.Mul(bit.S0).Sub(bit.B.Mul(bit.C0))
+	t2 := schnorr.ScalarBaseMult(bit.S1).Sub(bit.A.Mul(bit.C1))
+	t3 := Y.Mul(bit.S1).Sub(B1.Mul(bit.C1))
+
+	c := bit.challenge(t, t0, t1, t2, t3)
+	if mod(new(big.Int).Add(bit.C0, bit.C1)).Cmp(c) != 0 {
+		return fmt.Errorf("bit proof does not verify")
+	}
+	return nil
+}
+
+func (e *Escrow) weightedSums() (*schnorr.Point, *schnorr.Point) {
+	sumA, sumB := schnorr.Infinity, schnorr.Infinity
+	for i := len(e.Bits) - 1; i >= 0; i-- {
+		// Horner's rule: sum = 2*sum + bit
+		sumA = sumA.Add(sumA).Add(e.Bits[i].A)
+		sumB = sumB.Add(sumB).Add(e.Bits[i].B)
+	}
+	return sumA, sumB.Sub(e.PublicKey)
+}
+
+// Verify checks every proof, so that the escrow key holder is guaranteed to
+// recover the private key of PublicKey
+func (e *Escrow) Verify() error {
+	if e.EscrowKey == nil || e.PublicKey == nil || e.EscrowKey.IsInfinity() || e.PublicKey.IsInfinity() {
+		return fmt.Errorf("escrow is missing its keys")
+	}
+	if len(e.Bits) != Bits {
+		return fmt.Errorf("escrow has %d bits, want %d", len(e.Bits), Bits)
+	}
+	if e.C == nil || e.S == nil || e.S.Sign() < 0 || e.S.Cmp(schnorr.Curve.N) >= 0 {
+		return fmt.Errorf("proof scalar out of range")
+	}
+
+	t := e.transcript()
+	for i := range e.Bits {
+		if err := e.Bits[i].verify(t, e.EscrowKey); err != nil {
+			return fmt.Errorf("bit %d: %v", i, err)
+		}
+	}
+
+	sumA, sumB := e.weightedSums()
+	T1 := schnorr.ScalarBaseMult(e.S).Sub(sumA.Mul(e.C))
+	T2 := e.EscrowKey.Mul(e.S).Sub(sumB.Mul(e.C))
+
+	t.AppendPoint("T1", T1)
+	t.AppendPoint("T2", T2)
+	t.AppendPoint("sumA", sumA)
+	t.AppendPoint("sumB", sumB)
+
+	if t.ChallengeScalar("c").Cmp(e.C) != 0 {
+		return fmt.Errorf("ciphertexts are not an encryption of the private key")
+	}
+	return nil
+}
+
+// Decrypt opens the escrow with the escrow private key. The proofs are
+// checked first, and the result is checked against PublicKey.
+func Decrypt(escrowKey *big.Int, e *Escrow) (*big.Int, error) {
+	if err := e.Verify(); err != nil {
+		return nil, err
+	}
+	if !schnorr.ScalarBaseMult(escrowKey).Equal(e.EscrowKey) {
+		return nil, fmt.Errorf("escrow is not encrypted to this key")
+	}
+
+	x := new(big.Int)
+	for i := range e.Bits {
+		// B - y*A is either infinity for 0 or G for 1
+		M := e.Bits[i].B.Sub(e.Bits[i].A.Mul(escrowKey))
+		switch {
+		case M.IsInfinity():
+		case M.Equal(G):
+			x.SetBit(x, i, 1)
+		default:
+			return nil, fmt.Errorf("bit %d does not decrypt to 0 or 1", i)
+		}
+	}
+
+	if !schnorr.ScalarBaseMult(x).Equal(e.PublicKey) {
+		return nil, fmt.Errorf("decrypted key does not match the public key")
+	}
+	return x, nil
+}
+
+// MarshalBinary encodes the escrow as escrow key || public key ||
+// len(context) || context || bits || c || s
+func (e *Escrow) MarshalBinary() ([]byte, error) {
+	out := []byte{}
+	for _, p := range []*schnorr.Point{e.EscrowKey, e.PublicKey} {
+		b, err := p.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b[:]...)
+	}
+
+	out = appendUint32(out, uint32(len(e.Context)))
+	out = append(out, e.Context...)
+
+	for _, bit := range e.Bits {
+		for _, p := range []*schnorr.Point{bit.A, bit.B} {
+			b, err := p.PublicKey()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, b[:]...)
+		}
+		for _, k := range []*big.Int{bit.C0, bit.S0, bit.C1, bit.S1} {
+			out = append(out, schnorr.GetBigIntBytesImmutable(k)...)
+		}
+	}
+
+	out = append(out, schnorr.GetBigIntBytesImmutable(e.C)...)
+	return append(out, schnorr.GetBigIntBytesImmutable(e.S)...), nil
+}
+
+// UnmarshalBinary decodes an escrow encoded with MarshalBinary. The proofs
+// are not checked, call Verify for that.
+func (e *Escrow) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+
+	Y := d.point()
+	X := d.point()
+	context := d.next(int(d.uint32()))
+
+	bits := make([]BitCiphertext, Bits)
+	for i := range bits {
+		bits[i].A = d.point()
+		bits[i].B = d.point()
+		bits[i].C0 = d.scalar()
+		bits[i].S0 = d.scalar()
+		bits[i].C1 = d.scalar()
+		bits[i].S1 = d.scalar()
+	}
+
+	c := d.scalar()
+	s := d.scalar()
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) != 0 {
+		return fmt.Errorf("unexpected %d trailing bytes", len(d.data))
+	}
+
+	e.EscrowKey, e.PublicKey, e.Context, e.Bits, e.C, e.S = Y, X, append([]byte{}, context...), bits, c, s
+	return nil
+}
+
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.data) < n {
+		d.err = fmt.Errorf("escrow is truncated")
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func (d *decoder) point() *schnorr.Point {
+	b := d.next(33)
+	if b == nil {
+		return nil
+	}
+	var publickey [33]byte
+	copy(publickey[:], b)
+	p, err := schnorr.ParsePoint(publickey)
+	if err != nil {
+		d.err = err
+		return nil
+	}
+	return p
+}
+
+func (d *decoder) scalar() *big.Int {
+	b := d.next(32)
+	if b == nil {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
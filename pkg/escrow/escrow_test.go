@@ -0,0 +1,88 @@
+package escrow
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestEscrow(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("escrow"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	owner, escrowAgent := keys[0], keys[1]
+
+	// when
+	e, err := Encrypt(escrowAgent.PublicKey, owner.PrivateKey, []byte("backup of key 1"))
+	if err != nil {
+		t.Fatalf("Unexpected error from Encrypt: %v", err)
+	}
+
+	// then
+	publickey, _ := e.PublicKey.PublicKey()
+	if publickey != owner.PublicKey {
+		t.Fatalf("PublicKey = %x, want %x", publickey, owner.PublicKey)
+	}
+	if err := e.Verify(); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+
+	x, err := Decrypt(escrowAgent.PrivateKey, e)
+	if err != nil {
+		t.Fatalf("Unexpected error from Decrypt: %v", err)
+	}
+	if x.Cmp(owner.PrivateKey) != 0 {
+		t.Fatalf("Decrypt() = %x, want %x", x, owner.PrivateKey)
+	}
+
+	t.Run("Round trips through binary", func(t *testing.T) {
+		data, err := e.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Unexpected error from MarshalBinary: %v", err)
+		}
+		decoded := new(Escrow)
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Unexpected error from UnmarshalBinary: %v", err)
+		}
+		if err := decoded.Verify(); err != nil {
+			t.Fatalf("Verify() after round trip = %v, want nil", err)
+		}
+		if err := new(Escrow).UnmarshalBinary(data[:len(data)-1]); err == nil {
+			t.Fatalf("UnmarshalBinary() on truncated data = nil, want error")
+		}
+	})
+
+	t.Run("Wrong escrow key cannot decrypt", func(t *testing.T) {
+		if _, err := Decrypt(owner.PrivateKey, e); err == nil {
+			t.Fatalf("Decrypt() with wrong key = nil error, want error")
+		}
+	})
+
+	t.Run("Different public key fails", func(t *testing.T) {
+		tampered := *e
+		tampered.PublicKey = schnorr.ScalarBaseMult(big.NewInt(7))
+		if err := tampered.Verify(); err == nil {
+			t.Fatalf("Verify() with swapped public key = nil, want error")
+		}
+	})
+
+	t.Run("Different context fails", func(t *testing.T) {
+		tampered := *e
+		tampered.Context = []byte("backup of key 2")
+		if err := tampered.Verify(); err == nil {
+			t.Fatalf("Verify() with changed context = nil, want error")
+		}
+	})
+
+	t.Run("Non bit ciphertext fails", func(t *testing.T) {
+		tampered := *e
+		tampered.Bits = append([]BitCiphertext{}, e.Bits...)
+		tampered.Bits[3].B = tampered.Bits[3].B.Add(G).Add(G)
+		if err := tampered.Verify(); err == nil {
+			t.Fatalf("Verify() with a ciphertext of 2 = nil, want error")
+		}
+	})
+}
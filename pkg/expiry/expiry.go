@@ -0,0 +1,163 @@
+package expiry
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+)
+
+//
+// Finding signed envelopes that are about to expire and signing them again.
+// Envelopes are found either by walking a directory for json files which
+// parse as envelopes, or from a manifest listing their paths one per line.
+// Renewal keeps everything the old signature covered and only moves the
+// expiry, signing with whatever key the caller's SignFunc holds now.
+//
+
+// Status is where an envelope is in its lifetime
+type Status string
+
+const (
+	// StatusOK expires after the warning window
+	StatusOK Status = "ok"
+	// StatusExpiring expires within the warning window
+	StatusExpiring Status = "expiring"
+	// StatusExpired has expired
+	StatusExpired Status = "expired"
+	// StatusNoExpiry never expires
+	StatusNoExpiry Status = "no expiry"
+)
+
+// Item is one envelope found
+type Item struct {
+	Path     string
+	Envelope *envelope.Envelope
+}
+
+// Status is the item's status at now, warning within the window
+func (it Item) Status(now time.Time, within time.Duration) Status {
+	switch {
+	case it.Envelope.Expires == nil:
+		return StatusNoExpiry
+	case !now.Before(*it.Envelope.Expires):
+		return StatusExpired
+	case now.Add(within).After(*it.Envelope.Expires):
+		return StatusExpiring
+	}
+	return StatusOK
+}
+
+// ScanDir finds every envelope in the json files under dir. Json files
+// which are not envelopes are skipped.
+func ScanDir(dir string) ([]Item, error) {
+	items := []Item{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		e, err := readEnvelope(path)
+		if err != nil {
+			return nil
+		}
+		items = append(items, Item{Path: path, Envelope: e})
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+	return items, err
+}
+
+// ScanManifest reads the envelopes listed in the manifest, one path per
+// line relative to the manifest, skipping blank lines and # comments.
+// Unlike ScanDir every listed file must be an envelope.
+func ScanManifest(manifest string) ([]Item, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	items := []Item{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		path := line
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(manifest), filepath.FromSlash(line))
+		}
+		e, err := readEnvelope(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", line, err)
+		}
+		items = append(items, Item{Path: path, Envelope: e})
+	}
+	return items, scanner.Err()
+}
+
+func readEnvelope(path string) (*envelope.Envelope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e := new(envelope.Envelope)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	if e.PayloadType == "" || e.Signature == "" {
+		return nil, fmt.Errorf("not a signed envelope")
+	}
+	return e, nil
+}
+
+// Renew signs the item again to expire at expires and replaces the file,
+// keeping its mode. The old signature must still verify, expired or not, so
+// renewal never launders a tampered envelope.
+func Renew(it Item, sign envelope.SignFunc, expires time.Time) (*envelope.Envelope, error) {
+	publickey, err := it.Envelope.Key()
+	if err != nil {
+		return nil, err
+	}
+	verifyAt := time.Now()
+	if it.Envelope.Expires != nil {
+		verifyAt = *it.Envelope.Expires
+	}
+	if err := envelope.Verify(it.Envelope, publickey, envelope.VerifyAt(verifyAt)); err != nil {
+		return nil, fmt.Errorf("%s: %v", it.Path, err)
+	}
+
+	renewed, err := envelope.Renew(it.Envelope, sign, envelope.WithExpiry(expires))
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(renewed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(it.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp := it.Path + ".renew"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, it.Path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return renewed, nil
+}
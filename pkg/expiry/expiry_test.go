@@ -0,0 +1,149 @@
+package expiry
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestScanAndRenew(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("expiry"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	now := time.Now()
+	dir := t.TempDir()
+	for name, expires := range map[string]*time.Time{
+		"fresh.json":    timePtr(now.Add(90 * 24 * time.Hour)),
+		"soon.json":     timePtr(now.Add(24 * time.Hour)),
+		"stale.json":    timePtr(now.Add(-time.Hour)),
+		"forever.json":  nil,
+		"notes/a.json":  nil,
+		"notes/b.txt":   nil,
+		"settings.json": nil,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Unexpected error from MkdirAll: %v", err)
+		}
+		var data []byte
+		if name == "settings.json" || name == "notes/b.txt" {
+			data = []byte(`{"theme":"dark"}`)
+		} else {
+			opts := []envelope.Option{envelope.WithAnnotations(map[string]string{"name": name})}
+			if expires != nil {
+				opts = append(opts, envelope.WithExpiry(*expires))
+			}
+			e, err := envelope.Sign(keys[0].PrivateKey, "text/plain", []byte(name), opts...)
+			if err != nil {
+				t.Fatalf("Unexpected error from Sign: %v", err)
+			}
+			data, _ = json.Marshal(e)
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("Unexpected error from WriteFile: %v", err)
+		}
+	}
+
+	// when
+	items, err := ScanDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error from ScanDir: %v", err)
+	}
+
+	// then
+	want := map[string]Status{
+		"fresh.json":   StatusOK,
+		"soon.json":    StatusExpiring,
+		"stale.json":   StatusExpired,
+		"forever.json": StatusNoExpiry,
+		"notes/a.json": StatusNoExpiry,
+	}
+	if len(items) != len(want) {
+		t.Fatalf("ScanDir() found %d envelopes, want %d", len(items), len(want))
+	}
+	for _, it := range items {
+		rel, _ := filepath.Rel(dir, it.Path)
+		if got := it.Status(now, 30*24*time.Hour); got != want[filepath.ToSlash(rel)] {
+			t.Fatalf("Status(%s) = %s, want %s", rel, got, want[filepath.ToSlash(rel)])
+		}
+	}
+
+	t.Run("renew", func(t *testing.T) {
+		// given
+		stale := Item{}
+		for _, it := range items {
+			if filepath.Base(it.Path) == "stale.json" {
+				stale = it
+			}
+		}
+		if err := envelope.Verify(stale.Envelope, keys[0].PublicKey); !errors.Is(err, envelope.ErrExpired) {
+			t.Fatalf("Verify() error = %v, want %v", err, envelope.ErrExpired)
+		}
+		sign := func(digest [32]byte) ([64]byte, [33]byte, error) {
+			sig, err := schnorr.Sign(keys[1].PrivateKey, digest)
+			return sig, keys[1].PublicKey, err
+		}
+
+		// when
+		if _, err := Renew(stale, sign, now.Add(90*24*time.Hour)); err != nil {
+			t.Fatalf("Unexpected error from Renew: %v", err)
+		}
+		renewed, err := readEnvelope(stale.Path)
+		if err != nil {
+			t.Fatalf("Unexpected error from readEnvelope: %v", err)
+		}
+
+		// then
+		if err := envelope.Verify(renewed, keys[1].PublicKey); err != nil {
+			t.Fatalf("Unexpected error from Verify: %v", err)
+		}
+		if string(renewed.Payload) != "stale.json" || renewed.Annotations["name"] != "stale.json" {
+			t.Fatalf("Renew() = %+v, want the payload and annotations kept", renewed)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		// given
+		it := items[0]
+		it.Envelope.Payload = []byte("something else")
+
+		// when
+		_, err := Renew(it, nil, now.Add(time.Hour))
+
+		// then
+		if err == nil {
+			t.Fatalf("Renew() re-signed a tampered envelope")
+		}
+	})
+
+	t.Run("manifest", func(t *testing.T) {
+		// given
+		manifest := filepath.Join(dir, "attestations.txt")
+		if err := os.WriteFile(manifest, []byte("# refreshed monthly\nfresh.json\n\nnotes/a.json\n"), 0644); err != nil {
+			t.Fatalf("Unexpected error from WriteFile: %v", err)
+		}
+
+		// when
+		listed, err := ScanManifest(manifest)
+
+		// then
+		if err != nil {
+			t.Fatalf("Unexpected error from ScanManifest: %v", err)
+		}
+		if len(listed) != 2 {
+			t.Fatalf("ScanManifest() found %d envelopes, want 2", len(listed))
+		}
+	})
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,25 @@
+package frost
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BlameError is returned when a signing attempt fails because of what some
+// participants sent. Coordinators can leave them out and try again.
+type BlameError struct {
+	Participants []uint32
+	Reason       string
+}
+
+func blame(reason string, participants ...uint32) *BlameError {
+	return &BlameError{Participants: participants, Reason: reason}
+}
+
+func (e *BlameError) Error() string {
+	ids := make([]string, len(e.Participants))
+	for i, id := range e.Participants {
+		ids[i] = fmt.Sprint(id)
+	}
+	return fmt.Sprintf("participants %s %s", strings.Join(ids, ", "), e.Reason)
+}
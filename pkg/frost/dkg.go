@@ -0,0 +1,314 @@
+package frost
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Distributed key generation, the Pedersen DKG of the FROST paper as in
+// RFC 9591 appendix C: every participant deals a random secret as a dealer
+// would, proves it knows the secret so nobody can cancel out the others'
+// parts, and the key is the sum of all of them. No one ever holds the whole
+// key. Every message in or out is recorded in a transcript for auditors.
+//
+
+// Round1Package is a participant's public commitments to its polynomial and
+// a proof of knowledge (R, Z) of the constant term, broadcast to everyone
+type Round1Package struct {
+	From        uint32
+	Commitments []*schnorr.Point
+	R           *schnorr.Point
+	Z           *big.Int
+}
+
+// Round2Package is the shares one participant deals another, sent privately
+type Round2Package struct {
+	From   uint32
+	To     uint32
+	Shares []SecretShare
+}
+
+// DKG is one participant's side of a key generation. Round1 has to be
+// broadcast, Round2 sent to each participant privately, then once all
+// packages are received Finish gives the key share.
+type DKG struct {
+	id           uint32
+	policy       Policy
+	context      []byte
+	indices      map[uint32][]uint32
+	coefficients []*big.Int
+
+	round1     map[uint32]*Round1Package
+	round2     map[uint32]*Round2Package
+	transcript *DKGTranscript
+}
+
+// NewDKG starts key generation for participant id. The context must be
+// unique to the ceremony, such as a ceremony id, so proofs can't be
+// replayed from another one.
+func NewDKG(id uint32, policy Policy, context []byte) (*DKG, *Round1Package, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if _, ok := policy.Weights[id]; !ok {
+		return nil, nil, fmt.Errorf("participant %d is not part of the policy", id)
+	}
+
+	d := &DKG{
+		id:      id,
+		policy:  policy,
+		context: append([]byte{}, context...),
+		indices: policy.indices(),
+		round1:  map[uint32]*Round1Package{},
+		round2:  map[uint32]*Round2Package{},
+	}
+	d.transcript = newDKGTranscript(id, policy, context)
+
+	for i := 0; i < policy.Threshold; i++ {
+		a, err := randomScalar()
+		if err != nil {
+			return nil, nil, err
+		}
+		d.coefficients = append(d.coefficients, a)
+	}
+
+	p := &Round1Package{From: id}
+	for _, a := range d.coefficients {
+		p.Commitments = append(p.Commitments, schnorr.ScalarBaseMult(a))
+	}
+
+	k, err := randomScalar()
+	if err != nil {
+		return nil, nil, err
+	}
+	p.R = schnorr.ScalarBaseMult(k)
+	c, err := proofChallenge(context, id, p.Commitments[0], p.R)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.Z = new(big.Int).Mul(c, d.coefficients[0])
+	p.Z.Add(p.Z, k).Mod(p.Z, schnorr.Curve.N)
+
+	if err := d.ReceiveRound1(p); err != nil {
+		return nil, nil, err
+	}
+	return d, p, nil
+}
+
+// proofChallenge binds the proof of knowledge to the ceremony and the
+// participant
+func proofChallenge(context []byte, id uint32, secretCommitment, r *schnorr.Point) (*big.Int, error) {
+	c0, err := secretCommitment.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	rb, err := r.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	h := schnorr.TaggedHash("FROST/dkg-pok", appendUint32(nil, uint32(len(context))), context, appendUint32(nil, id), c0[:], rb[:])
+	c := new(big.Int).SetBytes(h[:])
+	return c.Mod(c, schnorr.Curve.N), nil
+}
+
+// verifyRound1 checks the package's shape and proof of knowledge
+func verifyRound1(policy Policy, context []byte, p *Round1Package) error {
+	if _, ok := policy.Weights[p.From]; !ok {
+		return fmt.Errorf("participant %d is not part of the policy", p.From)
+	}
+	if len(p.Commitments) != policy.Threshold {
+		return fmt.Errorf("participant %d sent %d commitments, want %d", p.From, len(p.Commitments), policy.Threshold)
+	}
+	for _, c := range p.Commitments {
+		if c == nil || c.IsInfinity() {
+			return fmt.Errorf("participant %d sent an invalid commitment", p.From)
+		}
+	}
+	if p.R == nil || p.R.IsInfinity() || p.Z == nil || p.Z.Cmp(schnorr.Curve.N) >= 0 {
+		return fmt.Errorf("participant %d sent an invalid proof of knowledge", p.From)
+	}
+
+	c, err := proofChallenge(context, p.From, p.Commitments[0], p.R)
+	if err != nil {
+		return err
+	}
+	// z*G = R + c*C_0
+	if !schnorr.ScalarBaseMult(p.Z).Equal(p.R.Add(p.Commitments[0].Mul(c))) {
+		return fmt.Errorf("participant %d proof of knowledge does not verify", p.From)
+	}
+	return nil
+}
+
+// ReceiveRound1 checks and keeps another participant's round 1 package.
+// Failures blame the sender.
+func (d *DKG) ReceiveRound1(p *Round1Package) error {
+	err := verifyRound1(d.policy, d.context, p)
+	if err == nil && d.round1[p.From] != nil {
+		err = fmt.Errorf("participant %d sent round 1 twice", p.From)
+	}
+	d.transcript.received(1, p.From, d.id, p.Hash(), "round 1 proof of knowledge", err)
+	if err != nil {
+		return blame(err.Error(), p.From)
+	}
+
+	d.round1[p.From] = p
+	d.transcript.Round1 = append(d.transcript.Round1, p)
+	return nil
+}
+
+// Round2 deals this participant's polynomial to everyone else, once every
+// round 1 package is in
+func (d *DKG) Round2() ([]*Round2Package, error) {
+	if missing := d.missing(d.round1Has); len(missing) > 0 {
+		return nil, blame("have not sent round 1", missing...)
+	}
+
+	packages := []*Round2Package{}
+	for _, id := range d.policy.Participants() {
+		p := &Round2Package{From: d.id, To: id}
+		for _, index := range d.indices[id] {
+			p.Shares = append(p.Shares, SecretShare{Index: index, Value: evaluate(d.coefficients, index)})
+		}
+		if id == d.id {
+			if err := d.ReceiveRound2(p); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		d.transcript.sent(2, id, p.Hash())
+		packages = append(packages, p)
+	}
+	return packages, nil
+}
+
+// ReceiveRound2 checks the shares another participant dealt us against its
+// round 1 commitments. Failures blame the sender.
+func (d *DKG) ReceiveRound2(p *Round2Package) error {
+	var err error
+	sender, ok := d.round1[p.From]
+	switch {
+	case p.To != d.id:
+		err = fmt.Errorf("round 2 package for %d delivered to %d", p.To, d.id)
+	case !ok:
+		err = fmt.Errorf("round 2 from %d before its round 1", p.From)
+	case d.round2[p.From] != nil:
+		err = fmt.Errorf("participant %d sent round 2 twice", p.From)
+	default:
+		dealt := &PublicKeyPackage{Indices: d.indices, Commitments: sender.Commitments}
+		err = dealt.VerifyKeyShare(&KeyShare{ID: d.id, Shares: p.Shares})
+	}
+	d.transcript.received(2, p.From, d.id, p.Hash(), "round 2 shares match commitments", err)
+	if err != nil {
+		return blame(err.Error(), p.From)
+	}
+
+	d.round2[p.From] = p
+	return nil
+}
+
+func (d *DKG) round1Has(id uint32) bool { return d.round1[id] != nil }
+func (d *DKG) round2Has(id uint32) bool { return d.round2[id] != nil }
+
+func (d *DKG) missing(has func(uint32) bool) []uint32 {
+	missing := []uint32{}
+	for _, id := range d.policy.Participants() {
+		if !has(id) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// Finish sums the shares dealt to this participant into its key share and
+// the commitments of everyone into the public key package
+func (d *DKG) Finish() (*KeyShare, *PublicKeyPackage, error) {
+	if missing := d.missing(d.round2Has); len(missing) > 0 {
+		return nil, nil, blame("have not sent round 2", missing...)
+	}
+
+	ks := &KeyShare{ID: d.id}
+	for _, index := range d.indices[d.id] {
+		ks.Shares = append(ks.Shares, SecretShare{Index: index, Value: new(big.Int)})
+	}
+	for _, p := range d.round2 {
+		for i, share := range p.Shares {
+			ks.Shares[i].Value.Add(ks.Shares[i].Value, share.Value)
+			ks.Shares[i].Value.Mod(ks.Shares[i].Value, schnorr.Curve.N)
+		}
+	}
+
+	pkg, err := combineRound1(d.policy, d.transcript.Round1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := pkg.VerifyKeyShare(ks); err != nil {
+		return nil, nil, err
+	}
+
+	d.transcript.finish(pkg)
+	return ks, pkg, nil
+}
+
+// combineRound1 sums the commitments of every participant into the public
+// key package
+func combineRound1(policy Policy, round1 []*Round1Package) (*PublicKeyPackage, error) {
+	commitments := make([]*schnorr.Point, policy.Threshold)
+	for i := range commitments {
+		commitments[i] = schnorr.Infinity
+		for _, p := range round1 {
+			commitments[i] = commitments[i].Add(p.Commitments[i])
+		}
+	}
+	if commitments[0].IsInfinity() {
+		return nil, fmt.Errorf("group key is infinity")
+	}
+
+	return &PublicKeyPackage{
+		GroupKey:    commitments[0],
+		Policy:      policy,
+		Indices:     policy.indices(),
+		Commitments: commitments,
+	}, nil
+}
+
+// Transcript is the record of the ceremony so far, as this participant saw
+// it
+func (d *DKG) Transcript() *DKGTranscript {
+	return d.transcript
+}
+
+// Hash identifies the package in transcripts
+func (p *Round1Package) Hash() [32]byte {
+	data := appendUint32(nil, p.From)
+	for _, c := range p.Commitments {
+		b, _ := c.PublicKey()
+		data = append(data, b[:]...)
+	}
+	if p.R != nil {
+		r, _ := p.R.PublicKey()
+		data = append(data, r[:]...)
+	}
+	if p.Z != nil {
+		data = append(data, schnorr.GetBigIntBytesImmutable(p.Z)...)
+	}
+	return schnorr.TaggedHash("FROST/dkg-round1", data)
+}
+
+// Hash identifies the package in transcripts without revealing the shares
+func (p *Round2Package) Hash() [32]byte {
+	data := appendUint32(nil, p.From)
+	data = appendUint32(data, p.To)
+	for _, share := range p.Shares {
+		data = appendUint32(data, share.Index)
+		data = append(data, schnorr.GetBigIntBytesImmutable(share.Value)...)
+	}
+	return schnorr.TaggedHash("FROST/dkg-round2", data)
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,155 @@
+package frost
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// DKGTranscript is one participant's record of a key generation: every
+// round 1 package in full, the hash of every message sent or received, and
+// the outcome of every check made on them. Round 2 shares are secret, so
+// only their hashes are kept.
+type DKGTranscript struct {
+	Participant      uint32            `json:"participant"`
+	Context          string            `json:"context"`
+	Policy           Policy            `json:"policy"`
+	Started          time.Time         `json:"started"`
+	Finished         time.Time         `json:"finished"`
+	Round1           []*Round1Package  `json:"round1"`
+	Messages         []DKGMessage      `json:"messages"`
+	Checks           []DKGCheck        `json:"checks"`
+	PublicKeyPackage *PublicKeyPackage `json:"public_key_package,omitempty"`
+}
+
+// DKGMessage records one message sent or received
+type DKGMessage struct {
+	Time   time.Time `json:"time"`
+	Round  int       `json:"round"`
+	From   uint32    `json:"from"`
+	To     uint32    `json:"to"`
+	SHA256 string    `json:"sha256"`
+}
+
+// DKGCheck records the outcome of one check on a received message
+type DKGCheck struct {
+	Time        time.Time `json:"time"`
+	Participant uint32    `json:"participant"`
+	Check       string    `json:"check"`
+	OK          bool      `json:"ok"`
+	Error       string    `json:"error,omitempty"`
+}
+
+func newDKGTranscript(id uint32, policy Policy, context []byte) *DKGTranscript {
+	return &DKGTranscript{
+		Participant: id,
+		Context:     hex.EncodeToString(context),
+		Policy:      policy,
+		Started:     now(),
+		Round1:      []*Round1Package{},
+		Messages:    []DKGMessage{},
+		Checks:      []DKGCheck{},
+	}
+}
+
+func (t *DKGTranscript) sent(round int, to uint32, hash [32]byte) {
+	t.Messages = append(t.Messages, DKGMessage{Time: now(), Round: round, From: t.Participant, To: to, SHA256: hex.EncodeToString(hash[:])})
+}
+
+func (t *DKGTranscript) received(round int, from, to uint32, hash [32]byte, check string, err error) {
+	at := now()
+	t.Messages = append(t.Messages, DKGMessage{Time: at, Round: round, From: from, To: to, SHA256: hex.EncodeToString(hash[:])})
+
+	c := DKGCheck{Time: at, Participant: from, Check: check, OK: err == nil}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	t.Checks = append(t.Checks, c)
+}
+
+func (t *DKGTranscript) finish(pkg *PublicKeyPackage) {
+	t.Finished = now()
+	t.PublicKeyPackage = pkg
+}
+
+// VerifyDKGTranscript re-checks a finished transcript after the fact: the
+// proofs of knowledge, that every recorded hash matches, that every message
+// the participant needed is there and passed its checks, and that the
+// public key package is what the round 1 packages add up to. Round 2 shares
+// can only be checked by their recipients, so for those the recorded check
+// is trusted.
+func VerifyDKGTranscript(t *DKGTranscript) (*PublicKeyPackage, error) {
+	if err := t.Policy.Validate(); err != nil {
+		return nil, err
+	}
+	if _, ok := t.Policy.Weights[t.Participant]; !ok {
+		return nil, fmt.Errorf("participant %d is not part of the policy", t.Participant)
+	}
+	context, err := hex.DecodeString(t.Context)
+	if err != nil {
+		return nil, fmt.Errorf("context is not hex")
+	}
+	if t.PublicKeyPackage == nil || t.Finished.IsZero() {
+		return nil, fmt.Errorf("key generation did not finish")
+	}
+	if t.Finished.Before(t.Started) {
+		return nil, fmt.Errorf("finished at %v, before it started at %v", t.Finished, t.Started)
+	}
+
+	for _, c := range t.Checks {
+		if !c.OK {
+			return nil, fmt.Errorf("check %q on participant %d failed: %s", c.Check, c.Participant, c.Error)
+		}
+	}
+
+	// which hashes arrived in each round from whom
+	received := map[int]map[uint32]string{1: {}, 2: {}}
+	for _, m := range t.Messages {
+		if m.To != t.Participant || received[m.Round] == nil {
+			continue
+		}
+		if _, dup := received[m.Round][m.From]; dup {
+			return nil, fmt.Errorf("round %d from %d recorded twice", m.Round, m.From)
+		}
+		received[m.Round][m.From] = m.SHA256
+	}
+
+	seen := map[uint32]bool{}
+	for _, p := range t.Round1 {
+		if seen[p.From] {
+			return nil, fmt.Errorf("two round 1 packages from %d", p.From)
+		}
+		seen[p.From] = true
+
+		if err := verifyRound1(t.Policy, context, p); err != nil {
+			return nil, err
+		}
+		hash := p.Hash()
+		if received[1][p.From] != hex.EncodeToString(hash[:]) {
+			return nil, fmt.Errorf("round 1 package from %d does not match its recorded hash", p.From)
+		}
+	}
+
+	for _, id := range t.Policy.Participants() {
+		if !seen[id] {
+			return nil, fmt.Errorf("no round 1 package from %d", id)
+		}
+		if _, ok := received[2][id]; !ok {
+			return nil, fmt.Errorf("no round 2 package from %d", id)
+		}
+	}
+
+	pkg, err := combineRound1(t.Policy, t.Round1)
+	if err != nil {
+		return nil, err
+	}
+	if !pkg.GroupKey.Equal(t.PublicKeyPackage.GroupKey) || len(pkg.Commitments) != len(t.PublicKeyPackage.Commitments) {
+		return nil, fmt.Errorf("recorded group key is not the sum of the round 1 commitments")
+	}
+	for i := range pkg.Commitments {
+		if !pkg.Commitments[i].Equal(t.PublicKeyPackage.Commitments[i]) {
+			return nil, fmt.Errorf("recorded commitment %d is not the sum of the round 1 commitments", i)
+		}
+	}
+	return pkg, nil
+}
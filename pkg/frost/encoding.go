@@ -0,0 +1,255 @@
+package frost
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+type publicKeyPackageJSON struct {
+	GroupKey    string              `json:"group_key"`
+	Policy      Policy              `json:"policy"`
+	Indices     map[uint32][]uint32 `json:"indices"`
+	Commitments []string            `json:"commitments"`
+}
+
+// MarshalJSON encodes the points as hex compressed keys
+func (pkg *PublicKeyPackage) MarshalJSON() ([]byte, error) {
+	out := publicKeyPackageJSON{Policy: pkg.Policy, Indices: pkg.Indices}
+
+	groupKey, err := pkg.GroupKey.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	out.GroupKey = hex.EncodeToString(groupKey[:])
+
+	for _, c := range pkg.Commitments {
+		b, err := c.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		out.Commitments = append(out.Commitments, hex.EncodeToString(b[:]))
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a package and checks it is consistent
+func (pkg *PublicKeyPackage) UnmarshalJSON(data []byte) error {
+	in := publicKeyPackageJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if err := in.Policy.Validate(); err != nil {
+		return err
+	}
+
+	groupKey, err := parseHexPoint(in.GroupKey)
+	if err != nil {
+		return fmt.Errorf("group key: %v", err)
+	}
+
+	commitments := []*schnorr.Point{}
+	for i, c := range in.Commitments {
+		p, err := parseHexPoint(c)
+		if err != nil {
+			return fmt.Errorf("commitment %d: %v", i, err)
+		}
+		commitments = append(commitments, p)
+	}
+	if len(commitments) != in.Policy.Threshold || !commitments[0].Equal(groupKey) {
+		return fmt.Errorf("commitments do not match the policy and group key")
+	}
+
+	expected := in.Policy.indices()
+	for id, indices := range expected {
+		if fmt.Sprint(in.Indices[id]) != fmt.Sprint(indices) {
+			return fmt.Errorf("participant %d has share indices %v, want %v", id, in.Indices[id], indices)
+		}
+	}
+
+	pkg.GroupKey, pkg.Policy, pkg.Indices, pkg.Commitments = groupKey, in.Policy, expected, commitments
+	return nil
+}
+
+func parseHexPoint(s string) (*schnorr.Point, error) {
+	var b [33]byte
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != 33 {
+		return nil, fmt.Errorf("not 33 bytes of hex")
+	}
+	copy(b[:], raw)
+	return schnorr.ParsePoint(b)
+}
+
+// Bytes encodes the commitment as D || E, the same 66 bytes as a MuSig
+// public nonce
+func (c SigningCommitment) Bytes() ([66]byte, error) {
+	var out [66]byte
+	d, err := c.D.PublicKey()
+	if err != nil {
+		return out, err
+	}
+	e, err := c.E.PublicKey()
+	if err != nil {
+		return out, err
+	}
+	copy(out[:33], d[:])
+	copy(out[33:], e[:])
+	return out, nil
+}
+
+// ParseSigningCommitment decodes D || E for the participant
+func ParseSigningCommitment(id uint32, b [66]byte) (SigningCommitment, error) {
+	var d, e [33]byte
+	copy(d[:], b[:33])
+	copy(e[:], b[33:])
+
+	D, err := schnorr.ParsePoint(d)
+	if err != nil {
+		return SigningCommitment{}, err
+	}
+	E, err := schnorr.ParsePoint(e)
+	if err != nil {
+		return SigningCommitment{}, err
+	}
+	return SigningCommitment{ID: id, D: D, E: E}, nil
+}
+
+type keyShareJSON struct {
+	ID     uint32 `json:"id"`
+	Shares []struct {
+		Index uint32 `json:"index"`
+		Value string `json:"value"`
+	} `json:"shares"`
+}
+
+// MarshalJSON encodes the share values as hex. The result is secret.
+func (ks *KeyShare) MarshalJSON() ([]byte, error) {
+	out := keyShareJSON{ID: ks.ID}
+	for _, share := range ks.Shares {
+		out.Shares = append(out.Shares, struct {
+			Index uint32 `json:"index"`
+			Value string `json:"value"`
+		}{share.Index, hex.EncodeToString(schnorr.GetBigIntBytesImmutable(share.Value))})
+	}
+	return json.Marshal(out)
+}
+
+func (ks *KeyShare) UnmarshalJSON(data []byte) error {
+	in := keyShareJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	shares := []SecretShare{}
+	for _, share := range in.Shares {
+		raw, err := hex.DecodeString(share.Value)
+		if err != nil || len(raw) != 32 {
+			return fmt.Errorf("share %d is not 32 bytes of hex", share.Index)
+		}
+		value := new(big.Int).SetBytes(raw)
+		if value.Cmp(schnorr.Curve.N) >= 0 {
+			return fmt.Errorf("share %d is larger than or equal to curve order N", share.Index)
+		}
+		shares = append(shares, SecretShare{Index: share.Index, Value: value})
+	}
+
+	ks.ID, ks.Shares = in.ID, shares
+	return nil
+}
+
+type round1PackageJSON struct {
+	From        uint32   `json:"from"`
+	Commitments []string `json:"commitments"`
+	R           string   `json:"r"`
+	Z           string   `json:"z"`
+}
+
+// MarshalJSON encodes the points as hex compressed keys and z as 32 bytes
+// of hex
+func (p *Round1Package) MarshalJSON() ([]byte, error) {
+	out := round1PackageJSON{From: p.From}
+	for _, c := range p.Commitments {
+		b, err := c.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		out.Commitments = append(out.Commitments, hex.EncodeToString(b[:]))
+	}
+	r, err := p.R.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	out.R = hex.EncodeToString(r[:])
+	out.Z = hex.EncodeToString(schnorr.GetBigIntBytesImmutable(p.Z))
+	return json.Marshal(out)
+}
+
+func (p *Round1Package) UnmarshalJSON(data []byte) error {
+	in := round1PackageJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	commitments := []*schnorr.Point{}
+	for i, c := range in.Commitments {
+		point, err := parseHexPoint(c)
+		if err != nil {
+			return fmt.Errorf("commitment %d: %v", i, err)
+		}
+		commitments = append(commitments, point)
+	}
+	r, err := parseHexPoint(in.R)
+	if err != nil {
+		return fmt.Errorf("r: %v", err)
+	}
+	raw, err := hex.DecodeString(in.Z)
+	if err != nil || len(raw) != 32 {
+		return fmt.Errorf("z is not 32 bytes of hex")
+	}
+
+	p.From, p.Commitments, p.R, p.Z = in.From, commitments, r, new(big.Int).SetBytes(raw)
+	return nil
+}
+
+type signingNoncesJSON struct {
+	D string `json:"d"`
+	E string `json:"e"`
+}
+
+// MarshalJSON encodes the nonces as hex, so a signer can keep them between
+// the rounds. The result is secret, and must be deleted once it has signed:
+// nonces restored from a copy would sign a second time.
+func (n *SigningNonces) MarshalJSON() ([]byte, error) {
+	if n.d == nil || n.e == nil {
+		return nil, fmt.Errorf("signing nonces have already been used")
+	}
+	return json.Marshal(signingNoncesJSON{
+		D: hex.EncodeToString(schnorr.GetBigIntBytesImmutable(n.d)),
+		E: hex.EncodeToString(schnorr.GetBigIntBytesImmutable(n.e)),
+	})
+}
+
+func (n *SigningNonces) UnmarshalJSON(data []byte) error {
+	in := signingNoncesJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	scalars := make([]*big.Int, 2)
+	for i, s := range []string{in.D, in.E} {
+		raw, err := hex.DecodeString(s)
+		if err != nil || len(raw) != 32 {
+			return fmt.Errorf("nonce is not 32 bytes of hex")
+		}
+		scalars[i] = new(big.Int).SetBytes(raw)
+		if scalars[i].Sign() == 0 || scalars[i].Cmp(schnorr.Curve.N) >= 0 {
+			return fmt.Errorf("nonce is not in the range 1..n-1")
+		}
+	}
+	n.d, n.e = scalars[0], scalars[1]
+	return nil
+}
@@ -0,0 +1,502 @@
+package frost
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	sg "github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func deal(t *testing.T, policy Policy) (map[uint32]*KeyShare, *PublicKeyPackage) {
+	keys, err := sg.GenerateTestKeys([]byte("frost"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+
+	shares, pkg, err := Deal(keys[0].PrivateKey, policy)
+	if err != nil {
+		t.Fatalf("Unexpected error from Deal: %v", err)
+	}
+
+	byID := map[uint32]*KeyShare{}
+	for _, ks := range shares {
+		if err := pkg.VerifyKeyShare(ks); err != nil {
+			t.Fatalf("VerifyKeyShare(%d) = %v, want nil", ks.ID, err)
+		}
+		byID[ks.ID] = ks
+	}
+	return byID, pkg
+}
+
+// sign runs both rounds with the signers and returns the signing package
+// error, if any
+func sign(t *testing.T, shares map[uint32]*KeyShare, pkg *PublicKeyPackage, signers ...uint32) error {
+	msg := sha256.Sum256([]byte("frost"))
+
+	nonces := map[uint32]*SigningNonces{}
+	commitments := []SigningCommitment{}
+	for _, id := range signers {
+		n, c, err := Commit(id)
+		if err != nil {
+			t.Fatalf("Unexpected error from Commit: %v", err)
+		}
+		nonces[id] = n
+		commitments = append(commitments, c)
+	}
+
+	sp, err := NewSigningPackage(pkg, commitments, msg[:])
+	if err != nil {
+		return err
+	}
+
+	zs := map[uint32][32]byte{}
+	for _, id := range signers {
+		z, err := sp.Sign(shares[id], nonces[id])
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign(%d): %v", id, err)
+		}
+		if err := sp.VerifyShare(id, z); err != nil {
+			t.Fatalf("VerifyShare(%d) = %v, want nil", id, err)
+		}
+		zs[id] = z
+	}
+
+	signature, err := sp.Aggregate(zs)
+	if err != nil {
+		t.Fatalf("Unexpected error from Aggregate: %v", err)
+	}
+
+	x, _ := pkg.GroupKey.XOnly()
+	pubKey, _ := schnorr.ParsePubKey(x[:])
+	sig, err := schnorr.ParseSignature(signature[:])
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseSignature: %v", err)
+	}
+	if !sig.Verify(msg[:], pubKey) {
+		t.Fatalf("Aggregate() = %x, does not verify", signature)
+	}
+	return nil
+}
+
+func TestThreshold(t *testing.T) {
+	// given
+	shares, pkg := deal(t, ThresholdPolicy(2, 3))
+
+	// then
+	for _, signers := range [][]uint32{{1, 2}, {1, 3}, {2, 3}, {1, 2, 3}} {
+		if err := sign(t, shares, pkg, signers...); err != nil {
+			t.Fatalf("NewSigningPackage(%v) = %v, want nil", signers, err)
+		}
+	}
+	if err := sign(t, shares, pkg, 2); err == nil {
+		t.Fatalf("NewSigningPackage([2]) = nil, want error")
+	}
+
+	t.Run("Bad share is caught", func(t *testing.T) {
+		n, c, _ := Commit(1)
+		_, c2, _ := Commit(2)
+		sp, err := NewSigningPackage(pkg, []SigningCommitment{c, c2}, []byte("m"))
+		if err != nil {
+			t.Fatalf("Unexpected error from NewSigningPackage: %v", err)
+		}
+		z, err := sp.Sign(shares[1], n)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		z[31] ^= 1
+		if err := sp.VerifyShare(1, z); err == nil {
+			t.Fatalf("VerifyShare() on a bad share = nil, want error")
+		}
+		if _, err := sp.Sign(shares[1], n); err == nil {
+			t.Fatalf("Sign() with used nonces = nil error, want error")
+		}
+	})
+}
+
+func TestWeighted(t *testing.T) {
+	// given the CFO (1) counts as 2, and 3 is needed
+	shares, pkg := deal(t, Policy{Threshold: 3, Weights: map[uint32]int{1: 2, 2: 1, 3: 1, 4: 1}})
+
+	if len(shares[1].Shares) != 2 || len(shares[2].Shares) != 1 {
+		t.Fatalf("Deal() gave %d and %d shares, want 2 and 1", len(shares[1].Shares), len(shares[2].Shares))
+	}
+
+	// then
+	for _, signers := range [][]uint32{{1, 2}, {1, 4}, {2, 3, 4}, {1, 2, 3, 4}} {
+		if err := sign(t, shares, pkg, signers...); err != nil {
+			t.Fatalf("NewSigningPackage(%v) = %v, want nil", signers, err)
+		}
+	}
+	for _, signers := range [][]uint32{{1}, {2, 3}, {3, 4}} {
+		if err := sign(t, shares, pkg, signers...); err == nil {
+			t.Fatalf("NewSigningPackage(%v) = nil, want error", signers)
+		}
+	}
+
+	t.Run("Required participant", func(t *testing.T) {
+		shares, pkg := deal(t, Policy{Threshold: 3, Weights: map[uint32]int{1: 2, 2: 1, 3: 1, 4: 1}, Required: []uint32{1}})
+		if err := sign(t, shares, pkg, 1, 3); err != nil {
+			t.Fatalf("NewSigningPackage([1 3]) = %v, want nil", err)
+		}
+		if err := sign(t, shares, pkg, 2, 3, 4); err == nil {
+			t.Fatalf("NewSigningPackage([2 3 4]) without the required participant = nil, want error")
+		}
+	})
+
+	t.Run("Invalid policies", func(t *testing.T) {
+		for _, p := range []Policy{
+			{Threshold: 4, Weights: map[uint32]int{1: 2, 2: 1}},
+			{Threshold: 1, Weights: map[uint32]int{1: 0}},
+			{Threshold: 1, Weights: map[uint32]int{0: 1}},
+			{Threshold: 1, Weights: map[uint32]int{1: 1}, Required: []uint32{2}},
+		} {
+			if err := p.Validate(); err == nil {
+				t.Fatalf("Validate(%v) = nil, want error", p)
+			}
+		}
+	})
+}
+
+func TestReshare(t *testing.T) {
+	// given a 2-of-3 key
+	shares, pkg := deal(t, ThresholdPolicy(2, 3))
+
+	// when 1 and 3 reshare it to a weighted 3 out of 4 between four people
+	policy := Policy{Threshold: 3, Weights: map[uint32]int{1: 2, 2: 1, 3: 1, 4: 1}}
+	reshare, err := NewReshare(pkg, []uint32{1, 3}, policy)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewReshare: %v", err)
+	}
+
+	dealings := []*Dealing{}
+	for _, id := range []uint32{1, 3} {
+		d, err := reshare.Deal(shares[id])
+		if err != nil {
+			t.Fatalf("Unexpected error from Deal(%d): %v", id, err)
+		}
+		dealings = append(dealings, d)
+	}
+
+	newPkg, err := reshare.PublicKeyPackage(dealings)
+	if err != nil {
+		t.Fatalf("Unexpected error from PublicKeyPackage: %v", err)
+	}
+	newShares := map[uint32]*KeyShare{}
+	for _, id := range policy.Participants() {
+		ks, err := reshare.Complete(id, dealings)
+		if err != nil {
+			t.Fatalf("Unexpected error from Complete(%d): %v", id, err)
+		}
+		if err := newPkg.VerifyKeyShare(ks); err != nil {
+			t.Fatalf("VerifyKeyShare(%d) = %v, want nil", id, err)
+		}
+		newShares[id] = ks
+	}
+
+	// then the group key is the same and the new shares sign under the new policy
+	if !newPkg.GroupKey.Equal(pkg.GroupKey) {
+		t.Fatalf("PublicKeyPackage() changed the group key")
+	}
+	if err := sign(t, newShares, newPkg, 1, 4); err != nil {
+		t.Fatalf("NewSigningPackage([1 4]) = %v, want nil", err)
+	}
+	if err := sign(t, newShares, newPkg, 2, 3); err == nil {
+		t.Fatalf("NewSigningPackage([2 3]) = nil, want error")
+	}
+
+	t.Run("Old and new shares do not mix", func(t *testing.T) {
+		if newShares[2].Shares[0].Value.Cmp(shares[2].Shares[0].Value) == 0 {
+			t.Fatalf("Complete() kept the old share")
+		}
+	})
+
+	t.Run("Too few dealers", func(t *testing.T) {
+		if _, err := NewReshare(pkg, []uint32{2}, policy); err == nil {
+			t.Fatalf("NewReshare() with one dealer = nil error, want error")
+		}
+	})
+
+	t.Run("Dealing another secret is caught", func(t *testing.T) {
+		bad, err := reshare.Deal(shares[3])
+		if err != nil {
+			t.Fatalf("Unexpected error from Deal: %v", err)
+		}
+		bad.Commitments[0] = bad.Commitments[0].Add(bad.Commitments[1])
+		if _, err := reshare.Complete(2, []*Dealing{dealings[0], bad}); err == nil {
+			t.Fatalf("Complete() with a bad dealing = nil error, want error")
+		}
+	})
+}
+
+func TestRepair(t *testing.T) {
+	// given participant 1, who counts as 2, lost its shares
+	shares, pkg := deal(t, Policy{Threshold: 3, Weights: map[uint32]int{1: 2, 2: 1, 3: 1, 4: 1}})
+	helpers := []uint32{2, 3, 4}
+
+	repair, err := NewRepair(pkg, helpers, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewRepair: %v", err)
+	}
+
+	// when
+	received := map[uint32][][]*big.Int{}
+	for _, id := range helpers {
+		pieces, err := repair.Split(shares[id])
+		if err != nil {
+			t.Fatalf("Unexpected error from Split(%d): %v", id, err)
+		}
+		for to, piece := range pieces {
+			received[to] = append(received[to], piece)
+		}
+	}
+
+	sums := [][]*big.Int{}
+	for _, id := range helpers {
+		sum, err := repair.Sum(id, received[id])
+		if err != nil {
+			t.Fatalf("Unexpected error from Sum(%d): %v", id, err)
+		}
+		sums = append(sums, sum)
+	}
+
+	ks, err := repair.Recover(sums)
+	if err != nil {
+		t.Fatalf("Unexpected error from Recover: %v", err)
+	}
+
+	// then
+	for i, share := range ks.Shares {
+		if share.Value.Cmp(shares[1].Shares[i].Value) != 0 {
+			t.Fatalf("Recover() share %d = %x, want %x", share.Index, share.Value, shares[1].Shares[i].Value)
+		}
+	}
+	shares[1] = ks
+	if err := sign(t, shares, pkg, 1, 2); err != nil {
+		t.Fatalf("NewSigningPackage([1 2]) with the repaired share = %v, want nil", err)
+	}
+
+	t.Run("Not enough helpers", func(t *testing.T) {
+		if _, err := NewRepair(pkg, []uint32{2, 3}, 1); err == nil {
+			t.Fatalf("NewRepair() with weight 2 = nil error, want error")
+		}
+		if _, err := NewRepair(pkg, []uint32{1, 2}, 1); err == nil {
+			t.Fatalf("NewRepair() with the target helping = nil error, want error")
+		}
+	})
+
+	t.Run("Bad sum is caught", func(t *testing.T) {
+		bad := append([][]*big.Int{}, sums...)
+		bad[0] = []*big.Int{big.NewInt(1), big.NewInt(2)}
+		if _, err := repair.Recover(bad); err == nil {
+			t.Fatalf("Recover() with a bad sum = nil error, want error")
+		}
+	})
+}
+
+func TestBlame(t *testing.T) {
+	// given
+	shares, pkg := deal(t, ThresholdPolicy(3, 4))
+	msg := []byte("blame")
+
+	nonces := map[uint32]*SigningNonces{}
+	commitments := []SigningCommitment{}
+	for _, id := range []uint32{1, 2, 4} {
+		n, c, _ := Commit(id)
+		nonces[id] = n
+		commitments = append(commitments, c)
+	}
+	sp, err := NewSigningPackage(pkg, commitments, msg)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewSigningPackage: %v", err)
+	}
+
+	zs := map[uint32][32]byte{}
+	for id, n := range nonces {
+		zs[id], _ = sp.Sign(shares[id], n)
+	}
+
+	// when participant 4 sends garbage
+	z := zs[4]
+	z[0] ^= 0x10
+	zs[4] = z
+	_, err = sp.Aggregate(zs)
+
+	// then
+	blameErr, ok := err.(*BlameError)
+	if !ok || len(blameErr.Participants) != 1 || blameErr.Participants[0] != 4 {
+		t.Fatalf("Aggregate() = %v, want participant 4 blamed", err)
+	}
+
+	t.Run("Missing share", func(t *testing.T) {
+		delete(zs, 2)
+		_, err := sp.Aggregate(zs)
+		if blameErr, ok := err.(*BlameError); !ok || blameErr.Participants[0] != 2 {
+			t.Fatalf("Aggregate() = %v, want participant 2 blamed", err)
+		}
+	})
+
+	t.Run("Outsider commitment", func(t *testing.T) {
+		_, c, _ := Commit(9)
+		_, err := NewSigningPackage(pkg, append(commitments, c), msg)
+		if blameErr, ok := err.(*BlameError); !ok || blameErr.Participants[0] != 9 {
+			t.Fatalf("NewSigningPackage() = %v, want participant 9 blamed", err)
+		}
+	})
+}
+
+// runDKG runs a whole key generation in memory, with tamper applied to every
+// round 2 package on the way
+func runDKG(t *testing.T, policy Policy, tamper func(*Round2Package)) (map[uint32]*DKG, map[uint32]*KeyShare, *PublicKeyPackage, error) {
+	context := []byte("ceremony 1")
+	dkgs := map[uint32]*DKG{}
+	round1 := []*Round1Package{}
+	for _, id := range policy.Participants() {
+		d, p, err := NewDKG(id, policy, context)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewDKG(%d): %v", id, err)
+		}
+		dkgs[id] = d
+		round1 = append(round1, p)
+	}
+
+	for id, d := range dkgs {
+		for _, p := range round1 {
+			if p.From != id {
+				if err := d.ReceiveRound1(p); err != nil {
+					t.Fatalf("Unexpected error from ReceiveRound1: %v", err)
+				}
+			}
+		}
+	}
+
+	for _, d := range dkgs {
+		packages, err := d.Round2()
+		if err != nil {
+			t.Fatalf("Unexpected error from Round2: %v", err)
+		}
+		for _, p := range packages {
+			if tamper != nil {
+				tamper(p)
+			}
+			if err := dkgs[p.To].ReceiveRound2(p); err != nil {
+				return dkgs, nil, nil, err
+			}
+		}
+	}
+
+	shares := map[uint32]*KeyShare{}
+	var pkg *PublicKeyPackage
+	for id, d := range dkgs {
+		ks, p, err := d.Finish()
+		if err != nil {
+			return dkgs, nil, nil, err
+		}
+		shares[id], pkg = ks, p
+	}
+	return dkgs, shares, pkg, nil
+}
+
+func TestDKG(t *testing.T) {
+	// given
+	policy := Policy{Threshold: 3, Weights: map[uint32]int{1: 2, 2: 1, 3: 1}}
+
+	// when
+	dkgs, shares, pkg, err := runDKG(t, policy, nil)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from the DKG: %v", err)
+	}
+	if err := sign(t, shares, pkg, 1, 3); err != nil {
+		t.Fatalf("sign() with weight 3 = %v, want nil", err)
+	}
+
+	transcript := dkgs[2].Transcript()
+	if _, err := VerifyDKGTranscript(transcript); err != nil {
+		t.Fatalf("VerifyDKGTranscript() = %v, want nil", err)
+	}
+
+	t.Run("Transcript survives json", func(t *testing.T) {
+		data, err := json.Marshal(transcript)
+		if err != nil {
+			t.Fatalf("Unexpected error from json.Marshal: %v", err)
+		}
+		decoded := new(DKGTranscript)
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatalf("Unexpected error from json.Unmarshal: %v", err)
+		}
+		verified, err := VerifyDKGTranscript(decoded)
+		if err != nil {
+			t.Fatalf("VerifyDKGTranscript() after json = %v, want nil", err)
+		}
+		if !verified.GroupKey.Equal(pkg.GroupKey) {
+			t.Fatalf("VerifyDKGTranscript() group key differs from the DKG's")
+		}
+	})
+
+	t.Run("Swapped round 1 package is caught", func(t *testing.T) {
+		tampered := *transcript
+		tampered.Round1 = append([]*Round1Package{}, transcript.Round1...)
+		other := *tampered.Round1[0]
+		other.Z = new(big.Int).Add(other.Z, big.NewInt(1))
+		tampered.Round1[0] = &other
+		if _, err := VerifyDKGTranscript(&tampered); err == nil {
+			t.Fatalf("VerifyDKGTranscript() with a changed proof = nil, want error")
+		}
+	})
+
+	t.Run("Bad shares are blamed and recorded", func(t *testing.T) {
+		dkgs, _, _, err := runDKG(t, policy, func(p *Round2Package) {
+			if p.From == 2 && p.To == 3 {
+				p.Shares[0].Value = big.NewInt(42)
+			}
+		})
+		blamed := new(BlameError)
+		if !errors.As(err, &blamed) || len(blamed.Participants) != 1 || blamed.Participants[0] != 2 {
+			t.Fatalf("DKG with a bad share = %v, want participant 2 blamed", err)
+		}
+		if _, err := VerifyDKGTranscript(dkgs[3].Transcript()); err == nil {
+			t.Fatalf("VerifyDKGTranscript() of a failed ceremony = nil, want error")
+		}
+	})
+}
+
+func TestSigningNoncesJSON(t *testing.T) {
+	// given
+	shares, pkg := deal(t, ThresholdPolicy(2, 3))
+	msg := []byte("kept between rounds")
+	n1, c1, _ := Commit(1)
+	n2, c2, _ := Commit(2)
+
+	// when the nonces are saved and restored
+	data, err := json.Marshal(n1)
+	if err != nil {
+		t.Fatalf("Unexpected error from json.Marshal: %v", err)
+	}
+	restored := new(SigningNonces)
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unexpected error from json.Unmarshal: %v", err)
+	}
+
+	// then they sign as the originals would
+	sp, err := NewSigningPackage(pkg, []SigningCommitment{c1, c2}, msg)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewSigningPackage: %v", err)
+	}
+	z1, err := sp.Sign(shares[1], restored)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	z2, _ := sp.Sign(shares[2], n2)
+	if _, err := sp.Aggregate(map[uint32][32]byte{1: z1, 2: z2}); err != nil {
+		t.Fatalf("Unexpected error from Aggregate: %v", err)
+	}
+
+	if _, err := json.Marshal(restored); err == nil {
+		t.Fatalf("json.Marshal() of used nonces = nil error, want error")
+	}
+}
@@ -0,0 +1,232 @@
+package frost
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// https://www.rfc-editor.org/rfc/rfc9591.html with BIP-340 signatures
+//
+// Weighted policies give a participant as many Shamir shares as its weight,
+// so the threshold is on the total weight of the signers and is enforced by
+// the sharing itself: fewer shares than the threshold say nothing about the
+// key.
+//
+
+// Policy is the access structure of a key. Weights maps participant ids to
+// the number of shares they hold, Threshold is the total weight needed to
+// sign, and Required lists participants who must take part in every
+// signature. Required is enforced by the signing package, not the sharing.
+type Policy struct {
+	Threshold int            `json:"threshold"`
+	Weights   map[uint32]int `json:"weights"`
+	Required  []uint32       `json:"required,omitempty"`
+}
+
+// ThresholdPolicy is the plain t-of-n policy over participants 1..n
+func ThresholdPolicy(t, n int) Policy {
+	weights := map[uint32]int{}
+	for i := 1; i <= n; i++ {
+		weights[uint32(i)] = 1
+	}
+	return Policy{Threshold: t, Weights: weights}
+}
+
+// Validate checks the policy can be satisfied
+func (p Policy) Validate() error {
+	total := 0
+	for id, w := range p.Weights {
+		if id == 0 {
+			return fmt.Errorf("participant ids start at 1")
+		}
+		if w < 1 {
+			return fmt.Errorf("participant %d has weight %d, want at least 1", id, w)
+		}
+		total += w
+	}
+
+	if p.Threshold < 1 || p.Threshold > total {
+		return fmt.Errorf("threshold %d must be between 1 and the total weight %d", p.Threshold, total)
+	}
+	for _, id := range p.Required {
+		if _, ok := p.Weights[id]; !ok {
+			return fmt.Errorf("required participant %d has no weight", id)
+		}
+	}
+	return nil
+}
+
+// Participants returns the participant ids in ascending order
+func (p Policy) Participants() []uint32 {
+	ids := make([]uint32, 0, len(p.Weights))
+	for id := range p.Weights {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// indices hands out the x coordinates of the shares, consecutive for each
+// participant in id order
+func (p Policy) indices() map[uint32][]uint32 {
+	indices := map[uint32][]uint32{}
+	next := uint32(1)
+	for _, id := range p.Participants() {
+		for i := 0; i < p.Weights[id]; i++ {
+			indices[id] = append(indices[id], next)
+			next++
+		}
+	}
+	return indices
+}
+
+// SecretShare is one point on the sharing polynomial
+type SecretShare struct {
+	Index uint32
+	Value *big.Int
+}
+
+// KeyShare is everything a participant holds
+type KeyShare struct {
+	ID     uint32
+	Shares []SecretShare
+}
+
+// PublicKeyPackage is the public side of a sharing: the group key, the
+// policy, which share indices belong to whom, and the commitments to the
+// polynomial that let shares be checked
+type PublicKeyPackage struct {
+	GroupKey    *schnorr.Point
+	Policy      Policy
+	Indices     map[uint32][]uint32
+	Commitments []*schnorr.Point
+}
+
+// Deal is the trusted dealer key generation: the secret is split for the
+// policy and the dealer is expected to forget it
+func Deal(secret *big.Int, policy Policy) ([]*KeyShare, *PublicKeyPackage, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if secret.Sign() <= 0 || secret.Cmp(schnorr.Curve.N) >= 0 {
+		return nil, nil, fmt.Errorf("private key is not in the range 1..n-1")
+	}
+
+	// f(x) = secret + a_1 x + ... + a_{t-1} x^{t-1}
+	coefficients := []*big.Int{new(big.Int).Set(secret)}
+	for i := 1; i < policy.Threshold; i++ {
+		a, err := randomScalar()
+		if err != nil {
+			return nil, nil, err
+		}
+		coefficients = append(coefficients, a)
+	}
+
+	pkg := &PublicKeyPackage{
+		GroupKey: schnorr.ScalarBaseMult(secret),
+		Policy:   policy,
+		Indices:  policy.indices(),
+	}
+	for _, a := range coefficients {
+		pkg.Commitments = append(pkg.Commitments, schnorr.ScalarBaseMult(a))
+	}
+
+	shares := []*KeyShare{}
+	for _, id := range policy.Participants() {
+		ks := &KeyShare{ID: id}
+		for _, index := range pkg.Indices[id] {
+			ks.Shares = append(ks.Shares, SecretShare{Index: index, Value: evaluate(coefficients, index)})
+		}
+		shares = append(shares, ks)
+	}
+
+	return shares, pkg, nil
+}
+
+func randomScalar() (*big.Int, error) {
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(schnorr.Curve.N, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+// evaluate computes f(x) with Horner's rule
+func evaluate(coefficients []*big.Int, x uint32) *big.Int {
+	y := new(big.Int)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y.Mul(y, big.NewInt(int64(x)))
+		y.Add(y, coefficients[i])
+		y.Mod(y, schnorr.Curve.N)
+	}
+	return y
+}
+
+// VerifyingShare is the public key of the share at index, computed from the
+// commitments
+func (pkg *PublicKeyPackage) VerifyingShare(index uint32) *schnorr.Point {
+	y := schnorr.Infinity
+	for i := len(pkg.Commitments) - 1; i >= 0; i-- {
+		y = y.Mul(big.NewInt(int64(index))).Add(pkg.Commitments[i])
+	}
+	return y
+}
+
+// Interpolate checks the participants satisfy the policy, Required
+// included, and returns the Lagrange coefficient of every share index they
+// hold, for recovering something linear in the key, such as a threshold
+// decryption, from their shares
+func (pkg *PublicKeyPackage) Interpolate(ids []uint32) (map[uint32]*big.Int, error) {
+	seen := map[uint32]bool{}
+	weight := 0
+	indices := []uint32{}
+	for _, id := range ids {
+		if _, ok := pkg.Indices[id]; !ok {
+			return nil, blame("are not part of the key", id)
+		}
+		if seen[id] {
+			return nil, blame("took part more than once", id)
+		}
+		seen[id] = true
+		weight += pkg.Policy.Weights[id]
+		indices = append(indices, pkg.Indices[id]...)
+	}
+	if weight < pkg.Policy.Threshold {
+		return nil, fmt.Errorf("participants have weight %d, the policy needs %d", weight, pkg.Policy.Threshold)
+	}
+	for _, id := range pkg.Policy.Required {
+		if !seen[id] {
+			return nil, fmt.Errorf("required participant %d is not taking part", id)
+		}
+	}
+
+	lambda := map[uint32]*big.Int{}
+	for _, x := range indices {
+		lambda[x] = lagrange(x, indices)
+	}
+	return lambda, nil
+}
+
+// VerifyKeyShare checks every share a participant received against the
+// commitments, so a dealer can't hand out inconsistent shares
+func (pkg *PublicKeyPackage) VerifyKeyShare(ks *KeyShare) error {
+	indices := pkg.Indices[ks.ID]
+	if len(indices) != len(ks.Shares) {
+		return fmt.Errorf("participant %d has %d shares, want %d", ks.ID, len(ks.Shares), len(indices))
+	}
+
+	for i, share := range ks.Shares {
+		if share.Index != indices[i] {
+			return fmt.Errorf("participant %d share %d has index %d, want %d", ks.ID, i, share.Index, indices[i])
+		}
+		if !schnorr.ScalarBaseMult(share.Value).Equal(pkg.VerifyingShare(share.Index)) {
+			return fmt.Errorf("participant %d share %d does not match the commitments", ks.ID, share.Index)
+		}
+	}
+	return nil
+}
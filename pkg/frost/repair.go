@@ -0,0 +1,179 @@
+package frost
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Share repair after Laing and Stinson, "A Survey and Refinement of
+// Repairable Threshold Schemes". Helpers with enough weight each compute
+// their part of f(r) for the lost index r and split it into random pieces,
+// one per helper. Every helper sums the pieces it was sent and passes only
+// that sum on, so the lost participant learns f(r) and nobody learns more
+// than they already had.
+//
+
+// Repair is the public state of repairing the shares of one participant
+type Repair struct {
+	pkg     *PublicKeyPackage
+	target  uint32
+	helpers []uint32
+
+	// lambda[x][k] interpolates f(target index k) from helper index x
+	lambda map[uint32][]*big.Int
+}
+
+// NewRepair sets up the helpers, whose weight must meet the threshold, to
+// restore the shares of target
+func NewRepair(pkg *PublicKeyPackage, helpers []uint32, target uint32) (*Repair, error) {
+	targetIndices, ok := pkg.Indices[target]
+	if !ok {
+		return nil, fmt.Errorf("participant %d is not part of the key", target)
+	}
+
+	r := &Repair{pkg: pkg, target: target, lambda: map[uint32][]*big.Int{}}
+
+	weight := 0
+	seen := map[uint32]bool{}
+	indices := []uint32{}
+	for _, id := range helpers {
+		if _, ok := pkg.Indices[id]; !ok {
+			return nil, fmt.Errorf("participant %d is not part of the key", id)
+		}
+		if id == target {
+			return nil, fmt.Errorf("participant %d cannot help repair itself", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("participant %d is a helper twice", id)
+		}
+		seen[id] = true
+		weight += pkg.Policy.Weights[id]
+		indices = append(indices, pkg.Indices[id]...)
+		r.helpers = append(r.helpers, id)
+	}
+	if weight < pkg.Policy.Threshold {
+		return nil, fmt.Errorf("helpers have weight %d, the policy needs %d", weight, pkg.Policy.Threshold)
+	}
+
+	for _, x := range indices {
+		for _, at := range targetIndices {
+			r.lambda[x] = append(r.lambda[x], lagrangeAt(at, x, indices))
+		}
+	}
+	return r, nil
+}
+
+// lagrangeAt is the coefficient of f(x) when interpolating f(at) from the
+// points at indices
+func lagrangeAt(at, x uint32, indices []uint32) *big.Int {
+	num, den := big.NewInt(1), big.NewInt(1)
+	for _, j := range indices {
+		if j == x {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(at)-int64(j)))
+		den.Mul(den, big.NewInt(int64(x)-int64(j)))
+	}
+
+	num.Mod(num, schnorr.Curve.N)
+	den.Mod(den, schnorr.Curve.N)
+	num.Mul(num, den.ModInverse(den, schnorr.Curve.N))
+	return num.Mod(num, schnorr.Curve.N)
+}
+
+// Split is the first step of a helper: its part of the lost shares split
+// into random pieces, one for every helper including itself, each sent
+// privately to that helper. There is one value per lost share.
+func (r *Repair) Split(ks *KeyShare) (map[uint32][]*big.Int, error) {
+	if !r.isHelper(ks.ID) {
+		return nil, fmt.Errorf("participant %d is not a helper", ks.ID)
+	}
+	if err := r.pkg.VerifyKeyShare(ks); err != nil {
+		return nil, err
+	}
+
+	pieces := map[uint32][]*big.Int{}
+	for k := range r.pkg.Indices[r.target] {
+		part := new(big.Int)
+		for _, share := range ks.Shares {
+			part.Add(part, new(big.Int).Mul(r.lambda[share.Index][k], share.Value))
+		}
+
+		// random pieces for all but the last helper, which gets the rest
+		for _, id := range r.helpers[:len(r.helpers)-1] {
+			piece, err := randomScalar()
+			if err != nil {
+				return nil, err
+			}
+			pieces[id] = append(pieces[id], piece)
+			part.Sub(part, piece)
+		}
+		last := r.helpers[len(r.helpers)-1]
+		pieces[last] = append(pieces[last], part.Mod(part, schnorr.Curve.N))
+	}
+
+	return pieces, nil
+}
+
+// Sum is the second step of a helper: the pieces it received from every
+// helper added up, to be sent to the participant being repaired
+func (r *Repair) Sum(id uint32, received [][]*big.Int) ([]*big.Int, error) {
+	if !r.isHelper(id) {
+		return nil, fmt.Errorf("participant %d is not a helper", id)
+	}
+	return r.add(received)
+}
+
+func (r *Repair) add(values [][]*big.Int) ([]*big.Int, error) {
+	if len(values) != len(r.helpers) {
+		return nil, fmt.Errorf("got %d values for %d helpers", len(values), len(r.helpers))
+	}
+
+	n := len(r.pkg.Indices[r.target])
+	sums := make([]*big.Int, n)
+	for k := range sums {
+		sums[k] = new(big.Int)
+	}
+	for i, v := range values {
+		if len(v) != n {
+			return nil, fmt.Errorf("value %d has %d entries, want %d", i, len(v), n)
+		}
+		for k := range v {
+			sums[k].Add(sums[k], v[k])
+			sums[k].Mod(sums[k], schnorr.Curve.N)
+		}
+	}
+	return sums, nil
+}
+
+// Recover is the last step, run by the participant being repaired: the sums
+// from every helper added up into its shares, which are checked against the
+// public key package
+func (r *Repair) Recover(sums [][]*big.Int) (*KeyShare, error) {
+	values, err := r.add(sums)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeyShare{ID: r.target}
+	for k, index := range r.pkg.Indices[r.target] {
+		ks.Shares = append(ks.Shares, SecretShare{Index: index, Value: values[k]})
+	}
+
+	if err := r.pkg.VerifyKeyShare(ks); err != nil {
+		return nil, fmt.Errorf("repaired share is wrong: %v", err)
+	}
+	return ks, nil
+}
+
+func (r *Repair) isHelper(id uint32) bool {
+	for _, helper := range r.helpers {
+		if helper == id {
+			return true
+		}
+	}
+	return false
+}
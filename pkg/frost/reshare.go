@@ -0,0 +1,216 @@
+package frost
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Resharing: each dealer, an old holder, takes its interpolated part of the
+// secret, sum(lambda_x * s_x), and shares it afresh for the new policy with
+// commitments anyone can check. The new shares are the sums of what every
+// dealer sent, so the secret and group key stay the same while the old
+// shares become useless against the new ones. Refreshing is resharing to the
+// same policy.
+//
+
+// Reshare is the public state of one resharing, known to dealers and new
+// holders alike
+type Reshare struct {
+	old     *PublicKeyPackage
+	policy  Policy
+	indices map[uint32][]uint32
+	dealers []uint32
+	lambda  map[uint32]*big.Int
+}
+
+// Dealing is what one dealer hands out. Shares holds the new shares of every
+// new participant and must be sent to each of them privately; Commitments
+// are public.
+type Dealing struct {
+	From        uint32
+	Commitments []*schnorr.Point
+	Shares      map[uint32][]SecretShare
+}
+
+// NewReshare sets up resharing the key from the dealers, whose weight must
+// meet the old threshold, to the new policy
+func NewReshare(old *PublicKeyPackage, dealers []uint32, policy Policy) (*Reshare, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &Reshare{
+		old:     old,
+		policy:  policy,
+		indices: policy.indices(),
+		lambda:  map[uint32]*big.Int{},
+	}
+
+	weight := 0
+	seen := map[uint32]bool{}
+	indices := []uint32{}
+	for _, id := range dealers {
+		if _, ok := old.Indices[id]; !ok {
+			return nil, fmt.Errorf("participant %d is not part of the key", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("participant %d is a dealer twice", id)
+		}
+		seen[id] = true
+		weight += old.Policy.Weights[id]
+		indices = append(indices, old.Indices[id]...)
+		r.dealers = append(r.dealers, id)
+	}
+	if weight < old.Policy.Threshold {
+		return nil, fmt.Errorf("dealers have weight %d, the policy needs %d", weight, old.Policy.Threshold)
+	}
+
+	for _, x := range indices {
+		r.lambda[x] = lagrange(x, indices)
+	}
+	return r, nil
+}
+
+func (r *Reshare) isDealer(id uint32) bool {
+	for _, dealer := range r.dealers {
+		if dealer == id {
+			return true
+		}
+	}
+	return false
+}
+
+// dealerKey is the public key of the part of the secret the dealer shares,
+// sum(lambda_x * Y_x)
+func (r *Reshare) dealerKey(id uint32) *schnorr.Point {
+	y := schnorr.Infinity
+	for _, x := range r.old.Indices[id] {
+		y = y.Add(r.old.VerifyingShare(x).Mul(r.lambda[x]))
+	}
+	return y
+}
+
+// Deal shares the dealer's part of the secret for the new policy
+func (r *Reshare) Deal(ks *KeyShare) (*Dealing, error) {
+	if !r.isDealer(ks.ID) {
+		return nil, fmt.Errorf("participant %d is not a dealer", ks.ID)
+	}
+	if err := r.old.VerifyKeyShare(ks); err != nil {
+		return nil, err
+	}
+
+	secret := new(big.Int)
+	for _, share := range ks.Shares {
+		secret.Add(secret, new(big.Int).Mul(r.lambda[share.Index], share.Value))
+	}
+	secret.Mod(secret, schnorr.Curve.N)
+
+	coefficients := []*big.Int{secret}
+	for i := 1; i < r.policy.Threshold; i++ {
+		a, err := randomScalar()
+		if err != nil {
+			return nil, err
+		}
+		coefficients = append(coefficients, a)
+	}
+
+	d := &Dealing{From: ks.ID, Shares: map[uint32][]SecretShare{}}
+	for _, a := range coefficients {
+		d.Commitments = append(d.Commitments, schnorr.ScalarBaseMult(a))
+	}
+	for id, indices := range r.indices {
+		for _, index := range indices {
+			d.Shares[id] = append(d.Shares[id], SecretShare{Index: index, Value: evaluate(coefficients, index)})
+		}
+	}
+
+	return d, nil
+}
+
+// checkDealings makes sure there is exactly one dealing from every dealer and
+// that each commits to the dealer's part of the secret
+func (r *Reshare) checkDealings(dealings []*Dealing) error {
+	if len(dealings) != len(r.dealers) {
+		return fmt.Errorf("got %d dealings for %d dealers", len(dealings), len(r.dealers))
+	}
+
+	seen := map[uint32]bool{}
+	for _, d := range dealings {
+		if !r.isDealer(d.From) {
+			return fmt.Errorf("dealing from %d, who is not a dealer", d.From)
+		}
+		if seen[d.From] {
+			return fmt.Errorf("participant %d dealt twice", d.From)
+		}
+		seen[d.From] = true
+
+		if len(d.Commitments) != r.policy.Threshold {
+			return fmt.Errorf("dealing from %d has %d commitments, want %d", d.From, len(d.Commitments), r.policy.Threshold)
+		}
+		if !d.Commitments[0].Equal(r.dealerKey(d.From)) {
+			return fmt.Errorf("dealing from %d does not share its part of the key", d.From)
+		}
+	}
+	return nil
+}
+
+// Complete checks the shares the new participant received from every dealer
+// and combines them into its new key share
+func (r *Reshare) Complete(id uint32, dealings []*Dealing) (*KeyShare, error) {
+	indices, ok := r.indices[id]
+	if !ok {
+		return nil, fmt.Errorf("participant %d is not part of the new policy", id)
+	}
+	if err := r.checkDealings(dealings); err != nil {
+		return nil, err
+	}
+
+	ks := &KeyShare{ID: id}
+	for _, index := range indices {
+		ks.Shares = append(ks.Shares, SecretShare{Index: index, Value: new(big.Int)})
+	}
+
+	for _, d := range dealings {
+		dealt := &PublicKeyPackage{Indices: r.indices, Commitments: d.Commitments}
+		if err := dealt.VerifyKeyShare(&KeyShare{ID: id, Shares: d.Shares[id]}); err != nil {
+			return nil, fmt.Errorf("dealing from %d: %v", d.From, err)
+		}
+
+		for i, share := range d.Shares[id] {
+			ks.Shares[i].Value.Add(ks.Shares[i].Value, share.Value)
+			ks.Shares[i].Value.Mod(ks.Shares[i].Value, schnorr.Curve.N)
+		}
+	}
+
+	return ks, nil
+}
+
+// PublicKeyPackage combines the commitments of every dealer into the public
+// side of the new sharing, checking the group key did not change
+func (r *Reshare) PublicKeyPackage(dealings []*Dealing) (*PublicKeyPackage, error) {
+	if err := r.checkDealings(dealings); err != nil {
+		return nil, err
+	}
+
+	commitments := make([]*schnorr.Point, r.policy.Threshold)
+	for i := range commitments {
+		commitments[i] = schnorr.Infinity
+		for _, d := range dealings {
+			commitments[i] = commitments[i].Add(d.Commitments[i])
+		}
+	}
+
+	if !commitments[0].Equal(r.old.GroupKey) {
+		return nil, fmt.Errorf("resharing changed the group key")
+	}
+
+	return &PublicKeyPackage{
+		GroupKey:    r.old.GroupKey,
+		Policy:      r.policy,
+		Indices:     r.indices,
+		Commitments: commitments,
+	}, nil
+}
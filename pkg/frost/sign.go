@@ -0,0 +1,286 @@
+package frost
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// SigningNonces are the secret nonces of one signing round. They are
+// cleared when used so they can never sign twice.
+type SigningNonces struct {
+	d, e *big.Int
+}
+
+// SigningCommitment is what a signer publishes in the first round
+type SigningCommitment struct {
+	ID   uint32
+	D, E *schnorr.Point
+}
+
+// Commit makes the nonces and commitment of a participant for one signature
+func Commit(id uint32) (*SigningNonces, SigningCommitment, error) {
+	d, err := randomScalar()
+	if err != nil {
+		return nil, SigningCommitment{}, err
+	}
+	e, err := randomScalar()
+	if err != nil {
+		return nil, SigningCommitment{}, err
+	}
+
+	return &SigningNonces{d: d, e: e}, SigningCommitment{
+		ID: id,
+		D:  schnorr.ScalarBaseMult(d),
+		E:  schnorr.ScalarBaseMult(e),
+	}, nil
+}
+
+// SigningPackage is the second round state every signer derives from the
+// commitments and message
+type SigningPackage struct {
+	pkg         *PublicKeyPackage
+	msg         []byte
+	commitments map[uint32]SigningCommitment
+	signers     []uint32
+
+	rho    map[uint32]*big.Int
+	lambda map[uint32]*big.Int
+	r      *schnorr.Point
+	c      *big.Int
+}
+
+// NewSigningPackage checks the signers satisfy the policy and computes the
+// binding factors, group nonce and challenge
+func NewSigningPackage(pkg *PublicKeyPackage, commitments []SigningCommitment, msg []byte) (*SigningPackage, error) {
+	sp := &SigningPackage{
+		pkg:         pkg,
+		msg:         append([]byte{}, msg...),
+		commitments: map[uint32]SigningCommitment{},
+		rho:         map[uint32]*big.Int{},
+		lambda:      map[uint32]*big.Int{},
+	}
+
+	weight := 0
+	for _, c := range commitments {
+		if _, ok := pkg.Indices[c.ID]; !ok {
+			return nil, blame("are not part of the key", c.ID)
+		}
+		if _, ok := sp.commitments[c.ID]; ok {
+			return nil, blame("committed more than once", c.ID)
+		}
+		if c.D == nil || c.E == nil || c.D.IsInfinity() || c.E.IsInfinity() {
+			return nil, blame("sent an invalid commitment", c.ID)
+		}
+		sp.commitments[c.ID] = c
+		sp.signers = append(sp.signers, c.ID)
+		weight += pkg.Policy.Weights[c.ID]
+	}
+	sort.Slice(sp.signers, func(i, j int) bool { return sp.signers[i] < sp.signers[j] })
+
+	if weight < pkg.Policy.Threshold {
+		return nil, fmt.Errorf("signers have weight %d, the policy needs %d", weight, pkg.Policy.Threshold)
+	}
+	for _, id := range pkg.Policy.Required {
+		if _, ok := sp.commitments[id]; !ok {
+			return nil, fmt.Errorf("required participant %d is not signing", id)
+		}
+	}
+
+	y, err := pkg.GroupKey.XOnly()
+	if err != nil {
+		return nil, err
+	}
+
+	// the binding factors cover the message and every commitment
+	encoded := []byte{}
+	for _, id := range sp.signers {
+		c := sp.commitments[id]
+		d, _ := c.D.PublicKey()
+		e, _ := c.E.PublicKey()
+		encoded = append(encoded, appendUint32(nil, id)...)
+		encoded = append(encoded, d[:]...)
+		encoded = append(encoded, e[:]...)
+	}
+	msgHash := schnorr.TaggedHash("FROST/msg", msg)
+	comHash := schnorr.TaggedHash("FROST/com", encoded)
+
+	sp.r = schnorr.Infinity
+	for _, id := range sp.signers {
+		h := schnorr.TaggedHash("FROST/rho", y[:], msgHash[:], comHash[:], appendUint32(nil, id))
+		rho := new(big.Int).SetBytes(h[:])
+		sp.rho[id] = rho.Mod(rho, schnorr.Curve.N)
+
+		c := sp.commitments[id]
+		sp.r = sp.r.Add(c.D.Add(c.E.Mul(rho)))
+	}
+	if sp.r.IsInfinity() {
+		return nil, fmt.Errorf("group commitment is infinity")
+	}
+
+	rX, _ := sp.r.XOnly()
+	sp.c, err = schnorr.ComputeChallenge(rX[:], pkg.GroupKey.X(), pkg.GroupKey.Y(), msg, schnorr.BIP340Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	// interpolate over every share index held by the signers
+	indices := []uint32{}
+	for _, id := range sp.signers {
+		indices = append(indices, pkg.Indices[id]...)
+	}
+	for _, x := range indices {
+		sp.lambda[x] = lagrange(x, indices)
+	}
+
+	return sp, nil
+}
+
+// lagrange is the coefficient of f(x) when interpolating f(0) from the
+// points at indices
+func lagrange(x uint32, indices []uint32) *big.Int {
+	num, den := big.NewInt(1), big.NewInt(1)
+	for _, j := range indices {
+		if j == x {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(j)))
+		den.Mul(den, big.NewInt(int64(j)-int64(x)))
+	}
+
+	den.Mod(den, schnorr.Curve.N)
+	num.Mul(num, den.ModInverse(den, schnorr.Curve.N))
+	return num.Mod(num, schnorr.Curve.N)
+}
+
+// Signers returns the ids of the participants in the signing package
+func (sp *SigningPackage) Signers() []uint32 {
+	return append([]uint32{}, sp.signers...)
+}
+
+// negate is N-1 when the point has an odd y, since BIP-340 only knows even
+// keys and nonces
+func negate(p *schnorr.Point) *big.Int {
+	if p.HasEvenY() {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Sub(schnorr.Curve.N, big.NewInt(1))
+}
+
+// Sign produces the signature share of a participant, clearing the nonces
+func (sp *SigningPackage) Sign(ks *KeyShare, nonces *SigningNonces) ([32]byte, error) {
+	var z [32]byte
+
+	if nonces.d == nil || nonces.e == nil {
+		return z, fmt.Errorf("signing nonces have already been used")
+	}
+	d, e := nonces.d, nonces.e
+	nonces.d, nonces.e = nil, nil
+
+	c, ok := sp.commitments[ks.ID]
+	if !ok {
+		return z, fmt.Errorf("participant %d is not in the signing package", ks.ID)
+	}
+	if !schnorr.ScalarBaseMult(d).Equal(c.D) || !schnorr.ScalarBaseMult(e).Equal(c.E) {
+		return z, fmt.Errorf("nonces do not match the commitment of participant %d", ks.ID)
+	}
+
+	// the participant's part of the secret, sum(lambda_x * s_x)
+	secret := new(big.Int)
+	for _, share := range ks.Shares {
+		lambda, ok := sp.lambda[share.Index]
+		if !ok {
+			return z, fmt.Errorf("share %d does not belong to participant %d", share.Index, ks.ID)
+		}
+		secret.Add(secret, new(big.Int).Mul(lambda, share.Value))
+	}
+
+	// z = g_R (d + rho e) + c g_Y secret
+	k := new(big.Int).Mul(sp.rho[ks.ID], e)
+	k.Add(k, d)
+	k.Mul(k, negate(sp.r))
+
+	secret.Mul(secret, sp.c)
+	secret.Mul(secret, negate(sp.pkg.GroupKey))
+	k.Add(k, secret)
+	k.Mod(k, schnorr.Curve.N)
+
+	copy(z[:], schnorr.GetBigIntBytesImmutable(k))
+	return z, nil
+}
+
+// VerifyShare checks the signature share of one participant against its
+// commitment and verifying shares
+func (sp *SigningPackage) VerifyShare(id uint32, z [32]byte) error {
+	c, ok := sp.commitments[id]
+	if !ok {
+		return fmt.Errorf("participant %d is not in the signing package", id)
+	}
+
+	s := new(big.Int).SetBytes(z[:])
+	if s.Cmp(schnorr.Curve.N) >= 0 {
+		return fmt.Errorf("s is larger than or equal to curve order N")
+	}
+
+	y := schnorr.Infinity
+	for _, x := range sp.pkg.Indices[id] {
+		y = y.Add(sp.pkg.VerifyingShare(x).Mul(sp.lambda[x]))
+	}
+
+	cg := new(big.Int).Mul(sp.c, negate(sp.pkg.GroupKey))
+	r := c.D.Add(c.E.Mul(sp.rho[id])).Mul(negate(sp.r))
+	if !schnorr.ScalarBaseMult(s).Equal(r.Add(y.Mul(cg))) {
+		return fmt.Errorf("signature share of participant %d is invalid", id)
+	}
+	return nil
+}
+
+// Aggregate sums the signature shares of every signer into a BIP-340
+// signature for the group key. If the signature does not verify every share
+// is checked, and the error is a BlameError naming the participants whose
+// shares are bad, so they can be left out of the next attempt.
+func (sp *SigningPackage) Aggregate(shares map[uint32][32]byte) ([64]byte, error) {
+	var signature [64]byte
+
+	missing := []uint32{}
+	z := new(big.Int)
+	for _, id := range sp.signers {
+		share, ok := shares[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		z.Add(z, new(big.Int).SetBytes(share[:]))
+	}
+	if len(missing) > 0 {
+		return signature, blame("sent no signature share", missing...)
+	}
+	if len(shares) != len(sp.signers) {
+		return signature, fmt.Errorf("got %d signature shares for %d signers", len(shares), len(sp.signers))
+	}
+	z.Mod(z, schnorr.Curve.N)
+
+	// z*G == g_R R + c g_Y Y
+	cg := new(big.Int).Mul(sp.c, negate(sp.pkg.GroupKey))
+	expected := sp.r.Mul(negate(sp.r)).Add(sp.pkg.GroupKey.Mul(cg))
+	if !schnorr.ScalarBaseMult(z).Equal(expected) {
+		bad := []uint32{}
+		for _, id := range sp.signers {
+			if err := sp.VerifyShare(id, shares[id]); err != nil {
+				bad = append(bad, id)
+			}
+		}
+		return signature, blame("sent an invalid signature share", bad...)
+	}
+
+	rX, _ := sp.r.XOnly()
+	copy(signature[:32], rX[:])
+	copy(signature[32:], schnorr.GetBigIntBytesImmutable(z))
+	return signature, nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
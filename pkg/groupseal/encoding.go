@@ -0,0 +1,125 @@
+package groupseal
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+type sealedJSON struct {
+	GroupKey   string `json:"group_key"`
+	Ephemeral  string `json:"ephemeral"`
+	Context    []byte `json:"context,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// MarshalJSON encodes the points as hex compressed keys and the rest as
+// base64
+func (s *Sealed) MarshalJSON() ([]byte, error) {
+	groupKey, err := hexPoint(s.GroupKey)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := hexPoint(s.Ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedJSON{groupKey, ephemeral, s.Context, s.Nonce, s.Ciphertext})
+}
+
+func (s *Sealed) UnmarshalJSON(data []byte) error {
+	in := sealedJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	groupKey, err := parseHexPoint(in.GroupKey)
+	if err != nil {
+		return fmt.Errorf("group key: %v", err)
+	}
+	ephemeral, err := parseHexPoint(in.Ephemeral)
+	if err != nil {
+		return fmt.Errorf("ephemeral key: %v", err)
+	}
+	if len(in.Ciphertext) == 0 {
+		return fmt.Errorf("not a sealed envelope")
+	}
+	s.GroupKey, s.Ephemeral, s.Context, s.Nonce, s.Ciphertext = groupKey, ephemeral, in.Context, in.Nonce, in.Ciphertext
+	return nil
+}
+
+type partialJSON struct {
+	Index uint32 `json:"index"`
+	D     string `json:"d"`
+	C     string `json:"c"`
+	S     string `json:"s"`
+}
+
+type decryptionShareJSON struct {
+	ID       uint32        `json:"id"`
+	Partials []partialJSON `json:"partials"`
+}
+
+// MarshalJSON encodes the points and scalars as hex
+func (share *DecryptionShare) MarshalJSON() ([]byte, error) {
+	out := decryptionShareJSON{ID: share.ID}
+	for _, p := range share.Partials {
+		d, err := hexPoint(p.D)
+		if err != nil {
+			return nil, err
+		}
+		out.Partials = append(out.Partials, partialJSON{
+			Index: p.Index,
+			D:     d,
+			C:     hex.EncodeToString(schnorr.GetBigIntBytesImmutable(p.C)),
+			S:     hex.EncodeToString(schnorr.GetBigIntBytesImmutable(p.S)),
+		})
+	}
+	return json.Marshal(out)
+}
+
+func (share *DecryptionShare) UnmarshalJSON(data []byte) error {
+	in := decryptionShareJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	partials := []PartialDecryption{}
+	for _, p := range in.Partials {
+		d, err := parseHexPoint(p.D)
+		if err != nil {
+			return fmt.Errorf("partial decryption %d: %v", p.Index, err)
+		}
+		c, okC := new(big.Int).SetString(p.C, 16)
+		s, okS := new(big.Int).SetString(p.S, 16)
+		if !okC || !okS {
+			return fmt.Errorf("partial decryption %d: proof is not hex", p.Index)
+		}
+		partials = append(partials, PartialDecryption{Index: p.Index, D: d, C: c, S: s})
+	}
+	share.ID, share.Partials = in.ID, partials
+	return nil
+}
+
+func hexPoint(p *schnorr.Point) (string, error) {
+	if p == nil {
+		return "", fmt.Errorf("missing point")
+	}
+	b, err := p.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func parseHexPoint(s string) (*schnorr.Point, error) {
+	var b [33]byte
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != 33 {
+		return nil, fmt.Errorf("must be 33 hex encoded bytes")
+	}
+	copy(b[:], raw)
+	return schnorr.ParsePoint(b)
+}
@@ -0,0 +1,257 @@
+package groupseal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/frost"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/transcript"
+)
+
+//
+// Signed envelopes encrypted to a FROST group key, so that any quorum the
+// key's policy allows can open them and nobody less can: signed-and-sealed
+// escrow documents.
+//
+// Sealing is ElGamal key encapsulation to the group key Y: a fresh r gives
+// the ephemeral point R = r*G and the shared point S = r*Y, and the envelope
+// is encrypted with AES-256-GCM under H(R || S). Y is x*G for a secret no
+// one holds, but every share x_i gives a decryption share D_i = x_i*R, and
+// S = sum(lambda_i * D_i) over a quorum's shares, the same interpolation
+// FROST signing uses. Each D_i comes with a proof that
+// log_G(X_i) == log_R(D_i) for the share's public X_i, so a participant
+// can't spoil the opening without being named.
+//
+
+// Sealed is an envelope encrypted to a group key. Context is authenticated
+// and bound into the decryption share proofs, so shares made for one
+// sealed document can't be replayed for another.
+type Sealed struct {
+	GroupKey   *schnorr.Point
+	Ephemeral  *schnorr.Point
+	Context    []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// PartialDecryption is x_i*R for one share index, with the proof
+type PartialDecryption struct {
+	Index uint32
+	D     *schnorr.Point
+	C, S  *big.Int
+}
+
+// DecryptionShare is a participant's partial decryptions, one for each
+// share it holds
+type DecryptionShare struct {
+	ID       uint32
+	Partials []PartialDecryption
+}
+
+func randomScalar() (*big.Int, error) {
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(schnorr.Curve.N, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+func mod(k *big.Int) *big.Int {
+	return k.Mod(k, schnorr.Curve.N)
+}
+
+// Seal encrypts the envelope to the group key
+func Seal(groupKey *schnorr.Point, e *envelope.Envelope, context []byte) (*Sealed, error) {
+	if groupKey == nil || groupKey.IsInfinity() {
+		return nil, fmt.Errorf("group key is the point at infinity")
+	}
+	plaintext, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	s := &Sealed{
+		GroupKey:  groupKey,
+		Ephemeral: schnorr.ScalarBaseMult(r),
+		Context:   append([]byte{}, context...),
+	}
+
+	aead, err := s.aead(groupKey.Mul(r))
+	if err != nil {
+		return nil, err
+	}
+	s.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return nil, err
+	}
+	ad, err := s.associatedData()
+	if err != nil {
+		return nil, err
+	}
+	s.Ciphertext = aead.Seal(nil, s.Nonce, plaintext, ad)
+	return s, nil
+}
+
+// aead is AES-256-GCM under H(R || S)
+func (s *Sealed) aead(shared *schnorr.Point) (cipher.AEAD, error) {
+	R, err := s.Ephemeral.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	S, err := shared.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	key := schnorr.TaggedHash("schnorr-go/groupseal/key", R[:], S[:])
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *Sealed) associatedData() ([]byte, error) {
+	Y, err := s.GroupKey.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return append(Y[:], s.Context...), nil
+}
+
+func (s *Sealed) transcript(index uint32) *transcript.Transcript {
+	t := transcript.New("schnorr-go/groupseal/v1")
+	t.AppendMessage("context", s.Context)
+	t.AppendPoint("Y", s.GroupKey)
+	t.AppendPoint("R", s.Ephemeral)
+	t.AppendUint64("index", uint64(index))
+	return t
+}
+
+// Share makes the participant's decryption share. The key share is
+// checked against the package first, and the package must be for the key
+// the envelope was sealed to.
+func (s *Sealed) Share(pkg *frost.PublicKeyPackage, ks *frost.KeyShare) (*DecryptionShare, error) {
+	if !pkg.GroupKey.Equal(s.GroupKey) {
+		return nil, fmt.Errorf("sealed to a different group key")
+	}
+	if s.Ephemeral == nil || s.Ephemeral.IsInfinity() {
+		return nil, fmt.Errorf("invalid ephemeral key")
+	}
+	if err := pkg.VerifyKeyShare(ks); err != nil {
+		return nil, err
+	}
+
+	share := &DecryptionShare{ID: ks.ID}
+	for _, secret := range ks.Shares {
+		k, err := randomScalar()
+		if err != nil {
+			return nil, err
+		}
+		p := PartialDecryption{Index: secret.Index, D: s.Ephemeral.Mul(secret.Value)}
+
+		// prove log_G(X_i) == log_R(D_i) == x_i
+		t := s.transcript(secret.Index)
+		t.AppendPoint("X", pkg.VerifyingShare(secret.Index))
+		t.AppendPoint("D", p.D)
+		t.AppendPoint("T1", schnorr.ScalarBaseMult(k))
+		t.AppendPoint("T2", s.Ephemeral.Mul(k))
+		p.C = t.ChallengeScalar("c")
+		p.S = mod(new(big.Int).Add(k, new(big.Int).Mul(p.C, secret.Value)))
+
+		share.Partials = append(share.Partials, p)
+	}
+	return share, nil
+}
+
+// VerifyShare checks every partial decryption of the share against the
+// participant's public shares
+func (s *Sealed) VerifyShare(pkg *frost.PublicKeyPackage, share *DecryptionShare) error {
+	indices, ok := pkg.Indices[share.ID]
+	if !ok {
+		return &frost.BlameError{Participants: []uint32{share.ID}, Reason: "are not part of the key"}
+	}
+	if len(share.Partials) != len(indices) {
+		return &frost.BlameError{Participants: []uint32{share.ID}, Reason: fmt.Sprintf("sent %d partial decryptions, want %d", len(share.Partials), len(indices))}
+	}
+
+	for i, p := range share.Partials {
+		if p.Index != indices[i] || p.D == nil || p.D.IsInfinity() || p.C == nil || p.S == nil ||
+			p.C.Sign() < 0 || p.C.Cmp(schnorr.Curve.N) >= 0 || p.S.Sign() < 0 || p.S.Cmp(schnorr.Curve.N) >= 0 {
+			return &frost.BlameError{Participants: []uint32{share.ID}, Reason: "sent an invalid partial decryption"}
+		}
+
+		X := pkg.VerifyingShare(p.Index)
+		t := s.transcript(p.Index)
+		t.AppendPoint("X", X)
+		t.AppendPoint("D", p.D)
+		t.AppendPoint("T1", schnorr.ScalarBaseMult(p.S).Sub(X.Mul(p.C)))
+		t.AppendPoint("T2", s.Ephemeral.Mul(p.S).Sub(p.D.Mul(p.C)))
+		if t.ChallengeScalar("c").Cmp(p.C) != 0 {
+			return &frost.BlameError{Participants: []uint32{share.ID}, Reason: "sent a partial decryption that does not match their share"}
+		}
+	}
+	return nil
+}
+
+// Open checks the decryption shares, combines them and decrypts the
+// envelope. The envelope's signature is the caller's to verify, against
+// whichever key it expects the document to be signed by.
+func (s *Sealed) Open(pkg *frost.PublicKeyPackage, shares []*DecryptionShare) (*envelope.Envelope, error) {
+	if !pkg.GroupKey.Equal(s.GroupKey) {
+		return nil, fmt.Errorf("sealed to a different group key")
+	}
+
+	ids := []uint32{}
+	for _, share := range shares {
+		if err := s.VerifyShare(pkg, share); err != nil {
+			return nil, err
+		}
+		ids = append(ids, share.ID)
+	}
+	lambda, err := pkg.Interpolate(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := schnorr.Infinity
+	for _, share := range shares {
+		for _, p := range share.Partials {
+			shared = shared.Add(p.D.Mul(lambda[p.Index]))
+		}
+	}
+	if shared.IsInfinity() {
+		return nil, fmt.Errorf("combined decryption is the point at infinity")
+	}
+
+	aead, err := s.aead(shared)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("nonce must be %d bytes", aead.NonceSize())
+	}
+	ad, err := s.associatedData()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, s.Nonce, s.Ciphertext, ad)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed, the sealed envelope is damaged")
+	}
+
+	e := new(envelope.Envelope)
+	if err := json.Unmarshal(plaintext, e); err != nil {
+		return nil, fmt.Errorf("decrypted payload is not an envelope: %v", err)
+	}
+	return e, nil
+}
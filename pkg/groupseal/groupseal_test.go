@@ -0,0 +1,94 @@
+package groupseal
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/frost"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestSealAndOpen(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("groupseal"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	signer, group := keys[0], keys[1]
+	shares, pkg, err := frost.Deal(group.PrivateKey, frost.ThresholdPolicy(2, 3))
+	if err != nil {
+		t.Fatalf("Unexpected error from Deal: %v", err)
+	}
+	e, err := envelope.Sign(signer.PrivateKey, "application/vnd.escrow", []byte("the vault combination"))
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	// when
+	sealed, err := Seal(pkg.GroupKey, e, []byte("escrow 7"))
+	if err != nil {
+		t.Fatalf("Unexpected error from Seal: %v", err)
+	}
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		t.Fatalf("Unexpected error from Marshal: %v", err)
+	}
+	sealed = new(Sealed)
+	if err := json.Unmarshal(data, sealed); err != nil {
+		t.Fatalf("Unexpected error from Unmarshal: %v", err)
+	}
+
+	decryptionShares := []*DecryptionShare{}
+	for _, ks := range shares[1:] {
+		share, err := sealed.Share(pkg, ks)
+		if err != nil {
+			t.Fatalf("Unexpected error from Share(%d): %v", ks.ID, err)
+		}
+		data, _ := json.Marshal(share)
+		decoded := new(DecryptionShare)
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatalf("Unexpected error from Unmarshal: %v", err)
+		}
+		decryptionShares = append(decryptionShares, decoded)
+	}
+	opened, err := sealed.Open(pkg, decryptionShares)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from Open: %v", err)
+	}
+	if string(opened.Payload) != "the vault combination" {
+		t.Fatalf("Open() payload = %q, want %q", opened.Payload, "the vault combination")
+	}
+	if err := envelope.Verify(opened, signer.PublicKey); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+
+	t.Run("too few shares", func(t *testing.T) {
+		if _, err := sealed.Open(pkg, decryptionShares[:1]); err == nil {
+			t.Fatalf("Open() with one share of a 2-of-3 key succeeded")
+		}
+	})
+
+	t.Run("bad share is blamed", func(t *testing.T) {
+		bad := *decryptionShares[0]
+		bad.Partials = append([]PartialDecryption{}, bad.Partials...)
+		bad.Partials[0].D = bad.Partials[0].D.Add(schnorr.ScalarBaseMult(big.NewInt(1)))
+		_, err := sealed.Open(pkg, []*DecryptionShare{&bad, decryptionShares[1]})
+		var blame *frost.BlameError
+		if !errors.As(err, &blame) || blame.Participants[0] != bad.ID {
+			t.Fatalf("Open() = %v, want blame for %d", err, bad.ID)
+		}
+	})
+
+	t.Run("shares are bound to the context", func(t *testing.T) {
+		other := *sealed
+		other.Context = []byte("escrow 8")
+		if err := other.VerifyShare(pkg, decryptionShares[0]); err == nil {
+			t.Fatalf("VerifyShare() accepted a share made for another context")
+		}
+	})
+}
@@ -0,0 +1,377 @@
+package halfagg
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"hash"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+//
+// Half-aggregation of BIP-340 signatures
+// https://github.com/BlockstreamResearch/cross-input-aggregation/blob/master/half-aggregation.mediawiki
+//
+// The randomizer z_i only depends on the signatures before it, so a running
+// hash of the prefix is enough to keep aggregating without touching the
+// signatures that are already in.
+//
+
+// MaxSignatures is the most signatures one aggregate may hold
+const MaxSignatures = 1<<16 - 1
+
+var (
+	tagRandomizer = []byte("HalfAgg/randomizer")
+	tagChallenge  = []byte("BIP0340/challenge")
+)
+
+// PubKeyMsg is an x-only public key and the 32 byte message it signed
+type PubKeyMsg struct {
+	PubKey [32]byte
+	Msg    [32]byte
+}
+
+// Log is an append-only half-aggregate. After every Append the running s is
+// kept as a checkpoint, so the aggregate of any earlier state can be given
+// to a verifier holding only that prefix of the log.
+type Log struct {
+	rs          [][32]byte
+	s           btcec.ModNScalar
+	checkpoints []checkpoint
+	randomizer  hash.Hash
+}
+
+type checkpoint struct {
+	count int
+	s     btcec.ModNScalar
+}
+
+// New returns an empty log
+func New() *Log {
+	return &Log{randomizer: taggedHasher(tagRandomizer)}
+}
+
+// Len returns the number of signatures aggregated so far
+func (l *Log) Len() int {
+	return len(l.rs)
+}
+
+// Append half-aggregates the signatures into the log
+func (l *Log) Append(pms []PubKeyMsg, sigs [][64]byte) error {
+	if len(pms) != len(sigs) {
+		return fmt.Errorf("got %d messages but %d signatures", len(pms), len(sigs))
+	}
+	if len(l.rs)+len(pms) > MaxSignatures {
+		return fmt.Errorf("aggregate would hold more than %d signatures", MaxSignatures)
+	}
+
+	// work on copies so a bad signature leaves the log untouched
+	s := l.s
+	rs := append([][32]byte{}, l.rs...)
+	randomizer, err := cloneHash(l.randomizer)
+	if err != nil {
+		return err
+	}
+
+	for i, sig := range sigs {
+		var r [32]byte
+		copy(r[:], sig[:32])
+
+		var si btcec.ModNScalar
+		if overflow := si.SetByteSlice(sig[32:]); overflow {
+			return fmt.Errorf("s of signature %d is not less than the curve order", len(rs))
+		}
+
+		z := nextRandomizer(randomizer, len(rs), r, pms[i])
+		si.Mul(&z)
+		s.Add(&si)
+		rs = append(rs, r)
+	}
+
+	l.rs, l.s, l.randomizer = rs, s, randomizer
+	l.checkpoints = append(l.checkpoints, checkpoint{count: len(rs), s: s})
+
+	return nil
+}
+
+// Bytes returns the aggregate signature r_0 || ... || r_{n-1} || s
+func (l *Log) Bytes() []byte {
+	return encodeAggSig(l.rs, &l.s)
+}
+
+// Checkpoints returns the prefix lengths an aggregate can be produced for
+func (l *Log) Checkpoints() []int {
+	counts := make([]int, len(l.checkpoints))
+	for i, c := range l.checkpoints {
+		counts[i] = c.count
+	}
+	return counts
+}
+
+// Prefix returns the aggregate signature over the first n signatures. n must
+// be the length of the log after one of the appends.
+func (l *Log) Prefix(n int) ([]byte, error) {
+	for _, c := range l.checkpoints {
+		if c.count == n {
+			return encodeAggSig(l.rs[:n], &c.s), nil
+		}
+	}
+	return nil, fmt.Errorf("no checkpoint for a prefix of %d signatures", n)
+}
+
+// MarshalBinary encodes the log including the checkpoints and the running
+// randomizer hash so aggregation can resume after a restart
+func (l *Log) MarshalBinary() ([]byte, error) {
+	state, err := l.randomizer.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	out := appendUint32(nil, uint32(len(l.rs)))
+	for _, r := range l.rs {
+		out = append(out, r[:]...)
+	}
+
+	out = appendUint32(out, uint32(len(l.checkpoints)))
+	for _, c := range l.checkpoints {
+		s := c.s.Bytes()
+		out = appendUint32(out, uint32(c.count))
+		out = append(out, s[:]...)
+	}
+
+	out = appendUint32(out, uint32(len(state)))
+	return append(out, state...), nil
+}
+
+// UnmarshalBinary restores a log written by MarshalBinary
+func (l *Log) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+
+	n := d.uint32()
+	if n > MaxSignatures {
+		return fmt.Errorf("log claims %d signatures", n)
+	}
+	rs := make([][32]byte, n)
+	for i := range rs {
+		copy(rs[i][:], d.next(32))
+	}
+
+	c := d.uint32()
+	if c > n {
+		return fmt.Errorf("log claims %d checkpoints for %d signatures", c, n)
+	}
+	checkpoints := make([]checkpoint, c)
+	for i := range checkpoints {
+		checkpoints[i].count = int(d.uint32())
+		if overflow := checkpoints[i].s.SetByteSlice(d.next(32)); overflow {
+			return fmt.Errorf("checkpoint %d is not less than the curve order", i)
+		}
+		if checkpoints[i].count > int(n) {
+			return fmt.Errorf("checkpoint %d is past the end of the log", i)
+		}
+	}
+
+	state := d.next(int(d.uint32()))
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) != 0 {
+		return fmt.Errorf("unexpected %d trailing bytes", len(d.data))
+	}
+
+	randomizer := sha256.New()
+	if err := randomizer.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return err
+	}
+
+	l.rs, l.checkpoints, l.randomizer = rs, checkpoints, randomizer
+	l.s = btcec.ModNScalar{}
+	if len(checkpoints) > 0 {
+		last := checkpoints[len(checkpoints)-1]
+		if last.count != int(n) {
+			return fmt.Errorf("last checkpoint does not cover the whole log")
+		}
+		l.s = last.s
+	}
+
+	return nil
+}
+
+// Aggregate half-aggregates the signatures in one go
+func Aggregate(pms []PubKeyMsg, sigs [][64]byte) ([]byte, error) {
+	l := New()
+	if err := l.Append(pms, sigs); err != nil {
+		return nil, err
+	}
+	return l.Bytes(), nil
+}
+
+// IncAggregate adds signatures to an existing aggregate signature. Unlike
+// Log.Append the prior messages are rehashed since only the aggregate is
+// available.
+func IncAggregate(pmAggd []PubKeyMsg, aggsig []byte, pms []PubKeyMsg, sigs [][64]byte) ([]byte, error) {
+	rs, s, err := decodeAggSig(aggsig, len(pmAggd))
+	if err != nil {
+		return nil, err
+	}
+
+	l := New()
+	for i, r := range rs {
+		nextRandomizer(l.randomizer, i, r, pmAggd[i])
+	}
+	l.rs, l.s = rs, s
+
+	if err := l.Append(pms, sigs); err != nil {
+		return nil, err
+	}
+	return l.Bytes(), nil
+}
+
+// Verify checks an aggregate signature against the keys and messages. Pass a
+// prefix of the log together with the aggregate returned by Log.Prefix to
+// check an earlier state.
+func Verify(pms []PubKeyMsg, aggsig []byte) (bool, error) {
+	rs, s, err := decodeAggSig(aggsig, len(pms))
+	if err != nil {
+		return false, err
+	}
+
+	randomizer := taggedHasher(tagRandomizer)
+
+	// accumulate sum(z_i * (R_i + e_i*P_i)) - s*G and expect infinity
+	var acc btcec.JacobianPoint
+	for i, pm := range pms {
+		P, err := schnorr.ParsePubKey(pm.PubKey[:])
+		if err != nil {
+			return false, fmt.Errorf("public key %d: %v", i, err)
+		}
+		R, err := schnorr.ParsePubKey(rs[i][:])
+		if err != nil {
+			return false, fmt.Errorf("r of signature %d is not on the curve", i)
+		}
+
+		var e btcec.ModNScalar
+		e.SetBytes((*[32]byte)(chainhash.TaggedHash(tagChallenge, rs[i][:], pm.PubKey[:], pm.Msg[:])))
+
+		z := nextRandomizer(randomizer, i, rs[i], pm)
+
+		var p, r, eP, term btcec.JacobianPoint
+		P.AsJacobian(&p)
+		R.AsJacobian(&r)
+		btcec.ScalarMultNonConst(&e, &p, &eP)
+		btcec.AddNonConst(&r, &eP, &term)
+		btcec.ScalarMultNonConst(&z, &term, &term)
+		btcec.AddNonConst(&acc, &term, &acc)
+	}
+
+	var negS btcec.ModNScalar
+	negS.NegateVal(&s)
+
+	var sG btcec.JacobianPoint
+	btcec.ScalarBaseMultNonConst(&negS, &sG)
+	btcec.AddNonConst(&acc, &sG, &acc)
+
+	return (acc.X.IsZero() && acc.Y.IsZero()) || acc.Z.IsZero(), nil
+}
+
+// nextRandomizer absorbs r_i || pk_i || m_i and returns z_i, which is 1 for
+// the first signature
+func nextRandomizer(h hash.Hash, i int, r [32]byte, pm PubKeyMsg) btcec.ModNScalar {
+	h.Write(r[:])
+	h.Write(pm.PubKey[:])
+	h.Write(pm.Msg[:])
+
+	var z btcec.ModNScalar
+	if i == 0 {
+		z.SetInt(1)
+		return z
+	}
+
+	var digest [32]byte
+	copy(digest[:], h.Sum(nil))
+	z.SetBytes(&digest)
+	return z
+}
+
+func taggedHasher(tag []byte) hash.Hash {
+	tagHash := sha256.Sum256(tag)
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	return h
+}
+
+func cloneHash(h hash.Hash) (hash.Hash, error) {
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	clone := sha256.New()
+	if err := clone.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+func encodeAggSig(rs [][32]byte, s *btcec.ModNScalar) []byte {
+	out := make([]byte, 0, 32*(len(rs)+1))
+	for _, r := range rs {
+		out = append(out, r[:]...)
+	}
+	sBytes := s.Bytes()
+	return append(out, sBytes[:]...)
+}
+
+func decodeAggSig(aggsig []byte, n int) ([][32]byte, btcec.ModNScalar, error) {
+	var s btcec.ModNScalar
+
+	if len(aggsig) != 32*(n+1) {
+		return nil, s, fmt.Errorf("aggregate signature is %d bytes, expected %d for %d signatures", len(aggsig), 32*(n+1), n)
+	}
+
+	rs := make([][32]byte, n)
+	for i := range rs {
+		copy(rs[i][:], aggsig[32*i:])
+	}
+
+	if overflow := s.SetByteSlice(aggsig[32*n:]); overflow {
+		return nil, s, fmt.Errorf("s is not less than the curve order")
+	}
+	return rs, s, nil
+}
+
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.data) < n {
+		d.err = fmt.Errorf("log is truncated")
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,151 @@
+package halfagg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+func signatures(n int, t *testing.T) ([]PubKeyMsg, [][64]byte) {
+	pms := make([]PubKeyMsg, n)
+	sigs := make([][64]byte, n)
+
+	for i := 0; i < n; i++ {
+		seed := sha256.Sum256([]byte{byte(i)})
+		privKey, pubKey := btcec.PrivKeyFromBytes(seed[:])
+
+		copy(pms[i].PubKey[:], schnorr.SerializePubKey(pubKey))
+		pms[i].Msg = sha256.Sum256(seed[:])
+
+		sig, err := schnorr.Sign(privKey, pms[i].Msg[:])
+		if err != nil {
+			t.Fatalf("Unexpected error from schnorr.Sign: %v", err)
+		}
+		copy(sigs[i][:], sig.Serialize())
+	}
+
+	return pms, sigs
+}
+
+func TestAggregateVerify(t *testing.T) {
+	pms, sigs := signatures(4, t)
+
+	aggsig, err := Aggregate(pms, sigs)
+	if err != nil {
+		t.Fatalf("Unexpected error from Aggregate: %v", err)
+	}
+
+	// then
+	if len(aggsig) != 32*5 {
+		t.Fatalf("Aggregate returned %d bytes, want %d", len(aggsig), 32*5)
+	}
+	if ok, err := Verify(pms, aggsig); !ok || err != nil {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+
+	t.Run("Wrong message fails", func(t *testing.T) {
+		bad := append([]PubKeyMsg{}, pms...)
+		bad[2].Msg[0] ^= 1
+		if ok, _ := Verify(bad, aggsig); ok {
+			t.Fatalf("Verify() with a changed message = true, want false")
+		}
+	})
+
+	t.Run("Reordering fails", func(t *testing.T) {
+		swapped := []PubKeyMsg{pms[1], pms[0], pms[2], pms[3]}
+		if ok, _ := Verify(swapped, aggsig); ok {
+			t.Fatalf("Verify() with swapped messages = true, want false")
+		}
+	})
+
+	t.Run("Empty aggregate", func(t *testing.T) {
+		empty, _ := Aggregate(nil, nil)
+		if ok, err := Verify(nil, empty); !ok || err != nil {
+			t.Fatalf("Verify() of empty aggregate = %v, %v, want true", ok, err)
+		}
+	})
+}
+
+func TestIncremental(t *testing.T) {
+	pms, sigs := signatures(6, t)
+
+	full, err := Aggregate(pms, sigs)
+	if err != nil {
+		t.Fatalf("Unexpected error from Aggregate: %v", err)
+	}
+
+	// when
+	log := New()
+	for _, split := range [][2]int{{0, 2}, {2, 3}, {3, 6}} {
+		if err := log.Append(pms[split[0]:split[1]], sigs[split[0]:split[1]]); err != nil {
+			t.Fatalf("Unexpected error from Append: %v", err)
+		}
+	}
+
+	// then
+	if !bytes.Equal(log.Bytes(), full) {
+		t.Fatalf("incremental aggregate differs from the one shot aggregate")
+	}
+
+	for _, n := range log.Checkpoints() {
+		prefix, err := log.Prefix(n)
+		if err != nil {
+			t.Fatalf("Unexpected error from Prefix(%d): %v", n, err)
+		}
+		if ok, err := Verify(pms[:n], prefix); !ok || err != nil {
+			t.Fatalf("Verify() of prefix %d = %v, %v, want true", n, ok, err)
+		}
+	}
+	if _, err := log.Prefix(4); err == nil {
+		t.Fatalf("Prefix(4) without a checkpoint succeeded, want error")
+	}
+
+	t.Run("IncAggregate matches", func(t *testing.T) {
+		prefix, _ := log.Prefix(3)
+		observed, err := IncAggregate(pms[:3], prefix, pms[3:], sigs[3:])
+		if err != nil {
+			t.Fatalf("Unexpected error from IncAggregate: %v", err)
+		}
+		if !bytes.Equal(observed, full) {
+			t.Fatalf("IncAggregate differs from the one shot aggregate")
+		}
+	})
+
+	t.Run("Resume after marshaling", func(t *testing.T) {
+		first := New()
+		first.Append(pms[:4], sigs[:4])
+
+		data, err := first.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Unexpected error from MarshalBinary: %v", err)
+		}
+
+		resumed := New()
+		if err := resumed.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Unexpected error from UnmarshalBinary: %v", err)
+		}
+		if err := resumed.Append(pms[4:], sigs[4:]); err != nil {
+			t.Fatalf("Unexpected error from Append: %v", err)
+		}
+		if !bytes.Equal(resumed.Bytes(), full) {
+			t.Fatalf("resumed aggregate differs from the one shot aggregate")
+		}
+	})
+
+	t.Run("Bad signature leaves the log untouched", func(t *testing.T) {
+		before := log.Bytes()
+		bad := [64]byte{}
+		for i := 32; i < 64; i++ {
+			bad[i] = 0xff
+		}
+		if err := log.Append(pms[:1], [][64]byte{bad}); err == nil {
+			t.Fatalf("Append with s >= n succeeded, want error")
+		}
+		if !bytes.Equal(log.Bytes(), before) {
+			t.Fatalf("failed Append changed the log")
+		}
+	})
+}
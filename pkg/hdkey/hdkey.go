@@ -0,0 +1,329 @@
+package hdkey
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/base58"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki
+//
+// Hierarchical deterministic keys: a seed gives a master key, and every key
+// gives 2^32 children, so one seed backed up once produces as many signing
+// keys as are wanted, each found again from its path such as
+// m/86'/0'/0'/0/0. Hardened children, ' in the path, need the parent's
+// private key; the others can be derived from an xpub too, so a watch-only
+// service can compute public keys it has no private keys for.
+//
+// Keys read and write as xprv and xpub, or tprv and tpub for testnet.
+//
+
+// Hardened is added to a child number to make it a hardened child
+const Hardened uint32 = 0x80000000
+
+// Extended key versions
+var (
+	versionMainnetPrivate = [4]byte{0x04, 0x88, 0xad, 0xe4}
+	versionMainnetPublic  = [4]byte{0x04, 0x88, 0xb2, 0x1e}
+	versionTestnetPrivate = [4]byte{0x04, 0x35, 0x83, 0x94}
+	versionTestnetPublic  = [4]byte{0x04, 0x35, 0x87, 0xcf}
+)
+
+// serializedLength is version, depth, parent fingerprint, child number,
+// chain code and key
+const serializedLength = 4 + 1 + 4 + 4 + 32 + 33
+
+// Key is an extended private or public key
+type Key struct {
+	// Network is "mainnet" or "testnet", only deciding how the key is
+	// written
+	Network string
+
+	Depth             uint8
+	ParentFingerprint [4]byte
+	ChildNumber       uint32
+	ChainCode         [32]byte
+
+	// privateKey is nil for extended public keys
+	privateKey *big.Int
+	publicKey  [33]byte
+}
+
+// NewMaster returns the master key of a 16 to 64 byte seed
+func NewMaster(seed []byte) (*Key, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("seed is %d bytes, want 16 to 64", len(seed))
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	i := mac.Sum(nil)
+
+	d := new(big.Int).SetBytes(i[:32])
+	if d.Sign() == 0 || d.Cmp(schnorr.Curve.N) >= 0 {
+		return nil, fmt.Errorf("seed gives an invalid master key, use another")
+	}
+	k := &Key{Network: "mainnet"}
+	copy(k.ChainCode[:], i[32:])
+	if err := k.setPrivateKey(d); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *Key) setPrivateKey(d *big.Int) error {
+	publickey, err := schnorr.ScalarBaseMult(d).PublicKey()
+	if err != nil {
+		return err
+	}
+	k.privateKey, k.publicKey = d, publickey
+	return nil
+}
+
+// IsPrivate reports whether the key is an xprv
+func (k *Key) IsPrivate() bool {
+	return k.privateKey != nil
+}
+
+// PrivateKey is the key's private key, nil for an xpub
+func (k *Key) PrivateKey() *big.Int {
+	if k.privateKey == nil {
+		return nil
+	}
+	return new(big.Int).Set(k.privateKey)
+}
+
+// PublicKey is the key's compressed public key
+func (k *Key) PublicKey() [33]byte {
+	return k.publicKey
+}
+
+// Fingerprint is the first 4 bytes of the hash160 of the public key, what
+// children record of their parent
+func (k *Key) Fingerprint() [4]byte {
+	var fp [4]byte
+	h := hash160(k.publicKey[:])
+	copy(fp[:], h[:4])
+	return fp
+}
+
+// Neuter returns the xpub of the key
+func (k *Key) Neuter() *Key {
+	pub := *k
+	pub.privateKey = nil
+	return &pub
+}
+
+// Child derives child i, hardened if i is Hardened or more. The rare index
+// that gives no valid key is an error; BIP-32 says to skip to the next.
+func (k *Key) Child(i uint32) (*Key, error) {
+	if k.Depth == 255 {
+		return nil, fmt.Errorf("key is at depth 255, it has no children")
+	}
+
+	var data []byte
+	if i >= Hardened {
+		if k.privateKey == nil {
+			return nil, fmt.Errorf("hardened child %d' needs the private key, not an xpub", i-Hardened)
+		}
+		data = append([]byte{0}, schnorr.GetBigIntBytesImmutable(k.privateKey)...)
+	} else {
+		data = append([]byte{}, k.publicKey[:]...)
+	}
+	data = appendUint32(data, i)
+
+	mac := hmac.New(sha512.New, k.ChainCode[:])
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(schnorr.Curve.N) >= 0 {
+		return nil, fmt.Errorf("child %s is invalid, use the next", formatIndex(i))
+	}
+
+	child := &Key{
+		Network:           k.Network,
+		Depth:             k.Depth + 1,
+		ParentFingerprint: k.Fingerprint(),
+		ChildNumber:       i,
+	}
+	copy(child.ChainCode[:], sum[32:])
+
+	if k.privateKey != nil {
+		d := tweak.Add(tweak, k.privateKey)
+		d.Mod(d, schnorr.Curve.N)
+		if d.Sign() == 0 {
+			return nil, fmt.Errorf("child %s is invalid, use the next", formatIndex(i))
+		}
+		if err := child.setPrivateKey(d); err != nil {
+			return nil, err
+		}
+		return child, nil
+	}
+
+	parent, err := schnorr.ParsePoint(k.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	p := schnorr.ScalarBaseMult(tweak).Add(parent)
+	if p.IsInfinity() {
+		return nil, fmt.Errorf("child %s is invalid, use the next", formatIndex(i))
+	}
+	if child.publicKey, err = p.PublicKey(); err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+// Derive follows the path from the key. A path starting m is from a master
+// key, one without from this key, so 0/5 from an account xpub.
+func (k *Key) Derive(path string) (*Key, error) {
+	indices, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.TrimSpace(path), "m") && k.Depth != 0 {
+		return nil, fmt.Errorf("path %s is from the master key, this key is at depth %d", path, k.Depth)
+	}
+	for _, i := range indices {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ParsePath reads a derivation path such as m/86'/0'/0'/0/0 into child
+// numbers. Hardened children are marked with ', h or H.
+func ParsePath(path string) ([]uint32, error) {
+	path = strings.TrimSpace(path)
+	if path == "m" || path == "" {
+		return nil, nil
+	}
+	path = strings.TrimPrefix(path, "m/")
+
+	indices := []uint32{}
+	for _, part := range strings.Split(path, "/") {
+		hardened := false
+		if s := strings.TrimRight(part, "'hH"); len(s) == len(part)-1 {
+			part, hardened = s, true
+		}
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(n) >= Hardened {
+			return nil, fmt.Errorf("invalid derivation path element %q", part)
+		}
+		if hardened {
+			n += uint64(Hardened)
+		}
+		indices = append(indices, uint32(n))
+	}
+	return indices, nil
+}
+
+// FormatPath writes child numbers as a path from the master key
+func FormatPath(indices []uint32) string {
+	parts := []string{"m"}
+	for _, i := range indices {
+		parts = append(parts, formatIndex(i))
+	}
+	return strings.Join(parts, "/")
+}
+
+func formatIndex(i uint32) string {
+	if i >= Hardened {
+		return fmt.Sprintf("%d'", i-Hardened)
+	}
+	return fmt.Sprint(i)
+}
+
+// String writes the key as an xprv or xpub, or tprv or tpub
+func (k *Key) String() string {
+	var version [4]byte
+	switch {
+	case k.Network == "testnet" && k.privateKey != nil:
+		version = versionTestnetPrivate
+	case k.Network == "testnet":
+		version = versionTestnetPublic
+	case k.privateKey != nil:
+		version = versionMainnetPrivate
+	default:
+		version = versionMainnetPublic
+	}
+
+	data := make([]byte, 0, serializedLength)
+	data = append(data, version[:]...)
+	data = append(data, k.Depth)
+	data = append(data, k.ParentFingerprint[:]...)
+	data = appendUint32(data, k.ChildNumber)
+	data = append(data, k.ChainCode[:]...)
+	if k.privateKey != nil {
+		data = append(data, 0)
+		data = append(data, schnorr.GetBigIntBytesImmutable(k.privateKey)...)
+	} else {
+		data = append(data, k.publicKey[:]...)
+	}
+	return base58.CheckEncode(data)
+}
+
+// Parse reads an xprv, xpub, tprv or tpub
+func Parse(s string) (*Key, error) {
+	data, err := base58.CheckDecode(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("extended key: %v", err)
+	}
+	if len(data) != serializedLength {
+		return nil, fmt.Errorf("extended key is %d bytes, want %d", len(data), serializedLength)
+	}
+
+	k := &Key{Depth: data[4], ChildNumber: binary.BigEndian.Uint32(data[9:13])}
+	copy(k.ParentFingerprint[:], data[5:9])
+	copy(k.ChainCode[:], data[13:45])
+	if k.Depth == 0 && (k.ParentFingerprint != [4]byte{} || k.ChildNumber != 0) {
+		return nil, fmt.Errorf("extended key is a master key with a parent")
+	}
+
+	var private bool
+	switch [4]byte{data[0], data[1], data[2], data[3]} {
+	case versionMainnetPrivate:
+		k.Network, private = "mainnet", true
+	case versionMainnetPublic:
+		k.Network = "mainnet"
+	case versionTestnetPrivate:
+		k.Network, private = "testnet", true
+	case versionTestnetPublic:
+		k.Network = "testnet"
+	default:
+		return nil, fmt.Errorf("extended key has unknown version %x", data[:4])
+	}
+
+	keydata := data[45:]
+	if private {
+		if keydata[0] != 0 {
+			return nil, fmt.Errorf("xprv does not hold a private key")
+		}
+		d := new(big.Int).SetBytes(keydata[1:])
+		if d.Sign() == 0 || d.Cmp(schnorr.Curve.N) >= 0 {
+			return nil, fmt.Errorf("xprv private key is not in the range 1..n-1")
+		}
+		if err := k.setPrivateKey(d); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+
+	copy(k.publicKey[:], keydata)
+	if _, err := schnorr.ParsePoint(k.publicKey); err != nil {
+		return nil, fmt.Errorf("xpub public key: %v", err)
+	}
+	return k, nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
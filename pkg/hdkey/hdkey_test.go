@@ -0,0 +1,156 @@
+package hdkey
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/base58"
+)
+
+// BIP-32 test vector 1
+const vectorSeed = "000102030405060708090a0b0c0d0e0f"
+
+var vectorKeys = []struct {
+	path string
+	xprv string
+	xpub string
+}{
+	{
+		"m",
+		"xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+		"xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8",
+	},
+	{
+		"m/0'",
+		"xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7",
+		"xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+	},
+	{
+		"m/0'/1",
+		"xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+		"xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+	},
+}
+
+func TestRIPEMD160(t *testing.T) {
+	for in, want := range map[string]string{
+		"":    "9c1185a5c5e9fc54612808977ee8f548b2258d31",
+		"abc": "8eb208f7e05d987a9b044a8e98c6b087f15a0bfc",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "9b752e45573d4b39f4dbd3323cab82bf63326bfb",
+	} {
+		if got := ripemd160([]byte(in)); hex.EncodeToString(got[:]) != want {
+			t.Fatalf("ripemd160(%q) = %x, want %s", in, got, want)
+		}
+	}
+}
+
+func TestDerive(t *testing.T) {
+	// given
+	seed, _ := hex.DecodeString(vectorSeed)
+	master, err := NewMaster(seed)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewMaster: %v", err)
+	}
+
+	for _, v := range vectorKeys {
+		// when
+		k, err := master.Derive(v.path)
+
+		// then
+		if err != nil {
+			t.Fatalf("Unexpected error from Derive(%s): %v", v.path, err)
+		}
+		if got := k.String(); got != v.xprv {
+			t.Fatalf("Derive(%s) = %s, want %s", v.path, got, v.xprv)
+		}
+		if got := k.Neuter().String(); got != v.xpub {
+			t.Fatalf("Derive(%s).Neuter() = %s, want %s", v.path, got, v.xpub)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, v := range vectorKeys {
+		for _, s := range []string{v.xprv, v.xpub} {
+			k, err := Parse(s)
+			if err != nil {
+				t.Fatalf("Unexpected error from Parse(%s): %v", s, err)
+			}
+			if got := k.String(); got != s {
+				t.Fatalf("Parse(%s).String() = %s", s, got)
+			}
+		}
+	}
+}
+
+func TestPublicDerivation(t *testing.T) {
+	// given
+	account, err := Parse(vectorKeys[1].xpub)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+
+	// when
+	child, err := account.Derive("1")
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from Derive: %v", err)
+	}
+	if got := child.String(); got != vectorKeys[2].xpub {
+		t.Fatalf("Derive(1) = %s, want %s", got, vectorKeys[2].xpub)
+	}
+	if _, err := account.Derive("1'"); err == nil {
+		t.Fatalf("Derive(1') from an xpub = nil error, want one")
+	}
+	if _, err := account.Derive("m/1"); err == nil {
+		t.Fatalf("Derive(m/1) from depth 1 = nil error, want one")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	indices, err := ParsePath("m/86'/0h/0H/0/5")
+	if err != nil {
+		t.Fatalf("Unexpected error from ParsePath: %v", err)
+	}
+	if got := FormatPath(indices); got != "m/86'/0'/0'/0/5" {
+		t.Fatalf("FormatPath() = %s, want m/86'/0'/0'/0/5", got)
+	}
+	for _, path := range []string{"m/", "m/x", "m/1''", "m/2147483648", "m//1"} {
+		if _, err := ParsePath(path); err == nil {
+			t.Fatalf("ParsePath(%q) = nil error, want one", path)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	k, err := Parse(vectorKeys[1].xprv)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	valid, _ := base58.CheckDecode(k.String())
+
+	tests := map[string]func(b []byte){
+		"unknown version":    func(b []byte) { b[3] = 0 },
+		"private key prefix": func(b []byte) { b[45] = 1 },
+		"zero private key": func(b []byte) {
+			for i := 46; i < len(b); i++ {
+				b[i] = 0
+			}
+		},
+		"master with parent": func(b []byte) { b[4] = 0 },
+		"xpub with private key": func(b []byte) {
+			copy(b, versionMainnetPublic[:])
+		},
+	}
+	for name, mutate := range tests {
+		b := append([]byte{}, valid...)
+		mutate(b)
+		if _, err := Parse(base58.CheckEncode(b)); err == nil {
+			t.Fatalf("%s: Parse() = nil error, want one", name)
+		}
+	}
+	if _, err := Parse(k.String()[:len(k.String())-1] + "1"); err == nil {
+		t.Fatalf("Parse() with a bad checksum = nil error, want one")
+	}
+}
@@ -0,0 +1,219 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+//
+// Programs run before and after signing, so that checks and changes to
+// what gets signed can be added without forking the tool. A hook is given
+// the request as json on stdin. A pre-sign hook allows the request by
+// exiting 0, and may print a json object with a new message or digest to
+// sign instead; any other exit refuses it, with stderr as the reason. A
+// post-sign hook is given the signature as well, and exiting non-zero
+// withholds it, for hooks that must record every signature before it is
+// released.
+//
+
+// DefaultTimeout is how long a hook may run before it is killed
+const DefaultTimeout = 10 * time.Second
+
+// Stage is the point in signing a hook runs at
+type Stage string
+
+const (
+	PreSign  Stage = "pre-sign"
+	PostSign Stage = "post-sign"
+)
+
+// Request is what hooks are given. Message is the message when the signer
+// hashes it itself, and empty when it is given a digest. File names the
+// file a digest was streamed from, "-" for stdin, when the message was too
+// large to pass.
+type Request struct {
+	Stage     Stage  `json:"stage"`
+	Key       string `json:"key,omitempty"`
+	PublicKey string `json:"public_key,omitempty"`
+	Principal string `json:"principal,omitempty"`
+	Message   []byte `json:"message,omitempty"`
+	File      string `json:"file,omitempty"`
+	Digest    string `json:"digest"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Result is what a pre-sign hook may print to change what is signed
+type Result struct {
+	Message []byte `json:"message,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+// Hook is a program and its arguments
+type Hook struct {
+	Path    string
+	Args    []string
+	Timeout time.Duration
+}
+
+// Parse reads a hook from a command line, split on spaces
+func Parse(command string) (*Hook, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("hook command is empty")
+	}
+	return &Hook{Path: fields[0], Args: fields[1:], Timeout: DefaultTimeout}, nil
+}
+
+func (h *Hook) String() string {
+	return strings.Join(append([]string{h.Path}, h.Args...), " ")
+}
+
+// RejectedError is a hook refusing a request
+type RejectedError struct {
+	Hook   string
+	Stage  Stage
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s hook %s refused the request", e.Stage, e.Hook)
+	}
+	return fmt.Sprintf("%s hook %s refused the request: %s", e.Stage, e.Hook, e.Reason)
+}
+
+// Run runs the hook with the request, returning what it printed if it
+// allowed it
+func (h *Hook) Run(ctx context.Context, req *Request) (*Result, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.Path, h.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s hook %s: %v", req.Stage, h, ctx.Err())
+		}
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, &RejectedError{Hook: h.String(), Stage: req.Stage, Reason: strings.TrimSpace(stderr.String())}
+		}
+		return nil, fmt.Errorf("%s hook %s: %v", req.Stage, h, err)
+	}
+
+	result := new(Result)
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, result); err != nil {
+			return nil, fmt.Errorf("%s hook %s printed something other than json: %v", req.Stage, h, err)
+		}
+	}
+	return result, nil
+}
+
+// Set is the hooks configured for a signer. The zero value and nil run
+// nothing.
+type Set struct {
+	Pre  []*Hook
+	Post []*Hook
+}
+
+// NewSet parses the pre-sign and post-sign hook commands, nil if there are
+// none
+func NewSet(pre, post []string) (*Set, error) {
+	if len(pre) == 0 && len(post) == 0 {
+		return nil, nil
+	}
+	s := new(Set)
+	for _, command := range pre {
+		h, err := Parse(command)
+		if err != nil {
+			return nil, err
+		}
+		s.Pre = append(s.Pre, h)
+	}
+	for _, command := range post {
+		h, err := Parse(command)
+		if err != nil {
+			return nil, err
+		}
+		s.Post = append(s.Post, h)
+	}
+	return s, nil
+}
+
+// RunPreSign runs the pre-sign hooks in order, each seeing the request as
+// the ones before left it. A hook replacing the message changes the digest
+// to hash of the new message, so hash may only be nil for signers given a
+// digest, where hooks may only replace the digest.
+func (s *Set) RunPreSign(ctx context.Context, req *Request, hash func([]byte) [32]byte) error {
+	if s == nil {
+		return nil
+	}
+	for _, h := range s.Pre {
+		req.Stage = PreSign
+		result, err := h.Run(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case result.Message != nil && hash == nil:
+			return fmt.Errorf("pre-sign hook %s replaced the message of a digest signing request", h)
+		case result.Message != nil:
+			digest := hash(result.Message)
+			req.Message, req.Digest = result.Message, hex.EncodeToString(digest[:])
+		case result.Digest != "":
+			digest, err := hex.DecodeString(result.Digest)
+			if err != nil || len(digest) != 32 {
+				return fmt.Errorf("pre-sign hook %s returned a digest that is not 32 bytes of hex", h)
+			}
+			// the message no longer matches the digest, so it is dropped
+			req.Message, req.Digest = nil, result.Digest
+		}
+	}
+	return nil
+}
+
+// RunPostSign runs the post-sign hooks in order with the signature set on
+// the request. Any of them failing means the signature must be withheld.
+func (s *Set) RunPostSign(ctx context.Context, req *Request) error {
+	if s == nil {
+		return nil
+	}
+	for _, h := range s.Post {
+		req.Stage = PostSign
+		if _, err := h.Run(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DigestBytes decodes the request's digest
+func (req *Request) DigestBytes() ([32]byte, error) {
+	var digest [32]byte
+	b, err := hex.DecodeString(req.Digest)
+	if err != nil || len(b) != 32 {
+		return digest, fmt.Errorf("digest %q is not 32 bytes of hex", req.Digest)
+	}
+	copy(digest[:], b)
+	return digest, nil
+}
@@ -0,0 +1,105 @@
+package hooks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// script writes an executable shell script and returns it as a hook
+func script(t *testing.T, body string) *Hook {
+	path := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0700); err != nil {
+		t.Fatalf("Unexpected error from WriteFile: %v", err)
+	}
+	return &Hook{Path: path}
+}
+
+func hash(b []byte) [32]byte {
+	return sha256.Sum256(b)
+}
+
+func TestPreSign(t *testing.T) {
+	ctx := context.Background()
+	digest := hash([]byte("release v1"))
+	newRequest := func() *Request {
+		return &Request{Key: "release", Message: []byte("release v1"), Digest: hex.EncodeToString(digest[:])}
+	}
+
+	t.Run("Allowing hooks leave the request alone", func(t *testing.T) {
+		req := newRequest()
+		s := &Set{Pre: []*Hook{script(t, "cat > /dev/null")}}
+		if err := s.RunPreSign(ctx, req, hash); err != nil {
+			t.Fatalf("Unexpected error from RunPreSign: %v", err)
+		}
+		if string(req.Message) != "release v1" || req.Digest != hex.EncodeToString(digest[:]) {
+			t.Fatalf("RunPreSign() changed the request to %+v", req)
+		}
+	})
+
+	t.Run("Refusals carry stderr", func(t *testing.T) {
+		s := &Set{Pre: []*Hook{script(t, "grep -q '\"key\":\"release\"' && echo 'no releases on fridays' >&2; exit 1")}}
+		err := s.RunPreSign(ctx, newRequest(), hash)
+
+		var rejected *RejectedError
+		if !errors.As(err, &rejected) || rejected.Reason != "no releases on fridays" {
+			t.Fatalf("RunPreSign() = %v, want a refusal with the reason", err)
+		}
+	})
+
+	t.Run("Message transformations are hashed again", func(t *testing.T) {
+		req := newRequest()
+		// the message is base64 in the json, "c2lnbmVk" is "signed"
+		s := &Set{Pre: []*Hook{script(t, `cat > /dev/null; echo '{"message": "c2lnbmVk"}'`)}}
+		if err := s.RunPreSign(ctx, req, hash); err != nil {
+			t.Fatalf("Unexpected error from RunPreSign: %v", err)
+		}
+
+		want := hash([]byte("signed"))
+		if string(req.Message) != "signed" || req.Digest != hex.EncodeToString(want[:]) {
+			t.Fatalf("RunPreSign() = %q %s, want the new message and its digest", req.Message, req.Digest)
+		}
+	})
+
+	t.Run("Digest requests can't get a message", func(t *testing.T) {
+		req := newRequest()
+		req.Message = nil
+		s := &Set{Pre: []*Hook{script(t, `cat > /dev/null; echo '{"message": "c2lnbmVk"}'`)}}
+		if err := s.RunPreSign(ctx, req, nil); err == nil {
+			t.Fatalf("RunPreSign() replaced the message of a digest request, want error")
+		}
+	})
+
+	t.Run("Nil sets run nothing", func(t *testing.T) {
+		var s *Set
+		if err := s.RunPreSign(ctx, newRequest(), hash); err != nil {
+			t.Fatalf("Unexpected error from RunPreSign: %v", err)
+		}
+	})
+}
+
+func TestPostSign(t *testing.T) {
+	// given
+	log := filepath.Join(t.TempDir(), "signatures.log")
+	s := &Set{Post: []*Hook{script(t, "cat >> "+log)}}
+	req := &Request{Key: "release", Digest: strings.Repeat("00", 32), Signature: "abcd"}
+
+	// when
+	if err := s.RunPostSign(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error from RunPostSign: %v", err)
+	}
+
+	// then
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("Unexpected error from ReadFile: %v", err)
+	}
+	if !strings.Contains(string(data), `"stage":"post-sign"`) || !strings.Contains(string(data), `"signature":"abcd"`) {
+		t.Fatalf("post-sign hook was given %s", data)
+	}
+}
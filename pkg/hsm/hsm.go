@@ -0,0 +1,340 @@
+package hsm
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/ryohare/schnorr-go/pkg/backend"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// A simulated HSM, for testing code written against one without the
+// hardware. It keeps to the semantics of a PKCS#11 token that such code has
+// to handle: keys live in the token and are used through sessions, signing
+// needs the user logged in with the PIN, too many wrong PINs lock it until
+// the security officer sets a new one, and keys made non-extractable can
+// never be read out. Everything is in memory and gone with the Token.
+//
+// It is not a security boundary: the keys are in this process's memory.
+//
+
+// MaxPINAttempts is how many wrong PINs in a row lock the user PIN
+const MaxPINAttempts = 3
+
+// MaxSessions is how many sessions may be open on a token at once
+const MaxSessions = 16
+
+var (
+	// ErrPINIncorrect is CKR_PIN_INCORRECT
+	ErrPINIncorrect = errors.New("pin incorrect")
+	// ErrPINLocked is CKR_PIN_LOCKED, after MaxPINAttempts wrong PINs
+	ErrPINLocked = errors.New("pin locked")
+	// ErrNotLoggedIn is CKR_USER_NOT_LOGGED_IN
+	ErrNotLoggedIn = errors.New("user not logged in")
+	// ErrSessionClosed is CKR_SESSION_HANDLE_INVALID
+	ErrSessionClosed = errors.New("session closed")
+	// ErrSessionCount is CKR_SESSION_COUNT, over MaxSessions
+	ErrSessionCount = errors.New("too many sessions open")
+	// ErrKeyNotFound is a search for the key's label finding nothing
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyExists is a key of the label being made twice
+	ErrKeyExists = errors.New("key already exists")
+	// ErrKeyNotExtractable is CKR_KEY_UNEXTRACTABLE
+	ErrKeyNotExtractable = errors.New("key not extractable")
+)
+
+// key is a private key object of the token
+type key struct {
+	d           *big.Int
+	public      [33]byte
+	extractable bool
+}
+
+// Token is one simulated token, in its slot
+type Token struct {
+	Label string
+	// Random is where keys are generated from, crypto/rand if nil
+	Random io.Reader
+
+	mu       sync.Mutex
+	soPIN    [32]byte
+	userPIN  [32]byte
+	failures int
+	loggedIn bool
+	sessions map[*Session]bool
+	keys     map[string]*key
+}
+
+// New returns an initialized token with the security officer's and the
+// user's PINs
+func New(label, soPIN, userPIN string) *Token {
+	return &Token{
+		Label:    label,
+		soPIN:    sha256.Sum256([]byte(soPIN)),
+		userPIN:  sha256.Sum256([]byte(userPIN)),
+		sessions: map[*Session]bool{},
+		keys:     map[string]*key{},
+	}
+}
+
+// OpenSession opens a session on the token
+func (t *Token) OpenSession() (*Session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.sessions) >= MaxSessions {
+		return nil, ErrSessionCount
+	}
+	s := &Session{token: t}
+	t.sessions[s] = true
+	return s, nil
+}
+
+// CloseAllSessions closes every session, which logs the user out
+func (t *Token) CloseAllSessions() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for s := range t.sessions {
+		delete(t.sessions, s)
+	}
+	t.loggedIn = false
+}
+
+// InitPIN sets a new user PIN, unlocking it, as the security officer
+func (t *Token) InitPIN(soPIN, userPIN string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sum := sha256.Sum256([]byte(soPIN))
+	if subtle.ConstantTimeCompare(sum[:], t.soPIN[:]) != 1 {
+		return ErrPINIncorrect
+	}
+	t.userPIN = sha256.Sum256([]byte(userPIN))
+	t.failures = 0
+	return nil
+}
+
+// Session is a session on a token. As in PKCS#11, logging in is for the
+// token as a whole, so logging in on one session logs in all of them.
+type Session struct {
+	token *Token
+}
+
+// locked runs f under the token's lock, if the session is still open
+func (s *Session) locked(f func(t *Token) error) error {
+	t := s.token
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.sessions[s] {
+		return ErrSessionClosed
+	}
+	return f(t)
+}
+
+// user runs f under the token's lock, if the user is logged in
+func (s *Session) user(f func(t *Token) error) error {
+	return s.locked(func(t *Token) error {
+		if !t.loggedIn {
+			return ErrNotLoggedIn
+		}
+		return f(t)
+	})
+}
+
+// Close closes the session, logging the user out if it was the last
+func (s *Session) Close() error {
+	return s.locked(func(t *Token) error {
+		delete(t.sessions, s)
+		if len(t.sessions) == 0 {
+			t.loggedIn = false
+		}
+		return nil
+	})
+}
+
+// Login logs the user in with the PIN. A wrong PIN counts towards
+// MaxPINAttempts, a right one resets the count.
+func (s *Session) Login(pin string) error {
+	return s.locked(func(t *Token) error {
+		if t.failures >= MaxPINAttempts {
+			return ErrPINLocked
+		}
+		sum := sha256.Sum256([]byte(pin))
+		if subtle.ConstantTimeCompare(sum[:], t.userPIN[:]) != 1 {
+			t.failures++
+			if t.failures >= MaxPINAttempts {
+				return ErrPINLocked
+			}
+			return ErrPINIncorrect
+		}
+		t.failures = 0
+		t.loggedIn = true
+		return nil
+	})
+}
+
+// Logout logs the user out of every session
+func (s *Session) Logout() error {
+	return s.user(func(t *Token) error {
+		t.loggedIn = false
+		return nil
+	})
+}
+
+// GenerateKey makes a key in the token under the label and returns its
+// public key
+func (s *Session) GenerateKey(label string, extractable bool) ([33]byte, error) {
+	var public [33]byte
+	err := s.user(func(t *Token) error {
+		if _, ok := t.keys[label]; ok {
+			return fmt.Errorf("%w: %s", ErrKeyExists, label)
+		}
+		k, err := schnorr.GeneratePrivateKey(t.Random)
+		if err != nil {
+			return err
+		}
+		public = k.PublicKey().Serialize()
+		t.keys[label] = &key{d: k.D(), public: public, extractable: extractable}
+		return nil
+	})
+	return public, err
+}
+
+// ImportKey puts an existing private key in the token under the label,
+// as unwrapping one would
+func (s *Session) ImportKey(label string, d *big.Int, extractable bool) ([33]byte, error) {
+	var public [33]byte
+	err := s.user(func(t *Token) error {
+		if _, ok := t.keys[label]; ok {
+			return fmt.Errorf("%w: %s", ErrKeyExists, label)
+		}
+		k, err := schnorr.NewPrivateKey(d)
+		if err != nil {
+			return err
+		}
+		public = k.PublicKey().Serialize()
+		t.keys[label] = &key{d: new(big.Int).Set(d), public: public, extractable: extractable}
+		return nil
+	})
+	return public, err
+}
+
+// DestroyKey removes the key from the token
+func (s *Session) DestroyKey(label string) error {
+	return s.user(func(t *Token) error {
+		if _, ok := t.keys[label]; !ok {
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, label)
+		}
+		delete(t.keys, label)
+		return nil
+	})
+}
+
+// Keys returns the labels of the keys in the token
+func (s *Session) Keys() ([]string, error) {
+	var labels []string
+	err := s.locked(func(t *Token) error {
+		for label := range t.keys {
+			labels = append(labels, label)
+		}
+		return nil
+	})
+	return labels, err
+}
+
+// ExportKey reads out the private key, which only extractable keys allow
+func (s *Session) ExportKey(label string) (*big.Int, error) {
+	var d *big.Int
+	err := s.user(func(t *Token) error {
+		k, ok := t.keys[label]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, label)
+		}
+		if !k.extractable {
+			return fmt.Errorf("%w: %s", ErrKeyNotExtractable, label)
+		}
+		d = new(big.Int).Set(k.d)
+		return nil
+	})
+	return d, err
+}
+
+// PublicKey returns the public key under the label. Public keys are public
+// objects, read without logging in.
+func (s *Session) PublicKey(ctx context.Context, label string) ([33]byte, error) {
+	var public [33]byte
+	err := s.locked(func(t *Token) error {
+		k, ok := t.keys[label]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, label)
+		}
+		public = k.public
+		return nil
+	})
+	return public, err
+}
+
+// Sign signs the message with the key under the label
+func (s *Session) Sign(ctx context.Context, label string, message [32]byte) ([64]byte, error) {
+	var signature [64]byte
+	err := s.user(func(t *Token) error {
+		k, ok := t.keys[label]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, label)
+		}
+		var err error
+		signature, err = schnorr.Sign(k.d, message)
+		return err
+	})
+	return signature, err
+}
+
+// Signer returns the key under the label as a crypto.Signer, signing
+// through the session
+func (s *Session) Signer(label string) (crypto.Signer, error) {
+	public, err := s.PublicKey(context.Background(), label)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := schnorr.ParsePublicKey(public[:])
+	if err != nil {
+		return nil, err
+	}
+	return &signer{session: s, label: label, public: pk}, nil
+}
+
+var _ backend.Backend = (*Session)(nil)
+
+// signer is a key of the token as a crypto.Signer, with the digests and
+// signatures of schnorr.Signer
+type signer struct {
+	session *Session
+	label   string
+	public  *schnorr.PublicKey
+}
+
+func (s *signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts != nil && opts.HashFunc() != 0 && opts.HashFunc().Size() != 32 {
+		return nil, fmt.Errorf("digest must be from a 32 byte hash, got %v", opts.HashFunc())
+	}
+	if len(digest) != 32 {
+		return nil, fmt.Errorf("digest must be 32 bytes, got %d", len(digest))
+	}
+	var message [32]byte
+	copy(message[:], digest)
+	signature, err := s.session.Sign(context.Background(), s.label, message)
+	if err != nil {
+		return nil, err
+	}
+	return signature[:], nil
+}
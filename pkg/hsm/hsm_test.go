@@ -0,0 +1,114 @@
+package hsm
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestToken(t *testing.T) {
+	token := New("test", "so-pin", "1234")
+	session, err := token.OpenSession()
+	if err != nil {
+		t.Fatalf("Unexpected error from OpenSession: %v", err)
+	}
+	ctx := context.Background()
+	message := sha256.Sum256([]byte("test"))
+
+	// given a key generated after logging in
+	if _, err := session.GenerateKey("release", false); !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("GenerateKey() before Login = %v, want ErrNotLoggedIn", err)
+	}
+	if err := session.Login("1234"); err != nil {
+		t.Fatalf("Unexpected error from Login: %v", err)
+	}
+	pk, err := session.GenerateKey("release", false)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateKey: %v", err)
+	}
+
+	// when
+	sig, err := session.Sign(ctx, "release", message)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	if ok, err := schnorr.Verify(pk, message, sig); !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+
+	t.Run("Keys made non-extractable can't be read out", func(t *testing.T) {
+		if _, err := session.ExportKey("release"); !errors.Is(err, ErrKeyNotExtractable) {
+			t.Fatalf("ExportKey() = %v, want ErrKeyNotExtractable", err)
+		}
+		keys, _ := schnorr.GenerateTestKeys([]byte("hsm"), 1)
+		if _, err := session.ImportKey("backup", keys[0].PrivateKey, true); err != nil {
+			t.Fatalf("Unexpected error from ImportKey: %v", err)
+		}
+		if d, err := session.ExportKey("backup"); err != nil || d.Cmp(keys[0].PrivateKey) != 0 {
+			t.Fatalf("ExportKey() = %v, %v, want the imported key", d, err)
+		}
+	})
+
+	t.Run("As a crypto.Signer", func(t *testing.T) {
+		signer, err := session.Signer("release")
+		if err != nil {
+			t.Fatalf("Unexpected error from Signer: %v", err)
+		}
+		raw, err := signer.Sign(nil, message[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		signature, _ := schnorr.ParseSignature(raw)
+		if ok, _ := signer.Public().(*schnorr.PublicKey).Verify(message, signature); !ok {
+			t.Fatalf("signature from the crypto.Signer does not verify")
+		}
+	})
+
+	t.Run("Closing the last session logs out", func(t *testing.T) {
+		second, _ := token.OpenSession()
+		session.Close()
+		if _, err := session.Sign(ctx, "release", message); !errors.Is(err, ErrSessionClosed) {
+			t.Fatalf("Sign() on a closed session = %v, want ErrSessionClosed", err)
+		}
+		if _, err := second.Sign(ctx, "release", message); err != nil {
+			t.Fatalf("Sign() on the other session = %v, want the login kept", err)
+		}
+		second.Close()
+		third, _ := token.OpenSession()
+		defer third.Close()
+		if _, err := third.Sign(ctx, "release", message); !errors.Is(err, ErrNotLoggedIn) {
+			t.Fatalf("Sign() after every session closed = %v, want ErrNotLoggedIn", err)
+		}
+		if _, err := third.PublicKey(ctx, "release"); err != nil {
+			t.Fatalf("PublicKey() without logging in = %v, want the public key", err)
+		}
+	})
+
+	t.Run("Wrong PINs lock the token until the SO sets a new one", func(t *testing.T) {
+		s, _ := token.OpenSession()
+		defer s.Close()
+		for i := 1; i < MaxPINAttempts; i++ {
+			if err := s.Login("0000"); !errors.Is(err, ErrPINIncorrect) {
+				t.Fatalf("Login() with a wrong PIN = %v, want ErrPINIncorrect", err)
+			}
+		}
+		if err := s.Login("0000"); !errors.Is(err, ErrPINLocked) {
+			t.Fatalf("Login() with the last wrong PIN = %v, want ErrPINLocked", err)
+		}
+		if err := s.Login("1234"); !errors.Is(err, ErrPINLocked) {
+			t.Fatalf("Login() once locked = %v, want ErrPINLocked", err)
+		}
+		if err := token.InitPIN("so-pin", "5678"); err != nil {
+			t.Fatalf("Unexpected error from InitPIN: %v", err)
+		}
+		if err := s.Login("5678"); err != nil {
+			t.Fatalf("Unexpected error from Login: %v", err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package httpsig
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+)
+
+//
+// https://www.rfc-editor.org/rfc/rfc9530.html Content-Digest, so a signature
+// covering the header covers the body
+//
+
+// ContentDigest is the Content-Digest header value for the body
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// VerifyContentDigest checks the body against every sha-256 and sha-512
+// digest in the header, which must have at least one of them
+func VerifyContentDigest(header string, body []byte) error {
+	members, err := parseDictionary(header)
+	if err != nil {
+		return fmt.Errorf("Content-Digest: %v", err)
+	}
+
+	checked := 0
+	for _, m := range members {
+		var sum []byte
+		switch m.Name {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+		if subtle.ConstantTimeCompare(sum, m.Bytes) != 1 {
+			return fmt.Errorf("body does not match its %s digest", m.Name)
+		}
+		checked++
+	}
+	if checked == 0 {
+		return fmt.Errorf("Content-Digest has no sha-256 or sha-512 digest")
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package httpsig
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// https://www.rfc-editor.org/rfc/rfc9421.html with the Schnorr keys of this
+// repo. The signature is over the sha256 of the signature base. There is no
+// registered algorithm for it, so the alg parameter is Algorithm.
+//
+
+// Algorithm is the alg parameter of the signatures made here
+const Algorithm = "schnorr-secp256k1-sha256"
+
+// DefaultLabel is the label signatures are made under
+const DefaultLabel = "sig1"
+
+// MaxClockSkew is how far in the future a created time may be
+const MaxClockSkew = time.Minute
+
+var (
+	// DefaultRequestComponents are what a request signature covers when the
+	// signer is not told otherwise, with content-digest added for a body
+	DefaultRequestComponents = []string{"@method", "@authority", "@path", "@query"}
+
+	// DefaultResponseComponents are the same for responses, tying the
+	// response to the request it answers
+	DefaultResponseComponents = []string{"@status", "@method;req", "@authority;req", "@path;req"}
+)
+
+// Message is the part of a request or response components are taken from.
+// Request is the request a response answers, for components with ;req.
+type Message struct {
+	Method  string
+	URL     *url.URL
+	Status  int
+	Header  http.Header
+	Request *Message
+}
+
+// RequestMessage wraps a client request or one received by a server, whose
+// URL lacks the scheme and host
+func RequestMessage(r *http.Request) *Message {
+	u := *r.URL
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	if r.Host != "" {
+		u.Host = r.Host
+	}
+	return &Message{Method: r.Method, URL: &u, Header: r.Header}
+}
+
+// ResponseMessage wraps a response to the request
+func ResponseMessage(status int, header http.Header, r *http.Request) *Message {
+	m := &Message{Status: status, Header: header}
+	if r != nil {
+		m.Request = RequestMessage(r)
+	}
+	return m
+}
+
+func (m *Message) isResponse() bool {
+	return m.Status != 0
+}
+
+// component is a parsed component identifier such as "@path";req
+type component struct {
+	name string
+	req  bool
+}
+
+func parseComponent(s string) (component, error) {
+	parts := strings.Split(s, ";")
+	c := component{name: strings.ToLower(strings.TrimSpace(parts[0]))}
+	if c.name == "" {
+		return c, fmt.Errorf("empty component identifier")
+	}
+	for _, p := range parts[1:] {
+		if p != "req" {
+			return c, fmt.Errorf("component %s: parameter %q is not supported", c.name, p)
+		}
+		c.req = true
+	}
+	return c, nil
+}
+
+func componentFromItem(it item) (component, error) {
+	c := component{name: it.Value}
+	for _, p := range it.Params {
+		if p.Name != "req" || p.Value != true {
+			return c, fmt.Errorf("component %s: parameter %q is not supported", c.name, p.Name)
+		}
+		c.req = true
+	}
+	return c, nil
+}
+
+func (c component) String() string {
+	s := quote(c.name)
+	if c.req {
+		s += ";req"
+	}
+	return s
+}
+
+// value canonicalizes the component of the message, as in section 2 of the
+// RFC
+func (c component) value(m *Message) (string, error) {
+	if c.req {
+		if m.Request == nil {
+			return "", fmt.Errorf("component %s: no request to take it from", c)
+		}
+		m = m.Request
+	}
+
+	if !strings.HasPrefix(c.name, "@") {
+		values, ok := m.Header[http.CanonicalHeaderKey(c.name)]
+		if !ok {
+			return "", fmt.Errorf("component %s: header is missing", c)
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.TrimSpace(v)
+		}
+		return strings.Join(trimmed, ", "), nil
+	}
+
+	if c.name == "@status" {
+		if !m.isResponse() {
+			return "", fmt.Errorf("component @status: not a response")
+		}
+		return strconv.Itoa(m.Status), nil
+	}
+	if m.URL == nil {
+		return "", fmt.Errorf("component %s: not a request", c)
+	}
+
+	switch c.name {
+	case "@method":
+		return m.Method, nil
+	case "@target-uri":
+		u := *m.URL
+		u.Host = authority(m.URL)
+		u.Scheme = strings.ToLower(u.Scheme)
+		return u.String(), nil
+	case "@authority":
+		return authority(m.URL), nil
+	case "@scheme":
+		return strings.ToLower(m.URL.Scheme), nil
+	case "@request-target":
+		return m.URL.RequestURI(), nil
+	case "@path":
+		path := m.URL.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		return path, nil
+	case "@query":
+		return "?" + m.URL.RawQuery, nil
+	}
+	return "", fmt.Errorf("component %s is not supported", c)
+}
+
+// authority is the lowercased host with the port only if it is not the
+// default for the scheme
+func authority(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	scheme := strings.ToLower(u.Scheme)
+	if (scheme == "http" && strings.HasSuffix(host, ":80")) || (scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndexByte(host, ':')]
+	}
+	return host
+}
+
+// signatureBase builds the lines of the covered components followed by
+// @signature-params, whose value is the raw inner list with parameters
+func signatureBase(m *Message, components []component, params string) ([]byte, error) {
+	var b strings.Builder
+	seen := map[string]bool{}
+	for _, c := range components {
+		if seen[c.String()] {
+			return nil, fmt.Errorf("component %s is covered twice", c)
+		}
+		seen[c.String()] = true
+
+		value, err := c.value(m)
+		if err != nil {
+			return nil, err
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("component %s has a line break", c)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", c, value)
+	}
+	fmt.Fprintf(&b, "%s: %s", quote("@signature-params"), params)
+	return []byte(b.String()), nil
+}
+
+// Signer adds signatures to messages. Components are component identifiers
+// like "@path" or "@method;req", the defaults for the kind of message if
+// nil. A non-zero Expires sets the expires parameter that far after created.
+type Signer struct {
+	Key        *big.Int
+	KeyID      string
+	Label      string
+	Components []string
+	Expires    time.Duration
+	Tag        string
+	Now        func() time.Time
+}
+
+// NewSigner signs with the private key under the key id verifiers resolve
+func NewSigner(privatekey *big.Int, keyID string) *Signer {
+	return &Signer{Key: privatekey, KeyID: keyID, Label: DefaultLabel, Now: time.Now}
+}
+
+func (s *Signer) components(m *Message) ([]component, error) {
+	ids := s.Components
+	if ids == nil {
+		ids = DefaultRequestComponents
+		if m.isResponse() {
+			ids = DefaultResponseComponents
+		}
+		if m.Header.Get("Content-Digest") != "" {
+			ids = append(append([]string{}, ids...), "content-digest")
+		}
+	}
+
+	components := []component{}
+	for _, id := range ids {
+		c, err := parseComponent(id)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, c)
+	}
+	return components, nil
+}
+
+// Sign adds the signature to the Signature-Input and Signature headers of
+// the message, next to any signatures already there
+func (s *Signer) Sign(m *Message) error {
+	components, err := s.components(m)
+	if err != nil {
+		return err
+	}
+
+	label := s.Label
+	if label == "" {
+		label = DefaultLabel
+	}
+	existing, err := parseDictionary(m.Header.Get("Signature-Input"))
+	if err != nil {
+		return fmt.Errorf("Signature-Input: %v", err)
+	}
+	for _, e := range existing {
+		if e.Name == label {
+			return fmt.Errorf("message already has a signature labelled %s", label)
+		}
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	created := now()
+
+	ids := make([]string, len(components))
+	for i, c := range components {
+		ids[i] = c.String()
+	}
+	params := "(" + strings.Join(ids, " ") + ")" + fmt.Sprintf(";created=%d", created.Unix())
+	if s.Expires != 0 {
+		params += fmt.Sprintf(";expires=%d", created.Add(s.Expires).Unix())
+	}
+	params += ";keyid=" + quote(s.KeyID) + ";alg=" + quote(Algorithm)
+	if s.Tag != "" {
+		params += ";tag=" + quote(s.Tag)
+	}
+
+	base, err := signatureBase(m, components, params)
+	if err != nil {
+		return err
+	}
+	sig, err := schnorr.Sign(s.Key, sha256.Sum256(base))
+	if err != nil {
+		return err
+	}
+
+	appendMember(m.Header, "Signature-Input", label+"="+params)
+	appendMember(m.Header, "Signature", label+"=:"+base64.StdEncoding.EncodeToString(sig[:])+":")
+	return nil
+}
+
+func appendMember(header http.Header, name, member string) {
+	if existing := header.Get(name); existing != "" {
+		member = existing + ", " + member
+	}
+	header.Set(name, member)
+}
@@ -0,0 +1,176 @@
+package httpsig
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestSignatureBase(t *testing.T) {
+	// given, the example from section 2.5 of the RFC
+	r, _ := http.NewRequest("POST", "https://example.com/foo?param=Value&Pet=dog", strings.NewReader(`{"hello": "world"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Digest", "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:")
+	r.Header.Set("Content-Length", "18")
+
+	components := []component{}
+	for _, id := range []string{"@method", "@authority", "@path", "content-digest", "content-length", "content-type"} {
+		c, _ := parseComponent(id)
+		components = append(components, c)
+	}
+	params := `("@method" "@authority" "@path" "content-digest" "content-length" "content-type");created=1618884473;keyid="test-key-rsa-pss"`
+
+	// when
+	observed, err := signatureBase(RequestMessage(r), components, params)
+	if err != nil {
+		t.Fatalf("Unexpected error from signatureBase: %v", err)
+	}
+
+	// then
+	expected := `"@method": POST
+"@authority": example.com
+"@path": /foo
+"content-digest": sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:
+"content-length": 18
+"content-type": application/json
+"@signature-params": ` + params
+	if string(observed) != expected {
+		t.Fatalf("signatureBase() = %s, want %s", observed, expected)
+	}
+}
+
+func TestContentDigest(t *testing.T) {
+	// example from RFC 9530
+	observed := ContentDigest([]byte(`{"hello": "world"}`))
+	if observed != "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:" {
+		t.Fatalf("ContentDigest() = %s", observed)
+	}
+	if err := VerifyContentDigest(observed, []byte(`{"hello": "world!"}`)); err == nil {
+		t.Fatalf("VerifyContentDigest of another body succeeded, want error")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("httpsig"), 2)
+	now := time.Unix(1700000000, 0)
+
+	sign := func(s *Signer) *Message {
+		r, _ := http.NewRequest("GET", "https://example.com:443/items?page=2", nil)
+		m := RequestMessage(r)
+		if err := s.Sign(m); err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		return m
+	}
+	signer := &Signer{Key: keys[0].PrivateKey, KeyID: "alice", Expires: time.Hour, Now: func() time.Time { return now }}
+	verifier := &Verifier{Keys: Keys{"alice": keys[0].PublicKey}, MaxAge: time.Minute, Now: func() time.Time { return now }}
+
+	t.Run("Valid", func(t *testing.T) {
+		result, err := verifier.Verify(sign(signer))
+		if err != nil {
+			t.Fatalf("Unexpected error from Verify: %v", err)
+		}
+		if result.KeyID != "alice" || result.Label != DefaultLabel || len(result.Components) != 4 {
+			t.Fatalf("Verify() = %+v", result)
+		}
+	})
+
+	t.Run("Second signature", func(t *testing.T) {
+		m := sign(signer)
+		bob := &Signer{Key: keys[1].PrivateKey, KeyID: "bob", Label: "proxy", Now: signer.Now}
+		if err := bob.Sign(m); err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+
+		v := *verifier
+		v.Keys = Keys{"bob": keys[1].PublicKey}
+		v.Label = "proxy"
+		if _, err := v.Verify(m); err != nil {
+			t.Fatalf("Unexpected error from Verify: %v", err)
+		}
+	})
+
+	failures := map[string]func(m *Message, v *Verifier){
+		"Tampered path":    func(m *Message, v *Verifier) { m.URL.Path = "/admin" },
+		"Unknown key":      func(m *Message, v *Verifier) { v.Keys = Keys{"bob": keys[1].PublicKey} },
+		"Wrong key":        func(m *Message, v *Verifier) { v.Keys = Keys{"alice": keys[1].PublicKey} },
+		"Too old":          func(m *Message, v *Verifier) { v.Now = func() time.Time { return now.Add(2 * time.Minute) } },
+		"Not covered":      func(m *Message, v *Verifier) { v.Required = []string{"@method", "authorization"} },
+		"Unsigned":         func(m *Message, v *Verifier) { m.Header.Del("Signature-Input") },
+		"Bad signature":    func(m *Message, v *Verifier) { m.Header.Set("Signature", "sig1=:AAAA:") },
+		"Other label only": func(m *Message, v *Verifier) { v.Label = "sig2" },
+	}
+	for name, tamper := range failures {
+		t.Run(name, func(t *testing.T) {
+			m := sign(signer)
+			v := *verifier
+			tamper(m, &v)
+			if _, err := v.Verify(m); err == nil {
+				t.Fatalf("Verify succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("httpsig"), 2)
+	client := NewSigner(keys[0].PrivateKey, "client")
+	server := NewSigner(keys[1].PrivateKey, "server")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, _ := FromContext(r.Context())
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(result.KeyID + ":" + string(body)))
+	})
+	ts := httptest.NewServer(SignResponses(server, Middleware(NewVerifier(Keys{"client": keys[0].PublicKey}), handler)))
+	defer ts.Close()
+
+	t.Run("Signed both ways", func(t *testing.T) {
+		c := &http.Client{Transport: &Transport{Signer: client, Verifier: NewVerifier(Keys{"server": keys[1].PublicKey})}}
+		resp, err := c.Post(ts.URL+"/echo", "text/plain", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatalf("Unexpected error from Post: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(body) != "client:hello" {
+			t.Fatalf("Post() = %d %s, want 200 client:hello", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("Unsigned request", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/echo")
+		if err != nil {
+			t.Fatalf("Unexpected error from Get: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("Accept-Signature") == "" {
+			t.Fatalf("Get() = %d, want 401 with Accept-Signature", resp.StatusCode)
+		}
+	})
+
+	t.Run("Body swapped after signing", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", ts.URL+"/echo", strings.NewReader("hello"))
+		r.Header.Set("Content-Digest", ContentDigest([]byte("hello")))
+		if err := client.Sign(RequestMessage(r)); err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader([]byte("goodbye")))
+		r.ContentLength = 7
+
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("Unexpected error from Do: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Do() = %d, want 400", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package httpsig
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// Transport signs every request it sends, adding a Content-Digest for any
+// body, and with a Verifier checks the signature on every response
+type Transport struct {
+	Signer   *Signer
+	Verifier *Verifier
+	Base     http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.Header.Set("Content-Digest", ContentDigest(body))
+	}
+
+	if err := t.Signer.Sign(RequestMessage(req)); err != nil {
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || t.Verifier == nil {
+		return resp, err
+	}
+
+	body, err := readBody(resp.Header, resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if _, err := requireDigest(t.Verifier, true, body).Verify(ResponseMessage(resp.StatusCode, resp.Header, req)); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// readBody reads a body and checks it against the Content-Digest header,
+// which a non-empty body must have
+func readBody(header http.Header, r io.Reader) ([]byte, error) {
+	if r == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > 0 || header.Get("Content-Digest") != "" {
+		if err := VerifyContentDigest(header.Get("Content-Digest"), body); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// requireDigest makes a signature over a message with a body cover its
+// Content-Digest as well
+func requireDigest(v *Verifier, response bool, body []byte) *Verifier {
+	if len(body) == 0 {
+		return v
+	}
+	verifier := *v
+	required := verifier.Required
+	if required == nil {
+		required = DefaultRequestComponents
+		if response {
+			required = DefaultResponseComponents
+		}
+	}
+	verifier.Required = append(append([]string{}, required...), "content-digest")
+	return &verifier
+}
+
+type contextKey struct{}
+
+// FromContext returns the verified signature of the request being handled
+func FromContext(ctx context.Context) (*Result, bool) {
+	result, ok := ctx.Value(contextKey{}).(*Result)
+	return result, ok
+}
+
+// Middleware only passes on requests with a valid signature, and a body
+// matching its signed Content-Digest. Handlers get the signature with
+// FromContext, for example to authorize the key id.
+func Middleware(v *Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(r.Header, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		result, err := requireDigest(v, false, body).Verify(RequestMessage(r))
+		if err != nil {
+			w.Header().Set("Accept-Signature", DefaultLabel+"=("+quote("@method")+" "+quote("@authority")+" "+quote("@path")+" "+quote("@query")+");alg="+quote(Algorithm))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, result)))
+	})
+}
+
+// responseBuffer holds a response until it can be signed
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *responseBuffer) Write(data []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(data)
+}
+
+// SignResponses signs every response of the handler, which is buffered to
+// add its Content-Digest first
+func SignResponses(s *Signer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := &responseBuffer{header: http.Header{}}
+		next.ServeHTTP(buf, r)
+		if buf.status == 0 {
+			buf.status = http.StatusOK
+		}
+
+		if buf.body.Len() > 0 {
+			buf.header.Set("Content-Digest", ContentDigest(buf.body.Bytes()))
+		}
+		if err := s.Sign(ResponseMessage(buf.status, buf.header, r)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for name, values := range buf.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(buf.status)
+		w.Write(buf.body.Bytes())
+	})
+}
@@ -0,0 +1,266 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//
+// Just enough of RFC 8941 structured fields to read Signature-Input and
+// Signature: dictionaries whose members are inner lists or byte sequences,
+// with parameters. Members keep their raw text, since the signature base has
+// to use the signature parameters exactly as they were sent.
+//
+
+// param is a parameter of an item or inner list, its value a string,
+// integer, token, byte sequence or boolean
+type param struct {
+	Name  string
+	Value interface{}
+}
+
+// member is one entry of a dictionary
+type member struct {
+	Name   string
+	Raw    string
+	Items  []item
+	Bytes  []byte
+	Params []param
+}
+
+// item is a string in an inner list, such as a component identifier
+type item struct {
+	Value  string
+	Params []param
+}
+
+type sfParser struct {
+	s   string
+	pos int
+}
+
+func (p *sfParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("structured field at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *sfParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *sfParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *sfParser) skipSpaces() {
+	for !p.done() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func isLCAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *sfParser) key() (string, error) {
+	start := p.pos
+	if c := p.peek(); !isLCAlpha(c) && c != '*' {
+		return "", p.errorf("expected a key")
+	}
+	for !p.done() {
+		c := p.s[p.pos]
+		if !isLCAlpha(c) && !isDigit(c) && c != '_' && c != '-' && c != '.' && c != '*' {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *sfParser) params() ([]param, error) {
+	params := []param{}
+	for p.peek() == ';' {
+		p.pos++
+		p.skipSpaces()
+		name, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		var value interface{} = true
+		if p.peek() == '=' {
+			p.pos++
+			if value, err = p.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, param{Name: name, Value: value})
+	}
+	return params, nil
+}
+
+func (p *sfParser) bareItem() (interface{}, error) {
+	c := p.peek()
+	switch {
+	case c == '"':
+		return p.str()
+	case c == ':':
+		return p.byteSequence()
+	case c == '?':
+		p.pos++
+		switch p.peek() {
+		case '0':
+			p.pos++
+			return false, nil
+		case '1':
+			p.pos++
+			return true, nil
+		}
+		return nil, p.errorf("bad boolean")
+	case c == '-' || isDigit(c):
+		start := p.pos
+		p.pos++
+		for !p.done() && isDigit(p.s[p.pos]) {
+			p.pos++
+		}
+		n, err := strconv.ParseInt(p.s[start:p.pos], 10, 64)
+		if err != nil {
+			return nil, p.errorf("bad integer %q", p.s[start:p.pos])
+		}
+		return n, nil
+	case c == '*' || (c >= 'A' && c <= 'Z') || isLCAlpha(c):
+		start := p.pos
+		for !p.done() && !strings.ContainsRune(" \t;,()=\"", rune(p.s[p.pos])) {
+			p.pos++
+		}
+		return p.s[start:p.pos], nil
+	}
+	return nil, p.errorf("unexpected %q", c)
+}
+
+func (p *sfParser) str() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for !p.done() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			if p.done() || (p.s[p.pos] != '"' && p.s[p.pos] != '\\') {
+				return "", p.errorf("bad escape")
+			}
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", p.errorf("bad character in string")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *sfParser) byteSequence() ([]byte, error) {
+	p.pos++
+	end := strings.IndexByte(p.s[p.pos:], ':')
+	if end < 0 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+	data, err := base64.StdEncoding.DecodeString(p.s[p.pos : p.pos+end])
+	if err != nil {
+		return nil, p.errorf("bad byte sequence: %v", err)
+	}
+	p.pos += end + 1
+	return data, nil
+}
+
+func (p *sfParser) innerList() ([]item, error) {
+	p.pos++
+	items := []item{}
+	for {
+		p.skipSpaces()
+		if p.peek() == ')' {
+			p.pos++
+			return items, nil
+		}
+		if p.peek() != '"' {
+			return nil, p.errorf("inner list members must be strings")
+		}
+		value, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		params, err := p.params()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item{Value: value, Params: params})
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, p.errorf("expected a space or ) in inner list")
+		}
+	}
+}
+
+// parseDictionary reads every member of a dictionary, in order
+func parseDictionary(s string) ([]member, error) {
+	p := &sfParser{s: s}
+	members := []member{}
+	p.skipSpaces()
+	for !p.done() {
+		name, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != '=' {
+			return nil, p.errorf("member %s has no value", name)
+		}
+		p.pos++
+
+		m := member{Name: name}
+		start := p.pos
+		switch p.peek() {
+		case '(':
+			m.Items, err = p.innerList()
+		case ':':
+			m.Bytes, err = p.byteSequence()
+		default:
+			_, err = p.bareItem()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if m.Params, err = p.params(); err != nil {
+			return nil, err
+		}
+		m.Raw = s[start:p.pos]
+		members = append(members, m)
+
+		p.skipSpaces()
+		if p.done() {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, p.errorf("expected , between members")
+		}
+		p.pos++
+		p.skipSpaces()
+		if p.done() {
+			return nil, p.errorf("trailing ,")
+		}
+	}
+	return members, nil
+}
+
+// quote serializes a structured field string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,217 @@
+package httpsig
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// KeyResolver finds the public key for the keyid of a signature. alg is the
+// alg parameter, empty if the signer left it out.
+type KeyResolver interface {
+	ResolveKey(keyID, alg string) ([33]byte, error)
+}
+
+// KeyResolverFunc adapts a function to a KeyResolver
+type KeyResolverFunc func(keyID, alg string) ([33]byte, error)
+
+func (f KeyResolverFunc) ResolveKey(keyID, alg string) ([33]byte, error) {
+	return f(keyID, alg)
+}
+
+// Keys is a fixed set of public keys by key id
+type Keys map[string][33]byte
+
+func (k Keys) ResolveKey(keyID, alg string) ([33]byte, error) {
+	publickey, ok := k[keyID]
+	if !ok {
+		return publickey, fmt.Errorf("unknown key id %q", keyID)
+	}
+	return publickey, nil
+}
+
+// Verifier checks the signatures on messages. Required are the components a
+// signature must cover, the defaults for the kind of message if nil. Label
+// and Tag, when set, pick which signatures are considered. Signatures older
+// than a non-zero MaxAge are rejected, as are expired ones.
+type Verifier struct {
+	Keys     KeyResolver
+	Label    string
+	Tag      string
+	Required []string
+	MaxAge   time.Duration
+	Now      func() time.Time
+}
+
+// NewVerifier resolves keys with the resolver and accepts signatures up to
+// five minutes old
+func NewVerifier(keys KeyResolver) *Verifier {
+	return &Verifier{Keys: keys, MaxAge: 5 * time.Minute, Now: time.Now}
+}
+
+// Result is a verified signature
+type Result struct {
+	Label      string
+	KeyID      string
+	PublicKey  [33]byte
+	Components []string
+	Created    time.Time
+	Expires    time.Time
+	Tag        string
+}
+
+// Verify checks the first signature on the message that Label and Tag
+// select and that covers the required components
+func (v *Verifier) Verify(m *Message) (*Result, error) {
+	inputs, err := parseDictionary(m.Header.Get("Signature-Input"))
+	if err != nil {
+		return nil, fmt.Errorf("Signature-Input: %v", err)
+	}
+	signatures, err := parseDictionary(m.Header.Get("Signature"))
+	if err != nil {
+		return nil, fmt.Errorf("Signature: %v", err)
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("message is not signed")
+	}
+
+	required := v.Required
+	if required == nil {
+		required = DefaultRequestComponents
+		if m.isResponse() {
+			required = DefaultResponseComponents
+		}
+	}
+
+	var lastErr error
+	for _, input := range inputs {
+		if v.Label != "" && input.Name != v.Label {
+			continue
+		}
+		result, err := v.verify(m, input, signatures, required)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		return nil, fmt.Errorf("no signature labelled %s", v.Label)
+	}
+	return nil, lastErr
+}
+
+func (v *Verifier) verify(m *Message, input member, signatures []member, required []string) (*Result, error) {
+	if input.Items == nil {
+		return nil, fmt.Errorf("signature %s: input is not an inner list", input.Name)
+	}
+
+	result := &Result{Label: input.Name}
+	var alg string
+	var created, expires int64
+	for _, p := range input.Params {
+		var ok bool
+		switch p.Name {
+		case "created":
+			created, ok = p.Value.(int64)
+		case "expires":
+			expires, ok = p.Value.(int64)
+		case "keyid":
+			result.KeyID, ok = p.Value.(string)
+		case "alg":
+			alg, ok = p.Value.(string)
+		case "tag":
+			result.Tag, ok = p.Value.(string)
+		case "nonce":
+			_, ok = p.Value.(string)
+		default:
+			ok = true
+		}
+		if !ok {
+			return nil, fmt.Errorf("signature %s: parameter %s has the wrong type", input.Name, p.Name)
+		}
+	}
+	if v.Tag != "" && result.Tag != v.Tag {
+		return nil, fmt.Errorf("signature %s has tag %q, want %q", input.Name, result.Tag, v.Tag)
+	}
+	if alg != "" && alg != Algorithm {
+		return nil, fmt.Errorf("signature %s: algorithm %s is not supported", input.Name, alg)
+	}
+	if result.KeyID == "" {
+		return nil, fmt.Errorf("signature %s has no keyid", input.Name)
+	}
+
+	components := []component{}
+	covered := map[string]bool{}
+	for _, it := range input.Items {
+		c, err := componentFromItem(it)
+		if err != nil {
+			return nil, fmt.Errorf("signature %s: %v", input.Name, err)
+		}
+		components = append(components, c)
+		covered[c.String()] = true
+		result.Components = append(result.Components, c.String())
+	}
+	for _, id := range required {
+		c, err := parseComponent(id)
+		if err != nil {
+			return nil, err
+		}
+		if !covered[c.String()] {
+			return nil, fmt.Errorf("signature %s does not cover %s", input.Name, c)
+		}
+	}
+
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	t := now()
+	if created != 0 {
+		result.Created = time.Unix(created, 0)
+		if result.Created.After(t.Add(MaxClockSkew)) {
+			return nil, fmt.Errorf("signature %s was created in the future", input.Name)
+		}
+	}
+	if v.MaxAge != 0 && (created == 0 || t.Sub(result.Created) > v.MaxAge) {
+		return nil, fmt.Errorf("signature %s is older than %s", input.Name, v.MaxAge)
+	}
+	if expires != 0 {
+		result.Expires = time.Unix(expires, 0)
+		if t.After(result.Expires) {
+			return nil, fmt.Errorf("signature %s expired at %s", input.Name, result.Expires)
+		}
+	}
+
+	var sig []byte
+	for _, s := range signatures {
+		if s.Name == input.Name {
+			sig = s.Bytes
+		}
+	}
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("signature %s is missing or not 64 bytes", input.Name)
+	}
+
+	if v.Keys == nil {
+		return nil, fmt.Errorf("no key resolver")
+	}
+	publickey, err := v.Keys.ResolveKey(result.KeyID, alg)
+	if err != nil {
+		return nil, fmt.Errorf("signature %s: %v", input.Name, err)
+	}
+	result.PublicKey = publickey
+
+	base, err := signatureBase(m, components, input.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("signature %s: %v", input.Name, err)
+	}
+	var signature [64]byte
+	copy(signature[:], sig)
+	ok, err := schnorr.Verify(publickey, sha256.Sum256(base), signature)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("signature %s does not verify", input.Name)
+	}
+	return result, nil
+}
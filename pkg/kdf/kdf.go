@@ -0,0 +1,142 @@
+package kdf
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"math/bits"
+)
+
+//
+// Password based key derivation for key files written by other tools:
+// PBKDF2 from RFC 8018 and scrypt from RFC 7914
+//
+
+// PBKDF2 derives keyLen bytes with HMAC over the hash h
+func PBKDF2(h func() hash.Hash, passphrase, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(h, passphrase)
+	size := prf.Size()
+
+	key := make([]byte, 0, (keyLen+size-1)/size*size)
+	u := make([]byte, size)
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u = prf.Sum(u[:0])
+
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// Scrypt derives keyLen bytes with cost N, which must be a power of two
+// above 1, block size r and parallelism p
+func Scrypt(passphrase, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+	if N <= 1 || N&(N-1) != 0 {
+		return nil, fmt.Errorf("scrypt N must be a power of two above 1, got %d", N)
+	}
+	if r < 1 || p < 1 || uint64(r)*uint64(p) >= 1<<30 || r > (1<<31-1)/128/p || r > (1<<31-1)/256 || N > (1<<31-1)/128/r {
+		return nil, fmt.Errorf("scrypt parameters N=%d r=%d p=%d are too large", N, r, p)
+	}
+
+	b := PBKDF2(sha256.New, passphrase, salt, 1, p*128*r)
+	x := make([]uint32, 32*r)
+	v := make([]uint32, 32*r*N)
+	for i := 0; i < p; i++ {
+		romix(b[i*128*r:(i+1)*128*r], x, v, N, r)
+	}
+	return PBKDF2(sha256.New, passphrase, b, 1, keyLen), nil
+}
+
+// romix is scryptROMix on block b, with x and v as scratch space
+func romix(b []byte, x, v []uint32, N, r int) {
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+
+	words := 32 * r
+	for i := 0; i < N; i++ {
+		copy(v[i*words:], x)
+		blockMix(x, r)
+	}
+	for i := 0; i < N; i++ {
+		j := int(x[words-16] & uint32(N-1))
+		for k := range x {
+			x[k] ^= v[j*words+k]
+		}
+		blockMix(x, r)
+	}
+
+	for i, w := range x {
+		binary.LittleEndian.PutUint32(b[i*4:], w)
+	}
+}
+
+// blockMix is scryptBlockMix with Salsa20/8 on the 2r 64 byte blocks of b
+func blockMix(b []uint32, r int) {
+	y := make([]uint32, len(b))
+	var x [16]uint32
+	copy(x[:], b[len(b)-16:])
+	for i := 0; i < 2*r; i++ {
+		for j := range x {
+			x[j] ^= b[i*16+j]
+		}
+		salsa208(&x)
+		// even blocks go to the first half, odd ones to the second
+		copy(y[(i/2+(i%2)*r)*16:], x[:])
+	}
+	copy(b, y)
+}
+
+func salsa208(b *[16]uint32) {
+	x := *b
+	for i := 0; i < 8; i += 2 {
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+	for i := range b {
+		b[i] += x[i]
+	}
+}
@@ -0,0 +1,42 @@
+package kdf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914 section 11
+	observed := PBKDF2(sha256.New, []byte("passwd"), []byte("salt"), 1, 64)
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if hex.EncodeToString(observed) != expected {
+		t.Fatalf("PBKDF2() = %x, want %s", observed, expected)
+	}
+}
+
+func TestScrypt(t *testing.T) {
+	// RFC 7914 section 12
+	testCases := []struct {
+		passphrase, salt string
+		N, r, p          int
+		expected         string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	}
+
+	for _, tc := range testCases {
+		observed, err := Scrypt([]byte(tc.passphrase), []byte(tc.salt), tc.N, tc.r, tc.p, 64)
+		if err != nil {
+			t.Fatalf("Unexpected error from Scrypt: %v", err)
+		}
+		if hex.EncodeToString(observed) != tc.expected {
+			t.Fatalf("Scrypt(%q, %q, %d, %d, %d) = %x, want %s", tc.passphrase, tc.salt, tc.N, tc.r, tc.p, observed, tc.expected)
+		}
+	}
+
+	if _, err := Scrypt(nil, nil, 1000, 1, 1, 32); err == nil {
+		t.Fatalf("Scrypt with N not a power of two succeeded, want error")
+	}
+}
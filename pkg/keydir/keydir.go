@@ -0,0 +1,204 @@
+package keydir
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Key directories after OpenPGP's Web Key Directory: the key for
+// alice@example.com is published at
+//
+//	https://example.com/.well-known/schnorr-go/keys/<sha256 of "alice">
+//
+// as an envelope the key signs itself, binding it to the address. Anyone
+// able to serve files on the domain vouches for its keys, which is all the
+// PKI a small team needs; pinning the fingerprint the first time a key is
+// seen catches it being swapped later.
+//
+
+// Prefix is the well-known path keys are published under
+const Prefix = "/.well-known/schnorr-go/keys/"
+
+// PayloadType is the envelope payload type of a published key
+const PayloadType = "application/vnd.schnorr-go.key+json"
+
+// ErrPinMismatch is returned when a key is not the one pinned for its
+// address
+var ErrPinMismatch = errors.New("key does not match the pinned fingerprint")
+
+// Record is what a published key says
+type Record struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"publicKey"`
+}
+
+// Fingerprint is the hex sha256 of the compressed public key
+func Fingerprint(publickey [33]byte) string {
+	sum := sha256.Sum256(publickey[:])
+	return hex.EncodeToString(sum[:])
+}
+
+// split returns the lowercased local part and domain of the address
+func split(address string) (string, string, error) {
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || at == len(address)-1 || strings.ContainsAny(address, "/?# ") {
+		return "", "", fmt.Errorf("%q is not an address of the form name@domain", address)
+	}
+	return strings.ToLower(address[:at]), strings.ToLower(address[at+1:]), nil
+}
+
+// Path is where the address's key lives under the well-known prefix
+func Path(address string) (string, error) {
+	local, _, err := split(address)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(local))
+	return Prefix + hex.EncodeToString(sum[:]), nil
+}
+
+// URL is the https URL the address's key is published at
+func URL(address string) (string, error) {
+	_, domain, err := split(address)
+	if err != nil {
+		return "", err
+	}
+	path, err := Path(address)
+	if err != nil {
+		return "", err
+	}
+	return "https://" + domain + path, nil
+}
+
+// Publish signs the record binding the private key's public key to the
+// address
+func Publish(privatekey *big.Int, address string) (*envelope.Envelope, error) {
+	if _, _, err := split(address); err != nil {
+		return nil, err
+	}
+	publickey, err := schnorr.ScalarBaseMult(privatekey).PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(Record{Address: address, PublicKey: hex.EncodeToString(publickey[:])})
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Sign(privatekey, PayloadType, payload)
+}
+
+// WriteDir writes the published key under root, laid out so root can be
+// served as the domain's document root
+func WriteDir(root string, e *envelope.Envelope) (string, error) {
+	record := new(Record)
+	if err := json.Unmarshal(e.Payload, record); err != nil {
+		return "", err
+	}
+	path, err := Path(record.Address)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	file := filepath.Join(root, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	return file, os.WriteFile(file, data, 0644)
+}
+
+// Parse verifies a published key is self-signed and for the address, and
+// returns the key
+func Parse(data []byte, address string) ([33]byte, error) {
+	var publickey [33]byte
+	e := new(envelope.Envelope)
+	if err := json.Unmarshal(data, e); err != nil {
+		return publickey, fmt.Errorf("published key is not valid json: %v", err)
+	}
+	if e.PayloadType != PayloadType {
+		return publickey, fmt.Errorf("not a published key: payload type %q", e.PayloadType)
+	}
+	publickey, err := e.Key()
+	if err != nil {
+		return publickey, err
+	}
+	if err := envelope.Verify(e, publickey); err != nil {
+		return publickey, err
+	}
+
+	record := new(Record)
+	if err := json.Unmarshal(e.Payload, record); err != nil {
+		return publickey, fmt.Errorf("published key is not valid json: %v", err)
+	}
+	if !strings.EqualFold(record.Address, address) {
+		return publickey, fmt.Errorf("key is published for %s, not %s", record.Address, address)
+	}
+	if record.PublicKey != hex.EncodeToString(publickey[:]) {
+		return publickey, fmt.Errorf("published key does not match the key signing it")
+	}
+	return publickey, nil
+}
+
+// Client fetches published keys
+type Client struct {
+	HTTP *http.Client
+	// BaseURL replaces https://<domain> when set, for directories served
+	// somewhere other than the address's domain
+	BaseURL string
+}
+
+func NewClient() *Client {
+	return &Client{HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Fetch gets and verifies the key published for the address
+func (c *Client) Fetch(ctx context.Context, address string) ([33]byte, error) {
+	url, err := URL(address)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	if c.BaseURL != "" {
+		path, _ := Path(address)
+		url = strings.TrimSuffix(c.BaseURL, "/") + path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return [33]byte{}, fmt.Errorf("no key published for %s", address)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return [33]byte{}, fmt.Errorf("fetching key for %s: %s", address, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return [33]byte{}, err
+	}
+	return Parse(data, address)
+}
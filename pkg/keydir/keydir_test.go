@@ -0,0 +1,118 @@
+package keydir
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestKeyDirectory(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("keydir"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	root := t.TempDir()
+	for i, address := range []string{"alice@example.com", "bob@example.com"} {
+		e, err := Publish(keys[i].PrivateKey, address)
+		if err != nil {
+			t.Fatalf("Unexpected error from Publish: %v", err)
+		}
+		if _, err := WriteDir(root, e); err != nil {
+			t.Fatalf("Unexpected error from WriteDir: %v", err)
+		}
+	}
+	server := httptest.NewServer(http.FileServer(http.Dir(root)))
+	defer server.Close()
+	c := NewClient()
+	c.BaseURL = server.URL
+
+	t.Run("fetch", func(t *testing.T) {
+		// when
+		publickey, err := c.Fetch(context.Background(), "Alice@Example.com")
+
+		// then
+		if err != nil {
+			t.Fatalf("Unexpected error from Fetch: %v", err)
+		}
+		if publickey != keys[0].PublicKey {
+			t.Fatalf("Fetch() = %x, want %x", publickey, keys[0].PublicKey)
+		}
+	})
+
+	t.Run("unpublished", func(t *testing.T) {
+		// when
+		_, err := c.Fetch(context.Background(), "carol@example.com")
+
+		// then
+		if err == nil {
+			t.Fatalf("Fetch() succeeded for an unpublished address")
+		}
+	})
+
+	t.Run("pins", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "pins.json")
+		pins, err := LoadPins(path)
+		if err != nil {
+			t.Fatalf("Unexpected error from LoadPins: %v", err)
+		}
+
+		// when
+		added, err := pins.Check("alice@example.com", keys[0].PublicKey)
+		if err != nil {
+			t.Fatalf("Unexpected error from Check: %v", err)
+		}
+		if err := pins.Save(); err != nil {
+			t.Fatalf("Unexpected error from Save: %v", err)
+		}
+		reloaded, err := LoadPins(path)
+		if err != nil {
+			t.Fatalf("Unexpected error from LoadPins: %v", err)
+		}
+		_, swapped := reloaded.Check("ALICE@example.com", keys[1].PublicKey)
+
+		// then
+		if !added {
+			t.Fatalf("Check() = false, want the first key pinned")
+		}
+		if fp, _ := reloaded.Fingerprint("alice@example.com"); fp != Fingerprint(keys[0].PublicKey) {
+			t.Fatalf("Fingerprint() = %s, want %s", fp, Fingerprint(keys[0].PublicKey))
+		}
+		if !errors.Is(swapped, ErrPinMismatch) {
+			t.Fatalf("Check() error = %v, want %v", swapped, ErrPinMismatch)
+		}
+	})
+}
+
+func TestParseRejectsOtherAddress(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("keydir"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	root := t.TempDir()
+	e, err := Publish(keys[0].PrivateKey, "mallory@example.com")
+	if err != nil {
+		t.Fatalf("Unexpected error from Publish: %v", err)
+	}
+	file, err := WriteDir(root, e)
+	if err != nil {
+		t.Fatalf("Unexpected error from WriteDir: %v", err)
+	}
+
+	// when
+	data, _ := os.ReadFile(file)
+	_, err = Parse(data, "alice@example.com")
+
+	// then
+	if err == nil {
+		t.Fatalf("Parse() accepted a key published for another address")
+	}
+}
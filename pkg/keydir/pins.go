@@ -0,0 +1,73 @@
+package keydir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Pins remembers the fingerprint of each address's key, trusting a key the
+// first time it is seen and refusing a different one after. It is kept in
+// a json file of address to fingerprint.
+type Pins struct {
+	path string
+
+	mu   sync.Mutex
+	pins map[string]string
+}
+
+// LoadPins reads the pins file, which need not exist yet
+func LoadPins(path string) (*Pins, error) {
+	p := &Pins{path: path, pins: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &p.pins); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// Fingerprint returns the pinned fingerprint of the address, if any
+func (p *Pins) Fingerprint(address string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fp, ok := p.pins[strings.ToLower(address)]
+	return fp, ok
+}
+
+// Check refuses a key that is not the pinned one, and pins it if the address
+// has none yet. It reports whether a new pin was made, which Save persists.
+func (p *Pins) Check(address string, publickey [33]byte) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	address = strings.ToLower(address)
+	fp := Fingerprint(publickey)
+	if pinned, ok := p.pins[address]; ok {
+		if pinned != fp {
+			return false, fmt.Errorf("%w: %s is pinned to %s, got %s", ErrPinMismatch, address, pinned, fp)
+		}
+		return false, nil
+	}
+	p.pins[address] = fp
+	return true, nil
+}
+
+// Save writes the pins back to their file
+func (p *Pins) Save() error {
+	p.mu.Lock()
+	data, err := json.MarshalIndent(p.pins, "", "  ")
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.path, data, 0600)
+}
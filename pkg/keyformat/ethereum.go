@@ -0,0 +1,237 @@
+package keyformat
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/kdf"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// https://ethereum.org/en/developers/docs/data-structures-and-encoding/web3-secret-storage/
+//
+
+// maxScryptMemory bounds the memory a keystore can make scrypt use, 128*N*r
+// bytes, at 1 GiB
+const maxScryptMemory = 1 << 30
+
+// EthereumKeystore is a version 3 keystore file. Older geth versions wrote
+// the crypto section as "Crypto".
+type EthereumKeystore struct {
+	Version int             `json:"version"`
+	ID      string          `json:"id,omitempty"`
+	Address string          `json:"address,omitempty"`
+	Crypto  EthereumCrypto  `json:"crypto"`
+	Legacy  *EthereumCrypto `json:"Crypto,omitempty"`
+}
+
+// EthereumCrypto is the encrypted key and how to decrypt it
+type EthereumCrypto struct {
+	Cipher       string `json:"cipher"`
+	CipherText   string `json:"ciphertext"`
+	CipherParams struct {
+		IV string `json:"iv"`
+	} `json:"cipherparams"`
+	KDF       string          `json:"kdf"`
+	KDFParams json.RawMessage `json:"kdfparams"`
+	MAC       string          `json:"mac"`
+}
+
+// derive runs the key derivation the keystore names
+func (c *EthereumCrypto) derive(passphrase []byte) ([]byte, error) {
+	params := struct {
+		DKLen int    `json:"dklen"`
+		Salt  string `json:"salt"`
+		N     int    `json:"n"`
+		R     int    `json:"r"`
+		P     int    `json:"p"`
+		C     int    `json:"c"`
+		PRF   string `json:"prf"`
+	}{}
+	if err := json.Unmarshal(c.KDFParams, &params); err != nil {
+		return nil, fmt.Errorf("keystore kdfparams: %v", err)
+	}
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("keystore salt is not hex")
+	}
+	if params.DKLen < 32 {
+		return nil, fmt.Errorf("keystore dklen %d is below 32", params.DKLen)
+	}
+
+	switch c.KDF {
+	case "scrypt":
+		if params.R < 1 || params.N > maxScryptMemory/128/params.R {
+			return nil, fmt.Errorf("keystore scrypt parameters n=%d r=%d need too much memory", params.N, params.R)
+		}
+		return kdf.Scrypt(passphrase, salt, params.N, params.R, params.P, params.DKLen)
+	case "pbkdf2":
+		if params.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("keystore pbkdf2 prf %q is not supported", params.PRF)
+		}
+		if params.C < 1 {
+			return nil, fmt.Errorf("keystore pbkdf2 iteration count %d is invalid", params.C)
+		}
+		return kdf.PBKDF2(sha256.New, passphrase, salt, params.C, params.DKLen), nil
+	}
+	return nil, fmt.Errorf("keystore kdf %q is not supported", c.KDF)
+}
+
+// ParseEthereumKeystore imports the key from a version 3 keystore file,
+// checking the MAC, which catches a wrong passphrase, and the address if
+// the file has one
+func ParseEthereumKeystore(data, passphrase []byte) (*Key, error) {
+	var ks EthereumKeystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("keystore: %v", err)
+	}
+	if ks.Version != 3 {
+		return nil, fmt.Errorf("keystore version %d is not supported, want 3", ks.Version)
+	}
+	c := &ks.Crypto
+	if c.Cipher == "" && ks.Legacy != nil {
+		c = ks.Legacy
+	}
+	if c.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("keystore cipher %q is not supported", c.Cipher)
+	}
+
+	ciphertext, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("keystore ciphertext is not hex")
+	}
+	iv, err := hex.DecodeString(c.CipherParams.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("keystore iv is not %d bytes of hex", aes.BlockSize)
+	}
+	mac, err := hex.DecodeString(c.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("keystore mac is not hex")
+	}
+
+	dk, err := c.derive(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	expected := keccak256(dk[16:32], ciphertext)
+	if subtle.ConstantTimeCompare(mac, expected[:]) != 1 {
+		return nil, fmt.Errorf("wrong passphrase or damaged keystore")
+	}
+
+	block, err := aes.NewCipher(dk[:16])
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(plaintext, ciphertext)
+	if len(plaintext) != 32 {
+		return nil, fmt.Errorf("keystore holds %d bytes, want a 32 byte private key", len(plaintext))
+	}
+
+	key, err := newKey(new(big.Int).SetBytes(plaintext), "ethereum")
+	if err != nil {
+		return nil, err
+	}
+	if key.Address, err = EthereumAddress(key.PublicKey); err != nil {
+		return nil, err
+	}
+	if ks.Address != "" && !strings.EqualFold(strings.TrimPrefix(ks.Address, "0x"), strings.TrimPrefix(key.Address, "0x")) {
+		return nil, fmt.Errorf("keystore is for address %s, the key's is %s", ks.Address, key.Address)
+	}
+	return key, nil
+}
+
+// EthereumParams are the costs of the kdf a keystore is written with:
+// scrypt's N, R and P, or pbkdf2's iteration count C
+type EthereumParams struct {
+	KDF     string
+	N, R, P int
+	C       int
+}
+
+var (
+	// EthereumStandard are geth's default scrypt costs, 256 MiB and a
+	// second or two
+	EthereumStandard = EthereumParams{KDF: "scrypt", N: 1 << 18, R: 8, P: 1}
+
+	// EthereumLight are geth's --lightkdf costs, 4 MiB
+	EthereumLight = EthereumParams{KDF: "scrypt", N: 1 << 12, R: 8, P: 6}
+
+	// EthereumPBKDF2 is pbkdf2 with hmac-sha256, for tools without scrypt
+	EthereumPBKDF2 = EthereumParams{KDF: "pbkdf2", C: 262144}
+)
+
+// EncryptEthereumKeystore writes the private key as a version 3 keystore
+// file under the passphrase, as geth does: aes-128-ctr under the first half
+// of the derived key, and a keccak256 MAC under the second
+func EncryptEthereumKeystore(privatekey *big.Int, passphrase []byte, params EthereumParams) ([]byte, error) {
+	key, err := newKey(privatekey, "ethereum")
+	if err != nil {
+		return nil, err
+	}
+	address, err := EthereumAddress(key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	random := make([]byte, 32+aes.BlockSize+16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	salt, iv, id := random[:32], random[32:32+aes.BlockSize], random[32+aes.BlockSize:]
+
+	var kdfParams interface{}
+	switch params.KDF {
+	case "scrypt":
+		kdfParams = map[string]interface{}{"dklen": 32, "n": params.N, "r": params.R, "p": params.P, "salt": hex.EncodeToString(salt)}
+	case "pbkdf2":
+		kdfParams = map[string]interface{}{"dklen": 32, "c": params.C, "prf": "hmac-sha256", "salt": hex.EncodeToString(salt)}
+	default:
+		return nil, fmt.Errorf("keystore kdf %q is not supported, want scrypt or pbkdf2", params.KDF)
+	}
+	rawParams, err := json.Marshal(kdfParams)
+	if err != nil {
+		return nil, err
+	}
+	c := EthereumCrypto{Cipher: "aes-128-ctr", KDF: params.KDF, KDFParams: rawParams}
+	c.CipherParams.IV = hex.EncodeToString(iv)
+
+	dk, err := c.derive(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(dk[:16])
+	if err != nil {
+		return nil, err
+	}
+	plaintext := schnorr.GetBigIntBytesImmutable(key.PrivateKey)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCTR(block, iv).XORKeyStream(ciphertext, plaintext)
+	mac := keccak256(dk[16:32], ciphertext)
+	c.CipherText = hex.EncodeToString(ciphertext)
+	c.MAC = hex.EncodeToString(mac[:])
+
+	// a random, version 4, uuid
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	ks := EthereumKeystore{
+		Version: 3,
+		ID:      fmt.Sprintf("%x-%x-%x-%x-%x", id[:4], id[4:6], id[6:8], id[8:10], id[10:]),
+		Address: strings.TrimPrefix(address, "0x"),
+		Crypto:  c,
+	}
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
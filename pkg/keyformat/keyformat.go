@@ -0,0 +1,100 @@
+package keyformat
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/keystore"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Importing secp256k1 private keys kept by other tools. ECDSA and Schnorr
+// keys on the curve are the same scalars, so an imported key signs as is;
+// what differs is how the public key is presented, which Key records.
+//
+
+// Key is an imported private key with what its source said about it
+type Key struct {
+	PrivateKey *big.Int
+	PublicKey  [33]byte
+
+	// Format is where the key came from: "wif", "ethereum", "keystore",
+	// "pkcs8", "sec1" or "hex"
+	Format string
+
+	// Network is "mainnet" or "testnet" for WIF keys
+	Network string
+
+	// Compressed is the WIF flag saying the wallet used the compressed
+	// public key. Keys here are always compressed, so addresses of keys
+	// imported without it will not match.
+	Compressed bool
+
+	// Address is the Ethereum address of the key, 0x and lowercase hex
+	Address string
+}
+
+func newKey(d *big.Int, format string) (*Key, error) {
+	if d.Sign() <= 0 || d.Cmp(schnorr.Curve.N) >= 0 {
+		return nil, fmt.Errorf("private key is not in the range 1..n-1")
+	}
+	publickey, err := schnorr.ScalarBaseMult(d).PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return &Key{PrivateKey: d, PublicKey: publickey, Format: format}, nil
+}
+
+// Import reads a private key in any format it recognizes: a keystore file
+// of this tool or Ethereum's, which need the passphrase, a PEM file, WIF, or
+// 64 hex characters
+func Import(data, passphrase []byte) (*Key, error) {
+	s := strings.TrimSpace(string(data))
+	if keystore.IsKeystore([]byte(s)) {
+		ks, err := keystore.Parse([]byte(s))
+		if err != nil {
+			return nil, err
+		}
+		d, err := ks.Decrypt(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return newKey(d, "keystore")
+	}
+	if strings.HasPrefix(s, "{") {
+		return ParseEthereumKeystore([]byte(s), passphrase)
+	}
+	if strings.HasPrefix(s, "-----BEGIN") {
+		return ParsePEMPrivateKey([]byte(s))
+	}
+	if len(s) == 64 {
+		if raw, err := hex.DecodeString(s); err == nil {
+			return newKey(new(big.Int).SetBytes(raw), "hex")
+		}
+	}
+	return ParseWIF(s)
+}
+
+// EvenY returns the private key, negated if its public key has an odd y.
+// X-only protocols such as BIP-340 sign with this key; it has the same
+// x-only public key as the imported one.
+func (k *Key) EvenY() *big.Int {
+	if k.PublicKey[0] == 0x02 {
+		return new(big.Int).Set(k.PrivateKey)
+	}
+	return new(big.Int).Sub(schnorr.Curve.N, k.PrivateKey)
+}
+
+// EthereumAddress is the last 20 bytes of the keccak256 of the
+// uncompressed public key, as 0x and lowercase hex
+func EthereumAddress(publickey [33]byte) (string, error) {
+	p, err := schnorr.ParsePoint(publickey)
+	if err != nil {
+		return "", err
+	}
+	h := keccak256(schnorr.GetBigIntBytesImmutable(p.X()), schnorr.GetBigIntBytesImmutable(p.Y()))
+	return fmt.Sprintf("0x%x", h[12:]), nil
+}
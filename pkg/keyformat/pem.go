@@ -0,0 +1,270 @@
+package keyformat
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// PEM key files as written by openssl and most other tools: PKCS#8
+// ("PRIVATE KEY") and SEC1 ("EC PRIVATE KEY") private keys, and PKIX
+// ("PUBLIC KEY") public keys. crypto/x509 refuses curves it doesn't
+// implement, secp256k1 among them, so the structures are read here.
+//
+// https://www.rfc-editor.org/rfc/rfc5915 (SEC1)
+// https://www.rfc-editor.org/rfc/rfc5958 (PKCS#8)
+// https://www.rfc-editor.org/rfc/rfc5480 (PKIX)
+//
+
+// PEM block types
+const (
+	pemPKCS8 = "PRIVATE KEY"
+	pemSEC1  = "EC PRIVATE KEY"
+	pemPKIX  = "PUBLIC KEY"
+)
+
+var (
+	oidECPublicKey = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSecp256k1   = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type pkcs8PrivateKey struct {
+	Version    int
+	Algorithm  algorithmIdentifier
+	PrivateKey []byte
+}
+
+type sec1PrivateKey struct {
+	Version    int
+	PrivateKey []byte
+	Curve      asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey  asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+type pkixPublicKey struct {
+	Algorithm algorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// ParsePEMPrivateKey reads a secp256k1 private key from a PKCS#8 or SEC1
+// PEM file. Encrypted PKCS#8 files are not supported.
+func ParsePEMPrivateKey(data []byte) (*Key, error) {
+	block, err := decodePEM(data, pemPKCS8, pemSEC1)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == pemSEC1 {
+		return parseSEC1(block.Bytes, nil, "sec1")
+	}
+
+	var p8 pkcs8PrivateKey
+	if rest, err := asn1.Unmarshal(block.Bytes, &p8); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("pkcs8: malformed private key")
+	}
+	if !p8.Algorithm.Algorithm.Equal(oidECPublicKey) {
+		return nil, fmt.Errorf("pkcs8: key is not an ec key, algorithm %v", p8.Algorithm.Algorithm)
+	}
+	curve, err := curveParameter(p8.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs8: %v", err)
+	}
+	return parseSEC1(p8.PrivateKey, curve, "pkcs8")
+}
+
+// parseSEC1 reads the ECPrivateKey structure, which PKCS#8 also wraps. The
+// curve is in the structure, the PKCS#8 algorithm or both.
+func parseSEC1(der []byte, curve asn1.ObjectIdentifier, format string) (*Key, error) {
+	var ec sec1PrivateKey
+	if rest, err := asn1.Unmarshal(der, &ec); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("%s: malformed private key", format)
+	}
+	if ec.Version != 1 {
+		return nil, fmt.Errorf("%s: unknown ec private key version %d", format, ec.Version)
+	}
+	if ec.Curve == nil {
+		ec.Curve = curve
+	}
+	if !ec.Curve.Equal(oidSecp256k1) {
+		return nil, fmt.Errorf("%s: key is on curve %v, want secp256k1", format, ec.Curve)
+	}
+	if len(ec.PrivateKey) > 32 {
+		return nil, fmt.Errorf("%s: private key is %d bytes, want 32", format, len(ec.PrivateKey))
+	}
+
+	key, err := newKey(new(big.Int).SetBytes(ec.PrivateKey), format)
+	if err != nil {
+		return nil, err
+	}
+	// a public key stored alongside must be the private key's
+	if len(ec.PublicKey.Bytes) > 0 {
+		publickey, err := parsePoint(ec.PublicKey.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", format, err)
+		}
+		if publickey != key.PublicKey {
+			return nil, fmt.Errorf("%s: public key does not match the private key", format)
+		}
+	}
+	key.Compressed = true
+	return key, nil
+}
+
+// ParsePEMPublicKey reads a secp256k1 public key from a PKIX PEM file,
+// with the point compressed or not
+func ParsePEMPublicKey(data []byte) ([33]byte, error) {
+	block, err := decodePEM(data, pemPKIX)
+	if err != nil {
+		return [33]byte{}, err
+	}
+
+	var pub pkixPublicKey
+	if rest, err := asn1.Unmarshal(block.Bytes, &pub); err != nil || len(rest) > 0 {
+		return [33]byte{}, fmt.Errorf("pkix: malformed public key")
+	}
+	if !pub.Algorithm.Algorithm.Equal(oidECPublicKey) {
+		return [33]byte{}, fmt.Errorf("pkix: key is not an ec key, algorithm %v", pub.Algorithm.Algorithm)
+	}
+	curve, err := curveParameter(pub.Algorithm)
+	if err != nil {
+		return [33]byte{}, fmt.Errorf("pkix: %v", err)
+	}
+	if !curve.Equal(oidSecp256k1) {
+		return [33]byte{}, fmt.Errorf("pkix: key is on curve %v, want secp256k1", curve)
+	}
+	publickey, err := parsePoint(pub.PublicKey.Bytes)
+	if err != nil {
+		return [33]byte{}, fmt.Errorf("pkix: %v", err)
+	}
+	return publickey, nil
+}
+
+// decodePEM returns the first block of one of the types, skipping others
+// such as the EC PARAMETERS openssl writes before a key
+func decodePEM(data []byte, types ...string) (*pem.Block, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no %s pem block found", types[0])
+		}
+		for _, t := range types {
+			if block.Type == t {
+				if _, encrypted := block.Headers["Proc-Type"]; encrypted {
+					return nil, fmt.Errorf("encrypted pem keys are not supported")
+				}
+				return block, nil
+			}
+		}
+	}
+}
+
+// curveParameter is the named curve of an ec algorithm identifier
+func curveParameter(alg algorithmIdentifier) (asn1.ObjectIdentifier, error) {
+	var curve asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &curve); err != nil {
+		return nil, fmt.Errorf("key does not name its curve")
+	}
+	return curve, nil
+}
+
+// parsePoint reads a SEC1 encoded point, compressed or uncompressed, and
+// returns it compressed
+func parsePoint(b []byte) ([33]byte, error) {
+	var compressed [33]byte
+	switch {
+	case len(b) == 33 && (b[0] == 2 || b[0] == 3):
+		copy(compressed[:], b)
+		if _, err := schnorr.ParsePoint(compressed); err != nil {
+			return compressed, err
+		}
+		return compressed, nil
+	case len(b) == 65 && b[0] == 4:
+		compressed[0] = 2 + b[64]&1
+		copy(compressed[1:], b[1:33])
+		p, err := schnorr.ParsePoint(compressed)
+		if err != nil {
+			return compressed, err
+		}
+		if !bytes.Equal(schnorr.GetBigIntBytesImmutable(p.Y()), b[33:]) {
+			return compressed, fmt.Errorf("point is not on the curve")
+		}
+		return compressed, nil
+	}
+	return compressed, fmt.Errorf("public key is not a sec1 encoded point")
+}
+
+// EncodePEMPrivateKey writes the private key as an unencrypted PKCS#8 PEM
+// file, with its public key, as openssl pkcs8 -topk8 -nocrypt does
+func EncodePEMPrivateKey(privatekey *big.Int) ([]byte, error) {
+	key, err := newKey(privatekey, "pkcs8")
+	if err != nil {
+		return nil, err
+	}
+	uncompressed, err := uncompressPoint(key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	d := make([]byte, 32)
+	privatekey.FillBytes(d)
+	ec, err := asn1.Marshal(sec1PrivateKey{
+		Version:    1,
+		PrivateKey: d,
+		PublicKey:  asn1.BitString{Bytes: uncompressed, BitLength: 8 * len(uncompressed)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	curve, err := asn1.Marshal(oidSecp256k1)
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(pkcs8PrivateKey{
+		Algorithm:  algorithmIdentifier{Algorithm: oidECPublicKey, Parameters: asn1.RawValue{FullBytes: curve}},
+		PrivateKey: ec,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPKCS8, Bytes: der}), nil
+}
+
+// EncodePEMPublicKey writes the public key as a PKIX PEM file, with the
+// point uncompressed as openssl ec -pubout does
+func EncodePEMPublicKey(publickey [33]byte) ([]byte, error) {
+	uncompressed, err := uncompressPoint(publickey)
+	if err != nil {
+		return nil, err
+	}
+	curve, err := asn1.Marshal(oidSecp256k1)
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(pkixPublicKey{
+		Algorithm: algorithmIdentifier{Algorithm: oidECPublicKey, Parameters: asn1.RawValue{FullBytes: curve}},
+		PublicKey: asn1.BitString{Bytes: uncompressed, BitLength: 8 * len(uncompressed)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPKIX, Bytes: der}), nil
+}
+
+// uncompressPoint returns the 65 byte SEC1 uncompressed encoding
+func uncompressPoint(publickey [33]byte) ([]byte, error) {
+	p, err := schnorr.ParsePoint(publickey)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{4}, schnorr.GetBigIntBytesImmutable(p.X())...), schnorr.GetBigIntBytesImmutable(p.Y())...), nil
+}
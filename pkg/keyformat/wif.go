@@ -0,0 +1,75 @@
+package keyformat
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/base58"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// WIF version bytes
+const (
+	wifMainnet = 0x80
+	wifTestnet = 0xef
+)
+
+// ParseWIF imports a Bitcoin Wallet Import Format private key
+func ParseWIF(s string) (*Key, error) {
+	data, err := base58.CheckDecode(s)
+	if err != nil {
+		return nil, fmt.Errorf("wif: %v", err)
+	}
+
+	var network string
+	switch {
+	case len(data) == 0:
+		return nil, fmt.Errorf("wif is empty")
+	case data[0] == wifMainnet:
+		network = "mainnet"
+	case data[0] == wifTestnet:
+		network = "testnet"
+	default:
+		return nil, fmt.Errorf("wif has unknown version byte %#x", data[0])
+	}
+
+	compressed := false
+	switch {
+	case len(data) == 34 && data[33] == 0x01:
+		compressed = true
+	case len(data) == 33:
+	default:
+		return nil, fmt.Errorf("wif has %d bytes, want 33 or 34 ending in 0x01", len(data))
+	}
+
+	key, err := newKey(new(big.Int).SetBytes(data[1:33]), "wif")
+	if err != nil {
+		return nil, err
+	}
+	key.Network = network
+	key.Compressed = compressed
+	return key, nil
+}
+
+// EncodeWIF exports a private key as WIF for "mainnet" or "testnet"
+func EncodeWIF(privatekey *big.Int, network string, compressed bool) (string, error) {
+	if privatekey.Sign() <= 0 || privatekey.Cmp(schnorr.Curve.N) >= 0 {
+		return "", fmt.Errorf("private key is not in the range 1..n-1")
+	}
+
+	var version byte
+	switch network {
+	case "mainnet":
+		version = wifMainnet
+	case "testnet":
+		version = wifTestnet
+	default:
+		return "", fmt.Errorf("unknown network %q", network)
+	}
+
+	data := append([]byte{version}, schnorr.GetBigIntBytesImmutable(privatekey)...)
+	if compressed {
+		data = append(data, 0x01)
+	}
+	return base58.CheckEncode(data), nil
+}
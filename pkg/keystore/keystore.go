@@ -0,0 +1,175 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/kdf"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Private keys encrypted under a passphrase for keeping on disk, so they're
+// never written, or typed into a shell, as plaintext hex. The key encrypting
+// key is scrypt of the passphrase, with no argon2id in the standard library,
+// and the private key is sealed with AES-256-GCM. The public key is left in
+// the clear so the file says whose key it is, and it and the scrypt
+// parameters are authenticated: changing any of them fails decryption.
+//
+
+// Version is the format written, and the only one read
+const Version = 1
+
+// Format names the files, telling them from other json keys
+const Format = "schnorr-go-keystore"
+
+// Params are the scrypt costs. Memory use is 128*N*R bytes.
+type Params struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+// DefaultParams take 128 MiB and most of a second
+var DefaultParams = Params{N: 1 << 17, R: 8, P: 1}
+
+// maxMemory bounds what a file can make scrypt use, at 1 GiB
+const maxMemory = 1 << 30
+
+// Keystore is an encrypted private key file. Binary fields are base64 in
+// json.
+type Keystore struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	PublicKey  string `json:"publicKey"`
+	KDF        string `json:"kdf"`
+	Params     Params `json:"kdfparams"`
+	Salt       []byte `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Encrypt seals the private key under the passphrase
+func Encrypt(privatekey *big.Int, passphrase []byte, params Params) (*Keystore, error) {
+	key, err := schnorr.NewPrivateKey(privatekey)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("keystore passphrase is empty")
+	}
+	ks := &Keystore{
+		Format:    Format,
+		Version:   Version,
+		PublicKey: key.PublicKey().String(),
+		KDF:       "scrypt",
+		Params:    params,
+		Salt:      make([]byte, 32),
+		Cipher:    "aes-256-gcm",
+	}
+	if _, err := rand.Read(ks.Salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := ks.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	ks.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(ks.Nonce); err != nil {
+		return nil, err
+	}
+	plaintext := key.Serialize()
+	ks.Ciphertext = aead.Seal(nil, ks.Nonce, plaintext[:], ks.additionalData())
+	return ks, nil
+}
+
+// Decrypt returns the private key, checking it's the one the file names
+func (ks *Keystore) Decrypt(passphrase []byte) (*big.Int, error) {
+	aead, err := ks.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(ks.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("keystore nonce must be %d bytes", aead.NonceSize())
+	}
+	plaintext, err := aead.Open(nil, ks.Nonce, ks.Ciphertext, ks.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or damaged keystore")
+	}
+
+	key, err := schnorr.ParsePrivateKeyHex(hex.EncodeToString(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	if key.PublicKey().String() != ks.PublicKey {
+		return nil, fmt.Errorf("keystore holds the key of %s, not %s", key.PublicKey(), ks.PublicKey)
+	}
+	return key.D(), nil
+}
+
+// Parse reads a keystore file
+func Parse(data []byte) (*Keystore, error) {
+	ks := new(Keystore)
+	if err := json.Unmarshal(data, ks); err != nil {
+		return nil, err
+	}
+	if ks.Format != Format {
+		return nil, fmt.Errorf("not a %s file", Format)
+	}
+	if ks.Version != Version {
+		return nil, fmt.Errorf("keystore is version %d, want %d", ks.Version, Version)
+	}
+	if ks.KDF != "scrypt" || ks.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("unsupported keystore kdf %q or cipher %q", ks.KDF, ks.Cipher)
+	}
+	if len(ks.Salt) == 0 || len(ks.Ciphertext) == 0 {
+		return nil, fmt.Errorf("keystore has no salt or ciphertext")
+	}
+	return ks, nil
+}
+
+// IsKeystore reports whether the data looks like a keystore file, for
+// telling it from other key formats before asking for a passphrase
+func IsKeystore(data []byte) bool {
+	header := struct {
+		Format string `json:"format"`
+	}{}
+	return json.Unmarshal(data, &header) == nil && header.Format == Format
+}
+
+// Marshal writes the keystore as indented json
+func (ks *Keystore) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// additionalData binds everything in the clear to the ciphertext
+func (ks *Keystore) additionalData() []byte {
+	return []byte(fmt.Sprintf("%s:%d:%s:%s:%d:%d:%d:%x:%s", ks.Format, ks.Version, ks.PublicKey, ks.KDF, ks.Params.N, ks.Params.R, ks.Params.P, ks.Salt, ks.Cipher))
+}
+
+func (ks *Keystore) aead(passphrase []byte) (cipher.AEAD, error) {
+	p := ks.Params
+	if p.R < 1 || p.P < 1 || p.P > 16 || p.N > maxMemory/128/p.R {
+		return nil, fmt.Errorf("keystore scrypt parameters n=%d r=%d p=%d are out of range", p.N, p.R, p.P)
+	}
+	dk, err := kdf.Scrypt(passphrase, ks.Salt, p.N, p.R, p.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(dk)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// testParams keep the tests fast
+var testParams = Params{N: 1 << 10, R: 8, P: 1}
+
+func TestEncryptDecrypt(t *testing.T) {
+	// given
+	keys, _ := schnorr.GenerateTestKeys([]byte("keystore"), 1)
+	passphrase := []byte("correct horse battery staple")
+
+	// when
+	ks, err := Encrypt(keys[0].PrivateKey, passphrase, testParams)
+	if err != nil {
+		t.Fatalf("Unexpected error from Encrypt: %v", err)
+	}
+	data, err := ks.Marshal()
+	if err != nil {
+		t.Fatalf("Unexpected error from Marshal: %v", err)
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	d, err := parsed.Decrypt(passphrase)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from Decrypt: %v", err)
+	}
+	if d.Cmp(keys[0].PrivateKey) != 0 {
+		t.Fatalf("Decrypt() = %x, want %x", d, keys[0].PrivateKey)
+	}
+	if !IsKeystore(data) {
+		t.Fatalf("IsKeystore() = false, want true")
+	}
+	if strings.Contains(string(data), keys[0].PrivateKey.Text(16)) {
+		t.Fatalf("keystore holds the private key in the clear")
+	}
+
+	t.Run("Wrong passphrase", func(t *testing.T) {
+		if _, err := parsed.Decrypt([]byte("wrong")); err == nil {
+			t.Fatalf("Decrypt with the wrong passphrase succeeded, want error")
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		others, _ := schnorr.GenerateTestKeys([]byte("other"), 1)
+		tampered := map[string]func(ks *Keystore){
+			"public key": func(ks *Keystore) { ks.PublicKey = hex.EncodeToString(others[0].PublicKey[:]) },
+			"params":     func(ks *Keystore) { ks.Params.N = 1 << 11 },
+			"ciphertext": func(ks *Keystore) { ks.Ciphertext[0] ^= 1 },
+		}
+		for name, tamper := range tampered {
+			copied, _ := Parse(data)
+			tamper(copied)
+			if _, err := copied.Decrypt(passphrase); err == nil {
+				t.Fatalf("Decrypt with tampered %s succeeded, want error", name)
+			}
+		}
+	})
+
+	t.Run("Costly params", func(t *testing.T) {
+		copied, _ := Parse(data)
+		copied.Params.N = 1 << 30
+		if _, err := copied.Decrypt(passphrase); err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Fatalf("Decrypt with n=2^30 = %v, want out of range", err)
+		}
+	})
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"version": 3, "crypto": {}}`,
+		`{"format": "schnorr-go-keystore", "version": 2}`,
+		`{"format": "schnorr-go-keystore", "version": 1, "kdf": "pbkdf2", "cipher": "aes-256-gcm"}`,
+		`not json`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Fatalf("Parse(%s) = nil error, want one", data)
+		}
+	}
+	if IsKeystore([]byte(`{"version": 3, "crypto": {}}`)) {
+		t.Fatalf("IsKeystore() of an Ethereum keystore = true, want false")
+	}
+}
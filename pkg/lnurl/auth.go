@@ -0,0 +1,202 @@
+package lnurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/ryohare/schnorr-go/pkg/bech32"
+	"github.com/ryohare/schnorr-go/pkg/hdkey"
+)
+
+//
+// https://github.com/lnurl/luds/blob/luds/04.md
+// https://github.com/lnurl/luds/blob/luds/05.md
+//
+
+// AuthRequest is a parsed LNURL-auth (tag=login) request
+type AuthRequest struct {
+	URL    *url.URL
+	Domain string
+	K1     [32]byte
+	Action string
+}
+
+// Decode turns an LNURL into the url it wraps. Bech32 encoded lnurls,
+// lightning: prefixed ones and plain https urls are all accepted.
+func Decode(lnurl string) (*url.URL, error) {
+	s := strings.TrimSpace(lnurl)
+	if len(s) > 10 && strings.EqualFold(s[:10], "lightning:") {
+		s = s[10:]
+	}
+
+	if strings.HasPrefix(strings.ToLower(s), "lnurl1") {
+		hrp, data, err := bech32.DecodeBytes(s)
+		if err != nil {
+			return nil, err
+		}
+		if hrp != "lnurl" {
+			return nil, fmt.Errorf("unexpected human readable part %q", hrp)
+		}
+		s = string(data)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// LUD-17 style keyauth:// urls are the same thing over https
+	if u.Scheme == "keyauth" {
+		u.Scheme = "https"
+	}
+
+	// plain http is only allowed for onion services
+	if u.Scheme != "https" && !(u.Scheme == "http" && strings.HasSuffix(u.Hostname(), ".onion")) {
+		return nil, fmt.Errorf("lnurl must use https, got %q", u.Scheme)
+	}
+
+	return u, nil
+}
+
+// Encode bech32 encodes a url as an LNURL
+func Encode(u string) (string, error) {
+	s, err := bech32.EncodeBytes("lnurl", []byte(u))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(s), nil
+}
+
+// ParseAuth decodes the lnurl and checks that it is a valid login request
+func ParseAuth(lnurl string) (*AuthRequest, error) {
+	u, err := Decode(lnurl)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	if q.Get("tag") != "login" {
+		return nil, fmt.Errorf("lnurl is not an auth request, tag is %q", q.Get("tag"))
+	}
+
+	k1, err := hex.DecodeString(q.Get("k1"))
+	if err != nil || len(k1) != 32 {
+		return nil, fmt.Errorf("k1 must be 32 hex encoded bytes")
+	}
+
+	action := q.Get("action")
+	switch action {
+	case "", "register", "login", "link", "auth":
+	default:
+		return nil, fmt.Errorf("unknown action %q", action)
+	}
+
+	req := &AuthRequest{URL: u, Domain: u.Hostname(), Action: action}
+	copy(req.K1[:], k1)
+
+	return req, nil
+}
+
+// HashingKey is the wallet's private key at m/138'/0, the key LUD-05 makes
+// each domain's derivation material with
+func HashingKey(root *hdkey.Key) ([]byte, error) {
+	if !root.IsPrivate() || root.Depth != 0 {
+		return nil, fmt.Errorf("linking keys are derived from the wallet's root xprv")
+	}
+	k, err := root.Derive("m/138'/0")
+	if err != nil {
+		return nil, err
+	}
+	return k.PrivateKey().FillBytes(make([]byte, 32)), nil
+}
+
+// LinkingPath is the path of the domain's linking key, m/138'/a/b/c/d with
+// a to d the first 16 bytes of HMAC-SHA256(hashingKey, domain) read as four
+// big endian uint32
+func LinkingPath(hashingKey []byte, domain string) []uint32 {
+	mac := hmac.New(sha256.New, hashingKey)
+	mac.Write([]byte(strings.ToLower(domain)))
+	material := mac.Sum(nil)
+
+	path := []uint32{138 + hdkey.Hardened}
+	for i := 0; i < 16; i += 4 {
+		path = append(path, binary.BigEndian.Uint32(material[i:]))
+	}
+	return path
+}
+
+// LinkingKey derives the domain's linking key from the wallet's BIP-32 root
+// as LUD-05 does, the key at LinkingPath of its HashingKey
+func LinkingKey(root *hdkey.Key, domain string) (*btcec.PrivateKey, error) {
+	hashingKey, err := HashingKey(root)
+	if err != nil {
+		return nil, err
+	}
+
+	k := root
+	for _, i := range LinkingPath(hashingKey, domain) {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+
+	d, _ := btcec.PrivKeyFromBytes(k.PrivateKey().FillBytes(make([]byte, 32)))
+	return d, nil
+}
+
+// SignK1 signs the k1 challenge with the linking key, returning the DER
+// encoded ECDSA signature and the compressed linking public key
+func (r *AuthRequest) SignK1(linkingKey *btcec.PrivateKey) ([]byte, []byte) {
+	sig := ecdsa.Sign(linkingKey, r.K1[:])
+	return sig.Serialize(), linkingKey.PubKey().SerializeCompressed()
+}
+
+// Callback derives the linking key for the request domain from the wallet's
+// root, signs k1 and returns the url the wallet must call to authenticate
+func (r *AuthRequest) Callback(root *hdkey.Key) (*url.URL, error) {
+	linkingKey, err := LinkingKey(root, r.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, key := r.SignK1(linkingKey)
+
+	callback := *r.URL
+	q := callback.Query()
+	q.Set("sig", hex.EncodeToString(sig))
+	q.Set("key", hex.EncodeToString(key))
+	callback.RawQuery = q.Encode()
+
+	return &callback, nil
+}
+
+// VerifyCallback is the service side check of a callback's sig and key
+// against the k1 it issued
+func VerifyCallback(k1 [32]byte, sigHex, keyHex string) (bool, error) {
+	sigBytes, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return false, err
+	}
+	keyBytes, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return false, err
+	}
+
+	sig, err := ecdsa.ParseDERSignature(sigBytes)
+	if err != nil {
+		return false, err
+	}
+	pubKey, err := btcec.ParsePubKey(keyBytes)
+	if err != nil {
+		return false, err
+	}
+
+	return sig.Verify(k1[:], pubKey), nil
+}
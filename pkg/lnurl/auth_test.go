@@ -0,0 +1,142 @@
+package lnurl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/hdkey"
+)
+
+const testK1 = "e2af6254a8df433264fa23f67eb8188635d15ce883e8fc020989d5f82ae6f11e"
+
+func TestDecode(t *testing.T) {
+	// example from LUD-01
+	lnurl := "LNURL1DP68GURN8GHJ7UM9WFMXJCM99E3K7MF0V9CXJ0M385EKVCENXC6R2C35XVUKXEFCV5MKVV34X5EKZD3EV56NYD3HXQURZEPEXEJXXEPNXSCRVWFNV9NXZCN9XQ6XYEFHVGCXXCMYXYMNSERXFQ5FNS"
+
+	// when
+	u, err := Decode("lightning:" + lnurl)
+	if err != nil {
+		t.Fatalf("Unexpected error from Decode(%s): %v", lnurl, err)
+	}
+
+	// then
+	expected := "https://service.com/api?q=3fc3645b439ce8e7f2553a69e5267081d96dcd340693afabe04be7b0ccd178df"
+	if u.String() != expected {
+		t.Fatalf("Decode(%s) = %s, want %s", lnurl, u, expected)
+	}
+
+	t.Run("Rejects plain http", func(t *testing.T) {
+		if _, err := Decode("http://service.com/api"); err == nil {
+			t.Fatalf("Decode of http url succeeded, want error")
+		}
+	})
+}
+
+func TestCallback(t *testing.T) {
+	root := testRoot(t)
+
+	lnurl, err := Encode("https://site.com/auth?tag=login&k1=" + testK1 + "&action=login")
+	if err != nil {
+		t.Fatalf("Unexpected error from Encode: %v", err)
+	}
+
+	req, err := ParseAuth(lnurl)
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseAuth(%s): %v", lnurl, err)
+	}
+	if req.Domain != "site.com" || req.Action != "login" || hex.EncodeToString(req.K1[:]) != testK1 {
+		t.Fatalf("ParseAuth(%s) = %+v", lnurl, req)
+	}
+
+	// when
+	callback, err := req.Callback(root)
+	if err != nil {
+		t.Fatalf("Unexpected error from Callback: %v", err)
+	}
+
+	// then
+	q := callback.Query()
+	ok, err := VerifyCallback(req.K1, q.Get("sig"), q.Get("key"))
+	if err != nil || !ok {
+		t.Fatalf("VerifyCallback(%s) = %v, %v, want true", callback, ok, err)
+	}
+
+	t.Run("Linking key depends on the domain", func(t *testing.T) {
+		a, _ := LinkingKey(root, "site.com")
+		b, _ := LinkingKey(root, "SITE.com")
+		c, _ := LinkingKey(root, "other.com")
+
+		if !bytes.Equal(a.Serialize(), b.Serialize()) {
+			t.Fatalf("LinkingKey differs by domain case")
+		}
+		if bytes.Equal(a.Serialize(), c.Serialize()) {
+			t.Fatalf("LinkingKey is the same for different domains")
+		}
+	})
+
+	t.Run("Rejects non login tags", func(t *testing.T) {
+		if _, err := ParseAuth("https://site.com/pay?tag=payRequest&k1=" + testK1); err == nil {
+			t.Fatalf("ParseAuth of payRequest succeeded, want error")
+		}
+	})
+}
+
+// testRoot is the master key of BIP-32 test vector 1
+func testRoot(t *testing.T) *hdkey.Key {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	root, err := hdkey.NewMaster(seed)
+	if err != nil {
+		t.Fatalf("Unexpected error from hdkey.NewMaster: %v", err)
+	}
+	return root
+}
+
+func TestLinkingPath(t *testing.T) {
+	// given the example of LUD-05
+	hashingKey, _ := hex.DecodeString("7d417a6a5e9a6a4a879aeaba11a11838764c8fa2b959c242d43dea682b3e409b")
+
+	// when
+	path := LinkingPath(hashingKey, "site.com")
+
+	// then
+	expected := []uint32{138 + hdkey.Hardened, 1588488367, 2659270754, 38110259, 4136336762}
+	if hdkey.FormatPath(path) != hdkey.FormatPath(expected) {
+		t.Fatalf("LinkingPath(site.com) = %s, want %s", hdkey.FormatPath(path), hdkey.FormatPath(expected))
+	}
+}
+
+func TestLinkingKey(t *testing.T) {
+	// given
+	root := testRoot(t)
+	hashingKey, err := HashingKey(root)
+	if err != nil {
+		t.Fatalf("Unexpected error from HashingKey: %v", err)
+	}
+	hashing, _ := root.Derive("m/138'/0")
+	if !bytes.Equal(hashingKey, hashing.PrivateKey().FillBytes(make([]byte, 32))) {
+		t.Fatalf("HashingKey = %x, want the key at m/138'/0", hashingKey)
+	}
+
+	// when
+	linkingKey, err := LinkingKey(root, "site.com")
+	if err != nil {
+		t.Fatalf("Unexpected error from LinkingKey: %v", err)
+	}
+
+	// then it's the wallet's key at the domain's path
+	path := hdkey.FormatPath(LinkingPath(hashingKey, "site.com"))
+	k, err := root.Derive(path)
+	if err != nil {
+		t.Fatalf("Unexpected error from Derive(%s): %v", path, err)
+	}
+	if pub := k.PublicKey(); !bytes.Equal(linkingKey.PubKey().SerializeCompressed(), pub[:]) {
+		t.Fatalf("LinkingKey(site.com) = %x, want the key at %s, %x", linkingKey.PubKey().SerializeCompressed(), path, pub)
+	}
+
+	t.Run("Rejects an xpub", func(t *testing.T) {
+		if _, err := LinkingKey(root.Neuter(), "site.com"); err == nil {
+			t.Fatalf("LinkingKey of an xpub succeeded, want error")
+		}
+	})
+}
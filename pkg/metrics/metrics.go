@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//
+// Just enough of the Prometheus text exposition format to serve gauges and
+// counters, so the daemon can be scraped without pulling in the client
+// library. Families are collected fresh on every scrape.
+//
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+//
+
+// Type is the metric type of a family
+type Type string
+
+const (
+	Gauge   Type = "gauge"
+	Counter Type = "counter"
+)
+
+// Sample is one value of a family, told apart by its labels
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Family is a named metric with its help text and samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Add appends a sample with labels given as name, value pairs
+func (f *Family) Add(value float64, labels ...string) {
+	s := Sample{Value: value}
+	if len(labels) > 0 {
+		s.Labels = map[string]string{}
+		for i := 0; i+1 < len(labels); i += 2 {
+			s.Labels[labels[i]] = labels[i+1]
+		}
+	}
+	f.Samples = append(f.Samples, s)
+}
+
+// Write writes the families in the text format. Families without samples
+// are left out.
+func Write(w io.Writer, families []*Family) error {
+	for _, f := range families {
+		if len(f.Samples) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.Name, escapeHelp(f.Help), f.Name, f.Type); err != nil {
+			return err
+		}
+		for _, s := range f.Samples {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", f.Name, formatLabels(s.Labels), formatValue(s.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Handler serves the families collect returns on every request
+func Handler(collect func() []*Family) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w, collect())
+	})
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=\"" + escapeLabel(labels[name]) + "\""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
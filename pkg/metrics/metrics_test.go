@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	// given
+	keys := &Family{Name: "schnorr_keys", Help: "Number of keys.", Type: Gauge}
+	keys.Add(2)
+	signatures := &Family{Name: "schnorr_signatures_total", Help: "Signatures made.", Type: Counter}
+	signatures.Add(3, "key", "release")
+	signatures.Add(1.5, "key", `a "quoted" name`)
+	empty := &Family{Name: "schnorr_empty", Help: "Never written.", Type: Gauge}
+
+	// when
+	var b strings.Builder
+	if err := Write(&b, []*Family{keys, signatures, empty}); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+
+	// then
+	want := `# HELP schnorr_keys Number of keys.
+# TYPE schnorr_keys gauge
+schnorr_keys 2
+# HELP schnorr_signatures_total Signatures made.
+# TYPE schnorr_signatures_total counter
+schnorr_signatures_total{key="release"} 3
+schnorr_signatures_total{key="a \"quoted\" name"} 1.5
+`
+	if b.String() != want {
+		t.Fatalf("Write() = %q, want %q", b.String(), want)
+	}
+}
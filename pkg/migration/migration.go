@@ -0,0 +1,117 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Moving a key's identity from the legacy scheme to BIP-340. Signatures
+// under the two schemes verify against different public keys, a
+// compressed key for the legacy scheme and an x-only key for BIP-340, so
+// verifiers who only know the legacy key have no reason to trust
+// BIP-340 signatures, even from the same private key.
+//
+// A migration statement links the two: the legacy key signs the BIP-340
+// key's fingerprint under the legacy scheme, and the BIP-340 key signs the
+// legacy key's fingerprint under BIP-340. Either signature alone could be
+// replayed by someone claiming a key they don't hold; together they show
+// the holder of each key agreed to the link. The BIP-340 key may be the
+// legacy key's own or a new one.
+//
+
+// Statement is a cross-signed link between a legacy key and a BIP-340 key
+type Statement struct {
+	// LegacyKey is the compressed public key, BIP340Key the x-only one
+	LegacyKey string `json:"legacyKey"`
+	BIP340Key string `json:"bip340Key"`
+	// LegacySignature is the legacy key's over the BIP-340 key's
+	// fingerprint, BIP340Signature the BIP-340 key's over the legacy key's
+	LegacySignature string `json:"legacySignature"`
+	BIP340Signature string `json:"bip340Signature"`
+}
+
+// Fingerprint is the hex sha256 of the public key as serialized for its
+// scheme, compressed for the legacy scheme and x-only for BIP-340
+func Fingerprint(publickey []byte) string {
+	sum := sha256.Sum256(publickey)
+	return hex.EncodeToString(sum[:])
+}
+
+// digest is what a key signs to vouch for the other, tagged by direction
+// so neither signature can stand in for the other
+func digest(tag string, signer, other []byte) [32]byte {
+	fingerprint, _ := hex.DecodeString(Fingerprint(other))
+	return schnorr.TaggedHash("schnorr-go/migration/"+tag, signer, fingerprint)
+}
+
+// Link makes the statement linking the legacy key to the BIP-340 key,
+// which may be the same private key
+func Link(legacy, bip340 *big.Int) (*Statement, error) {
+	legacyKey, err := schnorr.NewPrivateKey(legacy)
+	if err != nil {
+		return nil, fmt.Errorf("legacy key: %v", err)
+	}
+	bip340Key, err := schnorr.NewPrivateKey(bip340)
+	if err != nil {
+		return nil, fmt.Errorf("bip340 key: %v", err)
+	}
+	lk := legacyKey.PublicKey().Serialize()
+	bk := bip340Key.PublicKey().SerializeXOnly()
+
+	legacySig, err := schnorr.SignScheme(schnorr.SchemeLegacy, legacy, digest("legacy", lk[:], bk[:]))
+	if err != nil {
+		return nil, err
+	}
+	bip340Sig, err := schnorr.SignScheme(schnorr.SchemeBIP340, bip340, digest("bip340", bk[:], lk[:]))
+	if err != nil {
+		return nil, err
+	}
+	return &Statement{
+		LegacyKey:       hex.EncodeToString(lk[:]),
+		BIP340Key:       hex.EncodeToString(bk[:]),
+		LegacySignature: hex.EncodeToString(legacySig[:]),
+		BIP340Signature: hex.EncodeToString(bip340Sig[:]),
+	}, nil
+}
+
+// Verify checks both signatures of the statement and returns the keys it
+// links
+func Verify(s *Statement) ([33]byte, [32]byte, error) {
+	var lk [33]byte
+	var bk [32]byte
+	if err := decode(lk[:], s.LegacyKey, "legacy key"); err != nil {
+		return lk, bk, err
+	}
+	if err := decode(bk[:], s.BIP340Key, "bip340 key"); err != nil {
+		return lk, bk, err
+	}
+	var legacySig, bip340Sig [64]byte
+	if err := decode(legacySig[:], s.LegacySignature, "legacy signature"); err != nil {
+		return lk, bk, err
+	}
+	if err := decode(bip340Sig[:], s.BIP340Signature, "bip340 signature"); err != nil {
+		return lk, bk, err
+	}
+
+	if ok, err := schnorr.VerifyScheme(schnorr.SchemeLegacy, lk[:], digest("legacy", lk[:], bk[:]), legacySig); !ok {
+		return lk, bk, fmt.Errorf("legacy key's signature over the bip340 key does not verify: %v", err)
+	}
+	if ok, err := schnorr.VerifyScheme(schnorr.SchemeBIP340, bk[:], digest("bip340", bk[:], lk[:]), bip340Sig); !ok {
+		return lk, bk, fmt.Errorf("bip340 key's signature over the legacy key does not verify: %v", err)
+	}
+	return lk, bk, nil
+}
+
+func decode(out []byte, s, name string) error {
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != len(out) {
+		return fmt.Errorf("%s is not %d bytes of hex", name, len(out))
+	}
+	copy(out, raw)
+	return nil
+}
@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestLink(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("migration"), 2)
+
+	// given a legacy key moving to a new BIP-340 key
+	// when
+	s, err := Link(keys[0].PrivateKey, keys[1].PrivateKey)
+	if err != nil {
+		t.Fatalf("Unexpected error from Link: %v", err)
+	}
+
+	// then
+	legacy, bip340, err := Verify(s)
+	if err != nil {
+		t.Fatalf("Unexpected error from Verify: %v", err)
+	}
+	if legacy != keys[0].PublicKey {
+		t.Fatalf("Verify() legacy key = %x, want %x", legacy, keys[0].PublicKey)
+	}
+	if want := fmt.Sprintf("%x", keys[1].PublicKey[1:]); fmt.Sprintf("%x", bip340) != want {
+		t.Fatalf("Verify() bip340 key = %x, want %s", bip340, want)
+	}
+
+	t.Run("The same key under both schemes", func(t *testing.T) {
+		s, err := Link(keys[0].PrivateKey, keys[0].PrivateKey)
+		if err != nil {
+			t.Fatalf("Unexpected error from Link: %v", err)
+		}
+		if _, _, err := Verify(s); err != nil {
+			t.Fatalf("Unexpected error from Verify: %v", err)
+		}
+	})
+
+	t.Run("A statement naming another key is refused", func(t *testing.T) {
+		other, _ := Link(keys[1].PrivateKey, keys[1].PrivateKey)
+		forged := *s
+		forged.BIP340Key, forged.BIP340Signature = other.BIP340Key, other.BIP340Signature
+		if _, _, err := Verify(&forged); err == nil {
+			t.Fatalf("Verify() of a statement with another bip340 key succeeded, want an error")
+		}
+	})
+
+	t.Run("Swapped signatures are refused", func(t *testing.T) {
+		same, _ := Link(keys[0].PrivateKey, keys[0].PrivateKey)
+		swapped := *same
+		swapped.LegacySignature, swapped.BIP340Signature = same.BIP340Signature, same.LegacySignature
+		if _, _, err := Verify(&swapped); err == nil {
+			t.Fatalf("Verify() with swapped signatures succeeded, want an error")
+		}
+	})
+}
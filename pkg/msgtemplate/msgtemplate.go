@@ -0,0 +1,348 @@
+package msgtemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//
+// Templates say what a key may sign, checked before signing so a signer
+// refuses payloads nobody meant it to sign rather than signing whatever
+// digest it is handed. A key named by any template may only sign messages
+// matching one of its templates; keys no template names are unrestricted.
+//
+// A json template lists fields by dotted path, each with the type it must
+// have and optionally the values it may take, and other fields are refused
+// unless allow_extra_fields is set:
+//
+//	{"templates": [{
+//	  "name": "release",
+//	  "keys": ["release"],
+//	  "format": "json",
+//	  "fields": {
+//	    "type": {"type": "string", "enum": ["release"]},
+//	    "repo": {"type": "string", "enum": ["ryohare/schnorr-go"]},
+//	    "version": {"type": "string", "pattern": "^v[0-9]+\\.[0-9]+\\.[0-9]+$"},
+//	    "notes": {"type": "string", "optional": true}
+//	  }
+//	}]}
+//
+// A text template is a regular expression the whole message must match.
+//
+
+// Formats of message a template describes
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Field is what one field of a json message must be
+type Field struct {
+	// Type is string, number, bool, object or array, any type if empty
+	Type     string   `json:"type,omitempty"`
+	Optional bool     `json:"optional,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	// Pattern must match the whole of a string field
+	Pattern string `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Template is one kind of message some keys may sign
+type Template struct {
+	Name string `json:"name"`
+	// Keys are key names or hex public keys, "*" for every key
+	Keys             []string         `json:"keys"`
+	Format           string           `json:"format"`
+	Fields           map[string]Field `json:"fields,omitempty"`
+	AllowExtraFields bool             `json:"allow_extra_fields,omitempty"`
+	// Pattern is the expression text messages must match
+	Pattern string `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Policy is every template
+type Policy struct {
+	Templates []*Template `json:"templates"`
+}
+
+// Load reads and compiles a policy file
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse reads and compiles a policy
+func Parse(data []byte) (*Policy, error) {
+	p := new(Policy)
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("templates: %v", err)
+	}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Policy) compile() error {
+	for _, t := range p.Templates {
+		if len(t.Keys) == 0 {
+			return fmt.Errorf("template %q names no keys", t.Name)
+		}
+		switch t.Format {
+		case FormatText:
+			re, err := regexp.Compile(anchored(t.Pattern))
+			if err != nil {
+				return fmt.Errorf("template %q: %v", t.Name, err)
+			}
+			t.pattern = re
+		case FormatJSON:
+			for path, f := range t.Fields {
+				switch f.Type {
+				case "", "string", "number", "bool", "object", "array":
+				default:
+					return fmt.Errorf("template %q: field %s has unknown type %q", t.Name, path, f.Type)
+				}
+				if f.Pattern != "" {
+					re, err := regexp.Compile(anchored(f.Pattern))
+					if err != nil {
+						return fmt.Errorf("template %q: field %s: %v", t.Name, path, err)
+					}
+					f.pattern = re
+					t.Fields[path] = f
+				}
+			}
+		default:
+			return fmt.Errorf("template %q has format %q, want json or text", t.Name, t.Format)
+		}
+	}
+	return nil
+}
+
+// anchored makes the expression match whole strings only
+func anchored(pattern string) string {
+	return "^(?:" + pattern + ")$"
+}
+
+// ViolationError is a message matching none of the key's templates, with
+// why it failed each of them
+type ViolationError struct {
+	Key     string
+	Reasons map[string]string
+}
+
+func (e *ViolationError) Error() string {
+	names := make([]string, 0, len(e.Reasons))
+	for name := range e.Reasons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %s", name, e.Reasons[name])
+	}
+	return fmt.Sprintf("message for key %s matches no template (%s)", e.Key, strings.Join(parts, "; "))
+}
+
+// Restricted reports whether any template names one of the key's names,
+// so that only messages, not bare digests, may be signed with it
+func (p *Policy) Restricted(keys ...string) bool {
+	return len(p.templatesFor(keys)) > 0
+}
+
+// Check returns a *ViolationError unless the message matches a template
+// of the key, or the key is unrestricted. A key may go by several names,
+// such as its name and its public key.
+func (p *Policy) Check(message []byte, keys ...string) error {
+	templates := p.templatesFor(keys)
+	if len(templates) == 0 {
+		return nil
+	}
+
+	reasons := map[string]string{}
+	for _, t := range templates {
+		err := t.Match(message)
+		if err == nil {
+			return nil
+		}
+		reasons[t.Name] = err.Error()
+	}
+	return &ViolationError{Key: keys[0], Reasons: reasons}
+}
+
+func (p *Policy) templatesFor(keys []string) []*Template {
+	if p == nil {
+		return nil
+	}
+	matched := []*Template{}
+	for _, t := range p.Templates {
+	names:
+		for _, name := range t.Keys {
+			for _, key := range keys {
+				if name == "*" || strings.EqualFold(name, key) {
+					matched = append(matched, t)
+					break names
+				}
+			}
+		}
+	}
+	return matched
+}
+
+// Match returns why the message doesn't match the template, nil if it does
+func (t *Template) Match(message []byte) error {
+	if t.Format == FormatText {
+		if !t.pattern.Match(message) {
+			return fmt.Errorf("does not match %s", t.Pattern)
+		}
+		return nil
+	}
+
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(message))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("not json: %v", err)
+	}
+	if dec.More() {
+		return fmt.Errorf("more than one json value")
+	}
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("not a json object")
+	}
+
+	paths := make([]string, 0, len(t.Fields))
+	for path := range t.Fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		value, found := lookup(obj, path)
+		if !found {
+			if t.Fields[path].Optional {
+				continue
+			}
+			return fmt.Errorf("field %s is missing", path)
+		}
+		if err := t.Fields[path].check(value); err != nil {
+			return fmt.Errorf("field %s %v", path, err)
+		}
+	}
+
+	if !t.AllowExtraFields {
+		if extra := t.extraField(obj, ""); extra != "" {
+			return fmt.Errorf("field %s is not in the template", extra)
+		}
+	}
+	return nil
+}
+
+// lookup follows a dotted path through nested objects
+func lookup(obj map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = obj
+	for _, part := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// extraField returns the first field under prefix that the template
+// neither lists nor contains, sorted so the error is always the same
+func (t *Template) extraField(obj map[string]interface{}, prefix string) string {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := prefix + name
+		if _, ok := t.Fields[path]; ok {
+			continue
+		}
+		nested, isObject := obj[name].(map[string]interface{})
+		if !isObject || !t.hasFieldsUnder(path+".") {
+			return path
+		}
+		if extra := t.extraField(nested, path+"."); extra != "" {
+			return extra
+		}
+	}
+	return ""
+}
+
+func (t *Template) hasFieldsUnder(prefix string) bool {
+	for path := range t.Fields {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Field) check(value interface{}) error {
+	if f.Type != "" && typeOf(value) != f.Type {
+		return fmt.Errorf("is %s, want %s", typeOf(value), f.Type)
+	}
+
+	s, isString := value.(string)
+	if n, ok := value.(json.Number); ok {
+		s, isString = n.String(), true
+	}
+	if b, ok := value.(bool); ok {
+		s, isString = fmt.Sprint(b), true
+	}
+
+	if len(f.Enum) > 0 {
+		if !isString {
+			return fmt.Errorf("is %s, which can't be one of %s", typeOf(value), strings.Join(f.Enum, ", "))
+		}
+		allowed := false
+		for _, v := range f.Enum {
+			if v == s {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("is %q, want one of %s", s, strings.Join(f.Enum, ", "))
+		}
+	}
+	if f.pattern != nil && (!isString || !f.pattern.MatchString(s)) {
+		return fmt.Errorf("is %v, which does not match %s", value, f.Pattern)
+	}
+	return nil
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "bool"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return "null"
+}
@@ -0,0 +1,100 @@
+package msgtemplate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `{"templates": [
+	{
+		"name": "release",
+		"keys": ["release"],
+		"format": "json",
+		"fields": {
+			"type": {"type": "string", "enum": ["release"]},
+			"repo": {"type": "string", "enum": ["ryohare/schnorr-go", "ryohare/other"]},
+			"version": {"type": "string", "pattern": "v[0-9]+\\.[0-9]+\\.[0-9]+"},
+			"build.number": {"type": "number"},
+			"notes": {"type": "string", "optional": true}
+		}
+	},
+	{
+		"name": "tag",
+		"keys": ["release"],
+		"format": "text",
+		"pattern": "tag v[0-9]+"
+	}
+]}`
+
+func TestCheck(t *testing.T) {
+	policy, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		message string
+		want    string
+	}{
+		{"Release", "release", `{"type": "release", "repo": "ryohare/schnorr-go", "version": "v1.4.0", "build": {"number": 12}}`, ""},
+		{"Optional field", "release", `{"type": "release", "repo": "ryohare/other", "version": "v1.4.0", "build": {"number": 12}, "notes": "fixes"}`, ""},
+		{"Text template", "release", "tag v14", ""},
+		{"Repo not allowed", "release", `{"type": "release", "repo": "evil/fork", "version": "v1.4.0", "build": {"number": 12}}`, "field repo is \"evil/fork\""},
+		{"Bad version", "release", `{"type": "release", "repo": "ryohare/other", "version": "1.4", "build": {"number": 12}}`, "field version is 1.4, which does not match"},
+		{"Missing field", "release", `{"type": "release", "repo": "ryohare/other", "version": "v1.4.0"}`, "field build.number is missing"},
+		{"Wrong type", "release", `{"type": "release", "repo": "ryohare/other", "version": "v1.4.0", "build": {"number": "12"}}`, "field build.number is string, want number"},
+		{"Extra field", "release", `{"type": "release", "repo": "ryohare/other", "version": "v1.4.0", "build": {"number": 12, "host": "ci"}}`, "field build.host is not in the template"},
+		{"Not json", "release", "sign anything", "not json"},
+		{"Unrestricted key", "ops", "sign anything", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// when
+			err := policy.Check([]byte(test.message), test.key)
+
+			// then
+			if test.want == "" {
+				if err != nil {
+					t.Fatalf("Check() = %v, want nil", err)
+				}
+				return
+			}
+			var violation *ViolationError
+			if !errors.As(err, &violation) || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("Check() = %v, want a violation containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRestricted(t *testing.T) {
+	policy, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	if !policy.Restricted("0282b4d9", "release") || policy.Restricted("ops") {
+		t.Fatalf("Restricted() does not follow the templates' keys")
+	}
+
+	var none *Policy
+	if none.Restricted("release") || none.Check([]byte("anything"), "release") != nil {
+		t.Fatalf("a nil policy restricts keys")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, policy := range []string{
+		`{"templates": [{"name": "a", "keys": ["k"], "format": "yaml"}]}`,
+		`{"templates": [{"name": "a", "format": "text", "pattern": "x"}]}`,
+		`{"templates": [{"name": "a", "keys": ["k"], "format": "text", "pattern": "("}]}`,
+		`{"templates": [{"name": "a", "keys": ["k"], "format": "json", "fields": {"x": {"type": "date"}}}]}`,
+	} {
+		if _, err := Parse([]byte(policy)); err == nil {
+			t.Fatalf("Parse(%s) succeeded, want error", policy)
+		}
+	}
+}
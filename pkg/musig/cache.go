@@ -0,0 +1,334 @@
+package musig
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// Tweak is one tweak applied to an aggregate key, in order
+type Tweak struct {
+	Value [32]byte
+	XOnly bool
+}
+
+// SortKeys is KeySort of BIP-327, so cosigners who list each other in
+// different orders still agree on the aggregate key
+func SortKeys(keys [][33]byte) [][33]byte {
+	sorted := append([][33]byte{}, keys...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+	return sorted
+}
+
+// CacheID names an aggregation by its sorted keys and its tweaks
+func CacheID(keys [][33]byte, tweaks []Tweak) [32]byte {
+	data := []byte{}
+	for _, key := range SortKeys(keys) {
+		data = append(data, key[:]...)
+	}
+	data = appendUint64(data, uint64(len(tweaks)))
+	for _, t := range tweaks {
+		flag := byte(0)
+		if t.XOnly {
+			flag = 1
+		}
+		data = append(append(data, t.Value[:]...), flag)
+	}
+	return schnorr.TaggedHash("schnorr-go/musig/keyagg-cache", data)
+}
+
+// CachedKeyAgg is a computed aggregation: enough to rebuild its
+// KeyAggContext without hashing the key list or summing points again
+type CachedKeyAgg struct {
+	Keys         [][33]byte
+	Tweaks       []Tweak
+	AggregateKey [33]byte
+	Gacc, Tacc   *big.Int
+	Coefficients []*big.Int
+}
+
+type cachedKeyAggJSON struct {
+	Keys   []string `json:"keys"`
+	Tweaks []struct {
+		Value string `json:"value"`
+		XOnly bool   `json:"xonly,omitempty"`
+	} `json:"tweaks,omitempty"`
+	AggregateKey string   `json:"aggregate_key"`
+	Gacc         string   `json:"gacc"`
+	Tacc         string   `json:"tacc"`
+	Coefficients []string `json:"coefficients"`
+}
+
+func (c *CachedKeyAgg) MarshalJSON() ([]byte, error) {
+	out := cachedKeyAggJSON{
+		AggregateKey: hex.EncodeToString(c.AggregateKey[:]),
+		Gacc:         hex.EncodeToString(schnorr.GetBigIntBytesImmutable(c.Gacc)),
+		Tacc:         hex.EncodeToString(schnorr.GetBigIntBytesImmutable(c.Tacc)),
+	}
+	for _, key := range c.Keys {
+		out.Keys = append(out.Keys, hex.EncodeToString(key[:]))
+	}
+	for _, t := range c.Tweaks {
+		out.Tweaks = append(out.Tweaks, struct {
+			Value string `json:"value"`
+			XOnly bool   `json:"xonly,omitempty"`
+		}{hex.EncodeToString(t.Value[:]), t.XOnly})
+	}
+	for _, a := range c.Coefficients {
+		out.Coefficients = append(out.Coefficients, hex.EncodeToString(schnorr.GetBigIntBytesImmutable(a)))
+	}
+	return json.Marshal(out)
+}
+
+func (c *CachedKeyAgg) UnmarshalJSON(data []byte) error {
+	in := cachedKeyAggJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	scalar := func(s string) (*big.Int, error) {
+		raw, err := hex.DecodeString(s)
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("invalid scalar %q", s)
+		}
+		v := new(big.Int).SetBytes(raw)
+		if v.Cmp(schnorr.Curve.N) >= 0 {
+			return nil, fmt.Errorf("scalar %q is not below curve order N", s)
+		}
+		return v, nil
+	}
+
+	out := CachedKeyAgg{}
+	for _, k := range in.Keys {
+		raw, err := hex.DecodeString(k)
+		if err != nil || len(raw) != 33 {
+			return fmt.Errorf("invalid public key %q", k)
+		}
+		var key [33]byte
+		copy(key[:], raw)
+		out.Keys = append(out.Keys, key)
+	}
+	for _, t := range in.Tweaks {
+		raw, err := hex.DecodeString(t.Value)
+		if err != nil || len(raw) != 32 {
+			return fmt.Errorf("invalid tweak %q", t.Value)
+		}
+		tweak := Tweak{XOnly: t.XOnly}
+		copy(tweak.Value[:], raw)
+		out.Tweaks = append(out.Tweaks, tweak)
+	}
+	raw, err := hex.DecodeString(in.AggregateKey)
+	if err != nil || len(raw) != 33 {
+		return fmt.Errorf("invalid aggregate key %q", in.AggregateKey)
+	}
+	copy(out.AggregateKey[:], raw)
+	if out.Gacc, err = scalar(in.Gacc); err != nil {
+		return err
+	}
+	if out.Tacc, err = scalar(in.Tacc); err != nil {
+		return err
+	}
+	for _, s := range in.Coefficients {
+		a, err := scalar(s)
+		if err != nil {
+			return err
+		}
+		out.Coefficients = append(out.Coefficients, a)
+	}
+
+	*c = out
+	return nil
+}
+
+// context rebuilds the KeyAggContext the entry was made from
+func (c *CachedKeyAgg) context() (*KeyAggContext, error) {
+	if len(c.Coefficients) != len(c.Keys) {
+		return nil, fmt.Errorf("cached aggregation has %d coefficients for %d keys", len(c.Coefficients), len(c.Keys))
+	}
+	q, err := schnorr.ParsePoint(c.AggregateKey)
+	if err != nil {
+		return nil, fmt.Errorf("cached aggregate key: %v", err)
+	}
+
+	members := make([]Member, len(c.Keys))
+	for i, key := range c.Keys {
+		members[i].Key = key
+	}
+	return &KeyAggContext{
+		q:            q,
+		gacc:         new(big.Int).Set(c.Gacc),
+		tacc:         new(big.Int).Set(c.Tacc),
+		members:      members,
+		coefficients: append([]*big.Int{}, c.Coefficients...),
+	}, nil
+}
+
+// KeyAggStore holds cached aggregations by CacheID
+type KeyAggStore interface {
+	// Load returns the entry for id, false if there is none
+	Load(id [32]byte) (*CachedKeyAgg, bool, error)
+
+	Store(id [32]byte, entry *CachedKeyAgg) error
+}
+
+// KeyAggCache aggregates sorted keys, reusing earlier results from the
+// store. Sorting first means every session with the same cosigner set and
+// tweaks gets the same key, in whatever order the keys were collected.
+type KeyAggCache struct {
+	Store KeyAggStore
+}
+
+func NewKeyAggCache(store KeyAggStore) *KeyAggCache {
+	return &KeyAggCache{Store: store}
+}
+
+// Aggregate is AggregateKeys over the sorted keys followed by the tweaks
+func (c *KeyAggCache) Aggregate(keys [][33]byte, tweaks []Tweak) (*KeyAggContext, error) {
+	sorted := SortKeys(keys)
+	id := CacheID(sorted, tweaks)
+
+	entry, ok, err := c.Store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	if ok && sameAggregation(entry, sorted, tweaks) {
+		return entry.context()
+	}
+
+	ctx, err := AggregateKeys(sorted)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tweaks {
+		if ctx, err = ctx.Tweak(t.Value, t.XOnly); err != nil {
+			return nil, err
+		}
+	}
+
+	q, err := ctx.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	entry = &CachedKeyAgg{
+		Keys:         sorted,
+		Tweaks:       append([]Tweak{}, tweaks...),
+		AggregateKey: q,
+		Gacc:         ctx.gacc,
+		Tacc:         ctx.tacc,
+		Coefficients: ctx.coefficients,
+	}
+	if err := c.Store.Store(id, entry); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// sameAggregation guards against a store handing back an entry for a
+// different set of keys
+func sameAggregation(entry *CachedKeyAgg, keys [][33]byte, tweaks []Tweak) bool {
+	if len(entry.Keys) != len(keys) || len(entry.Tweaks) != len(tweaks) {
+		return false
+	}
+	for i := range keys {
+		if entry.Keys[i] != keys[i] {
+			return false
+		}
+	}
+	for i := range tweaks {
+		if entry.Tweaks[i] != tweaks[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// MemoryKeyAggStore keeps the cache in memory
+type MemoryKeyAggStore struct {
+	mu      sync.Mutex
+	entries map[[32]byte]*CachedKeyAgg
+}
+
+func NewMemoryKeyAggStore() *MemoryKeyAggStore {
+	return &MemoryKeyAggStore{entries: map[[32]byte]*CachedKeyAgg{}}
+}
+
+func (s *MemoryKeyAggStore) Load(id [32]byte) (*CachedKeyAgg, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	return entry, ok, nil
+}
+
+func (s *MemoryKeyAggStore) Store(id [32]byte, entry *CachedKeyAgg) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[id] = entry
+	return nil
+}
+
+// DirKeyAggStore keeps the cache as one json file per aggregation in a
+// directory next to the keys, so it survives restarts
+type DirKeyAggStore struct {
+	Dir string
+}
+
+func NewDirKeyAggStore(dir string) (*DirKeyAggStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DirKeyAggStore{Dir: dir}, nil
+}
+
+func (s *DirKeyAggStore) path(id [32]byte) string {
+	return filepath.Join(s.Dir, hex.EncodeToString(id[:])+".json")
+}
+
+func (s *DirKeyAggStore) Load(id [32]byte) (*CachedKeyAgg, bool, error) {
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry := new(CachedKeyAgg)
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, false, fmt.Errorf("cached aggregation %x: %v", id, err)
+	}
+	return entry, true, nil
+}
+
+// Store writes the entry to a temporary file and renames it into place so a
+// reader never sees half an entry
+func (s *DirKeyAggStore) Store(id [32]byte, entry *CachedKeyAgg) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, ".keyagg-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(id))
+}
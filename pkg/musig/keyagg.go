@@ -0,0 +1,221 @@
+package musig
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0327.mediawiki
+//
+
+// Member is one entry of a key aggregation. A plain member only has a Key.
+// A nested member has the Group it stands for, and its Key is the public key
+// of that group, so a team key can sit in a larger multisig while the team
+// members still sign for themselves.
+type Member struct {
+	Key   [33]byte
+	Group *KeyAggContext
+}
+
+// KeyAggContext is an aggregate public key with the bookkeeping needed to
+// sign for it: the key coefficients of the members and the accumulated
+// negations (gacc) and tweaks (tacc) applied on top
+type KeyAggContext struct {
+	q            *schnorr.Point
+	gacc, tacc   *big.Int
+	members      []Member
+	coefficients []*big.Int
+}
+
+// AggregateKeys is KeyAgg of BIP-327 over plain keys, in the order given
+func AggregateKeys(keys [][33]byte) (*KeyAggContext, error) {
+	members := make([]Member, len(keys))
+	for i, key := range keys {
+		members[i].Key = key
+	}
+	return Aggregate(members)
+}
+
+// Aggregate is KeyAgg over members, any of which may be aggregates
+// themselves
+func Aggregate(members []Member) (*KeyAggContext, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no public keys supplied")
+	}
+
+	ctx := &KeyAggContext{
+		q:            schnorr.Infinity,
+		gacc:         big.NewInt(1),
+		tacc:         big.NewInt(0),
+		members:      make([]Member, len(members)),
+		coefficients: make([]*big.Int, len(members)),
+	}
+	copy(ctx.members, members)
+
+	for i, m := range members {
+		if m.Group != nil {
+			groupKey, err := m.Group.PublicKey()
+			if err != nil {
+				return nil, err
+			}
+			if groupKey != m.Key {
+				return nil, fmt.Errorf("member %d key does not match its group key", i)
+			}
+		}
+	}
+
+	keys := make([][33]byte, len(members))
+	for i, m := range members {
+		keys[i] = m.Key
+	}
+	l := hashKeys(keys)
+	second := secondKey(keys)
+
+	for i, key := range keys {
+		p, err := schnorr.ParsePoint(key)
+		if err != nil {
+			return nil, fmt.Errorf("public key %d: %v", i, err)
+		}
+
+		a := big.NewInt(1)
+		if second == nil || key != *second {
+			h := schnorr.TaggedHash("KeyAgg coefficient", l[:], key[:])
+			a = new(big.Int).SetBytes(h[:])
+			a.Mod(a, schnorr.Curve.N)
+		}
+
+		ctx.coefficients[i] = a
+		ctx.q = ctx.q.Add(p.Mul(a))
+	}
+
+	if ctx.q.IsInfinity() {
+		return nil, fmt.Errorf("aggregate public key is infinity")
+	}
+	return ctx, nil
+}
+
+func hashKeys(keys [][33]byte) [32]byte {
+	data := make([]byte, 0, 33*len(keys))
+	for _, key := range keys {
+		data = append(data, key[:]...)
+	}
+	return schnorr.TaggedHash("KeyAgg list", data)
+}
+
+// secondKey is the first key that differs from the first one, which gets a
+// coefficient of 1
+func secondKey(keys [][33]byte) *[33]byte {
+	for i := range keys[1:] {
+		if !bytes.Equal(keys[i+1][:], keys[0][:]) {
+			return &keys[i+1]
+		}
+	}
+	return nil
+}
+
+// PublicKey is the plain 33 byte aggregate key, which is also the key to use
+// when nesting this aggregate inside another
+func (c *KeyAggContext) PublicKey() ([33]byte, error) {
+	return c.q.PublicKey()
+}
+
+// XOnly is the BIP-340 key signatures verify against
+func (c *KeyAggContext) XOnly() ([32]byte, error) {
+	return c.q.XOnly()
+}
+
+// Tweak returns the context with the tweak added to the aggregate key. An
+// x-only tweak first negates the key if needed to give it an even y, as
+// taproot does; a plain tweak is added to the key as is.
+func (c *KeyAggContext) Tweak(tweak [32]byte, xonly bool) (*KeyAggContext, error) {
+	t := new(big.Int).SetBytes(tweak[:])
+	if t.Cmp(schnorr.Curve.N) >= 0 {
+		return nil, fmt.Errorf("tweak is larger than or equal to curve order N")
+	}
+
+	g := big.NewInt(1)
+	if xonly && !c.q.HasEvenY() {
+		g = new(big.Int).Sub(schnorr.Curve.N, g)
+	}
+
+	q := c.q.Mul(g).Add(schnorr.ScalarBaseMult(t))
+	if q.IsInfinity() {
+		return nil, fmt.Errorf("tweaked public key is infinity")
+	}
+
+	gacc := new(big.Int).Mul(g, c.gacc)
+	tacc := new(big.Int).Mul(g, c.tacc)
+	tacc.Add(tacc, t)
+
+	return &KeyAggContext{
+		q:            q,
+		gacc:         gacc.Mod(gacc, schnorr.Curve.N),
+		tacc:         tacc.Mod(tacc, schnorr.Curve.N),
+		members:      c.members,
+		coefficients: c.coefficients,
+	}, nil
+}
+
+// Members returns the members the key was aggregated from
+func (c *KeyAggContext) Members() []Member {
+	return append([]Member{}, c.members...)
+}
+
+// Signers returns the distinct plain keys that sign for the aggregate,
+// descending into nested groups
+func (c *KeyAggContext) Signers() [][33]byte {
+	seen := map[[33]byte]bool{}
+	signers := [][33]byte{}
+
+	var walk func(*KeyAggContext)
+	walk = func(c *KeyAggContext) {
+		for _, m := range c.members {
+			if m.Group != nil {
+				walk(m.Group)
+			} else if !seen[m.Key] {
+				seen[m.Key] = true
+				signers = append(signers, m.Key)
+			}
+		}
+	}
+	walk(c)
+
+	return signers
+}
+
+// Coefficient is what the signer's private key is multiplied by in the
+// aggregate key: the product of the key coefficients and negations along the
+// path down to the signer. A key appearing more than once has the sum over
+// all its positions and signs once for all of them.
+func (c *KeyAggContext) Coefficient(publickey [33]byte) *big.Int {
+	sum := new(big.Int)
+	for i, m := range c.members {
+		if m.Group != nil {
+			sum.Add(sum, new(big.Int).Mul(c.coefficients[i], m.Group.Coefficient(publickey)))
+		} else if m.Key == publickey {
+			sum.Add(sum, c.coefficients[i])
+		}
+	}
+
+	sum.Mul(sum, c.gacc)
+	return sum.Mod(sum, schnorr.Curve.N)
+}
+
+// tweakSum is the discrete log of the part of the aggregate key which comes
+// from tweaks rather than signers, including those of nested groups
+func (c *KeyAggContext) tweakSum() *big.Int {
+	sum := new(big.Int)
+	for i, m := range c.members {
+		if m.Group != nil {
+			sum.Add(sum, new(big.Int).Mul(c.coefficients[i], m.Group.tweakSum()))
+		}
+	}
+
+	sum.Mul(sum, c.gacc)
+	sum.Add(sum, c.tacc)
+	return sum.Mod(sum, schnorr.Curve.N)
+}
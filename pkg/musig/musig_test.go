@@ -0,0 +1,417 @@
+package musig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	sg "github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func decodeKey(t *testing.T, s string) [33]byte {
+	var key [33]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 33 {
+		t.Fatalf("Unexpected error from hex.DecodeString(%s): %v", s, err)
+	}
+	copy(key[:], b)
+	return key
+}
+
+func TestAggregateKeys(t *testing.T) {
+	// key_agg_vectors.json from BIP-327
+	keys := []string{
+		"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		"03DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		"023590A94E768F8E1815C2F24B4D80A8E3149316C3518CE7B7AD338368D038CA66",
+	}
+
+	tests := []struct {
+		indices  []int
+		expected string
+	}{
+		{[]int{0, 1, 2}, "90539EEDE565F5D054F32CC0C220126889ED1E5D193BAF15AEF344FE59D4610C"},
+		{[]int{2, 1, 0}, "6204DE8B083426DC6EAF9502D27024D53FC826BF7D2012148A0575435DF54B2B"},
+		{[]int{0, 0, 0}, "B436E3BAD62B8CD409969A224731C193D051162D8C5AE8B109306127DA3AA935"},
+		{[]int{0, 0, 1, 1}, "69BC22BFA5D106306E48A20679DE1D7389386124D07571D0D872686028C26A3E"},
+	}
+
+	for _, tc := range tests {
+		// given
+		pks := [][33]byte{}
+		for _, i := range tc.indices {
+			pks = append(pks, decodeKey(t, keys[i]))
+		}
+
+		// when
+		ctx, err := AggregateKeys(pks)
+		if err != nil {
+			t.Fatalf("Unexpected error from AggregateKeys(%v): %v", tc.indices, err)
+		}
+
+		// then
+		x, _ := ctx.XOnly()
+		if got := strings.ToUpper(hex.EncodeToString(x[:])); got != tc.expected {
+			t.Fatalf("AggregateKeys(%v) = %s, want %s", tc.indices, got, tc.expected)
+		}
+	}
+}
+
+// sign runs both rounds for the signers under ctx and checks the result
+// against the BIP-340 verifier of btcec
+func sign(t *testing.T, ctx *KeyAggContext, keys []sg.TestKey, msg []byte) {
+	secnonces := make([]*SecNonce, len(keys))
+	pubnonces := make([]PubNonce, len(keys))
+	for i, key := range keys {
+		var err error
+		secnonces[i], pubnonces[i], err = NonceGen(key.PrivateKey, key.PublicKey, ctx, msg)
+		if err != nil {
+			t.Fatalf("Unexpected error from NonceGen: %v", err)
+		}
+	}
+
+	aggnonce, err := AggregateNonces(pubnonces)
+	if err != nil {
+		t.Fatalf("Unexpected error from AggregateNonces: %v", err)
+	}
+	session, err := NewSession(ctx, aggnonce, msg)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewSession: %v", err)
+	}
+
+	psigs := make([][32]byte, len(keys))
+	for i, key := range keys {
+		psigs[i], err = session.Sign(secnonces[i], key.PrivateKey)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		if err := session.VerifyPartial(psigs[i], pubnonces[i], key.PublicKey); err != nil {
+			t.Fatalf("VerifyPartial() = %v, want nil", err)
+		}
+	}
+
+	if _, err := session.Sign(secnonces[0], keys[0].PrivateKey); err == nil {
+		t.Fatalf("Sign() with a used nonce = nil error, want error")
+	}
+
+	bad := psigs[0]
+	bad[31] ^= 1
+	if err := session.VerifyPartial(bad, pubnonces[0], keys[0].PublicKey); err == nil {
+		t.Fatalf("VerifyPartial() on a bad partial signature = nil, want error")
+	}
+
+	signature, err := session.Combine(psigs)
+	if err != nil {
+		t.Fatalf("Unexpected error from Combine: %v", err)
+	}
+
+	x, _ := ctx.XOnly()
+	pubKey, err := schnorr.ParsePubKey(x[:])
+	if err != nil {
+		t.Fatalf("Unexpected error from ParsePubKey: %v", err)
+	}
+	sig, err := schnorr.ParseSignature(signature[:])
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseSignature: %v", err)
+	}
+	if !sig.Verify(msg, pubKey) {
+		t.Fatalf("Combine() = %x, does not verify", signature)
+	}
+}
+
+func TestSign(t *testing.T) {
+	keys, err := sg.GenerateTestKeys([]byte("musig"), 5)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	msg := sha256.Sum256([]byte("musig"))
+
+	t.Run("Flat", func(t *testing.T) {
+		ctx, err := AggregateKeys([][33]byte{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey})
+		if err != nil {
+			t.Fatalf("Unexpected error from AggregateKeys: %v", err)
+		}
+		sign(t, ctx, keys[:3], msg[:])
+	})
+
+	t.Run("Tweaked", func(t *testing.T) {
+		ctx, err := AggregateKeys([][33]byte{keys[0].PublicKey, keys[1].PublicKey})
+		if err != nil {
+			t.Fatalf("Unexpected error from AggregateKeys: %v", err)
+		}
+		ctx, err = ctx.Tweak(sha256.Sum256([]byte("plain")), false)
+		if err != nil {
+			t.Fatalf("Unexpected error from Tweak: %v", err)
+		}
+		ctx, err = ctx.Tweak(sha256.Sum256([]byte("xonly")), true)
+		if err != nil {
+			t.Fatalf("Unexpected error from Tweak: %v", err)
+		}
+		sign(t, ctx, keys[:2], msg[:])
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		// a tweaked team of three inside a multisig with two more keys
+		team, err := AggregateKeys([][33]byte{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey})
+		if err != nil {
+			t.Fatalf("Unexpected error from AggregateKeys: %v", err)
+		}
+		team, err = team.Tweak(sha256.Sum256([]byte("team")), true)
+		if err != nil {
+			t.Fatalf("Unexpected error from Tweak: %v", err)
+		}
+		teamKey, _ := team.PublicKey()
+
+		ctx, err := Aggregate([]Member{{Key: keys[3].PublicKey}, {Key: teamKey, Group: team}, {Key: keys[4].PublicKey}})
+		if err != nil {
+			t.Fatalf("Unexpected error from Aggregate: %v", err)
+		}
+		ctx, err = ctx.Tweak(sha256.Sum256([]byte("outer")), true)
+		if err != nil {
+			t.Fatalf("Unexpected error from Tweak: %v", err)
+		}
+
+		// then
+		if len(ctx.Signers()) != 5 {
+			t.Fatalf("Signers() = %d keys, want 5", len(ctx.Signers()))
+		}
+
+		// the nested key is the same as aggregating the team key directly
+		flat, err := AggregateKeys([][33]byte{keys[3].PublicKey, teamKey, keys[4].PublicKey})
+		if err != nil {
+			t.Fatalf("Unexpected error from AggregateKeys: %v", err)
+		}
+		flat, _ = flat.Tweak(sha256.Sum256([]byte("outer")), true)
+		if a, b := mustPublicKey(t, ctx), mustPublicKey(t, flat); a != b {
+			t.Fatalf("Aggregate() = %x, want %x", a, b)
+		}
+
+		sign(t, ctx, keys, msg[:])
+	})
+
+	t.Run("Mismatched group key fails", func(t *testing.T) {
+		team, _ := AggregateKeys([][33]byte{keys[0].PublicKey, keys[1].PublicKey})
+		_, err := Aggregate([]Member{{Key: keys[2].PublicKey, Group: team}, {Key: keys[3].PublicKey}})
+		if err == nil {
+			t.Fatalf("Aggregate() with wrong group key = nil error, want error")
+		}
+	})
+
+	t.Run("Outsider cannot sign", func(t *testing.T) {
+		ctx, _ := AggregateKeys([][33]byte{keys[0].PublicKey, keys[1].PublicKey})
+		if a := ctx.Coefficient(keys[2].PublicKey); a.Cmp(big.NewInt(0)) != 0 {
+			t.Fatalf("Coefficient() of an outsider = %v, want 0", a)
+		}
+	})
+}
+
+func mustPublicKey(t *testing.T, ctx *KeyAggContext) [33]byte {
+	pk, err := ctx.PublicKey()
+	if err != nil {
+		t.Fatalf("Unexpected error from PublicKey: %v", err)
+	}
+	return pk
+}
+
+func TestCombineVerified(t *testing.T) {
+	// given
+	keys, err := sg.GenerateTestKeys([]byte("musig"), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	publickeys := [][33]byte{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey}
+	ctx, err := AggregateKeys(publickeys)
+	if err != nil {
+		t.Fatalf("Unexpected error from AggregateKeys: %v", err)
+	}
+	msg := sha256.Sum256([]byte("blame"))
+
+	secnonces := make([]*SecNonce, 3)
+	pubnonces := make([]PubNonce, 3)
+	for i, key := range keys {
+		secnonces[i], pubnonces[i], _ = NonceGen(key.PrivateKey, key.PublicKey, ctx, msg[:])
+	}
+	aggnonce, _ := AggregateNonces(pubnonces)
+	session, err := NewSession(ctx, aggnonce, msg[:])
+	if err != nil {
+		t.Fatalf("Unexpected error from NewSession: %v", err)
+	}
+
+	psigs := make([][32]byte, 3)
+	for i, key := range keys {
+		psigs[i], _ = session.Sign(secnonces[i], key.PrivateKey)
+	}
+
+	if _, err := session.CombineVerified(psigs, pubnonces, publickeys); err != nil {
+		t.Fatalf("CombineVerified() = %v, want nil", err)
+	}
+
+	// when the second signer sends garbage
+	psigs[1][5] ^= 1
+	_, err = session.CombineVerified(psigs, pubnonces, publickeys)
+
+	// then
+	blameErr, ok := err.(*BlameError)
+	if !ok || len(blameErr.Signers) != 1 || blameErr.Signers[0] != keys[1].PublicKey {
+		t.Fatalf("CombineVerified() = %v, want the second signer blamed", err)
+	}
+}
+
+func TestKeyAggCache(t *testing.T) {
+	// given
+	keys, err := sg.GenerateTestKeys([]byte("musig"), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	publickeys := [][33]byte{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey}
+	tweaks := []Tweak{{Value: sha256.Sum256([]byte("taproot")), XOnly: true}}
+	store, err := NewDirKeyAggStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDirKeyAggStore: %v", err)
+	}
+	msg := sha256.Sum256([]byte("cached"))
+
+	// when
+	first, err := NewKeyAggCache(store).Aggregate(publickeys, tweaks)
+	if err != nil {
+		t.Fatalf("Unexpected error from Aggregate: %v", err)
+	}
+	reordered := [][33]byte{publickeys[2], publickeys[0], publickeys[1]}
+	second, err := NewKeyAggCache(store).Aggregate(reordered, tweaks)
+	if err != nil {
+		t.Fatalf("Unexpected error from Aggregate: %v", err)
+	}
+
+	// then
+	if _, ok, _ := store.Load(CacheID(publickeys, tweaks)); !ok {
+		t.Fatalf("Load() = false, want the aggregation stored")
+	}
+	q1, _ := first.PublicKey()
+	q2, _ := second.PublicKey()
+	if q1 != q2 {
+		t.Fatalf("PublicKey() from the cache = %x, want %x", q2, q1)
+	}
+	sign(t, second, keys, msg[:])
+
+	t.Run("Different tweaks miss", func(t *testing.T) {
+		if CacheID(publickeys, nil) == CacheID(publickeys, tweaks) {
+			t.Fatalf("CacheID() ignores the tweaks")
+		}
+		ctx, err := NewKeyAggCache(store).Aggregate(publickeys, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error from Aggregate: %v", err)
+		}
+		if q, _ := ctx.PublicKey(); q == q1 {
+			t.Fatalf("PublicKey() without tweaks = tweaked key, want untweaked")
+		}
+	})
+}
+
+func TestSigner(t *testing.T) {
+	keys, err := sg.GenerateTestKeys([]byte("musig"), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	msg := sha256.Sum256([]byte("signer"))
+
+	pks := [][33]byte{}
+	for _, key := range keys {
+		pks = append(pks, key.PublicKey)
+	}
+	ctx, err := AggregateKeys(SortKeys(pks))
+	if err != nil {
+		t.Fatalf("Unexpected error from AggregateKeys: %v", err)
+	}
+
+	// given every party with only its own key
+	signers := make([]*Signer, len(keys))
+	for i, key := range keys {
+		if signers[i], err = NewSigner(key.PrivateKey, ctx, msg[:]); err != nil {
+			t.Fatalf("Unexpected error from NewSigner: %v", err)
+		}
+	}
+
+	// when the nonces go round
+	for _, from := range signers {
+		nonce, err := from.Nonce()
+		if err != nil {
+			t.Fatalf("Unexpected error from Nonce: %v", err)
+		}
+		for _, to := range signers {
+			if to != from {
+				if err := to.AddNonce(from.PublicKey(), nonce); err != nil {
+					t.Fatalf("Unexpected error from AddNonce: %v", err)
+				}
+			}
+		}
+	}
+
+	// and then the partial signatures
+	psigs := make([][32]byte, len(signers))
+	for i, from := range signers {
+		if psigs[i], err = from.Sign(); err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+	}
+
+	bad := psigs[1]
+	bad[31] ^= 1
+	err = signers[0].AddPartial(signers[1].PublicKey(), bad)
+	if blame, ok := err.(*BlameError); !ok || blame.Signers[0] != signers[1].PublicKey() {
+		t.Fatalf("AddPartial() with a bad partial signature = %v, want a BlameError naming signer 1", err)
+	}
+
+	for i, from := range signers {
+		for _, to := range signers {
+			if to != from {
+				if err := to.AddPartial(from.PublicKey(), psigs[i]); err != nil {
+					t.Fatalf("Unexpected error from AddPartial: %v", err)
+				}
+			}
+		}
+	}
+
+	// then every party gets the same valid signature
+	x, _ := ctx.XOnly()
+	pubKey, _ := schnorr.ParsePubKey(x[:])
+	first, err := signers[0].Combine()
+	if err != nil {
+		t.Fatalf("Unexpected error from Combine: %v", err)
+	}
+	sig, err := schnorr.ParseSignature(first[:])
+	if err != nil || !sig.Verify(msg[:], pubKey) {
+		t.Fatalf("Combine() = %x, does not verify", first)
+	}
+	for _, s := range signers[1:] {
+		if signature, _ := s.Combine(); signature != first {
+			t.Fatalf("Combine() = %x, want %x", signature, first)
+		}
+	}
+
+	t.Run("Out of order", func(t *testing.T) {
+		s, _ := NewSigner(keys[0].PrivateKey, ctx, msg[:])
+		if _, err := s.Sign(); err == nil {
+			t.Fatalf("Sign() before Nonce = nil error, want error")
+		}
+		s.Nonce()
+		if _, err := s.Sign(); err == nil {
+			t.Fatalf("Sign() without the other nonces = nil error, want error")
+		}
+		if len(s.Missing()) != 2 {
+			t.Fatalf("Missing() = %d keys, want 2", len(s.Missing()))
+		}
+		if err := s.AddPartial(keys[1].PublicKey, psigs[1]); err == nil {
+			t.Fatalf("AddPartial() before Sign = nil error, want error")
+		}
+	})
+
+	t.Run("Not a signer", func(t *testing.T) {
+		others, _ := sg.GenerateTestKeys([]byte("outsider"), 1)
+		if _, err := NewSigner(others[0].PrivateKey, ctx, msg[:]); err == nil {
+			t.Fatalf("NewSigner() with an outside key = nil error, want error")
+		}
+	})
+}
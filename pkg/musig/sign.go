@@ -0,0 +1,326 @@
+package musig
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// PubNonce is the pair of public nonces R1 || R2 a signer shares in the first
+// round. An aggregated nonce has the same form, so the nonce of a nested
+// group is the aggregate of its members' nonces.
+type PubNonce [66]byte
+
+// SecNonce is the secret half of a nonce. It is cleared when used so it can
+// never sign twice.
+type SecNonce struct {
+	k1, k2    *big.Int
+	publickey [33]byte
+}
+
+// NonceGen makes a fresh nonce for the signer. The private key, aggregate
+// and message are optional extra inputs which guard against a bad random
+// number generator; pass nil to leave them out.
+func NonceGen(privatekey *big.Int, publickey [33]byte, ctx *KeyAggContext, msg []byte) (*SecNonce, PubNonce, error) {
+	var pubnonce PubNonce
+
+	rnd := make([]byte, 32)
+	if _, err := rand.Read(rnd); err != nil {
+		return nil, pubnonce, err
+	}
+	if privatekey != nil {
+		aux := schnorr.TaggedHash("MuSig/aux", rnd)
+		for i, b := range schnorr.GetBigIntBytesImmutable(privatekey) {
+			rnd[i] = b ^ aux[i]
+		}
+	}
+
+	aggpk := []byte{}
+	if ctx != nil {
+		x, err := ctx.XOnly()
+		if err != nil {
+			return nil, pubnonce, err
+		}
+		aggpk = x[:]
+	}
+
+	msgPrefixed := []byte{0}
+	if msg != nil {
+		msgPrefixed = append([]byte{1}, appendUint64(nil, uint64(len(msg)))...)
+		msgPrefixed = append(msgPrefixed, msg...)
+	}
+
+	k := make([]*big.Int, 2)
+	for i := range k {
+		h := schnorr.TaggedHash("MuSig/nonce",
+			rnd,
+			[]byte{byte(len(publickey))}, publickey[:],
+			[]byte{byte(len(aggpk))}, aggpk,
+			msgPrefixed,
+			[]byte{0, 0, 0, 0},
+			[]byte{byte(i)},
+		)
+		k[i] = new(big.Int).SetBytes(h[:])
+		k[i].Mod(k[i], schnorr.Curve.N)
+		if k[i].Sign() == 0 {
+			return nil, pubnonce, fmt.Errorf("nonce is zero")
+		}
+
+		r, _ := schnorr.ScalarBaseMult(k[i]).PublicKey()
+		copy(pubnonce[33*i:], r[:])
+	}
+
+	return &SecNonce{k1: k[0], k2: k[1], publickey: publickey}, pubnonce, nil
+}
+
+// AggregateNonces sums the nonces of all the signers
+func AggregateNonces(pubnonces []PubNonce) (PubNonce, error) {
+	var aggnonce PubNonce
+
+	for j := 0; j < 2; j++ {
+		sum := schnorr.Infinity
+		for i, pubnonce := range pubnonces {
+			r, err := parseNonce(pubnonce, j)
+			if err != nil {
+				return aggnonce, fmt.Errorf("nonce %d: %v", i, err)
+			}
+			sum = sum.Add(r)
+		}
+
+		// infinity is encoded as 33 zero bytes
+		if !sum.IsInfinity() {
+			r, _ := sum.PublicKey()
+			copy(aggnonce[33*j:], r[:])
+		}
+	}
+
+	return aggnonce, nil
+}
+
+func parseNonce(nonce PubNonce, j int) (*schnorr.Point, error) {
+	var r [33]byte
+	copy(r[:], nonce[33*j:33*j+33])
+	if r == ([33]byte{}) {
+		return schnorr.Infinity, nil
+	}
+	return schnorr.ParsePoint(r)
+}
+
+// Session is the state shared by every signer once the nonces are
+// aggregated
+type Session struct {
+	ctx *KeyAggContext
+	msg []byte
+
+	// b is the nonce coefficient, r the final nonce and e the challenge
+	b, e *big.Int
+	r    *schnorr.Point
+}
+
+// NewSession computes the session values for signing msg with the aggregate
+// key. For nested groups ctx is the outermost aggregate and aggnonce covers
+// every signer below it.
+func NewSession(ctx *KeyAggContext, aggnonce PubNonce, msg []byte) (*Session, error) {
+	r1, err := parseNonce(aggnonce, 0)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := parseNonce(aggnonce, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := ctx.XOnly()
+	if err != nil {
+		return nil, err
+	}
+
+	h := schnorr.TaggedHash("MuSig/noncecoef", aggnonce[:], q[:], msg)
+	b := new(big.Int).SetBytes(h[:])
+	b.Mod(b, schnorr.Curve.N)
+
+	r := r1.Add(r2.Mul(b))
+	if r.IsInfinity() {
+		r = schnorr.ScalarBaseMult(big.NewInt(1))
+	}
+
+	rX, _ := r.XOnly()
+	e, err := schnorr.ComputeChallenge(rX[:], ctx.q.X(), ctx.q.Y(), msg, schnorr.BIP340Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{ctx: ctx, msg: append([]byte{}, msg...), b: b, e: e, r: r}, nil
+}
+
+// g undoes the negation BIP-340 applies to keys with an odd y
+func (s *Session) g() *big.Int {
+	if s.ctx.q.HasEvenY() {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Sub(schnorr.Curve.N, big.NewInt(1))
+}
+
+// Sign produces the partial signature of one signer, clearing the secret
+// nonce
+func (s *Session) Sign(secnonce *SecNonce, privatekey *big.Int) ([32]byte, error) {
+	var psig [32]byte
+
+	if secnonce.k1 == nil || secnonce.k2 == nil {
+		return psig, fmt.Errorf("secret nonce has already been used")
+	}
+	k1, k2 := secnonce.k1, secnonce.k2
+	secnonce.k1, secnonce.k2 = nil, nil
+
+	if privatekey.Sign() <= 0 || privatekey.Cmp(schnorr.Curve.N) >= 0 {
+		return psig, fmt.Errorf("private key is not in the range 1..n-1")
+	}
+	publickey, _ := schnorr.ScalarBaseMult(privatekey).PublicKey()
+	if publickey != secnonce.publickey {
+		return psig, fmt.Errorf("secret nonce was made for a different key")
+	}
+
+	a := s.ctx.Coefficient(publickey)
+	if a.Sign() == 0 {
+		return psig, fmt.Errorf("public key is not a signer of the aggregate")
+	}
+
+	k := new(big.Int).Mul(s.b, k2)
+	k.Add(k, k1)
+	if !s.r.HasEvenY() {
+		k.Neg(k)
+	}
+
+	// s = k + e*g*a*d
+	sig := new(big.Int).Mul(s.e, s.g())
+	sig.Mul(sig, a)
+	sig.Mul(sig, privatekey)
+	sig.Add(sig, k)
+	sig.Mod(sig, schnorr.Curve.N)
+
+	copy(psig[:], schnorr.GetBigIntBytesImmutable(sig))
+	return psig, nil
+}
+
+// VerifyPartial checks the partial signature of the signer with the public
+// nonce it shared, which is what identifies a misbehaving signer
+func (s *Session) VerifyPartial(psig [32]byte, pubnonce PubNonce, publickey [33]byte) error {
+	sig := new(big.Int).SetBytes(psig[:])
+	if sig.Cmp(schnorr.Curve.N) >= 0 {
+		return fmt.Errorf("s is larger than or equal to curve order N")
+	}
+
+	p, err := schnorr.ParsePoint(publickey)
+	if err != nil {
+		return err
+	}
+	r1, err := parseNonce(pubnonce, 0)
+	if err != nil {
+		return err
+	}
+	r2, err := parseNonce(pubnonce, 1)
+	if err != nil {
+		return err
+	}
+
+	a := s.ctx.Coefficient(publickey)
+	if a.Sign() == 0 {
+		return fmt.Errorf("public key is not a signer of the aggregate")
+	}
+
+	r := r1.Add(r2.Mul(s.b))
+	if !s.r.HasEvenY() {
+		r = r.Negate()
+	}
+
+	// s*G == R + e*g*a*P
+	ega := new(big.Int).Mul(s.e, s.g())
+	ega.Mul(ega, a)
+	if !schnorr.ScalarBaseMult(sig).Equal(r.Add(p.Mul(ega))) {
+		return fmt.Errorf("partial signature verification failed")
+	}
+	return nil
+}
+
+// Combine adds up the partial signatures of every signer into a BIP-340
+// signature for the aggregate key
+func (s *Session) Combine(psigs [][32]byte) ([64]byte, error) {
+	var signature [64]byte
+
+	sum := new(big.Int)
+	for i, psig := range psigs {
+		sig := new(big.Int).SetBytes(psig[:])
+		if sig.Cmp(schnorr.Curve.N) >= 0 {
+			return signature, fmt.Errorf("partial signature %d is larger than or equal to curve order N", i)
+		}
+		sum.Add(sum, sig)
+	}
+
+	// the tweaks are known to everyone, so they are signed for here
+	t := new(big.Int).Mul(s.e, s.g())
+	t.Mul(t, s.ctx.tweakSum())
+	sum.Add(sum, t)
+	sum.Mod(sum, schnorr.Curve.N)
+
+	rX, _ := s.r.XOnly()
+	copy(signature[:32], rX[:])
+	copy(signature[32:], schnorr.GetBigIntBytesImmutable(sum))
+	return signature, nil
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	for i := 7; i >= 0; i-- {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return b
+}
+
+// BlameError names the signers, by public key, whose partial signatures did
+// not verify. Coordinators can leave them out and try again.
+type BlameError struct {
+	Signers [][33]byte
+}
+
+func (e *BlameError) Error() string {
+	keys := make([]string, len(e.Signers))
+	for i, key := range e.Signers {
+		keys[i] = hex.EncodeToString(key[:])
+	}
+	return fmt.Sprintf("invalid partial signatures from %s", strings.Join(keys, ", "))
+}
+
+// CombineVerified is Combine for coordinators, who know which signer sent
+// what. The signature is checked and if it does not verify every partial
+// signature is, and the error is a BlameError naming the bad signers.
+func (s *Session) CombineVerified(psigs [][32]byte, pubnonces []PubNonce, publickeys [][33]byte) ([64]byte, error) {
+	if len(psigs) != len(pubnonces) || len(psigs) != len(publickeys) {
+		return [64]byte{}, fmt.Errorf("got %d partial signatures, %d nonces and %d keys", len(psigs), len(pubnonces), len(publickeys))
+	}
+
+	signature, err := s.Combine(psigs)
+	if err != nil {
+		return signature, err
+	}
+
+	// s*G == R + e*lift_x(Q), with R and lift_x(Q) both having even y
+	sum := new(big.Int).SetBytes(signature[32:])
+	rEven := s.r
+	if !rEven.HasEvenY() {
+		rEven = rEven.Negate()
+	}
+	if schnorr.ScalarBaseMult(sum).Equal(rEven.Add(s.ctx.q.Mul(new(big.Int).Mul(s.e, s.g())))) {
+		return signature, nil
+	}
+
+	bad := &BlameError{}
+	for i := range psigs {
+		if err := s.VerifyPartial(psigs[i], pubnonces[i], publickeys[i]); err != nil {
+			bad.Signers = append(bad.Signers, publickeys[i])
+		}
+	}
+	return [64]byte{}, bad
+}
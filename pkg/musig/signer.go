@@ -0,0 +1,205 @@
+package musig
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// Signer is one party's side of a MuSig2 signature, for parties who each
+// hold only their own key. It walks the two rounds in order:
+//
+//	Nonce          share the public nonce with the other signers
+//	AddNonce       for every other signer's nonce
+//	Sign           share the partial signature
+//	AddPartial     for every other signer's partial signature
+//	Combine        the signature, which any party can compute
+//
+// Partial signatures are checked as they are added, so a bad signer is
+// named as soon as its share arrives. A Signer signs at most once.
+type Signer struct {
+	privatekey *big.Int
+	publickey  [33]byte
+	ctx        *KeyAggContext
+	msg        []byte
+
+	secnonce *SecNonce
+	nonces   map[[33]byte]PubNonce
+	session  *Session
+	partials map[[33]byte][32]byte
+}
+
+// NewSigner starts signing msg for the aggregate ctx, which the private key
+// must be one of the signers of. Every party has to build ctx from the same
+// keys in the same order, with the same tweaks; SortKeys or a KeyAggCache
+// fixes the order.
+func NewSigner(privatekey *big.Int, ctx *KeyAggContext, msg []byte) (*Signer, error) {
+	if privatekey.Sign() <= 0 || privatekey.Cmp(schnorr.Curve.N) >= 0 {
+		return nil, fmt.Errorf("private key is not in the range 1..n-1")
+	}
+	publickey, _ := schnorr.ScalarBaseMult(privatekey).PublicKey()
+	if ctx.Coefficient(publickey).Sign() == 0 {
+		return nil, fmt.Errorf("public key %x is not a signer of the aggregate", publickey)
+	}
+
+	return &Signer{
+		privatekey: privatekey,
+		publickey:  publickey,
+		ctx:        ctx,
+		msg:        append([]byte{}, msg...),
+		nonces:     map[[33]byte]PubNonce{},
+		partials:   map[[33]byte][32]byte{},
+	}, nil
+}
+
+// PublicKey is the signer's own key, which the other parties file its
+// nonce and partial signature under
+func (s *Signer) PublicKey() [33]byte {
+	return s.publickey
+}
+
+// Nonce is the first round: it makes the signer's nonce, once, and returns
+// the public half to send to the other signers
+func (s *Signer) Nonce() (PubNonce, error) {
+	if nonce, ok := s.nonces[s.publickey]; ok {
+		return nonce, nil
+	}
+
+	secnonce, pubnonce, err := NonceGen(s.privatekey, s.publickey, s.ctx, s.msg)
+	if err != nil {
+		return pubnonce, err
+	}
+	s.secnonce = secnonce
+	s.nonces[s.publickey] = pubnonce
+	return pubnonce, nil
+}
+
+// AddNonce records another signer's public nonce
+func (s *Signer) AddNonce(publickey [33]byte, nonce PubNonce) error {
+	if s.session != nil {
+		return fmt.Errorf("nonces are closed once signing has started")
+	}
+	if publickey == s.publickey {
+		return fmt.Errorf("nonce is for this signer's own key")
+	}
+	if s.ctx.Coefficient(publickey).Sign() == 0 {
+		return fmt.Errorf("public key %x is not a signer of the aggregate", publickey)
+	}
+	if existing, ok := s.nonces[publickey]; ok && existing != nonce {
+		return fmt.Errorf("signer %x already sent a different nonce", publickey)
+	}
+	for j := 0; j < 2; j++ {
+		if _, err := parseNonce(nonce, j); err != nil {
+			return fmt.Errorf("nonce from %x: %v", publickey, err)
+		}
+	}
+	s.nonces[publickey] = nonce
+	return nil
+}
+
+// Missing lists the signers whose nonce, or once signing has started whose
+// partial signature, has not arrived yet
+func (s *Signer) Missing() [][33]byte {
+	missing := [][33]byte{}
+	for _, key := range s.ctx.Signers() {
+		if s.session == nil {
+			if _, ok := s.nonces[key]; !ok {
+				missing = append(missing, key)
+			}
+		} else if _, ok := s.partials[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// Sign is the second round: once every nonce is in it returns the signer's
+// partial signature. The secret nonce is used up, so calling Sign again
+// returns the same partial signature rather than a new one.
+func (s *Signer) Sign() ([32]byte, error) {
+	if psig, ok := s.partials[s.publickey]; ok {
+		return psig, nil
+	}
+	if s.secnonce == nil {
+		return [32]byte{}, fmt.Errorf("call Nonce before Sign")
+	}
+	if missing := s.Missing(); len(missing) > 0 {
+		return [32]byte{}, fmt.Errorf("waiting for the nonces of %s", keyList(missing))
+	}
+
+	pubnonces := []PubNonce{}
+	for _, key := range s.ctx.Signers() {
+		pubnonces = append(pubnonces, s.nonces[key])
+	}
+	aggnonce, err := AggregateNonces(pubnonces)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	session, err := NewSession(s.ctx, aggnonce, s.msg)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	psig, err := session.Sign(s.secnonce, s.privatekey)
+	if err != nil {
+		return psig, err
+	}
+	s.session = session
+	s.partials[s.publickey] = psig
+	return psig, nil
+}
+
+// AddPartial checks and records another signer's partial signature. An
+// invalid one is refused with a BlameError naming the signer.
+func (s *Signer) AddPartial(publickey [33]byte, psig [32]byte) error {
+	if s.session == nil {
+		return fmt.Errorf("call Sign before adding partial signatures")
+	}
+	nonce, ok := s.nonces[publickey]
+	if !ok {
+		return fmt.Errorf("public key %x is not a signer of the aggregate", publickey)
+	}
+	if existing, ok := s.partials[publickey]; ok {
+		if existing != psig {
+			return fmt.Errorf("signer %x already sent a different partial signature", publickey)
+		}
+		return nil
+	}
+	if err := s.session.VerifyPartial(psig, nonce, publickey); err != nil {
+		return &BlameError{Signers: [][33]byte{publickey}}
+	}
+	s.partials[publickey] = psig
+	return nil
+}
+
+// Combine returns the BIP-340 signature for the aggregate key once every
+// partial signature is in
+func (s *Signer) Combine() ([64]byte, error) {
+	if s.session == nil {
+		return [64]byte{}, fmt.Errorf("call Sign before Combine")
+	}
+	if missing := s.Missing(); len(missing) > 0 {
+		return [64]byte{}, fmt.Errorf("waiting for the partial signatures of %s", keyList(missing))
+	}
+
+	psigs, pubnonces, keys := [][32]byte{}, []PubNonce{}, [][33]byte{}
+	for _, key := range s.ctx.Signers() {
+		psigs = append(psigs, s.partials[key])
+		pubnonces = append(pubnonces, s.nonces[key])
+		keys = append(keys, key)
+	}
+	return s.session.CombineVerified(psigs, pubnonces, keys)
+}
+
+func keyList(keys [][33]byte) string {
+	list := ""
+	for i, key := range keys {
+		if i > 0 {
+			list += ", "
+		}
+		list += hex.EncodeToString(key[:])
+	}
+	return list
+}
@@ -0,0 +1,199 @@
+package nostr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+//
+// https://github.com/nostr-protocol/nips/blob/master/01.md
+//
+
+// Event is a NIP-01 nostr event
+type Event struct {
+	ID        string     `json:"id"`
+	PubKey    string     `json:"pubkey"`
+	CreatedAt int64      `json:"created_at"`
+	Kind      int        `json:"kind"`
+	Tags      [][]string `json:"tags"`
+	Content   string     `json:"content"`
+	Sig       string     `json:"sig"`
+}
+
+// NewEvent creates an unsigned event stamped with the current time
+func NewEvent(kind int, content string, tags [][]string) *Event {
+	if tags == nil {
+		tags = [][]string{}
+	}
+
+	return &Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      kind,
+		Tags:      tags,
+		Content:   content,
+	}
+}
+
+// ParseEvent reads an event from json, which may be unsigned as clients
+// hand events to signers: without a created_at it is stamped with the
+// current time, and without tags it has none. The kind must be given.
+func ParseEvent(data []byte) (*Event, error) {
+	var raw struct {
+		Event
+		CreatedAt *int64 `json:"created_at"`
+		Kind      *int   `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("event is not json: %v", err)
+	}
+	if raw.Kind == nil {
+		return nil, fmt.Errorf("event has no kind")
+	}
+
+	ev := raw.Event
+	ev.Kind = *raw.Kind
+	if raw.CreatedAt == nil {
+		ev.CreatedAt = time.Now().Unix()
+	} else {
+		ev.CreatedAt = *raw.CreatedAt
+	}
+	if ev.Tags == nil {
+		ev.Tags = [][]string{}
+	}
+	return &ev, nil
+}
+
+// Serialize returns the canonical array form of the event which is hashed
+// to produce the event id: [0, pubkey, created_at, kind, tags, content]
+func (ev *Event) Serialize() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteString("[0,")
+	writeString(buf, ev.PubKey)
+	fmt.Fprintf(buf, ",%d,%d,[", ev.CreatedAt, ev.Kind)
+	for i, tag := range ev.Tags {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('[')
+		for j, s := range tag {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			writeString(buf, s)
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteString("],")
+	writeString(buf, ev.Content)
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// writeString writes s as a json string escaped as NIP-01 has it: only
+// the quote, the backslash and \n, \r, \t, \b and \f are escaped, and
+// everything else is written as it is. json.Marshal escapes more, such as
+// <, > and &, and U+2028 and U+2029 even with html escaping off, which
+// would change the id.
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// Hash computes the sha256 of the serialized event
+func (ev *Event) Hash() ([32]byte, error) {
+	data, err := ev.Serialize()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// Sign fills in the pubkey, id and sig of the event using the 32 byte
+// private key with a BIP-340 signature over the event id
+func (ev *Event) Sign(privatekey []byte) error {
+	if len(privatekey) != 32 {
+		return fmt.Errorf("private key must be 32 bytes, got %d", len(privatekey))
+	}
+
+	privKey, pubKey := btcec.PrivKeyFromBytes(privatekey)
+	if privKey.Key.IsZero() {
+		return fmt.Errorf("private key must not be zero")
+	}
+
+	ev.PubKey = hex.EncodeToString(schnorr.SerializePubKey(pubKey))
+
+	id, err := ev.Hash()
+	if err != nil {
+		return err
+	}
+
+	sig, err := schnorr.Sign(privKey, id[:])
+	if err != nil {
+		return err
+	}
+
+	ev.ID = hex.EncodeToString(id[:])
+	ev.Sig = hex.EncodeToString(sig.Serialize())
+
+	return nil
+}
+
+// Verify checks that the id matches the event contents and that the
+// signature over the id is valid for the event pubkey
+func (ev *Event) Verify() (bool, error) {
+	id, err := ev.Hash()
+	if err != nil {
+		return false, err
+	}
+
+	if hex.EncodeToString(id[:]) != ev.ID {
+		return false, fmt.Errorf("event id does not match the event contents")
+	}
+
+	pubKeyBytes, err := hex.DecodeString(ev.PubKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid pubkey: %v", err)
+	}
+	pubKey, err := schnorr.ParsePubKey(pubKeyBytes)
+	if err != nil {
+		return false, err
+	}
+
+	sigBytes, err := hex.DecodeString(ev.Sig)
+	if err != nil {
+		return false, fmt.Errorf("invalid sig: %v", err)
+	}
+	sig, err := schnorr.ParseSignature(sigBytes)
+	if err != nil {
+		return false, err
+	}
+
+	return sig.Verify(id[:], pubKey), nil
+}
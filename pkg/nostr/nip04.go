@@ -0,0 +1,124 @@
+package nostr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+//
+// https://github.com/nostr-protocol/nips/blob/master/04.md
+//
+
+// KindDirectMessage is the kind of NIP-04 encrypted direct messages
+const KindDirectMessage = 4
+
+// SharedSecret is the x coordinate of the ECDH point between the private key
+// and the hex x-only public key
+func SharedSecret(privatekey []byte, pubkey string) ([]byte, error) {
+	if len(privatekey) != 32 {
+		return nil, fmt.Errorf("private key must be 32 bytes, got %d", len(privatekey))
+	}
+	raw, err := hex.DecodeString(pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey: %v", err)
+	}
+	pub, err := schnorr.ParsePubKey(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, _ := btcec.PrivKeyFromBytes(privatekey)
+	return btcec.GenerateSharedSecret(priv, pub), nil
+}
+
+// Encrypt encrypts the text for pubkey with AES-256-CBC under the shared
+// secret, as base64(ciphertext)?iv=base64(iv)
+func Encrypt(privatekey []byte, pubkey, text string) (string, error) {
+	key, err := SharedSecret(privatekey, pubkey)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	// PKCS#7 padding
+	pad := aes.BlockSize - len(text)%aes.BlockSize
+	data := append([]byte(text), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	return base64.StdEncoding.EncodeToString(data) + "?iv=" + base64.StdEncoding.EncodeToString(iv), nil
+}
+
+// Decrypt reverses Encrypt, with pubkey being the other side's key
+func Decrypt(privatekey []byte, pubkey, content string) (string, error) {
+	body, ivPart, found := strings.Cut(content, "?iv=")
+	if !found {
+		return "", fmt.Errorf("content has no iv")
+	}
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext: %v", err)
+	}
+	iv, err := base64.StdEncoding.DecodeString(ivPart)
+	if err != nil || len(iv) != aes.BlockSize {
+		return "", fmt.Errorf("invalid iv")
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return "", fmt.Errorf("ciphertext is not a whole number of blocks")
+	}
+
+	key, err := SharedSecret(privatekey, pubkey)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(data[len(data)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return "", fmt.Errorf("invalid padding")
+	}
+	return string(data[:len(data)-pad]), nil
+}
+
+// NewDirectMessage makes a signed direct message to the hex x-only pubkey
+func NewDirectMessage(privatekey []byte, pubkey, text string, tags [][]string) (*Event, error) {
+	content, err := Encrypt(privatekey, pubkey, text)
+	if err != nil {
+		return nil, err
+	}
+
+	ev := NewEvent(KindDirectMessage, content, append([][]string{{"p", pubkey}}, tags...))
+	if err := ev.Sign(privatekey); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// PublicKey returns the hex x-only public key of the private key
+func PublicKey(privatekey []byte) (string, error) {
+	if len(privatekey) != 32 {
+		return "", fmt.Errorf("private key must be 32 bytes, got %d", len(privatekey))
+	}
+	_, pub := btcec.PrivKeyFromBytes(privatekey)
+	return hex.EncodeToString(schnorr.SerializePubKey(pub)), nil
+}
@@ -0,0 +1,69 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/bech32"
+)
+
+//
+// https://github.com/nostr-protocol/nips/blob/master/19.md
+//
+// Only the bare keys, npub and nsec, are handled here, not the entities
+// with TLV data such as nprofile.
+//
+
+// EncodePublicKey returns the npub of the hex x-only public key
+func EncodePublicKey(pubkey string) (string, error) {
+	raw, err := hex.DecodeString(pubkey)
+	if err != nil || len(raw) != 32 {
+		return "", fmt.Errorf("public key must be 32 bytes of hex")
+	}
+	return bech32.EncodeBytes("npub", raw)
+}
+
+// DecodePublicKey returns the hex x-only public key of the npub
+func DecodePublicKey(npub string) (string, error) {
+	raw, err := decodeKey(npub, "npub")
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// EncodePrivateKey returns the nsec of the private key
+func EncodePrivateKey(privatekey []byte) (string, error) {
+	if len(privatekey) != 32 {
+		return "", fmt.Errorf("private key must be 32 bytes, got %d", len(privatekey))
+	}
+	return bech32.EncodeBytes("nsec", privatekey)
+}
+
+// DecodePrivateKey returns the private key of the nsec
+func DecodePrivateKey(nsec string) ([]byte, error) {
+	return decodeKey(nsec, "nsec")
+}
+
+// PublicKeyHex returns the pubkey as hex, decoding it if it's an npub
+func PublicKeyHex(pubkey string) (string, error) {
+	if strings.HasPrefix(strings.ToLower(pubkey), "npub1") {
+		return DecodePublicKey(pubkey)
+	}
+	return pubkey, nil
+}
+
+func decodeKey(s, prefix string) ([]byte, error) {
+	hrp, raw, err := bech32.DecodeBytes(s)
+	if err != nil {
+		return nil, err
+	}
+	if hrp != prefix {
+		return nil, fmt.Errorf("want an %s, got human readable part %q", prefix, hrp)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("%s holds %d bytes, want 32", prefix, len(raw))
+	}
+	return raw, nil
+}
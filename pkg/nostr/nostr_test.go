@@ -0,0 +1,257 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+const testPrivKey = "B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF"
+
+func signedEvent(t *testing.T) *Event {
+	d, err := hex.DecodeString(testPrivKey)
+	if err != nil {
+		t.Fatalf("Unexpected error from hex.DecodeString(%s): %v", testPrivKey, err)
+	}
+
+	ev := NewEvent(1, "hello <nostr> & friends", [][]string{{"t", "schnorr"}})
+	if err := ev.Sign(d); err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	return ev
+}
+
+func TestSignVerify(t *testing.T) {
+	ev := signedEvent(t)
+
+	// then
+	if ev.PubKey != "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659" {
+		t.Fatalf("PubKey = %s, want x-only key of the private key", ev.PubKey)
+	}
+
+	ok, err := ev.Verify()
+	if err != nil || !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+
+	t.Run("Tampered content fails", func(t *testing.T) {
+		tampered := *ev
+		tampered.Content = "goodbye"
+		if ok, _ := tampered.Verify(); ok {
+			t.Fatalf("Verify() on tampered event = true, want false")
+		}
+	})
+
+	t.Run("Serialization does not escape html", func(t *testing.T) {
+		data, err := ev.Serialize()
+		if err != nil {
+			t.Fatalf("Unexpected error from Serialize: %v", err)
+		}
+		if !strings.Contains(string(data), "<nostr> & friends") {
+			t.Fatalf("Serialize() = %s, want unescaped content", data)
+		}
+	})
+}
+
+func TestSerialize(t *testing.T) {
+	// given content with line and paragraph separators and the characters
+	// NIP-01 escapes
+	ev := &Event{
+		PubKey:    "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+		CreatedAt: 1700000000,
+		Kind:      1,
+		Tags:      [][]string{{"t", "a\u2028b"}},
+		Content:   "line\u2028para\u2029\"q\" \\ \n\t<&>\x01",
+	}
+
+	// when
+	data, err := ev.Serialize()
+	if err != nil {
+		t.Fatalf("Unexpected error from Serialize: %v", err)
+	}
+
+	// then the separators and control characters are written as they are
+	want := `[0,"dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",1700000000,1,[["t","a` + "\u2028" + `b"]],"line` + "\u2028" + `para` + "\u2029" + `\"q\" \\ \n\t<&>` + "\x01" + `"]`
+	if string(data) != want {
+		t.Fatalf("Serialize() = %q, want %q", data, want)
+	}
+}
+
+// fakeRelay accepts every event and replays it back on any REQ
+func fakeRelay(ws *websocket.Conn) {
+	var stored json.RawMessage
+	for {
+		var data string
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			return
+		}
+
+		msg := []json.RawMessage{}
+		json.Unmarshal([]byte(data), &msg)
+
+		switch label(msg) {
+		case "EVENT":
+			stored = msg[1]
+			ev := new(Event)
+			json.Unmarshal(msg[1], ev)
+			websocket.Message.Send(ws, `["NOTICE","welcome"]`)
+			websocket.Message.Send(ws, `["OK","`+ev.ID+`",true,""]`)
+		case "REQ":
+			var sub string
+			json.Unmarshal(msg[1], &sub)
+			if stored != nil {
+				websocket.Message.Send(ws, `["EVENT","`+sub+`",`+string(stored)+`]`)
+			}
+			websocket.Message.Send(ws, `["EOSE","`+sub+`"]`)
+		}
+	}
+}
+
+func TestRelayPublishFetch(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(fakeRelay))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	relay, err := Connect(url, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error from Connect(%s): %v", url, err)
+	}
+	defer relay.Close()
+
+	ev := signedEvent(t)
+
+	// when
+	result, err := relay.Publish(ev)
+	if err != nil {
+		t.Fatalf("Unexpected error from Publish: %v", err)
+	}
+
+	// then
+	if !result.Accepted {
+		t.Fatalf("Publish() accepted = false, want true")
+	}
+	if len(result.Notices) != 1 || result.Notices[0] != "welcome" {
+		t.Fatalf("Publish() notices = %v, want [welcome]", result.Notices)
+	}
+
+	events, err := relay.Fetch(Filter{Authors: []string{ev.PubKey}})
+	if err != nil {
+		t.Fatalf("Unexpected error from Fetch: %v", err)
+	}
+	if len(events) != 1 || events[0].ID != ev.ID {
+		t.Fatalf("Fetch() = %v, want the published event", events)
+	}
+	if ok, err := events[0].Verify(); !ok {
+		t.Fatalf("Verify() on fetched event = %v, %v, want true", ok, err)
+	}
+}
+
+func TestDirectMessage(t *testing.T) {
+	// given
+	alice, _ := hex.DecodeString(testPrivKey)
+	bob, _ := hex.DecodeString("C90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B14E5C9")
+	alicePub, _ := PublicKey(alice)
+	bobPub, _ := PublicKey(bob)
+
+	// when
+	ev, err := NewDirectMessage(alice, bobPub, "round 1 nonce", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDirectMessage: %v", err)
+	}
+
+	// then
+	if ok, err := ev.Verify(); !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+	if strings.Contains(ev.Content, "nonce") {
+		t.Fatalf("Content = %s, want it encrypted", ev.Content)
+	}
+
+	text, err := Decrypt(bob, alicePub, ev.Content)
+	if err != nil {
+		t.Fatalf("Unexpected error from Decrypt: %v", err)
+	}
+	if text != "round 1 nonce" {
+		t.Fatalf("Decrypt() = %q, want %q", text, "round 1 nonce")
+	}
+
+	t.Run("Others cannot read it", func(t *testing.T) {
+		if text, err := Decrypt(alice, alicePub, ev.Content); err == nil && text == "round 1 nonce" {
+			t.Fatalf("Decrypt() with the wrong key = %q, want failure", text)
+		}
+	})
+}
+
+func TestNIP19(t *testing.T) {
+	// given the examples of NIP-19
+	npub := "npub10elfcs4fr0l0r8af98jlmgdh9c8tcxjvz9qkw038js35mp4dma8qzvjptg"
+	pubkey := "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+	nsec := "nsec1vl029mgpspedva04g90vltkh6fvh240zqtv9k0t9af8935ke9laqsnlfe5"
+	privkey := "67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa"
+
+	// when
+	decodedPub, err := DecodePublicKey(npub)
+	if err != nil {
+		t.Fatalf("Unexpected error from DecodePublicKey: %v", err)
+	}
+	decodedPriv, err := DecodePrivateKey(nsec)
+	if err != nil {
+		t.Fatalf("Unexpected error from DecodePrivateKey: %v", err)
+	}
+	encodedPub, _ := EncodePublicKey(pubkey)
+	raw, _ := hex.DecodeString(privkey)
+	encodedPriv, _ := EncodePrivateKey(raw)
+
+	// then
+	if decodedPub != pubkey {
+		t.Fatalf("DecodePublicKey() = %s, want %s", decodedPub, pubkey)
+	}
+	if hex.EncodeToString(decodedPriv) != privkey {
+		t.Fatalf("DecodePrivateKey() = %x, want %s", decodedPriv, privkey)
+	}
+	if encodedPub != npub {
+		t.Fatalf("EncodePublicKey() = %s, want %s", encodedPub, npub)
+	}
+	if encodedPriv != nsec {
+		t.Fatalf("EncodePrivateKey() = %s, want %s", encodedPriv, nsec)
+	}
+
+	t.Run("An nsec is not an npub", func(t *testing.T) {
+		if _, err := DecodePublicKey(nsec); err == nil {
+			t.Fatalf("DecodePublicKey(nsec) succeeded, want an error")
+		}
+	})
+}
+
+func TestParseEvent(t *testing.T) {
+	// given an unsigned event without a time or tags
+	data := []byte(`{"kind":1,"content":"gm"}`)
+
+	// when
+	ev, err := ParseEvent(data)
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseEvent: %v", err)
+	}
+
+	// then
+	if ev.Kind != 1 || ev.Content != "gm" || ev.CreatedAt == 0 || ev.Tags == nil {
+		t.Fatalf("ParseEvent() = %+v, want kind 1 stamped now with no tags", ev)
+	}
+
+	t.Run("Times and tags given are kept", func(t *testing.T) {
+		ev, err := ParseEvent([]byte(`{"kind":7,"created_at":1700000000,"tags":[["e","abc"]],"content":"+"}`))
+		if err != nil || ev.CreatedAt != 1700000000 || len(ev.Tags) != 1 {
+			t.Fatalf("ParseEvent() = %+v, %v, want the time and tag kept", ev, err)
+		}
+	})
+
+	t.Run("The kind is required", func(t *testing.T) {
+		if _, err := ParseEvent([]byte(`{"content":"gm"}`)); err == nil {
+			t.Fatalf("ParseEvent() without a kind succeeded, want an error")
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package nostr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// DefaultTimeout is how long to wait on a relay before giving up
+const DefaultTimeout = 10 * time.Second
+
+// Filter is a NIP-01 subscription filter
+type Filter struct {
+	IDs     []string `json:"ids,omitempty"`
+	Authors []string `json:"authors,omitempty"`
+	Kinds   []int    `json:"kinds,omitempty"`
+	P       []string `json:"#p,omitempty"`
+	T       []string `json:"#t,omitempty"`
+	Since   int64    `json:"since,omitempty"`
+	Until   int64    `json:"until,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+}
+
+// PublishResult is the answer a relay gave to an EVENT message
+type PublishResult struct {
+	Relay    string
+	EventID  string
+	Accepted bool
+	Message  string
+	Notices  []string
+}
+
+// Relay is a connection to a single nostr relay
+type Relay struct {
+	URL     string
+	Timeout time.Duration
+
+	conn    *websocket.Conn
+	notices []string
+}
+
+// Connect opens a websocket to the relay at url
+func Connect(url string, timeout time.Duration) (*Relay, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	config, err := websocket.NewConfig(url, "http://localhost/")
+	if err != nil {
+		return nil, err
+	}
+	config.Dialer = &net.Dialer{Timeout: timeout}
+
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", url, err)
+	}
+
+	return &Relay{URL: url, Timeout: timeout, conn: conn}, nil
+}
+
+// Close closes the connection to the relay
+func (r *Relay) Close() error {
+	return r.conn.Close()
+}
+
+// Publish sends the event to the relay and waits for its OK message. Any
+// NOTICE messages received while waiting are returned in the result.
+func (r *Relay) Publish(ev *Event) (*PublishResult, error) {
+	result := &PublishResult{Relay: r.URL, EventID: ev.ID}
+
+	if err := r.send("EVENT", ev); err != nil {
+		return result, err
+	}
+
+	for {
+		msg, err := r.receive()
+		if err != nil {
+			result.Notices = r.takeNotices()
+			return result, err
+		}
+
+		// ["OK", <event id>, <true|false>, <message>]
+		if label(msg) != "OK" || len(msg) < 3 {
+			continue
+		}
+
+		var id string
+		if err := json.Unmarshal(msg[1], &id); err != nil || id != ev.ID {
+			continue
+		}
+		if err := json.Unmarshal(msg[2], &result.Accepted); err != nil {
+			return result, fmt.Errorf("malformed OK message from %s: %v", r.URL, err)
+		}
+		if len(msg) > 3 {
+			json.Unmarshal(msg[3], &result.Message)
+		}
+
+		result.Notices = r.takeNotices()
+		return result, nil
+	}
+}
+
+// Fetch subscribes with the filter and collects events until the relay
+// signals the end of stored events, then closes the subscription
+func (r *Relay) Fetch(filter Filter) ([]*Event, error) {
+	subID := fmt.Sprintf("schnorr-go-%d", time.Now().UnixNano())
+
+	if err := r.send("REQ", subID, filter); err != nil {
+		return nil, err
+	}
+	defer r.send("CLOSE", subID)
+
+	events := []*Event{}
+	for {
+		msg, err := r.receive()
+		if err != nil {
+			return events, err
+		}
+
+		switch label(msg) {
+		case "EVENT":
+			// ["EVENT", <subscription id>, <event>]
+			if len(msg) < 3 {
+				continue
+			}
+			ev := new(Event)
+			if err := json.Unmarshal(msg[2], ev); err != nil {
+				return events, fmt.Errorf("malformed event from %s: %v", r.URL, err)
+			}
+			events = append(events, ev)
+		case "EOSE", "CLOSED":
+			return events, nil
+		}
+	}
+}
+
+// Notices returns the NOTICE messages received but not yet reported
+func (r *Relay) Notices() []string {
+	return r.takeNotices()
+}
+
+func (r *Relay) takeNotices() []string {
+	notices := r.notices
+	r.notices = nil
+	return notices
+}
+
+func (r *Relay) send(v ...interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	r.conn.SetWriteDeadline(time.Now().Add(r.Timeout))
+	return websocket.Message.Send(r.conn, string(data))
+}
+
+// receive reads the next relay message, stashing away NOTICE messages
+func (r *Relay) receive() ([]json.RawMessage, error) {
+	for {
+		var data string
+
+		r.conn.SetReadDeadline(time.Now().Add(r.Timeout))
+		if err := websocket.Message.Receive(r.conn, &data); err != nil {
+			return nil, fmt.Errorf("failed to read from %s: %v", r.URL, err)
+		}
+
+		msg := []json.RawMessage{}
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil, fmt.Errorf("malformed message from %s: %v", r.URL, err)
+		}
+
+		if label(msg) == "NOTICE" {
+			var notice string
+			if len(msg) > 1 {
+				json.Unmarshal(msg[1], &notice)
+			}
+			r.notices = append(r.notices, notice)
+			continue
+		}
+
+		return msg, nil
+	}
+}
+
+func label(msg []json.RawMessage) string {
+	var l string
+	if len(msg) > 0 {
+		json.Unmarshal(msg[0], &l)
+	}
+	return l
+}
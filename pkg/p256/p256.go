@@ -0,0 +1,120 @@
+package p256
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/drbg"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// EC-SDSA over NIST P-256 as in BSI TR-03111 section 4.2.3 and
+// ISO/IEC 14888-3, for places where secp256k1 is not allowed.
+//
+// The signature is r || s where r = sha256(Qx || Qy || m) is the challenge
+// itself rather than a point, so it is still 64 bytes like the secp256k1
+// signatures in pkg/schnorr.
+//
+
+var Curve = elliptic.P256()
+
+var nonceTag = []byte("schnorr-go/p256/nonce")
+
+// PublicKey returns the compressed public key of the private key
+func PublicKey(privatekey *big.Int) ([33]byte, error) {
+	var publickey [33]byte
+
+	if err := checkPrivateKey(privatekey); err != nil {
+		return publickey, err
+	}
+
+	px, py := Curve.ScalarBaseMult(schnorr.GetBigIntBytesImmutable(privatekey))
+	copy(publickey[:], elliptic.MarshalCompressed(Curve, px, py))
+	return publickey, nil
+}
+
+// s = k + r*d
+func Sign(privatekey *big.Int, message [32]byte) ([64]byte, error) {
+	signature := [64]byte{}
+
+	if err := checkPrivateKey(privatekey); err != nil {
+		return signature, err
+	}
+
+	d := schnorr.GetBigIntBytesImmutable(privatekey)
+
+	// the nonce is derived from the key and message like RFC 6979 so the
+	// same message always gets the same signature
+	k, err := drbg.New(append(d, message[:]...), nonceTag).Scalar(Curve.Params().N)
+	if err != nil {
+		return signature, err
+	}
+
+	qx, qy := Curve.ScalarBaseMult(schnorr.GetBigIntBytesImmutable(k))
+	r := getR(qx, qy, message)
+
+	s := new(big.Int).Mul(r, privatekey)
+	s.Add(s, k)
+	s.Mod(s, Curve.Params().N)
+
+	copy(signature[:32], schnorr.GetBigIntBytesImmutable(r))
+	copy(signature[32:], schnorr.GetBigIntBytesImmutable(s))
+
+	return signature, nil
+}
+
+func Verify(publickey [33]byte, message [32]byte, signature [64]byte) (bool, error) {
+	N := Curve.Params().N
+
+	px, py := elliptic.UnmarshalCompressed(Curve, publickey[:])
+	if px == nil || py == nil {
+		return false, fmt.Errorf("px or py was unmarshalled to nil")
+	}
+
+	r := new(big.Int).SetBytes(signature[:32])
+	if r.Sign() == 0 || r.Cmp(N) >= 0 {
+		return false, fmt.Errorf("r is not in the range 1..n-1")
+	}
+
+	s := new(big.Int).SetBytes(signature[32:])
+	if s.Sign() == 0 || s.Cmp(N) >= 0 {
+		return false, fmt.Errorf("s is not in the range 1..n-1")
+	}
+
+	// Q = s*G - r*P
+	sgx, sgy := Curve.ScalarBaseMult(signature[32:])
+	negR := new(big.Int).Sub(N, r)
+	rpx, rpy := Curve.ScalarMult(px, py, schnorr.GetBigIntBytesImmutable(negR))
+	qx, qy := Curve.Add(sgx, sgy, rpx, rpy)
+
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return false, fmt.Errorf("sign with q[x|y] is 0 indicating the result is 0")
+	}
+
+	if getR(qx, qy, message).Cmp(r) != 0 {
+		return false, fmt.Errorf("r does not match the recomputed challenge")
+	}
+
+	return true, nil
+}
+
+func checkPrivateKey(privatekey *big.Int) error {
+	if privatekey.Cmp(big.NewInt(1)) < 0 || privatekey.Cmp(new(big.Int).Sub(Curve.Params().N, big.NewInt(1))) > 0 {
+		return fmt.Errorf("private key must be an integer between 1 and %d", Curve.Params().N)
+	}
+	return nil
+}
+
+// Calculate the challenge. r = hash(Qx || Qy || m) reduced modulo n
+func getR(qx, qy *big.Int, m [32]byte) *big.Int {
+	h := sha256.New()
+	h.Write(schnorr.GetBigIntBytesImmutable(qx))
+	h.Write(schnorr.GetBigIntBytesImmutable(qy))
+	h.Write(m[:])
+
+	i := new(big.Int).SetBytes(h.Sum(nil))
+	return i.Mod(i, Curve.Params().N)
+}
@@ -0,0 +1,63 @@
+package p256
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	d, _ := new(big.Int).SetString("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721", 16)
+	m := [32]byte{0x24, 0x3f, 0x6a, 0x88}
+
+	pk, err := PublicKey(d)
+	if err != nil {
+		t.Fatalf("Unexpected error from PublicKey: %v", err)
+	}
+
+	// when
+	sig, err := Sign(d, m)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	// then
+	again, _ := Sign(d, m)
+	if again != sig {
+		t.Fatalf("Sign is not deterministic")
+	}
+
+	ok, err := Verify(pk, m, sig)
+	if err != nil || !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+
+	t.Run("Other message fails", func(t *testing.T) {
+		other := m
+		other[31] = 1
+		if ok, _ := Verify(pk, other, sig); ok {
+			t.Fatalf("Verify() of another message = true, want false")
+		}
+	})
+
+	t.Run("Tampered s fails", func(t *testing.T) {
+		bad := sig
+		bad[63] ^= 1
+		if ok, _ := Verify(pk, m, bad); ok {
+			t.Fatalf("Verify() with a tampered s = true, want false")
+		}
+	})
+
+	t.Run("Zero key is rejected", func(t *testing.T) {
+		if _, err := Sign(big.NewInt(0), m); err == nil {
+			t.Fatalf("Sign with a zero key succeeded, want error")
+		}
+	})
+
+	t.Run("Invalid public key is rejected", func(t *testing.T) {
+		bad := pk
+		bad[0] = 4
+		if _, err := Verify(bad, m, sig); err == nil {
+			t.Fatalf("Verify with a bad public key succeeded, want error")
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package pipe
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Signing and verifying as a filter process: requests come in as one JSON
+// object per line and a response goes out for each, in order, as a line of
+// its own. A program embedding the tool starts it once and keeps writing
+// to it, rather than paying for a process per signature.
+//
+//	{"id":1,"op":"sign","message":"hello"}
+//	{"id":1,"signature":"..."}
+//
+// The key is given when the process starts and never goes over the pipe.
+//
+
+// MaxLine is the longest request line read, 16 MiB
+const MaxLine = 16 << 20
+
+// Request is one line of input. Op is sign, verify or pubkey. The message
+// is given as text, or as the hex digest to sign as it is.
+type Request struct {
+	ID        json.RawMessage `json:"id,omitempty"`
+	Op        string          `json:"op"`
+	Message   *string         `json:"message,omitempty"`
+	Digest    string          `json:"digest,omitempty"`
+	PublicKey string          `json:"pubkey,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+	Scheme    string          `json:"scheme,omitempty"`
+}
+
+// Response is one line of output, carrying the id of its request. Verify
+// requests are answered with Valid, and the reason in Error if false.
+type Response struct {
+	ID        json.RawMessage `json:"id,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+	PublicKey string          `json:"pubkey,omitempty"`
+	Valid     *bool           `json:"valid,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Server answers requests. Without a PrivateKey only verify requests are
+// answered. Messages are hashed with Hash, and signed and verified in
+// Scheme unless a request names another.
+type Server struct {
+	PrivateKey *big.Int
+	Scheme     schnorr.Scheme
+	Hash       func([]byte) [32]byte
+}
+
+// Serve answers the requests read from r on w until r ends. A line that
+// isn't a request gets a response with an error, so only failing to read
+// or write stops it.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MaxLine)
+	enc := json.NewEncoder(w)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req Request
+		var resp *Response
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp = &Response{Error: fmt.Sprintf("request is not json: %v", err)}
+		} else {
+			resp = s.Handle(&req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Handle answers one request
+func (s *Server) Handle(req *Request) *Response {
+	resp, err := s.handle(req)
+	if err != nil {
+		resp = &Response{Error: err.Error()}
+	}
+	resp.ID = req.ID
+	return resp
+}
+
+func (s *Server) handle(req *Request) (*Response, error) {
+	scheme := s.Scheme
+	if req.Scheme != "" {
+		var err error
+		if scheme, err = schnorr.ParseScheme(req.Scheme); err != nil {
+			return nil, err
+		}
+	}
+
+	switch req.Op {
+	case "pubkey":
+		key, err := s.key()
+		if err != nil {
+			return nil, err
+		}
+		return &Response{PublicKey: key.PublicKey().String()}, nil
+	case "sign":
+		key, err := s.key()
+		if err != nil {
+			return nil, err
+		}
+		digest, err := s.digest(req)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := schnorr.SignScheme(scheme, key.D(), digest)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Signature: hex.EncodeToString(signature[:])}, nil
+	case "verify":
+		digest, err := s.digest(req)
+		if err != nil {
+			return nil, err
+		}
+		publickey, err := hex.DecodeString(req.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("pubkey is not hex")
+		}
+		raw, err := hex.DecodeString(req.Signature)
+		if err != nil || len(raw) != 64 {
+			return nil, fmt.Errorf("signature is not 64 bytes of hex")
+		}
+		var signature [64]byte
+		copy(signature[:], raw)
+		// a signature that doesn't verify is an answer, not a failure
+		valid, err := schnorr.VerifyScheme(scheme, publickey, digest, signature)
+		resp := &Response{Valid: &valid}
+		if !valid && err != nil {
+			resp.Error = err.Error()
+		}
+		return resp, nil
+	case "":
+		return nil, fmt.Errorf("request has no op")
+	}
+	return nil, fmt.Errorf("unknown op %q, want sign, verify or pubkey", req.Op)
+}
+
+func (s *Server) key() (*schnorr.PrivateKey, error) {
+	if s.PrivateKey == nil {
+		return nil, fmt.Errorf("no private key was given, only verify requests are answered")
+	}
+	return schnorr.NewPrivateKey(s.PrivateKey)
+}
+
+func (s *Server) digest(req *Request) ([32]byte, error) {
+	var digest [32]byte
+	switch {
+	case req.Message != nil && req.Digest != "":
+		return digest, fmt.Errorf("give message or digest, not both")
+	case req.Message != nil:
+		return s.Hash([]byte(*req.Message)), nil
+	case req.Digest != "":
+		raw, err := hex.DecodeString(req.Digest)
+		if err != nil || len(raw) != 32 {
+			return digest, fmt.Errorf("digest is not 32 bytes of hex")
+		}
+		copy(digest[:], raw)
+		return digest, nil
+	}
+	return digest, fmt.Errorf("request has no message or digest")
+}
@@ -0,0 +1,70 @@
+package pipe
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestServe(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("pipe"), 1)
+	s := &Server{PrivateKey: keys[0].PrivateKey, Scheme: schnorr.SchemeBIP340, Hash: sha256.Sum256}
+
+	// given a sign request, a line that's not json and a blank line
+	in := `{"id":1,"op":"sign","message":"hello"}` + "\n" + "not json\n\n" + `{"id":"b","op":"pubkey"}` + "\n"
+
+	// when
+	var out bytes.Buffer
+	if err := s.Serve(strings.NewReader(in), &out); err != nil {
+		t.Fatalf("Unexpected error from Serve: %v", err)
+	}
+
+	// then there's a response for each request, in order
+	var responses []Response
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("Unexpected error from Decode: %v", err)
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3", len(responses))
+	}
+	if string(responses[0].ID) != "1" || responses[0].Signature == "" {
+		t.Fatalf("responses[0] = %+v, want a signature for id 1", responses[0])
+	}
+	if responses[1].Error == "" {
+		t.Fatalf("responses[1] = %+v, want an error", responses[1])
+	}
+	if want := fmt.Sprintf("%x", keys[0].PublicKey); string(responses[2].ID) != `"b"` || responses[2].PublicKey != want {
+		t.Fatalf("responses[2] = %+v, want public key %s for id b", responses[2], want)
+	}
+
+	t.Run("The signature verifies", func(t *testing.T) {
+		message := "hello"
+		resp := s.Handle(&Request{Op: "verify", Message: &message, PublicKey: responses[2].PublicKey, Signature: responses[0].Signature})
+		if resp.Valid == nil || !*resp.Valid {
+			t.Fatalf("Handle(verify) = %+v, want valid", resp)
+		}
+
+		other := "hello!"
+		resp = s.Handle(&Request{Op: "verify", Message: &other, PublicKey: responses[2].PublicKey, Signature: responses[0].Signature})
+		if resp.Valid == nil || *resp.Valid {
+			t.Fatalf("Handle(verify) of another message = %+v, want invalid", resp)
+		}
+	})
+
+	t.Run("Without a key only verify is answered", func(t *testing.T) {
+		resp := (&Server{Scheme: schnorr.SchemeBIP340, Hash: sha256.Sum256}).Handle(&Request{Op: "sign", Digest: strings.Repeat("00", 32)})
+		if resp.Error == "" {
+			t.Fatalf("Handle(sign) = %+v, want an error", resp)
+		}
+	})
+}
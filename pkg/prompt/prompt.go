@@ -0,0 +1,151 @@
+package prompt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrNotInteractive is returned when a question needs a person at a
+// terminal and there isn't one
+var ErrNotInteractive = errors.New("not running in a terminal")
+
+// Prompter asks questions on In and writes the prompts to Out. Secrets are
+// read without echo when In is a terminal, and as a plain line when it is a
+// pipe, so scripts can still feed them in without putting them in argv.
+type Prompter struct {
+	In  io.Reader
+	Out io.Writer
+
+	reader *bufio.Reader
+}
+
+// stdin buffers piped input for every Prompter on it, so a line one has
+// read ahead isn't lost to the next
+var stdin = bufio.NewReader(os.Stdin)
+
+// Stdin returns stdin as the prompters read it, for reading the rest of it
+// once they are done without losing what they buffered
+func Stdin() io.Reader {
+	return stdin
+}
+
+// New prompts on stdin, writing to stderr so stdout stays clean for output
+func New() *Prompter {
+	return &Prompter{In: os.Stdin, Out: os.Stderr}
+}
+
+// IsTerminal reports whether the file is a terminal
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// Interactive reports whether In is a terminal
+func (p *Prompter) Interactive() bool {
+	f, ok := p.In.(*os.File)
+	return ok && IsTerminal(f)
+}
+
+func (p *Prompter) line() (string, error) {
+	if p.reader == nil && p.In == io.Reader(os.Stdin) {
+		p.reader = stdin
+	} else if p.reader == nil {
+		p.reader = bufio.NewReader(p.In)
+	}
+	line, err := p.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Line asks for a line of text
+func (p *Prompter) Line(prompt string) (string, error) {
+	fmt.Fprint(p.Out, prompt)
+	return p.line()
+}
+
+// Secret asks for a secret, without echoing it on a terminal
+func (p *Prompter) Secret(prompt string) ([]byte, error) {
+	fmt.Fprint(p.Out, prompt)
+
+	if p.Interactive() {
+		secret, err := term.ReadPassword(int(p.In.(*os.File).Fd()))
+		fmt.Fprintln(p.Out)
+		return secret, err
+	}
+
+	line, err := p.line()
+	return []byte(line), err
+}
+
+// NewPassphrase asks for a passphrase twice and checks both match. Piped
+// input is only read once, since a script can't mistype.
+func (p *Prompter) NewPassphrase(prompt string) ([]byte, error) {
+	passphrase, err := p.Secret(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase is empty")
+	}
+	if !p.Interactive() {
+		return passphrase, nil
+	}
+
+	again, err := p.Secret("Repeat " + strings.ToLower(prompt[:1]) + prompt[1:])
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, again) {
+		return nil, fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// Confirm asks a yes/no question before a high risk operation. When
+// assumeYes is set, from a -yes flag, it doesn't ask. Without a terminal it
+// refuses with ErrNotInteractive rather than guess, so scripts have to opt
+// in explicitly.
+func (p *Prompter) Confirm(question string, assumeYes bool) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+	if !p.Interactive() {
+		return false, fmt.Errorf("%w: pass -yes to confirm %q", ErrNotInteractive, question)
+	}
+
+	answer, err := p.Line(question + " [y/N] ")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// SecretFlag returns the value of a secret flag, asking for it when the flag
+// was left empty. Passing secrets as flags puts them in shell history and
+// the process list, so prompting is the way to go.
+func (p *Prompter) SecretFlag(value, prompt string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	secret, err := p.Secret(prompt)
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSpace(string(secret))
+	if s == "" {
+		return "", fmt.Errorf("nothing entered")
+	}
+	return s, nil
+}
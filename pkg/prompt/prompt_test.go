@@ -0,0 +1,60 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func piped(input string) (*Prompter, *bytes.Buffer) {
+	out := new(bytes.Buffer)
+	return &Prompter{In: strings.NewReader(input), Out: out}, out
+}
+
+func TestPiped(t *testing.T) {
+	// given
+	p, out := piped("hunter2\nsecond line\n")
+
+	// then
+	if p.Interactive() {
+		t.Fatalf("Interactive() = true for a pipe, want false")
+	}
+
+	secret, err := p.Secret("Passphrase: ")
+	if err != nil || string(secret) != "hunter2" {
+		t.Fatalf("Secret() = %q, %v, want hunter2", secret, err)
+	}
+	line, err := p.Line("Name: ")
+	if err != nil || line != "second line" {
+		t.Fatalf("Line() = %q, %v, want second line", line, err)
+	}
+	if out.String() != "Passphrase: Name: " {
+		t.Fatalf("prompts = %q, want both prompts", out.String())
+	}
+
+	t.Run("Last line without a newline", func(t *testing.T) {
+		p, _ := piped("abc")
+		if s, err := p.SecretFlag("", "Key: "); err != nil || s != "abc" {
+			t.Fatalf("SecretFlag() = %q, %v, want abc", s, err)
+		}
+	})
+
+	t.Run("Flag value wins", func(t *testing.T) {
+		p, out := piped("")
+		if s, err := p.SecretFlag("from-flag", "Key: "); err != nil || s != "from-flag" || out.Len() != 0 {
+			t.Fatalf("SecretFlag() = %q, %v, want from-flag without a prompt", s, err)
+		}
+	})
+}
+
+func TestConfirm(t *testing.T) {
+	p, _ := piped("y\n")
+
+	if _, err := p.Confirm("Delete the key?", false); !errors.Is(err, ErrNotInteractive) {
+		t.Fatalf("Confirm() without a terminal = %v, want ErrNotInteractive", err)
+	}
+	if ok, err := p.Confirm("Delete the key?", true); !ok || err != nil {
+		t.Fatalf("Confirm() with assumeYes = %v, %v, want true", ok, err)
+	}
+}
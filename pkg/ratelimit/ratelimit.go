@@ -0,0 +1,247 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Token buckets with daily quotas, per signing key and per client principal.
+// A request is only let through if every limit that applies to it has room,
+// and then counts against all of them, so a client hitting its own limit
+// does not use up the key's.
+//
+
+// Limit is how fast and how much something may sign. Rate is in signatures
+// per second with bursts of up to Burst, Daily is the number of signatures
+// per UTC day. Zero means no limit.
+type Limit struct {
+	Rate  float64 `json:"rate,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+	Daily int     `json:"daily,omitempty"`
+}
+
+// ParseLimit reads rate:burst:daily, such as 5:20:1000, where any part
+// may be left empty or 0 for no limit
+func ParseLimit(s string) (Limit, error) {
+	var l Limit
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return l, fmt.Errorf("limit %q is not rate:burst:daily", s)
+	}
+	var err error
+	if parts[0] != "" {
+		if l.Rate, err = strconv.ParseFloat(parts[0], 64); err != nil || l.Rate < 0 {
+			return l, fmt.Errorf("limit %q has a bad rate", s)
+		}
+	}
+	if parts[1] != "" {
+		if l.Burst, err = strconv.Atoi(parts[1]); err != nil || l.Burst < 0 {
+			return l, fmt.Errorf("limit %q has a bad burst", s)
+		}
+	}
+	if parts[2] != "" {
+		if l.Daily, err = strconv.Atoi(parts[2]); err != nil || l.Daily < 0 {
+			return l, fmt.Errorf("limit %q has a bad daily quota", s)
+		}
+	}
+	return l, nil
+}
+
+// Scope says what a limit applies to
+type Scope string
+
+const (
+	ScopeKey       Scope = "key"
+	ScopePrincipal Scope = "principal"
+)
+
+// LimitedError is returned when a limit has no room. RetryAfter is how
+// long until it will, a whole day at most for quotas.
+type LimitedError struct {
+	Scope      Scope
+	Name       string
+	Quota      bool
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	what := "rate limit"
+	if e.Quota {
+		what = "daily quota"
+	}
+	return fmt.Sprintf("%s %s reached its %s, retry after %s", e.Scope, e.Name, what, e.RetryAfter.Round(time.Second))
+}
+
+// Stats counts the decisions for one key or principal
+type Stats struct {
+	Allowed uint64 `json:"allowed"`
+	Limited uint64 `json:"limited"`
+	Today   int    `json:"today"`
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+	day    time.Time
+	used   int
+	stats  Stats
+}
+
+// refill adds the tokens earned since last and resets the quota on a new day
+func (b *bucket) refill(now time.Time) {
+	if b.last.IsZero() {
+		b.tokens = float64(b.burst())
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+		if max := float64(b.burst()); b.tokens > max {
+			b.tokens = max
+		}
+	}
+	b.last = now
+
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(b.day) {
+		b.day = day
+		b.used = 0
+	}
+}
+
+func (b *bucket) burst() int {
+	if b.limit.Burst < 1 {
+		return 1
+	}
+	return b.limit.Burst
+}
+
+// check says why the bucket can't take one more, nil if it can
+func (b *bucket) check(scope Scope, name string, now time.Time) *LimitedError {
+	if b.limit.Daily > 0 && b.used >= b.limit.Daily {
+		return &LimitedError{Scope: scope, Name: name, Quota: true, RetryAfter: b.day.Add(24 * time.Hour).Sub(now)}
+	}
+	if b.limit.Rate > 0 && b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+		return &LimitedError{Scope: scope, Name: name, RetryAfter: wait}
+	}
+	return nil
+}
+
+func (b *bucket) take() {
+	if b.limit.Rate > 0 {
+		b.tokens--
+	}
+	b.used++
+	b.stats.Allowed++
+}
+
+// Limiter applies Key to every signing key and Principal to every client,
+// with per-name overrides. It is safe for concurrent use.
+type Limiter struct {
+	Key       Limit
+	Principal Limit
+
+	KeyOverrides       map[string]Limit
+	PrincipalOverrides map[string]Limit
+
+	mu      sync.Mutex
+	buckets map[Scope]map[string]*bucket
+	now     func() time.Time
+}
+
+// New limits every key and every principal separately
+func New(key, principal Limit) *Limiter {
+	return &Limiter{
+		Key:                key,
+		Principal:          principal,
+		KeyOverrides:       map[string]Limit{},
+		PrincipalOverrides: map[string]Limit{},
+		buckets:            map[Scope]map[string]*bucket{},
+		now:                time.Now,
+	}
+}
+
+// LimitFor returns the limit that applies to the key or principal
+func (l *Limiter) LimitFor(scope Scope, name string) Limit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit(scope, name)
+}
+
+func (l *Limiter) limit(scope Scope, name string) Limit {
+	if scope == ScopeKey {
+		if limit, ok := l.KeyOverrides[name]; ok {
+			return limit
+		}
+		return l.Key
+	}
+	if limit, ok := l.PrincipalOverrides[name]; ok {
+		return limit
+	}
+	return l.Principal
+}
+
+func (l *Limiter) bucket(scope Scope, name string) *bucket {
+	if l.buckets[scope] == nil {
+		l.buckets[scope] = map[string]*bucket{}
+	}
+	b, ok := l.buckets[scope][name]
+	if !ok {
+		b = &bucket{}
+		l.buckets[scope][name] = b
+	}
+	b.limit = l.limit(scope, name)
+	return b
+}
+
+// Allow takes one signature from the key's and the principal's limits, or
+// returns a *LimitedError without taking from either
+func (l *Limiter) Allow(key, principal string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	keyBucket := l.bucket(ScopeKey, key)
+	principalBucket := l.bucket(ScopePrincipal, principal)
+	keyBucket.refill(now)
+	principalBucket.refill(now)
+
+	// the principal is checked first so that a client over its own limit
+	// shows up as such rather than as pressure on the key
+	if err := principalBucket.check(ScopePrincipal, principal, now); err != nil {
+		principalBucket.stats.Limited++
+		return err
+	}
+	if err := keyBucket.check(ScopeKey, key, now); err != nil {
+		keyBucket.stats.Limited++
+		principalBucket.stats.Limited++
+		return err
+	}
+
+	keyBucket.take()
+	principalBucket.take()
+	return nil
+}
+
+// Stats returns the counters of every key and principal seen so far, by
+// scope and name
+func (l *Limiter) Stats() map[Scope]map[string]Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	stats := map[Scope]map[string]Stats{}
+	for scope, buckets := range l.buckets {
+		stats[scope] = map[string]Stats{}
+		for name, b := range buckets {
+			b.refill(now)
+			s := b.stats
+			s.Today = b.used
+			stats[scope][name] = s
+		}
+	}
+	return stats
+}
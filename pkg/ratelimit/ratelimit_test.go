@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 59, 0, 0, time.UTC)
+	newLimiter := func(key, principal Limit) *Limiter {
+		l := New(key, principal)
+		l.now = func() time.Time { return now }
+		return l
+	}
+	limited := func(err error, scope Scope, quota bool) bool {
+		e, ok := err.(*LimitedError)
+		return ok && e.Scope == scope && e.Quota == quota && e.RetryAfter > 0
+	}
+
+	t.Run("Burst then rate", func(t *testing.T) {
+		l := newLimiter(Limit{Rate: 1, Burst: 3}, Limit{})
+		for i := 0; i < 3; i++ {
+			if err := l.Allow("release", "alice"); err != nil {
+				t.Fatalf("Unexpected error from Allow %d: %v", i, err)
+			}
+		}
+		if err := l.Allow("release", "alice"); !limited(err, ScopeKey, false) {
+			t.Fatalf("Allow past the burst = %v, want a key rate limit", err)
+		}
+
+		// other keys have their own buckets
+		if err := l.Allow("staging", "alice"); err != nil {
+			t.Fatalf("Unexpected error from Allow on another key: %v", err)
+		}
+
+		now = now.Add(time.Second)
+		if err := l.Allow("release", "alice"); err != nil {
+			t.Fatalf("Unexpected error from Allow a second later: %v", err)
+		}
+	})
+
+	t.Run("Daily quota resets at midnight", func(t *testing.T) {
+		now = time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+		l := newLimiter(Limit{Daily: 2}, Limit{})
+		l.Allow("release", "alice")
+		l.Allow("release", "bob")
+		if err := l.Allow("release", "carol"); !limited(err, ScopeKey, true) {
+			t.Fatalf("Allow past the quota = %v, want a key quota", err)
+		}
+
+		now = now.Add(time.Hour)
+		if err := l.Allow("release", "carol"); err != nil {
+			t.Fatalf("Unexpected error from Allow the next day: %v", err)
+		}
+	})
+
+	t.Run("Principal limit leaves the key alone", func(t *testing.T) {
+		l := newLimiter(Limit{Daily: 3}, Limit{Daily: 1})
+		l.PrincipalOverrides["ci"] = Limit{Daily: 2}
+
+		if err := l.Allow("release", "alice"); err != nil {
+			t.Fatalf("Unexpected error from Allow: %v", err)
+		}
+		if err := l.Allow("release", "alice"); !limited(err, ScopePrincipal, true) {
+			t.Fatalf("Allow past the principal quota = %v, want a principal quota", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := l.Allow("release", "ci"); err != nil {
+				t.Fatalf("Unexpected error from Allow for the override: %v", err)
+			}
+		}
+
+		stats := l.Stats()
+		if s := stats[ScopeKey]["release"]; s.Allowed != 3 || s.Limited != 0 || s.Today != 3 {
+			t.Fatalf("Stats() key = %+v, want 3 allowed and none limited", s)
+		}
+		if s := stats[ScopePrincipal]["alice"]; s.Allowed != 1 || s.Limited != 1 {
+			t.Fatalf("Stats() alice = %+v, want 1 allowed and 1 limited", s)
+		}
+	})
+}
+
+func TestParseLimit(t *testing.T) {
+	observed, err := ParseLimit("2.5::1000")
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseLimit: %v", err)
+	}
+	if observed != (Limit{Rate: 2.5, Daily: 1000}) {
+		t.Fatalf("ParseLimit() = %+v", observed)
+	}
+	for _, s := range []string{"", "1:2", "x::", ":-1:"} {
+		if _, err := ParseLimit(s); err == nil {
+			t.Fatalf("ParseLimit(%q) succeeded, want error", s)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package revocation
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Revocation certificates: a statement that a key must no longer be
+// trusted, signed by that key. Since only the key can sign it, the
+// certificate is made when the key is generated and put away, sealed under
+// a passphrase, so the key can still be revoked after it is lost. Once
+// published, anyone holding it can drop the key, and nobody can forge one
+// for a key they don't hold.
+//
+
+// PayloadType is the envelope payload type of a revocation statement
+const PayloadType = "application/vnd.schnorr-go.revocation+json"
+
+// Reasons, after the ones of RFC 4880
+const (
+	ReasonUnspecified = "unspecified"
+	ReasonCompromised = "compromised"
+	ReasonSuperseded  = "superseded"
+	ReasonRetired     = "retired"
+)
+
+// Statement is what a revocation certificate says
+type Statement struct {
+	PublicKey string `json:"publicKey"`
+	Reason    string `json:"reason"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// Generate makes the revocation certificate for the private key's public
+// key, as an envelope signed by the key itself
+func Generate(privatekey *big.Int, reason, comment string) (*envelope.Envelope, error) {
+	switch reason {
+	case ReasonUnspecified, ReasonCompromised, ReasonSuperseded, ReasonRetired:
+	default:
+		return nil, fmt.Errorf("unknown revocation reason %q", reason)
+	}
+
+	publickey, err := schnorr.ScalarBaseMult(privatekey).PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(Statement{
+		PublicKey: hex.EncodeToString(publickey[:]),
+		Reason:    reason,
+		Comment:   comment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Sign(privatekey, PayloadType, payload)
+}
+
+// Verify checks a certificate is a revocation signed by the key it revokes
+// and returns the statement and that key
+func Verify(cert *envelope.Envelope) (*Statement, [33]byte, error) {
+	var publickey [33]byte
+
+	if cert.PayloadType != PayloadType {
+		return nil, publickey, fmt.Errorf("not a revocation certificate: payload type %q", cert.PayloadType)
+	}
+	s := new(Statement)
+	if err := json.Unmarshal(cert.Payload, s); err != nil {
+		return nil, publickey, fmt.Errorf("revocation statement is not valid json: %v", err)
+	}
+	if s.PublicKey != cert.PublicKey {
+		return nil, publickey, fmt.Errorf("revocation for %s is signed by %s", s.PublicKey, cert.PublicKey)
+	}
+
+	publickey, err := cert.Key()
+	if err != nil {
+		return nil, publickey, err
+	}
+	if err := envelope.Verify(cert, publickey); err != nil {
+		return nil, publickey, err
+	}
+	return s, publickey, nil
+}
+
+// Parse reads and verifies a certificate in its json form
+func Parse(data []byte) (*Statement, [33]byte, error) {
+	cert := new(envelope.Envelope)
+	if err := json.Unmarshal(data, cert); err != nil {
+		return nil, [33]byte{}, fmt.Errorf("revocation certificate is not an envelope: %v", err)
+	}
+	return Verify(cert)
+}
+
+// Set is the keys known to be revoked. It satisfies envelope.Revocations.
+type Set struct {
+	mu      sync.RWMutex
+	revoked map[[33]byte]*Statement
+	certs   map[[33]byte]*envelope.Envelope
+}
+
+func NewSet() *Set {
+	return &Set{revoked: map[[33]byte]*Statement{}, certs: map[[33]byte]*envelope.Envelope{}}
+}
+
+// Add verifies the certificate and marks its key revoked
+func (s *Set) Add(cert *envelope.Envelope) error {
+	statement, publickey, err := Verify(cert)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[publickey] = statement
+	s.certs[publickey] = cert
+	return nil
+}
+
+// Revoked returns the statement revoking the key, nil if it is not revoked
+func (s *Set) Revoked(publickey [33]byte) *Statement {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revoked[publickey]
+}
+
+// IsRevoked is Revoked as a plain yes or no
+func (s *Set) IsRevoked(publickey [33]byte) bool {
+	return s.Revoked(publickey) != nil
+}
+
+// Keys returns the revoked keys in order
+func (s *Set) Keys() [][33]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([][33]byte, 0, len(s.revoked))
+	for k := range s.revoked {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return hex.EncodeToString(keys[i][:]) < hex.EncodeToString(keys[j][:]) })
+	return keys
+}
+
+// Certificates returns the certificates added, in the order of Keys, so the
+// set can be handed on for someone else to check
+func (s *Set) Certificates() []*envelope.Envelope {
+	keys := s.Keys()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	certs := make([]*envelope.Envelope, 0, len(keys))
+	for _, k := range keys {
+		certs = append(certs, s.certs[k])
+	}
+	return certs
+}
+
+// LoadDir adds every *.json certificate in dir. A file that is not a valid
+// certificate is an error rather than skipped, so a corrupted revocation
+// is noticed.
+func (s *Set) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		cert := new(envelope.Envelope)
+		if err := json.Unmarshal(data, cert); err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		if err := s.Add(cert); err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package revocation
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestRevocation(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("revocation"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	signed, err := envelope.Sign(keys[0].PrivateKey, "text/plain", []byte("release 1.0"))
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	// when
+	cert, err := Generate(keys[0].PrivateKey, ReasonCompromised, "laptop stolen")
+	if err != nil {
+		t.Fatalf("Unexpected error from Generate: %v", err)
+	}
+	revoked := NewSet()
+	if err := revoked.Add(cert); err != nil {
+		t.Fatalf("Unexpected error from Add: %v", err)
+	}
+
+	// then
+	if s := revoked.Revoked(keys[0].PublicKey); s == nil || s.Reason != ReasonCompromised {
+		t.Fatalf("Revoked() = %+v, want compromised", s)
+	}
+	if revoked.IsRevoked(keys[1].PublicKey) {
+		t.Fatalf("IsRevoked() on another key = true, want false")
+	}
+	if err := envelope.Verify(signed, keys[0].PublicKey); err != nil {
+		t.Fatalf("Verify() without revocations = %v, want nil", err)
+	}
+	if err := envelope.Verify(signed, keys[0].PublicKey, envelope.RejectRevoked(revoked)); !errors.Is(err, envelope.ErrRevoked) {
+		t.Fatalf("Verify() with the key revoked = %v, want ErrRevoked", err)
+	}
+
+	t.Run("Only the key can revoke itself", func(t *testing.T) {
+		forged, err := Generate(keys[1].PrivateKey, ReasonCompromised, "")
+		if err != nil {
+			t.Fatalf("Unexpected error from Generate: %v", err)
+		}
+		forged.Payload, _ = json.Marshal(Statement{PublicKey: hex.EncodeToString(keys[0].PublicKey[:]), Reason: ReasonCompromised})
+		if err := NewSet().Add(forged); err == nil {
+			t.Fatalf("Add() of a revocation signed by another key = nil, want error")
+		}
+	})
+
+	t.Run("Sealed certificate opens with the passphrase", func(t *testing.T) {
+		data, _ := json.Marshal(cert)
+		sealed, err := Seal(data, cert.PublicKey, []byte("correct horse"))
+		if err != nil {
+			t.Fatalf("Unexpected error from Seal: %v", err)
+		}
+		raw, _ := json.Marshal(sealed)
+		parsed, err := ParseSealed(raw)
+		if err != nil {
+			t.Fatalf("Unexpected error from ParseSealed: %v", err)
+		}
+
+		if _, err := parsed.Open([]byte("wrong horse")); err == nil {
+			t.Fatalf("Open() with the wrong passphrase = nil, want error")
+		}
+		opened, err := parsed.Open([]byte("correct horse"))
+		if err != nil {
+			t.Fatalf("Unexpected error from Open: %v", err)
+		}
+		if _, pk, err := Parse(opened); err != nil || pk != keys[0].PublicKey {
+			t.Fatalf("Parse() of the opened certificate = %x, %v, want the revoked key", pk, err)
+		}
+	})
+
+	t.Run("Certificates load from a directory", func(t *testing.T) {
+		dir := t.TempDir()
+		data, _ := json.Marshal(cert)
+		os.WriteFile(filepath.Join(dir, "key0.json"), data, 0644)
+
+		set := NewSet()
+		if err := set.LoadDir(dir); err != nil {
+			t.Fatalf("Unexpected error from LoadDir: %v", err)
+		}
+		if got := set.Keys(); len(got) != 1 || got[0] != keys[0].PublicKey {
+			t.Fatalf("Keys() = %x, want the revoked key", got)
+		}
+	})
+}
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914 section 11, first 32 bytes
+	key := pbkdf2([]byte("passwd"), []byte("salt"), 1)
+	if hex.EncodeToString(key) != "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" {
+		t.Fatalf("pbkdf2() = %x, want the RFC 7914 vector", key)
+	}
+}
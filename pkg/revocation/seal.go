@@ -0,0 +1,104 @@
+package revocation
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultIterations is the PBKDF2 iteration count for new sealed
+// certificates
+const DefaultIterations = 600000
+
+// Sealed is a certificate encrypted under a passphrase with AES-256-GCM,
+// the key derived with PBKDF2-HMAC-SHA256. Binary fields are base64 in json.
+type Sealed struct {
+	PublicKey  string `json:"publicKey"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Seal encrypts the certificate, which is json, under the passphrase. The
+// public key is left in the clear, and authenticated, so it is clear which
+// key the sealed certificate is for.
+func Seal(cert []byte, publickey string, passphrase []byte) (*Sealed, error) {
+	s := &Sealed{PublicKey: publickey, Iterations: DefaultIterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(s.Salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := s.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	s.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return nil, err
+	}
+	s.Ciphertext = aead.Seal(nil, s.Nonce, cert, []byte(s.PublicKey))
+	return s, nil
+}
+
+// Open decrypts the certificate
+func (s *Sealed) Open(passphrase []byte) ([]byte, error) {
+	if s.Iterations < 1 {
+		return nil, fmt.Errorf("invalid iteration count %d", s.Iterations)
+	}
+	aead, err := s.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("nonce must be %d bytes", aead.NonceSize())
+	}
+	cert, err := aead.Open(nil, s.Nonce, s.Ciphertext, []byte(s.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or damaged certificate")
+	}
+	return cert, nil
+}
+
+// ParseSealed reads a sealed certificate from its json form
+func ParseSealed(data []byte) (*Sealed, error) {
+	s := new(Sealed)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if len(s.Salt) == 0 || len(s.Ciphertext) == 0 {
+		return nil, fmt.Errorf("not a sealed revocation certificate")
+	}
+	return s, nil
+}
+
+func (s *Sealed) aead(passphrase []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2(passphrase, s.Salt, s.Iterations))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 is PBKDF2-HMAC-SHA256 from RFC 8018 for a single 32 byte block
+func pbkdf2(passphrase, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, passphrase)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+
+	key := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
@@ -0,0 +1,303 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+//
+// A small client for the parts of the S3 API that signing needs: listing,
+// reading and writing objects, with AWS Signature Version 4. It works with
+// AWS and the S3-compatible stores, MinIO, Ceph, R2 and the like, which all
+// accept path-style requests.
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
+//
+
+// emptyPayloadHash is the sha256 of an empty body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Client talks to one S3 endpoint with one set of credentials
+type Client struct {
+	// Endpoint is the base URL, such as https://s3.eu-west-1.amazonaws.com
+	// or http://127.0.0.1:9000
+	Endpoint     string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// VirtualHosted puts the bucket in the host name rather than the path,
+	// for AWS buckets that need it
+	VirtualHosted bool
+	HTTP          *http.Client
+
+	now func() time.Time
+}
+
+// NewClient returns a path-style client for the endpoint
+func NewClient(endpoint, region, accessKey, secretKey string) *Client {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &Client{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		HTTP:      &http.Client{Timeout: 5 * time.Minute},
+		now:       time.Now,
+	}
+}
+
+// APIError is an error response from the store
+type APIError struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("s3: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound reports whether err is a missing object or bucket
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Object is one entry of a listing
+type Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	ETag         string    `xml:"ETag"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type listBucketResult struct {
+	Contents              []Object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// List returns every object in the bucket under prefix, following the
+// continuation tokens of ListObjectsV2
+func (c *Client) List(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	objects := []Object{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := c.do(ctx, http.MethodGet, bucket, "", query, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		result := listBucketResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: reading listing: %v", err)
+		}
+
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated {
+			return objects, nil
+		}
+		if result.NextContinuationToken == "" {
+			return nil, fmt.Errorf("s3: truncated listing without a continuation token")
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Get opens the object for reading; the caller closes it
+func (c *Client) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, bucket, key, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Put writes the object
+func (c *Client) Put(ctx context.Context, bucket, key string, data []byte, contentType string) error {
+	resp, err := c.do(ctx, http.MethodPut, bucket, key, nil, data, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) url(bucket, key string) (*url.URL, error) {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if c.VirtualHosted {
+		u.Host = bucket + "." + u.Host
+		u.Path = "/" + key
+	} else {
+		u.Path = "/" + bucket + "/" + key
+	}
+	// send the path encoded exactly as it is signed
+	u.RawPath = uriEncode(u.Path, false)
+	return u, nil
+}
+
+// do sends a signed request, turning error statuses into *APIError
+func (c *Client) do(ctx context.Context, method, bucket, key string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	u, err := c.url(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		h := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(h[:])
+	}
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	c.sign(req, payloadHash, now())
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		xml.Unmarshal(data, apiErr)
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// sign adds the SigV4 Authorization header, signing the host and every
+// header already on the request
+func (c *Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), date)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery sorts and encodes the query as SigV4 wants it, which is
+// also a valid query string to send
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters, and
+// slashes too when encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
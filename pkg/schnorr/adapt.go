@@ -0,0 +1,85 @@
+package schnorr
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcschnorr "github.com/btcsuite/btcd/btcec/v2/schnorr"
+	dcrschnorr "github.com/decred/dcrd/dcrec/secp256k1/v4/schnorr"
+)
+
+//
+// Conversions to and from the btcec v2 and dcrd secp256k1 v4 types. The
+// btcec key types are aliases of dcrd's, so the key conversions serve both.
+//
+// Signatures convert as r || s, the layout all three share, but each scheme
+// hashes its challenge and picks the sign of R differently: a signature only
+// verifies under the scheme that made it, whatever type it is held in.
+//
+
+// PrivateKeyFromBTCEC returns the scalar of a btcec or dcrd private key
+func PrivateKeyFromBTCEC(key *btcec.PrivateKey) *big.Int {
+	return new(big.Int).SetBytes(key.Serialize())
+}
+
+// PrivateKeyToBTCEC returns the btcec or dcrd private key for the scalar
+func PrivateKeyToBTCEC(privatekey *big.Int) (*btcec.PrivateKey, error) {
+	if privatekey.Sign() <= 0 || privatekey.Cmp(Curve.N) >= 0 {
+		return nil, fmt.Errorf("private key is not in the range 1..n-1")
+	}
+	key, _ := btcec.PrivKeyFromBytes(GetBigIntBytesImmutable(privatekey))
+	return key, nil
+}
+
+// PublicKeyFromBTCEC returns the compressed encoding Verify takes
+func PublicKeyFromBTCEC(key *btcec.PublicKey) [33]byte {
+	var publickey [33]byte
+	copy(publickey[:], key.SerializeCompressed())
+	return publickey
+}
+
+// PublicKeyToBTCEC parses a compressed public key into a btcec or dcrd one
+func PublicKeyToBTCEC(publickey [33]byte) (*btcec.PublicKey, error) {
+	return btcec.ParsePubKey(publickey[:])
+}
+
+// PointFromBTCEC returns the point of a btcec or dcrd public key
+func PointFromBTCEC(key *btcec.PublicKey) *Point {
+	return &Point{x: key.X(), y: key.Y()}
+}
+
+// BTCEC returns the point as a btcec or dcrd public key
+func (p *Point) BTCEC() (*btcec.PublicKey, error) {
+	publickey, err := p.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return PublicKeyToBTCEC(publickey)
+}
+
+// SignatureToBTCEC holds the signature in btcec's BIP-340 type, checking r
+// and s are in range
+func SignatureToBTCEC(signature [64]byte) (*btcschnorr.Signature, error) {
+	return btcschnorr.ParseSignature(signature[:])
+}
+
+// SignatureFromBTCEC returns the r || s bytes of a btcec signature
+func SignatureFromBTCEC(sig *btcschnorr.Signature) [64]byte {
+	var signature [64]byte
+	copy(signature[:], sig.Serialize())
+	return signature
+}
+
+// SignatureToDCRD holds the signature in dcrd's EC-Schnorr-DCRv0 type,
+// checking r and s are in range
+func SignatureToDCRD(signature [64]byte) (*dcrschnorr.Signature, error) {
+	return dcrschnorr.ParseSignature(signature[:])
+}
+
+// SignatureFromDCRD returns the r || s bytes of a dcrd signature
+func SignatureFromDCRD(sig *dcrschnorr.Signature) [64]byte {
+	var signature [64]byte
+	copy(signature[:], sig.Serialize())
+	return signature
+}
@@ -0,0 +1,121 @@
+package schnorr
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	btcschnorr "github.com/btcsuite/btcd/btcec/v2/schnorr"
+	dcrschnorr "github.com/decred/dcrd/dcrec/secp256k1/v4/schnorr"
+)
+
+func TestBTCECKeys(t *testing.T) {
+	keys, err := GenerateTestKeys([]byte("adapt"), 4)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+
+	for _, k := range keys {
+		// when
+		privKey, err := PrivateKeyToBTCEC(k.PrivateKey)
+		if err != nil {
+			t.Fatalf("Unexpected error from PrivateKeyToBTCEC: %v", err)
+		}
+
+		// then
+		if PrivateKeyFromBTCEC(privKey).Cmp(k.PrivateKey) != 0 {
+			t.Fatalf("PrivateKeyFromBTCEC(PrivateKeyToBTCEC(%x)) changed the key", k.PrivateKey)
+		}
+		if PublicKeyFromBTCEC(privKey.PubKey()) != k.PublicKey {
+			t.Fatalf("PublicKeyFromBTCEC() = %x, want %x", PublicKeyFromBTCEC(privKey.PubKey()), k.PublicKey)
+		}
+		pubKey, err := ScalarBaseMult(k.PrivateKey).BTCEC()
+		if err != nil || !pubKey.IsEqual(privKey.PubKey()) {
+			t.Fatalf("Point.BTCEC() = %v, %v, want the btcec public key", pubKey, err)
+		}
+		if !PointFromBTCEC(pubKey).Equal(ScalarBaseMult(k.PrivateKey)) {
+			t.Fatalf("PointFromBTCEC() is not the key's point")
+		}
+	}
+
+	if _, err := PrivateKeyToBTCEC(Curve.N); err == nil {
+		t.Fatalf("PrivateKeyToBTCEC(n) succeeded, want error")
+	}
+}
+
+func TestBTCECSignatures(t *testing.T) {
+	keys, _ := GenerateTestKeys([]byte("adapt"), 1)
+	message := sha256.Sum256([]byte("adapt"))
+	privKey, _ := PrivateKeyToBTCEC(keys[0].PrivateKey)
+
+	t.Run("BIP-340 signatures from btcec verify there", func(t *testing.T) {
+		sig, err := btcschnorr.Sign(privKey, message[:])
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		back, err := SignatureToBTCEC(SignatureFromBTCEC(sig))
+		if err != nil || !back.Verify(message[:], privKey.PubKey()) {
+			t.Fatalf("round tripped btcec signature does not verify: %v", err)
+		}
+	})
+
+	t.Run("dcrd signatures round trip", func(t *testing.T) {
+		sig, err := dcrschnorr.Sign(privKey, message[:])
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		back, err := SignatureToDCRD(SignatureFromDCRD(sig))
+		if err != nil || !back.Verify(message[:], privKey.PubKey()) {
+			t.Fatalf("round tripped dcrd signature does not verify: %v", err)
+		}
+	})
+
+	t.Run("Schemes do not mix", func(t *testing.T) {
+		signature, err := Sign(keys[0].PrivateKey, message)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		sig, err := SignatureToBTCEC(signature)
+		if err != nil {
+			t.Fatalf("Unexpected error from SignatureToBTCEC: %v", err)
+		}
+		if SignatureFromBTCEC(sig) != signature {
+			t.Fatalf("SignatureFromBTCEC(SignatureToBTCEC()) changed the signature")
+		}
+		if sig.Verify(message[:], privKey.PubKey()) {
+			t.Fatalf("legacy signature verified as BIP-340")
+		}
+	})
+}
+
+func TestVerifyBIP340(t *testing.T) {
+	// given
+	keys, err := GenerateTestKeys([]byte("bip340"), 4)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	message := sha256.Sum256([]byte("bip340"))
+
+	for _, key := range keys {
+		privKey, _ := PrivateKeyToBTCEC(key.PrivateKey)
+		x := btcschnorr.SerializePubKey(privKey.PubKey())
+		sig, err := btcschnorr.Sign(privKey, message[:])
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		var publickey [32]byte
+		copy(publickey[:], x)
+		signature := SignatureFromBTCEC(sig)
+
+		// when
+		valid, err := VerifyBIP340(publickey, message, signature)
+
+		// then
+		if !valid {
+			t.Fatalf("VerifyBIP340() = false, want true: %v", err)
+		}
+		signature[63] ^= 1
+		if valid, _ := VerifyBIP340(publickey, message, signature); valid {
+			t.Fatalf("VerifyBIP340() = true for a tampered signature")
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package schnorr
+
+import (
+	"fmt"
+	"math/big"
+)
+
+//
+// Audited signing, after the anti-exfil protocol hardware wallets use. A
+// signer free to pick its nonces can leak its key a few bits at a time
+// through them, and a deterministic nonce can't be checked without the
+// key. Instead the verifier, a host or an auditor, supplies a salt:
+//
+//	verifier   picks a random salt and sends SaltCommitment(salt)
+//	signer     derives k0 from its key, the message and the commitment,
+//	           and sends R0 = k0*G
+//	verifier   reveals the salt
+//	signer     signs with k = k0 + t, t = H(R0 || salt)
+//
+// The signer committed to R0 before it knew the salt, so R = R0 + t*G is
+// out of its control, and the AuditProof of R0 and the salt lets anyone
+// check that R is that point. It does not prove k0 itself was derived as
+// specified, which would take a zero-knowledge proof over SHA-256, but a
+// biased k0 no longer reaches the signature.
+//
+
+// AuditProof is what ties an audited signature's nonce to the salt
+type AuditProof struct {
+	// NonceCommitment is R0, sent before the salt was revealed
+	NonceCommitment [33]byte
+	Salt            [32]byte
+}
+
+// SaltCommitment is the verifier's commitment to its salt
+func SaltCommitment(salt [32]byte) [32]byte {
+	return TaggedHash("schnorr-go/audit/salt", salt[:])
+}
+
+// auditNonce derives k0 from the key, the message and the salt commitment
+func auditNonce(privatekey *big.Int, message, commitment [32]byte) (*big.Int, error) {
+	if privatekey.Sign() <= 0 || privatekey.Cmp(Curve.N) >= 0 {
+		return nil, fmt.Errorf("private key must be an integer between 1 and %d", new(big.Int).Sub(Curve.N, big.NewInt(1)))
+	}
+	return getDeterministicK(GetBigIntBytesImmutable(privatekey), TaggedHash("schnorr-go/audit/nonce", message[:], commitment[:]))
+}
+
+// auditTweak is t = H(R0 || salt)
+func auditTweak(r0 [33]byte, salt [32]byte) *big.Int {
+	h := TaggedHash("schnorr-go/audit/tweak", r0[:], salt[:])
+	t := new(big.Int).SetBytes(h[:])
+	return t.Mod(t, Curve.N)
+}
+
+// AuditNonceCommit is the signer's first step: R0 for the message, given
+// the commitment to a salt it has not seen
+func AuditNonceCommit(privatekey *big.Int, message, saltCommitment [32]byte) ([33]byte, error) {
+	k0, err := auditNonce(privatekey, message, saltCommitment)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	return ScalarBaseMult(k0).PublicKey()
+}
+
+// SignAudited is the signer's second step, once the salt is revealed. The
+// salt must match the commitment R0 was made for, so R0 can't be reused
+// with a different salt.
+func SignAudited(privatekey *big.Int, message [32]byte, salt [32]byte) ([64]byte, *AuditProof, error) {
+	k0, err := auditNonce(privatekey, message, SaltCommitment(salt))
+	if err != nil {
+		return [64]byte{}, nil, err
+	}
+	r0, err := ScalarBaseMult(k0).PublicKey()
+	if err != nil {
+		return [64]byte{}, nil, err
+	}
+
+	k := k0.Add(k0, auditTweak(r0, salt))
+	k.Mod(k, Curve.N)
+	if k.Sign() == 0 {
+		return [64]byte{}, nil, fmt.Errorf("k is zero")
+	}
+	sig, err := signWithNonce(privatekey, message, k)
+	if err != nil {
+		return sig, nil, err
+	}
+	return sig, &AuditProof{NonceCommitment: r0, Salt: salt}, nil
+}
+
+// VerifyAudited checks the signature and that its nonce is the committed
+// R0 tweaked by the salt. The caller must also check that the proof's
+// NonceCommitment is the R0 it received before revealing the salt.
+func VerifyAudited(publickey [33]byte, message [32]byte, signature [64]byte, proof *AuditProof) (bool, error) {
+	if ok, err := Verify(publickey, message, signature); !ok {
+		return false, err
+	}
+
+	r0, err := ParsePoint(proof.NonceCommitment)
+	if err != nil {
+		return false, fmt.Errorf("nonce commitment: %v", err)
+	}
+	r := r0.Add(ScalarBaseMult(auditTweak(proof.NonceCommitment, proof.Salt)))
+	if r.IsInfinity() {
+		return false, fmt.Errorf("tweaked nonce is the point at infinity")
+	}
+
+	// R may have been negated for a square y, which keeps its x
+	if r.X().Cmp(new(big.Int).SetBytes(signature[:32])) != 0 {
+		return false, fmt.Errorf("signature nonce is not the committed nonce tweaked by the salt")
+	}
+	return true, nil
+}
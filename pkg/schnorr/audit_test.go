@@ -0,0 +1,57 @@
+package schnorr
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestAuditedSigning(t *testing.T) {
+	// given
+	keys, err := GenerateTestKeys([]byte("audit"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	message := sha256.Sum256([]byte("audited"))
+	salt := sha256.Sum256([]byte("verifier salt"))
+
+	// when
+	r0, err := AuditNonceCommit(keys[0].PrivateKey, message, SaltCommitment(salt))
+	if err != nil {
+		t.Fatalf("Unexpected error from AuditNonceCommit: %v", err)
+	}
+	sig, proof, err := SignAudited(keys[0].PrivateKey, message, salt)
+	if err != nil {
+		t.Fatalf("Unexpected error from SignAudited: %v", err)
+	}
+
+	// then
+	if proof.NonceCommitment != r0 {
+		t.Fatalf("NonceCommitment = %x, want the committed %x", proof.NonceCommitment, r0)
+	}
+	if ok, err := VerifyAudited(keys[0].PublicKey, message, sig, proof); !ok {
+		t.Fatalf("VerifyAudited() = false, want true: %v", err)
+	}
+
+	t.Run("plain signature", func(t *testing.T) {
+		plain, _ := Sign(keys[0].PrivateKey, message)
+		if ok, _ := VerifyAudited(keys[0].PublicKey, message, plain, proof); ok {
+			t.Fatalf("VerifyAudited() accepted a signature with an unaudited nonce")
+		}
+	})
+
+	t.Run("other salt", func(t *testing.T) {
+		other := *proof
+		other.Salt[0] ^= 1
+		if ok, _ := VerifyAudited(keys[0].PublicKey, message, sig, &other); ok {
+			t.Fatalf("VerifyAudited() accepted a different salt")
+		}
+	})
+
+	t.Run("other commitment", func(t *testing.T) {
+		other := *proof
+		other.NonceCommitment = keys[0].PublicKey
+		if ok, _ := VerifyAudited(keys[0].PublicKey, message, sig, &other); ok {
+			t.Fatalf("VerifyAudited() accepted a different nonce commitment")
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package schnorr
+
+import (
+	"crypto/subtle"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+//
+// k*G in constant time, for the nonces and keys Sign and
+// AggregateSignatures multiply. secp256k1's ScalarBaseMultNonConst skips
+// zero windows and branches on the points it adds, so its timing depends on
+// the scalar.
+//
+// The scalar is split into 64 windows of 4 bits, and the table holds
+// j*16^i*G for every window i and value j. Each window's point is found by
+// reading all 16 entries and keeping the matching one with crypto/subtle,
+// and the 64 points are summed with the complete addition formulas of
+// Renes, Costello and Batina (Algorithm 7 of eprint 2015/1060), which have
+// no special cases for doubling or the point at infinity and so no
+// branches. Verification multiplies public values and keeps the faster
+// ScalarBaseMultNonConst.
+//
+
+const (
+	baseWindowBits = 4
+	baseWindows    = 256 / baseWindowBits
+	baseWindowSize = 1 << baseWindowBits
+)
+
+// baseEntry is a table point in affine coordinates, the point at infinity
+// having z 0
+type baseEntry struct {
+	x, y [32]byte
+	z    byte
+}
+
+var (
+	baseTableOnce sync.Once
+	baseTable     [baseWindows][baseWindowSize]baseEntry
+)
+
+// b3 is 3*b for secp256k1's b = 7
+var b3 = new(secp256k1.FieldVal).SetInt(21)
+
+// buildBaseTable fills in j*16^i*G. G is public, so the table is built with
+// the ordinary point arithmetic.
+func buildBaseTable() {
+	var base secp256k1.JacobianPoint
+	one := new(secp256k1.ModNScalar).SetInt(1)
+	secp256k1.ScalarBaseMultNonConst(one, &base)
+	for i := 0; i < baseWindows; i++ {
+		// entry 0 is the point at infinity, (0:1:0)
+		baseTable[i][0].y[31] = 1
+		var p secp256k1.JacobianPoint
+		for j := 1; j < baseWindowSize; j++ {
+			secp256k1.AddNonConst(&p, &base, &p)
+			affine := p
+			affine.ToAffine()
+			baseTable[i][j] = baseEntry{x: *affine.X.Bytes(), y: *affine.Y.Bytes(), z: 1}
+		}
+		// the next window's base is 16 times this one's
+		for j := 0; j < baseWindowBits; j++ {
+			secp256k1.DoubleNonConst(&base, &base)
+		}
+	}
+}
+
+// projectivePoint is (X:Y:Z) standing for the affine point (X/Z, Y/Z), the
+// point at infinity being (0:1:0)
+type projectivePoint struct {
+	x, y, z secp256k1.FieldVal
+}
+
+// scalarBaseMult sets result to k*G, normalized to affine coordinates, in
+// time independent of k
+func scalarBaseMult(k *secp256k1.ModNScalar, result *secp256k1.JacobianPoint) {
+	baseTableOnce.Do(buildBaseTable)
+
+	kb := k.Bytes()
+	defer zeroBytes(kb[:])
+
+	var acc projectivePoint
+	acc.y.SetInt(1)
+	for i := 0; i < baseWindows; i++ {
+		// window i is the low or high nibble of byte 31-i/2
+		nibble := int(kb[31-i/2]>>(baseWindowBits*uint(i%2))) & (baseWindowSize - 1)
+		var entry baseEntry
+		for j := range baseTable[i] {
+			match := subtle.ConstantTimeEq(int32(j), int32(nibble))
+			subtle.ConstantTimeCopy(match, entry.x[:], baseTable[i][j].x[:])
+			subtle.ConstantTimeCopy(match, entry.y[:], baseTable[i][j].y[:])
+			entry.z = byte(subtle.ConstantTimeSelect(match, int(baseTable[i][j].z), int(entry.z)))
+		}
+
+		var p projectivePoint
+		p.x.SetBytes(&entry.x)
+		p.y.SetBytes(&entry.y)
+		p.z.SetInt(uint16(entry.z))
+		completeAdd(&acc, &p, &acc)
+		zeroBytes(entry.x[:])
+		zeroBytes(entry.y[:])
+	}
+
+	// (X/Z, Y/Z), or all zero for the point at infinity as Z's inverse is 0
+	var zInv secp256k1.FieldVal
+	zInv.Set(&acc.z).Inverse()
+	result.X.Mul2(&acc.x, &zInv).Normalize()
+	result.Y.Mul2(&acc.y, &zInv).Normalize()
+	result.Z.SetInt(uint16(1 ^ acc.z.Normalize().IsZeroBit()))
+}
+
+// completeAdd sets r to p+q, for any p and q including equal points and the
+// point at infinity. r may be p or q. Every value is kept normalized, so
+// the magnitudes needn't be tracked.
+func completeAdd(p, q, r *projectivePoint) {
+	add := func(a, b *secp256k1.FieldVal) secp256k1.FieldVal {
+		var s secp256k1.FieldVal
+		s.Add2(a, b).Normalize()
+		return s
+	}
+	sub := func(a, b *secp256k1.FieldVal) secp256k1.FieldVal {
+		var s secp256k1.FieldVal
+		s.NegateVal(b, 1).Add(a).Normalize()
+		return s
+	}
+	mul := func(a, b *secp256k1.FieldVal) secp256k1.FieldVal {
+		var s secp256k1.FieldVal
+		s.Mul2(a, b).Normalize()
+		return s
+	}
+
+	t0 := mul(&p.x, &q.x)
+	t1 := mul(&p.y, &q.y)
+	t2 := mul(&p.z, &q.z)
+	t3 := add(&p.x, &p.y)
+	t4 := add(&q.x, &q.y)
+	t3 = mul(&t3, &t4)
+	t4 = add(&t0, &t1)
+	t3 = sub(&t3, &t4)
+	t4 = add(&p.y, &p.z)
+	x3 := add(&q.y, &q.z)
+	t4 = mul(&t4, &x3)
+	x3 = add(&t1, &t2)
+	t4 = sub(&t4, &x3)
+	x3 = add(&p.x, &p.z)
+	y3 := add(&q.x, &q.z)
+	x3 = mul(&x3, &y3)
+	y3 = add(&t0, &t2)
+	y3 = sub(&x3, &y3)
+	x3 = add(&t0, &t0)
+	t0 = add(&x3, &t0)
+	t2 = mul(b3, &t2)
+	z3 := add(&t1, &t2)
+	t1 = sub(&t1, &t2)
+	y3 = mul(b3, &y3)
+	x3 = mul(&t4, &y3)
+	t2 = mul(&t3, &t1)
+	x3 = sub(&t2, &x3)
+	y3 = mul(&y3, &t0)
+	t1 = mul(&t1, &z3)
+	y3 = add(&t1, &y3)
+	t0 = mul(&t0, &t3)
+	z3 = mul(&z3, &t4)
+	z3 = add(&z3, &t0)
+
+	r.x, r.y, r.z = x3, y3, z3
+}
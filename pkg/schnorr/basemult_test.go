@@ -0,0 +1,88 @@
+package schnorr
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestScalarBaseMult(t *testing.T) {
+	scalars := []*secp256k1.ModNScalar{
+		new(secp256k1.ModNScalar).SetInt(1),
+		new(secp256k1.ModNScalar).SetInt(2),
+		new(secp256k1.ModNScalar).SetInt(15),
+		new(secp256k1.ModNScalar).SetInt(16),
+		new(secp256k1.ModNScalar).SetInt(0x1111),
+		new(secp256k1.ModNScalar).SetInt(1).Negate(),
+	}
+	for i := 0; i < 50; i++ {
+		var b [32]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			t.Fatalf("Unexpected error from rand.Read: %v", err)
+		}
+		var k secp256k1.ModNScalar
+		k.SetBytes(&b)
+		scalars = append(scalars, &k)
+	}
+
+	for _, k := range scalars {
+		// when
+		var got, want secp256k1.JacobianPoint
+		scalarBaseMult(k, &got)
+		secp256k1.ScalarBaseMultNonConst(k, &want)
+		want.ToAffine()
+
+		// then
+		if !got.X.Equals(&want.X) || !got.Y.Equals(&want.Y) || !got.Z.IsOne() {
+			t.Fatalf("scalarBaseMult(%v) = (%v, %v), want (%v, %v)", k, got.X, got.Y, want.X, want.Y)
+		}
+	}
+
+	t.Run("Zero is the point at infinity", func(t *testing.T) {
+		var got secp256k1.JacobianPoint
+		scalarBaseMult(new(secp256k1.ModNScalar), &got)
+		if !isInfinity(&got) {
+			t.Fatalf("scalarBaseMult(0) = (%v, %v, %v), want the point at infinity", got.X, got.Y, got.Z)
+		}
+	})
+}
+
+func TestCompleteAdd(t *testing.T) {
+	// given G in projective coordinates and the point at infinity
+	var g secp256k1.JacobianPoint
+	scalarBaseMult(new(secp256k1.ModNScalar).SetInt(1), &g)
+	G := projectivePoint{x: g.X, y: g.Y, z: g.Z}
+	var infinity projectivePoint
+	infinity.y.SetInt(1)
+
+	affine := func(p projectivePoint) (secp256k1.FieldVal, secp256k1.FieldVal) {
+		var zInv, x, y secp256k1.FieldVal
+		zInv.Set(&p.z).Inverse()
+		x.Mul2(&p.x, &zInv).Normalize()
+		y.Mul2(&p.y, &zInv).Normalize()
+		return x, y
+	}
+	var twoG secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(new(secp256k1.ModNScalar).SetInt(2), &twoG)
+	twoG.ToAffine()
+
+	// when
+	var sum, withInfinity, cancelled projectivePoint
+	completeAdd(&G, &G, &sum)
+	completeAdd(&G, &infinity, &withInfinity)
+	negG := G
+	negG.y.Negate(1).Normalize()
+	completeAdd(&G, &negG, &cancelled)
+
+	// then
+	if x, y := affine(sum); !x.Equals(&twoG.X) || !y.Equals(&twoG.Y) {
+		t.Fatalf("completeAdd(G, G) = (%v, %v), want 2G", x, y)
+	}
+	if x, y := affine(withInfinity); !x.Equals(&g.X) || !y.Equals(&g.Y) {
+		t.Fatalf("completeAdd(G, infinity) = (%v, %v), want G", x, y)
+	}
+	if !cancelled.z.Normalize().IsZero() {
+		t.Fatalf("completeAdd(G, -G) has z %v, want the point at infinity", cancelled.z)
+	}
+}
@@ -0,0 +1,50 @@
+package schnorr
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// VerifyBIP340 verifies a BIP-340 signature against a 32 byte x-only public
+// key with this package's own point arithmetic. Built with the differential
+// tag, every result is checked against btcec's implementation, which is
+// dcrd's secp256k1, and disagreements are reported to OnDisagreement.
+func VerifyBIP340(publickey [32]byte, message [32]byte, signature [64]byte) (bool, error) {
+	valid, err := verifyBIP340(publickey, message, signature)
+	differentialBIP340(publickey, message, signature, valid)
+	return valid, err
+}
+
+func verifyBIP340(publickey [32]byte, message [32]byte, signature [64]byte) (bool, error) {
+	P, err := LiftX(publickey[:])
+	if err != nil {
+		return false, err
+	}
+
+	r := new(big.Int).SetBytes(signature[:32])
+	if r.Cmp(Curve.P) >= 0 {
+		return false, fmt.Errorf("r is larger or equal to the field size")
+	}
+	s := new(big.Int).SetBytes(signature[32:])
+	if s.Cmp(Curve.N) >= 0 {
+		return false, fmt.Errorf("s is larger than or equal to curve order N")
+	}
+
+	e, err := ComputeChallenge(signature[:32], P.X(), P.Y(), message[:], BIP340Challenge)
+	if err != nil {
+		return false, err
+	}
+
+	// R = s*G - e*P
+	R := ScalarBaseMult(s).Sub(P.Mul(e))
+	if R.IsInfinity() {
+		return false, fmt.Errorf("R is the point at infinity")
+	}
+	if !R.HasEvenY() {
+		return false, fmt.Errorf("R has an odd y")
+	}
+	if R.X().Cmp(r) != 0 {
+		return false, fmt.Errorf("r and rx do not match")
+	}
+	return true, nil
+}
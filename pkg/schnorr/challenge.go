@@ -0,0 +1,108 @@
+package schnorr
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/taggedhash"
+)
+
+// ChallengeScheme selects how the challenge e = hash(R || P || m) is encoded
+// and hashed. An empty Tag is the legacy encoding used by Sign and Verify:
+// sha256(r || compressed P || m). Any other Tag uses BIP-340 style tagged
+// hashing over r || x-only P || m.
+type ChallengeScheme struct {
+	Tag string
+}
+
+var (
+	// LegacyChallenge is the encoding Sign, Verify and AggregateSignatures use
+	LegacyChallenge = ChallengeScheme{}
+
+	// BIP340Challenge is the challenge of BIP-340 signatures
+	BIP340Challenge = ChallengeScheme{Tag: "BIP0340/challenge"}
+)
+
+// CustomChallenge returns a tagged scheme for protocols which want their
+// challenges domain separated from plain BIP-340 signatures
+func CustomChallenge(tag string) ChallengeScheme {
+	return ChallengeScheme{Tag: tag}
+}
+
+// ComputeChallenge calculates the challenge for the 32 byte x coordinate of R,
+// the public key P and the message m, reduced modulo the curve order. Legacy
+// challenges are only defined for 32 byte messages.
+func ComputeChallenge(rX []byte, Px, Py *big.Int, m []byte, scheme ChallengeScheme) (*big.Int, error) {
+	if len(rX) != 32 {
+		return nil, fmt.Errorf("r must be 32 bytes, got %d", len(rX))
+	}
+	if Px == nil || Py == nil || !Curve.IsOnCurve(Px, Py) {
+		return nil, fmt.Errorf("px and py are not on the curve")
+	}
+
+	if scheme.Tag == "" {
+		if len(m) != 32 {
+			return nil, fmt.Errorf("legacy challenges need a 32 byte message, got %d", len(m))
+		}
+		var message [32]byte
+		copy(message[:], m)
+		return getE(Px, Py, rX, message), nil
+	}
+
+	var h [32]byte
+	if scheme == BIP340Challenge {
+		h = taggedhash.BIP340Challenge.Sum(rX, GetBigIntBytesImmutable(Px), m)
+	} else {
+		h = TaggedHash(scheme.Tag, rX, GetBigIntBytesImmutable(Px), m)
+	}
+	i := new(big.Int).SetBytes(h[:])
+	return i.Mod(i, Curve.N), nil
+}
+
+// DeriveNonce derives the secret nonce k for signing m with the scheme. The
+// legacy scheme is Sign's deterministic nonce over a 32 byte message and
+// ignores auxRand. Tagged schemes use the default nonce function of
+// BIP-340, with the private key negated for an odd public key:
+//
+//	t = d xor hash_aux(auxRand), k = hash_nonce(t || x(P) || m)
+//
+// As in BIP-340 the caller negates k if k*G has an odd y.
+func DeriveNonce(privatekey *big.Int, m []byte, auxRand [32]byte, scheme ChallengeScheme) (*big.Int, error) {
+	if privatekey.Sign() <= 0 || privatekey.Cmp(Curve.N) >= 0 {
+		return nil, fmt.Errorf("private key must be an integer between 1 and %d", new(big.Int).Sub(Curve.N, big.NewInt(1)))
+	}
+
+	if scheme.Tag == "" {
+		if len(m) != 32 {
+			return nil, fmt.Errorf("legacy nonces need a 32 byte message, got %d", len(m))
+		}
+		var message [32]byte
+		copy(message[:], m)
+		return getDeterministicK(GetBigIntBytesImmutable(privatekey), message)
+	}
+
+	P := ScalarBaseMult(privatekey)
+	d := new(big.Int).Set(privatekey)
+	if !P.HasEvenY() {
+		d.Sub(Curve.N, d)
+	}
+
+	t := GetBigIntBytesImmutable(d)
+	aux := taggedhash.BIP340Aux.Sum(auxRand[:])
+	for i := range t {
+		t[i] ^= aux[i]
+	}
+	h := taggedhash.BIP340Nonce.Sum(t, GetBigIntBytesImmutable(P.X()), m)
+	k := new(big.Int).SetBytes(h[:])
+	k.Mod(k, Curve.N)
+	if k.Sign() == 0 {
+		return nil, fmt.Errorf("k is zero")
+	}
+	return k, nil
+}
+
+// TaggedHash computes sha256(sha256(tag) || sha256(tag) || data...), from
+// the tag's precomputed midstate once it has been seen
+func TaggedHash(tag string, data ...[]byte) [32]byte {
+	return taggedhash.Sum(tag, data...)
+}
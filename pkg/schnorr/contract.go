@@ -0,0 +1,150 @@
+package schnorr
+
+import (
+	"fmt"
+	"math/big"
+)
+
+//
+// Commitments to arbitrary data hidden in a key or a signature, so only
+// someone shown the data can tell it's there.
+//
+// Pay-to-contract commits through the public key: P' = P + t*G with
+// t = H(P || data), and the private key of P' is d + t. Paying to P'
+// commits the payment to the contract in data, which the payee proves by
+// revealing P and the data.
+//
+// Sign-to-contract commits through the signature's nonce: the signer picks
+// R0 = k0*G as usual and signs with k = k0 + H(R0 || data), so R = R0 +
+// H(R0 || data)*G. The signature then timestamps the data, and as with
+// audited signing the nonce is tied to something the signer can show,
+// which leaves it no room for a covert channel once the data is fixed.
+//
+
+// ContractProof is what ties a sign-to-contract signature's nonce to the
+// data it commits to
+type ContractProof struct {
+	// NonceCommitment is R0, the nonce before it was tweaked by the data
+	NonceCommitment [33]byte
+}
+
+// contractTweak is t = H(point || data) for the tag
+func contractTweak(tag string, point [33]byte, data []byte) (*big.Int, error) {
+	h := TaggedHash(tag, point[:], data)
+	t := new(big.Int).SetBytes(h[:])
+	if t.Sign() == 0 || t.Cmp(Curve.N) >= 0 {
+		return nil, fmt.Errorf("contract tweak is not in the range 1..n-1")
+	}
+	return t, nil
+}
+
+// PayToContractPublicKey returns P' = P + H(P || data)*G
+func PayToContractPublicKey(publickey [33]byte, data []byte) ([33]byte, error) {
+	P, err := ParsePoint(publickey)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	t, err := contractTweak("schnorr-go/contract/key", publickey, data)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	tweaked := P.Add(ScalarBaseMult(t))
+	if tweaked.IsInfinity() {
+		return [33]byte{}, fmt.Errorf("tweaked key is the point at infinity")
+	}
+	return tweaked.PublicKey()
+}
+
+// PayToContractPrivateKey returns d + H(P || data), the private key of the
+// public key PayToContractPublicKey tweaks d's to
+func PayToContractPrivateKey(privatekey *big.Int, data []byte) (*big.Int, error) {
+	if privatekey.Sign() <= 0 || privatekey.Cmp(Curve.N) >= 0 {
+		return nil, fmt.Errorf("private key is not in the range 1..n-1")
+	}
+	publickey, err := ScalarBaseMult(privatekey).PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	t, err := contractTweak("schnorr-go/contract/key", publickey, data)
+	if err != nil {
+		return nil, err
+	}
+	tweaked := t.Add(t, privatekey)
+	tweaked.Mod(tweaked, Curve.N)
+	if tweaked.Sign() == 0 {
+		return nil, fmt.Errorf("tweaked key is zero")
+	}
+	return tweaked, nil
+}
+
+// VerifyPayToContract checks that tweaked is the public key tweaked by
+// the data
+func VerifyPayToContract(publickey, tweaked [33]byte, data []byte) (bool, error) {
+	want, err := PayToContractPublicKey(publickey, data)
+	if err != nil {
+		return false, err
+	}
+	if want != tweaked {
+		return false, fmt.Errorf("key is not the public key tweaked by the data")
+	}
+	return true, nil
+}
+
+// SignToContract signs the message with a nonce committing to the data.
+// The nonce is derived from the key, the message and the data, so signing
+// the same again gives the same signature.
+func SignToContract(privatekey *big.Int, message [32]byte, data []byte) ([64]byte, *ContractProof, error) {
+	if privatekey.Sign() <= 0 || privatekey.Cmp(Curve.N) >= 0 {
+		return [64]byte{}, nil, fmt.Errorf("private key must be an integer between 1 and %d", new(big.Int).Sub(Curve.N, big.NewInt(1)))
+	}
+	k0, err := getDeterministicK(GetBigIntBytesImmutable(privatekey), TaggedHash("schnorr-go/contract/nonce", message[:], data))
+	if err != nil {
+		return [64]byte{}, nil, err
+	}
+	r0, err := ScalarBaseMult(k0).PublicKey()
+	if err != nil {
+		return [64]byte{}, nil, err
+	}
+	t, err := contractTweak("schnorr-go/contract/nonce-tweak", r0, data)
+	if err != nil {
+		return [64]byte{}, nil, err
+	}
+
+	k := k0.Add(k0, t)
+	k.Mod(k, Curve.N)
+	if k.Sign() == 0 {
+		return [64]byte{}, nil, fmt.Errorf("k is zero")
+	}
+	sig, err := signWithNonce(privatekey, message, k)
+	if err != nil {
+		return sig, nil, err
+	}
+	return sig, &ContractProof{NonceCommitment: r0}, nil
+}
+
+// VerifySignToContract checks the signature and that its nonce is the
+// proof's R0 tweaked by the data
+func VerifySignToContract(publickey [33]byte, message [32]byte, signature [64]byte, data []byte, proof *ContractProof) (bool, error) {
+	if ok, err := Verify(publickey, message, signature); !ok {
+		return false, err
+	}
+
+	r0, err := ParsePoint(proof.NonceCommitment)
+	if err != nil {
+		return false, fmt.Errorf("nonce commitment: %v", err)
+	}
+	t, err := contractTweak("schnorr-go/contract/nonce-tweak", proof.NonceCommitment, data)
+	if err != nil {
+		return false, err
+	}
+	r := r0.Add(ScalarBaseMult(t))
+	if r.IsInfinity() {
+		return false, fmt.Errorf("tweaked nonce is the point at infinity")
+	}
+
+	// R may have been negated for a square y, which keeps its x
+	if r.X().Cmp(new(big.Int).SetBytes(signature[:32])) != 0 {
+		return false, fmt.Errorf("signature nonce does not commit to the data")
+	}
+	return true, nil
+}
@@ -0,0 +1,84 @@
+package schnorr
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestPayToContract(t *testing.T) {
+	// given
+	keys, err := GenerateTestKeys([]byte("contract"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	contract := []byte("invoice 1042: 0.5 BTC for 20 widgets")
+
+	// when
+	tweaked, err := PayToContractPublicKey(keys[0].PublicKey, contract)
+	if err != nil {
+		t.Fatalf("Unexpected error from PayToContractPublicKey: %v", err)
+	}
+	d, err := PayToContractPrivateKey(keys[0].PrivateKey, contract)
+	if err != nil {
+		t.Fatalf("Unexpected error from PayToContractPrivateKey: %v", err)
+	}
+
+	// then the tweaked private key signs for the tweaked public key
+	if pk, _ := ScalarBaseMult(d).PublicKey(); pk != tweaked {
+		t.Fatalf("PayToContractPrivateKey() is the key of %x, want %x", pk, tweaked)
+	}
+	message := sha256.Sum256([]byte("spend"))
+	sig, err := Sign(d, message)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	if ok, err := Verify(tweaked, message, sig); !ok {
+		t.Fatalf("Verify() = false, want true: %v", err)
+	}
+	if ok, err := VerifyPayToContract(keys[0].PublicKey, tweaked, contract); !ok {
+		t.Fatalf("VerifyPayToContract() = false, want true: %v", err)
+	}
+
+	t.Run("other contract", func(t *testing.T) {
+		if ok, _ := VerifyPayToContract(keys[0].PublicKey, tweaked, []byte("invoice 1043")); ok {
+			t.Fatalf("VerifyPayToContract() accepted a different contract")
+		}
+	})
+}
+
+func TestSignToContract(t *testing.T) {
+	// given
+	keys, err := GenerateTestKeys([]byte("contract"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	message := sha256.Sum256([]byte("release 1.4"))
+	data := []byte("sha256 of the build log")
+
+	// when
+	sig, proof, err := SignToContract(keys[0].PrivateKey, message, data)
+	if err != nil {
+		t.Fatalf("Unexpected error from SignToContract: %v", err)
+	}
+
+	// then
+	if ok, err := Verify(keys[0].PublicKey, message, sig); !ok {
+		t.Fatalf("Verify() = false, want true: %v", err)
+	}
+	if ok, err := VerifySignToContract(keys[0].PublicKey, message, sig, data, proof); !ok {
+		t.Fatalf("VerifySignToContract() = false, want true: %v", err)
+	}
+
+	t.Run("other data", func(t *testing.T) {
+		if ok, _ := VerifySignToContract(keys[0].PublicKey, message, sig, []byte("something else"), proof); ok {
+			t.Fatalf("VerifySignToContract() accepted different data")
+		}
+	})
+
+	t.Run("plain signature", func(t *testing.T) {
+		plain, _ := Sign(keys[0].PrivateKey, message)
+		if ok, _ := VerifySignToContract(keys[0].PublicKey, message, plain, data, proof); ok {
+			t.Fatalf("VerifySignToContract() accepted a signature with an untweaked nonce")
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package schnorr
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcschnorr "github.com/btcsuite/btcd/btcec/v2/schnorr"
+	dcrschnorr "github.com/decred/dcrd/dcrec/secp256k1/v4/schnorr"
+)
+
+// Scheme names one of the secp256k1 Schnorr variants signatures are found
+// in. The names match the ones pkg/vectors uses.
+type Scheme string
+
+const (
+	// SchemeLegacy is this package's Sign and Verify
+	SchemeLegacy Scheme = "legacy"
+	// SchemeBIP340 is BIP-340, as in Taproot and Nostr
+	SchemeBIP340 Scheme = "bip340"
+	// SchemeDecred is Decred's EC-Schnorr-DCRv0
+	SchemeDecred Scheme = "ec-schnorr-dcrv0"
+)
+
+// Schemes lists every scheme DetectScheme tries by default, in the order it
+// tries them
+var Schemes = []Scheme{SchemeLegacy, SchemeBIP340, SchemeDecred}
+
+// ErrNoScheme is returned by DetectScheme when the signature verifies under
+// none of the schemes
+var ErrNoScheme = errors.New("signature does not verify under any of the schemes tried")
+
+// ParseScheme checks name is a known scheme
+func ParseScheme(name string) (Scheme, error) {
+	for _, s := range Schemes {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scheme %q, want one of %v", name, Schemes)
+}
+
+// VerifyScheme verifies the signature under one scheme. The public key may
+// be compressed or x-only: BIP-340 ignores the y of a compressed key, and the
+// other schemes take an x-only key to be the point with an even y.
+func VerifyScheme(scheme Scheme, publickey []byte, message [32]byte, signature [64]byte) (bool, error) {
+	var pk *btcec.PublicKey
+	var err error
+	switch len(publickey) {
+	case 32:
+		pk, err = btcschnorr.ParsePubKey(publickey)
+	case 33:
+		pk, err = btcec.ParsePubKey(publickey)
+	default:
+		return false, fmt.Errorf("public key is %d bytes, want 32 or 33", len(publickey))
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch scheme {
+	case SchemeLegacy:
+		return Verify(PublicKeyFromBTCEC(pk), message, signature)
+	case SchemeBIP340:
+		var x [32]byte
+		copy(x[:], btcschnorr.SerializePubKey(pk))
+		return VerifyBIP340(x, message, signature)
+	case SchemeDecred:
+		sig, err := SignatureToDCRD(signature)
+		if err != nil {
+			return false, err
+		}
+		return sig.Verify(message[:], pk), nil
+	}
+	return false, fmt.Errorf("unknown scheme %q", scheme)
+}
+
+// SignScheme signs under one scheme, as VerifyScheme verifies. BIP-340
+// signs for the key's x-only public key, negating the private key if its
+// y is odd.
+func SignScheme(scheme Scheme, privatekey *big.Int, message [32]byte) ([64]byte, error) {
+	key, err := PrivateKeyToBTCEC(privatekey)
+	if err != nil {
+		return [64]byte{}, err
+	}
+	switch scheme {
+	case SchemeLegacy:
+		return Sign(privatekey, message)
+	case SchemeBIP340:
+		sig, err := btcschnorr.Sign(key, message[:])
+		if err != nil {
+			return [64]byte{}, err
+		}
+		return SignatureFromBTCEC(sig), nil
+	case SchemeDecred:
+		sig, err := dcrschnorr.Sign(key, message[:])
+		if err != nil {
+			return [64]byte{}, err
+		}
+		return SignatureFromDCRD(sig), nil
+	}
+	return [64]byte{}, fmt.Errorf("unknown scheme %q", scheme)
+}
+
+// DetectScheme tries the signature under each scheme, all of Schemes when
+// none are given, and returns the first it verifies under. It is meant for
+// migrations where signatures of mixed provenance are about; where the
+// scheme is known, verify under that scheme alone.
+func DetectScheme(publickey []byte, message [32]byte, signature [64]byte, schemes ...Scheme) (Scheme, error) {
+	if len(schemes) == 0 {
+		schemes = Schemes
+	}
+	for _, scheme := range schemes {
+		valid, err := VerifyScheme(scheme, publickey, message, signature)
+		if valid && err == nil {
+			return scheme, nil
+		}
+	}
+	return "", ErrNoScheme
+}
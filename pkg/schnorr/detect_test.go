@@ -0,0 +1,85 @@
+package schnorr
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	btcschnorr "github.com/btcsuite/btcd/btcec/v2/schnorr"
+	dcrschnorr "github.com/decred/dcrd/dcrec/secp256k1/v4/schnorr"
+)
+
+func TestDetectScheme(t *testing.T) {
+	keys, err := GenerateTestKeys([]byte("detect"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	message := sha256.Sum256([]byte("detect"))
+	privKey, _ := PrivateKeyToBTCEC(keys[0].PrivateKey)
+
+	legacy, err := Sign(keys[0].PrivateKey, message)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	// btcec's BIP-340 Sign negates an odd key in place, so it goes last
+	decred, _ := dcrschnorr.Sign(privKey, message[:])
+	bip340, _ := btcschnorr.Sign(privKey, message[:])
+
+	tests := []struct {
+		name      string
+		publickey []byte
+		signature [64]byte
+		want      Scheme
+	}{
+		{"legacy", keys[0].PublicKey[:], legacy, SchemeLegacy},
+		{"bip340 with a compressed key", keys[0].PublicKey[:], SignatureFromBTCEC(bip340), SchemeBIP340},
+		{"bip340 with an x-only key", btcschnorr.SerializePubKey(privKey.PubKey()), SignatureFromBTCEC(bip340), SchemeBIP340},
+		{"decred", keys[0].PublicKey[:], SignatureFromDCRD(decred), SchemeDecred},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// when
+			scheme, err := DetectScheme(test.publickey, message, test.signature)
+
+			// then
+			if err != nil {
+				t.Fatalf("Unexpected error from DetectScheme: %v", err)
+			}
+			if scheme != test.want {
+				t.Fatalf("DetectScheme() = %v, want %v", scheme, test.want)
+			}
+		})
+	}
+
+	t.Run("Restricted schemes", func(t *testing.T) {
+		if _, err := DetectScheme(keys[0].PublicKey[:], message, legacy, SchemeBIP340, SchemeDecred); err != ErrNoScheme {
+			t.Fatalf("DetectScheme() error = %v, want ErrNoScheme", err)
+		}
+	})
+
+	t.Run("Altered message", func(t *testing.T) {
+		message[0] ^= 1
+		if _, err := DetectScheme(keys[0].PublicKey[:], message, legacy); err != ErrNoScheme {
+			t.Fatalf("DetectScheme() error = %v, want ErrNoScheme", err)
+		}
+	})
+}
+
+func TestSignScheme(t *testing.T) {
+	keys, _ := GenerateTestKeys([]byte("sign scheme"), 4)
+	message := sha256.Sum256([]byte("sign scheme"))
+	for _, scheme := range Schemes {
+		for i, key := range keys {
+			// when
+			signature, err := SignScheme(scheme, key.PrivateKey, message)
+			if err != nil {
+				t.Fatalf("Unexpected error from SignScheme(%s): %v", scheme, err)
+			}
+
+			// then
+			if detected, err := DetectScheme(key.PublicKey[:], message, signature); err != nil || detected != scheme {
+				t.Fatalf("DetectScheme() of a %s signature with key %d = %v, %v", scheme, i, detected, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+//go:build differential
+
+package schnorr
+
+import (
+	"fmt"
+
+	btcschnorr "github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+//
+// Differential checking, built in with -tags differential: VerifyBIP340's
+// result is compared with btcec's BIP-340 verification, ported from dcrd
+// and built on its secp256k1, and any disagreement is reported. This is for
+// fuzzing and test runs while the package's own arithmetic matures, not
+// for production builds, as every verification is done twice.
+//
+//	go test -tags differential -fuzz FuzzVerifyBIP340 ./pkg/schnorr
+//
+
+// Disagreement is an input the two implementations disagree on
+type Disagreement struct {
+	PublicKey [32]byte
+	Message   [32]byte
+	Signature [64]byte
+
+	// Valid is this package's result, Reference the other implementation's
+	Valid     bool
+	Reference bool
+}
+
+func (d *Disagreement) Error() string {
+	return fmt.Sprintf("differential: VerifyBIP340 = %t, reference = %t for key %x, message %x, signature %x",
+		d.Valid, d.Reference, d.PublicKey, d.Message, d.Signature)
+}
+
+// OnDisagreement is called with every disagreement. It panics by default,
+// which the fuzzer records as a crasher; tests may replace it to collect
+// them instead.
+var OnDisagreement = func(d *Disagreement) {
+	panic(d)
+}
+
+func differentialBIP340(publickey [32]byte, message [32]byte, signature [64]byte, valid bool) {
+	reference := referenceBIP340(publickey, message, signature)
+	if reference != valid {
+		OnDisagreement(&Disagreement{
+			PublicKey: publickey,
+			Message:   message,
+			Signature: signature,
+			Valid:     valid,
+			Reference: reference,
+		})
+	}
+}
+
+func referenceBIP340(publickey [32]byte, message [32]byte, signature [64]byte) bool {
+	pk, err := btcschnorr.ParsePubKey(publickey[:])
+	if err != nil {
+		return false
+	}
+	sig, err := btcschnorr.ParseSignature(signature[:])
+	if err != nil {
+		return false
+	}
+	return sig.Verify(message[:], pk)
+}
@@ -0,0 +1,6 @@
+//go:build !differential
+
+package schnorr
+
+// differentialBIP340 checks nothing unless built with the differential tag
+func differentialBIP340(publickey [32]byte, message [32]byte, signature [64]byte, valid bool) {}
@@ -0,0 +1,44 @@
+//go:build differential
+
+package schnorr
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	btcschnorr "github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+func FuzzVerifyBIP340(f *testing.F) {
+	keys, err := GenerateTestKeys([]byte("differential"), 4)
+	if err != nil {
+		f.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	for i, key := range keys {
+		privKey, err := PrivateKeyToBTCEC(key.PrivateKey)
+		if err != nil {
+			f.Fatalf("Unexpected error from PrivateKeyToBTCEC: %v", err)
+		}
+		message := sha256.Sum256([]byte{byte(i)})
+		sig, err := btcschnorr.Sign(privKey, message[:])
+		if err != nil {
+			f.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		signature := SignatureFromBTCEC(sig)
+		f.Add(btcschnorr.SerializePubKey(privKey.PubKey()), message[:], signature[:])
+	}
+
+	f.Fuzz(func(t *testing.T, pk, m, sig []byte) {
+		if len(pk) != 32 || len(m) != 32 || len(sig) != 64 {
+			return
+		}
+		var publickey, message [32]byte
+		var signature [64]byte
+		copy(publickey[:], pk)
+		copy(message[:], m)
+		copy(signature[:], sig)
+
+		// a disagreement panics in VerifyBIP340
+		VerifyBIP340(publickey, message, signature)
+	})
+}
@@ -0,0 +1,275 @@
+package schnorr
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+//
+// Typed keys and signatures. Sign and Verify take raw integers and byte
+// arrays, which are easy to mix up and can't check themselves; these types
+// are validated once, when parsed, and carry their own encoding. The raw
+// functions stay for the packages built on them, and the types convert to
+// and from them freely.
+//
+
+// PrivateKey is a scalar in 1..n-1 and its public key, optionally bound
+// to a purpose
+type PrivateKey struct {
+	d       secp256k1.ModNScalar
+	pub     *PublicKey
+	purpose KeyPurpose
+}
+
+// NewPrivateKey checks d is in 1..n-1 and wraps a copy of it
+func NewPrivateKey(d *big.Int) (*PrivateKey, error) {
+	if d == nil || d.Sign() <= 0 || d.Cmp(Curve.N) >= 0 {
+		return nil, fmt.Errorf("private key must be an integer between 1 and %d", new(big.Int).Sub(Curve.N, big.NewInt(1)))
+	}
+	k := &PrivateKey{d: scalarFromBig(d)}
+	point := scalarBaseMultPoint(&k.d)
+	compressed, err := point.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	k.pub = &PublicKey{point: point, compressed: compressed}
+	return k, nil
+}
+
+// GeneratePrivateKey draws a private key from rand, crypto/rand if nil
+func GeneratePrivateKey(random io.Reader) (*PrivateKey, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+	d, err := rand.Int(random, new(big.Int).Sub(Curve.N, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return NewPrivateKey(d.Add(d, big.NewInt(1)))
+}
+
+// ParsePrivateKey reads a 32 byte big-endian private key
+func ParsePrivateKey(b []byte) (*PrivateKey, error) {
+	if len(b) != 32 {
+		return nil, fmt.Errorf("private key must be 32 bytes, got %d", len(b))
+	}
+	return NewPrivateKey(new(big.Int).SetBytes(b))
+}
+
+// ParsePrivateKeyHex reads a hex private key, as the CLI takes them
+func ParsePrivateKeyHex(s string) (*PrivateKey, error) {
+	d, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		return nil, fmt.Errorf("private key is not hex")
+	}
+	return NewPrivateKey(d)
+}
+
+// D returns a copy of the scalar, for the functions taking a *big.Int
+func (k *PrivateKey) D() *big.Int {
+	b := k.d.Bytes()
+	defer zeroBytes(b[:])
+	return new(big.Int).SetBytes(b[:])
+}
+
+// Serialize returns the key as 32 big-endian bytes
+func (k *PrivateKey) Serialize() [32]byte {
+	return k.d.Bytes()
+}
+
+// PublicKey returns the key's public key
+func (k *PrivateKey) PublicKey() *PublicKey {
+	return k.pub
+}
+
+// Sign signs the 32 byte message digest, for the key's purpose if it has
+// one
+func (k *PrivateKey) Sign(message [32]byte, opts ...SignOption) (*Signature, error) {
+	raw, err := SignWithPurpose(k.D(), k.purpose, message, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSignature(raw[:])
+}
+
+// PublicKey is a point on the curve, never infinity, optionally bound to a
+// purpose
+type PublicKey struct {
+	point      *Point
+	compressed [33]byte
+	purpose    KeyPurpose
+}
+
+// ParsePublicKey reads a 33 byte compressed public key, or a 32 byte x-only
+// key as Taproot outputs and nostr use, taken to have an even y. Either way
+// it checks the point is on the curve.
+func ParsePublicKey(b []byte) (*PublicKey, error) {
+	if len(b) == 32 {
+		return ParseXOnlyPublicKey(b)
+	}
+	if len(b) != 33 {
+		return nil, fmt.Errorf("public key must be 32 or 33 bytes, got %d", len(b))
+	}
+	var compressed [33]byte
+	copy(compressed[:], b)
+	point, err := ParsePoint(compressed)
+	if err != nil {
+		return nil, err
+	}
+	return &PublicKey{point: point, compressed: compressed}, nil
+}
+
+// ParseXOnlyPublicKey reads a 32 byte x-only public key, lifting it to the
+// point with an even y
+func ParseXOnlyPublicKey(b []byte) (*PublicKey, error) {
+	point, err := LiftX(b)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := point.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return &PublicKey{point: point, compressed: compressed}, nil
+}
+
+// ParsePublicKeyHex reads a hex compressed or x-only public key
+func ParsePublicKeyHex(s string) (*PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("public key is not hex")
+	}
+	return ParsePublicKey(b)
+}
+
+// Serialize returns the 33 byte compressed key
+func (pk *PublicKey) Serialize() [33]byte {
+	return pk.compressed
+}
+
+// SerializeXOnly returns the 32 byte x coordinate. It only round trips
+// through ParsePublicKey when HasEvenY is true; an odd key's x-only form
+// is its negation.
+func (pk *PublicKey) SerializeXOnly() [32]byte {
+	var x [32]byte
+	copy(x[:], pk.compressed[1:])
+	return x
+}
+
+// HasEvenY reports whether the key's y is even, as every x-only key's is
+func (pk *PublicKey) HasEvenY() bool {
+	return pk.compressed[0] == 2
+}
+
+// Point returns the key as a curve point
+func (pk *PublicKey) Point() *Point {
+	return pk.point
+}
+
+// Equal reports whether other is the same key, in the form of the
+// standard library's public keys, whatever either is bound to
+func (pk *PublicKey) Equal(other crypto.PublicKey) bool {
+	o, ok := other.(*PublicKey)
+	return ok && o != nil && pk.compressed == o.compressed
+}
+
+// Verify checks the signature over the 32 byte message digest, for the
+// key's purpose if it has one
+func (pk *PublicKey) Verify(message [32]byte, signature *Signature) (bool, error) {
+	return VerifyWithPurpose(pk.compressed, pk.purpose, message, signature.Serialize())
+}
+
+func (pk *PublicKey) String() string {
+	return hex.EncodeToString(pk.compressed[:])
+}
+
+func (pk *PublicKey) MarshalText() ([]byte, error) {
+	return []byte(pk.String()), nil
+}
+
+func (pk *PublicKey) UnmarshalText(text []byte) error {
+	parsed, err := ParsePublicKeyHex(string(text))
+	if err != nil {
+		return err
+	}
+	*pk = *parsed
+	return nil
+}
+
+// Signature is r, the x coordinate of the nonce point, and s
+type Signature struct {
+	r, s *big.Int
+}
+
+// ParseSignature reads a 64 byte r || s signature, checking r is below the
+// field size and s below the curve order
+func ParseSignature(b []byte) (*Signature, error) {
+	if len(b) != 64 {
+		return nil, fmt.Errorf("signature must be 64 bytes, got %d", len(b))
+	}
+	r := new(big.Int).SetBytes(b[:32])
+	if r.Cmp(Curve.P) >= 0 {
+		return nil, fmt.Errorf("r is larger or equal to the field size")
+	}
+	s := new(big.Int).SetBytes(b[32:])
+	if s.Cmp(Curve.N) >= 0 {
+		return nil, fmt.Errorf("s is larger than or equal to curve order N")
+	}
+	return &Signature{r: r, s: s}, nil
+}
+
+// ParseSignatureHex reads a hex signature
+func ParseSignatureHex(s string) (*Signature, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("signature is not hex")
+	}
+	return ParseSignature(b)
+}
+
+// R returns a copy of r
+func (sig *Signature) R() *big.Int {
+	return new(big.Int).Set(sig.r)
+}
+
+// S returns a copy of s
+func (sig *Signature) S() *big.Int {
+	return new(big.Int).Set(sig.s)
+}
+
+// Serialize returns the 64 byte r || s encoding
+func (sig *Signature) Serialize() [64]byte {
+	var b [64]byte
+	copy(b[:32], GetBigIntBytesImmutable(sig.r))
+	copy(b[32:], GetBigIntBytesImmutable(sig.s))
+	return b
+}
+
+// Verify checks the signature over the message digest against the key
+func (sig *Signature) Verify(message [32]byte, publickey *PublicKey) (bool, error) {
+	return publickey.Verify(message, sig)
+}
+
+func (sig *Signature) String() string {
+	b := sig.Serialize()
+	return hex.EncodeToString(b[:])
+}
+
+func (sig *Signature) MarshalText() ([]byte, error) {
+	return []byte(sig.String()), nil
+}
+
+func (sig *Signature) UnmarshalText(text []byte) error {
+	parsed, err := ParseSignatureHex(string(text))
+	if err != nil {
+		return err
+	}
+	*sig = *parsed
+	return nil
+}
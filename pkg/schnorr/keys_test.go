@@ -0,0 +1,232 @@
+package schnorr
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestTypedKeys(t *testing.T) {
+	// given
+	keys, err := GenerateTestKeys([]byte("typed"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	message := sha256.Sum256([]byte("typed keys"))
+
+	// when
+	priv, err := NewPrivateKey(keys[0].PrivateKey)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewPrivateKey: %v", err)
+	}
+	sig, err := priv.Sign(message)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	// then
+	if got := priv.PublicKey().Serialize(); got != keys[0].PublicKey {
+		t.Fatalf("PublicKey() = %x, want %x", got, keys[0].PublicKey)
+	}
+	raw, _ := Sign(keys[0].PrivateKey, message)
+	if sig.Serialize() != raw {
+		t.Fatalf("Sign() = %s, want %x", sig, raw)
+	}
+	if ok, err := priv.PublicKey().Verify(message, sig); !ok {
+		t.Fatalf("Verify() = false, want true: %v", err)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		b := priv.Serialize()
+		parsed, err := ParsePrivateKey(b[:])
+		if err != nil {
+			t.Fatalf("Unexpected error from ParsePrivateKey: %v", err)
+		}
+		if parsed.D().Cmp(keys[0].PrivateKey) != 0 {
+			t.Fatalf("ParsePrivateKey() = %x, want %x", parsed.D(), keys[0].PrivateKey)
+		}
+
+		data, err := json.Marshal(struct {
+			PublicKey *PublicKey `json:"public_key"`
+			Signature *Signature `json:"signature"`
+		}{priv.PublicKey(), sig})
+		if err != nil {
+			t.Fatalf("Unexpected error from Marshal: %v", err)
+		}
+		var decoded struct {
+			PublicKey *PublicKey `json:"public_key"`
+			Signature *Signature `json:"signature"`
+		}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Unexpected error from Unmarshal: %v", err)
+		}
+		if !decoded.PublicKey.Equal(priv.PublicKey()) || decoded.Signature.Serialize() != sig.Serialize() {
+			t.Fatalf("Unmarshal() = %s %s, want %s %s", decoded.PublicKey, decoded.Signature, priv.PublicKey(), sig)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewPrivateKey(big.NewInt(0)); err == nil {
+			t.Fatalf("NewPrivateKey(0) succeeded")
+		}
+		if _, err := NewPrivateKey(Curve.N); err == nil {
+			t.Fatalf("NewPrivateKey(n) succeeded")
+		}
+		bad := priv.PublicKey().Serialize()
+		bad[0] = 5
+		if _, err := ParsePublicKey(bad[:]); err == nil {
+			t.Fatalf("ParsePublicKey() accepted a bad prefix")
+		}
+		highS := sig.Serialize()
+		copy(highS[32:], GetBigIntBytesImmutable(Curve.N))
+		if _, err := ParseSignature(highS[:]); err == nil {
+			t.Fatalf("ParseSignature() accepted s = n")
+		}
+	})
+}
+
+func TestXOnlyKeys(t *testing.T) {
+	// given
+	keys, err := GenerateTestKeys([]byte("x-only"), 8)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	message := sha256.Sum256([]byte("x-only keys"))
+
+	for _, key := range keys {
+		priv, err := NewPrivateKey(key.PrivateKey)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewPrivateKey: %v", err)
+		}
+		if !priv.PublicKey().HasEvenY() {
+			priv, _ = NewPrivateKey(new(big.Int).Sub(Curve.N, key.PrivateKey))
+		}
+		sig, err := priv.Sign(message)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+
+		// when
+		x := priv.PublicKey().SerializeXOnly()
+		parsed, err := ParsePublicKey(x[:])
+
+		// then
+		if err != nil {
+			t.Fatalf("Unexpected error from ParsePublicKey: %v", err)
+		}
+		if !parsed.Equal(priv.PublicKey()) {
+			t.Fatalf("ParsePublicKey(%x) = %s, want %s", x, parsed, priv.PublicKey())
+		}
+		if ok, err := parsed.Verify(message, sig); !ok {
+			t.Fatalf("Verify() = false, want true: %v", err)
+		}
+		if ok, err := VerifyXOnly(x, message, sig.Serialize()); !ok {
+			t.Fatalf("VerifyXOnly() = false, want true: %v", err)
+		}
+	}
+
+	t.Run("odd key", func(t *testing.T) {
+		for _, key := range keys {
+			priv, _ := NewPrivateKey(key.PrivateKey)
+			if priv.PublicKey().HasEvenY() {
+				continue
+			}
+			sig, _ := priv.Sign(message)
+			if ok, _ := VerifyXOnly(priv.PublicKey().SerializeXOnly(), message, sig.Serialize()); ok {
+				t.Fatalf("VerifyXOnly() = true for the negation of an odd key")
+			}
+			return
+		}
+		t.Skip("no odd key among the test keys")
+	})
+}
+
+func TestSigner(t *testing.T) {
+	// given
+	keys, err := GenerateTestKeys([]byte("crypto.Signer"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	priv, err := NewPrivateKey(keys[0].PrivateKey)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewPrivateKey: %v", err)
+	}
+	var signer crypto.Signer = priv.Signer()
+	digest := sha256.Sum256([]byte("stdlib"))
+
+	// when
+	raw, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	pub, ok := signer.Public().(*PublicKey)
+	if !ok || !pub.Equal(priv.PublicKey()) {
+		t.Fatalf("Public() = %v, want %s", signer.Public(), priv.PublicKey())
+	}
+	sig, err := ParseSignature(raw)
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseSignature: %v", err)
+	}
+	if ok, err := pub.Verify(digest, sig); !ok {
+		t.Fatalf("Verify() = false, want true: %v", err)
+	}
+	if _, err := signer.Sign(rand.Reader, digest[:20], crypto.Hash(0)); err == nil {
+		t.Fatalf("Sign() accepted a 20 byte digest")
+	}
+	if _, err := signer.Sign(rand.Reader, digest[:], crypto.SHA512); err == nil {
+		t.Fatalf("Sign() accepted a SHA-512 digest")
+	}
+}
+
+func TestKeyPurpose(t *testing.T) {
+	// given
+	keys, err := GenerateTestKeys([]byte("purpose"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	priv, err := NewPrivateKey(keys[0].PrivateKey)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewPrivateKey: %v", err)
+	}
+	message := sha256.Sum256([]byte("bound keys"))
+	auth := priv.WithPurpose(PurposeAuth)
+
+	// when
+	sig, err := auth.Sign(message)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	if ok, err := auth.PublicKey().Verify(message, sig); !ok {
+		t.Fatalf("Verify() = false, want true: %v", err)
+	}
+	if ok, _ := priv.PublicKey().Verify(message, sig); ok {
+		t.Fatalf("Verify() = true for an unbound key")
+	}
+	if ok, _ := priv.PublicKey().WithPurpose(PurposeRelease).Verify(message, sig); ok {
+		t.Fatalf("Verify() = true for a key bound to another purpose")
+	}
+	if _, err := auth.SignPurpose(PurposeTransaction, message); !errors.Is(err, ErrPurposeMismatch) {
+		t.Fatalf("SignPurpose() = %v, want %v", err, ErrPurposeMismatch)
+	}
+	if _, err := auth.PublicKey().VerifyPurpose(PurposeRelease, message, sig); !errors.Is(err, ErrPurposeMismatch) {
+		t.Fatalf("VerifyPurpose() = %v, want %v", err, ErrPurposeMismatch)
+	}
+
+	plain, _ := priv.Sign(message)
+	release, _ := priv.WithPurpose(PurposeRelease).Sign(message)
+	for _, other := range []*Signature{plain, release} {
+		a, b := other.Serialize(), sig.Serialize()
+		if bytes.Equal(a[:32], b[:32]) {
+			t.Fatalf("signatures for different purposes share a nonce")
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package schnorr
+
+import (
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ryohare/schnorr-go/pkg/drbg"
+)
+
+//
+// Sign's nonce is sha256(d || m) by default, which keeps every signature
+// made so far reproducible but follows no standard. WithRFC6979 derives it
+// by RFC 6979 instead, HMAC_DRBG over the key and message, and WithAuxRand
+// mixes fresh randomness into that as the extra data of section 3.6. A
+// nonce that also depends on random bytes is no longer the same for the
+// same message, which is what stops fault attacks recovering the key from
+// two signatures over one message where one of them was glitched.
+//
+// https://www.rfc-editor.org/rfc/rfc6979#section-3.2
+//
+
+// SignOption changes how Sign derives its nonce
+type SignOption func(*signOptions)
+
+type signOptions struct {
+	rfc6979 bool
+	aux     []byte
+}
+
+// WithRFC6979 derives the nonce by RFC 6979 with HMAC-SHA256
+func WithRFC6979() SignOption {
+	return func(o *signOptions) {
+		o.rfc6979 = true
+	}
+}
+
+// WithAuxRand derives the nonce by RFC 6979 with the 32 bytes as additional
+// data. They should be fresh from crypto/rand for every signature.
+func WithAuxRand(auxRand [32]byte) SignOption {
+	return func(o *signOptions) {
+		o.rfc6979 = true
+		o.aux = auxRand[:]
+	}
+}
+
+// nonce derives k for signing message with the key d
+func (o *signOptions) nonce(d []byte, message [32]byte) (secp256k1.ModNScalar, error) {
+	if !o.rfc6979 {
+		return deterministicNonce(d, message)
+	}
+	return rfc6979Nonce(d, message, o.aux)
+}
+
+// rfc6979Nonce is the nonce of RFC 6979 section 3.2 for the secp256k1
+// order, with extra data appended to the seed as in section 3.6. The
+// message digest is reduced mod n to make bits2octets(h1).
+func rfc6979Nonce(d []byte, message [32]byte, extra []byte) (secp256k1.ModNScalar, error) {
+	var h1 secp256k1.ModNScalar
+	h1.SetBytes(&message)
+	hb := h1.Bytes()
+
+	seed := make([]byte, 0, 64)
+	seed = append(seed, d...)
+	seed = append(seed, hb[:]...)
+	defer zeroBytes(seed)
+
+	var k secp256k1.ModNScalar
+	rng := drbg.New(seed, extra)
+	for {
+		candidate, err := rng.Generate(32)
+		if err != nil {
+			return k, err
+		}
+		var b [32]byte
+		copy(b[:], candidate)
+		zeroBytes(candidate)
+
+		// candidates at or above n are rejected, not reduced
+		overflow := k.SetBytes(&b)
+		zeroBytes(b[:])
+		if overflow == 0 && !k.IsZero() {
+			return k, nil
+		}
+	}
+}
+
+func newSignOptions(opts []SignOption) *signOptions {
+	o := new(signOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,163 @@
+package schnorr
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Point is an immutable point on the curve. The zero value, like the (0, 0)
+// the curve functions use, is the point at infinity.
+type Point struct {
+	x, y *big.Int
+}
+
+// Infinity is the identity of point addition
+var Infinity = &Point{}
+
+// NewPoint checks that x and y are on the curve and copies them into a point
+func NewPoint(x, y *big.Int) (*Point, error) {
+	if x == nil || y == nil || !Curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("px and py are not on the curve")
+	}
+	return &Point{x: new(big.Int).Set(x), y: new(big.Int).Set(y)}, nil
+}
+
+// ParsePoint unmarshals a compressed public key
+func ParsePoint(publickey [33]byte) (*Point, error) {
+	x, y := Unmarshal(Curve, publickey[:])
+	if x == nil || y == nil {
+		return nil, fmt.Errorf("px or py was unmarshalled to nil")
+	}
+	return NewPoint(x, y)
+}
+
+// LiftX returns the point with the 32 byte x coordinate and an even y, the
+// lift_x function of BIP-340
+func LiftX(x []byte) (*Point, error) {
+	if len(x) != 32 {
+		return nil, fmt.Errorf("x must be 32 bytes, got %d", len(x))
+	}
+	if new(big.Int).SetBytes(x).Cmp(Curve.P) >= 0 {
+		return nil, fmt.Errorf("x is larger than or equal to the field size")
+	}
+
+	var publickey [33]byte
+	publickey[0] = 2
+	copy(publickey[1:], x)
+	return ParsePoint(publickey)
+}
+
+// ScalarBaseMult returns k*G, in time independent of k so that it can be
+// given private keys, nonces and tweaks
+func ScalarBaseMult(k *big.Int) *Point {
+	if k.Sign() < 0 || k.BitLen() > 256 {
+		k = new(big.Int).Mod(k, Curve.N)
+	}
+	s := scalarFromBig(k)
+	defer s.Zero()
+	return scalarBaseMultPoint(&s)
+}
+
+// scalarBaseMultPoint is scalarBaseMult as a Point
+func scalarBaseMultPoint(k *secp256k1.ModNScalar) *Point {
+	var p secp256k1.JacobianPoint
+	scalarBaseMult(k, &p)
+	if isInfinity(&p) {
+		return Infinity
+	}
+	x, y := p.X.Bytes(), p.Y.Bytes()
+	return &Point{x: new(big.Int).SetBytes(x[:]), y: new(big.Int).SetBytes(y[:])}
+}
+
+// IsInfinity reports whether the point is the point at infinity
+func (p *Point) IsInfinity() bool {
+	return p.x == nil || (p.x.Sign() == 0 && p.y.Sign() == 0)
+}
+
+// X returns a copy of the x coordinate, nil for infinity
+func (p *Point) X() *big.Int {
+	if p.IsInfinity() {
+		return nil
+	}
+	return new(big.Int).Set(p.x)
+}
+
+// Y returns a copy of the y coordinate, nil for infinity
+func (p *Point) Y() *big.Int {
+	if p.IsInfinity() {
+		return nil
+	}
+	return new(big.Int).Set(p.y)
+}
+
+// HasEvenY reports whether y is even, false for infinity
+func (p *Point) HasEvenY() bool {
+	return !p.IsInfinity() && p.y.Bit(0) == 0
+}
+
+// Add returns p + q
+func (p *Point) Add(q *Point) *Point {
+	switch {
+	case p.IsInfinity():
+		return q
+	case q.IsInfinity():
+		return p
+	}
+
+	x, y := Curve.Add(p.x, p.y, q.x, q.y)
+	return &Point{x: x, y: y}
+}
+
+// Negate returns -p
+func (p *Point) Negate() *Point {
+	if p.IsInfinity() {
+		return Infinity
+	}
+	return &Point{x: new(big.Int).Set(p.x), y: new(big.Int).Sub(Curve.P, p.y)}
+}
+
+// Sub returns p - q
+func (p *Point) Sub(q *Point) *Point {
+	return p.Add(q.Negate())
+}
+
+// Mul returns k*p, with k reduced modulo the curve order
+func (p *Point) Mul(k *big.Int) *Point {
+	k = new(big.Int).Mod(k, Curve.N)
+	if p.IsInfinity() || k.Sign() == 0 {
+		return Infinity
+	}
+
+	x, y := Curve.ScalarMult(p.x, p.y, GetBigIntBytesImmutable(k))
+	return &Point{x: x, y: y}
+}
+
+// Equal reports whether the two points are the same
+func (p *Point) Equal(q *Point) bool {
+	if p.IsInfinity() || q.IsInfinity() {
+		return p.IsInfinity() && q.IsInfinity()
+	}
+	return p.x.Cmp(q.x) == 0 && p.y.Cmp(q.y) == 0
+}
+
+// PublicKey returns the compressed encoding used by Verify
+func (p *Point) PublicKey() ([33]byte, error) {
+	var publickey [33]byte
+	if p.IsInfinity() {
+		return publickey, fmt.Errorf("the point at infinity has no encoding")
+	}
+	copy(publickey[:], Marshal(Curve, p.x, p.y))
+	return publickey, nil
+}
+
+// XOnly returns the 32 byte x coordinate used by BIP-340 keys
+func (p *Point) XOnly() ([32]byte, error) {
+	var x [32]byte
+	if p.IsInfinity() {
+		return x, fmt.Errorf("the point at infinity has no encoding")
+	}
+	copy(x[:], GetBigIntBytesImmutable(p.x))
+	return x, nil
+}
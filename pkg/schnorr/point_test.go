@@ -0,0 +1,89 @@
+package schnorr
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestPointArithmetic(t *testing.T) {
+	a := ScalarBaseMult(big.NewInt(5))
+	b := ScalarBaseMult(big.NewInt(7))
+
+	// then
+	if !a.Add(b).Equal(ScalarBaseMult(big.NewInt(12))) {
+		t.Fatalf("5G + 7G != 12G")
+	}
+	if !b.Sub(a).Equal(ScalarBaseMult(big.NewInt(2))) {
+		t.Fatalf("7G - 5G != 2G")
+	}
+	if !a.Mul(big.NewInt(3)).Equal(ScalarBaseMult(big.NewInt(15))) {
+		t.Fatalf("3 * 5G != 15G")
+	}
+	if !a.Add(a.Negate()).IsInfinity() {
+		t.Fatalf("5G - 5G is not infinity")
+	}
+	if !a.Mul(Curve.N).IsInfinity() {
+		t.Fatalf("n * 5G is not infinity")
+	}
+	if !Infinity.Add(a).Equal(a) || !a.Add(Infinity).Equal(a) {
+		t.Fatalf("infinity is not the identity")
+	}
+}
+
+func TestPointEncoding(t *testing.T) {
+	pk := decodePublicKey("03FAC2114C2FBB091527EB7C64ECB11F8021CB45E8E7809D3C0938E4B8C0E5F84B", t)
+
+	p, err := ParsePoint(pk)
+	if err != nil {
+		t.Fatalf("Unexpected error from ParsePoint: %v", err)
+	}
+
+	observed, err := p.PublicKey()
+	if err != nil || observed != pk {
+		t.Fatalf("PublicKey() = %x, %v, want %x", observed, err, pk)
+	}
+
+	// then lift_x gives the even point, which is the negation of an odd key
+	x, _ := p.XOnly()
+	lifted, err := LiftX(x[:])
+	if err != nil {
+		t.Fatalf("Unexpected error from LiftX: %v", err)
+	}
+	if !lifted.HasEvenY() || !lifted.Equal(p.Negate()) {
+		t.Fatalf("LiftX(%x) is not the even negation of the key", x)
+	}
+
+	t.Run("Rejects x not on the curve", func(t *testing.T) {
+		// from the "public key not on the curve" test case
+		x, _ := hex.DecodeString("EEFDEA4CDB677750A420FEE807EACF21EB9898AE79B9768766E4FAA04A2D4A34")
+		if _, err := LiftX(x); err == nil {
+			t.Fatalf("LiftX(%x) succeeded, want error", x)
+		}
+	})
+
+	t.Run("Infinity has no encoding", func(t *testing.T) {
+		if _, err := Infinity.PublicKey(); err == nil {
+			t.Fatalf("Infinity.PublicKey() succeeded, want error")
+		}
+	})
+}
+
+func TestScalarBaseMultReduces(t *testing.T) {
+	G := ScalarBaseMult(big.NewInt(1))
+	for _, k := range []*big.Int{
+		big.NewInt(0),
+		new(big.Int).Set(Curve.N),
+		new(big.Int).Add(Curve.N, big.NewInt(5)),
+		big.NewInt(-5),
+		new(big.Int).Lsh(big.NewInt(1), 300),
+	} {
+		// when
+		got := ScalarBaseMult(k)
+
+		// then it's k mod n times G, as the curve's own multiplication has it
+		if want := G.Mul(k); !got.Equal(want) {
+			t.Fatalf("ScalarBaseMult(%v) = %v, want %v", k, got, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package schnorr
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// VerifyFunc has the signature of Verify, so other schemes with the same
+// encodings (like pkg/p256) can be run through a pool
+type VerifyFunc func(publickey [33]byte, message [32]byte, signature [64]byte) (bool, error)
+
+// VerifyJob is one signature to check. ID is not used by the pool, it is
+// handed back in the result so callers can match them up.
+type VerifyJob struct {
+	ID        uint64
+	PublicKey [33]byte
+	Message   [32]byte
+	Signature [64]byte
+}
+
+// VerifyResult is the outcome of a job. Results arrive in completion order,
+// not submission order.
+type VerifyResult struct {
+	Job   VerifyJob
+	Valid bool
+	Err   error
+}
+
+// VerifierPool spreads independent verifications over a fixed number of
+// goroutines. Submit blocks once the queue is full and workers block once
+// the results are not being read, so a slow consumer slows the producer down
+// instead of growing memory.
+type VerifierPool struct {
+	verify  VerifyFunc
+	jobs    chan VerifyJob
+	results chan VerifyResult
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewVerifierPool starts workers goroutines, one per CPU when workers < 1,
+// with room for queue pending jobs. A nil verify uses Verify.
+func NewVerifierPool(workers, queue int, verify VerifyFunc) *VerifierPool {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	if verify == nil {
+		verify = Verify
+	}
+
+	p := &VerifierPool{
+		verify:  verify,
+		jobs:    make(chan VerifyJob, queue),
+		results: make(chan VerifyResult, queue),
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	// results is closed once every worker has drained the jobs
+	go func() {
+		p.wg.Wait()
+		close(p.results)
+	}()
+
+	return p
+}
+
+func (p *VerifierPool) work() {
+	defer p.wg.Done()
+
+	for job := range p.jobs {
+		valid, err := p.verify(job.PublicKey, job.Message, job.Signature)
+		p.results <- VerifyResult{Job: job, Valid: valid, Err: err}
+	}
+}
+
+// Submit queues a job, blocking while the queue is full
+func (p *VerifierPool) Submit(job VerifyJob) error {
+	return p.SubmitContext(context.Background(), job)
+}
+
+// SubmitContext queues a job, giving up when ctx is done
+func (p *VerifierPool) SubmitContext(ctx context.Context, job VerifyJob) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return fmt.Errorf("verifier pool is closed")
+	}
+
+	select {
+	case p.jobs <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Results returns the channel results are delivered on. It is closed after
+// Close once every queued job has been verified, and must be drained or the
+// workers stall.
+func (p *VerifierPool) Results() <-chan VerifyResult {
+	return p.results
+}
+
+// Close stops accepting jobs. Jobs already queued are still verified. Close
+// waits for any Submit that is blocked on a full queue.
+func (p *VerifierPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+}
@@ -0,0 +1,73 @@
+package schnorr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVerifierPool(t *testing.T) {
+	pool := NewVerifierPool(4, 2, nil)
+
+	expected := map[uint64]bool{}
+	go func() {
+		for i, test := range testCases {
+			job := VerifyJob{
+				ID:        uint64(i),
+				PublicKey: decodePublicKey(test.pk, nil),
+				Message:   decodeMessage(test.m, nil),
+				Signature: decodeSignature(test.sig, nil),
+			}
+			if err := pool.Submit(job); err != nil {
+				t.Errorf("Unexpected error from Submit: %v", err)
+			}
+		}
+		pool.Close()
+	}()
+
+	for i, test := range testCases {
+		expected[uint64(i)] = test.result
+	}
+
+	// when
+	count := 0
+	for result := range pool.Results() {
+		count++
+
+		// then
+		if result.Valid != expected[result.Job.ID] {
+			t.Fatalf("result for case %d = %v, want %v", result.Job.ID, result.Valid, expected[result.Job.ID])
+		}
+	}
+
+	if count != len(testCases) {
+		t.Fatalf("got %d results, want %d", count, len(testCases))
+	}
+
+	if err := pool.Submit(VerifyJob{}); err == nil {
+		t.Fatalf("Submit after Close succeeded, want error")
+	}
+}
+
+func TestVerifierPoolBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	slow := func(publickey [33]byte, message [32]byte, signature [64]byte) (bool, error) {
+		<-release
+		return true, nil
+	}
+
+	// one worker busy, one job in the queue and one result buffered
+	pool := NewVerifierPool(1, 1, slow)
+	defer close(release)
+
+	pool.Submit(VerifyJob{ID: 1})
+	pool.Submit(VerifyJob{ID: 2})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// then
+	if err := pool.SubmitContext(ctx, VerifyJob{ID: 3}); err != context.DeadlineExceeded {
+		t.Fatalf("SubmitContext on a full pool = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,134 @@
+package schnorr
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ryohare/schnorr-go/pkg/taggedhash"
+)
+
+//
+// Keys bound to a purpose, so a key made for one protocol can't be used
+// for another without anyone noticing. The purpose is mixed into the
+// challenge, e = tagged_hash("schnorr-go/purpose/<purpose>", r || P || m),
+// so a signature made for one purpose doesn't verify for any other or as a
+// plain signature, and the typed keys refuse calls naming another purpose.
+//
+// The nonce is bound to the purpose too. Sign's default nonce depends only
+// on the key and message, and two signatures with the same nonce and
+// different challenges give away the key, so purpose signatures derive
+// theirs by RFC 6979 with the purpose tag as extra data.
+//
+
+// KeyPurpose is what a key is for. The empty purpose is an unbound key,
+// which signs and verifies as Sign and Verify do.
+type KeyPurpose string
+
+const (
+	PurposeNone        KeyPurpose = ""
+	PurposeAuth        KeyPurpose = "auth"
+	PurposeRelease     KeyPurpose = "release-signing"
+	PurposeTransaction KeyPurpose = "tx-signing"
+)
+
+// ErrPurposeMismatch is a key used for a purpose it isn't bound to
+var ErrPurposeMismatch = errors.New("key purpose mismatch")
+
+func (p KeyPurpose) String() string {
+	if p == PurposeNone {
+		return "none"
+	}
+	return string(p)
+}
+
+func (p KeyPurpose) tag() string {
+	return "schnorr-go/purpose/" + string(p)
+}
+
+// challenge returns the challenge function of the purpose
+func (p KeyPurpose) challenge() challengeFunc {
+	if p == PurposeNone {
+		return legacyChallenge
+	}
+	tag := taggedhash.New(p.tag())
+	return func(rX []byte, publickey [33]byte, m [32]byte) secp256k1.ModNScalar {
+		h := tag.Sum(rX, publickey[:], m[:])
+		var e secp256k1.ModNScalar
+		e.SetBytes(&h)
+		return e
+	}
+}
+
+// SignWithPurpose signs for the purpose, which verifies only with
+// VerifyWithPurpose and the same purpose. PurposeNone is Sign.
+func SignWithPurpose(privatekey *big.Int, purpose KeyPurpose, message [32]byte, opts ...SignOption) ([64]byte, error) {
+	if purpose == PurposeNone {
+		return Sign(privatekey, message, opts...)
+	}
+	signature := [64]byte{}
+	if privatekey.Cmp(big.NewInt(1)) < 0 || privatekey.Cmp(new(big.Int).Sub(Curve.N, big.NewInt(1))) > 0 {
+		return signature, fmt.Errorf("private key must be an integer between 1 and %d", Curve.N)
+	}
+
+	d := scalarFromBig(privatekey)
+	defer d.Zero()
+
+	// RFC 6979 with the purpose, and any aux rand, as extra data
+	o := newSignOptions(opts)
+	extra := append([]byte(purpose.tag()), o.aux...)
+	db := d.Bytes()
+	k, err := rfc6979Nonce(db[:], message, extra)
+	zeroBytes(db[:])
+	if err != nil {
+		return signature, err
+	}
+	defer k.Zero()
+
+	return sign(&d, &k, message, purpose.challenge()), nil
+}
+
+// VerifyWithPurpose verifies a signature from SignWithPurpose
+func VerifyWithPurpose(publickey [33]byte, purpose KeyPurpose, message [32]byte, signature [64]byte) (bool, error) {
+	return verify(publickey, message, signature, purpose.challenge())
+}
+
+// WithPurpose returns the key bound to the purpose, with its public key
+// bound to it too
+func (k *PrivateKey) WithPurpose(purpose KeyPurpose) *PrivateKey {
+	return &PrivateKey{d: k.d, pub: k.pub.WithPurpose(purpose), purpose: purpose}
+}
+
+// Purpose returns what the key is bound to
+func (k *PrivateKey) Purpose() KeyPurpose {
+	return k.purpose
+}
+
+// SignPurpose signs for the purpose the caller's protocol needs, refusing
+// keys bound to another
+func (k *PrivateKey) SignPurpose(purpose KeyPurpose, message [32]byte, opts ...SignOption) (*Signature, error) {
+	if purpose != k.purpose {
+		return nil, fmt.Errorf("%w: key is for %s, asked to sign for %s", ErrPurposeMismatch, k.purpose, purpose)
+	}
+	return k.Sign(message, opts...)
+}
+
+// WithPurpose returns the public key bound to the purpose
+func (pk *PublicKey) WithPurpose(purpose KeyPurpose) *PublicKey {
+	return &PublicKey{point: pk.point, compressed: pk.compressed, purpose: purpose}
+}
+
+// Purpose returns what the key is bound to
+func (pk *PublicKey) Purpose() KeyPurpose {
+	return pk.purpose
+}
+
+// VerifyPurpose verifies for the purpose the caller's protocol needs,
+// refusing keys bound to another
+func (pk *PublicKey) VerifyPurpose(purpose KeyPurpose, message [32]byte, signature *Signature) (bool, error) {
+	if purpose != pk.purpose {
+		return false, fmt.Errorf("%w: key is for %s, asked to verify for %s", ErrPurposeMismatch, pk.purpose, purpose)
+	}
+	return pk.Verify(message, signature)
+}
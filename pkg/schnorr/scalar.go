@@ -0,0 +1,110 @@
+package schnorr
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+//
+// Sign, Verify and AggregateSignatures do their arithmetic on secp256k1's
+// ModNScalar and FieldVal, fixed size types whose operations take the same
+// time whatever the values, rather than on big.Int, whose operations take
+// time depending on the size of their values and so leak bits of keys and
+// nonces. big.Int stays at the edges of the API: keys come in as *big.Int
+// and are converted once, and the typed PrivateKey holds its key as a
+// ModNScalar.
+//
+// Secrets are multiplied by G with scalarBaseMult, a fixed-window table
+// read in constant time, see basemult.go: the nonce and key in Sign, the
+// typed PrivateKey's public key, and through ScalarBaseMult the keys,
+// nonces and tweaks of the packages built on this one. Verification
+// multiplies only public values, with secp256k1's ScalarBaseMultNonConst
+// and ScalarMultNonConst. Point.Mul is not constant time.
+//
+
+// scalarFromBig loads k as a scalar, reduced modulo n
+func scalarFromBig(k *big.Int) secp256k1.ModNScalar {
+	var b [32]byte
+	k.FillBytes(b[:])
+	var s secp256k1.ModNScalar
+	s.SetBytes(&b)
+	zeroBytes(b[:])
+	return s
+}
+
+// pointFromBig makes the affine point x, y, which must be on the curve
+func pointFromBig(x, y *big.Int) secp256k1.JacobianPoint {
+	var fx, fy secp256k1.FieldVal
+	fx.SetByteSlice(GetBigIntBytesImmutable(x))
+	fy.SetByteSlice(GetBigIntBytesImmutable(y))
+	return secp256k1.MakeJacobianPoint(&fx, &fy, new(secp256k1.FieldVal).SetInt(1))
+}
+
+// isInfinity reports whether the point is the point at infinity
+func isInfinity(p *secp256k1.JacobianPoint) bool {
+	return (p.X.IsZero() && p.Y.IsZero()) || p.Z.IsZero()
+}
+
+// hasSquareY reports whether the y coordinate of the normalized affine point
+// is a quadratic residue, the legacy scheme's rule for R
+func hasSquareY(p *secp256k1.JacobianPoint) bool {
+	var root secp256k1.FieldVal
+	return root.SquareRootVal(&p.Y)
+}
+
+// compressed encodes the normalized affine point as a compressed key
+func compressed(p *secp256k1.JacobianPoint) [33]byte {
+	var b [33]byte
+	b[0] = 2
+	if p.Y.IsOdd() {
+		b[0] = 3
+	}
+	p.X.PutBytesUnchecked(b[1:])
+	return b
+}
+
+// challengeFunc computes e for the x coordinate of R, the compressed public
+// key and the message
+type challengeFunc func(rX []byte, publickey [33]byte, m [32]byte) secp256k1.ModNScalar
+
+// legacyChallenge is e = sha256(r || compressed P || m) mod n
+func legacyChallenge(rX []byte, publickey [33]byte, m [32]byte) secp256k1.ModNScalar {
+	h := sha256.New()
+	h.Write(rX)
+	h.Write(publickey[:])
+	h.Write(m[:])
+
+	var digest [32]byte
+	copy(digest[:], h.Sum(nil))
+	var e secp256k1.ModNScalar
+	e.SetBytes(&digest)
+	return e
+}
+
+// deterministicNonce is k0 = sha256(d || m) mod n
+func deterministicNonce(d []byte, message [32]byte) (secp256k1.ModNScalar, error) {
+	h := sha256.New()
+	h.Write(d)
+	h.Write(message[:])
+
+	var digest [32]byte
+	copy(digest[:], h.Sum(nil))
+	var k secp256k1.ModNScalar
+	k.SetBytes(&digest)
+	zeroBytes(digest[:])
+
+	// check that the nonce didnt evaluate to 0
+	if k.IsZero() {
+		return k, fmt.Errorf("k0 is zero")
+	}
+	return k, nil
+}
+
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
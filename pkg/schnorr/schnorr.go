@@ -2,11 +2,11 @@ package schnorr
 
 import (
 	"crypto/elliptic"
-	"crypto/sha256"
 	"fmt"
 	"math/big"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 )
 
 //
@@ -28,7 +28,10 @@ func GetBigIntBytesImmutable(i *big.Int) []byte {
 }
 
 // s*G = R + e*Q
-func Sign(privatekey *big.Int, message [32]byte) ([64]byte, error) {
+//
+// Sign takes the raw scalar; PrivateKey.Sign is the checked, typed form.
+// The nonce is sha256(d || m) unless an option says otherwise.
+func Sign(privatekey *big.Int, message [32]byte, opts ...SignOption) ([64]byte, error) {
 	signature := [64]byte{}
 
 	// check the bounds on the private key passed in
@@ -36,45 +39,71 @@ func Sign(privatekey *big.Int, message [32]byte) ([64]byte, error) {
 		return signature, fmt.Errorf("private key must be an integer between 1 and %d", Curve.N)
 	}
 
-	// get the d as bytes, known as the private key in schnorr lingo
-	d := GetBigIntBytesImmutable(privatekey)
+	// get d as a scalar, known as the private key in schnorr lingo
+	d := scalarFromBig(privatekey)
+	defer d.Zero()
 
-	// get a random nounce value for the signature
-	k0, err := getDeterministicK(d, message)
+	// get a deterministic nonce value for the signature
+	db := d.Bytes()
+	k, err := newSignOptions(opts).nonce(db[:], message)
+	zeroBytes(db[:])
 	if err != nil {
 		return signature, err
 	}
+	defer k.Zero()
+
+	return sign(&d, &k, message, legacyChallenge), nil
+}
 
-	// Get Rx and Ry from the curve
-	rx, ry := Curve.ScalarBaseMult(GetBigIntBytesImmutable(k0))
+// signWithNonce signs with the nonce k0, negated if need be so R has a
+// square y
+func signWithNonce(privatekey *big.Int, message [32]byte, k0 *big.Int) ([64]byte, error) {
+	d, k := scalarFromBig(privatekey), scalarFromBig(k0)
+	defer d.Zero()
+	defer k.Zero()
+	if k.IsZero() {
+		return [64]byte{}, fmt.Errorf("k is zero")
+	}
+	return sign(&d, &k, message, legacyChallenge), nil
+}
 
-	// get the true k value now
-	k := getK(ry, k0)
+// sign computes s = k + e*d, negating k first if R = k*G has a non-square
+// y. k is left negated.
+func sign(d, k *secp256k1.ModNScalar, message [32]byte, challenge challengeFunc) [64]byte {
+	signature := [64]byte{}
 
-	// get Py and Px
-	Px, Py := Curve.ScalarBaseMult(d)
+	// get R from the curve and the true k value
+	var R secp256k1.JacobianPoint
+	scalarBaseMult(k, &R)
+	if !hasSquareY(&R) {
+		k.Negate()
+	}
 
-	// get the bytes for the Rx value
-	rxBytes := GetBigIntBytesImmutable(rx)
+	// get P
+	var P secp256k1.JacobianPoint
+	scalarBaseMult(d, &P)
 
-	// Get the E value
-	e := getE(Px, Py, rxBytes, message)
+	// get the bytes for the Rx value and the E value
+	rX := R.X.Bytes()
+	e := challenge(rX[:], compressed(&P), message)
 
 	// do the actual signing part
-	e.Mul(e, privatekey)
-	k.Add(k, e)
-	k.Mod(k, Curve.N)
-
-	// copy rx to the lower 32 bytes of the result
-	copy(signature[:32], rxBytes)
+	var s secp256k1.ModNScalar
+	s.Mul2(&e, d).Add(k)
 
-	// copy the k value into the upper 32 bytes of the result
-	copy(signature[32:], GetBigIntBytesImmutable(k))
+	// rx goes in the lower 32 bytes of the result, s in the upper
+	copy(signature[:32], rX[:])
+	s.PutBytesUnchecked(signature[32:])
 
-	return signature, nil
+	return signature
 }
 
+// Verify takes the raw encodings; PublicKey.Verify is the typed form.
 func Verify(publickey [33]byte, message [32]byte, signature [64]byte) (bool, error) {
+	return verify(publickey, message, signature, legacyChallenge)
+}
+
+func verify(publickey [33]byte, message [32]byte, signature [64]byte, challenge challengeFunc) (bool, error) {
 	px, py := Unmarshal(Curve, publickey[:])
 
 	// validate the points unmarshalled correctly and land on the curve
@@ -86,55 +115,69 @@ func Verify(publickey [33]byte, message [32]byte, signature [64]byte) (bool, err
 	}
 
 	// check r against the field size which is the lower 32 bytes of the signature
-	r := new(big.Int).SetBytes(signature[:32])
-	if r.Cmp(Curve.P) >= 0 {
+	var r secp256k1.FieldVal
+	if r.SetByteSlice(signature[:32]) {
 		return false, fmt.Errorf("r is larger or equal to the field size")
 	}
 
-	// check the k against the N value which is the upper 32 bytes of the sig
-	s := new(big.Int).SetBytes(signature[32:])
-	if s.Cmp(Curve.N) >= 0 {
+	// check the s against the N value which is the upper 32 bytes of the sig
+	var s secp256k1.ModNScalar
+	if s.SetByteSlice(signature[32:]) {
 		return false, fmt.Errorf("s is larger than or equal to curve order N")
 	}
 
 	// get the value
-	e := getE(px, py, GetBigIntBytesImmutable(r), message)
-
-	// Get the generator points multiplied by the signature
-	sgx, sgy := Curve.ScalarBaseMult(GetBigIntBytesImmutable(s))
+	P := pointFromBig(px, py)
+	e := challenge(signature[:32], compressed(&P), message)
 
-	// multiply by e the py and px values
-	epx, epy := Curve.ScalarMult(px, py, GetBigIntBytesImmutable(e))
+	// R = s*G - e*P
+	var sG, eP, R secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&s, &sG)
+	secp256k1.ScalarMultNonConst(e.Negate(), &P, &eP)
+	secp256k1.AddNonConst(&sG, &eP, &R)
 
-	epy.Sub(Curve.P, epy)
-
-	// add up the points we calculated
-	rx, ry := Curve.Add(sgx, sgy, epx, epy)
-
-	if rx.Sign() == 0 && ry.Sign() == 0 {
+	if isInfinity(&R) {
 		return false, fmt.Errorf("sign with r[x|y] is 0 indicating the result is 0")
 	}
-	if big.Jacobi(ry, Curve.P) != 1 {
+	R.ToAffine()
+	if !hasSquareY(&R) {
 		return false, fmt.Errorf("failed to validate the jacobi symbol")
 	}
-	if rx.Cmp(r) != 0 {
+	if !R.X.Equals(&r) {
 		return false, fmt.Errorf("r and rx do not match")
 	}
 
 	return true, nil
 }
 
+// VerifyXOnly verifies against a 32 byte x-only public key, the point with
+// that x and an even y
+func VerifyXOnly(publickey [32]byte, message [32]byte, signature [64]byte) (bool, error) {
+	compressed := [33]byte{2}
+	copy(compressed[1:], publickey[:])
+	return Verify(compressed, message, signature)
+}
+
+// AggregateSignatures needs every private key in one process, so it only
+// suits tests and single owners of several keys. Parties who each hold their
+// own key should sign with musig.Signer from pkg/musig instead.
 func AggregateSignatures(privatekeys []*big.Int, message [32]byte) ([64]byte, error) {
 	signature := [64]byte{}
 	if len(privatekeys) == 0 {
 		return signature, fmt.Errorf("no private keys supplied")
 	}
 
-	k0s := []*big.Int{}
-	px, py := new(big.Int), new(big.Int)
-	rx, ry := new(big.Int), new(big.Int)
+	ds := make([]secp256k1.ModNScalar, len(privatekeys))
+	ks := make([]secp256k1.ModNScalar, len(privatekeys))
+	defer func() {
+		for i := range ds {
+			ds[i].Zero()
+			ks[i].Zero()
+		}
+	}()
+	var R, P secp256k1.JacobianPoint
 
-	for _, privatekey := range privatekeys {
+	for i, privatekey := range privatekeys {
 		// check the range of the private key
 		if privatekey.Cmp(big.NewInt(1)) < 0 || privatekey.Cmp(new(big.Int).Sub(Curve.N, big.NewInt(1))) > 0 {
 			return signature, fmt.Errorf("private key is not in the range 1..n-1")
@@ -142,86 +185,70 @@ func AggregateSignatures(privatekeys []*big.Int, message [32]byte) ([64]byte, er
 
 		// this is similar to sign but we add up the signatures together
 
-		// get the bytes of the private key called d
-		d := GetBigIntBytesImmutable(privatekey)
-
-		// get a k0 value
-		k0i, err := getDeterministicK(d, message)
+		// get the private key called d and a k0 value
+		ds[i] = scalarFromBig(privatekey)
+		db := ds[i].Bytes()
+		k, err := deterministicNonce(db[:], message)
+		zeroBytes(db[:])
 		if err != nil {
 			return signature, err
 		}
+		ks[i] = k
 
-		rix, riy := Curve.ScalarBaseMult(GetBigIntBytesImmutable(k0i))
-		pix, piy := Curve.ScalarBaseMult(d)
+		var Ri, Pi secp256k1.JacobianPoint
+		scalarBaseMult(&ks[i], &Ri)
+		scalarBaseMult(&ds[i], &Pi)
 
-		k0s = append(k0s, k0i)
-
-		// add the curves together effectivly stacking the signatures.
-		rx, ry = Curve.Add(rx, ry, rix, riy)
-		px, py = Curve.Add(px, py, pix, piy)
+		// add the points together effectivly stacking the signatures.
+		secp256k1.AddNonConst(&R, &Ri, &R)
+		secp256k1.AddNonConst(&P, &Pi, &P)
+	}
+	if isInfinity(&R) || isInfinity(&P) {
+		return signature, fmt.Errorf("the keys or nonces sum to the point at infinity")
 	}
 
 	// all right, now we have a mega huge signature, time to get the E
 	// and create the byte arrays
-
-	newRx := GetBigIntBytesImmutable(rx)
-	e := getE(px, py, newRx, message)
-
-	// accumulator for all the k values which get added together
-	s := new(big.Int).SetInt64(0)
-
-	// iterate over the signatures and private keys and get a determinstic k for each
-	for i, k0 := range k0s {
-		k := getK(ry, k0)
-		k.Add(k, new(big.Int).Mul(e, privatekeys[i]))
-		s.Add(s, k)
+	R.ToAffine()
+	P.ToAffine()
+	rX := R.X.Bytes()
+	e := legacyChallenge(rX[:], compressed(&P), message)
+	negate := !hasSquareY(&R)
+
+	// accumulator for all the k + e*d values which get added together
+	var s, ed secp256k1.ModNScalar
+	for i := range ks {
+		if negate {
+			ks[i].Negate()
+		}
+		s.Add(&ks[i]).Add(ed.Mul2(&e, &ds[i]))
 	}
+	ed.Zero()
 
 	// package into a byte array
-	copy(signature[:32], newRx)
-	copy(signature[32:], GetBigIntBytesImmutable(s.Mod(s, Curve.N)))
+	copy(signature[:32], rX[:])
+	s.PutBytesUnchecked(signature[32:])
 
 	return signature, nil
 }
 
 func getDeterministicK(d []byte, message [32]byte) (*big.Int, error) {
-	// niave way to get a random value based on the message however it
-	// will ensure that the k value is unique for the message
-	h := sha256.Sum256(append(d, message[:]...))
-	i := new(big.Int).SetBytes(h[:])
-
-	// ensure the the k value is witin the limits for the N of curve secp256k1
-	k0 := i.Mod(i, Curve.N)
-
-	// check that the nonce didnt evaluate to 0
-	if k0.Sign() == 0 {
-		return nil, fmt.Errorf("k0 is zero")
-	}
-
-	return k0, nil
-}
-
-func getK(Ry, k *big.Int) *big.Int {
-	if big.Jacobi(Ry, Curve.P) == 1 {
-		return k
+	k, err := deterministicNonce(d, message)
+	if err != nil {
+		return nil, err
 	}
-	return k.Sub(Curve.N, k)
+	b := k.Bytes()
+	k.Zero()
+	return new(big.Int).SetBytes(b[:]), nil
 }
 
-// Calculate the challenge. e = hash(R || m)
+// Calculate the legacy challenge. e = hash(R || P || m), see ComputeChallenge
 func getE(Px, Py *big.Int, rX []byte, m [32]byte) *big.Int {
-	r := append(rX, elliptic.MarshalCompressed(Curve, Px, Py)...)
-
-	// WTF, the unmarshall function doesnt work, need to use
-	// the sample one to get it to work
-	// data := elliptic.MarshalCompressed(Curve, Px, Py)
-	// x, y := elliptic.UnmarshalCompressed(Curve, data)
-	// fmt.Println(x, y)
-
-	r = append(r, m[:]...)
-	h := sha256.Sum256(r)
-	i := new(big.Int).SetBytes(h[:])
-	return i.Mod(i, Curve.N)
+	var publickey [33]byte
+	copy(publickey[:], elliptic.MarshalCompressed(Curve, Px, Py))
+	e := legacyChallenge(rX, publickey, m)
+	b := e.Bytes()
+	return new(big.Int).SetBytes(b[:])
 }
 
 // Marshal converts a point into the form specified in section 2.3.3 of the
@@ -1,6 +1,7 @@
 package schnorr
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/big"
@@ -252,3 +253,155 @@ func TestAggregateSignatures(t *testing.T) {
 		}
 	})
 }
+
+func TestComputeChallenge(t *testing.T) {
+	t.Run("Legacy challenge satisfies s*G = R + e*P", func(t *testing.T) {
+		test := testCases[1]
+		d := decodePrivateKey(test.d, t)
+		m := decodeMessage(test.m, t)
+
+		sig, err := Sign(d, m)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+
+		Px, Py := Curve.ScalarBaseMult(GetBigIntBytesImmutable(d))
+		e, err := ComputeChallenge(sig[:32], Px, Py, m[:], LegacyChallenge)
+		if err != nil {
+			t.Fatalf("Unexpected error from ComputeChallenge: %v", err)
+		}
+
+		// s*G - e*P must have the x coordinate r
+		sGx, sGy := Curve.ScalarBaseMult(sig[32:])
+		ePx, ePy := Curve.ScalarMult(Px, Py, GetBigIntBytesImmutable(e))
+		Rx, _ := Curve.Add(sGx, sGy, ePx, new(big.Int).Sub(Curve.P, ePy))
+
+		if Rx.Cmp(new(big.Int).SetBytes(sig[:32])) != 0 {
+			t.Fatalf("legacy challenge does not match the one Sign used")
+		}
+	})
+
+	t.Run("BIP-340 challenge matches the tagged hash", func(t *testing.T) {
+		// first BIP-340 test vector, secret key 3
+		Px, Py := Curve.ScalarBaseMult([]byte{3})
+		sig, _ := hex.DecodeString("E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0")
+		m := make([]byte, 32)
+
+		e, err := ComputeChallenge(sig[:32], Px, Py, m, BIP340Challenge)
+		if err != nil {
+			t.Fatalf("Unexpected error from ComputeChallenge: %v", err)
+		}
+
+		// BIP-340 lifts P to the even point, which it is for this key
+		sGx, sGy := Curve.ScalarBaseMult(sig[32:])
+		ePx, ePy := Curve.ScalarMult(Px, Py, GetBigIntBytesImmutable(e))
+		Rx, _ := Curve.Add(sGx, sGy, ePx, new(big.Int).Sub(Curve.P, ePy))
+
+		if Rx.Cmp(new(big.Int).SetBytes(sig[:32])) != 0 {
+			t.Fatalf("BIP-340 challenge does not verify the test vector")
+		}
+	})
+
+	t.Run("Custom tags are domain separated", func(t *testing.T) {
+		Px, Py := Curve.ScalarBaseMult([]byte{3})
+		rX := make([]byte, 32)
+		m := []byte("any length message")
+
+		a, _ := ComputeChallenge(rX, Px, Py, m, CustomChallenge("my-protocol/challenge"))
+		b, _ := ComputeChallenge(rX, Px, Py, m, BIP340Challenge)
+		if a.Cmp(b) == 0 {
+			t.Fatalf("custom tag gave the same challenge as BIP-340")
+		}
+
+		if _, err := ComputeChallenge(rX, Px, Py, m, LegacyChallenge); err == nil {
+			t.Fatalf("legacy challenge of a %d byte message succeeded, want error", len(m))
+		}
+	})
+}
+
+func TestDeriveNonce(t *testing.T) {
+	t.Run("BIP-340 nonce gives the test vector's R", func(t *testing.T) {
+		// second BIP-340 test vector
+		d, _ := new(big.Int).SetString("B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF", 16)
+		m, _ := hex.DecodeString("243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89")
+		aux := [32]byte{31: 1}
+		wantR := "6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de3341"
+
+		k, err := DeriveNonce(d, m, aux, BIP340Challenge)
+		if err != nil {
+			t.Fatalf("Unexpected error from DeriveNonce: %v", err)
+		}
+
+		R, _ := ScalarBaseMult(k).XOnly()
+		if hex.EncodeToString(R[:]) != wantR {
+			t.Fatalf("DeriveNonce() gave R = %x, want %s", R, wantR)
+		}
+	})
+
+	t.Run("Legacy nonce is Sign's", func(t *testing.T) {
+		test := testCases[1]
+		d := decodePrivateKey(test.d, t)
+		m := decodeMessage(test.m, t)
+
+		k, err := DeriveNonce(d, m[:], [32]byte{}, LegacyChallenge)
+		if err != nil {
+			t.Fatalf("Unexpected error from DeriveNonce: %v", err)
+		}
+		want, _ := getDeterministicK(GetBigIntBytesImmutable(d), m)
+		if k.Cmp(want) != 0 {
+			t.Fatalf("DeriveNonce() = %x, want %x", k, want)
+		}
+	})
+}
+
+func TestRFC6979Nonce(t *testing.T) {
+	// the secp256k1 RFC 6979 vectors used across bitcoin libraries
+	tests := []struct {
+		d, message, k string
+	}{
+		{"1", "Satoshi Nakamoto", "8f8a276c19f4149656b280621e358cce24f5f52542772691ee69063b74f15d15"},
+		{"1", "All those moments will be lost in time, like tears in rain. Time to die...", "38aa22d72376b4dbc472e06c3ba403ee0a394da63fc58d88686c611aba98d6b3"},
+		{"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140", "Satoshi Nakamoto", "33a19b60e25fb6f4435af53a3d42d493644827367e6453928554f43e49aa6f90"},
+	}
+
+	for _, test := range tests {
+		d, _ := new(big.Int).SetString(test.d, 16)
+		m := sha256.Sum256([]byte(test.message))
+
+		// when
+		k, err := rfc6979Nonce(GetBigIntBytesImmutable(d), m, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error from rfc6979Nonce: %v", err)
+		}
+
+		// then
+		b := k.Bytes()
+		if hex.EncodeToString(b[:]) != test.k {
+			t.Fatalf("rfc6979Nonce(%s, %q) = %x, want %s", test.d, test.message, b, test.k)
+		}
+	}
+
+	t.Run("Aux rand changes the nonce and signatures still verify", func(t *testing.T) {
+		test := testCases[1]
+		d := decodePrivateKey(test.d, t)
+		m := decodeMessage(test.m, t)
+		pk := decodePublicKey(test.pk, t)
+
+		plain, err := Sign(d, m, WithRFC6979())
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		withAux, err := Sign(d, m, WithAuxRand([32]byte{1}))
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		if plain == withAux {
+			t.Fatalf("aux rand did not change the signature")
+		}
+		for _, sig := range [][64]byte{plain, withAux} {
+			if ok, err := Verify(pk, m, sig); !ok {
+				t.Fatalf("Verify() = %v, %v, want true", ok, err)
+			}
+		}
+	})
+}
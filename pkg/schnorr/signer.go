@@ -0,0 +1,57 @@
+package schnorr
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+)
+
+// Signer adapts a PrivateKey to crypto.Signer, for code written against the
+// standard library's signing interface. PrivateKey.Sign already has the
+// natural signature, so this is a separate type rather than a method set
+// on the key.
+//
+// The digest must be 32 bytes, and opts either nil, crypto.Hash(0) or a hash
+// with a 32 byte output such as crypto.SHA256. Nonces are deterministic, so
+// rand is not read. Signatures are the 64 byte r || s encoding, which only
+// code that knows this scheme can verify: crypto/tls and crypto/x509 accept
+// the interface but not the algorithm.
+type Signer struct {
+	key *PrivateKey
+}
+
+// NewSigner wraps the private key
+func NewSigner(key *PrivateKey) *Signer {
+	return &Signer{key: key}
+}
+
+// Signer returns the key as a crypto.Signer
+func (k *PrivateKey) Signer() *Signer {
+	return NewSigner(k)
+}
+
+// Public returns the *PublicKey
+func (s *Signer) Public() crypto.PublicKey {
+	return s.key.PublicKey()
+}
+
+// Sign signs the digest, returning the 64 byte signature
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts != nil && opts.HashFunc() != 0 && opts.HashFunc().Size() != 32 {
+		return nil, fmt.Errorf("digest must be from a 32 byte hash, got %v", opts.HashFunc())
+	}
+	if len(digest) != 32 {
+		return nil, fmt.Errorf("digest must be 32 bytes, got %d", len(digest))
+	}
+
+	var message [32]byte
+	copy(message[:], digest)
+	sig, err := s.key.Sign(message)
+	if err != nil {
+		return nil, err
+	}
+	b := sig.Serialize()
+	return b[:], nil
+}
+
+var _ crypto.Signer = (*Signer)(nil)
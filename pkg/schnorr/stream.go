@@ -0,0 +1,229 @@
+package schnorr
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// DefaultMaxBuffered is how many bytes of message a StreamVerifier holds
+// while waiting for the chunks before them
+const DefaultMaxBuffered = 1 << 20
+
+// ErrIncomplete is returned by Result until every part has arrived
+var ErrIncomplete = errors.New("signature, public key or message still missing")
+
+// StreamVerifier checks a signature whose parts arrive in any order, as
+// they come off the wire. The message is hashed as it becomes contiguous, so
+// only chunks that arrive ahead of a gap are buffered. It verifies as soon as
+// the last part arrives and the outcome is kept for Result.
+//
+// A StreamVerifier is not safe for concurrent use.
+type StreamVerifier struct {
+	// MaxBuffered caps the bytes held for out of order chunks
+	MaxBuffered int
+
+	verify VerifyFunc
+	h      hash.Hash
+
+	publicKey    *[33]byte
+	signature    *[64]byte
+	digest       *[32]byte
+	offset       uint64
+	length       uint64
+	lengthKnown  bool
+	pending      map[uint64][]byte
+	pendingBytes int
+
+	done  bool
+	valid bool
+	err   error
+}
+
+// NewStreamVerifier hashes the message with newHash, sha256 when nil, and
+// checks the signature with verify, Verify when nil
+func NewStreamVerifier(newHash func() hash.Hash, verify VerifyFunc) *StreamVerifier {
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	if verify == nil {
+		verify = Verify
+	}
+	return &StreamVerifier{
+		MaxBuffered: DefaultMaxBuffered,
+		verify:      verify,
+		h:           newHash(),
+		pending:     map[uint64][]byte{},
+	}
+}
+
+// SetPublicKey supplies the public key. Sending the same key again is
+// harmless, a different one is an error.
+func (v *StreamVerifier) SetPublicKey(publickey [33]byte) error {
+	if v.publicKey != nil {
+		if *v.publicKey != publickey {
+			return fmt.Errorf("public key already received")
+		}
+		return nil
+	}
+	v.publicKey = &publickey
+	return v.finalize()
+}
+
+// SetSignature supplies the signature
+func (v *StreamVerifier) SetSignature(signature [64]byte) error {
+	if v.signature != nil {
+		if *v.signature != signature {
+			return fmt.Errorf("signature already received")
+		}
+		return nil
+	}
+	v.signature = &signature
+	return v.finalize()
+}
+
+// SetDigest supplies the message already hashed, in place of the chunks
+func (v *StreamVerifier) SetDigest(digest [32]byte) error {
+	if v.offset > 0 || v.lengthKnown || len(v.pending) > 0 {
+		return fmt.Errorf("message chunks already received")
+	}
+	if v.digest != nil {
+		if *v.digest != digest {
+			return fmt.Errorf("message digest already received")
+		}
+		return nil
+	}
+	v.digest = &digest
+	return v.finalize()
+}
+
+// SetLength supplies the total length of the message, which tells the
+// verifier when the last chunk has arrived
+func (v *StreamVerifier) SetLength(length uint64) error {
+	if v.digest != nil {
+		return fmt.Errorf("message digest already received")
+	}
+	if v.lengthKnown {
+		if v.length != length {
+			return fmt.Errorf("message length already received as %d", v.length)
+		}
+		return nil
+	}
+	if length < v.offset {
+		return fmt.Errorf("message length %d is shorter than the %d bytes received", length, v.offset)
+	}
+	for offset, chunk := range v.pending {
+		if offset+uint64(len(chunk)) > length {
+			return fmt.Errorf("message length %d is shorter than the chunk at %d", length, offset)
+		}
+	}
+	v.length = length
+	v.lengthKnown = true
+	return v.finalize()
+}
+
+// WriteChunk supplies the bytes of the message starting at offset. Chunks
+// may not overlap, as the bytes hashed so far are not kept to compare.
+func (v *StreamVerifier) WriteChunk(offset uint64, data []byte) error {
+	if v.digest != nil {
+		return fmt.Errorf("message digest already received")
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	end := offset + uint64(len(data))
+	if end < offset || (v.lengthKnown && end > v.length) {
+		return fmt.Errorf("chunk at %d runs past the end of the message", offset)
+	}
+	if offset < v.offset {
+		return fmt.Errorf("chunk at %d overlaps bytes already received", offset)
+	}
+	for o, chunk := range v.pending {
+		if offset < o+uint64(len(chunk)) && o < end {
+			return fmt.Errorf("chunk at %d overlaps the chunk at %d", offset, o)
+		}
+	}
+
+	if offset > v.offset {
+		if v.pendingBytes+len(data) > v.MaxBuffered {
+			return fmt.Errorf("more than %d bytes of out of order chunks", v.MaxBuffered)
+		}
+		v.pending[offset] = append([]byte{}, data...)
+		v.pendingBytes += len(data)
+		return nil
+	}
+
+	v.consume(data)
+	for {
+		chunk, ok := v.pending[v.offset]
+		if !ok {
+			break
+		}
+		delete(v.pending, v.offset)
+		v.pendingBytes -= len(chunk)
+		v.consume(chunk)
+	}
+	return v.finalize()
+}
+
+func (v *StreamVerifier) consume(data []byte) {
+	v.h.Write(data)
+	v.offset += uint64(len(data))
+}
+
+// Missing reports which parts have not arrived yet
+func (v *StreamVerifier) Missing() []string {
+	missing := []string{}
+	if v.publicKey == nil {
+		missing = append(missing, "public key")
+	}
+	if v.signature == nil {
+		missing = append(missing, "signature")
+	}
+	if v.digest == nil {
+		if !v.lengthKnown {
+			missing = append(missing, "message length")
+		}
+		if !v.lengthKnown || v.offset < v.length {
+			missing = append(missing, "message")
+		}
+	}
+	return missing
+}
+
+// Done reports whether every part has arrived and the signature was checked
+func (v *StreamVerifier) Done() bool {
+	return v.done
+}
+
+// Result returns the outcome, or ErrIncomplete while parts are missing
+func (v *StreamVerifier) Result() (bool, error) {
+	if !v.done {
+		return false, ErrIncomplete
+	}
+	return v.valid, v.err
+}
+
+// finalize verifies once everything is in. The error it returns is only
+// about the input just given; the outcome of the verification is left for
+// Result.
+func (v *StreamVerifier) finalize() error {
+	if v.done || len(v.Missing()) > 0 {
+		return nil
+	}
+
+	if v.digest == nil {
+		var digest [32]byte
+		sum := v.h.Sum(nil)
+		if len(sum) != len(digest) {
+			return fmt.Errorf("message hash is %d bytes, want %d", len(sum), len(digest))
+		}
+		copy(digest[:], sum)
+		v.digest = &digest
+	}
+
+	v.valid, v.err = v.verify(*v.publicKey, *v.digest, *v.signature)
+	v.done = true
+	return nil
+}
@@ -0,0 +1,109 @@
+package schnorr
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestStreamVerifier(t *testing.T) {
+	keys, err := GenerateTestKeys([]byte("stream"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	message := []byte("parts of a message that arrive off the wire in any order")
+	signature, err := Sign(keys[0].PrivateKey, sha256.Sum256(message))
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	t.Run("Out of order parts verify", func(t *testing.T) {
+		// given
+		v := NewStreamVerifier(nil, nil)
+
+		// when
+		steps := []func() error{
+			func() error { return v.WriteChunk(40, message[40:]) },
+			func() error { return v.SetSignature(signature) },
+			func() error { return v.WriteChunk(10, message[10:40]) },
+			func() error { return v.SetLength(uint64(len(message))) },
+			func() error { return v.SetPublicKey(keys[0].PublicKey) },
+			func() error { return v.WriteChunk(0, message[:10]) },
+		}
+		for i, step := range steps {
+			if v.Done() {
+				t.Fatalf("Done() before step %d", i)
+			}
+			if err := step(); err != nil {
+				t.Fatalf("Unexpected error from step %d: %v", i, err)
+			}
+		}
+
+		// then
+		valid, err := v.Result()
+		if err != nil || !valid {
+			t.Fatalf("Result() = %v, %v, want true", valid, err)
+		}
+		if len(v.pending) != 0 || v.pendingBytes != 0 {
+			t.Fatalf("%d chunks still buffered", len(v.pending))
+		}
+	})
+
+	t.Run("Digest instead of chunks", func(t *testing.T) {
+		v := NewStreamVerifier(nil, nil)
+		v.SetPublicKey(keys[0].PublicKey)
+		v.SetSignature(signature)
+		if _, err := v.Result(); err != ErrIncomplete {
+			t.Fatalf("Result() error = %v, want ErrIncomplete", err)
+		}
+		v.SetDigest(sha256.Sum256(message))
+		if valid, err := v.Result(); err != nil || !valid {
+			t.Fatalf("Result() = %v, %v, want true", valid, err)
+		}
+	})
+
+	t.Run("Altered message fails", func(t *testing.T) {
+		v := NewStreamVerifier(nil, nil)
+		v.SetPublicKey(keys[0].PublicKey)
+		v.SetSignature(signature)
+		v.SetLength(uint64(len(message)))
+		v.WriteChunk(0, message[:len(message)-1])
+		v.WriteChunk(uint64(len(message)-1), []byte{'!'})
+		if valid, _ := v.Result(); valid || !v.Done() {
+			t.Fatalf("Result() = %v, want false", valid)
+		}
+	})
+
+	t.Run("Bad input is rejected", func(t *testing.T) {
+		v := NewStreamVerifier(nil, nil)
+		v.MaxBuffered = 8
+		v.SetLength(20)
+		v.WriteChunk(0, []byte("abcd"))
+
+		tests := []struct {
+			name string
+			err  error
+		}{
+			{"overlaps received bytes", v.WriteChunk(2, []byte("xy"))},
+			{"past the end", v.WriteChunk(18, []byte("xyz"))},
+			{"too much buffered", v.WriteChunk(8, make([]byte, 9))},
+			{"different length", v.SetLength(21)},
+			{"digest after chunks", v.SetDigest([32]byte{})},
+		}
+		for _, test := range tests {
+			if test.err == nil {
+				t.Fatalf("%s: got no error", test.name)
+			}
+		}
+
+		v.WriteChunk(10, []byte("1234"))
+		if err := v.WriteChunk(12, []byte("56")); err == nil {
+			t.Fatalf("overlapping buffered chunks: got no error")
+		}
+		if err := v.SetPublicKey(keys[0].PublicKey); err != nil {
+			t.Fatalf("Unexpected error from SetPublicKey: %v", err)
+		}
+		if err := v.SetPublicKey([33]byte{2}); err == nil {
+			t.Fatalf("different public key: got no error")
+		}
+	})
+}
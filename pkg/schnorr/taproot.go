@@ -0,0 +1,86 @@
+package schnorr
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ryohare/schnorr-go/pkg/taggedhash"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0341.mediawiki
+//
+// The taproot tweak, which commits a P2TR output key Q to its internal key
+// P and the merkle root of its script tree: Q = lift_x(P) + t·G with
+// t = hash_TapTweak(P || merkle root). An output with no scripts is
+// tweaked with an empty merkle root. Signing for Q on the key path is
+// BIP-340 signing with the tweaked private key.
+//
+
+// TapTweak computes t = hash_TapTweak(P || merkle root) for the x-only
+// internal key. The merkle root must be empty or 32 bytes.
+func TapTweak(internal [32]byte, merkleRoot []byte) (secp256k1.ModNScalar, error) {
+	var t secp256k1.ModNScalar
+	if len(merkleRoot) != 0 && len(merkleRoot) != 32 {
+		return t, fmt.Errorf("merkle root must be empty or 32 bytes, got %d", len(merkleRoot))
+	}
+	h := taggedhash.Sum("TapTweak", internal[:], merkleRoot)
+	if overflow := t.SetBytes(&h); overflow != 0 {
+		return t, fmt.Errorf("taproot tweak is larger than the curve order")
+	}
+	return t, nil
+}
+
+// TweakPublicKey returns the x-only output key Q of the internal key, and
+// whether Q has an odd y, the parity bit control blocks carry
+func TweakPublicKey(internal [32]byte, merkleRoot []byte) ([32]byte, bool, error) {
+	P, err := LiftX(internal[:])
+	if err != nil {
+		return [32]byte{}, false, err
+	}
+	t, err := TapTweak(internal, merkleRoot)
+	if err != nil {
+		return [32]byte{}, false, err
+	}
+	Q := P.Add(scalarBaseMultPoint(&t))
+	if Q.IsInfinity() {
+		return [32]byte{}, false, fmt.Errorf("tweaked key is the point at infinity")
+	}
+	x, err := Q.XOnly()
+	if err != nil {
+		return [32]byte{}, false, err
+	}
+	return x, !Q.HasEvenY(), nil
+}
+
+// TweakPrivateKey returns the private key of the output key Q, for BIP-340
+// signing on the key path. The internal key is implicitly even, so d is
+// negated first if d·G has an odd y.
+func TweakPrivateKey(d *big.Int, merkleRoot []byte) (*big.Int, error) {
+	if d.Sign() <= 0 || d.Cmp(Curve.N) >= 0 {
+		return nil, fmt.Errorf("private key is not in the range 1..n-1")
+	}
+	tweaked := scalarFromBig(d)
+	defer tweaked.Zero()
+	P := scalarBaseMultPoint(&tweaked)
+	internal, err := P.XOnly()
+	if err != nil {
+		return nil, err
+	}
+	t, err := TapTweak(internal, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	if !P.HasEvenY() {
+		tweaked.Negate()
+	}
+	tweaked.Add(&t)
+	if tweaked.IsZero() {
+		return nil, fmt.Errorf("tweaked key is zero")
+	}
+	b := tweaked.Bytes()
+	defer zeroBytes(b[:])
+	return new(big.Int).SetBytes(b[:]), nil
+}
@@ -0,0 +1,63 @@
+package schnorr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestTweakPublicKey(t *testing.T) {
+	// given the key path only vector of BIP-341's wallet tests
+	var internal [32]byte
+	hex.Decode(internal[:], []byte("d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d"))
+
+	// when
+	output, _, err := TweakPublicKey(internal, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error from TweakPublicKey: %v", err)
+	}
+
+	// then
+	if got, want := hex.EncodeToString(output[:]), "53a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343"; got != want {
+		t.Fatalf("TweakPublicKey() = %s, want %s", got, want)
+	}
+}
+
+func TestTweakPrivateKey(t *testing.T) {
+	keys, _ := GenerateTestKeys([]byte("taproot"), 4)
+	message := sha256.Sum256([]byte("spend"))
+	root := sha256.Sum256([]byte("script tree"))
+
+	for _, merkleRoot := range [][]byte{nil, root[:]} {
+		for _, k := range keys {
+			// given
+			var internal [32]byte
+			copy(internal[:], k.PublicKey[1:])
+
+			// when
+			tweaked, err := TweakPrivateKey(k.PrivateKey, merkleRoot)
+			if err != nil {
+				t.Fatalf("Unexpected error from TweakPrivateKey: %v", err)
+			}
+			signature, err := SignScheme(SchemeBIP340, tweaked, message)
+			if err != nil {
+				t.Fatalf("Unexpected error from SignScheme: %v", err)
+			}
+
+			// then the signature is for the tweaked public key
+			output, _, err := TweakPublicKey(internal, merkleRoot)
+			if err != nil {
+				t.Fatalf("Unexpected error from TweakPublicKey: %v", err)
+			}
+			if ok, err := VerifyBIP340(output, message, signature); !ok {
+				t.Fatalf("VerifyBIP340() under the output key = %v, %v, want true", ok, err)
+			}
+		}
+	}
+
+	t.Run("Merkle roots are 32 bytes", func(t *testing.T) {
+		if _, err := TweakPrivateKey(keys[0].PrivateKey, []byte{1, 2, 3}); err == nil {
+			t.Fatalf("TweakPrivateKey() with a 3 byte merkle root succeeded, want an error")
+		}
+	})
+}
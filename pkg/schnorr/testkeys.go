@@ -0,0 +1,43 @@
+package schnorr
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/drbg"
+)
+
+// TestKey is a keypair made by GenerateTestKeys
+type TestKey struct {
+	PrivateKey *big.Int
+	PublicKey  [33]byte
+}
+
+// GenerateTestKeys derives n keypairs from the seed so test suites and
+// simulations get the same keys on every run without hardcoding them.
+//
+// NOT FOR PRODUCTION: anyone who knows the seed knows every private key.
+func GenerateTestKeys(seed []byte, n int) ([]TestKey, error) {
+	if len(seed) == 0 {
+		return nil, fmt.Errorf("seed must not be empty")
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("cannot generate %d keys", n)
+	}
+
+	rng := drbg.New(seed, []byte("schnorr-go/testkeys/v1"))
+	keys := make([]TestKey, n)
+
+	for i := range keys {
+		d, err := rng.Scalar(Curve.N)
+		if err != nil {
+			return nil, err
+		}
+
+		px, py := Curve.ScalarBaseMult(GetBigIntBytesImmutable(d))
+		keys[i].PrivateKey = d
+		copy(keys[i].PublicKey[:], Marshal(Curve, px, py))
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,38 @@
+package schnorr
+
+import (
+	"testing"
+)
+
+func TestGenerateTestKeys(t *testing.T) {
+	keys, err := GenerateTestKeys([]byte("unit tests"), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	again, _ := GenerateTestKeys([]byte("unit tests"), 5)
+
+	for i, key := range keys {
+		// then the same seed gives the same keys, and asking for more
+		// keys extends the list rather than changing it
+		if key.PrivateKey.Cmp(again[i].PrivateKey) != 0 || key.PublicKey != again[i].PublicKey {
+			t.Fatalf("key %d is not reproducible", i)
+		}
+
+		m := [32]byte{byte(i)}
+		sig, err := Sign(key.PrivateKey, m)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign with key %d: %v", i, err)
+		}
+		if ok, err := Verify(key.PublicKey, m, sig); !ok {
+			t.Fatalf("Verify with key %d = %v, %v, want true", i, ok, err)
+		}
+	}
+
+	if keys[0].PrivateKey.Cmp(keys[1].PrivateKey) == 0 {
+		t.Fatalf("GenerateTestKeys returned the same key twice")
+	}
+
+	if _, err := GenerateTestKeys(nil, 1); err == nil {
+		t.Fatalf("GenerateTestKeys with an empty seed succeeded, want error")
+	}
+}
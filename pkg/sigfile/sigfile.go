@@ -0,0 +1,97 @@
+package sigfile
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/decred/dcrd/crypto/blake256"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Detached signature files, the sidecar next to a signed file: the 64 raw
+// bytes of the signature, a PEM block that survives being pasted into
+// email and issue trackers, or hex. Each is a Decred EC-Schnorr-DCRv0
+// signature over the blake256 of the file, as sign -in makes them, so
+// sign -detach, verify -sig, verify -recursive and the watch daemon all
+// read and write the same files.
+//
+
+// Ext is added to a file's name for its signature file
+const Ext = ".sig"
+
+// ArmorType is the PEM block type of an armored signature
+const ArmorType = "SCHNORR SIGNATURE"
+
+// Scheme is the scheme signature files are signed with
+const Scheme = schnorr.SchemeDecred
+
+// Encode returns the signature file of sig, binary or armored
+func Encode(sig []byte, armor bool) []byte {
+	if !armor {
+		return sig
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: ArmorType, Bytes: sig})
+}
+
+// Decode returns the signature in a signature file of any of the forms
+func Decode(data []byte) ([]byte, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != ArmorType {
+			return nil, fmt.Errorf("armored block is a %s, want a %s", block.Type, ArmorType)
+		}
+		return block.Bytes, nil
+	}
+	if len(data) == 64 && !isHex(data) {
+		return data, nil
+	}
+	sig, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("signature file is not binary, armored or hex")
+	}
+	return sig, nil
+}
+
+func isHex(data []byte) bool {
+	return len(bytes.Trim(data, "0123456789abcdefABCDEF")) == 0
+}
+
+// Digest is the blake256 of the file at p, what its signature is over
+func Digest(p string) ([32]byte, error) {
+	var digest [32]byte
+	f, err := os.Open(p)
+	if err != nil {
+		return digest, err
+	}
+	defer f.Close()
+
+	h := blake256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return digest, err
+	}
+	copy(digest[:], h.Sum(nil))
+	return digest, nil
+}
+
+// Read returns the signature in the signature file of the file at p
+func Read(p string) ([64]byte, error) {
+	var sig [64]byte
+	data, err := os.ReadFile(p + Ext)
+	if err != nil {
+		return sig, err
+	}
+	raw, err := Decode(data)
+	if err != nil {
+		return sig, err
+	}
+	if len(raw) != len(sig) {
+		return sig, fmt.Errorf("signature is %d bytes, want %d", len(raw), len(sig))
+	}
+	copy(sig[:], raw)
+	return sig, nil
+}
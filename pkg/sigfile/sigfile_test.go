@@ -0,0 +1,42 @@
+package sigfile
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	sig := bytes.Repeat([]byte{0xab}, 64)
+	for _, tt := range []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{name: "binary", data: Encode(sig, false)},
+		{name: "armored", data: Encode(sig, true)},
+		{name: "hex", data: []byte(hex.EncodeToString(sig) + "\n")},
+		{name: "another pem block", data: []byte("-----BEGIN CERTIFICATE-----\nq6ur\n-----END CERTIFICATE-----\n"), wantErr: "want a SCHNORR SIGNATURE"},
+		{name: "neither", data: []byte("not a signature"), wantErr: "not binary, armored or hex"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			got, err := Decode(tt.data)
+
+			// then
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Decode() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error from Decode: %v", err)
+			}
+			if !bytes.Equal(got, sig) {
+				t.Fatalf("Decode() = %x, want %x", got, sig)
+			}
+		})
+	}
+}
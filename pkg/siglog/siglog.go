@@ -0,0 +1,280 @@
+package siglog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// A compact binary format for long runs of signatures made by one key, such
+// as the audit log of a signer that has been up for months. The public key
+// and scheme are written once in the header and each record holds only
+// what differs from the one before:
+//
+//	header:  "SGLG" | version | scheme length | scheme | public key (33)
+//	record:  flags | time delta | digest (32) | signature (64) | label delta
+//
+// The time is the signed varint milliseconds since the previous record's,
+// and the label, a file path say, is the uvarint length of the prefix it
+// shares with the previous label then the uvarint length and bytes of the
+// rest. Digest, time and label are each left out when the flags say so.
+//
+// The 64 bytes of signature are not compressed: x(R) and s are uniformly
+// random, so no encoding stores them in less. A signature and digest take
+// 96 bytes and a few more for the deltas, where a json audit line with the
+// key and hex takes several hundred.
+//
+
+// magic starts every log
+var magic = [4]byte{'S', 'G', 'L', 'G'}
+
+// version is the format written, and the only one read
+const version = 1
+
+// Record flags
+const (
+	flagDigest = 1 << iota
+	flagTime
+	flagLabel
+)
+
+// maxLabel bounds the label a record may carry, so a corrupt length can't
+// make the decoder allocate without limit
+const maxLabel = 1 << 16
+
+// ErrFormat is a stream that isn't a signature log, or is corrupt
+var ErrFormat = errors.New("not a signature log")
+
+// Record is one signature in the log
+type Record struct {
+	// Time is when the signature was made, to the millisecond, left out
+	// if zero
+	Time time.Time
+
+	// Digest is the message digest signed, left out if HasDigest is false
+	Digest    [32]byte
+	HasDigest bool
+
+	Signature [64]byte
+
+	// Label says what was signed, such as a file path, left out if empty
+	Label string
+}
+
+// Encoder writes records signed by one key. Close, or Flush, must be called
+// for the buffered records to be written.
+type Encoder struct {
+	w         *bufio.Writer
+	lastTime  int64
+	lastLabel string
+	buf       []byte
+}
+
+// NewEncoder writes the header for the key's signatures under the scheme
+func NewEncoder(w io.Writer, scheme schnorr.Scheme, publickey [33]byte) (*Encoder, error) {
+	if _, err := schnorr.ParseScheme(string(scheme)); err != nil {
+		return nil, err
+	}
+	e := &Encoder{w: bufio.NewWriter(w)}
+	header := append(magic[:], version, byte(len(scheme)))
+	header = append(header, scheme...)
+	header = append(header, publickey[:]...)
+	if _, err := e.w.Write(header); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Encode appends the record
+func (e *Encoder) Encode(r *Record) error {
+	if len(r.Label) > maxLabel {
+		return fmt.Errorf("label is %d bytes, at most %d are stored", len(r.Label), maxLabel)
+	}
+
+	var flags byte
+	if r.HasDigest {
+		flags |= flagDigest
+	}
+	if !r.Time.IsZero() {
+		flags |= flagTime
+	}
+	if r.Label != "" {
+		flags |= flagLabel
+	}
+
+	b := append(e.buf[:0], flags)
+	if flags&flagTime != 0 {
+		ms := r.Time.UnixNano() / int64(time.Millisecond)
+		b = appendVarint(b, ms-e.lastTime)
+		e.lastTime = ms
+	}
+	if r.HasDigest {
+		b = append(b, r.Digest[:]...)
+	}
+	b = append(b, r.Signature[:]...)
+	if flags&flagLabel != 0 {
+		shared := sharedPrefix(e.lastLabel, r.Label)
+		b = appendUvarint(b, uint64(shared))
+		b = appendUvarint(b, uint64(len(r.Label)-shared))
+		b = append(b, r.Label[shared:]...)
+		e.lastLabel = r.Label
+	}
+	e.buf = b
+
+	_, err := e.w.Write(b)
+	return err
+}
+
+// Flush writes the buffered records
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// Close flushes the encoder. It does not close the writer.
+func (e *Encoder) Close() error {
+	return e.Flush()
+}
+
+// Decoder reads the records of a log
+type Decoder struct {
+	r         *bufio.Reader
+	scheme    schnorr.Scheme
+	publickey [33]byte
+	lastTime  int64
+	lastLabel string
+}
+
+// NewDecoder reads the header of the log
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	d := &Decoder{r: bufio.NewReader(r)}
+
+	var head [6]byte
+	if _, err := io.ReadFull(d.r, head[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	if [4]byte{head[0], head[1], head[2], head[3]} != magic {
+		return nil, ErrFormat
+	}
+	if head[4] != version {
+		return nil, fmt.Errorf("signature log is version %d, want %d", head[4], version)
+	}
+	scheme := make([]byte, head[5])
+	if _, err := io.ReadFull(d.r, scheme); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	s, err := schnorr.ParseScheme(string(scheme))
+	if err != nil {
+		return nil, err
+	}
+	d.scheme = s
+	if _, err := io.ReadFull(d.r, d.publickey[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	return d, nil
+}
+
+// PublicKey is the key every signature in the log is by
+func (d *Decoder) PublicKey() [33]byte {
+	return d.publickey
+}
+
+// Scheme is the scheme the signatures are made under
+func (d *Decoder) Scheme() schnorr.Scheme {
+	return d.scheme
+}
+
+// Decode returns the next record, or io.EOF after the last
+func (d *Decoder) Decode() (*Record, error) {
+	flags, err := d.r.ReadByte()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if flags&^(flagDigest|flagTime|flagLabel) != 0 {
+		return nil, fmt.Errorf("%w: unknown record flags %#x", ErrFormat, flags)
+	}
+
+	r := &Record{}
+	if flags&flagTime != 0 {
+		delta, err := binary.ReadVarint(d.r)
+		if err != nil {
+			return nil, truncated(err)
+		}
+		d.lastTime += delta
+		r.Time = time.Unix(0, d.lastTime*int64(time.Millisecond)).UTC()
+	}
+	if flags&flagDigest != 0 {
+		if _, err := io.ReadFull(d.r, r.Digest[:]); err != nil {
+			return nil, truncated(err)
+		}
+		r.HasDigest = true
+	}
+	if _, err := io.ReadFull(d.r, r.Signature[:]); err != nil {
+		return nil, truncated(err)
+	}
+	if flags&flagLabel != 0 {
+		shared, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return nil, truncated(err)
+		}
+		rest, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return nil, truncated(err)
+		}
+		if shared > uint64(len(d.lastLabel)) || shared+rest > maxLabel {
+			return nil, fmt.Errorf("%w: label out of range", ErrFormat)
+		}
+		label := make([]byte, shared+rest)
+		copy(label, d.lastLabel[:shared])
+		if _, err := io.ReadFull(d.r, label[shared:]); err != nil {
+			return nil, truncated(err)
+		}
+		r.Label = string(label)
+		d.lastLabel = r.Label
+	}
+	return r, nil
+}
+
+// Verify checks the record's signature against the log's key. Records
+// without a digest can't be checked.
+func (d *Decoder) Verify(r *Record) (bool, error) {
+	if !r.HasDigest {
+		return false, fmt.Errorf("record has no digest to verify against")
+	}
+	return schnorr.VerifyScheme(d.scheme, d.publickey[:], r.Digest, r.Signature)
+}
+
+// truncated reports a record cut off part way as a format error rather
+// than a clean end
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated record", ErrFormat)
+	}
+	return err
+}
+
+func sharedPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(b, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(b, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
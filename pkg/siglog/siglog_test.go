@@ -0,0 +1,103 @@
+package siglog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestRoundTrip(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("siglog"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	records := []*Record{}
+	for i := 0; i < 100; i++ {
+		digest := sha256.Sum256([]byte(fmt.Sprint(i)))
+		sig, err := schnorr.Sign(keys[0].PrivateKey, digest)
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		records = append(records, &Record{
+			Time:      start.Add(time.Duration(i) * 1500 * time.Millisecond),
+			Digest:    digest,
+			HasDigest: true,
+			Signature: sig,
+			Label:     fmt.Sprintf("builds/release-%03d/schnorr-go.tar.gz", i),
+		})
+	}
+	records = append(records, &Record{Signature: records[0].Signature})
+
+	// when
+	var buf bytes.Buffer
+	e, err := NewEncoder(&buf, schnorr.SchemeLegacy, keys[0].PublicKey)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewEncoder: %v", err)
+	}
+	for _, r := range records {
+		if err := e.Encode(r); err != nil {
+			t.Fatalf("Unexpected error from Encode: %v", err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Unexpected error from Close: %v", err)
+	}
+
+	// then
+	if perRecord := buf.Len() / len(records); perRecord > 128 {
+		t.Fatalf("records take %d bytes each, want at most %d", perRecord, 128)
+	}
+	d, err := NewDecoder(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDecoder: %v", err)
+	}
+	if d.PublicKey() != keys[0].PublicKey || d.Scheme() != schnorr.SchemeLegacy {
+		t.Fatalf("header = %x %s, want %x %s", d.PublicKey(), d.Scheme(), keys[0].PublicKey, schnorr.SchemeLegacy)
+	}
+	for i, want := range records {
+		got, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Unexpected error from Decode: %v", err)
+		}
+		if !got.Time.Equal(want.Time) || got.Digest != want.Digest || got.HasDigest != want.HasDigest ||
+			got.Signature != want.Signature || got.Label != want.Label {
+			t.Fatalf("Decode() record %d = %+v, want %+v", i, got, want)
+		}
+		if want.HasDigest {
+			if ok, err := d.Verify(got); !ok {
+				t.Fatalf("Verify() record %d = false, want true: %v", i, err)
+			}
+		}
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Fatalf("Decode() after the last record = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("siglog"), 1)
+	var buf bytes.Buffer
+	e, _ := NewEncoder(&buf, schnorr.SchemeBIP340, keys[0].PublicKey)
+	e.Encode(&Record{Time: time.Now(), HasDigest: true, Label: "a"})
+	e.Close()
+	log := buf.Bytes()
+
+	if _, err := NewDecoder(bytes.NewReader([]byte("not a log at all"))); !errors.Is(err, ErrFormat) {
+		t.Fatalf("NewDecoder() = %v, want %v", err, ErrFormat)
+	}
+	d, err := NewDecoder(bytes.NewReader(log[:len(log)-10]))
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDecoder: %v", err)
+	}
+	if _, err := d.Decode(); !errors.Is(err, ErrFormat) {
+		t.Fatalf("Decode() of a truncated record = %v, want %v", err, ErrFormat)
+	}
+}
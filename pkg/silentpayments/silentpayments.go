@@ -0,0 +1,316 @@
+package silentpayments
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0352.mediawiki
+//
+
+var (
+	tagInputs       = []byte("BIP0352/Inputs")
+	tagSharedSecret = []byte("BIP0352/SharedSecret")
+	tagLabel        = []byte("BIP0352/Label")
+)
+
+// Outpoint identifies a transaction input. TxID is in the serialized
+// (little endian) byte order used inside transactions.
+type Outpoint struct {
+	TxID [32]byte
+	Vout uint32
+}
+
+func (o Outpoint) serialize() []byte {
+	b := make([]byte, 36)
+	copy(b, o.TxID[:])
+	binary.LittleEndian.PutUint32(b[32:], o.Vout)
+	return b
+}
+
+// Input is a sender owned input that is eligible for silent payments.
+// Taproot inputs have their key negated when it has an odd Y.
+type Input struct {
+	PrivKey *btcec.PrivateKey
+	Taproot bool
+}
+
+// Recipient is a silent payment address, a scan key and a spend key
+// which may already carry a label
+type Recipient struct {
+	Scan  *btcec.PublicKey
+	Spend *btcec.PublicKey
+}
+
+// FoundOutput is an output the receiver detected while scanning. Tweak is
+// what gets added to the spend private key to spend it, including the label
+// tweak when the output was paid to a labelled address.
+type FoundOutput struct {
+	Output [32]byte
+	Tweak  btcec.ModNScalar
+	Label  *uint32
+}
+
+// SmallestOutpoint returns the lexicographically smallest serialized outpoint
+func SmallestOutpoint(outpoints []Outpoint) (Outpoint, error) {
+	if len(outpoints) == 0 {
+		return Outpoint{}, fmt.Errorf("no outpoints supplied")
+	}
+
+	smallest := outpoints[0]
+	for _, o := range outpoints[1:] {
+		if bytes.Compare(o.serialize(), smallest.serialize()) < 0 {
+			smallest = o
+		}
+	}
+	return smallest, nil
+}
+
+// InputHash computes hash_BIP0352/Inputs(outpoint_L || A)
+func InputHash(outpoints []Outpoint, A *btcec.PublicKey) (btcec.ModNScalar, error) {
+	var scalar btcec.ModNScalar
+
+	smallest, err := SmallestOutpoint(outpoints)
+	if err != nil {
+		return scalar, err
+	}
+
+	h := chainhash.TaggedHash(tagInputs, smallest.serialize(), A.SerializeCompressed())
+	if overflow := scalar.SetBytes((*[32]byte)(h)); overflow != 0 || scalar.IsZero() {
+		return scalar, fmt.Errorf("input hash is not a valid scalar")
+	}
+	return scalar, nil
+}
+
+// SumInputKeys returns a, the sum of the input private keys
+func SumInputKeys(inputs []Input) (btcec.ModNScalar, error) {
+	var sum btcec.ModNScalar
+
+	for _, input := range inputs {
+		k := input.PrivKey.Key
+		if input.Taproot && input.PrivKey.PubKey().SerializeCompressed()[0] == 0x03 {
+			k.Negate()
+		}
+		sum.Add(&k)
+	}
+
+	if sum.IsZero() {
+		return sum, fmt.Errorf("input private keys sum to zero")
+	}
+	return sum, nil
+}
+
+// SumInputPubKeys returns A, the sum of the input public keys as seen by the
+// receiver. Taproot keys must already be lifted to their even Y point.
+func SumInputPubKeys(pubKeys []*btcec.PublicKey) (*btcec.PublicKey, error) {
+	if len(pubKeys) == 0 {
+		return nil, fmt.Errorf("no public keys supplied")
+	}
+
+	var sum btcec.JacobianPoint
+	for _, pubKey := range pubKeys {
+		var p btcec.JacobianPoint
+		pubKey.AsJacobian(&p)
+		btcec.AddNonConst(&sum, &p, &sum)
+	}
+
+	return toPublicKey(&sum)
+}
+
+// LabelTweak computes hash_BIP0352/Label(ser256(b_scan) || ser32(m))
+func LabelTweak(scanKey *btcec.PrivateKey, m uint32) btcec.ModNScalar {
+	var mBytes [4]byte
+	binary.BigEndian.PutUint32(mBytes[:], m)
+
+	h := chainhash.TaggedHash(tagLabel, scanKey.Serialize(), mBytes[:])
+
+	var tweak btcec.ModNScalar
+	tweak.SetBytes((*[32]byte)(h))
+	return tweak
+}
+
+// LabeledSpendKey returns B_m = B_spend + hash(b_scan || m)·G, the spend key
+// of the labelled address m
+func LabeledSpendKey(scanKey *btcec.PrivateKey, spendKey *btcec.PublicKey, m uint32) (*btcec.PublicKey, error) {
+	tweak := LabelTweak(scanKey, m)
+	return addTweak(spendKey, &tweak)
+}
+
+// sharedSecretTweak computes t_k = hash_BIP0352/SharedSecret(serP(S) || ser32(k))
+func sharedSecretTweak(shared *btcec.PublicKey, k uint32) (btcec.ModNScalar, error) {
+	var kBytes [4]byte
+	binary.BigEndian.PutUint32(kBytes[:], k)
+
+	h := chainhash.TaggedHash(tagSharedSecret, shared.SerializeCompressed(), kBytes[:])
+
+	var tweak btcec.ModNScalar
+	if overflow := tweak.SetBytes((*[32]byte)(h)); overflow != 0 || tweak.IsZero() {
+		return tweak, fmt.Errorf("shared secret tweak is not a valid scalar")
+	}
+	return tweak, nil
+}
+
+// SenderOutputs derives the x-only taproot output keys paying each
+// recipient. Outputs come back in the same order as the recipients.
+func SenderOutputs(inputs []Input, outpoints []Outpoint, recipients []Recipient) ([][32]byte, error) {
+	a, err := SumInputKeys(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	A := btcec.PrivKeyFromScalar(&a).PubKey()
+	inputHash, err := InputHash(outpoints, A)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret btcec.ModNScalar
+	secret.Mul2(&inputHash, &a)
+
+	// k counts up per scan key so that several outputs to the same
+	// recipient still produce distinct keys
+	counters := map[string]uint32{}
+	outputs := make([][32]byte, len(recipients))
+
+	for i, recipient := range recipients {
+		scanID := string(recipient.Scan.SerializeCompressed())
+
+		shared, err := multiply(recipient.Scan, &secret)
+		if err != nil {
+			return nil, err
+		}
+
+		tweak, err := sharedSecretTweak(shared, counters[scanID])
+		if err != nil {
+			return nil, err
+		}
+		counters[scanID]++
+
+		output, err := addTweak(recipient.Spend, &tweak)
+		if err != nil {
+			return nil, err
+		}
+		copy(outputs[i][:], schnorr.SerializePubKey(output))
+	}
+
+	return outputs, nil
+}
+
+// Scan looks for outputs in a transaction paying to the receiver. A is the
+// sum of the eligible input public keys and labels are the label numbers the
+// receiver hands out, which may be empty.
+func Scan(scanKey *btcec.PrivateKey, spendKey *btcec.PublicKey, outpoints []Outpoint, A *btcec.PublicKey, outputs [][32]byte, labels []uint32) ([]FoundOutput, error) {
+	inputHash, err := InputHash(outpoints, A)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret btcec.ModNScalar
+	secret.Mul2(&inputHash, &scanKey.Key)
+
+	shared, err := multiply(A, &secret)
+	if err != nil {
+		return nil, err
+	}
+
+	labelTweaks := make([]btcec.ModNScalar, len(labels))
+	for i, m := range labels {
+		labelTweaks[i] = LabelTweak(scanKey, m)
+	}
+
+	remaining := append([][32]byte{}, outputs...)
+	found := []FoundOutput{}
+
+	for k := uint32(0); ; k++ {
+		tweak, err := sharedSecretTweak(shared, k)
+		if err != nil {
+			return nil, err
+		}
+
+		Pk, err := addTweak(spendKey, &tweak)
+		if err != nil {
+			return nil, err
+		}
+
+		match := -1
+		var result FoundOutput
+
+		for i, output := range remaining {
+			if bytes.Equal(output[:], schnorr.SerializePubKey(Pk)) {
+				match = i
+				result = FoundOutput{Output: output, Tweak: tweak}
+				break
+			}
+
+			for j := range labelTweaks {
+				labelled, err := addTweak(Pk, &labelTweaks[j])
+				if err != nil {
+					continue
+				}
+				if bytes.Equal(output[:], schnorr.SerializePubKey(labelled)) {
+					match = i
+					m := labels[j]
+					result = FoundOutput{Output: output, Tweak: tweak, Label: &m}
+					result.Tweak.Add(&labelTweaks[j])
+					break
+				}
+			}
+			if match >= 0 {
+				break
+			}
+		}
+
+		// the sender increments k for each output, so the first k with no
+		// match means there is nothing more for us
+		if match < 0 {
+			break
+		}
+
+		found = append(found, result)
+		remaining = append(remaining[:match], remaining[match+1:]...)
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return bytes.Compare(found[i].Output[:], found[j].Output[:]) < 0
+	})
+
+	return found, nil
+}
+
+// SpendKey returns the private key that spends a found output with a
+// taproot key path signature
+func SpendKey(spendKey *btcec.PrivateKey, found FoundOutput) *btcec.PrivateKey {
+	d := spendKey.Key
+	d.Add(&found.Tweak)
+	return btcec.PrivKeyFromScalar(&d)
+}
+
+func multiply(P *btcec.PublicKey, k *btcec.ModNScalar) (*btcec.PublicKey, error) {
+	var p, result btcec.JacobianPoint
+	P.AsJacobian(&p)
+	btcec.ScalarMultNonConst(k, &p, &result)
+	return toPublicKey(&result)
+}
+
+func addTweak(P *btcec.PublicKey, t *btcec.ModNScalar) (*btcec.PublicKey, error) {
+	var p, tG, result btcec.JacobianPoint
+	P.AsJacobian(&p)
+	btcec.ScalarBaseMultNonConst(t, &tG)
+	btcec.AddNonConst(&p, &tG, &result)
+	return toPublicKey(&result)
+}
+
+func toPublicKey(p *btcec.JacobianPoint) (*btcec.PublicKey, error) {
+	if (p.X.IsZero() && p.Y.IsZero()) || p.Z.IsZero() {
+		return nil, fmt.Errorf("point is at infinity")
+	}
+	p.ToAffine()
+	return btcec.NewPublicKey(&p.X, &p.Y), nil
+}
@@ -0,0 +1,215 @@
+package silentpayments
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+func decodeKey(s string, t *testing.T) *btcec.PrivateKey {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Unexpected error from hex.DecodeString(%s): %v", s, err)
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(b)
+	return privKey
+}
+
+// evenPubKey is the public key a receiver sees for a taproot input
+func evenPubKey(privKey *btcec.PrivateKey, t *testing.T) *btcec.PublicKey {
+	pubKey, err := schnorr.ParsePubKey(schnorr.SerializePubKey(privKey.PubKey()))
+	if err != nil {
+		t.Fatalf("Unexpected error from schnorr.ParsePubKey: %v", err)
+	}
+	return pubKey
+}
+
+func TestSendAndScan(t *testing.T) {
+	input1 := decodeKey("eadc78165ff1f8ea94ad7cfdc54990738a4c53f6e0507b42154201b8e5dff3b1", t)
+	input2 := decodeKey("93f5ed907ad5b2bdbbdcb6d9116ebc0a4e1f92f910d5260237fa45a9408aad16", t)
+	scanKey := decodeKey("0f694e068028a717f8af6b9411f9a133dd3565258714cc226594b34db90c1f2c", t)
+	spendKey := decodeKey("9d6ad855ce3417ef84e836892e5a56392bfba05fa5d97ccea30e266f540e08b3", t)
+
+	outpoints := []Outpoint{
+		{TxID: [32]byte{0xf4, 0x18, 0x4f}, Vout: 0},
+		{TxID: [32]byte{0xa1, 0x07, 0x5d}, Vout: 1},
+	}
+	inputs := []Input{{PrivKey: input1, Taproot: true}, {PrivKey: input2}}
+	A, err := SumInputPubKeys([]*btcec.PublicKey{evenPubKey(input1, t), input2.PubKey()})
+	if err != nil {
+		t.Fatalf("Unexpected error from SumInputPubKeys: %v", err)
+	}
+
+	labelled, err := LabeledSpendKey(scanKey, spendKey.PubKey(), 7)
+	if err != nil {
+		t.Fatalf("Unexpected error from LabeledSpendKey: %v", err)
+	}
+
+	recipients := []Recipient{
+		{Scan: scanKey.PubKey(), Spend: spendKey.PubKey()},
+		{Scan: scanKey.PubKey(), Spend: spendKey.PubKey()},
+		{Scan: scanKey.PubKey(), Spend: labelled},
+	}
+
+	// when
+	outputs, err := SenderOutputs(inputs, outpoints, recipients)
+	if err != nil {
+		t.Fatalf("Unexpected error from SenderOutputs: %v", err)
+	}
+	if bytes.Equal(outputs[0][:], outputs[1][:]) {
+		t.Fatalf("SenderOutputs gave the same key twice for one recipient")
+	}
+
+	// an unrelated output in the same transaction
+	unrelated := [32]byte{}
+	copy(unrelated[:], schnorr.SerializePubKey(input2.PubKey()))
+
+	found, err := Scan(scanKey, spendKey.PubKey(), outpoints, A, append(outputs, unrelated), []uint32{7})
+	if err != nil {
+		t.Fatalf("Unexpected error from Scan: %v", err)
+	}
+
+	// then
+	if len(found) != 3 {
+		t.Fatalf("Scan found %d outputs, want 3", len(found))
+	}
+
+	labels := 0
+	for _, f := range found {
+		if f.Label != nil {
+			labels++
+			if *f.Label != 7 {
+				t.Fatalf("Scan found label %d, want 7", *f.Label)
+			}
+		}
+
+		// the derived spend key must control the output
+		d := SpendKey(spendKey, f)
+		if !bytes.Equal(schnorr.SerializePubKey(d.PubKey()), f.Output[:]) {
+			t.Fatalf("SpendKey does not match output %x", f.Output)
+		}
+
+		msg := [32]byte{1, 2, 3}
+		sig, err := schnorr.Sign(d, msg[:])
+		if err != nil {
+			t.Fatalf("Unexpected error from schnorr.Sign: %v", err)
+		}
+		outputKey, _ := schnorr.ParsePubKey(f.Output[:])
+		if !sig.Verify(msg[:], outputKey) {
+			t.Fatalf("signature with the spend key does not verify for %x", f.Output)
+		}
+	}
+	if labels != 1 {
+		t.Fatalf("Scan found %d labelled outputs, want 1", labels)
+	}
+
+	t.Run("Other receivers find nothing", func(t *testing.T) {
+		found, err := Scan(spendKey, scanKey.PubKey(), outpoints, A, outputs, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error from Scan: %v", err)
+		}
+		if len(found) != 0 {
+			t.Fatalf("Scan with the wrong keys found %d outputs, want 0", len(found))
+		}
+	})
+}
+
+// outpoint decodes a txid as it is displayed, the reverse of the byte order
+// inside transactions
+func outpoint(txid string, vout uint32, t *testing.T) Outpoint {
+	b, err := hex.DecodeString(txid)
+	if err != nil || len(b) != 32 {
+		t.Fatalf("Unexpected error from hex.DecodeString(%s): %v", txid, err)
+	}
+	o := Outpoint{Vout: vout}
+	for i := range b {
+		o.TxID[31-i] = b[i]
+	}
+	return o
+}
+
+// TestBIP352Vectors checks the simple send cases of the BIP-352 test
+// vectors, sending and then receiving
+func TestBIP352Vectors(t *testing.T) {
+	input1 := decodeKey("eadc78165ff1f8ea94ad7cfdc54990738a4c53f6e0507b42154201b8e5dff3b1", t)
+	input2 := decodeKey("93f5ed907ad5b2bdbbdcb5d9116ebc0a4e1f92f910d5260237fa45a9408aad16", t)
+	scanKey := decodeKey("0f694e068028a717f8af6b9411f9a133dd3565258714cc226594b34db90c1f2c", t)
+	spendKey := decodeKey("9d6ad855ce3417ef84e836892e5a56392bfba05fa5d97ccea30e266f540e08b3", t)
+	const (
+		txid1 = "f4184fc596403b9d638783cf57adfe4c75c605f6356fbc91338530e9831e9e16"
+		txid2 = "a1075db55d416d3ca199f55b6084e2115b9345e16c5cf302fc80e9d5fbf5d48d"
+	)
+
+	for _, tt := range []struct {
+		name      string
+		outpoints []Outpoint
+		inputs    []Input
+		want      string
+	}{
+		{
+			"Simple send: two inputs",
+			[]Outpoint{outpoint(txid1, 0, t), outpoint(txid2, 0, t)},
+			[]Input{{PrivKey: input1}, {PrivKey: input2}},
+			"3e9fce73d4e77a4809908e3c3a2e54ee147b9312dc5044a193d1fc85de46e3c1",
+		},
+		{
+			"Simple send: two inputs, order reversed",
+			[]Outpoint{outpoint(txid2, 0, t), outpoint(txid1, 0, t)},
+			[]Input{{PrivKey: input2}, {PrivKey: input1}},
+			"3e9fce73d4e77a4809908e3c3a2e54ee147b9312dc5044a193d1fc85de46e3c1",
+		},
+		{
+			"Simple send: two inputs from the same transaction",
+			[]Outpoint{outpoint(txid1, 3, t), outpoint(txid1, 7, t)},
+			[]Input{{PrivKey: input1}, {PrivKey: input2}},
+			"79e71baa2ba3fc66396de3a04f168c7bf24d6870ec88ca877754790c1db357b6",
+		},
+		{
+			"Simple send: two inputs from the same transaction, order reversed",
+			[]Outpoint{outpoint(txid1, 7, t), outpoint(txid1, 3, t)},
+			[]Input{{PrivKey: input2}, {PrivKey: input1}},
+			"79e71baa2ba3fc66396de3a04f168c7bf24d6870ec88ca877754790c1db357b6",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			recipients := []Recipient{{Scan: scanKey.PubKey(), Spend: spendKey.PubKey()}}
+			outputs, err := SenderOutputs(tt.inputs, tt.outpoints, recipients)
+			if err != nil {
+				t.Fatalf("Unexpected error from SenderOutputs: %v", err)
+			}
+
+			// then
+			if len(outputs) != 1 {
+				t.Fatalf("SenderOutputs gave %d outputs, want 1", len(outputs))
+			}
+			if got := hex.EncodeToString(outputs[0][:]); got != tt.want {
+				t.Fatalf("SenderOutputs() = %s, want %s", got, tt.want)
+			}
+
+			t.Run("The receiver finds the output", func(t *testing.T) {
+				A, err := SumInputPubKeys([]*btcec.PublicKey{tt.inputs[0].PrivKey.PubKey(), tt.inputs[1].PrivKey.PubKey()})
+				if err != nil {
+					t.Fatalf("Unexpected error from SumInputPubKeys: %v", err)
+				}
+				found, err := Scan(scanKey, spendKey.PubKey(), tt.outpoints, A, outputs, nil)
+				if err != nil {
+					t.Fatalf("Unexpected error from Scan: %v", err)
+				}
+				if len(found) != 1 {
+					t.Fatalf("Scan found %d outputs, want 1", len(found))
+				}
+				if got := hex.EncodeToString(found[0].Output[:]); got != tt.want {
+					t.Fatalf("Scan() found %s, want %s", got, tt.want)
+				}
+				d := SpendKey(spendKey, found[0])
+				if got := hex.EncodeToString(schnorr.SerializePubKey(d.PubKey())); got != tt.want {
+					t.Fatalf("SpendKey() controls %s, want %s", got, tt.want)
+				}
+			})
+		})
+	}
+}
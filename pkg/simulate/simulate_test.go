@@ -0,0 +1,105 @@
+package simulate
+
+import (
+	"crypto/sha256"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+func verify(t *testing.T, r *Result, message []byte) {
+	msg := sha256.Sum256(message)
+	pk, err := schnorr.ParsePubKey(r.PublicKey[1:])
+	if err != nil {
+		t.Fatalf("Unexpected error from ParsePubKey: %v", err)
+	}
+	sig, err := schnorr.ParseSignature(r.Signature[:])
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseSignature: %v", err)
+	}
+	if !sig.Verify(msg[:], pk) {
+		t.Fatalf("signature %x does not verify", r.Signature)
+	}
+}
+
+func TestRun(t *testing.T) {
+	message := []byte("rehearsal")
+
+	tests := []struct {
+		name      string
+		kind      string
+		faults    []string
+		completed bool
+		blamed    []uint32
+	}{
+		{"dkg", KindDKG, nil, true, nil},
+		{"dkg dropped commitment", KindDKG, []string{"drop:2"}, false, []uint32{2}},
+		{"dkg dropped shares", KindDKG, []string{"drop:3:2"}, false, []uint32{3}},
+		{"dkg bad share", KindDKG, []string{"bad-share:1"}, false, []uint32{1}},
+		{"dkg bad proof", KindDKG, []string{"bad-nonce:4"}, false, []uint32{4}},
+		{"musig", KindMuSig, nil, true, nil},
+		{"musig dropped nonce", KindMuSig, []string{"drop:2"}, false, []uint32{2}},
+		{"musig bad partial signature", KindMuSig, []string{"bad-share:3"}, false, []uint32{3}},
+		{"musig bad nonce", KindMuSig, []string{"bad-nonce:1"}, false, []uint32{1}},
+		{"frost", KindFROST, nil, true, nil},
+		{"frost retried", KindFROST, []string{"bad-share:2"}, true, []uint32{2}},
+		{"frost dropped commitment", KindFROST, []string{"drop:1"}, true, []uint32{1}},
+		{"frost bad nonce", KindFROST, []string{"bad-nonce:4"}, true, []uint32{4}},
+		{"frost too few left", KindFROST, []string{"bad-share:1", "bad-nonce:2", "drop:3:2"}, false, []uint32{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			cfg := Config{Kind: tt.kind, Participants: 4, Threshold: 2, Message: message}
+			for _, s := range tt.faults {
+				f, err := ParseFault(s)
+				if err != nil {
+					t.Fatalf("Unexpected error from ParseFault: %v", err)
+				}
+				cfg.Faults = append(cfg.Faults, f)
+			}
+			events := 0
+			cfg.Events = func(Event) { events++ }
+
+			// when
+			r, err := Run(cfg)
+
+			// then
+			if err != nil {
+				t.Fatalf("Unexpected error from Run: %v", err)
+			}
+			if r.Completed != tt.completed {
+				t.Fatalf("Completed = %v, want %v, failure %q", r.Completed, tt.completed, r.Failure)
+			}
+			if !reflect.DeepEqual(r.Blamed, tt.blamed) {
+				t.Fatalf("Blamed = %v, want %v", r.Blamed, tt.blamed)
+			}
+			if events != len(r.Events) {
+				t.Fatalf("Events called %d times, want %d", events, len(r.Events))
+			}
+			if r.Completed && tt.kind != KindDKG {
+				verify(t, r, message)
+			}
+		})
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	valid := map[string]Fault{
+		"drop:3":      {FaultDrop, 3, 1},
+		"drop:3:2":    {FaultDrop, 3, 2},
+		"bad-share:1": {FaultBadShare, 1, 2},
+		"bad-nonce:2": {FaultBadNonce, 2, 1},
+	}
+	for s, want := range valid {
+		if f, err := ParseFault(s); err != nil || f != want {
+			t.Fatalf("ParseFault(%q) = %v, %v, want %v", s, f, err, want)
+		}
+	}
+	for _, s := range []string{"drop", "drop:0", "drop:1:3", "bad-share:1:1", "late:1"} {
+		if _, err := ParseFault(s); err == nil {
+			t.Fatalf("ParseFault(%q) = nil error, want one", s)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dir keeps each value in a file, <dir>/<bucket>/<hex key>, and each log in
+// <dir>/<log>.log. It's for a daemon on one host; replicas on several hosts
+// share a SQL store.
+type Dir struct {
+	dir string
+	now func() time.Time
+
+	// mu orders Create's check of an expired value against its removal
+	mu sync.Mutex
+}
+
+func NewDir(dir string) (*Dir, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("dir store needs a directory")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Dir{dir: dir, now: time.Now}, nil
+}
+
+func (d *Dir) path(bucket, key string) (string, error) {
+	if !validName(bucket) {
+		return "", fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	return filepath.Join(d.dir, bucket, hex.EncodeToString([]byte(key))), nil
+}
+
+// validName keeps bucket and log names to one plain path element
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeValue prefixes the value with its expiry in unix nanoseconds
+func encodeValue(value []byte, expires time.Time) []byte {
+	var at int64
+	if !expires.IsZero() {
+		at = expires.UnixNano()
+	}
+	b := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(at))
+	return append(b, value...)
+}
+
+func decodeValue(data []byte) ([]byte, time.Time, error) {
+	if len(data) < 8 {
+		return nil, time.Time{}, fmt.Errorf("stored value is truncated")
+	}
+	var expires time.Time
+	if at := int64(binary.BigEndian.Uint64(data)); at != 0 {
+		expires = time.Unix(0, at)
+	}
+	return data[8:], expires, nil
+}
+
+// read returns the live value at p
+func (d *Dir) read(p string) ([]byte, error) {
+	value, _, err := d.readExpiry(p)
+	return value, err
+}
+
+// readExpiry returns the live value at p and when it expires
+func (d *Dir) readExpiry(p string) ([]byte, time.Time, error) {
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	value, expires, err := decodeValue(data)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if expired(expires, d.now()) {
+		return nil, time.Time{}, ErrNotFound
+	}
+	return value, expires, nil
+}
+
+func (d *Dir) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	p, err := d.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	return d.read(p)
+}
+
+func (d *Dir) Put(ctx context.Context, bucket, key string, value []byte, expires time.Time) error {
+	p, err := d.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	tmp := p + "." + randomSuffix() + ".tmp"
+	if err := os.WriteFile(tmp, encodeValue(value, expires), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (d *Dir) Create(ctx context.Context, bucket, key string, value []byte, expires time.Time) error {
+	p, err := d.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		// an expired value doesn't count
+		if _, err := d.read(p); err != ErrNotFound {
+			if err != nil {
+				return err
+			}
+			return ErrExists
+		}
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		f, err = os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	}
+	if os.IsExist(err) {
+		return ErrExists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(encodeValue(value, expires)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (d *Dir) Take(ctx context.Context, bucket, key string) ([]byte, error) {
+	p, err := d.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := d.take(p)
+	return value, err
+}
+
+// take removes the value at p, returning it and when it expires
+func (d *Dir) take(p string) ([]byte, time.Time, error) {
+	// only one rename of the file succeeds
+	taken := p + "." + randomSuffix() + ".taken"
+	if err := os.Rename(p, taken); os.IsNotExist(err) {
+		return nil, time.Time{}, ErrNotFound
+	} else if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer os.Remove(taken)
+	return d.readExpiry(taken)
+}
+
+// Swap takes the value, so no other Swap sees it while it's compared, then
+// creates the new one, or the old one again if it didn't match. A Create
+// in between wins, and the Swap returns ErrChanged.
+func (d *Dir) Swap(ctx context.Context, bucket, key string, old, value []byte, expires time.Time) error {
+	p, err := d.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	current, currentExpires, err := d.take(p)
+	if err == ErrNotFound {
+		return ErrChanged
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, old) {
+		if err := d.Create(ctx, bucket, key, current, currentExpires); err != nil && err != ErrExists {
+			return err
+		}
+		return ErrChanged
+	}
+	if err := d.Create(ctx, bucket, key, value, expires); err == ErrExists {
+		return ErrChanged
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *Dir) Delete(ctx context.Context, bucket, key string) error {
+	p, err := d.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *Dir) List(ctx context.Context, bucket string) ([]string, error) {
+	if !validName(bucket) {
+		return nil, fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	entries, err := os.ReadDir(filepath.Join(d.dir, bucket))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// hex names sort as their keys do
+	keys := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.Contains(name, ".") {
+			// being written or taken
+			continue
+		}
+		key, err := hex.DecodeString(name)
+		if err != nil {
+			continue
+		}
+		if _, err := d.read(filepath.Join(d.dir, bucket, name)); err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		keys = append(keys, string(key))
+	}
+	return keys, nil
+}
+
+func (d *Dir) Append(ctx context.Context, log string, record []byte) error {
+	if !validName(log) {
+		return fmt.Errorf("invalid log name %q", log)
+	}
+	f, err := os.OpenFile(filepath.Join(d.dir, log+".log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(record); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (d *Dir) Close() error {
+	return nil
+}
+
+func randomSuffix() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryValue struct {
+	value   []byte
+	expires time.Time
+}
+
+// Memory keeps state in the process, for tests and single daemons that can
+// lose it on restart
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]memoryValue
+	logs    map[string][][]byte
+	now     func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]map[string]memoryValue{}, logs: map[string][][]byte{}, now: time.Now}
+}
+
+// lookup returns the live value of the key, dropping it if it has expired.
+// The lock must be held.
+func (m *Memory) lookup(bucket, key string) (memoryValue, bool) {
+	v, ok := m.buckets[bucket][key]
+	if ok && expired(v.expires, m.now()) {
+		delete(m.buckets[bucket], key)
+		return memoryValue{}, false
+	}
+	return v, ok
+}
+
+func (m *Memory) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.lookup(bucket, key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, v.value...), nil
+}
+
+func (m *Memory) Put(ctx context.Context, bucket, key string, value []byte, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(bucket, key, value, expires)
+	return nil
+}
+
+func (m *Memory) put(bucket, key string, value []byte, expires time.Time) {
+	b, ok := m.buckets[bucket]
+	if !ok {
+		b = map[string]memoryValue{}
+		m.buckets[bucket] = b
+	}
+	// sweep as we go, ledgers of one time ids only ever grow otherwise
+	now := m.now()
+	for k, v := range b {
+		if expired(v.expires, now) {
+			delete(b, k)
+		}
+	}
+	b[key] = memoryValue{value: append([]byte{}, value...), expires: expires}
+}
+
+func (m *Memory) Create(ctx context.Context, bucket, key string, value []byte, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(bucket, key); ok {
+		return ErrExists
+	}
+	m.put(bucket, key, value, expires)
+	return nil
+}
+
+func (m *Memory) Take(ctx context.Context, bucket, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.lookup(bucket, key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(m.buckets[bucket], key)
+	return v.value, nil
+}
+
+func (m *Memory) Swap(ctx context.Context, bucket, key string, old, value []byte, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.lookup(bucket, key)
+	if !ok || !bytes.Equal(v.value, old) {
+		return ErrChanged
+	}
+	m.put(bucket, key, value, expires)
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context, bucket string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []string{}
+	for key := range m.buckets[bucket] {
+		if _, ok := m.lookup(bucket, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *Memory) Append(ctx context.Context, log string, record []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs[log] = append(m.logs[log], append([]byte{}, record...))
+	return nil
+}
+
+// Log returns the records appended to the log
+func (m *Memory) Log(log string) [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]byte{}, m.logs[log]...)
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQL dialects
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+// drivers are the database/sql driver names tried for each dialect, the
+// common drivers' registered names
+var drivers = map[string][]string{
+	SQLite:   {"sqlite3", "sqlite"},
+	Postgres: {"postgres", "pgx"},
+}
+
+// schemas differ in their blob and auto increment types
+var schemas = map[string][]string{
+	SQLite: {
+		`CREATE TABLE IF NOT EXISTS schnorr_state (bucket TEXT NOT NULL, name TEXT NOT NULL, value BLOB NOT NULL, expires INTEGER NOT NULL, PRIMARY KEY (bucket, name))`,
+		`CREATE TABLE IF NOT EXISTS schnorr_log (id INTEGER PRIMARY KEY AUTOINCREMENT, log TEXT NOT NULL, time INTEGER NOT NULL, record BLOB NOT NULL)`,
+	},
+	Postgres: {
+		`CREATE TABLE IF NOT EXISTS schnorr_state (bucket TEXT NOT NULL, name TEXT NOT NULL, value BYTEA NOT NULL, expires BIGINT NOT NULL, PRIMARY KEY (bucket, name))`,
+		`CREATE TABLE IF NOT EXISTS schnorr_log (id BIGSERIAL PRIMARY KEY, log TEXT NOT NULL, time BIGINT NOT NULL, record BYTEA NOT NULL)`,
+	},
+}
+
+// sweepInterval is how often expired values are deleted
+const sweepInterval = time.Minute
+
+// SQL keeps state in SQLite or Postgres. Every replica of the daemon can
+// share a Postgres database; SQLite suits one host, as a file several
+// processes there open.
+//
+// Expiry is kept as unix nanoseconds, 0 for never. Create is an insert that
+// only overwrites an expired row, and Take a DELETE RETURNING, both needing
+// SQLite 3.35 or later.
+type SQL struct {
+	db      *sql.DB
+	dialect string
+	now     func() time.Time
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// OpenSQL opens the database with whichever driver for the dialect is
+// linked into the binary: sqlite3 or sqlite, postgres or pgx. None are by
+// default; add a blank import of one, such as _ "github.com/lib/pq".
+func OpenSQL(dialect, dsn string) (*SQL, error) {
+	names, ok := drivers[dialect]
+	if !ok {
+		return nil, fmt.Errorf("unknown sql dialect %q, want %s or %s", dialect, SQLite, Postgres)
+	}
+	registered := map[string]bool{}
+	for _, name := range sql.Drivers() {
+		registered[name] = true
+	}
+	for _, name := range names {
+		if !registered[name] {
+			continue
+		}
+		db, err := sql.Open(name, dsn)
+		if err != nil {
+			return nil, err
+		}
+		s, err := NewSQL(db, dialect)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("no %s driver is linked in, build with one of %s imported", dialect, strings.Join(names, " or "))
+}
+
+// NewSQL keeps state in the database, creating its tables if they're not
+// there
+func NewSQL(db *sql.DB, dialect string) (*SQL, error) {
+	schema, ok := schemas[dialect]
+	if !ok {
+		return nil, fmt.Errorf("unknown sql dialect %q, want %s or %s", dialect, SQLite, Postgres)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+	return &SQL{db: db, dialect: dialect, now: time.Now}, nil
+}
+
+// rebind turns the ? placeholders into Postgres's $1, $2...
+func rebind(dialect, query string) string {
+	if dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (s *SQL) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db.ExecContext(ctx, rebind(s.dialect, query), args...)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func (s *SQL) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRowContext(ctx, rebind(s.dialect,
+		`SELECT value FROM schnorr_state WHERE bucket = ? AND name = ? AND (expires = 0 OR expires >= ?)`),
+		bucket, key, s.now().UnixNano()).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *SQL) Put(ctx context.Context, bucket, key string, value []byte, expires time.Time) error {
+	s.sweep(ctx)
+	_, err := s.exec(ctx,
+		`INSERT INTO schnorr_state (bucket, name, value, expires) VALUES (?, ?, ?, ?)
+		ON CONFLICT (bucket, name) DO UPDATE SET value = excluded.value, expires = excluded.expires`,
+		bucket, key, value, unixNano(expires))
+	return err
+}
+
+func (s *SQL) Create(ctx context.Context, bucket, key string, value []byte, expires time.Time) error {
+	s.sweep(ctx)
+	result, err := s.exec(ctx,
+		`INSERT INTO schnorr_state (bucket, name, value, expires) VALUES (?, ?, ?, ?)
+		ON CONFLICT (bucket, name) DO UPDATE SET value = excluded.value, expires = excluded.expires
+		WHERE schnorr_state.expires <> 0 AND schnorr_state.expires < ?`,
+		bucket, key, value, unixNano(expires), s.now().UnixNano())
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrExists
+	}
+	return nil
+}
+
+func (s *SQL) Take(ctx context.Context, bucket, key string) ([]byte, error) {
+	var value []byte
+	var expires int64
+	err := s.db.QueryRowContext(ctx, rebind(s.dialect,
+		`DELETE FROM schnorr_state WHERE bucket = ? AND name = ? RETURNING value, expires`),
+		bucket, key).Scan(&value, &expires)
+	if err == sql.ErrNoRows || err == nil && expires != 0 && s.now().UnixNano() > expires {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *SQL) Swap(ctx context.Context, bucket, key string, old, value []byte, expires time.Time) error {
+	result, err := s.exec(ctx,
+		`UPDATE schnorr_state SET value = ?, expires = ?
+		WHERE bucket = ? AND name = ? AND value = ? AND (expires = 0 OR expires >= ?)`,
+		value, unixNano(expires), bucket, key, old, s.now().UnixNano())
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrChanged
+	}
+	return nil
+}
+
+func (s *SQL) Delete(ctx context.Context, bucket, key string) error {
+	_, err := s.exec(ctx, `DELETE FROM schnorr_state WHERE bucket = ? AND name = ?`, bucket, key)
+	return err
+}
+
+func (s *SQL) List(ctx context.Context, bucket string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, rebind(s.dialect,
+		`SELECT name FROM schnorr_state WHERE bucket = ? AND (expires = 0 OR expires >= ?) ORDER BY name`),
+		bucket, s.now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQL) Append(ctx context.Context, log string, record []byte) error {
+	_, err := s.exec(ctx, `INSERT INTO schnorr_log (log, time, record) VALUES (?, ?, ?)`, log, s.now().UnixNano(), record)
+	return err
+}
+
+// sweep deletes expired values, at most once a sweepInterval
+func (s *SQL) sweep(ctx context.Context) {
+	s.mu.Lock()
+	now := s.now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+	s.exec(ctx, `DELETE FROM schnorr_state WHERE expires <> 0 AND expires < ?`, now.UnixNano())
+}
+
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+//
+// Where the daemon keeps state that has to outlive the process or be shared
+// between replicas of it: two-party shares and sessions, ledgers of nonces
+// and proofs already used, and audit logs. Values are kept by bucket and
+// key, and may expire; logs are only appended to.
+//
+// Create and Take are what make several replicas safe to run against one
+// store. Create records a nonce or proof id only if no replica has, and Take
+// hands a pending session to exactly one of the replicas it's presented to.
+// Swap renews a leader's lease only while it still holds it. Each backend
+// makes them atomic in its own way: a mutex in memory, O_EXCL and rename in
+// a directory, conditional inserts and updates and DELETE RETURNING in SQL.
+//
+
+var (
+	// ErrNotFound is a key with no value, or one that has expired
+	ErrNotFound = errors.New("not found")
+
+	// ErrExists is Create of a key that already has a value
+	ErrExists = errors.New("already exists")
+
+	// ErrChanged is Swap of a key that no longer has the old value
+	ErrChanged = errors.New("value has changed")
+)
+
+// Store keeps daemon state. Expires is when a value goes, never if zero.
+type Store interface {
+	Get(ctx context.Context, bucket, key string) ([]byte, error)
+	Put(ctx context.Context, bucket, key string, value []byte, expires time.Time) error
+
+	// Create stores the value only if the key has none, returning ErrExists
+	// if it has
+	Create(ctx context.Context, bucket, key string, value []byte, expires time.Time) error
+
+	// Take returns the value and deletes it, so of two callers taking the
+	// same key only one gets it
+	Take(ctx context.Context, bucket, key string) ([]byte, error)
+
+	// Swap replaces the value only if the key still has old, returning
+	// ErrChanged if it hasn't
+	Swap(ctx context.Context, bucket, key string, old, value []byte, expires time.Time) error
+
+	Delete(ctx context.Context, bucket, key string) error
+
+	// List returns the keys in the bucket with values, in order
+	List(ctx context.Context, bucket string) ([]string, error)
+
+	// Append adds a record to the end of the named log
+	Append(ctx context.Context, log string, record []byte) error
+
+	Close() error
+}
+
+// Open opens the store named by spec: memory or dir:<path>. SQL stores
+// aren't named by spec, as no database driver is linked into the binary;
+// programs that link one open them with OpenSQL or NewSQL.
+func Open(spec string) (Store, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "memory":
+		return NewMemory(), nil
+	case "dir":
+		return NewDir(arg)
+	}
+	return nil, fmt.Errorf("unknown store %q, want memory or dir:<path>", spec)
+}
+
+// logWriter appends each write to a log
+type logWriter struct {
+	ctx   context.Context
+	store Store
+	log   string
+}
+
+// LogWriter returns a writer appending each write as a record of the log,
+// for audit logs written one entry per write
+func LogWriter(ctx context.Context, s Store, log string) io.Writer {
+	return &logWriter{ctx: ctx, store: s, log: log}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	record := append([]byte{}, p...)
+	if err := w.store.Append(w.ctx, w.log, record); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func expired(expires time.Time, now time.Time) bool {
+	return !expires.IsZero() && now.After(expires)
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore checks the behaviour every backend shares
+func testStore(t *testing.T, s Store, advance func(time.Duration)) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("Put and Get", func(t *testing.T) {
+		if err := s.Put(ctx, "shares", "a", []byte("one"), time.Time{}); err != nil {
+			t.Fatalf("Unexpected error from Put: %v", err)
+		}
+		if err := s.Put(ctx, "shares", "a", []byte("two"), time.Time{}); err != nil {
+			t.Fatalf("Unexpected error from Put: %v", err)
+		}
+		value, err := s.Get(ctx, "shares", "a")
+		if err != nil || string(value) != "two" {
+			t.Fatalf("Get() = %q, %v, want two", value, err)
+		}
+		if _, err := s.Get(ctx, "shares", "b"); err != ErrNotFound {
+			t.Fatalf("Get() of a missing key = %v, want ErrNotFound", err)
+		}
+		if err := s.Delete(ctx, "shares", "a"); err != nil {
+			t.Fatalf("Unexpected error from Delete: %v", err)
+		}
+		if _, err := s.Get(ctx, "shares", "a"); err != ErrNotFound {
+			t.Fatalf("Get() after Delete = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		if err := s.Create(ctx, "nonces", "n1", nil, now.Add(time.Minute)); err != nil {
+			t.Fatalf("Unexpected error from Create: %v", err)
+		}
+		if err := s.Create(ctx, "nonces", "n1", nil, now.Add(time.Minute)); err != ErrExists {
+			t.Fatalf("Create() twice = %v, want ErrExists", err)
+		}
+	})
+
+	t.Run("Create at once", func(t *testing.T) {
+		var wg sync.WaitGroup
+		created := make(chan bool, 8)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				created <- s.Create(ctx, "nonces", "n2", nil, time.Time{}) == nil
+			}()
+		}
+		wg.Wait()
+		close(created)
+		n := 0
+		for ok := range created {
+			if ok {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Fatalf("%d concurrent Creates succeeded, want 1", n)
+		}
+	})
+
+	t.Run("Take", func(t *testing.T) {
+		s.Put(ctx, "sessions", "s1", []byte("pending"), time.Time{})
+		value, err := s.Take(ctx, "sessions", "s1")
+		if err != nil || string(value) != "pending" {
+			t.Fatalf("Take() = %q, %v, want pending", value, err)
+		}
+		if _, err := s.Take(ctx, "sessions", "s1"); err != ErrNotFound {
+			t.Fatalf("Take() twice = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		s.Put(ctx, "sessions", "s2", []byte("pending"), now.Add(time.Minute))
+		s.Create(ctx, "nonces", "n3", nil, now.Add(time.Minute))
+		advance(2 * time.Minute)
+
+		if _, err := s.Get(ctx, "sessions", "s2"); err != ErrNotFound {
+			t.Fatalf("Get() of an expired value = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Take(ctx, "sessions", "s2"); err != ErrNotFound {
+			t.Fatalf("Take() of an expired value = %v, want ErrNotFound", err)
+		}
+		if err := s.Create(ctx, "nonces", "n3", nil, time.Time{}); err != nil {
+			t.Fatalf("Create() over an expired value = %v, want nil", err)
+		}
+	})
+
+	t.Run("Swap", func(t *testing.T) {
+		expires := time.Now().Add(time.Hour)
+		s.Put(ctx, "leases", "signer", []byte("a"), expires)
+		if err := s.Swap(ctx, "leases", "signer", []byte("b"), []byte("c"), expires); err != ErrChanged {
+			t.Fatalf("Swap() from the wrong value = %v, want ErrChanged", err)
+		}
+		if err := s.Swap(ctx, "leases", "signer", []byte("a"), []byte("b"), expires); err != nil {
+			t.Fatalf("Unexpected error from Swap: %v", err)
+		}
+		value, err := s.Get(ctx, "leases", "signer")
+		if err != nil || string(value) != "b" {
+			t.Fatalf("Get() after Swap = %q, %v, want b", value, err)
+		}
+		if err := s.Swap(ctx, "leases", "missing", nil, []byte("b"), expires); err != ErrChanged {
+			t.Fatalf("Swap() of a missing key = %v, want ErrChanged", err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		s.Put(ctx, "keys", "b", []byte("2"), time.Time{})
+		s.Put(ctx, "keys", "a", []byte("1"), time.Time{})
+		s.Put(ctx, "keys", "c", []byte("3"), time.Now().Add(-time.Hour))
+		keys, err := s.List(ctx, "keys")
+		if err != nil {
+			t.Fatalf("Unexpected error from List: %v", err)
+		}
+		if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+			t.Fatalf("List() = %q, want [a b]", keys)
+		}
+	})
+
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	offset := time.Duration(0)
+	m.now = func() time.Time { return time.Now().Add(offset) }
+	testStore(t, m, func(d time.Duration) { offset += d })
+
+	w := LogWriter(context.Background(), m, "audit")
+	fmt.Fprintf(w, "one\n")
+	fmt.Fprintf(w, "two\n")
+	if log := m.Log("audit"); len(log) != 2 || string(log[1]) != "two\n" {
+		t.Fatalf("Log() = %q, want the two records", log)
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDir: %v", err)
+	}
+	offset := time.Duration(0)
+	d.now = func() time.Time { return time.Now().Add(offset) }
+	testStore(t, d, func(d time.Duration) { offset += d })
+
+	if err := d.Put(context.Background(), "../x", "a", nil, time.Time{}); err == nil {
+		t.Fatalf("Put() with bucket ../x = nil error, want one")
+	}
+
+	w := LogWriter(context.Background(), d, "audit")
+	fmt.Fprintf(w, "one\n")
+	fmt.Fprintf(w, "two\n")
+	data, err := os.ReadFile(filepath.Join(dir, "audit.log"))
+	if err != nil || !bytes.Equal(data, []byte("one\ntwo\n")) {
+		t.Fatalf("audit.log = %q, %v, want both records", data, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open("memory"); err != nil {
+		t.Fatalf("Unexpected error from Open(memory): %v", err)
+	}
+	if _, err := Open("dir:" + t.TempDir()); err != nil {
+		t.Fatalf("Unexpected error from Open(dir): %v", err)
+	}
+	// SQL stores are opened with OpenSQL, not by spec
+	for _, spec := range []string{"sqlite:state.db", "postgres:postgres://localhost/schnorr", "redis:localhost", "dir:"} {
+		if _, err := Open(spec); err == nil {
+			t.Fatalf("Open(%q) = nil error, want one", spec)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT value FROM schnorr_state WHERE bucket = ? AND name = ?`
+	if got := rebind(Postgres, query); got != `SELECT value FROM schnorr_state WHERE bucket = $1 AND name = $2` {
+		t.Fatalf("rebind(postgres) = %s", got)
+	}
+	if got := rebind(SQLite, query); got != query {
+		t.Fatalf("rebind(sqlite) = %s", got)
+	}
+}
@@ -0,0 +1,89 @@
+package taggedhash
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"hash"
+	"sync"
+)
+
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0340.mediawiki#design
+//
+// A tagged hash is sha256(sha256(tag) || sha256(tag) || msg). The prefix is
+// exactly one SHA-256 block, so the state after it, the tag's midstate, can
+// be computed once and every hash under the tag starts from there, saving a
+// compression and the hash of the tag itself.
+//
+
+// Tag is a tag with its midstate precomputed
+type Tag struct {
+	name     string
+	midstate []byte
+}
+
+var (
+	// BIP340Challenge is the tag of BIP-340 challenges
+	BIP340Challenge = New("BIP0340/challenge")
+
+	// BIP340Aux is the tag hashing the auxiliary randomness of BIP-340 nonces
+	BIP340Aux = New("BIP0340/aux")
+
+	// BIP340Nonce is the tag of BIP-340 nonce derivation
+	BIP340Nonce = New("BIP0340/nonce")
+)
+
+// New hashes the tag prefix and keeps the midstate
+func New(name string) *Tag {
+	tagHash := sha256.Sum256([]byte(name))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+
+	// the standard library's sha256 has marshalled its state since go 1.8
+	midstate, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	return &Tag{name: name, midstate: midstate}
+}
+
+// Name returns the tag
+func (t *Tag) Name() string {
+	return t.name
+}
+
+// Hash returns a sha256 hash already holding the tag prefix, for data too
+// large or too scattered to pass to Sum
+func (t *Tag) Hash() hash.Hash {
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(t.midstate); err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// Sum hashes the concatenation of data under the tag
+func (t *Tag) Sum(data ...[]byte) [32]byte {
+	h := t.Hash()
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// tags caches the midstates of tags used through Sum
+var tags sync.Map
+
+// Sum hashes data under the named tag, computing its midstate the first
+// time the tag is seen
+func Sum(name string, data ...[]byte) [32]byte {
+	t, ok := tags.Load(name)
+	if !ok {
+		t, _ = tags.LoadOrStore(name, New(name))
+	}
+	return t.(*Tag).Sum(data...)
+}
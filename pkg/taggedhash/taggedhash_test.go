@@ -0,0 +1,45 @@
+package taggedhash
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func naive(tag string, data []byte) [32]byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	return sha256.Sum256(append(append(tagHash[:], tagHash[:]...), data...))
+}
+
+func TestSum(t *testing.T) {
+	for _, tag := range []string{"BIP0340/challenge", "TapLeaf", "", "a tag longer than one sha256 block of sixty four bytes, to be sure"} {
+		// given
+		data := []byte("some data to hash under the tag")
+
+		// when
+		got := Sum(tag, data[:7], data[7:])
+		again := New(tag).Sum(data)
+
+		// then
+		if want := naive(tag, data); got != want || again != want {
+			t.Fatalf("Sum(%q) = %x and %x, want %x", tag, got, again, want)
+		}
+	}
+
+	if BIP340Nonce.Name() != "BIP0340/nonce" {
+		t.Fatalf("Name() = %q, want BIP0340/nonce", BIP340Nonce.Name())
+	}
+}
+
+func BenchmarkSum(b *testing.B) {
+	data := make([]byte, 96)
+	b.Run("midstate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			BIP340Challenge.Sum(data)
+		}
+	})
+	b.Run("naive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			naive("BIP0340/challenge", data)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package textnorm
+
+import (
+	"bytes"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+//
+// Text messages normalized the same way wherever they are signed or
+// verified, so a message typed on one platform verifies on another: the
+// same text can be written with precomposed or combining accents, and ends
+// its lines in \r\n on Windows and in \n elsewhere, and each spelling
+// hashes differently.
+//
+// Normalize puts text in Unicode Normalization Form C and ends every line
+// in \n, and can strip whitespace at the ends of lines that editors add or
+// remove. NFC is golang.org/x/text/unicode/norm's. Bytes that aren't UTF-8
+// are kept as they are, so normalizing never fails, and the same bytes
+// always normalize to the same text.
+//
+
+// Options are the optional steps of Normalize
+type Options struct {
+	// TrimTrailing strips spaces and tabs from the ends of lines
+	TrimTrailing bool
+}
+
+// Normalize returns the text in NFC, with a leading byte order mark
+// dropped and \r\n and lone \r line endings turned into \n
+func Normalize(text []byte, opts Options) []byte {
+	text = bytes.TrimPrefix(text, []byte("\uFEFF"))
+	text = bytes.ReplaceAll(text, []byte("\r\n"), []byte("\n"))
+	text = bytes.ReplaceAll(text, []byte("\r"), []byte("\n"))
+	if opts.TrimTrailing {
+		lines := bytes.Split(text, []byte("\n"))
+		for i, line := range lines {
+			lines[i] = bytes.TrimRight(line, " \t")
+		}
+		text = bytes.Join(lines, []byte("\n"))
+	}
+	return NFC(text)
+}
+
+// NFC returns the text in Unicode Normalization Form C
+func NFC(text []byte) []byte {
+	return norm.NFC.Bytes(text)
+}
@@ -0,0 +1,49 @@
+package textnorm
+
+import "testing"
+
+func TestNFC(t *testing.T) {
+	for _, tt := range []struct {
+		name, text, want string
+	}{
+		{"combining accent", "cafe\u0301", "caf\u00e9"},
+		{"already composed", "caf\u00e9", "caf\u00e9"},
+		{"singleton", "\u212b", "\u00c5"},
+		{"marks reordered", "a\u0301\u0323", "\u1ea1\u0301"},
+		{"blocked mark", "a\u0301\u0301", "\u00e1\u0301"},
+		{"excluded composition", "\u0958", "\u0915\u093c"},
+		{"hangul jamo", "\u1100\u1161\u11a8", "\uac01"},
+		{"hangul syllable and trailing jamo", "\uac00\u11a8", "\uac01"},
+		{"not utf-8", "e\xff\u0301", "e\xff\u0301"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(NFC([]byte(tt.text))); got != tt.want {
+				t.Fatalf("NFC(%+q) = %+q, want %+q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// given the same text as written on two platforms
+	windows := "\uFEFFR\u00e9sume\u0301  \r\nline two\t\r\n"
+	unix := "Re\u0301sum\u00e9\nline two\n"
+
+	// when
+	a := Normalize([]byte(windows), Options{TrimTrailing: true})
+	b := Normalize([]byte(unix), Options{TrimTrailing: true})
+
+	// then
+	if string(a) != string(b) {
+		t.Fatalf("Normalize() = %+q and %+q, want the same text", a, b)
+	}
+	if want := "R\u00e9sum\u00e9\nline two\n"; string(a) != want {
+		t.Fatalf("Normalize() = %+q, want %+q", a, want)
+	}
+
+	t.Run("Trailing whitespace is kept unless trimmed", func(t *testing.T) {
+		if got := string(Normalize([]byte("a \rb"), Options{})); got != "a \nb" {
+			t.Fatalf("Normalize() = %+q, want %+q", got, "a \nb")
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package transcript
+
+//
+// The subset of STROBE-128 that Merlin uses
+// https://strobe.sourceforge.io/specs/
+// https://github.com/dalek-cryptography/merlin/blob/master/src/strobe.rs
+//
+
+const strobeR = 166
+
+const (
+	flagI = 1 << iota
+	flagA
+	flagC
+	flagT
+	flagM
+	flagK
+)
+
+type strobe128 struct {
+	state    [200]byte
+	pos      byte
+	posBegin byte
+	curFlags byte
+}
+
+func newStrobe128(protocolLabel []byte) *strobe128 {
+	s := new(strobe128)
+	copy(s.state[:], []byte{1, strobeR + 2, 1, 0, 1, 96})
+	copy(s.state[6:], "STROBEv1.0.2")
+	keccakF1600(&s.state)
+
+	s.metaAD(protocolLabel, false)
+	return s
+}
+
+func (s *strobe128) metaAD(data []byte, more bool) {
+	s.beginOp(flagM|flagA, more)
+	s.absorb(data)
+}
+
+func (s *strobe128) ad(data []byte, more bool) {
+	s.beginOp(flagA, more)
+	s.absorb(data)
+}
+
+func (s *strobe128) prf(data []byte, more bool) {
+	s.beginOp(flagI|flagA|flagC, more)
+	s.squeeze(data)
+}
+
+func (s *strobe128) key(data []byte, more bool) {
+	s.beginOp(flagA|flagC, more)
+	s.overwrite(data)
+}
+
+func (s *strobe128) runF() {
+	s.state[s.pos] ^= s.posBegin
+	s.state[s.pos+1] ^= 0x04
+	s.state[strobeR+1] ^= 0x80
+	keccakF1600(&s.state)
+	s.pos = 0
+	s.posBegin = 0
+}
+
+func (s *strobe128) absorb(data []byte) {
+	for _, b := range data {
+		s.state[s.pos] ^= b
+		s.pos++
+		if s.pos == strobeR {
+			s.runF()
+		}
+	}
+}
+
+func (s *strobe128) overwrite(data []byte) {
+	for _, b := range data {
+		s.state[s.pos] = b
+		s.pos++
+		if s.pos == strobeR {
+			s.runF()
+		}
+	}
+}
+
+func (s *strobe128) squeeze(data []byte) {
+	for i := range data {
+		data[i] = s.state[s.pos]
+		s.state[s.pos] = 0
+		s.pos++
+		if s.pos == strobeR {
+			s.runF()
+		}
+	}
+}
+
+func (s *strobe128) beginOp(flags byte, more bool) {
+	if more {
+		if s.curFlags != flags {
+			panic("strobe: continued an operation with different flags")
+		}
+		return
+	}
+
+	oldBegin := s.posBegin
+	s.posBegin = s.pos + 1
+	s.curFlags = flags
+
+	s.absorb([]byte{oldBegin, flags})
+
+	if flags&(flagC|flagK) != 0 && s.pos != 0 {
+		s.runF()
+	}
+}
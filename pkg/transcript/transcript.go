@@ -0,0 +1,105 @@
+package transcript
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Merlin transcripts for Fiat-Shamir, byte compatible with the Rust crate
+// https://merlin.cool
+//
+
+// Transcript is a running record of a protocol's public messages that
+// challenges are derived from. Every message and challenge carries a label
+// so two protocols, or two steps of one protocol, never get the same
+// challenge by accident.
+type Transcript struct {
+	strobe *strobe128
+}
+
+// New starts a transcript for the protocol named by label
+func New(label string) *Transcript {
+	t := &Transcript{strobe: newStrobe128([]byte("Merlin v1.0"))}
+	t.AppendMessage("dom-sep", []byte(label))
+	return t
+}
+
+// Clone returns an independent copy of the transcript in its current state
+func (t *Transcript) Clone() *Transcript {
+	s := *t.strobe
+	return &Transcript{strobe: &s}
+}
+
+func le32(n int) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(n))
+	return b[:]
+}
+
+// AppendMessage adds a labelled message
+func (t *Transcript) AppendMessage(label string, message []byte) {
+	t.strobe.metaAD([]byte(label), false)
+	t.strobe.metaAD(le32(len(message)), true)
+	t.strobe.ad(message, false)
+}
+
+// AppendUint64 adds a labelled number as 8 little endian bytes
+func (t *Transcript) AppendUint64(label string, n uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	t.AppendMessage(label, b[:])
+}
+
+// AppendPoint adds a labelled point in compressed form, or 33 zero bytes for
+// the point at infinity
+func (t *Transcript) AppendPoint(label string, p *schnorr.Point) {
+	encoded, err := p.PublicKey()
+	if err != nil {
+		encoded = [33]byte{}
+	}
+	t.AppendMessage(label, encoded[:])
+}
+
+// AppendScalar adds a labelled scalar as 32 big endian bytes
+func (t *Transcript) AppendScalar(label string, k *big.Int) {
+	t.AppendMessage(label, schnorr.GetBigIntBytesImmutable(new(big.Int).Mod(k, schnorr.Curve.N)))
+}
+
+// ChallengeBytes fills a new slice of n bytes derived from everything
+// appended so far. The challenge is itself absorbed, so asking again gives a
+// different answer.
+func (t *Transcript) ChallengeBytes(label string, n int) []byte {
+	out := make([]byte, n)
+	t.strobe.metaAD([]byte(label), false)
+	t.strobe.metaAD(le32(n), true)
+	t.strobe.prf(out, false)
+	return out
+}
+
+// ChallengeScalar derives a scalar modulo the curve order. 64 bytes are
+// reduced so the bias is negligible.
+func (t *Transcript) ChallengeScalar(label string) *big.Int {
+	wide := new(big.Int).SetBytes(t.ChallengeBytes(label, 64))
+	return wide.Mod(wide, schnorr.Curve.N)
+}
+
+// RekeyWithWitness mixes secret data into a clone of the transcript, like
+// Merlin's transcript RNG, and returns a nonce scalar. The nonce is
+// bound to both the public transcript and the witness, with fresh entropy
+// added on top when rnd is not nil.
+func (t *Transcript) RekeyWithWitness(label string, witness []byte, rnd []byte) *big.Int {
+	c := t.Clone()
+	c.strobe.metaAD([]byte(label), false)
+	c.strobe.metaAD(le32(len(witness)), true)
+	c.strobe.key(witness, false)
+
+	if rnd != nil {
+		c.strobe.metaAD([]byte("rng"), false)
+		c.strobe.key(rnd, false)
+	}
+
+	return c.ChallengeScalar("nonce")
+}
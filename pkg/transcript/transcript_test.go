@@ -0,0 +1,72 @@
+package transcript
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestMerlinEquivalence(t *testing.T) {
+	// equivalence_simple from the merlin crate
+	tr := New("test protocol")
+	tr.AppendMessage("some label", []byte("some data"))
+
+	observed := tr.ChallengeBytes("challenge", 32)
+
+	// then
+	expected := "d5a21972d0d5fe320c0d263fac7fffb8145aa640af6e9bca177c03c7efcf0615"
+	if hex.EncodeToString(observed) != expected {
+		t.Fatalf("ChallengeBytes = %x, want %s", observed, expected)
+	}
+}
+
+func TestChallenges(t *testing.T) {
+	P := schnorr.ScalarBaseMult(big.NewInt(7))
+
+	a, b := New("proto"), New("proto")
+	a.AppendPoint("P", P)
+	b.AppendPoint("P", P)
+
+	// then same inputs give the same challenge
+	ca, cb := a.ChallengeScalar("c"), b.ChallengeScalar("c")
+	if ca.Cmp(cb) != 0 {
+		t.Fatalf("same transcripts gave different challenges")
+	}
+
+	// asking again moves the transcript on
+	if a.ChallengeScalar("c").Cmp(ca) == 0 {
+		t.Fatalf("second challenge is the same as the first")
+	}
+
+	t.Run("Labels are bound", func(t *testing.T) {
+		x, y := New("proto"), New("proto")
+		x.AppendMessage("a", []byte("m"))
+		y.AppendMessage("b", []byte("m"))
+		if x.ChallengeScalar("c").Cmp(y.ChallengeScalar("c")) == 0 {
+			t.Fatalf("different labels gave the same challenge")
+		}
+	})
+
+	t.Run("Clone is independent", func(t *testing.T) {
+		x := New("proto")
+		y := x.Clone()
+		x.AppendUint64("n", 1)
+		if x.ChallengeScalar("c").Cmp(y.ChallengeScalar("c")) == 0 {
+			t.Fatalf("clone followed the original")
+		}
+	})
+
+	t.Run("Witness nonces depend on the witness", func(t *testing.T) {
+		x := New("proto")
+		n1 := x.RekeyWithWitness("w", []byte{1}, nil)
+		n2 := x.RekeyWithWitness("w", []byte{2}, nil)
+		if n1.Cmp(n2) == 0 {
+			t.Fatalf("different witnesses gave the same nonce")
+		}
+		if x.RekeyWithWitness("w", []byte{1}, nil).Cmp(n1) != 0 {
+			t.Fatalf("rekeying changed the transcript")
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is how often a FileTransport looks for new messages
+const DefaultPollInterval = 200 * time.Millisecond
+
+// FileTransport passes messages through a directory, such as a shared mount
+// or a folder carried between air gapped machines. Every message is its own
+// file, written under a temporary name and renamed into place so readers
+// never see half a message.
+type FileTransport struct {
+	id           uint32
+	dir          string
+	PollInterval time.Duration
+
+	mu   sync.Mutex
+	seq  int
+	seen map[string]bool
+}
+
+// OpenFileTransport uses dir, creating it if needed
+func OpenFileTransport(id uint32, dir string) (*FileTransport, error) {
+	if id == Broadcast {
+		return nil, fmt.Errorf("participant ids start at 1")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileTransport{id: id, dir: dir, PollInterval: DefaultPollInterval, seen: map[string]bool{}}, nil
+}
+
+func (t *FileTransport) ID() uint32 {
+	return t.id
+}
+
+// Send writes round-<round>.from-<from>.to-<to>.<unix nanos>-<seq>.json
+func (t *FileTransport) Send(ctx context.Context, msg Message) error {
+	msg.From = t.id
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.seq++
+	name := fmt.Sprintf("round-%d.from-%d.to-%d.%d-%d.json", msg.Round, msg.From, msg.To, time.Now().UnixNano(), t.seq)
+	t.mu.Unlock()
+
+	tmp := filepath.Join(t.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.dir, name))
+}
+
+// Receive waits for a message addressed to this participant which it has
+// not read yet. The files are left in place for the other participants and
+// for auditing.
+func (t *FileTransport) Receive(ctx context.Context) (Message, error) {
+	for {
+		msg, ok, err := t.next()
+		if err != nil || ok {
+			return msg, err
+		}
+
+		select {
+		case <-time.After(t.PollInterval):
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+func (t *FileTransport) next() (Message, bool, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, name := range names {
+		if t.seen[name] {
+			continue
+		}
+
+		var round int
+		var from, to uint32
+		if _, err := fmt.Sscanf(name, "round-%d.from-%d.to-%d.", &round, &from, &to); err != nil {
+			continue
+		}
+		if from == t.id || (to != Broadcast && to != t.id) {
+			t.seen[name] = true
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(t.dir, name))
+		if err != nil {
+			return Message{}, false, err
+		}
+		t.seen[name] = true
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return Message{}, false, fmt.Errorf("malformed message %s: %v", name, err)
+		}
+		msg.From = from
+		return msg, true, nil
+	}
+	return Message{}, false, nil
+}
+
+func (t *FileTransport) Close() error {
+	return nil
+}
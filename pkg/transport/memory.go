@@ -0,0 +1,95 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MemoryNetwork connects participants in the same process, for tests and
+// for running every party of a ceremony locally
+type MemoryNetwork struct {
+	mu      sync.Mutex
+	inboxes map[uint32]chan Message
+}
+
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{inboxes: map[uint32]chan Message{}}
+}
+
+// Join adds the participant to the network
+func (n *MemoryNetwork) Join(id uint32) (Transport, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if id == Broadcast {
+		return nil, fmt.Errorf("participant ids start at 1")
+	}
+	if _, ok := n.inboxes[id]; ok {
+		return nil, fmt.Errorf("participant %d has already joined", id)
+	}
+
+	n.inboxes[id] = make(chan Message, 1024)
+	return &memoryTransport{network: n, id: id, inbox: n.inboxes[id]}, nil
+}
+
+type memoryTransport struct {
+	network *MemoryNetwork
+	id      uint32
+	inbox   chan Message
+}
+
+func (t *memoryTransport) ID() uint32 {
+	return t.id
+}
+
+func (t *memoryTransport) Send(ctx context.Context, msg Message) error {
+	msg.From = t.id
+
+	t.network.mu.Lock()
+	targets := []chan Message{}
+	if msg.To == Broadcast {
+		for id, inbox := range t.network.inboxes {
+			if id != t.id {
+				targets = append(targets, inbox)
+			}
+		}
+	} else if inbox, ok := t.network.inboxes[msg.To]; ok {
+		targets = append(targets, inbox)
+	}
+	t.network.mu.Unlock()
+
+	if len(targets) == 0 && msg.To != Broadcast {
+		return fmt.Errorf("participant %d is not on the network", msg.To)
+	}
+
+	for _, inbox := range targets {
+		copied := msg
+		copied.Payload = append([]byte{}, msg.Payload...)
+		select {
+		case inbox <- copied:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (t *memoryTransport) Receive(ctx context.Context) (Message, error) {
+	select {
+	case msg := <-t.inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+// Close leaves the network, messages sent to the participant afterwards
+// fail
+func (t *memoryTransport) Close() error {
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+
+	delete(t.network.inboxes, t.id)
+	return nil
+}
@@ -0,0 +1,222 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/nostr"
+)
+
+// NostrLookback is how far back a NostrTransport looks for messages sent
+// before it connected
+const NostrLookback = 10 * time.Minute
+
+// NostrTransport passes messages as NIP-04 encrypted direct messages through
+// public relays, so cosigners need no reachable server of their own. Every
+// message is tagged with the session, and only messages signed by a known
+// peer are accepted, which also authenticates From.
+type NostrTransport struct {
+	id           uint32
+	privatekey   []byte
+	pubkey       string
+	session      string
+	PollInterval time.Duration
+
+	mu      sync.Mutex
+	peers   map[uint32]string
+	byKey   map[string]uint32
+	relays  []*nostr.Relay
+	since   int64
+	seen    map[string]bool
+	pending []Message
+}
+
+// DialNostr connects to the relays. Peers maps the ids of the other
+// participants to their hex x-only nostr public keys, and session keeps
+// concurrent ceremonies apart.
+func DialNostr(id uint32, privatekey []byte, peers map[uint32]string, relayURLs []string, session string) (*NostrTransport, error) {
+	if id == Broadcast {
+		return nil, fmt.Errorf("participant ids start at 1")
+	}
+	if len(relayURLs) == 0 {
+		return nil, fmt.Errorf("no relays given")
+	}
+
+	pubkey, err := nostr.PublicKey(privatekey)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &NostrTransport{
+		id:           id,
+		privatekey:   append([]byte{}, privatekey...),
+		pubkey:       pubkey,
+		session:      session,
+		PollInterval: DefaultPollInterval,
+		peers:        map[uint32]string{},
+		byKey:        map[string]uint32{},
+		since:        time.Now().Add(-NostrLookback).Unix(),
+		seen:         map[string]bool{},
+	}
+	for peer, key := range peers {
+		if peer != id {
+			t.peers[peer] = key
+			t.byKey[key] = peer
+		}
+	}
+
+	for _, url := range relayURLs {
+		relay, err := nostr.Connect(url, 0)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.relays = append(t.relays, relay)
+	}
+	return t, nil
+}
+
+func (t *NostrTransport) ID() uint32 {
+	return t.id
+}
+
+// Send publishes an encrypted message to every relay for each recipient,
+// succeeding if at least one relay accepted each
+func (t *NostrTransport) Send(ctx context.Context, msg Message) error {
+	msg.From = t.id
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	targets := map[uint32]string{}
+	if msg.To == Broadcast {
+		targets = t.peers
+	} else if key, ok := t.peers[msg.To]; ok {
+		targets[msg.To] = key
+	} else {
+		return fmt.Errorf("participant %d has no nostr key", msg.To)
+	}
+
+	for id, key := range targets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ev, err := nostr.NewDirectMessage(t.privatekey, key, string(data), [][]string{{"t", t.session}})
+		if err != nil {
+			return err
+		}
+
+		accepted := false
+		errs := []error{}
+		for _, relay := range t.relays {
+			result, err := relay.Publish(ev)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			accepted = accepted || result.Accepted
+		}
+		if !accepted {
+			return fmt.Errorf("no relay accepted the message to participant %d: %v", id, errs)
+		}
+	}
+	return nil
+}
+
+// Receive polls the relays until a message for this participant arrives
+func (t *NostrTransport) Receive(ctx context.Context) (Message, error) {
+	for {
+		msg, ok, err := t.next()
+		if err != nil || ok {
+			return msg, err
+		}
+
+		select {
+		case <-time.After(t.PollInterval):
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+func (t *NostrTransport) next() (Message, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.pending) == 0 {
+		if err := t.poll(); err != nil {
+			return Message{}, false, err
+		}
+	}
+	if len(t.pending) == 0 {
+		return Message{}, false, nil
+	}
+
+	msg := t.pending[0]
+	t.pending = t.pending[1:]
+	return msg, true, nil
+}
+
+func (t *NostrTransport) poll() error {
+	filter := nostr.Filter{
+		Kinds: []int{nostr.KindDirectMessage},
+		P:     []string{t.pubkey},
+		T:     []string{t.session},
+		Since: t.since,
+	}
+
+	errs := []error{}
+	for _, relay := range t.relays {
+		events, err := relay.Fetch(filter)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, ev := range events {
+			if t.seen[ev.ID] {
+				continue
+			}
+			t.seen[ev.ID] = true
+
+			from, ok := t.byKey[ev.PubKey]
+			if !ok {
+				continue
+			}
+			if ok, _ := ev.Verify(); !ok {
+				continue
+			}
+
+			text, err := nostr.Decrypt(t.privatekey, ev.PubKey, ev.Content)
+			if err != nil {
+				continue
+			}
+			var msg Message
+			if err := json.Unmarshal([]byte(text), &msg); err != nil {
+				continue
+			}
+			msg.From = from
+			t.pending = append(t.pending, msg)
+		}
+	}
+
+	if len(errs) == len(t.relays) {
+		return fmt.Errorf("every relay failed: %v", errs)
+	}
+	return nil
+}
+
+func (t *NostrTransport) Close() error {
+	for _, relay := range t.relays {
+		relay.Close()
+	}
+	return nil
+}
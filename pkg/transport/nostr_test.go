@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/nostr"
+	"golang.org/x/net/websocket"
+)
+
+// memoryRelay stores every event and answers REQs filtered by kind and the
+// #p and #t tags
+type memoryRelay struct {
+	mu     sync.Mutex
+	events []*nostr.Event
+}
+
+func (r *memoryRelay) matches(ev *nostr.Event, f nostr.Filter) bool {
+	hasTag := func(name string, values []string) bool {
+		if len(values) == 0 {
+			return true
+		}
+		for _, tag := range ev.Tags {
+			for _, v := range values {
+				if len(tag) > 1 && tag[0] == name && tag[1] == v {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return ev.CreatedAt >= f.Since && hasTag("p", f.P) && hasTag("t", f.T)
+}
+
+func (r *memoryRelay) serve(ws *websocket.Conn) {
+	for {
+		var data string
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			return
+		}
+		msg := []json.RawMessage{}
+		json.Unmarshal([]byte(data), &msg)
+
+		var label, sub string
+		json.Unmarshal(msg[0], &label)
+		switch label {
+		case "EVENT":
+			ev := new(nostr.Event)
+			json.Unmarshal(msg[1], ev)
+			r.mu.Lock()
+			r.events = append(r.events, ev)
+			r.mu.Unlock()
+			websocket.Message.Send(ws, `["OK","`+ev.ID+`",true,""]`)
+		case "REQ":
+			json.Unmarshal(msg[1], &sub)
+			f := nostr.Filter{}
+			json.Unmarshal(msg[2], &f)
+			r.mu.Lock()
+			for _, ev := range r.events {
+				if r.matches(ev, f) {
+					data, _ := json.Marshal(ev)
+					websocket.Message.Send(ws, `["EVENT","`+sub+`",`+string(data)+`]`)
+				}
+			}
+			r.mu.Unlock()
+			websocket.Message.Send(ws, `["EOSE","`+sub+`"]`)
+		}
+	}
+}
+
+func TestNostr(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(new(memoryRelay).serve))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	privatekeys := map[uint32][]byte{}
+	peers := map[uint32]string{}
+	for id := uint32(1); id <= 3; id++ {
+		d := make([]byte, 32)
+		d[31] = byte(id)
+		privatekeys[id] = d
+		peers[id], _ = nostr.PublicKey(d)
+	}
+
+	transports := []Transport{}
+	for id := uint32(1); id <= 3; id++ {
+		tr, err := DialNostr(id, privatekeys[id], peers, []string{url}, "ceremony-1")
+		if err != nil {
+			t.Fatalf("Unexpected error from DialNostr: %v", err)
+		}
+		defer tr.Close()
+		tr.PollInterval = 10 * time.Millisecond
+		transports = append(transports, tr)
+	}
+
+	exchange(t, transports)
+}
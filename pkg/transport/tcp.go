@@ -0,0 +1,209 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// TCPTransport connects every participant directly to every other. Each one
+// listens on its own address and dials the others on first send. A
+// connection starts with the dialer's id, and every message on it is
+// stamped with that id. Nothing is encrypted or authenticated, so run it
+// over a trusted network or sign the payloads.
+type TCPTransport struct {
+	id       uint32
+	peers    map[uint32]string
+	listener net.Listener
+	inbox    chan Message
+
+	mu    sync.Mutex
+	conns map[uint32]*json.Encoder
+	raw   []net.Conn
+
+	done chan struct{}
+}
+
+type hello struct {
+	ID uint32 `json:"id"`
+}
+
+// ListenTCP starts the participant listening on addr. Peers maps the ids of
+// the other participants to their addresses.
+func ListenTCP(id uint32, addr string, peers map[uint32]string) (*TCPTransport, error) {
+	if id == Broadcast {
+		return nil, fmt.Errorf("participant ids start at 1")
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &TCPTransport{
+		id:       id,
+		peers:    map[uint32]string{},
+		listener: listener,
+		inbox:    make(chan Message, 1024),
+		conns:    map[uint32]*json.Encoder{},
+		done:     make(chan struct{}),
+	}
+	for peer, addr := range peers {
+		if peer != id {
+			t.peers[peer] = addr
+		}
+	}
+
+	go t.accept()
+	return t, nil
+}
+
+// Addr is the address the participant listens on
+func (t *TCPTransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+// SetPeer sets or changes the address of a peer
+func (t *TCPTransport) SetPeer(id uint32, addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.peers[id] = addr
+}
+
+func (t *TCPTransport) ID() uint32 {
+	return t.id
+}
+
+func (t *TCPTransport) accept() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.track(conn)
+		go t.read(conn)
+	}
+}
+
+func (t *TCPTransport) track(conn net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.raw = append(t.raw, conn)
+}
+
+func (t *TCPTransport) read(conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return
+	}
+
+	for {
+		var msg Message
+		if err := dec.Decode(&msg); err != nil {
+			return
+		}
+		msg.From = h.ID
+
+		select {
+		case t.inbox <- msg:
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *TCPTransport) encoder(ctx context.Context, id uint32) (*json.Encoder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if enc, ok := t.conns[id]; ok {
+		return enc, nil
+	}
+	addr, ok := t.peers[id]
+	if !ok {
+		return nil, fmt.Errorf("participant %d has no address", id)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to participant %d: %v", id, err)
+	}
+	t.raw = append(t.raw, conn)
+
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(hello{ID: t.id}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	t.conns[id] = enc
+	return enc, nil
+}
+
+func (t *TCPTransport) Send(ctx context.Context, msg Message) error {
+	msg.From = t.id
+
+	targets := []uint32{msg.To}
+	if msg.To == Broadcast {
+		t.mu.Lock()
+		targets = targets[:0]
+		for id := range t.peers {
+			targets = append(targets, id)
+		}
+		t.mu.Unlock()
+	}
+
+	for _, id := range targets {
+		enc, err := t.encoder(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		t.mu.Lock()
+		err = enc.Encode(msg)
+		if err != nil {
+			delete(t.conns, id)
+		}
+		t.mu.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("failed to send to participant %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (t *TCPTransport) Receive(ctx context.Context) (Message, error) {
+	select {
+	case msg := <-t.inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	case <-t.done:
+		return Message{}, fmt.Errorf("transport is closed")
+	}
+}
+
+// Close stops listening and closes every connection
+func (t *TCPTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return nil
+	default:
+	}
+	close(t.done)
+
+	err := t.listener.Close()
+	for _, conn := range t.raw {
+		conn.Close()
+	}
+	return err
+}
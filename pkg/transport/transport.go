@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+)
+
+// Broadcast is the To of a message meant for every other participant
+const Broadcast = 0
+
+// Message is one round message between participants of a multiparty
+// protocol. Participant ids start at 1, like FROST's.
+type Message struct {
+	Round   int    `json:"round"`
+	From    uint32 `json:"from"`
+	To      uint32 `json:"to"`
+	Payload []byte `json:"payload"`
+}
+
+// Transport moves round messages between participants. Send delivers to To,
+// or everyone else for Broadcast, and Receive returns the next message for
+// this participant. Implementations stamp From themselves.
+type Transport interface {
+	ID() uint32
+	Send(ctx context.Context, msg Message) error
+	Receive(ctx context.Context) (Message, error)
+	Close() error
+}
+
+// Gatherer collects whole rounds from a transport. Messages for later rounds
+// which arrive early are held until their round is gathered.
+type Gatherer struct {
+	t       Transport
+	pending []Message
+}
+
+func NewGatherer(t Transport) *Gatherer {
+	return &Gatherer{t: t}
+}
+
+// Gather returns one message from each participant for the round, keyed by
+// sender
+func (g *Gatherer) Gather(ctx context.Context, round int, from []uint32) (map[uint32]Message, error) {
+	want := map[uint32]bool{}
+	for _, id := range from {
+		want[id] = true
+	}
+
+	got := map[uint32]Message{}
+	take := func(msg Message) error {
+		if !want[msg.From] {
+			return fmt.Errorf("round %d message from unexpected participant %d", round, msg.From)
+		}
+		if _, ok := got[msg.From]; ok {
+			return fmt.Errorf("participant %d sent round %d twice", msg.From, round)
+		}
+		got[msg.From] = msg
+		return nil
+	}
+
+	pending := g.pending[:0]
+	for _, msg := range g.pending {
+		if msg.Round != round {
+			pending = append(pending, msg)
+			continue
+		}
+		if err := take(msg); err != nil {
+			return nil, err
+		}
+	}
+	g.pending = pending
+
+	for len(got) < len(want) {
+		msg, err := g.t.Receive(ctx)
+		if err != nil {
+			return got, err
+		}
+		switch {
+		case msg.Round == round:
+			if err := take(msg); err != nil {
+				return nil, err
+			}
+		case msg.Round > round:
+			g.pending = append(g.pending, msg)
+		}
+		// messages for rounds already gathered are stale and dropped
+	}
+
+	return got, nil
+}
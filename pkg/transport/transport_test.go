@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// exchange has every participant broadcast in round 1 and then message each
+// of the others directly in round 2
+func exchange(t *testing.T, transports []Transport) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ids := []uint32{}
+	for _, tr := range transports {
+		ids = append(ids, tr.ID())
+	}
+	others := func(id uint32) []uint32 {
+		out := []uint32{}
+		for _, other := range ids {
+			if other != id {
+				out = append(out, other)
+			}
+		}
+		return out
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(transports))
+	for _, tr := range transports {
+		wg.Add(1)
+		go func(tr Transport) {
+			defer wg.Done()
+			g := NewGatherer(tr)
+			errs <- func() error {
+				if err := tr.Send(ctx, Message{Round: 1, To: Broadcast, Payload: []byte("hello")}); err != nil {
+					return err
+				}
+				for _, to := range others(tr.ID()) {
+					payload := []byte(fmt.Sprintf("%d->%d", tr.ID(), to))
+					if err := tr.Send(ctx, Message{Round: 2, To: to, Payload: payload}); err != nil {
+						return err
+					}
+				}
+
+				round1, err := g.Gather(ctx, 1, others(tr.ID()))
+				if err != nil {
+					return err
+				}
+				round2, err := g.Gather(ctx, 2, others(tr.ID()))
+				if err != nil {
+					return err
+				}
+
+				for _, from := range others(tr.ID()) {
+					if string(round1[from].Payload) != "hello" {
+						return fmt.Errorf("round 1 from %d = %s", from, round1[from].Payload)
+					}
+					if want := fmt.Sprintf("%d->%d", from, tr.ID()); string(round2[from].Payload) != want {
+						return fmt.Errorf("round 2 from %d = %s, want %s", from, round2[from].Payload, want)
+					}
+				}
+				return nil
+			}()
+		}(tr)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Unexpected error from exchange: %v", err)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	network := NewMemoryNetwork()
+	transports := []Transport{}
+	for id := uint32(1); id <= 3; id++ {
+		tr, err := network.Join(id)
+		if err != nil {
+			t.Fatalf("Unexpected error from Join(%d): %v", id, err)
+		}
+		defer tr.Close()
+		transports = append(transports, tr)
+	}
+
+	exchange(t, transports)
+
+	if _, err := network.Join(2); err == nil {
+		t.Fatalf("Join(2) twice = nil error, want error")
+	}
+}
+
+func TestTCP(t *testing.T) {
+	tcp := []*TCPTransport{}
+	for id := uint32(1); id <= 3; id++ {
+		tr, err := ListenTCP(id, "127.0.0.1:0", nil)
+		if err != nil {
+			t.Fatalf("Unexpected error from ListenTCP: %v", err)
+		}
+		defer tr.Close()
+		tcp = append(tcp, tr)
+	}
+
+	transports := []Transport{}
+	for _, tr := range tcp {
+		for _, peer := range tcp {
+			if peer != tr {
+				tr.SetPeer(peer.ID(), peer.Addr().String())
+			}
+		}
+		transports = append(transports, tr)
+	}
+
+	exchange(t, transports)
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	transports := []Transport{}
+	for id := uint32(1); id <= 3; id++ {
+		tr, err := OpenFileTransport(id, dir)
+		if err != nil {
+			t.Fatalf("Unexpected error from OpenFileTransport: %v", err)
+		}
+		tr.PollInterval = 10 * time.Millisecond
+		transports = append(transports, tr)
+	}
+
+	exchange(t, transports)
+}
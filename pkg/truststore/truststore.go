@@ -0,0 +1,261 @@
+package truststore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+//
+// A local trust store: the public keys a user has decided about, each with
+// a name and a trust level. Verifiers consult it to refuse keys they have
+// not vouched for, and may pin keys the first time they see them, with a
+// warning, so that a key swapped later is refused instead.
+//
+
+// Level is how far a key is trusted
+type Level string
+
+// Levels, from least to most trusted
+const (
+	// LevelNever is a key explicitly distrusted
+	LevelNever Level = "never"
+	// LevelFirstUse is a key pinned the first time it was seen, unchecked
+	LevelFirstUse Level = "first-use"
+	// LevelMarginal is a key checked, but not thoroughly
+	LevelMarginal Level = "marginal"
+	// LevelFull is a key checked against its owner
+	LevelFull Level = "full"
+)
+
+var levelRank = map[Level]int{LevelNever: 0, LevelFirstUse: 1, LevelMarginal: 2, LevelFull: 3}
+
+// ParseLevel checks the name is a known level
+func ParseLevel(s string) (Level, error) {
+	if _, ok := levelRank[Level(s)]; !ok {
+		return "", fmt.Errorf("unknown trust level %q, want never, first-use, marginal or full", s)
+	}
+	return Level(s), nil
+}
+
+// AtLeast reports whether l is as trusted as min
+func (l Level) AtLeast(min Level) bool {
+	return levelRank[l] >= levelRank[min]
+}
+
+var (
+	// ErrUnknownKey is returned for a key not in the store
+	ErrUnknownKey = errors.New("key is not in the trust store")
+	// ErrDistrusted is returned for a key at LevelNever
+	ErrDistrusted = errors.New("key is distrusted")
+	// ErrInsufficientTrust is returned for a key below the level required
+	ErrInsufficientTrust = errors.New("key is not trusted enough")
+)
+
+// Entry is one key in the store
+type Entry struct {
+	Name        string    `json:"name"`
+	PublicKey   string    `json:"public_key"`
+	Fingerprint string    `json:"fingerprint"`
+	Trust       Level     `json:"trust"`
+	Added       time.Time `json:"added"`
+	Comment     string    `json:"comment,omitempty"`
+}
+
+// Fingerprint is the hex sha256 of the compressed public key
+func Fingerprint(publickey [33]byte) string {
+	sum := sha256.Sum256(publickey[:])
+	return hex.EncodeToString(sum[:])
+}
+
+// Store is a trust store kept in a json file
+type Store struct {
+	path string
+	// MinTrust is the level Trusted requires, LevelMarginal by default
+	MinTrust Level
+	// FirstUse makes Trusted pin unknown keys at LevelFirstUse rather than
+	// refuse them, calling Warn so the user can check the key
+	FirstUse bool
+	Warn     func(e *Entry)
+
+	mu      sync.Mutex
+	entries map[[33]byte]*Entry
+	dirty   bool
+}
+
+// Open reads the store at path, which need not exist yet
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, MinTrust: LevelMarginal, entries: map[[33]byte]*Entry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*Entry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, e := range entries {
+		raw, err := hex.DecodeString(e.PublicKey)
+		if err != nil || len(raw) != 33 {
+			return nil, fmt.Errorf("%s: key %q is not 33 bytes of hex", path, e.Name)
+		}
+		var pk [33]byte
+		copy(pk[:], raw)
+		if e.Fingerprint != Fingerprint(pk) {
+			return nil, fmt.Errorf("%s: fingerprint of %q does not match its key", path, e.Name)
+		}
+		if _, err := ParseLevel(string(e.Trust)); err != nil {
+			return nil, fmt.Errorf("%s: %q: %v", path, e.Name, err)
+		}
+		s.entries[pk] = e
+	}
+	return s, nil
+}
+
+// Add puts the key in the store under name, or changes the name and level
+// of a key already there. Names are unique.
+func (s *Store) Add(name string, publickey [33]byte, level Level, comment string) (*Entry, error) {
+	if name == "" {
+		return nil, fmt.Errorf("key needs a name")
+	}
+	if _, err := ParseLevel(string(level)); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pk, e := range s.entries {
+		if e.Name == name && pk != publickey {
+			return nil, fmt.Errorf("name %q is already used for %s", name, e.Fingerprint)
+		}
+	}
+	e, ok := s.entries[publickey]
+	if !ok {
+		e = &Entry{PublicKey: hex.EncodeToString(publickey[:]), Fingerprint: Fingerprint(publickey), Added: time.Now().UTC()}
+		s.entries[publickey] = e
+	}
+	e.Name, e.Trust, e.Comment = name, level, comment
+	s.dirty = true
+	return e, nil
+}
+
+// Remove drops the key with the name
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pk, e := range s.entries {
+		if e.Name == name {
+			delete(s.entries, pk)
+			s.dirty = true
+			return nil
+		}
+	}
+	return fmt.Errorf("no key named %q", name)
+}
+
+// Lookup returns the entry for the key, nil if it is not in the store
+func (s *Store) Lookup(publickey [33]byte) *Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[publickey]
+}
+
+// Find returns the key with the name
+func (s *Store) Find(name string) ([33]byte, *Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pk, e := range s.entries {
+		if e.Name == name {
+			return pk, e, nil
+		}
+	}
+	return [33]byte{}, nil, fmt.Errorf("no key named %q", name)
+}
+
+// Entries returns the entries sorted by name
+func (s *Store) Entries() []*Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// Trusted returns nil if the key is trusted to MinTrust. An unknown key is
+// refused with ErrUnknownKey unless FirstUse is set, when it is pinned. It
+// satisfies envelope.Trust.
+func (s *Store) Trusted(publickey [33]byte) error {
+	s.mu.Lock()
+	e, ok := s.entries[publickey]
+	if !ok && s.FirstUse {
+		e = &Entry{
+			Name:        "first-use-" + Fingerprint(publickey)[:16],
+			PublicKey:   hex.EncodeToString(publickey[:]),
+			Fingerprint: Fingerprint(publickey),
+			Trust:       LevelFirstUse,
+			Added:       time.Now().UTC(),
+		}
+		s.entries[publickey] = e
+		s.dirty = true
+		s.mu.Unlock()
+		if s.Warn != nil {
+			s.Warn(e)
+		}
+		return nil
+	}
+	s.mu.Unlock()
+
+	min := s.MinTrust
+	if min == "" {
+		min = LevelMarginal
+	}
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: %s", ErrUnknownKey, Fingerprint(publickey))
+	case e.Trust == LevelNever:
+		return fmt.Errorf("%w: %s (%s)", ErrDistrusted, e.Name, e.Fingerprint)
+	case e.Trust == LevelFirstUse && s.FirstUse:
+		// pinned earlier, trusted as long as first use is
+		return nil
+	case !e.Trust.AtLeast(min):
+		return fmt.Errorf("%w: %s is %s, want %s", ErrInsufficientTrust, e.Name, e.Trust, min)
+	}
+	return nil
+}
+
+// Save writes the store back to its file if it changed
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,108 @@
+package truststore
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestStore(t *testing.T) {
+	keys, err := schnorr.GenerateTestKeys([]byte("truststore"), 4)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "trust.json")
+
+	// given
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error from Open: %v", err)
+	}
+	for i, add := range []struct {
+		name  string
+		level Level
+	}{{"alice", LevelFull}, {"bob", LevelMarginal}, {"mallory", LevelNever}} {
+		if _, err := s.Add(add.name, keys[i].PublicKey, add.level, ""); err != nil {
+			t.Fatalf("Unexpected error from Add: %v", err)
+		}
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Unexpected error from Save: %v", err)
+	}
+
+	// when
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error from Open: %v", err)
+	}
+
+	// then
+	t.Run("levels", func(t *testing.T) {
+		for _, tc := range []struct {
+			key  [33]byte
+			min  Level
+			want error
+		}{
+			{keys[0].PublicKey, LevelFull, nil},
+			{keys[1].PublicKey, LevelMarginal, nil},
+			{keys[1].PublicKey, LevelFull, ErrInsufficientTrust},
+			{keys[2].PublicKey, LevelFirstUse, ErrDistrusted},
+			{keys[3].PublicKey, LevelMarginal, ErrUnknownKey},
+		} {
+			s.MinTrust = tc.min
+			if err := s.Trusted(tc.key); !errors.Is(err, tc.want) {
+				t.Fatalf("Trusted(%x) at %s = %v, want %v", tc.key, tc.min, err, tc.want)
+			}
+		}
+	})
+
+	t.Run("names", func(t *testing.T) {
+		pk, e, err := s.Find("bob")
+		if err != nil {
+			t.Fatalf("Unexpected error from Find: %v", err)
+		}
+		if pk != keys[1].PublicKey || e.Fingerprint != Fingerprint(keys[1].PublicKey) {
+			t.Fatalf("Find() = %x %+v, want %x", pk, e, keys[1].PublicKey)
+		}
+		if _, err := s.Add("bob", keys[3].PublicKey, LevelFull, ""); err == nil {
+			t.Fatalf("Add() reused the name of another key")
+		}
+	})
+
+	t.Run("first use", func(t *testing.T) {
+		// given
+		s.MinTrust = LevelMarginal
+		s.FirstUse = true
+		warned := []*Entry{}
+		s.Warn = func(e *Entry) { warned = append(warned, e) }
+		signed, err := envelope.Sign(keys[3].PrivateKey, "text/plain", []byte("hello"))
+		if err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+
+		// when
+		for i := 0; i < 2; i++ {
+			if err := envelope.Verify(signed, keys[3].PublicKey, envelope.RequireTrusted(s)); err != nil {
+				t.Fatalf("Unexpected error from Verify: %v", err)
+			}
+		}
+
+		// then
+		if len(warned) != 1 || warned[0].Fingerprint != Fingerprint(keys[3].PublicKey) {
+			t.Fatalf("Warn called with %+v, want one warning for the new key", warned)
+		}
+		if e := s.Lookup(keys[3].PublicKey); e == nil || e.Trust != LevelFirstUse {
+			t.Fatalf("Lookup() = %+v, want a first-use pin", e)
+		}
+		if err := envelope.Verify(signed, keys[3].PublicKey, envelope.RequireTrusted(s)); err != nil {
+			t.Fatalf("Unexpected error from Verify: %v", err)
+		}
+		s.FirstUse = false
+		if err := envelope.Verify(signed, keys[3].PublicKey, envelope.RequireTrusted(s)); !errors.Is(err, ErrInsufficientTrust) {
+			t.Fatalf("Verify() error = %v, want %v", err, ErrInsufficientTrust)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package twoparty
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// Client is the client side of the split, talking to a Server
+type Client struct {
+	Address string
+	Token   string
+	HTTP    *http.Client
+}
+
+func NewClient(address, token string) *Client {
+	return &Client{
+		Address: strings.TrimSuffix(address, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *Client) do(ctx context.Context, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Address+Prefix+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		errResp := struct {
+			Errors []string `json:"errors"`
+		}{}
+		json.Unmarshal(data, &errResp)
+		return fmt.Errorf("2p server %s: %s %s", path, resp.Status, strings.Join(errResp.Errors, "; "))
+	}
+
+	wrapped := struct {
+		Data interface{} `json:"data"`
+	}{Data: out}
+	return json.Unmarshal(data, &wrapped)
+}
+
+// Pair creates a new split key with the server and returns the client's
+// share
+func (c *Client) Pair(ctx context.Context) (*Share, error) {
+	pairing, msg, err := Pair(RoleClient)
+	if err != nil {
+		return nil, err
+	}
+	reply := new(PairMessage)
+	if err := c.do(ctx, "/pair", msg, reply); err != nil {
+		return nil, err
+	}
+	return pairing.Complete(reply)
+}
+
+// Sign runs the signing rounds with the server and checks the signature it
+// returns against the joint key
+func (c *Client) Sign(ctx context.Context, share *Share, message [32]byte) ([64]byte, error) {
+	var signature [64]byte
+
+	session, commit, err := NewClientSession(share, message)
+	if err != nil {
+		return signature, err
+	}
+	started := struct {
+		Session string `json:"session"`
+		Nonce   string `json:"nonce"`
+	}{}
+	req := map[string]string{"message": hex.EncodeToString(message[:]), "commitment": commit.Commitment}
+	if err := c.do(ctx, "/keys/"+hex.EncodeToString(share.JointKey[:])+"/sign", req, &started); err != nil {
+		return signature, err
+	}
+
+	reveal, err := session.Reveal(share, &NonceMessage{Nonce: started.Nonce})
+	if err != nil {
+		return signature, err
+	}
+	done := struct {
+		Signature string `json:"signature"`
+	}{}
+	if err := c.do(ctx, "/sessions/"+started.Session+"/reveal", reveal, &done); err != nil {
+		return signature, err
+	}
+
+	raw, err := hex.DecodeString(done.Signature)
+	if err != nil || len(raw) != 64 {
+		return signature, fmt.Errorf("server returned a signature which is not 64 bytes of hex")
+	}
+	copy(signature[:], raw)
+	if ok, err := schnorr.Verify(share.JointKey, message, signature); !ok {
+		return signature, fmt.Errorf("server returned a signature which does not verify: %v", err)
+	}
+	return signature, nil
+}
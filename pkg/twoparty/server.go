@@ -0,0 +1,362 @@
+package twoparty
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/store"
+)
+
+// Prefix is the path the server answers under
+const Prefix = "/v1/2p"
+
+// DefaultSessionTimeout is how long a client has to reveal after committing
+const DefaultSessionTimeout = time.Minute
+
+// ShareStore keeps the server's shares by joint key
+type ShareStore interface {
+	Load(jointKey [33]byte) (*Share, error)
+	Save(share *Share) error
+}
+
+// MemoryShareStore keeps shares in memory, for tests and dev servers
+type MemoryShareStore struct {
+	mu     sync.Mutex
+	shares map[[33]byte]*Share
+}
+
+func NewMemoryShareStore() *MemoryShareStore {
+	return &MemoryShareStore{shares: map[[33]byte]*Share{}}
+}
+
+func (m *MemoryShareStore) Load(jointKey [33]byte) (*Share, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	share, ok := m.shares[jointKey]
+	if !ok {
+		return nil, fmt.Errorf("no share for %x", jointKey)
+	}
+	return share, nil
+}
+
+func (m *MemoryShareStore) Save(share *Share) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shares[share.JointKey] = share
+	return nil
+}
+
+// DirShareStore keeps each share in <dir>/<joint key>.json
+type DirShareStore struct {
+	dir string
+}
+
+func NewDirShareStore(dir string) (*DirShareStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DirShareStore{dir: dir}, nil
+}
+
+func (d *DirShareStore) path(jointKey [33]byte) string {
+	return filepath.Join(d.dir, hex.EncodeToString(jointKey[:])+".json")
+}
+
+func (d *DirShareStore) Load(jointKey [33]byte) (*Share, error) {
+	data, err := os.ReadFile(d.path(jointKey))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no share for %x", jointKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	share := new(Share)
+	if err := json.Unmarshal(data, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+func (d *DirShareStore) Save(share *Share) error {
+	data, err := json.MarshalIndent(share, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := d.path(share.JointKey)
+	if err := os.WriteFile(p+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// StateShareStore keeps shares in a daemon state store, so every replica
+// sharing it can sign with them
+type StateShareStore struct {
+	State store.Store
+}
+
+// shareBucket is the state store bucket of the shares
+const shareBucket = "twoparty-shares"
+
+func (s *StateShareStore) Load(jointKey [33]byte) (*Share, error) {
+	data, err := s.State.Get(context.Background(), shareBucket, hex.EncodeToString(jointKey[:]))
+	if err == store.ErrNotFound {
+		return nil, fmt.Errorf("no share for %x", jointKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	share := new(Share)
+	if err := json.Unmarshal(data, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+func (s *StateShareStore) Save(share *Share) error {
+	data, err := json.Marshal(share)
+	if err != nil {
+		return err
+	}
+	return s.State.Put(context.Background(), shareBucket, hex.EncodeToString(share.JointKey[:]), data, time.Time{})
+}
+
+// sessionBucket is the state store bucket of the sessions awaiting a reveal
+const sessionBucket = "twoparty-sessions"
+
+type pendingSession struct {
+	session  *Session
+	deadline time.Time
+}
+
+// Server is the server side of the split. It answers under /v1/2p with:
+//
+//	POST /pair                 a PairMessage, answered with the server's
+//	POST /keys/<joint>/sign    {"message": hex, "commitment": hex}, answered
+//	                           with {"session", "nonce"}
+//	POST /sessions/<id>/reveal a RevealMessage, answered with {"signature"}
+//
+// Approve, if set, decides whether the server takes part in signing a
+// message, which is where a hot server enforces its policy.
+//
+// State, if set, keeps the sessions awaiting a reveal instead of memory, so
+// a client can reveal to any replica sharing it. They hold the server's
+// secret nonces until then.
+type Server struct {
+	Store          ShareStore
+	Token          string
+	SessionTimeout time.Duration
+	Approve        func(jointKey [33]byte, message [32]byte) error
+	State          store.Store
+
+	mu       sync.Mutex
+	sessions map[string]*pendingSession
+}
+
+func NewServer(store ShareStore) *Server {
+	return &Server{Store: store, SessionTimeout: DefaultSessionTimeout, sessions: map[string]*pendingSession{}}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.Token)) != 1 {
+		writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, Prefix), "/")
+	parts := strings.Split(path, "/")
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound, "no handler for route")
+		return
+	}
+
+	switch {
+	case path == "pair":
+		s.handlePair(w, r)
+	case len(parts) == 3 && parts[0] == "keys" && parts[2] == "sign":
+		s.handleSign(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "sessions" && parts[2] == "reveal":
+		s.handleReveal(w, r, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "no handler for route")
+	}
+}
+
+func (s *Server) handlePair(w http.ResponseWriter, r *http.Request) {
+	var msg PairMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&msg); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pairing, reply, err := Pair(RoleServer)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	share, err := pairing.Complete(&msg)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.Store.Save(share); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeData(w, reply)
+}
+
+func (s *Server) handleSign(w http.ResponseWriter, r *http.Request, joint string) {
+	req := struct {
+		Message    string `json:"message"`
+		Commitment string `json:"commitment"`
+	}{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	jointKey, ok := parseKey(joint)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "joint key is not 33 bytes of hex")
+		return
+	}
+	raw, err := hex.DecodeString(req.Message)
+	if err != nil || len(raw) != 32 {
+		writeError(w, http.StatusBadRequest, "message must be 32 bytes of hex")
+		return
+	}
+	var message [32]byte
+	copy(message[:], raw)
+
+	share, err := s.Store.Load(jointKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if s.Approve != nil {
+		if err := s.Approve(jointKey, message); err != nil {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+	}
+
+	session, reply, err := NewServerSession(share, message, &CommitMessage{Commitment: req.Commitment})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := s.savePending(r.Context(), hex.EncodeToString(id), session); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeData(w, map[string]string{"session": hex.EncodeToString(id), "nonce": reply.Nonce})
+}
+
+func (s *Server) handleReveal(w http.ResponseWriter, r *http.Request, id string) {
+	var msg RevealMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&msg); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// a session is only ever completed once, whatever the outcome
+	pending, err := s.takePending(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if pending == nil {
+		writeError(w, http.StatusNotFound, "session not found or expired")
+		return
+	}
+
+	share, err := s.Store.Load(pending.JointKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	sig, err := pending.Complete(share, &msg)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeData(w, map[string]string{"signature": hex.EncodeToString(sig[:])})
+}
+
+func (s *Server) savePending(ctx context.Context, id string, session *Session) error {
+	now := time.Now()
+	deadline := now.Add(s.SessionTimeout)
+	if s.State != nil {
+		data, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		return s.State.Put(ctx, sessionBucket, id, data, deadline)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, p := range s.sessions {
+		if now.After(p.deadline) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[id] = &pendingSession{session: session, deadline: deadline}
+	return nil
+}
+
+// takePending removes the session and returns it, nil if there's none or
+// it has expired
+func (s *Server) takePending(ctx context.Context, id string) (*Session, error) {
+	if s.State != nil {
+		data, err := s.State.Take(ctx, sessionBucket, id)
+		if err == store.ErrNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		session := new(Session)
+		if err := json.Unmarshal(data, session); err != nil {
+			return nil, err
+		}
+		return session, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, ok := s.sessions[id]
+	delete(s.sessions, id)
+	if !ok || time.Now().After(pending.deadline) {
+		return nil, nil
+	}
+	return pending.session, nil
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]string{"errors": {msg}})
+}
@@ -0,0 +1,298 @@
+package twoparty
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// CommitMessage is the client's first message, a commitment to its nonce
+type CommitMessage struct {
+	Commitment string `json:"commitment"`
+}
+
+// NonceMessage is the server's answer, its nonce
+type NonceMessage struct {
+	Nonce string `json:"nonce"`
+}
+
+// RevealMessage opens the client's commitment and carries its partial
+// signature
+type RevealMessage struct {
+	Nonce   string `json:"nonce"`
+	Partial string `json:"partial"`
+}
+
+// State is where a session is
+type State string
+
+const (
+	// StateCommitted is the client waiting for the server's nonce
+	StateCommitted State = "committed"
+	// StateNonce is the server waiting for the client's reveal
+	StateNonce State = "nonce"
+	// StateDone is a session whose secret nonce has been used and dropped
+	StateDone State = "done"
+)
+
+// Session is one side's state while signing a message. It can be saved
+// between rounds with json, which includes the secret nonce: a saved session
+// must be stored like the share, and must never be resumed twice, since
+// answering two different peer nonces with the same secret nonce gives the
+// share away.
+type Session struct {
+	Role       Role
+	JointKey   [33]byte
+	Message    [32]byte
+	State      State
+	nonce      *big.Int
+	Nonce      [33]byte
+	Commitment [32]byte
+}
+
+// commitment hashes the client's nonce together with what is being signed
+func commitment(nonce, jointKey [33]byte, message [32]byte) [32]byte {
+	return schnorr.TaggedHash("schnorr-go/2p/commit", nonce[:], jointKey[:], message[:])
+}
+
+func newNonce() (*big.Int, [33]byte, error) {
+	k, err := randomScalar()
+	if err != nil {
+		return nil, [33]byte{}, err
+	}
+	R, err := schnorr.ScalarBaseMult(k).PublicKey()
+	return k, R, err
+}
+
+// NewClientSession starts signing the message and returns the commitment to
+// send to the server
+func NewClientSession(share *Share, message [32]byte) (*Session, *CommitMessage, error) {
+	if share.Role != RoleClient {
+		return nil, nil, fmt.Errorf("share is the %s's, signing is started by the client", share.Role)
+	}
+	k, R, err := newNonce()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := &Session{Role: RoleClient, JointKey: share.JointKey, Message: message, State: StateCommitted, nonce: k, Nonce: R}
+	s.Commitment = commitment(R, share.JointKey, message)
+	return s, &CommitMessage{Commitment: hex.EncodeToString(s.Commitment[:])}, nil
+}
+
+// NewServerSession answers a client's commitment to sign the message with
+// the server's nonce
+func NewServerSession(share *Share, message [32]byte, commit *CommitMessage) (*Session, *NonceMessage, error) {
+	if share.Role != RoleServer {
+		return nil, nil, fmt.Errorf("share is the %s's, not the server's", share.Role)
+	}
+	raw, err := hex.DecodeString(commit.Commitment)
+	if err != nil || len(raw) != 32 {
+		return nil, nil, fmt.Errorf("commitment is not 32 bytes of hex")
+	}
+	k, R, err := newNonce()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := &Session{Role: RoleServer, JointKey: share.JointKey, Message: message, State: StateNonce, nonce: k, Nonce: R}
+	copy(s.Commitment[:], raw)
+	return s, &NonceMessage{Nonce: hex.EncodeToString(R[:])}, nil
+}
+
+func (s *Session) use(share *Share, role Role, state State) error {
+	if share.Role != role || s.Role != role {
+		return fmt.Errorf("session is the %s's, share the %s's", s.Role, share.Role)
+	}
+	if share.JointKey != s.JointKey {
+		return fmt.Errorf("share is for %x, session for %x", share.JointKey, s.JointKey)
+	}
+	if s.State != state || s.nonce == nil {
+		return fmt.Errorf("session is %s, want %s", s.State, state)
+	}
+	return nil
+}
+
+// challenge combines the nonces into R and returns its x coordinate, the
+// challenge, and whether the nonces have to be negated
+func (s *Session) challenge(peerNonce [33]byte) ([]byte, *big.Int, bool, error) {
+	own, err := schnorr.ParsePoint(s.Nonce)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	peer, err := schnorr.ParsePoint(peerNonce)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("peer nonce: %v", err)
+	}
+	R := own.Add(peer)
+	if R.IsInfinity() {
+		return nil, nil, false, fmt.Errorf("the nonces cancel out")
+	}
+	P, err := schnorr.ParsePoint(s.JointKey)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	rX := schnorr.GetBigIntBytesImmutable(R.X())
+	e, err := schnorr.ComputeChallenge(rX, P.X(), P.Y(), s.Message[:], schnorr.LegacyChallenge)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return rX, e, big.Jacobi(R.Y(), schnorr.Curve.P) != 1, nil
+}
+
+// partial is k + e*x with k negated if R needed to be
+func partial(k, x, e *big.Int, negate bool) *big.Int {
+	k = new(big.Int).Set(k)
+	if negate {
+		k.Sub(schnorr.Curve.N, k)
+	}
+	s := new(big.Int).Mul(e, x)
+	s.Add(s, k)
+	return s.Mod(s, schnorr.Curve.N)
+}
+
+// Reveal answers the server's nonce with the client's nonce and partial
+// signature. The session's secret nonce is dropped.
+func (s *Session) Reveal(share *Share, msg *NonceMessage) (*RevealMessage, error) {
+	if err := s.use(share, RoleClient, StateCommitted); err != nil {
+		return nil, err
+	}
+	peerNonce, ok := parseKey(msg.Nonce)
+	if !ok {
+		return nil, fmt.Errorf("server nonce is not 33 bytes of hex")
+	}
+	_, e, negate, err := s.challenge(peerNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	s1 := partial(s.nonce, share.Secret, e, negate)
+	s.nonce = nil
+	s.State = StateDone
+	return &RevealMessage{Nonce: hex.EncodeToString(s.Nonce[:]), Partial: hex.EncodeToString(schnorr.GetBigIntBytesImmutable(s1))}, nil
+}
+
+// Complete checks the client's reveal and partial signature and finishes
+// the signature. The session's secret nonce is dropped either way.
+func (s *Session) Complete(share *Share, msg *RevealMessage) ([64]byte, error) {
+	var signature [64]byte
+	if err := s.use(share, RoleServer, StateNonce); err != nil {
+		return signature, err
+	}
+	k := s.nonce
+	s.nonce = nil
+	s.State = StateDone
+
+	clientNonce, ok := parseKey(msg.Nonce)
+	if !ok {
+		return signature, fmt.Errorf("client nonce is not 33 bytes of hex")
+	}
+	if commitment(clientNonce, s.JointKey, s.Message) != s.Commitment {
+		return signature, fmt.Errorf("client nonce does not open its commitment")
+	}
+	raw, err := hex.DecodeString(msg.Partial)
+	if err != nil || len(raw) != 32 {
+		return signature, fmt.Errorf("client partial signature is not 32 bytes of hex")
+	}
+	s1 := new(big.Int).SetBytes(raw)
+	if s1.Cmp(schnorr.Curve.N) >= 0 {
+		return signature, fmt.Errorf("client partial signature is not below the curve order")
+	}
+
+	rX, e, negate, err := s.challenge(clientNonce)
+	if err != nil {
+		return signature, err
+	}
+
+	// s1*G must be +-R1 + e*P1
+	R1, _ := schnorr.ParsePoint(clientNonce)
+	if negate {
+		R1 = R1.Negate()
+	}
+	P1, err := schnorr.ParsePoint(share.PeerKey)
+	if err != nil {
+		return signature, err
+	}
+	if !schnorr.ScalarBaseMult(s1).Equal(R1.Add(P1.Mul(e))) {
+		return signature, fmt.Errorf("client partial signature does not verify")
+	}
+
+	sum := partial(k, share.Secret, e, negate)
+	sum.Add(sum, s1)
+	sum.Mod(sum, schnorr.Curve.N)
+
+	copy(signature[:32], rX)
+	copy(signature[32:], schnorr.GetBigIntBytesImmutable(sum))
+	if ok, err := schnorr.Verify(s.JointKey, s.Message, signature); !ok {
+		return signature, fmt.Errorf("joint signature does not verify: %v", err)
+	}
+	return signature, nil
+}
+
+type sessionJSON struct {
+	Role       Role   `json:"role"`
+	JointKey   string `json:"joint_key"`
+	Message    string `json:"message"`
+	State      State  `json:"state"`
+	SecNonce   string `json:"secnonce,omitempty"`
+	Nonce      string `json:"nonce"`
+	Commitment string `json:"commitment"`
+}
+
+func (s *Session) MarshalJSON() ([]byte, error) {
+	j := &sessionJSON{
+		Role:       s.Role,
+		JointKey:   hex.EncodeToString(s.JointKey[:]),
+		Message:    hex.EncodeToString(s.Message[:]),
+		State:      s.State,
+		Nonce:      hex.EncodeToString(s.Nonce[:]),
+		Commitment: hex.EncodeToString(s.Commitment[:]),
+	}
+	if s.nonce != nil {
+		j.SecNonce = hex.EncodeToString(schnorr.GetBigIntBytesImmutable(s.nonce))
+	}
+	return json.Marshal(j)
+}
+
+func (s *Session) UnmarshalJSON(data []byte) error {
+	var j sessionJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	session := Session{Role: j.Role, State: j.State}
+	var ok bool
+	if session.JointKey, ok = parseKey(j.JointKey); !ok {
+		return fmt.Errorf("session joint key is not 33 bytes of hex")
+	}
+	if session.Nonce, ok = parseKey(j.Nonce); !ok {
+		return fmt.Errorf("session nonce is not 33 bytes of hex")
+	}
+	for _, f := range []struct {
+		dst *[32]byte
+		src string
+	}{{&session.Message, j.Message}, {&session.Commitment, j.Commitment}} {
+		raw, err := hex.DecodeString(f.src)
+		if err != nil || len(raw) != 32 {
+			return fmt.Errorf("session message and commitment must be 32 bytes of hex")
+		}
+		copy(f.dst[:], raw)
+	}
+
+	if j.SecNonce != "" {
+		k, ok := new(big.Int).SetString(j.SecNonce, 16)
+		if !ok {
+			return fmt.Errorf("session secret nonce is not hex")
+		}
+		if R, err := schnorr.ScalarBaseMult(k).PublicKey(); err != nil || R != session.Nonce {
+			return fmt.Errorf("session secret nonce does not match its nonce")
+		}
+		session.nonce = k
+	}
+	*s = session
+	return nil
+}
@@ -0,0 +1,220 @@
+package twoparty
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Two-party signing with an additively split key: the client holds x1, the
+// server x2, and the joint key is P = x1*G + x2*G. Signatures are ordinary
+// signatures under P that neither side can make alone.
+//
+// Pairing: each side sends its public key with a proof of knowledge of the
+// secret, so neither can pick its key as a function of the other's and end
+// up controlling P.
+//
+// Signing, MuSig1 style with the client committing to its nonce first:
+//
+//	client -> server  Commit:  H(R1)
+//	server -> client  Nonce:   R2
+//	client -> server  Reveal:  R1, s1
+//	server            checks s1, adds s2 and has the signature
+//
+// R = R1 + R2 is negated by both sides when its y is not a quadratic
+// residue, as Sign does for single signers.
+//
+
+// Role is which side of the split a share is
+type Role string
+
+const (
+	RoleClient Role = "client"
+	RoleServer Role = "server"
+)
+
+func (r Role) peer() Role {
+	if r == RoleClient {
+		return RoleServer
+	}
+	return RoleClient
+}
+
+// Share is one side's half of a split key
+type Share struct {
+	Role      Role
+	Secret    *big.Int
+	PublicKey [33]byte
+	PeerKey   [33]byte
+	JointKey  [33]byte
+}
+
+type shareJSON struct {
+	Role      Role   `json:"role"`
+	Secret    string `json:"secret"`
+	PublicKey string `json:"public_key"`
+	PeerKey   string `json:"peer_key"`
+	JointKey  string `json:"joint_key"`
+}
+
+func (s *Share) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&shareJSON{
+		Role:      s.Role,
+		Secret:    hex.EncodeToString(schnorr.GetBigIntBytesImmutable(s.Secret)),
+		PublicKey: hex.EncodeToString(s.PublicKey[:]),
+		PeerKey:   hex.EncodeToString(s.PeerKey[:]),
+		JointKey:  hex.EncodeToString(s.JointKey[:]),
+	})
+}
+
+func (s *Share) UnmarshalJSON(data []byte) error {
+	var j shareJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Role != RoleClient && j.Role != RoleServer {
+		return fmt.Errorf("share has unknown role %q", j.Role)
+	}
+	secret, ok := new(big.Int).SetString(j.Secret, 16)
+	if !ok {
+		return fmt.Errorf("share secret is not hex")
+	}
+
+	share := Share{Role: j.Role, Secret: secret}
+	for _, k := range []struct {
+		dst *[33]byte
+		src string
+	}{{&share.PublicKey, j.PublicKey}, {&share.PeerKey, j.PeerKey}, {&share.JointKey, j.JointKey}} {
+		if *k.dst, ok = parseKey(k.src); !ok {
+			return fmt.Errorf("share keys must be 33 bytes of hex")
+		}
+	}
+	if err := share.check(); err != nil {
+		return err
+	}
+	*s = share
+	return nil
+}
+
+// check makes sure the keys of the share fit together
+func (s *Share) check() error {
+	own, err := schnorr.ScalarBaseMult(s.Secret).PublicKey()
+	if err != nil || own != s.PublicKey {
+		return fmt.Errorf("share secret does not match its public key")
+	}
+	joint, err := jointKey(s.PublicKey, s.PeerKey)
+	if err != nil {
+		return err
+	}
+	if joint != s.JointKey {
+		return fmt.Errorf("share joint key is not the sum of the two public keys")
+	}
+	return nil
+}
+
+func parseKey(s string) ([33]byte, bool) {
+	var key [33]byte
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != 33 {
+		return key, false
+	}
+	copy(key[:], raw)
+	return key, true
+}
+
+func jointKey(a, b [33]byte) ([33]byte, error) {
+	pa, err := schnorr.ParsePoint(a)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	pb, err := schnorr.ParsePoint(b)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	joint := pa.Add(pb)
+	if joint.IsInfinity() {
+		return [33]byte{}, fmt.Errorf("the two keys cancel out")
+	}
+	return joint.PublicKey()
+}
+
+// PairMessage is what each side sends to pair: its public key and a
+// signature over it proving it knows the secret
+type PairMessage struct {
+	PublicKey string `json:"public_key"`
+	Proof     string `json:"proof"`
+}
+
+// Pairing is one side's state while pairing
+type Pairing struct {
+	role   Role
+	secret *big.Int
+	key    [33]byte
+}
+
+// proofMessage binds the proof to the role, so a side can't send the other
+// its own message back
+func proofMessage(role Role, publickey [33]byte) [32]byte {
+	return schnorr.TaggedHash("schnorr-go/2p/pair", []byte(role), publickey[:])
+}
+
+// Pair generates this side's half of a new key
+func Pair(role Role) (*Pairing, *PairMessage, error) {
+	if role != RoleClient && role != RoleServer {
+		return nil, nil, fmt.Errorf("unknown role %q", role)
+	}
+	secret, err := randomScalar()
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := schnorr.ScalarBaseMult(secret).PublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	proof, err := schnorr.Sign(secret, proofMessage(role, key))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &Pairing{role: role, secret: secret, key: key}
+	return p, &PairMessage{PublicKey: hex.EncodeToString(key[:]), Proof: hex.EncodeToString(proof[:])}, nil
+}
+
+// Complete checks the other side's message and returns this side's share
+func (p *Pairing) Complete(peer *PairMessage) (*Share, error) {
+	peerKey, ok := parseKey(peer.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("peer public key is not 33 bytes of hex")
+	}
+	if peerKey == p.key {
+		return nil, fmt.Errorf("peer sent our own public key")
+	}
+	raw, err := hex.DecodeString(peer.Proof)
+	if err != nil || len(raw) != 64 {
+		return nil, fmt.Errorf("peer proof is not 64 bytes of hex")
+	}
+	var proof [64]byte
+	copy(proof[:], raw)
+	if ok, err := schnorr.Verify(peerKey, proofMessage(p.role.peer(), peerKey), proof); !ok {
+		return nil, fmt.Errorf("peer proof of knowledge does not verify: %v", err)
+	}
+
+	joint, err := jointKey(p.key, peerKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Share{Role: p.role, Secret: p.secret, PublicKey: p.key, PeerKey: peerKey, JointKey: joint}, nil
+}
+
+func randomScalar() (*big.Int, error) {
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(schnorr.Curve.N, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
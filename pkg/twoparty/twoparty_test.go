@@ -0,0 +1,230 @@
+package twoparty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/store"
+)
+
+func pair(t *testing.T) (*Share, *Share) {
+	clientPairing, clientMsg, err := Pair(RoleClient)
+	if err != nil {
+		t.Fatalf("Unexpected error from Pair: %v", err)
+	}
+	serverPairing, serverMsg, err := Pair(RoleServer)
+	if err != nil {
+		t.Fatalf("Unexpected error from Pair: %v", err)
+	}
+	client, err := clientPairing.Complete(serverMsg)
+	if err != nil {
+		t.Fatalf("Unexpected error from Complete: %v", err)
+	}
+	server, err := serverPairing.Complete(clientMsg)
+	if err != nil {
+		t.Fatalf("Unexpected error from Complete: %v", err)
+	}
+	return client, server
+}
+
+func TestSign(t *testing.T) {
+	// given
+	client, server := pair(t)
+	if client.JointKey != server.JointKey {
+		t.Fatalf("joint keys differ: %x and %x", client.JointKey, server.JointKey)
+	}
+
+	for i := 0; i < 8; i++ {
+		message := sha256.Sum256([]byte(fmt.Sprintf("message %d", i)))
+
+		// when
+		cs, commit, err := NewClientSession(client, message)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewClientSession: %v", err)
+		}
+		ss, nonce, err := NewServerSession(server, message, commit)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewServerSession: %v", err)
+		}
+		reveal, err := cs.Reveal(client, nonce)
+		if err != nil {
+			t.Fatalf("Unexpected error from Reveal: %v", err)
+		}
+		sig, err := ss.Complete(server, reveal)
+		if err != nil {
+			t.Fatalf("Unexpected error from Complete: %v", err)
+		}
+
+		// then
+		if ok, err := schnorr.Verify(client.JointKey, message, sig); !ok {
+			t.Fatalf("Verify() = %v, %v, want true", ok, err)
+		}
+		if _, err := cs.Reveal(client, nonce); err == nil {
+			t.Fatalf("Reveal twice succeeded, want error")
+		}
+	}
+}
+
+func TestPairing(t *testing.T) {
+	t.Run("Reflected message", func(t *testing.T) {
+		p, msg, _ := Pair(RoleClient)
+		if _, err := p.Complete(msg); err == nil {
+			t.Fatalf("Complete with our own message succeeded, want error")
+		}
+	})
+
+	t.Run("Message for the wrong role", func(t *testing.T) {
+		p, _, _ := Pair(RoleClient)
+		_, other, _ := Pair(RoleClient)
+		if _, err := p.Complete(other); err == nil {
+			t.Fatalf("Complete with another client's message succeeded, want error")
+		}
+	})
+
+	t.Run("Share round trip", func(t *testing.T) {
+		client, _ := pair(t)
+		data, err := json.Marshal(client)
+		if err != nil {
+			t.Fatalf("Unexpected error from Marshal: %v", err)
+		}
+		var observed Share
+		if err := json.Unmarshal(data, &observed); err != nil {
+			t.Fatalf("Unexpected error from Unmarshal: %v", err)
+		}
+		if observed.JointKey != client.JointKey || observed.Secret.Cmp(client.Secret) != 0 {
+			t.Fatalf("Unmarshal() = %+v, want %+v", observed, client)
+		}
+	})
+}
+
+func TestSessionErrors(t *testing.T) {
+	client, server := pair(t)
+	message := sha256.Sum256([]byte("pay carol"))
+
+	t.Run("Reveal of another nonce", func(t *testing.T) {
+		cs, commit, _ := NewClientSession(client, message)
+		ss, nonce, _ := NewServerSession(server, message, commit)
+		reveal, _ := cs.Reveal(client, nonce)
+
+		other, _, _ := NewClientSession(client, message)
+		reveal.Nonce = hex.EncodeToString(other.Nonce[:])
+
+		if _, err := ss.Complete(server, reveal); err == nil {
+			t.Fatalf("Complete with a nonce not matching the commitment succeeded, want error")
+		}
+	})
+
+	t.Run("Bad partial", func(t *testing.T) {
+		cs, commit, _ := NewClientSession(client, message)
+		ss, nonce, _ := NewServerSession(server, message, commit)
+		reveal, _ := cs.Reveal(client, nonce)
+		reveal.Partial = "01" + reveal.Partial[2:]
+
+		if _, err := ss.Complete(server, reveal); err == nil {
+			t.Fatalf("Complete with a bad partial signature succeeded, want error")
+		}
+	})
+
+	t.Run("Saved session", func(t *testing.T) {
+		cs, commit, _ := NewClientSession(client, message)
+		data, err := json.Marshal(cs)
+		if err != nil {
+			t.Fatalf("Unexpected error from Marshal: %v", err)
+		}
+		var resumed Session
+		if err := json.Unmarshal(data, &resumed); err != nil {
+			t.Fatalf("Unexpected error from Unmarshal: %v", err)
+		}
+
+		ss, nonce, _ := NewServerSession(server, message, commit)
+		reveal, err := resumed.Reveal(client, nonce)
+		if err != nil {
+			t.Fatalf("Unexpected error from Reveal: %v", err)
+		}
+		if _, err := ss.Complete(server, reveal); err != nil {
+			t.Fatalf("Unexpected error from Complete: %v", err)
+		}
+	})
+}
+
+func TestClientServer(t *testing.T) {
+	server := NewServer(NewMemoryShareStore())
+	server.Token = "pairing"
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	ctx := context.Background()
+	client := NewClient(ts.URL, "pairing")
+	share, err := client.Pair(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error from Pair: %v", err)
+	}
+
+	message := sha256.Sum256([]byte("rotate the vault key"))
+	sig, err := client.Sign(ctx, share, message)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	if ok, err := schnorr.Verify(share.JointKey, message, sig); !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+
+	t.Run("Server policy refuses", func(t *testing.T) {
+		server.Approve = func(jointKey [33]byte, message [32]byte) error {
+			return fmt.Errorf("outside business hours")
+		}
+		defer func() { server.Approve = nil }()
+		if _, err := client.Sign(ctx, share, message); err == nil {
+			t.Fatalf("Sign refused by the server succeeded, want error")
+		}
+	})
+
+	t.Run("Bad token", func(t *testing.T) {
+		if _, err := NewClient(ts.URL, "guess").Pair(ctx); err == nil {
+			t.Fatalf("Pair with a bad token succeeded, want error")
+		}
+	})
+}
+
+func TestReplicas(t *testing.T) {
+	// given two servers sharing state, taking requests in turn
+	state := store.NewMemory()
+	replicas := []*Server{}
+	for i := 0; i < 2; i++ {
+		server := NewServer(&StateShareStore{State: state})
+		server.State = state
+		replicas = append(replicas, server)
+	}
+	n := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replicas[n%2].ServeHTTP(w, r)
+		n++
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	client := NewClient(ts.URL, "")
+	share, err := client.Pair(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error from Pair: %v", err)
+	}
+
+	// when
+	message := sha256.Sum256([]byte("rotate the vault key"))
+	sig, err := client.Sign(ctx, share, message)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	if ok, err := schnorr.Verify(share.JointKey, message, sig); !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+}
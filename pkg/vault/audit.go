@@ -0,0 +1,44 @@
+package vault
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry is a line of the engine's audit log: a signature made, or a
+// request the hooks, templates or limits refused
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Reason    string    `json:"reason,omitempty"`
+	Key       string    `json:"key"`
+	PublicKey string    `json:"publicKey,omitempty"`
+	Client    string    `json:"client"`
+	Digest    string    `json:"digest,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+// Audit outcomes
+const (
+	AuditSigned  = "signed"
+	AuditRefused = "refused"
+)
+
+// audit appends the entry to the audit log, if there is one. The response
+// has been decided by then, so a log that can't be written to doesn't
+// change it.
+func (e *Engine) audit(entry AuditEntry) {
+	if e.Audit == nil {
+		return
+	}
+	entry.Time = e.clock().UTC()
+	entry.Tenant = e.Tenant
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	e.auditMu.Lock()
+	defer e.auditMu.Unlock()
+	e.Audit.Write(append(data, '\n'))
+}
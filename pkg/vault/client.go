@@ -0,0 +1,342 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// DefaultMount is the path the engine is expected to be mounted at
+const DefaultMount = "schnorr"
+
+// DefaultRetryPolicy retries twice, backing off from 200ms
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, MinBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// RetryPolicy is how a Client retries requests that failed for reasons
+// that may pass: a dropped connection, or a 409, 429, 502, 503 or 504. Requests
+// that may already have taken effect, like a rotation whose response was
+// lost, are not retried. The context passed to a call bounds all its
+// attempts together.
+type RetryPolicy struct {
+	// Attempts is the most times a request is sent, 1 for no retries
+	Attempts int
+	// MinBackoff is the wait before the first retry, doubling each time up
+	// to MaxBackoff. A longer Retry-After from the server is honoured if it
+	// is within MaxBackoff, otherwise the error is returned at once.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// idempotencyKey is the context key of WithIdempotencyKey
+type idempotencyKey struct{}
+
+// WithIdempotencyKey has signing requests made with the context sent with
+// the key, so that one repeated after the caller lost its response, by a
+// crash or a timeout, is answered with the same signature rather than
+// signed again. Without one each signing call picks its own, which covers
+// the client's own retries.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// Client talks to a Vault server with the schnorr engine mounted. It is a
+// backend.Backend: keys stay in Vault, the client checks every signature
+// that comes back against the key's public key before returning it.
+//
+// A Client is safe for concurrent use and should be reused: it keeps its
+// connections open, and remembers the public key of every key version it
+// has seen since versions never change, so signing needs one round trip.
+// Errors from the server are *APIError.
+type Client struct {
+	Address   string
+	Token     string
+	Mount     string
+	Namespace string
+	HTTP      *http.Client
+	Retry     RetryPolicy
+
+	mu   sync.Mutex
+	keys map[string]map[int][33]byte
+}
+
+// NewClient returns a client for the engine mounted at mount on address
+func NewClient(address, token, mount string) *Client {
+	if mount == "" {
+		mount = DefaultMount
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 16
+
+	return &Client{
+		Address: strings.TrimSuffix(address, "/"),
+		Token:   token,
+		Mount:   mount,
+		HTTP:    &http.Client{Timeout: 30 * time.Second, Transport: transport},
+		Retry:   DefaultRetryPolicy,
+	}
+}
+
+// do sends the request, retrying under the retry policy. idempotent says
+// the request can safely be sent again even if the server may have acted
+// on it.
+func (c *Client) do(ctx context.Context, method, path string, idempotent bool, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	backoff := c.Retry.MinBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.send(ctx, method, path, data, out)
+		if err == nil || attempt >= c.Retry.Attempts || ctx.Err() != nil {
+			return err
+		}
+
+		wait := backoff
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			if !apiErr.Temporary() || (apiErr.processed() && !idempotent) {
+				return err
+			}
+			if apiErr.RetryAfter > wait {
+				wait = apiErr.RetryAfter
+			}
+		} else if !idempotent {
+			return err
+		}
+		if wait > c.Retry.MaxBackoff {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+		if backoff *= 2; backoff > c.Retry.MaxBackoff {
+			backoff = c.Retry.MaxBackoff
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.Address+"/v1/"+c.Mount+"/"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", c.Token)
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key, ok := ctx.Value(idempotencyKey{}).(string); ok {
+		req.Header.Set(IdempotencyHeader, key)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		errResp := struct {
+			Errors []string `json:"errors"`
+		}{}
+		json.Unmarshal(data, &errResp)
+		apiErr := &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Status: resp.Status, Errors: errResp.Errors}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return apiErr
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// CreateKey creates the named key, doing nothing if it already exists
+func (c *Client) CreateKey(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, "keys/"+name, true, map[string]string{"type": "schnorr-secp256k1"}, nil)
+}
+
+// RotateKey adds a new version of the key which is used for new signatures
+func (c *Client) RotateKey(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, "keys/"+name+"/rotate", false, nil, nil)
+}
+
+// PublicKeys returns the public key of every version of the key and the
+// latest version
+func (c *Client) PublicKeys(ctx context.Context, name string) (map[int][33]byte, int, error) {
+	resp := struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+			Keys          map[string]struct {
+				PublicKey string `json:"public_key"`
+			} `json:"keys"`
+		} `json:"data"`
+	}{}
+	if err := c.do(ctx, http.MethodGet, "keys/"+name, true, nil, &resp); err != nil {
+		return nil, 0, err
+	}
+
+	keys := map[int][33]byte{}
+	for v, k := range resp.Data.Keys {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid key version %q", v)
+		}
+		raw, err := hex.DecodeString(k.PublicKey)
+		if err != nil || len(raw) != 33 {
+			return nil, 0, fmt.Errorf("invalid public key for version %d", version)
+		}
+		var pk [33]byte
+		copy(pk[:], raw)
+		keys[version] = pk
+	}
+
+	if _, ok := keys[resp.Data.LatestVersion]; !ok {
+		return nil, 0, fmt.Errorf("latest version %d of %s has no public key", resp.Data.LatestVersion, name)
+	}
+
+	c.mu.Lock()
+	if c.keys == nil {
+		c.keys = map[string]map[int][33]byte{}
+	}
+	c.keys[name] = keys
+	c.mu.Unlock()
+
+	return keys, resp.Data.LatestVersion, nil
+}
+
+// PublicKey returns the public key of the latest version of the key
+func (c *Client) PublicKey(ctx context.Context, name string) ([33]byte, error) {
+	keys, latest, err := c.PublicKeys(ctx, name)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	return keys[latest], nil
+}
+
+// Sign has Vault sign the message with the latest version of the key
+func (c *Client) Sign(ctx context.Context, name string, message [32]byte) ([64]byte, error) {
+	sig, _, err := c.SignVersion(ctx, name, message, 0)
+	return sig, err
+}
+
+// SignVersion signs with a specific key version, 0 being the latest, and
+// returns the version that was used
+func (c *Client) SignVersion(ctx context.Context, name string, message [32]byte, version int) ([64]byte, int, error) {
+	req := map[string]interface{}{"input": base64.StdEncoding.EncodeToString(message[:])}
+	return c.sign(ctx, name, req, message, version)
+}
+
+// SignMessage sends the message itself, for keys whose templates must see
+// it, and has its sha256 signed with the latest version of the key
+func (c *Client) SignMessage(ctx context.Context, name string, message []byte) ([64]byte, error) {
+	req := map[string]interface{}{"message": base64.StdEncoding.EncodeToString(message)}
+	sig, _, err := c.sign(ctx, name, req, sha256.Sum256(message), 0)
+	return sig, err
+}
+
+// sign sends a signing request and checks the signature over digest
+func (c *Client) sign(ctx context.Context, name string, req map[string]interface{}, digest [32]byte, version int) ([64]byte, int, error) {
+	var signature [64]byte
+
+	resp := struct {
+		Data struct {
+			Signature  string `json:"signature"`
+			KeyVersion int    `json:"key_version"`
+		} `json:"data"`
+	}{}
+	if version > 0 {
+		req["key_version"] = version
+	}
+	// every attempt is the same request to the server
+	if _, ok := ctx.Value(idempotencyKey{}).(string); !ok {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return signature, 0, err
+		}
+		ctx = WithIdempotencyKey(ctx, hex.EncodeToString(b))
+	}
+	if err := c.do(ctx, http.MethodPost, "sign/"+name, true, req, &resp); err != nil {
+		return signature, 0, err
+	}
+
+	// schnorr:v<version>:<base64 signature>
+	parts := strings.SplitN(resp.Data.Signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "schnorr" || parts[1] != "v"+strconv.Itoa(resp.Data.KeyVersion) {
+		return signature, 0, fmt.Errorf("unexpected signature format %q", resp.Data.Signature)
+	}
+	raw, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil || len(raw) != 64 {
+		return signature, 0, fmt.Errorf("signature is not 64 bytes of base64")
+	}
+	copy(signature[:], raw)
+
+	pk, err := c.versionKey(ctx, name, resp.Data.KeyVersion)
+	if err != nil {
+		return signature, 0, err
+	}
+	if ok, err := schnorr.Verify(pk, digest, signature); !ok {
+		return signature, 0, fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+
+	return signature, resp.Data.KeyVersion, nil
+}
+
+// versionKey is the public key of one version, fetching the key's versions
+// only when the version has not been seen before
+func (c *Client) versionKey(ctx context.Context, name string, version int) ([33]byte, error) {
+	c.mu.Lock()
+	pk, ok := c.keys[name][version]
+	c.mu.Unlock()
+	if ok {
+		return pk, nil
+	}
+
+	keys, _, err := c.PublicKeys(ctx, name)
+	if err != nil {
+		return pk, err
+	}
+	if pk, ok = keys[version]; !ok {
+		return pk, fmt.Errorf("vault signed with unknown key version %d", version)
+	}
+	return pk, nil
+}
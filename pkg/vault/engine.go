@@ -0,0 +1,527 @@
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/hooks"
+	"github.com/ryohare/schnorr-go/pkg/msgtemplate"
+	"github.com/ryohare/schnorr-go/pkg/ratelimit"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/store"
+)
+
+// Storage is where the engine keeps its keys. Inside Vault this is the
+// plugin's logical storage, which is encrypted by Vault's barrier.
+type Storage interface {
+	Get(name string) ([]byte, error)
+	Put(name string, value []byte) error
+	// List returns the names of the entries starting with prefix
+	List(prefix string) ([]string, error)
+}
+
+// MemoryStorage keeps keys in memory, for tests and dev servers
+type MemoryStorage struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (m *MemoryStorage) Get(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entries[name], nil
+}
+
+func (m *MemoryStorage) Put(name string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = map[string][]byte{}
+	}
+	m.entries[name] = value
+	return nil
+}
+
+func (m *MemoryStorage) List(prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := []string{}
+	for name := range m.entries {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// StateStorage keeps keys in a daemon state store, so every replica
+// sharing it signs with the same keys
+type StateStorage struct {
+	State store.Store
+}
+
+// keyBucket is the state store bucket of the keys
+const keyBucket = "vault-keys"
+
+func (s *StateStorage) Get(name string) ([]byte, error) {
+	data, err := s.State.Get(context.Background(), keyBucket, name)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *StateStorage) Put(name string, value []byte) error {
+	return s.State.Put(context.Background(), keyBucket, name, value, time.Time{})
+}
+
+func (s *StateStorage) List(prefix string) ([]string, error) {
+	keys, err := s.State.List(context.Background(), keyBucket)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+	return names, nil
+}
+
+// storedKey is a named key with all of its versions, like a transit key.
+// Created is when each version was generated, and is missing for keys
+// stored before it was recorded.
+type storedKey struct {
+	LatestVersion int                  `json:"latest_version"`
+	Versions      map[string]string    `json:"versions"`
+	Created       map[string]time.Time `json:"created,omitempty"`
+}
+
+// created returns when the version was generated, or the zero time if that
+// wasn't recorded
+func (k *storedKey) created(version int) time.Time {
+	return k.Created[strconv.Itoa(version)]
+}
+
+// Engine is the secrets engine side of the transit-style API. It answers
+// under /v1/<mount>/ with:
+//
+//	POST /keys/<name>         create a key
+//	GET  /keys/<name>         read the public keys of every version
+//	POST /keys/<name>/rotate  add a new key version
+//	POST /sign/<name>         sign {"input": base64(32 byte digest)}, or
+//	                          {"message": base64(message)} to sign its sha256
+//	GET  /limits              rate limit counters per key and principal
+//
+// A Vault plugin wraps the same handlers, standalone it is a dev server.
+// With a Limiter, signing requests over the limits of the key or of the
+// principal Principal names are refused with 429. With a MaxAge, key
+// versions older than that are expired and signing with them is refused
+// until the key is rotated. Hooks run before and after every signature,
+// and their refusals are answered with 403, as are messages Templates
+// refuse and digests for keys that Templates restrict. Signing requests
+// with an Idempotency-Key header are answered once, and their response is
+// kept in Idempotency for IdempotencyTTL to replay to retries. An engine
+// serving one of several Tenants is named by Tenant, which its audit
+// entries and idempotency keys carry.
+type Engine struct {
+	Mount     string
+	Token     string
+	Tenant    string
+	Storage   Storage
+	Limiter   *ratelimit.Limiter
+	Principal func(r *http.Request) string
+	MaxAge    time.Duration
+	Hooks     *hooks.Set
+	Templates *msgtemplate.Policy
+
+	Idempotency    store.Store
+	IdempotencyTTL time.Duration
+
+	Audit   io.Writer
+	auditMu sync.Mutex
+
+	mu         sync.Mutex
+	signatures map[string]uint64
+	now        func() time.Time
+}
+
+// NewEngine returns an engine keeping its keys in memory
+func NewEngine(mount, token string) *Engine {
+	return &Engine{
+		Mount:       mount,
+		Token:       token,
+		Storage:     new(MemoryStorage),
+		Idempotency: store.NewMemory(),
+		signatures:  map[string]uint64{},
+		now:         time.Now,
+	}
+}
+
+func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Vault-Token")), []byte(e.Token)) != 1 {
+		writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	prefix := "/v1/" + e.Mount + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeError(w, http.StatusNotFound, "no handler for route")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	switch {
+	case len(parts) == 2 && parts[0] == "keys" && r.Method == http.MethodPost:
+		e.handleCreate(w, parts[1])
+	case len(parts) == 2 && parts[0] == "keys" && r.Method == http.MethodGet:
+		e.handleRead(w, parts[1])
+	case len(parts) == 3 && parts[0] == "keys" && parts[2] == "rotate" && r.Method == http.MethodPost:
+		e.handleRotate(w, parts[1])
+	case len(parts) == 2 && parts[0] == "sign" && r.Method == http.MethodPost:
+		e.handleIdempotentSign(w, r, parts[1])
+	case len(parts) == 1 && parts[0] == "limits" && r.Method == http.MethodGet:
+		e.handleLimits(w)
+	default:
+		writeError(w, http.StatusNotFound, "no handler for route")
+	}
+}
+
+func (e *Engine) load(name string) (*storedKey, error) {
+	data, err := e.Storage.Get("keys/" + name)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	key := new(storedKey)
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (e *Engine) store(name string, key *storedKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return e.Storage.Put("keys/"+name, data)
+}
+
+// keyNames lists the names of every stored key
+func (e *Engine) keyNames() ([]string, error) {
+	entries, err := e.Storage.List("keys/")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = strings.TrimPrefix(entry, "keys/")
+	}
+	return names, nil
+}
+
+func (e *Engine) clock() time.Time {
+	if e.now == nil {
+		return time.Now()
+	}
+	return e.now()
+}
+
+// expiresAt is when the version expires under MaxAge, or the zero time if
+// it never does
+func (e *Engine) expiresAt(key *storedKey, version int) time.Time {
+	created := key.created(version)
+	if e.MaxAge <= 0 || created.IsZero() {
+		return time.Time{}
+	}
+	return created.Add(e.MaxAge)
+}
+
+// addVersion generates a fresh private key as the next version
+func addVersion(key *storedKey, now time.Time) error {
+	d, err := randomScalar()
+	if err != nil {
+		return err
+	}
+	key.LatestVersion++
+	version := strconv.Itoa(key.LatestVersion)
+	key.Versions[version] = hex.EncodeToString(schnorr.GetBigIntBytesImmutable(d))
+	if key.Created == nil {
+		key.Created = map[string]time.Time{}
+	}
+	key.Created[version] = now.UTC()
+	return nil
+}
+
+func (e *Engine) handleCreate(w http.ResponseWriter, name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	existing, err := e.load(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if existing != nil {
+		// creating an existing key is a no-op, like transit
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	key := &storedKey{Versions: map[string]string{}}
+	if err := addVersion(key, e.clock()); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := e.store(name, key); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleRotate(w http.ResponseWriter, name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key, err := e.load(name)
+	if err != nil || key == nil {
+		writeError(w, http.StatusBadRequest, "key not found")
+		return
+	}
+	if err := addVersion(key, e.clock()); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := e.store(name, key); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleRead(w http.ResponseWriter, name string) {
+	key, err := e.load(name)
+	if err != nil || key == nil {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+
+	keys := map[string]map[string]string{}
+	for version, privHex := range key.Versions {
+		d, _ := new(big.Int).SetString(privHex, 16)
+		px, py := schnorr.Curve.ScalarBaseMult(schnorr.GetBigIntBytesImmutable(d))
+		keys[version] = map[string]string{"public_key": hex.EncodeToString(schnorr.Marshal(schnorr.Curve, px, py))}
+	}
+
+	writeData(w, map[string]interface{}{
+		"name":           name,
+		"type":           "schnorr-secp256k1",
+		"latest_version": key.LatestVersion,
+		"keys":           keys,
+	})
+}
+
+func (e *Engine) handleSign(w http.ResponseWriter, r *http.Request, name string) {
+	req := struct {
+		Input      string `json:"input"`
+		Message    string `json:"message"`
+		KeyVersion int    `json:"key_version"`
+	}{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the message itself may be sent instead of its digest, for templates
+	// and hooks to check
+	var input, message []byte
+	var err error
+	if req.Message != "" {
+		if req.Input != "" {
+			writeError(w, http.StatusBadRequest, "send the input digest or the message, not both")
+			return
+		}
+		if message, err = base64.StdEncoding.DecodeString(req.Message); err != nil {
+			writeError(w, http.StatusBadRequest, "message must be base64 encoded")
+			return
+		}
+		digest := sha256.Sum256(message)
+		input = digest[:]
+	} else {
+		input, err = base64.StdEncoding.DecodeString(req.Input)
+		if err != nil || len(input) != 32 {
+			writeError(w, http.StatusBadRequest, "input must be a base64 encoded 32 byte digest")
+			return
+		}
+	}
+
+	key, err := e.load(name)
+	if err != nil || key == nil {
+		writeError(w, http.StatusBadRequest, "key not found")
+		return
+	}
+
+	version := req.KeyVersion
+	if version == 0 {
+		version = key.LatestVersion
+	}
+	privHex, ok := key.Versions[strconv.Itoa(version)]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("key version %d not found", version))
+		return
+	}
+	if expires := e.expiresAt(key, version); !expires.IsZero() && !e.clock().Before(expires) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("key version %d expired at %s, rotate the key", version, expires.Format(time.RFC3339)))
+		return
+	}
+
+	hookReq := &hooks.Request{Key: fmt.Sprintf("%s:v%d", name, version), Principal: e.principal(r), Message: message, Digest: hex.EncodeToString(input)}
+	var hash func([]byte) [32]byte
+	if message != nil {
+		hash = sha256.Sum256
+	}
+	refused := func(err error) {
+		e.audit(AuditEntry{Outcome: AuditRefused, Reason: err.Error(), Key: hookReq.Key, Client: hookReq.Principal, Digest: hookReq.Digest})
+	}
+	if err := e.Hooks.RunPreSign(r.Context(), hookReq, hash); err != nil {
+		refused(err)
+		writeHookError(w, err)
+		return
+	}
+
+	// templates see the message as the hooks left it
+	if e.Templates.Restricted(name) {
+		if hookReq.Message == nil {
+			err := fmt.Errorf("key %s only signs messages matching its templates, send the message rather than its digest", name)
+			refused(err)
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err := e.Templates.Check(hookReq.Message, name); err != nil {
+			refused(err)
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+	}
+
+	// only requests that would otherwise be signed count against the limits
+	if e.Limiter != nil {
+		if err := e.Limiter.Allow(name, hookReq.Principal); err != nil {
+			refused(err)
+			if limited, ok := err.(*ratelimit.LimitedError); ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+			}
+			writeError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+	}
+
+	// the digest as the hooks left it
+	digest, err := hookReq.DigestBytes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	d, _ := new(big.Int).SetString(privHex, 16)
+	sig, err := schnorr.Sign(d, digest)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	hookReq.Signature = hex.EncodeToString(sig[:])
+	if err := e.Hooks.RunPostSign(r.Context(), hookReq); err != nil {
+		refused(err)
+		writeHookError(w, err)
+		return
+	}
+	var publickey string
+	if key, err := schnorr.NewPrivateKey(d); err == nil {
+		publickey = key.PublicKey().String()
+	}
+	e.audit(AuditEntry{Outcome: AuditSigned, Key: hookReq.Key, PublicKey: publickey, Client: hookReq.Principal, Digest: hex.EncodeToString(digest[:]), Signature: hookReq.Signature})
+
+	e.mu.Lock()
+	if e.signatures == nil {
+		e.signatures = map[string]uint64{}
+	}
+	e.signatures[name]++
+	e.mu.Unlock()
+
+	writeData(w, map[string]interface{}{
+		"signature":   fmt.Sprintf("schnorr:v%d:%s", version, base64.StdEncoding.EncodeToString(sig[:])),
+		"key_version": version,
+		// what was signed, which a pre-sign hook may have changed
+		"input": base64.StdEncoding.EncodeToString(digest[:]),
+	})
+}
+
+// principal names the client for rate limiting, by default its address
+func (e *Engine) principal(r *http.Request) string {
+	if e.Principal != nil {
+		return e.Principal(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (e *Engine) handleLimits(w http.ResponseWriter) {
+	if e.Limiter == nil {
+		writeData(w, map[string]interface{}{})
+		return
+	}
+	writeData(w, e.Limiter.Stats())
+}
+
+// writeHookError answers a hook's refusal with 403, and a hook that could
+// not be run with 500
+func writeHookError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*hooks.RejectedError); ok {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]string{"errors": {msg}})
+}
+
+func randomScalar() (*big.Int, error) {
+	max := new(big.Int).Sub(schnorr.Curve.N, big.NewInt(1))
+	d, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return nil, err
+	}
+	return d.Add(d, big.NewInt(1)), nil
+}
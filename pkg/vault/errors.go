@@ -0,0 +1,72 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrPermissionDenied matches an APIError for a missing or wrong token
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrKeyNotFound matches an APIError for a key that does not exist
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrRateLimited matches an APIError for a request over a rate limit or
+	// daily quota
+	ErrRateLimited = errors.New("rate limited")
+	// ErrBadSignature is returned when a signature that came back does not
+	// verify against the key, which should never happen with a sound server
+	ErrBadSignature = errors.New("signature from vault does not verify")
+)
+
+// APIError is an error response from the server. It matches the sentinel
+// errors above with errors.Is.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Errors     []string
+	// RetryAfter is the server's Retry-After, zero when it sent none
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("vault %s %s: %s %s", e.Method, e.Path, e.Status, strings.Join(e.Errors, "; "))
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrPermissionDenied:
+		return e.StatusCode == http.StatusForbidden
+	case ErrKeyNotFound:
+		if e.StatusCode == http.StatusNotFound {
+			return true
+		}
+		for _, msg := range e.Errors {
+			if msg == "key not found" {
+				return true
+			}
+		}
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// Temporary reports whether the request may succeed if sent again later
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusConflict, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// processed reports whether the server may have acted on the request, so
+// sending it again would not be safe unless it is idempotent
+func (e *APIError) processed() bool {
+	return e.StatusCode != http.StatusTooManyRequests && e.StatusCode != http.StatusServiceUnavailable
+}
@@ -0,0 +1,158 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/store"
+)
+
+// IdempotencyHeader names the client's key for a signing request. The
+// request sent again with the same key gets the first response back,
+// marked with ReplayedHeader, rather than a second signature: it isn't
+// signed, counted against the limits or shown to the hooks again.
+const IdempotencyHeader = "Idempotency-Key"
+
+// ReplayedHeader is set on responses replayed for an idempotency key
+const ReplayedHeader = "Idempotent-Replayed"
+
+// DefaultIdempotencyTTL is how long responses are kept for replay
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// pendingTTL bounds how long a request being signed holds its key, should
+// the replica signing it go before the response is recorded
+const pendingTTL = time.Minute
+
+// idempotencyBucket is the state store bucket of the responses
+const idempotencyBucket = "vault-idempotency"
+
+// idempotentResponse is what's kept for a key: the request it was first
+// used for, and its response once there is one
+type idempotentResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Pending     bool   `json:"pending,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// responseRecorder keeps a copy of the response as it's written
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// handleIdempotentSign signs as handleSign does, unless the request has an
+// idempotency key that has been used before. Then a request that's the
+// same as the first is answered with the first's response, or 409 while
+// that is still being signed, and a different one is refused with 422.
+// Only signatures are kept: a request that failed can be sent again with
+// its key and is tried afresh.
+func (e *Engine) handleIdempotentSign(w http.ResponseWriter, r *http.Request, name string) {
+	key := r.Header.Get(IdempotencyHeader)
+	if key == "" || e.Idempotency == nil {
+		e.handleSign(w, r, name)
+		return
+	}
+	if len(key) > 255 {
+		writeError(w, http.StatusBadRequest, "idempotency key is longer than 255 characters")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sum := sha256.Sum256(append([]byte(name+"\x00"), body...))
+	fingerprint := hex.EncodeToString(sum[:])
+
+	ctx := r.Context()
+	id := e.Mount + "/" + key
+	if e.Tenant != "" {
+		id = e.Tenant + "/" + id
+	}
+	pending, err := json.Marshal(idempotentResponse{Fingerprint: fingerprint, Pending: true})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = e.Idempotency.Create(ctx, idempotencyBucket, id, pending, e.clock().Add(pendingTTL))
+	if err == store.ErrExists {
+		e.replay(w, id, fingerprint, r)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	e.handleSign(rec, r, name)
+	if rec.status != http.StatusOK {
+		e.Idempotency.Delete(ctx, idempotencyBucket, id)
+		return
+	}
+	done, err := json.Marshal(idempotentResponse{Fingerprint: fingerprint, Status: rec.status, Body: rec.body.Bytes()})
+	if err != nil {
+		return
+	}
+	// the signature has been sent; if it can't be kept, a retry signs again
+	e.Idempotency.Put(ctx, idempotencyBucket, id, done, e.clock().Add(e.idempotencyTTL()))
+}
+
+// replay answers a request whose idempotency key has been used before
+func (e *Engine) replay(w http.ResponseWriter, id, fingerprint string, r *http.Request) {
+	data, err := e.Idempotency.Get(r.Context(), idempotencyBucket, id)
+	if err == store.ErrNotFound {
+		// the first request failed or expired just now
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusConflict, "request with this idempotency key is being retried, try again")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var first idempotentResponse
+	if err := json.Unmarshal(data, &first); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch {
+	case first.Fingerprint != fingerprint:
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("idempotency key %s was used for a different request", r.Header.Get(IdempotencyHeader)))
+	case first.Pending:
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusConflict, fmt.Sprintf("request with idempotency key %s is still being signed", r.Header.Get(IdempotencyHeader)))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(first.Status)
+		w.Write(first.Body)
+	}
+}
+
+func (e *Engine) idempotencyTTL() time.Duration {
+	if e.IdempotencyTTL <= 0 {
+		return DefaultIdempotencyTTL
+	}
+	return e.IdempotencyTTL
+}
@@ -0,0 +1,80 @@
+package vault
+
+import (
+	"sort"
+
+	"github.com/ryohare/schnorr-go/pkg/metrics"
+	"github.com/ryohare/schnorr-go/pkg/ratelimit"
+)
+
+//
+// Keystore health for Prometheus: how old every key is and when it
+// expires, how much it has signed and how close it is to its daily quota,
+// so that alerts fire before a signing request is refused rather than
+// after.
+//
+
+// Metrics collects the keystore metrics. Timestamps are unix seconds and
+// missing for keys stored before creation times were recorded.
+func (e *Engine) Metrics() []*metrics.Family {
+	keys := &metrics.Family{Name: "schnorr_vault_keys", Help: "Number of keys in the keystore.", Type: metrics.Gauge}
+	version := &metrics.Family{Name: "schnorr_vault_key_latest_version", Help: "Latest version of the key.", Type: metrics.Gauge}
+	rotated := &metrics.Family{Name: "schnorr_vault_key_rotated_timestamp_seconds", Help: "When the latest version of the key was created.", Type: metrics.Gauge}
+	expires := &metrics.Family{Name: "schnorr_vault_key_expiry_timestamp_seconds", Help: "When the latest version of the key expires under the maximum key age.", Type: metrics.Gauge}
+	signatures := &metrics.Family{Name: "schnorr_vault_key_signatures_total", Help: "Signatures made with the key since the engine started.", Type: metrics.Counter}
+	today := &metrics.Family{Name: "schnorr_vault_key_signatures_today", Help: "Signatures made with the key today, counted against its daily limit.", Type: metrics.Gauge}
+	daily := &metrics.Family{Name: "schnorr_vault_key_daily_limit", Help: "Daily signature limit of the key.", Type: metrics.Gauge}
+	limited := &metrics.Family{Name: "schnorr_vault_limited_total", Help: "Signing requests refused by rate limits, by scope and name.", Type: metrics.Counter}
+	families := []*metrics.Family{keys, version, rotated, expires, signatures, today, daily, limited}
+
+	names, err := e.keyNames()
+	if err != nil {
+		return families
+	}
+
+	var stats map[ratelimit.Scope]map[string]ratelimit.Stats
+	if e.Limiter != nil {
+		stats = e.Limiter.Stats()
+	}
+	e.mu.Lock()
+	counts := map[string]uint64{}
+	for name, n := range e.signatures {
+		counts[name] = n
+	}
+	e.mu.Unlock()
+
+	keys.Add(float64(len(names)))
+	for _, name := range names {
+		key, err := e.load(name)
+		if err != nil || key == nil {
+			continue
+		}
+		version.Add(float64(key.LatestVersion), "key", name)
+		if created := key.created(key.LatestVersion); !created.IsZero() {
+			rotated.Add(float64(created.Unix()), "key", name)
+		}
+		if expiry := e.expiresAt(key, key.LatestVersion); !expiry.IsZero() {
+			expires.Add(float64(expiry.Unix()), "key", name)
+		}
+		signatures.Add(float64(counts[name]), "key", name)
+
+		if e.Limiter != nil {
+			today.Add(float64(stats[ratelimit.ScopeKey][name].Today), "key", name)
+			if limit := e.Limiter.LimitFor(ratelimit.ScopeKey, name); limit.Daily > 0 {
+				daily.Add(float64(limit.Daily), "key", name)
+			}
+		}
+	}
+
+	for _, scope := range []ratelimit.Scope{ratelimit.ScopeKey, ratelimit.ScopePrincipal} {
+		names := make([]string, 0, len(stats[scope]))
+		for name := range stats[scope] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			limited.Add(float64(stats[scope][name].Limited), "scope", string(scope), "name", name)
+		}
+	}
+	return families
+}
@@ -0,0 +1,103 @@
+package vault
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+//
+// Tenants host the keystores of several teams in one daemon. Each tenant is
+// an engine of its own, with its own storage, limits, templates, hooks and
+// audit log, and its own token: the token a request authenticates with is
+// what selects the tenant, so no request can name another tenant's keys.
+//
+
+// tenantName is what a tenant may be called, as it's used in storage
+// prefixes and audit log names
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidTenantName checks the name can be used for a tenant
+func ValidTenantName(name string) error {
+	if !tenantName.MatchString(name) {
+		return fmt.Errorf("tenant name %q must be up to 64 lowercase letters, digits, - and _", name)
+	}
+	return nil
+}
+
+// Tenants routes each request to the engine whose token it carries
+type Tenants struct {
+	engines []*Engine
+}
+
+// NewTenants serves the engines, which must all have a Tenant name and
+// tokens of their own
+func NewTenants(engines ...*Engine) (*Tenants, error) {
+	names, tokens := map[string]bool{}, map[string]bool{}
+	for _, e := range engines {
+		if err := ValidTenantName(e.Tenant); err != nil {
+			return nil, err
+		}
+		if e.Token == "" {
+			return nil, fmt.Errorf("tenant %s has no token", e.Tenant)
+		}
+		if names[e.Tenant] {
+			return nil, fmt.Errorf("tenant %s is given twice", e.Tenant)
+		}
+		if tokens[e.Token] {
+			return nil, fmt.Errorf("tenant %s has the token of another tenant", e.Tenant)
+		}
+		names[e.Tenant], tokens[e.Token] = true, true
+	}
+	return &Tenants{engines: engines}, nil
+}
+
+// Engines returns the engines by tenant, in the order given
+func (t *Tenants) Engines() []*Engine {
+	return t.engines
+}
+
+func (t *Tenants) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := []byte(r.Header.Get("X-Vault-Token"))
+	// every token is compared, so the time taken doesn't tell which tenant
+	// a guess came close to
+	var match *Engine
+	for _, e := range t.engines {
+		if subtle.ConstantTimeCompare(token, []byte(e.Token)) == 1 {
+			match = e
+		}
+	}
+	if match == nil {
+		writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+	match.ServeHTTP(w, r)
+}
+
+// PrefixStorage keeps the entries of one tenant apart from the others' in
+// shared storage, under Prefix
+type PrefixStorage struct {
+	Storage Storage
+	Prefix  string
+}
+
+func (s *PrefixStorage) Get(name string) ([]byte, error) {
+	return s.Storage.Get(s.Prefix + name)
+}
+
+func (s *PrefixStorage) Put(name string, value []byte) error {
+	return s.Storage.Put(s.Prefix+name, value)
+}
+
+func (s *PrefixStorage) List(prefix string) ([]string, error) {
+	names, err := s.Storage.List(s.Prefix + prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		names[i] = strings.TrimPrefix(name, s.Prefix)
+	}
+	return names, nil
+}
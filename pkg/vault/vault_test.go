@@ -0,0 +1,512 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/backend"
+	"github.com/ryohare/schnorr-go/pkg/metrics"
+	"github.com/ryohare/schnorr-go/pkg/msgtemplate"
+	"github.com/ryohare/schnorr-go/pkg/ratelimit"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/store"
+)
+
+var _ backend.Backend = (*Client)(nil)
+
+func TestClientEngine(t *testing.T) {
+	server := httptest.NewServer(NewEngine("schnorr", "root"))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL, "root", "")
+	message := sha256.Sum256([]byte("test"))
+
+	if err := client.CreateKey(ctx, "release"); err != nil {
+		t.Fatalf("Unexpected error from CreateKey: %v", err)
+	}
+
+	// when
+	sig, err := client.Sign(ctx, "release", message)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	// then
+	pk, err := client.PublicKey(ctx, "release")
+	if err != nil {
+		t.Fatalf("Unexpected error from PublicKey: %v", err)
+	}
+	if ok, err := schnorr.Verify(pk, message, sig); !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+
+	t.Run("Rotation keeps old versions usable", func(t *testing.T) {
+		if err := client.RotateKey(ctx, "release"); err != nil {
+			t.Fatalf("Unexpected error from RotateKey: %v", err)
+		}
+
+		newSig, version, err := client.SignVersion(ctx, "release", message, 0)
+		if err != nil || version != 2 {
+			t.Fatalf("SignVersion() version = %d, %v, want 2", version, err)
+		}
+		if newSig == sig {
+			t.Fatalf("rotated key produced the same signature")
+		}
+
+		oldSig, version, err := client.SignVersion(ctx, "release", message, 1)
+		if err != nil || version != 1 || oldSig != sig {
+			t.Fatalf("SignVersion(1) = %x, %d, %v, want the first signature", oldSig, version, err)
+		}
+	})
+
+	t.Run("Bad token is refused", func(t *testing.T) {
+		bad := NewClient(server.URL, "guess", "")
+		if _, err := bad.Sign(ctx, "release", message); !errors.Is(err, ErrPermissionDenied) {
+			t.Fatalf("Sign with a bad token = %v, want ErrPermissionDenied", err)
+		}
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		if _, err := client.Sign(ctx, "missing", message); !errors.Is(err, ErrKeyNotFound) {
+			t.Fatalf("Sign with a missing key = %v, want ErrKeyNotFound", err)
+		}
+	})
+}
+
+func TestEngineRateLimit(t *testing.T) {
+	engine := NewEngine("schnorr", "root")
+	engine.Limiter = ratelimit.New(ratelimit.Limit{Daily: 2}, ratelimit.Limit{})
+	var audit bytes.Buffer
+	engine.Audit = &audit
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL, "root", "")
+	message := sha256.Sum256([]byte("test"))
+	if err := client.CreateKey(ctx, "release"); err != nil {
+		t.Fatalf("Unexpected error from CreateKey: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Sign(ctx, "release", message); err != nil {
+			t.Fatalf("Unexpected error from Sign %d: %v", i, err)
+		}
+	}
+
+	// when
+	_, err := client.Sign(ctx, "release", message)
+
+	// then
+	if err == nil || !strings.Contains(err.Error(), "429") || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Sign past the quota = %v, want 429", err)
+	}
+
+	t.Run("Signatures and refusals are audited", func(t *testing.T) {
+		outcomes := map[string]int{}
+		for _, line := range strings.Split(strings.TrimSpace(audit.String()), "\n") {
+			var entry AuditEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("Unexpected error from json.Unmarshal(%s): %v", line, err)
+			}
+			if entry.Key != "release:v1" || entry.Client == "" {
+				t.Fatalf("audit entry = %+v, want key release:v1 and the client", entry)
+			}
+			outcomes[entry.Outcome]++
+		}
+		if outcomes[AuditSigned] != 2 || outcomes[AuditRefused] == 0 {
+			t.Fatalf("audit outcomes = %v, want 2 signed and the refusals", outcomes)
+		}
+	})
+}
+
+func TestEngineKeyHealth(t *testing.T) {
+	engine := NewEngine("schnorr", "root")
+	engine.Limiter = ratelimit.New(ratelimit.Limit{Daily: 10}, ratelimit.Limit{})
+	engine.MaxAge = 24 * time.Hour
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return created }
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL, "root", "")
+	message := sha256.Sum256([]byte("test"))
+	if err := client.CreateKey(ctx, "release"); err != nil {
+		t.Fatalf("Unexpected error from CreateKey: %v", err)
+	}
+	if _, err := client.Sign(ctx, "release", message); err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	t.Run("Metrics report age, usage and limits", func(t *testing.T) {
+		var b strings.Builder
+		if err := metrics.Write(&b, engine.Metrics()); err != nil {
+			t.Fatalf("Unexpected error from Write: %v", err)
+		}
+		for _, want := range []string{
+			"schnorr_vault_keys 1\n",
+			`schnorr_vault_key_rotated_timestamp_seconds{key="release"} 1.7092944e+09`,
+			`schnorr_vault_key_expiry_timestamp_seconds{key="release"} 1.7093808e+09`,
+			`schnorr_vault_key_signatures_total{key="release"} 1`,
+			`schnorr_vault_key_signatures_today{key="release"} 1`,
+			`schnorr_vault_key_daily_limit{key="release"} 10`,
+		} {
+			if !strings.Contains(b.String(), want) {
+				t.Fatalf("Metrics() = %s\nwant %s", b.String(), want)
+			}
+		}
+	})
+
+	t.Run("Expired keys are refused until rotated", func(t *testing.T) {
+		engine.now = func() time.Time { return created.Add(25 * time.Hour) }
+		if _, err := client.Sign(ctx, "release", message); err == nil || !strings.Contains(err.Error(), "expired") {
+			t.Fatalf("Sign with an expired key = %v, want expired", err)
+		}
+
+		if err := client.RotateKey(ctx, "release"); err != nil {
+			t.Fatalf("Unexpected error from RotateKey: %v", err)
+		}
+		if _, err := client.Sign(ctx, "release", message); err != nil {
+			t.Fatalf("Unexpected error from Sign after rotation: %v", err)
+		}
+	})
+}
+
+func TestEngineTemplates(t *testing.T) {
+	engine := NewEngine("schnorr", "root")
+	policy, err := msgtemplate.Parse([]byte(`{"templates": [{"name": "release", "keys": ["release"], "format": "json",
+		"fields": {"type": {"type": "string", "enum": ["release"]}}}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	engine.Templates = policy
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL, "root", "")
+	for _, name := range []string{"release", "ops"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Unexpected error from CreateKey: %v", err)
+		}
+	}
+
+	t.Run("Matching messages are signed", func(t *testing.T) {
+		message := []byte(`{"type": "release"}`)
+		sig, err := client.SignMessage(ctx, "release", message)
+		if err != nil {
+			t.Fatalf("Unexpected error from SignMessage: %v", err)
+		}
+		pk, _ := client.PublicKey(ctx, "release")
+		if ok, err := schnorr.Verify(pk, sha256.Sum256(message), sig); !ok {
+			t.Fatalf("Verify() = %v, %v, want true", ok, err)
+		}
+	})
+
+	t.Run("Other messages and bare digests are refused", func(t *testing.T) {
+		if _, err := client.SignMessage(ctx, "release", []byte(`{"type": "hotfix"}`)); err == nil || !strings.Contains(err.Error(), "matches no template") {
+			t.Fatalf("SignMessage with the wrong type = %v, want refused", err)
+		}
+		if _, err := client.Sign(ctx, "release", sha256.Sum256([]byte(`{"type": "release"}`))); err == nil || !strings.Contains(err.Error(), "send the message") {
+			t.Fatalf("Sign with a digest = %v, want refused", err)
+		}
+	})
+
+	t.Run("Unrestricted keys sign anything", func(t *testing.T) {
+		if _, err := client.Sign(ctx, "ops", sha256.Sum256([]byte("anything"))); err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+	})
+}
+
+// flaky fails the first failures requests to paths containing match with
+// status, counting every request it sees
+type flaky struct {
+	next     http.Handler
+	match    string
+	status   int
+	failures int
+	requests int
+}
+
+func (f *flaky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests++
+	if strings.Contains(r.URL.Path, f.match) && f.failures > 0 {
+		f.failures--
+		writeError(w, f.status, "try again")
+		return
+	}
+	f.next.ServeHTTP(w, r)
+}
+
+func TestClientRetry(t *testing.T) {
+	ctx := context.Background()
+	message := sha256.Sum256([]byte("test"))
+
+	setup := func(match string, status, failures int) (*flaky, *Client, func()) {
+		f := &flaky{next: NewEngine("schnorr", "root"), match: match, status: status}
+		server := httptest.NewServer(f)
+		client := NewClient(server.URL, "root", "")
+		client.Retry = RetryPolicy{Attempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+		if err := client.CreateKey(ctx, "release"); err != nil {
+			t.Fatalf("Unexpected error from CreateKey: %v", err)
+		}
+		f.failures, f.requests = failures, 0
+		return f, client, server.Close
+	}
+
+	t.Run("Signing is retried", func(t *testing.T) {
+		f, client, done := setup("sign", http.StatusBadGateway, 2)
+		defer done()
+
+		if _, err := client.Sign(ctx, "release", message); err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		// two failures, the signature, and the public keys once
+		if f.requests != 4 {
+			t.Fatalf("Sign made %d requests, want 4", f.requests)
+		}
+
+		f.requests = 0
+		if _, err := client.Sign(ctx, "release", message); err != nil || f.requests != 1 {
+			t.Fatalf("second Sign = %d requests, %v, want 1 with the public key remembered", f.requests, err)
+		}
+	})
+
+	t.Run("Attempts run out", func(t *testing.T) {
+		f, client, done := setup("sign", http.StatusServiceUnavailable, 5)
+		defer done()
+
+		_, err := client.Sign(ctx, "release", message)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || f.requests != 3 {
+			t.Fatalf("Sign = %v after %d requests, want a 503 after 3", err, f.requests)
+		}
+	})
+
+	t.Run("Rotation is only retried when it was not processed", func(t *testing.T) {
+		f, client, done := setup("rotate", http.StatusBadGateway, 1)
+		defer done()
+		if err := client.RotateKey(ctx, "release"); err == nil || f.requests != 1 {
+			t.Fatalf("RotateKey = %v after %d requests, want a 502 after 1", err, f.requests)
+		}
+
+		f.status, f.failures, f.requests = http.StatusServiceUnavailable, 1, 0
+		if err := client.RotateKey(ctx, "release"); err != nil || f.requests != 2 {
+			t.Fatalf("RotateKey = %v after %d requests, want success after 2", err, f.requests)
+		}
+	})
+}
+
+func TestStateStorage(t *testing.T) {
+	// given two engines sharing a state store
+	state := store.NewMemory()
+	first, second := NewEngine("schnorr", "root"), NewEngine("schnorr", "root")
+	first.Storage = &StateStorage{State: state}
+	second.Storage = &StateStorage{State: state}
+	firstServer, secondServer := httptest.NewServer(first), httptest.NewServer(second)
+	defer firstServer.Close()
+	defer secondServer.Close()
+
+	ctx := context.Background()
+	message := sha256.Sum256([]byte("test"))
+	if err := NewClient(firstServer.URL, "root", "").CreateKey(ctx, "release"); err != nil {
+		t.Fatalf("Unexpected error from CreateKey: %v", err)
+	}
+
+	// when
+	sig, err := NewClient(secondServer.URL, "root", "").Sign(ctx, "release", message)
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+
+	// then
+	pk, err := NewClient(firstServer.URL, "root", "").PublicKey(ctx, "release")
+	if err != nil {
+		t.Fatalf("Unexpected error from PublicKey: %v", err)
+	}
+	if ok, err := schnorr.Verify(pk, message, sig); !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+	if names, err := second.keyNames(); err != nil || len(names) != 1 || names[0] != "release" {
+		t.Fatalf("keyNames() = %q, %v, want [release]", names, err)
+	}
+}
+
+// lossy signs with the engine but answers the first request with a 502,
+// as if the response was lost on the way back
+type lossy struct {
+	next http.Handler
+	lost bool
+}
+
+func (l *lossy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.URL.Path, "sign") && !l.lost {
+		l.lost = true
+		l.next.ServeHTTP(httptest.NewRecorder(), r)
+		writeError(w, http.StatusBadGateway, "lost")
+		return
+	}
+	l.next.ServeHTTP(w, r)
+}
+
+func TestIdempotency(t *testing.T) {
+	engine := NewEngine("schnorr", "root")
+	server := httptest.NewServer(engine)
+	defer server.Close()
+	ctx := context.Background()
+	if err := NewClient(server.URL, "root", "").CreateKey(ctx, "release"); err != nil {
+		t.Fatalf("Unexpected error from CreateKey: %v", err)
+	}
+
+	sign := func(key, body string) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/schnorr/sign/release", strings.NewReader(body))
+		req.Header.Set("X-Vault-Token", "root")
+		if key != "" {
+			req.Header.Set(IdempotencyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error from Do: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+	body := `{"message":"aGVsbG8="}`
+
+	t.Run("Retry gets the first response", func(t *testing.T) {
+		first, firstBody := sign("k1", body)
+		second, secondBody := sign("k1", body)
+		if first.StatusCode != http.StatusOK || second.StatusCode != http.StatusOK || firstBody != secondBody {
+			t.Fatalf("responses = %d %s and %d %s, want the same signature twice", first.StatusCode, firstBody, second.StatusCode, secondBody)
+		}
+		if second.Header.Get(ReplayedHeader) != "true" || first.Header.Get(ReplayedHeader) != "" {
+			t.Fatalf("%s = %q then %q, want only the retry marked", ReplayedHeader, first.Header.Get(ReplayedHeader), second.Header.Get(ReplayedHeader))
+		}
+		if n := engine.signatures["release"]; n != 1 {
+			t.Fatalf("engine made %d signatures, want 1", n)
+		}
+	})
+
+	t.Run("Key reused for another request", func(t *testing.T) {
+		if resp, _ := sign("k1", `{"message":"b3RoZXI="}`); resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("status = %d, want 422", resp.StatusCode)
+		}
+	})
+
+	t.Run("Failures aren't kept", func(t *testing.T) {
+		if resp, _ := sign("k2", `{"input":"short"}`); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400", resp.StatusCode)
+		}
+		if resp, _ := sign("k2", body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("status after a failure = %d, want 200", resp.StatusCode)
+		}
+	})
+
+	t.Run("Client retries sign once", func(t *testing.T) {
+		engine := NewEngine("schnorr", "root")
+		server := httptest.NewServer(&lossy{next: engine})
+		defer server.Close()
+		client := NewClient(server.URL, "root", "")
+		client.Retry = RetryPolicy{Attempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+		if err := client.CreateKey(ctx, "release"); err != nil {
+			t.Fatalf("Unexpected error from CreateKey: %v", err)
+		}
+
+		if _, err := client.Sign(ctx, "release", sha256.Sum256([]byte("test"))); err != nil {
+			t.Fatalf("Unexpected error from Sign: %v", err)
+		}
+		if n := engine.signatures["release"]; n != 1 {
+			t.Fatalf("engine made %d signatures, want 1", n)
+		}
+	})
+}
+
+func TestTenants(t *testing.T) {
+	// given two tenants keeping their keys in the same state store
+	state := store.NewMemory()
+	payments, ledger := NewEngine("schnorr", "payments-token"), NewEngine("schnorr", "ledger-token")
+	payments.Tenant, ledger.Tenant = "payments", "ledger"
+	payments.Storage = &PrefixStorage{Storage: &StateStorage{State: state}, Prefix: "tenants/payments/"}
+	ledger.Storage = &PrefixStorage{Storage: &StateStorage{State: state}, Prefix: "tenants/ledger/"}
+	var audit bytes.Buffer
+	payments.Audit = &audit
+	tenants, err := NewTenants(payments, ledger)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewTenants: %v", err)
+	}
+	server := httptest.NewServer(tenants)
+	defer server.Close()
+
+	ctx := context.Background()
+	message := sha256.Sum256([]byte("test"))
+	client := NewClient(server.URL, "payments-token", "")
+	if err := client.CreateKey(ctx, "release"); err != nil {
+		t.Fatalf("Unexpected error from CreateKey: %v", err)
+	}
+
+	// when
+	sig, err := client.Sign(ctx, "release", message)
+
+	// then
+	if err != nil {
+		t.Fatalf("Unexpected error from Sign: %v", err)
+	}
+	pk, err := client.PublicKey(ctx, "release")
+	if err != nil {
+		t.Fatalf("Unexpected error from PublicKey: %v", err)
+	}
+	if ok, err := schnorr.Verify(pk, message, sig); !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+	if !strings.Contains(audit.String(), `"tenant":"payments"`) {
+		t.Fatalf("audit = %s, want entries of tenant payments", audit.String())
+	}
+
+	t.Run("Another tenant can't use the key", func(t *testing.T) {
+		other := NewClient(server.URL, "ledger-token", "")
+		if _, err := other.Sign(ctx, "release", message); !errors.Is(err, ErrKeyNotFound) {
+			t.Fatalf("Sign by another tenant = %v, want ErrKeyNotFound", err)
+		}
+		if err := other.CreateKey(ctx, "release"); err != nil {
+			t.Fatalf("Unexpected error from CreateKey: %v", err)
+		}
+		if otherPk, err := other.PublicKey(ctx, "release"); err != nil || otherPk == pk {
+			t.Fatalf("PublicKey() of the other tenant's key = %x, %v, want a key of its own", otherPk, err)
+		}
+		if names, err := payments.keyNames(); err != nil || len(names) != 1 || names[0] != "release" {
+			t.Fatalf("keyNames() = %q, %v, want [release]", names, err)
+		}
+	})
+
+	t.Run("Unknown tokens are refused", func(t *testing.T) {
+		bad := NewClient(server.URL, "guess", "")
+		if _, err := bad.Sign(ctx, "release", message); !errors.Is(err, ErrPermissionDenied) {
+			t.Fatalf("Sign with a bad token = %v, want ErrPermissionDenied", err)
+		}
+	})
+
+	t.Run("Tenants need tokens of their own", func(t *testing.T) {
+		shared := NewEngine("schnorr", "payments-token")
+		shared.Tenant = "shared"
+		if _, err := NewTenants(payments, shared); err == nil {
+			t.Fatalf("NewTenants() with a shared token succeeded, want an error")
+		}
+		unnamed := NewEngine("schnorr", "other-token")
+		if _, err := NewTenants(payments, unnamed); err == nil {
+			t.Fatalf("NewTenants() without a tenant name succeeded, want an error")
+		}
+	})
+}
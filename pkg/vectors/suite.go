@@ -0,0 +1,329 @@
+package vectors
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	bip340 "github.com/btcsuite/btcd/btcec/v2/schnorr"
+	dcrschnorr "github.com/decred/dcrd/dcrec/secp256k1/v4/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/drbg"
+	"github.com/ryohare/schnorr-go/pkg/p256"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+// SuiteVersion is bumped whenever the derivation of the suite changes, so a
+// fixture can be matched to the code that made it
+const SuiteVersion = 1
+
+// suitePersonalization keeps the suite inputs apart from Generate's
+var suitePersonalization = []byte("schnorr-go/vectors/suite/v1")
+
+// Suite is a set of fixtures covering every signature scheme the package
+// implements, positive and negative, for testing other implementations
+// against
+type Suite struct {
+	Version int      `json:"version"`
+	Seed    string   `json:"seed"`
+	Schemes []Scheme `json:"schemes"`
+}
+
+// Scheme is the fixtures for one signature scheme
+type Scheme struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Cases       []Case `json:"cases"`
+}
+
+// Case is one fixture. SecretKey and AuxRand are empty for cases made by
+// altering another case, and for schemes without aux randomness. Valid is
+// the outcome verification must have.
+type Case struct {
+	Index     int    `json:"index"`
+	Comment   string `json:"comment"`
+	SecretKey string `json:"secret_key,omitempty"`
+	PublicKey string `json:"public_key"`
+	Message   string `json:"message"`
+	AuxRand   string `json:"aux_rand,omitempty"`
+	Signature string `json:"signature"`
+	Valid     bool   `json:"valid"`
+}
+
+// scheme is how the suite signs and verifies with one scheme. Sign gets the
+// aux randomness, which schemes with deterministic nonces ignore.
+type scheme struct {
+	description string
+	aux         bool
+	n           *big.Int
+	publicKey   func(d *big.Int) ([]byte, error)
+	sign        func(d *big.Int, message, aux [32]byte) ([]byte, error)
+	verify      func(publickey, message, signature []byte) bool
+}
+
+var schemes = map[string]scheme{
+	"legacy": {
+		description: "pkg/schnorr Sign and Verify: e = sha256(r || compressed P || m), R with a quadratic residue y, deterministic nonce",
+		n:           schnorr.Curve.N,
+		publicKey:   compressedSecp256k1,
+		sign: func(d *big.Int, message, aux [32]byte) ([]byte, error) {
+			sig, err := schnorr.Sign(d, message)
+			return sig[:], err
+		},
+		verify: func(publickey, message, signature []byte) bool {
+			var pk [33]byte
+			var m [32]byte
+			var sig [64]byte
+			if len(publickey) != len(pk) || len(signature) != len(sig) {
+				return false
+			}
+			copy(pk[:], publickey)
+			copy(m[:], message)
+			copy(sig[:], signature)
+			valid, err := schnorr.Verify(pk, m, sig)
+			return valid && err == nil
+		},
+	},
+	"bip340": {
+		description: "BIP-340 with x-only public keys and aux_rand as the auxiliary randomness",
+		aux:         true,
+		n:           schnorr.Curve.N,
+		publicKey: func(d *big.Int) ([]byte, error) {
+			_, pubKey := btcec.PrivKeyFromBytes(schnorr.GetBigIntBytesImmutable(d))
+			return bip340.SerializePubKey(pubKey), nil
+		},
+		sign: func(d *big.Int, message, aux [32]byte) ([]byte, error) {
+			privKey, _ := btcec.PrivKeyFromBytes(schnorr.GetBigIntBytesImmutable(d))
+			sig, err := bip340.Sign(privKey, message[:], bip340.CustomNonce(aux))
+			if err != nil {
+				return nil, err
+			}
+			return sig.Serialize(), nil
+		},
+		verify: func(publickey, message, signature []byte) bool {
+			pubKey, err := bip340.ParsePubKey(publickey)
+			if err != nil {
+				return false
+			}
+			sig, err := bip340.ParseSignature(signature)
+			if err != nil {
+				return false
+			}
+			return sig.Verify(message, pubKey)
+		},
+	},
+	"ec-schnorr-dcrv0": {
+		description: "Decred's EC-Schnorr-DCRv0 as the top level -sign and -verify use it, with the message already hashed",
+		n:           schnorr.Curve.N,
+		publicKey:   compressedSecp256k1,
+		sign: func(d *big.Int, message, aux [32]byte) ([]byte, error) {
+			privKey, _ := btcec.PrivKeyFromBytes(schnorr.GetBigIntBytesImmutable(d))
+			sig, err := dcrschnorr.Sign(privKey, message[:])
+			if err != nil {
+				return nil, err
+			}
+			return sig.Serialize(), nil
+		},
+		verify: func(publickey, message, signature []byte) bool {
+			pubKey, err := btcec.ParsePubKey(publickey)
+			if err != nil {
+				return false
+			}
+			sig, err := dcrschnorr.ParseSignature(signature)
+			if err != nil {
+				return false
+			}
+			return sig.Verify(message, pubKey)
+		},
+	},
+	"p256": {
+		description: "pkg/p256 EC-SDSA over NIST P-256: r = sha256(Qx || Qy || m), deterministic nonce",
+		n:           p256.Curve.Params().N,
+		publicKey: func(d *big.Int) ([]byte, error) {
+			pk, err := p256.PublicKey(d)
+			return pk[:], err
+		},
+		sign: func(d *big.Int, message, aux [32]byte) ([]byte, error) {
+			sig, err := p256.Sign(d, message)
+			return sig[:], err
+		},
+		verify: func(publickey, message, signature []byte) bool {
+			var pk [33]byte
+			var m [32]byte
+			var sig [64]byte
+			if len(publickey) != len(pk) || len(signature) != len(sig) {
+				return false
+			}
+			copy(pk[:], publickey)
+			copy(m[:], message)
+			copy(sig[:], signature)
+			valid, err := p256.Verify(pk, m, sig)
+			return valid && err == nil
+		},
+	},
+}
+
+func compressedSecp256k1(d *big.Int) ([]byte, error) {
+	_, pubKey := btcec.PrivKeyFromBytes(schnorr.GetBigIntBytesImmutable(d))
+	return pubKey.SerializeCompressed(), nil
+}
+
+// SchemeNames lists the schemes GenerateSuite knows
+func SchemeNames() []string {
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenerateSuite derives n signing cases per scheme from the seed, each
+// followed by the negative cases made from it, for the named schemes or
+// all of them when names is empty. The inputs are drawn from HMAC_DRBG like
+// Generate's but with the scheme name in the personalization, so adding a
+// scheme never changes the fixtures of another.
+func GenerateSuite(seed []byte, n int, names []string) (*Suite, error) {
+	if len(seed) == 0 {
+		return nil, fmt.Errorf("seed must not be empty")
+	}
+	if len(names) == 0 {
+		names = SchemeNames()
+	}
+
+	suite := &Suite{Version: SuiteVersion, Seed: hex.EncodeToString(seed)}
+	for _, name := range names {
+		s, ok := schemes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown scheme %q, want one of %v", name, SchemeNames())
+		}
+		cases, err := s.generate(drbg.New(seed, append(append([]byte{}, suitePersonalization...), name...)), n)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		suite.Schemes = append(suite.Schemes, Scheme{Name: name, Description: s.description, Cases: cases})
+	}
+	return suite, nil
+}
+
+func (s scheme) generate(rng *drbg.DRBG, n int) ([]Case, error) {
+	cases := []Case{}
+	add := func(c Case) {
+		c.Index = len(cases)
+		c.Valid = s.verify(mustHex(c.PublicKey), mustHex(c.Message), mustHex(c.Signature))
+		cases = append(cases, c)
+	}
+
+	for i := 0; i < n; i++ {
+		d, err := rng.Scalar(s.n)
+		if err != nil {
+			return nil, err
+		}
+		var message, aux [32]byte
+		if _, err := rng.Read(message[:]); err != nil {
+			return nil, err
+		}
+		if _, err := rng.Read(aux[:]); err != nil {
+			return nil, err
+		}
+
+		publickey, err := s.publicKey(d)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := s.sign(d, message, aux)
+		if err != nil {
+			return nil, err
+		}
+
+		good := Case{
+			Comment:   "signed",
+			SecretKey: hex.EncodeToString(schnorr.GetBigIntBytesImmutable(d)),
+			PublicKey: hex.EncodeToString(publickey),
+			Message:   hex.EncodeToString(message[:]),
+			Signature: hex.EncodeToString(signature),
+		}
+		if s.aux {
+			good.AuxRand = hex.EncodeToString(aux[:])
+		}
+		add(good)
+		if !cases[len(cases)-1].Valid {
+			return nil, fmt.Errorf("case %d does not verify", i)
+		}
+
+		// the negative cases are derived from the good one by hand, so
+		// they do not depend on the DRBG
+		alter := func(comment string, f func(c *Case)) {
+			bad := Case{Comment: comment, PublicKey: good.PublicKey, Message: good.Message, Signature: good.Signature}
+			f(&bad)
+			add(bad)
+		}
+		alter("message with the last bit flipped", func(c *Case) {
+			c.Message = flipLastBit(c.Message)
+		})
+		alter("signature with the last bit of s flipped", func(c *Case) {
+			c.Signature = flipLastBit(c.Signature)
+		})
+		alter("signature with the last bit of r flipped", func(c *Case) {
+			sig := mustHex(c.Signature)
+			sig[len(sig)/2-1] ^= 1
+			c.Signature = hex.EncodeToString(sig)
+		})
+		alter("s set to the group order", func(c *Case) {
+			sig := mustHex(c.Signature)
+			copy(sig[len(sig)/2:], schnorr.GetBigIntBytesImmutable(s.n))
+			c.Signature = hex.EncodeToString(sig)
+		})
+		alter("s set to zero", func(c *Case) {
+			sig := mustHex(c.Signature)
+			copy(sig[len(sig)/2:], make([]byte, len(sig)/2))
+			c.Signature = hex.EncodeToString(sig)
+		})
+		alter("public key of a different secret key", func(c *Case) {
+			other, _ := s.publicKey(new(big.Int).Add(d, big.NewInt(1)))
+			c.PublicKey = hex.EncodeToString(other)
+		})
+		alter("public key x coordinate not on the curve", func(c *Case) {
+			c.PublicKey = notOnCurve(c.PublicKey)
+		})
+	}
+
+	return cases, nil
+}
+
+// notOnCurve replaces the x coordinate of the key with the first value
+// after it that is not on either curve
+func notOnCurve(publickey string) string {
+	pk := mustHex(publickey)
+	x := pk[len(pk)-32:]
+	for {
+		for i := len(x) - 1; i >= 0; i-- {
+			x[i]++
+			if x[i] != 0 {
+				break
+			}
+		}
+		compressed := append([]byte{2}, x...)
+		if _, err := btcec.ParsePubKey(compressed); err != nil {
+			if px, _ := elliptic.UnmarshalCompressed(p256.Curve, compressed); px == nil {
+				return hex.EncodeToString(pk)
+			}
+		}
+	}
+}
+
+func flipLastBit(h string) string {
+	b := mustHex(h)
+	b[len(b)-1] ^= 1
+	return hex.EncodeToString(b)
+}
+
+func mustHex(h string) []byte {
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
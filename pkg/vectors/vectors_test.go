@@ -0,0 +1,75 @@
+package vectors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	a, err := Generate([]byte("fixtures"), 4)
+	if err != nil {
+		t.Fatalf("Unexpected error from Generate: %v", err)
+	}
+	b, _ := Generate([]byte("fixtures"), 4)
+	c, _ := Generate([]byte("other"), 4)
+
+	// then
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("Generate with the same seed is not reproducible")
+	}
+	if reflect.DeepEqual(a, c) {
+		t.Fatalf("Generate with different seeds gave the same vectors")
+	}
+
+	for _, v := range a {
+		if !v.LegacyValid || !v.BIP340Valid {
+			t.Fatalf("vector %d did not verify: %+v", v.Index, v)
+		}
+	}
+
+	if _, err := Generate(nil, 1); err == nil {
+		t.Fatalf("Generate with an empty seed succeeded, want error")
+	}
+}
+
+func TestGenerateSuite(t *testing.T) {
+	a, err := GenerateSuite([]byte("fixtures"), 3, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateSuite: %v", err)
+	}
+	b, _ := GenerateSuite([]byte("fixtures"), 3, nil)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("GenerateSuite with the same seed is not reproducible")
+	}
+
+	if len(a.Schemes) != len(SchemeNames()) {
+		t.Fatalf("suite has %d schemes, want %d", len(a.Schemes), len(SchemeNames()))
+	}
+	for _, s := range a.Schemes {
+		valid := 0
+		for _, c := range s.Cases {
+			if c.Valid {
+				valid++
+				if c.SecretKey == "" {
+					t.Fatalf("%s case %d is valid but altered: %+v", s.Name, c.Index, c)
+				}
+			}
+		}
+		if valid != 3 || len(s.Cases) <= 3 {
+			t.Fatalf("%s has %d valid cases of %d, want 3 and some negative ones", s.Name, valid, len(s.Cases))
+		}
+
+		// a scheme's fixtures don't depend on which other schemes are asked for
+		alone, err := GenerateSuite([]byte("fixtures"), 3, []string{s.Name})
+		if err != nil {
+			t.Fatalf("Unexpected error from GenerateSuite: %v", err)
+		}
+		if !reflect.DeepEqual(alone.Schemes[0], s) {
+			t.Fatalf("%s fixtures changed when generated alone", s.Name)
+		}
+	}
+
+	if _, err := GenerateSuite([]byte("fixtures"), 1, []string{"rsa"}); err == nil {
+		t.Fatalf("GenerateSuite with an unknown scheme succeeded, want error")
+	}
+}
@@ -0,0 +1,266 @@
+package watch
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/crypto/blake256"
+	"github.com/ryohare/schnorr-go/pkg/dirverify"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/sigfile"
+)
+
+//
+// Signing whatever lands in a drop directory, such as build output copied
+// in by a CI job with no signing plugin. The directory is polled, every new
+// or changed file the policy allows gets a sidecar signature, and every
+// decision is appended to an audit log as a json line. Whether a file needs
+// signing is decided from the files alone, a sidecar older than its file,
+// so restarts neither miss nor repeat anything.
+//
+
+// DefaultPollInterval is how often the directory is scanned
+const DefaultPollInterval = time.Second
+
+// DefaultSettleTime is how long a file must go unmodified before it is
+// signed, so files still being copied in are left alone
+const DefaultSettleTime = 2 * time.Second
+
+// Policy decides which files get signed
+type Policy struct {
+	// Include are globs matched against the file name, all files if empty
+	Include []string
+
+	// Exclude are globs matched against the file name, checked after
+	// Include
+	Exclude []string
+
+	// MaxSize is the largest file signed, no limit if 0
+	MaxSize int64
+
+	SettleTime time.Duration
+}
+
+// allow returns why the file is not signed, empty if it is
+func (p Policy) allow(name string, size int64) (string, error) {
+	if len(p.Include) > 0 {
+		matched := false
+		for _, pattern := range p.Include {
+			ok, err := filepath.Match(pattern, name)
+			if err != nil {
+				return "", err
+			}
+			matched = matched || ok
+		}
+		if !matched {
+			return "not included", nil
+		}
+	}
+	for _, pattern := range p.Exclude {
+		ok, err := filepath.Match(pattern, name)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return "excluded by " + pattern, nil
+		}
+	}
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return fmt.Sprintf("larger than %d bytes", p.MaxSize), nil
+	}
+	return "", nil
+}
+
+// Audit outcomes
+const (
+	Signed  = "signed"
+	Skipped = "skipped"
+	Failed  = "failed"
+)
+
+// AuditEntry records one decision about one file
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Path    string    `json:"path"`
+	Outcome string    `json:"outcome"`
+	Reason  string    `json:"reason,omitempty"`
+	Size    int64     `json:"size"`
+	// BLAKE256 is the digest of the file the signature is over
+	BLAKE256  string `json:"blake256,omitempty"`
+	PublicKey string `json:"publicKey,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Watcher signs the files in Dir with the private key
+type Watcher struct {
+	Dir          string
+	Policy       Policy
+	PollInterval time.Duration
+
+	// Audit gets one json line per entry
+	Audit io.Writer
+
+	// Notify is called with every entry, if set
+	Notify func(AuditEntry)
+
+	privatekey *big.Int
+	publickey  string
+
+	mu sync.Mutex
+	// skipped remembers files the policy turned down, so they are audited
+	// once per version rather than every scan
+	skipped map[string]time.Time
+}
+
+func NewWatcher(dir string, privatekey *big.Int, policy Policy, audit io.Writer) (*Watcher, error) {
+	publickey, err := schnorr.ScalarBaseMult(privatekey).PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if policy.SettleTime == 0 {
+		policy.SettleTime = DefaultSettleTime
+	}
+	if _, err := policy.allow("", 0); err != nil {
+		return nil, fmt.Errorf("invalid policy pattern: %v", err)
+	}
+	return &Watcher{
+		Dir:          dir,
+		Policy:       policy,
+		PollInterval: DefaultPollInterval,
+		Audit:        audit,
+		privatekey:   privatekey,
+		publickey:    hex.EncodeToString(publickey[:]),
+		skipped:      map[string]time.Time{},
+	}, nil
+}
+
+// Run scans the directory until the context is done
+func (w *Watcher) Run(ctx context.Context) error {
+	for {
+		if err := w.Scan(time.Now()); err != nil {
+			return err
+		}
+
+		select {
+		case <-time.After(w.PollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Scan makes one pass over the directory, signing what is due as of now.
+// Hidden files and sidecars are never signed.
+func (w *Watcher) Scan(now time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return filepath.WalkDir(w.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if p != w.Dir && strings.HasPrefix(name, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(name, dirverify.SidecarExt) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if now.Sub(info.ModTime()) < w.Policy.SettleTime {
+			return nil
+		}
+		if sidecar, err := os.Stat(p + dirverify.SidecarExt); err == nil && !sidecar.ModTime().Before(info.ModTime()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(w.Dir, p)
+		if err != nil {
+			return err
+		}
+		entry := AuditEntry{Time: now.UTC(), Path: filepath.ToSlash(rel), Size: info.Size()}
+
+		reason, err := w.Policy.allow(name, info.Size())
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			if seen, ok := w.skipped[p]; ok && seen.Equal(info.ModTime()) {
+				return nil
+			}
+			w.skipped[p] = info.ModTime()
+			entry.Outcome, entry.Reason = Skipped, reason
+			return w.record(entry)
+		}
+		delete(w.skipped, p)
+
+		if err := w.sign(p, &entry); err != nil {
+			entry.Outcome, entry.Reason = Failed, err.Error()
+		}
+		return w.record(entry)
+	})
+}
+
+func (w *Watcher) sign(p string, entry *AuditEntry) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := blake256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	var digest [32]byte
+	copy(digest[:], h.Sum(nil))
+
+	sig, err := schnorr.SignScheme(sigfile.Scheme, w.privatekey, digest)
+	if err != nil {
+		return err
+	}
+	if err := dirverify.WriteSidecar(p, sig); err != nil {
+		return err
+	}
+
+	entry.Outcome = Signed
+	entry.BLAKE256 = hex.EncodeToString(digest[:])
+	entry.PublicKey = w.publickey
+	entry.Signature = hex.EncodeToString(sig[:])
+	return nil
+}
+
+func (w *Watcher) record(entry AuditEntry) error {
+	if w.Notify != nil {
+		w.Notify(entry)
+	}
+	if w.Audit == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Audit.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing audit log: %v", err)
+	}
+	return nil
+}
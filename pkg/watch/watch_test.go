@@ -0,0 +1,99 @@
+package watch
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/dirverify"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func entries(t *testing.T, audit *bytes.Buffer) []AuditEntry {
+	list := []AuditEntry{}
+	for _, line := range strings.Split(strings.TrimSpace(audit.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		e := AuditEntry{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Unexpected error from json.Unmarshal(%s): %v", line, err)
+		}
+		list = append(list, e)
+	}
+	audit.Reset()
+	return list
+}
+
+func TestScan(t *testing.T) {
+	// given
+	keys, err := schnorr.GenerateTestKeys([]byte("watch"), 1)
+	if err != nil {
+		t.Fatalf("Unexpected error from GenerateTestKeys: %v", err)
+	}
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "linux"), 0755)
+	os.WriteFile(filepath.Join(dir, "linux", "app.tar.gz"), []byte("build"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0644)
+	os.WriteFile(filepath.Join(dir, ".partial"), []byte("copying"), 0644)
+
+	audit := new(bytes.Buffer)
+	w, err := NewWatcher(dir, keys[0].PrivateKey, Policy{Include: []string{"*.tar.gz"}}, audit)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewWatcher: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+
+	// when
+	if err := w.Scan(later); err != nil {
+		t.Fatalf("Unexpected error from Scan: %v", err)
+	}
+
+	// then
+	got := entries(t, audit)
+	if len(got) != 2 || got[0].Path != "linux/app.tar.gz" || got[0].Outcome != Signed || got[1].Outcome != Skipped {
+		t.Fatalf("audit = %+v, want app.tar.gz signed and notes.txt skipped", got)
+	}
+	results, err := dirverify.VerifyTree(filepath.Join(dir, "linux"), keys[0].PublicKey)
+	if err != nil || !dirverify.OK(results) {
+		t.Fatalf("VerifyTree() = %v, %v, want the build signed", results, err)
+	}
+
+	t.Run("Nothing is redone", func(t *testing.T) {
+		if err := w.Scan(later); err != nil {
+			t.Fatalf("Unexpected error from Scan: %v", err)
+		}
+		if got := entries(t, audit); len(got) != 0 {
+			t.Fatalf("audit on a second scan = %+v, want nothing", got)
+		}
+	})
+
+	t.Run("Changed files are signed again", func(t *testing.T) {
+		p := filepath.Join(dir, "linux", "app.tar.gz")
+		os.WriteFile(p, []byte("build 2"), 0644)
+		future := later.Add(time.Second)
+		os.Chtimes(p, future, future)
+
+		if err := w.Scan(future.Add(time.Minute)); err != nil {
+			t.Fatalf("Unexpected error from Scan: %v", err)
+		}
+		got := entries(t, audit)
+		if len(got) != 1 || got[0].Outcome != Signed {
+			t.Fatalf("audit after a change = %+v, want the file signed again", got)
+		}
+	})
+
+	t.Run("Files still being written wait", func(t *testing.T) {
+		os.WriteFile(filepath.Join(dir, "fresh.tar.gz"), []byte("new"), 0644)
+		if err := w.Scan(time.Now()); err != nil {
+			t.Fatalf("Unexpected error from Scan: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "fresh.tar.gz.sig")); !os.IsNotExist(err) {
+			t.Fatalf("fresh file was signed before it settled")
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+//
+// Signed webhooks: the sender signs the request body along with the time it
+// was sent, and puts both in one header,
+//
+//	Schnorr-Signature: t=1700000000,keyid=deploy-bot,sig=<hex>
+//
+// The signature is over sha256 of the decimal time, a dot and the body, in
+// whichever scheme the two sides agree on. It's simpler to add to a sender
+// than the HTTP message signatures of pkg/httpsig, at the price of covering
+// only the body, not the method or path. A signed request can be replayed
+// until its time is out of tolerance; receivers that must act once dedupe
+// on the signature.
+//
+
+// DefaultHeader is the header the signature is sent in
+const DefaultHeader = "Schnorr-Signature"
+
+// DefaultTolerance is how far the signed time may be from the receiver's
+const DefaultTolerance = 5 * time.Minute
+
+// DefaultMaxBody is the largest body read, 1 MiB
+const DefaultMaxBody = 1 << 20
+
+// KeyResolver finds the public key, compressed or x-only, for the keyid of
+// a signature
+type KeyResolver interface {
+	ResolveKey(keyID string) ([]byte, error)
+}
+
+// KeyResolverFunc adapts a function to a KeyResolver
+type KeyResolverFunc func(keyID string) ([]byte, error)
+
+func (f KeyResolverFunc) ResolveKey(keyID string) ([]byte, error) {
+	return f(keyID)
+}
+
+// Keys is a fixed set of public keys by key id
+type Keys map[string][]byte
+
+func (k Keys) ResolveKey(keyID string) ([]byte, error) {
+	publickey, ok := k[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", keyID)
+	}
+	return publickey, nil
+}
+
+// Digest is what's signed: sha256 of the time, a dot and the body
+func Digest(t time.Time, body []byte) [32]byte {
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatInt(t.Unix(), 10) + "."))
+	h.Write(body)
+	var digest [32]byte
+	copy(digest[:], h.Sum(nil))
+	return digest
+}
+
+// Signer signs request bodies for a receiver using Verifier
+type Signer struct {
+	PrivateKey *big.Int
+	KeyID      string
+	Scheme     schnorr.Scheme
+	Header     string
+	Now        func() time.Time
+}
+
+// NewSigner signs legacy scheme signatures under the key id
+func NewSigner(privatekey *big.Int, keyID string) *Signer {
+	return &Signer{PrivateKey: privatekey, KeyID: keyID, Scheme: schnorr.SchemeLegacy, Header: DefaultHeader, Now: time.Now}
+}
+
+// HeaderValue returns the header signing the body now
+func (s *Signer) HeaderValue(body []byte) (string, error) {
+	t := time.Now()
+	if s.Now != nil {
+		t = s.Now()
+	}
+	signature, err := schnorr.SignScheme(s.Scheme, s.PrivateKey, Digest(t, body))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("t=%d,keyid=%s,sig=%x", t.Unix(), s.KeyID, signature), nil
+}
+
+// SignRequest reads the body of the request, which it leaves in place, and
+// adds the header signing it
+func (s *Signer) SignRequest(r *http.Request) error {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	value, err := s.HeaderValue(body)
+	if err != nil {
+		return err
+	}
+	r.Header.Set(s.header(), value)
+	return nil
+}
+
+func (s *Signer) header() string {
+	if s.Header == "" {
+		return DefaultHeader
+	}
+	return s.Header
+}
+
+// Verifier checks the signatures on requests. Signatures are only accepted
+// in Scheme, and only with a time within Tolerance of now, DefaultTolerance
+// if zero.
+type Verifier struct {
+	Keys      KeyResolver
+	Scheme    schnorr.Scheme
+	Header    string
+	Tolerance time.Duration
+	MaxBody   int64
+	Now       func() time.Time
+}
+
+// NewVerifier resolves keys with the resolver and accepts legacy scheme
+// signatures up to DefaultTolerance old
+func NewVerifier(keys KeyResolver) *Verifier {
+	return &Verifier{Keys: keys, Scheme: schnorr.SchemeLegacy, Header: DefaultHeader, Tolerance: DefaultTolerance, MaxBody: DefaultMaxBody, Now: time.Now}
+}
+
+// Result is a verified signature
+type Result struct {
+	KeyID     string
+	PublicKey []byte
+	Signed    time.Time
+}
+
+// Verify checks the header signs the body
+func (v *Verifier) Verify(header string, body []byte) (*Result, error) {
+	if header == "" {
+		return nil, fmt.Errorf("request is not signed")
+	}
+	var ts, keyID, sig string
+	for _, part := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("signature header part %q is not name=value", part)
+		}
+		switch name {
+		case "t":
+			ts = value
+		case "keyid":
+			keyID = value
+		case "sig":
+			sig = value
+		}
+	}
+	if ts == "" || keyID == "" || sig == "" {
+		return nil, fmt.Errorf("signature header needs t, keyid and sig")
+	}
+
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("signature time %q is not a unix time", ts)
+	}
+	signed := time.Unix(seconds, 0)
+	now, tolerance := time.Now(), v.Tolerance
+	if v.Now != nil {
+		now = v.Now()
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if skew := now.Sub(signed); skew > tolerance || skew < -tolerance {
+		return nil, fmt.Errorf("signature time %s is more than %s from now", signed.UTC().Format(time.RFC3339), tolerance)
+	}
+
+	raw, err := hex.DecodeString(sig)
+	if err != nil || len(raw) != 64 {
+		return nil, fmt.Errorf("signature is not 64 bytes of hex")
+	}
+	var signature [64]byte
+	copy(signature[:], raw)
+
+	publickey, err := v.Keys.ResolveKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := schnorr.VerifyScheme(v.Scheme, publickey, Digest(signed, body), signature); !ok {
+		if err != nil {
+			return nil, fmt.Errorf("signature does not verify: %v", err)
+		}
+		return nil, fmt.Errorf("signature does not verify")
+	}
+	return &Result{KeyID: keyID, PublicKey: publickey, Signed: signed}, nil
+}
+
+type contextKey struct{}
+
+// FromContext returns the verified signature of the request being handled
+func FromContext(ctx context.Context) (*Result, bool) {
+	result, ok := ctx.Value(contextKey{}).(*Result)
+	return result, ok
+}
+
+// Middleware only passes on requests whose body the header signs, answering
+// the rest with 401, or 413 for bodies over MaxBody. Handlers read the body
+// as usual, and get the signature with FromContext, for example to
+// authorize the key id.
+func Middleware(v *Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxBody := v.MaxBody
+		if maxBody <= 0 {
+			maxBody = DefaultMaxBody
+		}
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > maxBody {
+				http.Error(w, fmt.Sprintf("body is over %d bytes", maxBody), http.StatusRequestEntityTooLarge)
+				return
+			}
+		}
+
+		header := v.Header
+		if header == "" {
+			header = DefaultHeader
+		}
+		result, err := v.Verify(r.Header.Get(header), body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, result)))
+	})
+}
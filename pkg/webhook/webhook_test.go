@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+func TestMiddleware(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("webhook"), 2)
+	now := time.Unix(1700000000, 0)
+
+	for _, scheme := range schnorr.Schemes {
+		t.Run(string(scheme), func(t *testing.T) {
+			// given
+			signer := NewSigner(keys[0].PrivateKey, "deploy-bot")
+			signer.Scheme = scheme
+			signer.Now = func() time.Time { return now }
+			verifier := NewVerifier(Keys{"deploy-bot": keys[0].PublicKey[:], "other": keys[1].PublicKey[:]})
+			verifier.Scheme = scheme
+			verifier.Now = func() time.Time { return now.Add(time.Minute) }
+
+			handler := Middleware(verifier, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				result, _ := FromContext(r.Context())
+				body, _ := io.ReadAll(r.Body)
+				io.WriteString(w, result.KeyID+" "+string(body))
+			}))
+			send := func(body string, alter func(r *http.Request)) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(body))
+				if err := signer.SignRequest(r); err != nil {
+					t.Fatalf("Unexpected error from SignRequest: %v", err)
+				}
+				if alter != nil {
+					alter(r)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				return w
+			}
+
+			// when
+			w := send(`{"event":"deploy"}`, nil)
+
+			// then
+			if w.Code != http.StatusOK || w.Body.String() != `deploy-bot {"event":"deploy"}` {
+				t.Fatalf("signed request = %d %q, want 200 with the body", w.Code, w.Body.String())
+			}
+
+			tests := []struct {
+				name  string
+				alter func(r *http.Request)
+			}{
+				{"Unsigned", func(r *http.Request) { r.Header.Del(DefaultHeader) }},
+				{"Altered body", func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader(`{"event":"delete"}`)) }},
+				{"Other key id", func(r *http.Request) {
+					r.Header.Set(DefaultHeader, strings.Replace(r.Header.Get(DefaultHeader), "deploy-bot", "other", 1))
+				}},
+				{"Unknown key id", func(r *http.Request) {
+					r.Header.Set(DefaultHeader, strings.Replace(r.Header.Get(DefaultHeader), "deploy-bot", "nobody", 1))
+				}},
+			}
+			for _, test := range tests {
+				if w := send(`{"event":"deploy"}`, test.alter); w.Code != http.StatusUnauthorized {
+					t.Fatalf("%s request = %d, want 401", test.name, w.Code)
+				}
+			}
+
+			verifier.Now = func() time.Time { return now.Add(time.Hour) }
+			if w := send(`{"event":"deploy"}`, nil); w.Code != http.StatusUnauthorized {
+				t.Fatalf("stale request = %d, want 401", w.Code)
+			}
+		})
+	}
+}
+
+func TestVerifyOtherScheme(t *testing.T) {
+	keys, _ := schnorr.GenerateTestKeys([]byte("webhook"), 1)
+	signer := NewSigner(keys[0].PrivateKey, "k")
+	signer.Scheme = schnorr.SchemeBIP340
+	header, err := signer.HeaderValue([]byte("body"))
+	if err != nil {
+		t.Fatalf("Unexpected error from HeaderValue: %v", err)
+	}
+
+	// a legacy verifier refuses BIP-340 signatures
+	if _, err := NewVerifier(Keys{"k": keys[0].PublicKey[:]}).Verify(header, []byte("body")); err == nil {
+		t.Fatalf("Verify() of a bip340 signature with a legacy verifier = nil error, want one")
+	}
+}
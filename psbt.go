@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ryohare/schnorr-go/pkg/btctx"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+)
+
+func runPSBT(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go psbt <sign> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "sign":
+		psbtSign(args[1:])
+	default:
+		unknownCommand("psbt", args)
+	}
+}
+
+// psbtSign signs the taproot inputs of a PSBT the key can spend, on the
+// key path or the script path, and writes it back with the signatures for
+// whoever finalizes it. The PSBT is read as base64 or binary and written
+// the same way.
+func psbtSign(args []string) {
+	fs := newFlagSet("psbt sign")
+	inPtr := fs.String("in", "", "psbt file, base64 or binary, - for stdin")
+	outPtr := fs.String("out", "", "file to write the signed psbt to, stdout if empty")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, prompted for if empty")
+	parseFlags(fs, args)
+
+	var raw []byte
+	var err error
+	switch *inPtr {
+	case "":
+		failf("pass the psbt with -in")
+		return
+	case "-":
+		raw, err = io.ReadAll(prompt.Stdin())
+	default:
+		raw, err = os.ReadFile(*inPtr)
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	encoded := !bytes.HasPrefix(raw, []byte("psbt\xff"))
+	if encoded {
+		if raw, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(raw))); err != nil {
+			failf("psbt is neither binary nor base64")
+			return
+		}
+	}
+	p, err := btctx.ParsePSBT(raw)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	privateKey, err := secretPrivateKey(prompt.New(), *privateKeyPtr, "Private key (hex or WIF): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	keyBytes, err := hex.DecodeString(privateKey)
+	if err != nil || len(keyBytes) != 32 {
+		failf("private key is not 32 bytes of hex")
+		return
+	}
+	key, _ := btcec.PrivKeyFromBytes(keyBytes)
+
+	signed, err := btctx.SignPSBT(p, key)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if len(signed) == 0 {
+		failf("the key can't sign any input of the psbt")
+		exit(1)
+	}
+	for _, s := range signed {
+		if s.LeafHash == nil {
+			fmt.Fprintf(os.Stderr, "signed input %d on the key path\n", s.Input)
+		} else {
+			fmt.Fprintf(os.Stderr, "signed input %d on the script path, leaf %x\n", s.Input, s.LeafHash)
+		}
+	}
+
+	out := p.Serialize()
+	if encoded {
+		out = []byte(base64.StdEncoding.EncodeToString(out) + "\n")
+	}
+	if *outPtr == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(*outPtr, out, 0644); err != nil {
+		fail(err)
+	}
+}
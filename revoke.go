@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/revocation"
+)
+
+func runRevoke(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go revoke <generate|unseal|check> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "generate":
+		revokeGenerate(args[1:])
+	case "unseal":
+		revokeUnseal(args[1:])
+	case "check":
+		revokeCheck(args[1:])
+	default:
+		unknownCommand("revoke", args)
+	}
+}
+
+func revokeGenerate(args []string) {
+	fs := newFlagSet("revoke generate")
+	privateKeyPtr := fs.String("privkey", "", "private key to make the certificate for, prompted for if empty")
+	reasonPtr := fs.String("reason", revocation.ReasonUnspecified, "unspecified, compromised, superseded or retired")
+	commentPtr := fs.String("comment", "", "free text kept in the certificate")
+	sealPtr := fs.Bool("seal", true, "encrypt the certificate under a passphrase for safe keeping")
+	outputPtr := fs.String("output", "", "file to write the certificate to, stdout if empty")
+	parseFlags(fs, args)
+
+	p := prompt.New()
+	privateKey, err := secretPrivateKey(p, *privateKeyPtr, "Private key (hex or WIF): ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, ok := new(big.Int).SetString(privateKey, 16)
+	if !ok {
+		failf("private key is not hex")
+		return
+	}
+
+	cert, err := revocation.Generate(d, *reasonPtr, *commentPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	data, err := json.MarshalIndent(cert, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if *sealPtr {
+		passphrase, err := p.NewPassphrase("Passphrase to seal the certificate: ")
+		if err != nil {
+			fail(err)
+			return
+		}
+		sealed, err := revocation.Seal(data, cert.PublicKey, passphrase)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if data, err = json.MarshalIndent(sealed, "", "  "); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+	}
+}
+
+func revokeUnseal(args []string) {
+	fs := newFlagSet("revoke unseal")
+	inputPtr := fs.String("input", "", "sealed certificate file")
+	outputPtr := fs.String("output", "", "file to write the certificate to for publishing, stdout if empty")
+	parseFlags(fs, args)
+
+	data, err := os.ReadFile(*inputPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	sealed, err := revocation.ParseSealed(data)
+	if err != nil {
+		fail(err)
+		return
+	}
+	passphrase, err := prompt.New().Secret("Passphrase: ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	cert, err := sealed.Open(passphrase)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if _, _, err := revocation.Parse(cert); err != nil {
+		fail(err)
+		return
+	}
+
+	if err := writeOrPrint(*outputPtr, cert); err != nil {
+		fail(err)
+	}
+}
+
+func revokeCheck(args []string) {
+	fs := newFlagSet("revoke check")
+	certPtr := fs.String("cert", "", "revocation certificate file")
+	parseFlags(fs, args)
+
+	data, err := os.ReadFile(*certPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	statement, publickey, err := revocation.Parse(data)
+	if err != nil {
+		fail(err)
+		printVerified("Signature", false)
+		return
+	}
+	fmt.Printf("revokes %x: %s %s\n", publickey, statement.Reason, statement.Comment)
+	printVerified("Signature", true)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/dirverify"
+	"github.com/ryohare/schnorr-go/pkg/s3"
+)
+
+//
+// Signing release artifacts where they live, in an S3-compatible bucket.
+// Credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, as every other S3 tool takes them.
+//
+
+func runS3(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go s3 <sign|verify> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "sign":
+		s3Sign(args[1:])
+	case "verify":
+		s3Verify(args[1:])
+	default:
+		unknownCommand("s3", args)
+	}
+}
+
+// s3Flags adds the flags naming the store and bucket
+func s3Flags(fs *flag.FlagSet) func() (*s3.Client, string, string) {
+	endpointPtr := fs.String("endpoint", "https://s3.amazonaws.com", "base URL of the S3-compatible store")
+	regionPtr := fs.String("region", "us-east-1", "region to sign requests for")
+	bucketPtr := fs.String("bucket", "", "bucket holding the objects")
+	prefixPtr := fs.String("prefix", "", "only objects whose keys start with this")
+	virtualPtr := fs.Bool("virtual-host", false, "address the bucket as a host name rather than in the path")
+
+	return func() (*s3.Client, string, string) {
+		c := s3.NewClient(*endpointPtr, *regionPtr, os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"))
+		c.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		c.VirtualHosted = *virtualPtr
+		return c, *bucketPtr, *prefixPtr
+	}
+}
+
+func s3Sign(args []string) {
+	fs := newFlagSet("s3 sign")
+	client := s3Flags(fs)
+	privateKeyPtr := fs.String("privkey", "", "private key to sign with, prompted for if empty")
+	resignPtr := fs.Bool("resign", false, "sign objects that already have a sidecar again")
+	parseFlags(fs, args)
+
+	c, bucket, prefix := client()
+	if bucket == "" {
+		failf("-bucket is required")
+		exit(2)
+	}
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+
+	results, err := c.SignBucket(context.Background(), bucket, prefix, d, *resignPtr)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	signed, skipped, failed := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Printf("%-8s %s: %v\n", "failed", r.Key, r.Err)
+		case r.Skipped:
+			skipped++
+		default:
+			signed++
+			fmt.Printf("%-8s %s\n", "signed", r.Key)
+		}
+	}
+	fmt.Printf("\n%d objects: %d signed, %d already signed, %d failed\n", len(results), signed, skipped, failed)
+	if failed > 0 {
+		exit(1)
+	}
+}
+
+func s3Verify(args []string) {
+	fs := newFlagSet("s3 verify")
+	client := s3Flags(fs)
+	pubKeyPtr := fs.String("pubkey", "", "public key the objects must be signed by")
+	quietPtr := fs.Bool("quiet", false, "only print objects which did not verify")
+	parseFlags(fs, args)
+
+	c, bucket, prefix := client()
+	if bucket == "" {
+		failf("-bucket is required")
+		exit(2)
+	}
+	pk, err := parsePublicKeyHex(*pubKeyPtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+
+	results, err := c.VerifyBucket(context.Background(), bucket, prefix, pk)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	for _, r := range results {
+		if *quietPtr && r.Status == dirverify.StatusOK {
+			continue
+		}
+		if r.Err != nil {
+			fmt.Printf("%-14s %s: %v\n", r.Status, r.Path, r.Err)
+		} else {
+			fmt.Printf("%-14s %s\n", r.Status, r.Path)
+		}
+	}
+
+	counts := dirverify.Summary(results)
+	fmt.Printf("\n%d objects: %d ok, %d bad signature, %d unsigned, %d missing, %d unreadable\n",
+		len(results), counts[dirverify.StatusOK], counts[dirverify.StatusBadSignature],
+		counts[dirverify.StatusUnsigned], counts[dirverify.StatusMissing], counts[dirverify.StatusError])
+
+	if !dirverify.OK(results) {
+		exit(1)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/envelope"
+	"github.com/ryohare/schnorr-go/pkg/groupseal"
+)
+
+//
+// Signed documents sealed to a FROST group key, see pkg/groupseal. Anyone
+// with public.json can seal, each participant turns its share file into a
+// decryption share, and a quorum's decryption shares open the document.
+//
+
+func runSeal(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go seal <encrypt|share|open> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "encrypt":
+		sealEncrypt(args[1:])
+	case "share":
+		sealShare(args[1:])
+	case "open":
+		sealOpen(args[1:])
+	default:
+		unknownCommand("seal", args)
+	}
+}
+
+func sealEncrypt(args []string) {
+	fs := newFlagSet("seal encrypt")
+	publicPtr := fs.String("public", "", "public.json of the group, or any share file")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign the document with, prompted for if empty")
+	inPtr := fs.String("in", "", "document to sign and seal")
+	typePtr := fs.String("type", "application/octet-stream", "payload type of the document")
+	contextPtr := fs.String("context", "", "what the document is for, bound to the seal and every decryption share")
+	outputPtr := fs.String("output", "", "file to write the sealed document to, stdout if empty")
+	parseFlags(fs, args)
+
+	pkg, err := readPublicKeyPackage(*publicPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	payload, err := os.ReadFile(*inPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	d, err := readPrivateKeyHex(*privateKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	e, err := envelope.Sign(d, *typePtr, payload)
+	if err != nil {
+		fail(err)
+		return
+	}
+	sealed, err := groupseal.Seal(pkg.GroupKey, e, []byte(*contextPtr))
+	if err != nil {
+		fail(err)
+		return
+	}
+	data, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+	}
+}
+
+func sealShare(args []string) {
+	fs := newFlagSet("seal share")
+	sharePtr := fs.String("share", "", "json file with the key_share and public_key_package")
+	sealedPtr := fs.String("sealed", "", "sealed document from seal encrypt")
+	outputPtr := fs.String("output", "", "file to write the decryption share to, stdout if empty")
+	parseFlags(fs, args)
+
+	share, err := readShareFile(*sharePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	sealed, err := readSealed(*sealedPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "context: %q\n", sealed.Context)
+	decryptionShare, err := sealed.Share(share.PublicKeyPackage, share.KeyShare)
+	if err != nil {
+		fail(err)
+		return
+	}
+	data, err := json.MarshalIndent(decryptionShare, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+	}
+}
+
+func sealOpen(args []string) {
+	var shareFiles stringList
+
+	fs := newFlagSet("seal open")
+	publicPtr := fs.String("public", "", "public.json of the group, or any share file")
+	sealedPtr := fs.String("sealed", "", "sealed document from seal encrypt")
+	fs.Var(&shareFiles, "decryption-share", "decryption share from seal share, repeated for each participant")
+	signerPtr := fs.String("signer", "", "public key the document must be signed by")
+	outputPtr := fs.String("output", "", "file to write the document to, stdout if empty")
+	parseFlags(fs, args)
+
+	signer, err := parsePublicKeyHex(*signerPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	pkg, err := readPublicKeyPackage(*publicPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	sealed, err := readSealed(*sealedPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	shares := []*groupseal.DecryptionShare{}
+	for _, path := range shareFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fail(err)
+			return
+		}
+		share := new(groupseal.DecryptionShare)
+		if err := json.Unmarshal(data, share); err != nil {
+			failf("%s: %v", path, err)
+			return
+		}
+		shares = append(shares, share)
+	}
+
+	e, err := sealed.Open(pkg, shares)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := envelope.Verify(e, signer); err != nil {
+		fail(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "opened %s signed by %s\n", e.PayloadType, e.PublicKey)
+	if err := writeOrPrint(*outputPtr, e.Payload); err != nil {
+		fail(err)
+	}
+}
+
+func readSealed(path string) (*groupseal.Sealed, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sealed := new(groupseal.Sealed)
+	if err := json.Unmarshal(data, sealed); err != nil {
+		return nil, err
+	}
+	return sealed, nil
+}
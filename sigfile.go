@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/sigfile"
+)
+
+//
+// Detached signature files from sign -detach or -output, see pkg/sigfile:
+// the 64 raw bytes, or with -armor a PEM block. verify -sig reads either,
+// or the hex sign prints, and verify -recursive reads the ones -detach
+// writes next to each file.
+//
+
+// readSignatureArg reads a signature given as hex, or as the path of a
+// signature file in any of the forms sign writes
+func readSignatureArg(arg string) ([]byte, error) {
+	data, err := os.ReadFile(arg)
+	if os.IsNotExist(err) {
+		return hex.DecodeString(arg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sigfile.Decode(data)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetachVerifiesRecursively(t *testing.T) {
+	// given files signed with -detach, binary and armored, and one whose
+	// .sig is the hex sign -in prints
+	dir := t.TempDir()
+	pubkey, _ := signTestMessage("", t)
+	for _, args := range [][]string{
+		{"sign", "-detach", "-in", writeTestFile(dir, "a.txt", "first\n", t), "-privkey", testPrivateKey},
+		{"sign", "-detach", "-armor", "-in", writeTestFile(dir, "b.txt", "second\n", t), "-privkey", testPrivateKey},
+	} {
+		if code, printed := runTestCommand(t, args...); code != 0 {
+			t.Fatalf("%s = exit %d: %s", strings.Join(args, " "), code, printed)
+		}
+	}
+	code, printed := runTestCommand(t, "sign", "-in", writeTestFile(dir, "c.txt", "third\n", t), "-privkey", testPrivateKey)
+	if code != 0 {
+		t.Fatalf("sign -in = exit %d: %s", code, printed)
+	}
+	writeTestFile(dir, "c.txt.sig", printed, t)
+
+	// when
+	code, printed = runTestCommand(t, "verify", "-pubkey", pubkey, "-recursive", dir)
+
+	// then
+	if code != 0 {
+		t.Fatalf("verify -recursive = exit %d, want 0: %s", code, printed)
+	}
+	if !strings.Contains(printed, "3 files: 3 ok") {
+		t.Fatalf("verify -recursive printed %q, want 3 files ok", printed)
+	}
+
+	t.Run("A changed file fails", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("changed\n"), 0644); err != nil {
+			t.Fatalf("Unexpected error from os.WriteFile: %v", err)
+		}
+		code, printed := runTestCommand(t, "verify", "-pubkey", pubkey, "-recursive", dir)
+		if code != 1 || !strings.Contains(printed, "bad signature") {
+			t.Fatalf("verify -recursive = exit %d, %q, want 1 with a bad signature", code, printed)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/sigfile"
+	"github.com/ryohare/schnorr-go/pkg/siglog"
+	"github.com/ryohare/schnorr-go/pkg/watch"
+)
+
+//
+// Compacting the json audit log of daemon -watch into a signature log, see
+// pkg/siglog, and expanding it back. Only signed entries are kept, the rest
+// record decisions rather than signatures, and not their file sizes.
+//
+
+func runSiglog(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go siglog <compress|expand> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "compress":
+		siglogCompress(args[1:])
+	case "expand":
+		siglogExpand(args[1:])
+	default:
+		unknownCommand("siglog", args)
+	}
+}
+
+func siglogCompress(args []string) {
+	fs := newFlagSet("siglog compress")
+	inPtr := fs.String("in", "", "audit log of daemon -watch to compress")
+	outputPtr := fs.String("output", "", "file to write the signature log to")
+	parseFlags(fs, args)
+
+	if *inPtr == "" || *outputPtr == "" {
+		failf("usage: schnorr-go siglog compress -in audit.jsonl -output audit.sglg")
+		exit(2)
+	}
+	in, err := os.Open(*inPtr)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	defer in.Close()
+	out, err := os.Create(*outputPtr)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	defer out.Close()
+
+	n, err := compressAuditLog(in, out)
+	if err != nil {
+		fail(err)
+		exit(1)
+	}
+	info, _ := in.Stat()
+	packed, _ := out.Stat()
+	fmt.Fprintf(os.Stderr, "%d signatures, %d bytes down to %d\n", n, info.Size(), packed.Size())
+}
+
+// compressAuditLog writes the signed entries of the audit log to a
+// signature log, refusing logs with signatures from more than one key
+func compressAuditLog(r io.Reader, w io.Writer) (int, error) {
+	var e *siglog.Encoder
+	var publickey string
+	n := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var entry watch.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return n, fmt.Errorf("line %d: %v", line, err)
+		}
+		if entry.Outcome != watch.Signed {
+			continue
+		}
+
+		if e == nil {
+			pk, err := parsePublicKeyHex(entry.PublicKey)
+			if err != nil {
+				return n, fmt.Errorf("line %d: %v", line, err)
+			}
+			if e, err = siglog.NewEncoder(w, sigfile.Scheme, pk); err != nil {
+				return n, err
+			}
+			publickey = entry.PublicKey
+		} else if entry.PublicKey != publickey {
+			return n, fmt.Errorf("line %d: signed by %s, earlier entries by %s, a signature log holds one key", line, entry.PublicKey, publickey)
+		}
+
+		r := &siglog.Record{Time: entry.Time, Label: entry.Path, HasDigest: true}
+		if err := decodeHexInto(r.Digest[:], entry.BLAKE256, "blake256"); err != nil {
+			return n, fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := decodeHexInto(r.Signature[:], entry.Signature, "signature"); err != nil {
+			return n, fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := e.Encode(r); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, err
+	}
+	if e == nil {
+		return 0, fmt.Errorf("the audit log has no signed entries")
+	}
+	return n, e.Close()
+}
+
+func siglogExpand(args []string) {
+	fs := newFlagSet("siglog expand")
+	inPtr := fs.String("in", "", "signature log to expand")
+	verifyPtr := fs.Bool("verify", false, "verify every signature, exiting 1 if any fail")
+	parseFlags(fs, args)
+
+	in, err := os.Open(*inPtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	defer in.Close()
+	d, err := siglog.NewDecoder(in)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+
+	publickey := d.PublicKey()
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+	failed := 0
+	for {
+		r, err := d.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Flush()
+			fail(err)
+			exit(1)
+		}
+		if *verifyPtr {
+			if ok, _ := d.Verify(r); !ok {
+				failed++
+				fmt.Fprintf(os.Stderr, "bad signature: %s %x\n", r.Label, r.Signature)
+			}
+		}
+		enc.Encode(watch.AuditEntry{
+			Time:      r.Time,
+			Path:      r.Label,
+			Outcome:   watch.Signed,
+			BLAKE256:  hex.EncodeToString(r.Digest[:]),
+			PublicKey: hex.EncodeToString(publickey[:]),
+			Signature: hex.EncodeToString(r.Signature[:]),
+		})
+	}
+	if failed > 0 {
+		out.Flush()
+		fmt.Fprintf(os.Stderr, "%d signatures did not verify\n", failed)
+		exit(1)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/decred/dcrd/crypto/blake256"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/hooks"
+	"github.com/ryohare/schnorr-go/pkg/msgtemplate"
+	"github.com/ryohare/schnorr-go/pkg/prompt"
+	"github.com/ryohare/schnorr-go/pkg/sigfile"
+	"github.com/ryohare/schnorr-go/pkg/textnorm"
+)
+
+//
+// Signing and verifying a message with Decred's EC-Schnorr-DCRv0 over its
+// blake256 hash, as the tool always has. These were the -sign and -verify
+// flags before there were subcommands.
+//
+
+func runSign(args []string) {
+	var preSign, postSign stringList
+
+	fs := newFlagSet("sign")
+	messagePtr := fs.String("message", "", "message to be signed")
+	inPtr := fs.String("in", "", "file to sign instead of -message, - for stdin")
+	privateKeyPtr := fs.String("privkey", "", "private key to sign the message with, prompted for if empty")
+	privateKeyFilePtr := fs.String("privkey-file", "", "PEM, encrypted keystore or Ethereum keystore file of the private key instead of -privkey")
+	derivationPathPtr := fs.String("derivation-path", "", "sign with the key at this BIP-32 path, such as m/86'/0'/0'/0/0, of the xprv given as -privkey")
+	fs.Var(&preSign, "pre-sign", "program to run before signing, which may refuse or change the message, can be repeated")
+	fs.Var(&postSign, "post-sign", "program to run after signing, which may withhold the signature, can be repeated")
+	templatesPtr := fs.String("templates", "", "message templates the key is restricted to, by its public key")
+	detachPtr := fs.Bool("detach", false, "with -in, write the signature to the file's name with .sig added")
+	outputPtr := fs.String("output", "", "file to write the signature to, as binary unless -armor, stdout in hex if empty")
+	armorPtr := fs.Bool("armor", false, "write the signature file as armored text rather than binary")
+	templatePtr := fs.String("template", "", "Go text/template to print the result with, such as 'sig={{.Signature}} key={{.Fingerprint}}'")
+	batchPtr := fs.String("batch", "", "json manifest of messages and files to sign with one key load, instead of -message or -in")
+	textPtr := fs.Bool("text", false, "normalize the message as text before signing, NFC with \\n line endings, as verify -text does")
+	trimPtr := fs.Bool("trim", false, "with -text, also strip spaces and tabs from the ends of lines")
+	resultsPtr := fs.String("results", "", "with -batch, file to write the signatures to, stdout if empty")
+	parseFlags(fs, args)
+
+	privateKey, err := privateKeyFlag(*privateKeyPtr, *privateKeyFilePtr)
+	if err == nil && *derivationPathPtr != "" {
+		if *privateKeyFilePtr != "" {
+			err = fmt.Errorf("-derivation-path needs an xprv as -privkey, not -privkey-file")
+		} else {
+			privateKey, err = derivedKeyFlag(privateKey, *derivationPathPtr)
+		}
+	}
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	text, err := textFlag(*textPtr, *trimPtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	if *batchPtr != "" {
+		if *messagePtr != "" || *inPtr != "" || *outputPtr != "" || *detachPtr || *templatePtr != "" {
+			failf("-batch takes the messages from the manifest, and writes -results, not -message, -in, -output, -detach or -template")
+			exit(2)
+		}
+	} else if *resultsPtr != "" {
+		failf("-results needs -batch")
+		exit(2)
+	}
+	output := *outputPtr
+	if *detachPtr {
+		if *inPtr == "" || *inPtr == "-" || output != "" {
+			failf("-detach needs -in with a file, and no -output")
+			exit(2)
+		}
+		output = *inPtr + sigfile.Ext
+	}
+
+	hookSet, err := hooks.NewSet(preSign, postSign)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	tmpl, err := outputTemplate(*templatePtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+	var templates *msgtemplate.Policy
+	if *templatesPtr != "" {
+		if templates, err = msgtemplate.Load(*templatesPtr); err != nil {
+			fail(err)
+			exit(2)
+		}
+	}
+	if *batchPtr != "" {
+		if !signBatch(*batchPtr, *resultsPtr, text, privateKey, hookSet, templates) {
+			exit(1)
+		}
+		return
+	}
+	signature, publickey := signMessage(*messagePtr, *inPtr, text, privateKey, hookSet, templates)
+	if signature == nil {
+		exit(1)
+	}
+	if output != "" {
+		if err := os.WriteFile(output, sigfile.Encode(signature, *armorPtr), 0644); err != nil {
+			fail(err)
+			exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "signature written to %s\n", output)
+	}
+	out := signOutput{
+		Signature:   hex.EncodeToString(signature),
+		PublicKey:   hex.EncodeToString(publickey),
+		Fingerprint: fingerprintHex(hex.EncodeToString(publickey)),
+		File:        *inPtr,
+		Output:      output,
+	}
+	recordResult(out)
+	switch {
+	case tmpl != nil:
+		if err := printTemplate(tmpl, out); err != nil {
+			fail(err)
+			exit(1)
+		}
+	case output == "":
+		fmt.Printf("%x\n", signature)
+	}
+}
+
+// hashInput writes the message, or the file in streamed from disk or
+// stdin if it's "-", to the hashes. With text options the message or file
+// is read whole and normalized first, see textFlag.
+func hashInput(message, in string, text *textnorm.Options, hashes ...hash.Hash) error {
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		writers[i] = h
+	}
+	w := io.MultiWriter(writers...)
+	if in != "" && message != "" {
+		return fmt.Errorf("give -message or -in, not both")
+	}
+
+	var r io.Reader
+	switch in {
+	case "":
+		r = strings.NewReader(message)
+	case "-":
+		r = os.Stdin
+	default:
+		f, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if text != nil {
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(textnorm.Normalize(raw, *text))
+	}
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// textFlag is the normalization of -text and -trim, nil for messages
+// signed as they are
+func textFlag(text, trim bool) (*textnorm.Options, error) {
+	if !text {
+		if trim {
+			return nil, fmt.Errorf("-trim needs -text")
+		}
+		return nil, nil
+	}
+	return &textnorm.Options{TrimTrailing: trim}, nil
+}
+
+// signMessage returns the signature of the message, or of the file in, and
+// the compressed public key it verifies under, as messageSigner signs it.
+// Errors are printed and return nil, or exit for bad input and refusals.
+func signMessage(message, in string, text *textnorm.Options, privateKeyFlag string, hookSet *hooks.Set, templates *msgtemplate.Policy) ([]byte, []byte) {
+	if in == "-" && privateKeyFlag == "" {
+		failf("stdin holds the message, so the private key must be given with -privkey or -privkey-file")
+		exit(2)
+	}
+	signer, err := newMessageSigner(privateKeyFlag, text, hookSet, templates)
+	if err != nil {
+		fail(err)
+		return nil, nil
+	}
+	signature, publickey, err := signer.sign(message, in)
+	if err != nil {
+		fail(err)
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			exit(exitErr.code)
+		}
+		return nil, nil
+	}
+	return signature, publickey
+}
+
+// exitError is an error the command exits on with code: 2 for input it
+// couldn't read, 1 for a refusal
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// messageSigner signs messages and files with a key loaded once, running
+// the hooks around each signature and refusing messages outside the key's
+// templates. Files are hashed as a stream and never held whole, so hooks
+// and templates only see their digest.
+type messageSigner struct {
+	key       *secp256k1.PrivateKey
+	text      *textnorm.Options
+	hooks     *hooks.Set
+	templates *msgtemplate.Policy
+}
+
+// newMessageSigner loads the key, prompting for it if the flag is empty
+func newMessageSigner(privateKeyFlag string, text *textnorm.Options, hookSet *hooks.Set, templates *msgtemplate.Policy) (*messageSigner, error) {
+	privateKey, err := secretPrivateKey(prompt.New(), privateKeyFlag, "Private key (hex or WIF): ")
+	if err != nil {
+		return nil, err
+	}
+	pkBytes, err := hex.DecodeString(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &messageSigner{key: secp256k1.PrivKeyFromBytes(pkBytes), text: text, hooks: hookSet, templates: templates}, nil
+}
+
+// publicKey is the compressed public key signatures verify under
+func (s *messageSigner) publicKey() []byte {
+	return s.key.PubKey().SerializeCompressed()
+}
+
+// sign returns the signature of the message, or of the file in, and the
+// compressed public key it verifies under
+func (s *messageSigner) sign(message, in string) ([]byte, []byte, error) {
+	// Sign a message using the private key, as the hooks left it.
+	h := blake256.New()
+	if err := hashInput(message, in, s.text, h); err != nil {
+		return nil, nil, &exitError{2, err}
+	}
+	var messageHash [32]byte
+	copy(messageHash[:], h.Sum(nil))
+	hookReq := &hooks.Request{
+		PublicKey: hex.EncodeToString(s.publicKey()),
+		Digest:    hex.EncodeToString(messageHash[:]),
+	}
+	// only a message given on the command line may be replaced by hooks
+	var rehash func([]byte) [32]byte
+	if in == "" {
+		hookReq.Message, rehash = []byte(message), blake256.Sum256
+		if s.text != nil {
+			// hooks and templates see the text as it's signed, and a
+			// message a hook replaces it with is normalized too
+			text := *s.text
+			hookReq.Message = textnorm.Normalize(hookReq.Message, text)
+			rehash = func(message []byte) [32]byte {
+				return blake256.Sum256(textnorm.Normalize(message, text))
+			}
+		}
+	} else {
+		hookReq.File = in
+	}
+	if err := s.hooks.RunPreSign(context.Background(), hookReq, rehash); err != nil {
+		return nil, nil, &exitError{1, err}
+	}
+	if s.templates.Restricted(hookReq.PublicKey) {
+		if hookReq.Message == nil {
+			return nil, nil, &exitError{1, fmt.Errorf("the key only signs messages matching its templates, which files and digests from hooks are not checked against")}
+		}
+		if err := s.templates.Check(hookReq.Message, hookReq.PublicKey); err != nil {
+			return nil, nil, &exitError{1, err}
+		}
+	}
+	messageHash, err := hookReq.DigestBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err := schnorr.Sign(s.key, messageHash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hookReq.Signature = hex.EncodeToString(signature.Serialize())
+	if err := s.hooks.RunPostSign(context.Background(), hookReq); err != nil {
+		return nil, nil, &exitError{1, err}
+	}
+
+	// Verify the signature for the message using the public key.
+	if !signature.Verify(messageHash[:], s.key.PubKey()) {
+		return nil, nil, fmt.Errorf("signing has failed validation")
+	}
+	return signature.Serialize(), s.publicKey(), nil
+}
+
+// verifyMessage checks a signature from signMessage over the message or the
+// file in
+func verifyMessage(publickey, message, in string, text *textnorm.Options, sig string) (bool, error) {
+	// Decode hex-encoded serialized public key.
+	pubKeyBytes, err := hex.DecodeString(publickey)
+	if err != nil {
+		return false, err
+	}
+	// an x-only key is the one with an even y
+	if len(pubKeyBytes) == 32 {
+		pubKeyBytes = append([]byte{2}, pubKeyBytes...)
+	}
+
+	pubKey, err := schnorr.ParsePubKey(pubKeyBytes)
+	if err != nil {
+		return false, err
+	}
+
+	// Decode the signature, hex or a signature file.
+	sigBytes, err := readSignatureArg(sig)
+	if err != nil {
+		return false, err
+	}
+	signature, err := schnorr.ParseSignature(sigBytes)
+	if err != nil {
+		return false, err
+	}
+
+	// Verify the signature for the message using the public key.
+	h := blake256.New()
+	if err := hashInput(message, in, text, h); err != nil {
+		return false, err
+	}
+	return signature.Verify(h.Sum(nil), pubKey), nil
+}
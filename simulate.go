@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/simulate"
+)
+
+//
+// Rehearsing a ceremony with every participant simulated here, see
+// pkg/simulate. The events go to stdout as they happen, as text or json
+// lines for piping into whatever will monitor the real ceremony, and the
+// exit status is 1 if the ceremony did not complete.
+//
+
+func ceremonySimulate(args []string) {
+	fs := flag.NewFlagSet("ceremony simulate", flag.ExitOnError)
+	kindPtr := fs.String("kind", simulate.KindFROST, "ceremony to rehearse: dkg, musig or frost")
+	participantsPtr := fs.Int("n", 3, "number of participants")
+	thresholdPtr := fs.Int("t", 2, "signers a dkg or frost key needs")
+	messagePtr := fs.String("message", "rehearsal", "message to be signed, its sha256 is what is signed")
+	jsonPtr := fs.Bool("json", false, "print events as json lines")
+	var faultFlags stringList
+	fs.Var(&faultFlags, "fault", "fault to inject, as drop:<participant>[:<round>], bad-share:<participant> or bad-nonce:<participant>, repeatable")
+	fs.Parse(args)
+
+	cfg := simulate.Config{
+		Kind:         *kindPtr,
+		Participants: *participantsPtr,
+		Threshold:    *thresholdPtr,
+		Message:      []byte(*messagePtr),
+	}
+	for _, s := range faultFlags {
+		f, err := simulate.ParseFault(s)
+		if err != nil {
+			fail(err)
+			exit(2)
+		}
+		cfg.Faults = append(cfg.Faults, f)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	cfg.Events = func(e simulate.Event) {
+		if *jsonPtr {
+			enc.Encode(e)
+			return
+		}
+		fmt.Println(formatEvent(e))
+	}
+
+	result, err := simulate.Run(cfg)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+
+	if !result.Completed {
+		fmt.Fprintf(os.Stderr, "ceremony failed: %s\n", result.Failure)
+	} else {
+		fmt.Fprintf(os.Stderr, "public key: %x\n", result.PublicKey)
+		if cfg.Kind != simulate.KindDKG {
+			fmt.Fprintf(os.Stderr, "signature:  %x\n", result.Signature)
+		}
+	}
+	if len(result.Blamed) > 0 {
+		fmt.Fprintf(os.Stderr, "blamed: %v\n", result.Blamed)
+	}
+	if !result.Completed {
+		exit(1)
+	}
+}
+
+func formatEvent(e simulate.Event) string {
+	s := ""
+	if e.Attempt > 0 {
+		s = fmt.Sprintf("attempt %d ", e.Attempt)
+	}
+	s += e.Step
+	switch {
+	case e.From != 0 && e.To != 0:
+		s += fmt.Sprintf(" %d -> %d", e.From, e.To)
+	case e.From != 0:
+		s += fmt.Sprintf(" %d", e.From)
+	}
+	s += ": " + e.Outcome
+	if e.Detail != "" {
+		s += " (" + e.Detail + ")"
+	}
+	return s
+}
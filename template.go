@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/truststore"
+)
+
+//
+// -template shapes the output of sign, verify and inspect for scripts with
+// a Go text/template, such as
+//
+//	-template 'sig={{.Signature}} key={{.Fingerprint}}'
+//
+// executed with signOutput, verifyOutput or inspectOutput. Each is printed
+// on a line of its own. -json gives the same values as results, see json.go.
+//
+
+// signOutput is what sign -template is executed with
+type signOutput struct {
+	Signature   string `json:"signature"`
+	PublicKey   string `json:"publicKey"`
+	Fingerprint string `json:"fingerprint"`
+	// File is the -in file signed, Output the file the signature was
+	// written to, if any
+	File   string `json:"file,omitempty"`
+	Output string `json:"output,omitempty"`
+}
+
+// verifyOutput is what verify -template is executed with, once for each
+// file, or once for a -sig signature
+type verifyOutput struct {
+	Verified    bool   `json:"verified"`
+	PublicKey   string `json:"publicKey"`
+	Fingerprint string `json:"fingerprint"`
+	// Path, Status and Error are set for files
+	Path   string `json:"path,omitempty"`
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// inspectOutput is what inspect -template is executed with
+type inspectOutput struct {
+	PayloadType string            `json:"payloadType"`
+	PublicKey   string            `json:"publicKey"`
+	Fingerprint string            `json:"fingerprint"`
+	Sequence    *uint64           `json:"sequence,omitempty"`
+	Expires     *time.Time        `json:"expires,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// outputTemplate parses the -template flag, nil if it's empty
+func outputTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	t, err := template.New("output").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("-template: %v", err)
+	}
+	return t, nil
+}
+
+// printTemplate prints the template executed with data on a line
+func printTemplate(t *template.Template, data interface{}) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return fmt.Errorf("-template: %v", err)
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		buf.WriteString("\n")
+	}
+	_, err := os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// fingerprintHex is the trust store fingerprint of a hex public key, empty
+// if it isn't one; an x-only key is taken to have an even y
+func fingerprintHex(publickey string) string {
+	raw, err := hex.DecodeString(publickey)
+	if err != nil {
+		return ""
+	}
+	if len(raw) == 32 {
+		raw = append([]byte{2}, raw...)
+	}
+	if len(raw) != 33 {
+		return ""
+	}
+	var pk [33]byte
+	copy(pk[:], raw)
+	return truststore.Fingerprint(pk)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ryohare/schnorr-go/pkg/hooks"
+	"github.com/ryohare/schnorr-go/pkg/msgtemplate"
+	"github.com/ryohare/schnorr-go/pkg/store"
+	"github.com/ryohare/schnorr-go/pkg/vault"
+)
+
+//
+// -tenants hosts the keystores of several teams in one daemon, from a json
+// file of tenants by name:
+//
+//	{
+//	  "payments": {"token": "...", "key_limit": "5:20:10000", "audit": "payments-audit.jsonl"},
+//	  "ledger": {"token": "...", "templates": "ledger-templates.json"}
+//	}
+//
+// Each tenant gets a signing api of its own behind its token, with its own
+// keys, limits, templates, hooks and audit log, as the daemon flags give
+// them for -token.
+//
+
+// tenantConfig is one tenant of -tenants
+type tenantConfig struct {
+	Token       string   `json:"token"`
+	KeyLimit    string   `json:"key_limit,omitempty"`
+	ClientLimit string   `json:"client_limit,omitempty"`
+	KeyLimitFor []string `json:"key_limit_for,omitempty"`
+	Templates   string   `json:"templates,omitempty"`
+	PreSign     []string `json:"pre_sign,omitempty"`
+	PostSign    []string `json:"post_sign,omitempty"`
+	Audit       string   `json:"audit,omitempty"`
+	MaxKeyAge   string   `json:"max_key_age,omitempty"`
+}
+
+// loadTenants reads the -tenants file and returns an engine for each
+// tenant, sorted by name, with the files they audit to for the caller to
+// close. Keys are kept in the state store under tenants/<name>/ if there
+// is one, in memory if not.
+func loadTenants(path, mount string, state store.Store, principal func(r *http.Request) string) ([]*vault.Engine, []io.Closer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var configs map[string]tenantConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, nil, fmt.Errorf("tenants file %s is not json: %v", path, err)
+	}
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var engines []*vault.Engine
+	var closers []io.Closer
+	for _, name := range names {
+		engine, closer, err := newTenant(name, configs[name], mount, state, principal)
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+			return nil, nil, fmt.Errorf("tenant %s: %v", name, err)
+		}
+		engines = append(engines, engine)
+	}
+	return engines, closers, nil
+}
+
+func newTenant(name string, config tenantConfig, mount string, state store.Store, principal func(r *http.Request) string) (*vault.Engine, io.Closer, error) {
+	if err := vault.ValidTenantName(name); err != nil {
+		return nil, nil, err
+	}
+	engine := vault.NewEngine(mount, config.Token)
+	engine.Tenant = name
+	if state != nil {
+		engine.Storage = &vault.PrefixStorage{Storage: &vault.StateStorage{State: state}, Prefix: "tenants/" + name + "/"}
+		engine.Idempotency = state
+	}
+	if config.MaxKeyAge != "" {
+		maxAge, err := time.ParseDuration(config.MaxKeyAge)
+		if err != nil {
+			return nil, nil, fmt.Errorf("max_key_age: %v", err)
+		}
+		engine.MaxAge = maxAge
+	}
+	hookSet, err := hooks.NewSet(config.PreSign, config.PostSign)
+	if err != nil {
+		return nil, nil, err
+	}
+	engine.Hooks = hookSet
+	if config.Templates != "" {
+		if engine.Templates, err = msgtemplate.Load(config.Templates); err != nil {
+			return nil, nil, err
+		}
+	}
+	if config.KeyLimit != "" || config.ClientLimit != "" || len(config.KeyLimitFor) > 0 {
+		if engine.Limiter, err = newLimiter(config.KeyLimit, config.ClientLimit, config.KeyLimitFor); err != nil {
+			return nil, nil, err
+		}
+		engine.Principal = principal
+	}
+
+	if config.Audit != "" {
+		f, err := os.OpenFile(config.Audit, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, nil, err
+		}
+		engine.Audit = f
+		return engine, f, nil
+	} else if state != nil {
+		engine.Audit = store.LogWriter(context.Background(), state, "signing-audit-"+name)
+	}
+	return engine, nil, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/truststore"
+)
+
+func runTrust(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go trust <add|remove|list> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		trustAdd(args[1:])
+	case "remove":
+		trustRemove(args[1:])
+	case "list":
+		trustList(args[1:])
+	default:
+		unknownCommand("trust", args)
+	}
+}
+
+func trustAdd(args []string) {
+	fs := newFlagSet("trust add")
+	storePtr := fs.String("trust", "trust.json", "trust store file")
+	namePtr := fs.String("name", "", "name to know the key by")
+	pubKeyPtr := fs.String("pubkey", "", "public key to trust")
+	levelPtr := fs.String("level", string(truststore.LevelFull), "never, first-use, marginal or full")
+	commentPtr := fs.String("comment", "", "free text kept with the key, such as how it was checked")
+	parseFlags(fs, args)
+
+	pk, err := parsePublicKeyHex(*pubKeyPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	level, err := truststore.ParseLevel(*levelPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	s, err := truststore.Open(*storePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	e, err := s.Add(*namePtr, pk, level, *commentPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := s.Save(); err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("%s %s %s\n", e.Name, e.Trust, e.Fingerprint)
+}
+
+func trustRemove(args []string) {
+	fs := newFlagSet("trust remove")
+	storePtr := fs.String("trust", "trust.json", "trust store file")
+	namePtr := fs.String("name", "", "name of the key to forget")
+	parseFlags(fs, args)
+
+	s, err := truststore.Open(*storePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := s.Remove(*namePtr); err != nil {
+		fail(err)
+		return
+	}
+	if err := s.Save(); err != nil {
+		fail(err)
+	}
+}
+
+func trustList(args []string) {
+	fs := newFlagSet("trust list")
+	storePtr := fs.String("trust", "trust.json", "trust store file")
+	parseFlags(fs, args)
+
+	s, err := truststore.Open(*storePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	for _, e := range s.Entries() {
+		fmt.Println(strings.TrimSpace(fmt.Sprintf("%-20s %-10s %s %s", e.Name, e.Trust, e.PublicKey, e.Comment)))
+	}
+}
+
+// openTrustStore opens the store for a verify, warning on stderr about keys
+// pinned on first use
+func openTrustStore(path string, firstUse bool, minTrust string) (*truststore.Store, error) {
+	min, err := truststore.ParseLevel(minTrust)
+	if err != nil {
+		return nil, err
+	}
+	s, err := truststore.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s.MinTrust, s.FirstUse = min, firstUse
+	s.Warn = func(e *truststore.Entry) {
+		fmt.Fprintf(os.Stderr, "warning: first use of key %s, pinned as %s; check its fingerprint %s with its owner and trust add it\n", e.PublicKey, e.Name, e.Fingerprint)
+	}
+	return s, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ryohare/schnorr-go/pkg/twoparty"
+)
+
+func runTwoParty(args []string) {
+	if len(args) == 0 {
+		printUsage(args, "usage: schnorr-go twoparty <pair|sign> [flags]")
+		return
+	}
+
+	switch args[0] {
+	case "pair":
+		twoPartyPair(args[1:])
+	case "sign":
+		twoPartySign(args[1:])
+	default:
+		unknownCommand("twoparty", args)
+	}
+}
+
+func twoPartyPair(args []string) {
+	fs := newFlagSet("twoparty pair")
+	serverPtr := fs.String("server", "http://127.0.0.1:8200", "daemon holding the other half of the key")
+	tokenPtr := fs.String("token", "", "token of the daemon's -twoparty api")
+	outputPtr := fs.String("output", "", "file to keep the client share in")
+	parseFlags(fs, args)
+
+	if *outputPtr == "" {
+		failf("-output is required, the share is the only copy of the client half")
+		return
+	}
+
+	share, err := twoparty.NewClient(*serverPtr, *tokenPtr).Pair(context.Background())
+	if err != nil {
+		fail(err)
+		return
+	}
+	data, err := json.MarshalIndent(share, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := os.WriteFile(*outputPtr, data, 0600); err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("Joint public key: %s\n", hex.EncodeToString(share.JointKey[:]))
+}
+
+func twoPartySign(args []string) {
+	fs := newFlagSet("twoparty sign")
+	serverPtr := fs.String("server", "http://127.0.0.1:8200", "daemon holding the other half of the key")
+	tokenPtr := fs.String("token", "", "token of the daemon's -twoparty api")
+	sharePtr := fs.String("share", "", "client share from twoparty pair")
+	messagePtr := fs.String("message", "", "message to be signed, its sha256 is what is signed")
+	parseFlags(fs, args)
+
+	data, err := os.ReadFile(*sharePtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	share := new(twoparty.Share)
+	if err := json.Unmarshal(data, share); err != nil {
+		fail(err)
+		return
+	}
+
+	digest := sha256.Sum256([]byte(*messagePtr))
+	sig, err := twoparty.NewClient(*serverPtr, *tokenPtr).Sign(context.Background(), share, digest)
+	if err != nil {
+		fail(err)
+		return
+	}
+	fmt.Printf("Signature: %s\n", hex.EncodeToString(sig[:]))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ryohare/schnorr-go/pkg/vectors"
+)
+
+func runVectors(args []string) {
+	if args == nil {
+		fmt.Println("usage: schnorr-go vectors [-seed seed] [-n count]")
+		fmt.Println("       schnorr-go vectors generate [-seed seed] [-n count] [-schemes names] [-output file]")
+		return
+	}
+	if len(args) > 0 && args[0] == "generate" {
+		runVectorsGenerate(args[1:])
+		return
+	}
+
+	fs := newFlagSet("vectors")
+	seedPtr := fs.String("seed", "", "seed all keys, messages and nonces are derived from")
+	countPtr := fs.Int("n", 10, "number of vectors to generate")
+	parseFlags(fs, args)
+
+	vs, err := vectors.Generate([]byte(*seedPtr), *countPtr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(vs); err != nil {
+		fail(err)
+	}
+}
+
+func runVectorsGenerate(args []string) {
+	fs := newFlagSet("vectors generate")
+	seedPtr := fs.String("seed", "", "seed all keys, messages and nonces are derived from")
+	countPtr := fs.Int("n", 5, "number of signing cases per scheme, each followed by its negative cases")
+	schemesPtr := fs.String("schemes", "", "comma separated schemes to generate, all of "+strings.Join(vectors.SchemeNames(), ",")+" if empty")
+	outputPtr := fs.String("output", "", "file to write the fixtures to, stdout if empty")
+	parseFlags(fs, args)
+
+	var names []string
+	if *schemesPtr != "" {
+		names = strings.Split(*schemesPtr, ",")
+	}
+
+	suite, err := vectors.GenerateSuite([]byte(*seedPtr), *countPtr, names)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	data, err := json.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := writeOrPrint(*outputPtr, data); err != nil {
+		fail(err)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ryohare/schnorr-go/pkg/dirverify"
+	"github.com/ryohare/schnorr-go/pkg/revocation"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/truststore"
+)
+
+// runVerify checks files against their .sig sidecars, or whole trees with
+// -recursive, and exits non-zero unless everything verified. With -sig it
+// checks a signature from sign over -message instead.
+func runVerify(args []string) {
+	fs := newFlagSet("verify")
+	pubKeyPtr := fs.String("pubkey", "", "public key the files must be signed by")
+	pubKeyFilePtr := fs.String("pubkey-file", "", "PKIX PEM file of the public key instead of -pubkey")
+	recursivePtr := fs.Bool("recursive", false, "verify every file under the given directories")
+	quietPtr := fs.Bool("quiet", false, "only print files which did not verify")
+	revocationsPtr := fs.String("revocations", "", "directory of published revocation certificates to honor")
+	addressPtr := fs.String("address", "", "fetch the public key published for name@domain instead of -pubkey")
+	fingerprintPtr := fs.String("fingerprint", "", "with -address, fingerprint the fetched key must have")
+	pinsPtr := fs.String("pins", "", "with -address, file of pinned fingerprints to check and add to")
+	trustPtr := fs.String("trust", "", "trust store the key must be trusted in")
+	namePtr := fs.String("name", "", "with -trust, name of the key in the store instead of -pubkey")
+	firstUsePtr := fs.Bool("tofu", false, "with -trust, pin a key not yet in the store with a warning")
+	minTrustPtr := fs.String("min-trust", "marginal", "with -trust, least trust level accepted")
+	messagePtr := fs.String("message", "", "with -sig, message the signature is over")
+	inPtr := fs.String("in", "", "with -sig, file the signature is over instead of -message, - for stdin")
+	signaturePtr := fs.String("sig", "", "signature from sign, in hex or a signature file, to verify over -message or -in instead of files")
+	detectPtr := fs.Bool("detect", false, "with -sig, try every scheme and message hash and report which matched")
+	batchPtr := fs.String("batch", "", "json or .csv list of pubkey, message or file and signature to verify instead of files")
+	manifestPtr := fs.String("manifest", "", "with -batch of sign -batch's results, the manifest they were signed from, for their messages")
+	schemePtr := fs.String("scheme", string(schnorr.SchemeDecred), "with -batch, scheme of the signatures: legacy, bip340 or ec-schnorr-dcrv0")
+	hashPtr := fs.String("hash", "blake256", "with -batch, hash of messages, blake256 as sign uses or sha256")
+	workersPtr := fs.Int("workers", 0, "with -batch, signatures verified at once, 0 for every cpu")
+	textPtr := fs.Bool("text", false, "with -sig or -batch, normalize messages as text as sign -text does")
+	trimPtr := fs.Bool("trim", false, "with -text, also strip spaces and tabs from the ends of lines")
+	templatePtr := fs.String("template", "", "Go text/template to print each result with, such as '{{.Path}} {{.Status}} {{.Fingerprint}}', instead of the listing and summary")
+	parseFlags(fs, args)
+
+	tmpl, err := outputTemplate(*templatePtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+
+	text, err := textFlag(*textPtr, *trimPtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+
+	if *batchPtr != "" {
+		if code := runVerifyBatch(*batchPtr, *manifestPtr, *schemePtr, *hashPtr, text, *workersPtr, *quietPtr, tmpl); code != 0 {
+			exit(code)
+		}
+		return
+	}
+	if *manifestPtr != "" {
+		failf("-manifest needs -batch")
+		exit(2)
+	}
+
+	pubkey, err := publicKeyFlag(*pubKeyPtr, *pubKeyFilePtr)
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+
+	if *signaturePtr != "" {
+		if *detectPtr {
+			detectScheme(pubkey, *messagePtr, *inPtr, text, *signaturePtr)
+			return
+		}
+		ok, err := verifyMessage(pubkey, *messagePtr, *inPtr, text, *signaturePtr)
+		if err != nil {
+			fail(err)
+			exit(2)
+		}
+		out := verifyOutput{Verified: ok, PublicKey: pubkey, Fingerprint: fingerprintHex(pubkey)}
+		recordResult(out)
+		if tmpl == nil {
+			fmt.Println("Signature Verified?", ok)
+		} else if err := printTemplate(tmpl, out); err != nil {
+			fail(err)
+			exit(2)
+		}
+		if !ok {
+			exit(1)
+		}
+		return
+	}
+
+	var trust *truststore.Store
+	if *trustPtr != "" {
+		s, err := openTrustStore(*trustPtr, *firstUsePtr, *minTrustPtr)
+		if err != nil {
+			fail(err)
+			exit(2)
+		}
+		trust = s
+	}
+
+	var pk [33]byte
+	if trust != nil && *namePtr != "" {
+		pk, _, err = trust.Find(*namePtr)
+	} else {
+		pk, err = keyFromFlags(pubkey, *addressPtr, *fingerprintPtr, *pinsPtr)
+	}
+	if err != nil {
+		fail(err)
+		exit(2)
+	}
+
+	if *revocationsPtr != "" {
+		revoked := revocation.NewSet()
+		if err := revoked.LoadDir(*revocationsPtr); err != nil {
+			fail(err)
+			exit(2)
+		}
+		if s := revoked.Revoked(pk); s != nil {
+			failf("%x has been revoked: %s %s", pk, s.Reason, s.Comment)
+			exit(1)
+		}
+	}
+
+	if fs.NArg() == 0 {
+		failf("usage: schnorr-go verify <-pubkey key|-address name@domain> [-recursive] <path>...\n" +
+			"       schnorr-go verify -pubkey key <-message message|-in file> -sig signature\n" +
+			"       schnorr-go verify -batch list.json|list.csv")
+		exit(2)
+	}
+
+	results := []dirverify.Result{}
+	for _, path := range fs.Args() {
+		if !*recursivePtr {
+			results = append(results, dirverify.VerifyFile(pk, path))
+			continue
+		}
+
+		tree, err := dirverify.VerifyTree(path, pk)
+		if err != nil {
+			failf("%s: %v", path, err)
+			exit(1)
+		}
+		for _, r := range tree {
+			r.Path = filepath.ToSlash(filepath.Join(path, r.Path))
+			results = append(results, r)
+		}
+	}
+
+	if trust != nil {
+		if err := trust.Trusted(pk); err != nil {
+			fail(err)
+			exit(1)
+		}
+		if err := trust.Save(); err != nil {
+			fail(err)
+			exit(2)
+		}
+	}
+
+	for _, r := range results {
+		out := verifyOutput{
+			Verified:    r.Status == dirverify.StatusOK,
+			PublicKey:   hex.EncodeToString(pk[:]),
+			Fingerprint: truststore.Fingerprint(pk),
+			Path:        r.Path,
+			Status:      string(r.Status),
+		}
+		if r.Err != nil {
+			out.Error = r.Err.Error()
+		}
+		recordResult(out)
+		if tmpl == nil || *quietPtr && r.Status == dirverify.StatusOK {
+			continue
+		}
+		if err := printTemplate(tmpl, out); err != nil {
+			fail(err)
+			exit(2)
+		}
+	}
+	if tmpl != nil {
+		if !dirverify.OK(results) {
+			exit(1)
+		}
+		return
+	}
+
+	for _, r := range results {
+		if *quietPtr && r.Status == dirverify.StatusOK {
+			continue
+		}
+		if r.Err != nil {
+			fmt.Printf("%-14s %s: %v\n", r.Status, r.Path, r.Err)
+		} else {
+			fmt.Printf("%-14s %s\n", r.Status, r.Path)
+		}
+	}
+
+	counts := dirverify.Summary(results)
+	fmt.Printf("\n%d files: %d ok, %d bad signature, %d modified, %d unsigned, %d missing, %d unreadable\n",
+		len(results), counts[dirverify.StatusOK], counts[dirverify.StatusBadSignature], counts[dirverify.StatusModified],
+		counts[dirverify.StatusUnsigned], counts[dirverify.StatusMissing], counts[dirverify.StatusError])
+
+	if !dirverify.OK(results) {
+		exit(1)
+	}
+}
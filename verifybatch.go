@@ -0,0 +1,371 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/decred/dcrd/crypto/blake256"
+	"github.com/ryohare/schnorr-go/pkg/dirverify"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+	"github.com/ryohare/schnorr-go/pkg/textnorm"
+)
+
+//
+// verify -batch checks a list of signatures, each under its own public key,
+// spread over a verifier pool rather than a process per signature. The list
+// is json, as sign -batch's manifest with a key and signature per item:
+//
+//	{"items": [
+//	  {"id": "greeting", "pubkey": "02...", "message": "hello", "signature": "..."},
+//	  {"pubkey": "npub1...", "file": "dist/app.tar", "signature": "..."}
+//	]}
+//
+// or, if its name ends in .csv, a csv file whose header names the columns,
+// some of id, pubkey, message, file and signature. Files are relative to the
+// list.
+//
+// The results sign -batch writes are read as a list too, every signature
+// under their publicKey. They don't hold the messages, so -manifest gives
+// the manifest they were signed from, whose messages and files are matched
+// to the results by id. Without it only files can be checked, relative to
+// the results. Items sign -batch failed on fail here with its error.
+//
+
+// verifyBatchList is what verify -batch reads as json, a list of items or
+// sign -batch's results
+type verifyBatchList struct {
+	Items     []verifyBatchItem `json:"items"`
+	PublicKey string            `json:"publicKey"`
+	Results   []batchResult     `json:"results"`
+}
+
+// verifyBatchItem is a signature to check over a message or a file, with an
+// id to find its result by, the file or its index if not given
+type verifyBatchItem struct {
+	ID        string  `json:"id,omitempty"`
+	PublicKey string  `json:"pubkey"`
+	Message   *string `json:"message,omitempty"`
+	File      string  `json:"file,omitempty"`
+	Signature string  `json:"signature"`
+	// path is File resolved against the list's directory
+	path string
+	// unsigned is why sign -batch didn't sign the item
+	unsigned string
+}
+
+// verifyBatchColumns are the columns a csv list may have
+var verifyBatchColumns = map[string]bool{"id": true, "pubkey": true, "message": true, "file": true, "signature": true}
+
+// readVerifyBatch reads the list, checking every item has a key, a
+// signature and a message or a file, and that ids are unique. manifest is
+// the sign -batch manifest of a list of its results, if given.
+func readVerifyBatch(path, manifest string) ([]verifyBatchItem, error) {
+	var items []verifyBatchItem
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		items, err = readVerifyBatchCSV(path)
+	} else {
+		items, err = readVerifyBatchJSON(path, manifest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s lists no signatures", path)
+	}
+
+	ids := map[string]bool{}
+	for i := range items {
+		item := &items[i]
+		if item.PublicKey == "" || item.Signature == "" && item.unsigned == "" {
+			return nil, fmt.Errorf("item %d of %s must have a pubkey and a signature", i, path)
+		}
+		if (item.Message == nil) == (item.File == "") && item.unsigned == "" {
+			return nil, fmt.Errorf("item %d of %s must have a message or a file", i, path)
+		}
+		if item.path == "" {
+			item.path = item.File
+			if item.File != "" && !filepath.IsAbs(item.File) {
+				item.path = filepath.Join(filepath.Dir(path), item.File)
+			}
+		}
+		if item.ID == "" {
+			item.ID = item.File
+			if item.ID == "" {
+				item.ID = fmt.Sprint(i)
+			}
+		}
+		if ids[item.ID] {
+			return nil, fmt.Errorf("item id %q is in %s twice", item.ID, path)
+		}
+		ids[item.ID] = true
+	}
+	return items, nil
+}
+
+// readVerifyBatchJSON reads a json list, or sign -batch's results with
+// their messages and files taken from manifest if it isn't empty
+func readVerifyBatchJSON(path, manifest string) ([]verifyBatchItem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list verifyBatchList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s is not json: %v", path, err)
+	}
+	if list.Results == nil {
+		if manifest != "" {
+			return nil, fmt.Errorf("-manifest is for sign -batch's results, and %s lists items", path)
+		}
+		return list.Items, nil
+	}
+	if len(list.Items) > 0 {
+		return nil, fmt.Errorf("%s has both items and results", path)
+	}
+
+	signed := map[string]batchItem{}
+	if manifest != "" {
+		items, err := readBatchManifest(manifest)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			signed[item.ID] = item
+		}
+	}
+	items := make([]verifyBatchItem, 0, len(list.Results))
+	for _, r := range list.Results {
+		item := verifyBatchItem{ID: r.ID, PublicKey: list.PublicKey, File: r.File, Signature: r.Signature, unsigned: r.Error}
+		if manifest != "" {
+			m, ok := signed[r.ID]
+			if !ok {
+				return nil, fmt.Errorf("item %q of %s is not in manifest %s", r.ID, path, manifest)
+			}
+			item.Message, item.File, item.path = m.Message, m.File, m.path
+		} else if r.File == "" && r.Error == "" {
+			return nil, fmt.Errorf("item %q of %s is a message, which sign -batch's results don't hold, give the manifest it was signed from with -manifest", r.ID, path)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// readVerifyBatchCSV reads a csv list. An empty message is a message, as
+// the column is there, unless the row names a file instead.
+func readVerifyBatchCSV(path string) ([]verifyBatchItem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s is not csv: %v", path, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !verifyBatchColumns[name] {
+			return nil, fmt.Errorf("%s has unknown column %q, want id, pubkey, message, file or signature", path, name)
+		}
+		columns[name] = i
+	}
+
+	var items []verifyBatchItem
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s is not csv: %v", path, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return record[i]
+			}
+			return ""
+		}
+		item := verifyBatchItem{
+			ID:        field("id"),
+			PublicKey: strings.TrimSpace(field("pubkey")),
+			File:      field("file"),
+			Signature: strings.TrimSpace(field("signature")),
+		}
+		if _, ok := columns["message"]; ok && item.File == "" {
+			message := field("message")
+			item.Message = &message
+		}
+		items = append(items, item)
+	}
+}
+
+// runVerifyBatch verifies the list and prints a line per item, or only
+// those that failed if quiet, then a summary. It returns the exit code: 0
+// if every signature verified, 1 if any didn't and 2 if the list or the
+// flags are bad.
+func runVerifyBatch(path, manifest, schemeName, hashName string, text *textnorm.Options, workers int, quiet bool, tmpl *template.Template) int {
+	scheme, err := schnorr.ParseScheme(schemeName)
+	if err != nil {
+		fail(err)
+		return 2
+	}
+	newHash, err := verifyBatchHash(hashName)
+	if err != nil {
+		fail(err)
+		return 2
+	}
+	items, err := readVerifyBatch(path, manifest)
+	if err != nil {
+		fail(err)
+		return 2
+	}
+
+	results := verifyBatch(items, scheme, newHash, text, workers)
+	for i, r := range results {
+		out := verifyOutput{Verified: r.Status == dirverify.StatusOK, PublicKey: items[i].PublicKey, Path: r.Path, Status: string(r.Status)}
+		if pk, err := parsePublicKeyHex(items[i].PublicKey); err == nil {
+			out.Fingerprint = fingerprintHex(hex.EncodeToString(pk[:]))
+		}
+		if r.Err != nil {
+			out.Error = r.Err.Error()
+		}
+		recordResult(out)
+		if quiet && r.Status == dirverify.StatusOK {
+			continue
+		}
+		if tmpl != nil {
+			if err := printTemplate(tmpl, out); err != nil {
+				fail(err)
+				return 2
+			}
+			continue
+		}
+		if r.Err != nil {
+			fmt.Printf("%-14s %s: %v\n", r.Status, r.Path, r.Err)
+		} else {
+			fmt.Printf("%-14s %s\n", r.Status, r.Path)
+		}
+	}
+
+	counts := dirverify.Summary(results)
+	if tmpl == nil {
+		fmt.Printf("\n%d signatures: %d ok, %d bad signature, %d unreadable\n",
+			len(results), counts[dirverify.StatusOK], counts[dirverify.StatusBadSignature], counts[dirverify.StatusError])
+	}
+	if !dirverify.OK(results) {
+		return 1
+	}
+	return 0
+}
+
+// verifyBatch checks the items on a pool of workers, every cpu if workers
+// is 0, and returns their results in the list's order. As many goroutines
+// hash the messages and files with newHash, normalized first if text isn't
+// nil, and hand them to the pool, which verifies them under scheme.
+func verifyBatch(items []verifyBatchItem, scheme schnorr.Scheme, newHash func() hash.Hash, text *textnorm.Options, workers int) []dirverify.Result {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	results := make([]dirverify.Result, len(items))
+	pool := schnorr.NewVerifierPool(workers, len(items), func(publickey [33]byte, message [32]byte, signature [64]byte) (bool, error) {
+		return schnorr.VerifyScheme(scheme, publickey[:], message, signature)
+	})
+
+	// each hasher writes only the results of the items it failed to hash,
+	// and the pool's results are read once the hashers are done with them
+	next := make(chan int)
+	var hashers sync.WaitGroup
+	hashers.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer hashers.Done()
+			for i := range next {
+				job, err := verifyBatchJob(items[i], newHash, text)
+				if err != nil {
+					results[i].Status, results[i].Err = dirverify.StatusError, err
+					continue
+				}
+				job.ID = uint64(i)
+				pool.Submit(job)
+			}
+		}()
+	}
+	for i := range items {
+		results[i].Path = items[i].ID
+		next <- i
+	}
+	close(next)
+	hashers.Wait()
+	pool.Close()
+
+	for r := range pool.Results() {
+		result := &results[r.Job.ID]
+		switch {
+		case r.Valid:
+			result.Status = dirverify.StatusOK
+		case r.Err != nil:
+			result.Status, result.Err = dirverify.StatusBadSignature, r.Err
+		default:
+			result.Status = dirverify.StatusBadSignature
+		}
+	}
+	return results
+}
+
+// verifyBatchJob decodes an item's key and signature and hashes what it
+// signed
+func verifyBatchJob(item verifyBatchItem, newHash func() hash.Hash, text *textnorm.Options) (schnorr.VerifyJob, error) {
+	var job schnorr.VerifyJob
+	if item.unsigned != "" {
+		return job, fmt.Errorf("not signed: %s", item.unsigned)
+	}
+	publickey, err := parsePublicKeyHex(item.PublicKey)
+	if err != nil {
+		return job, err
+	}
+	signature, err := hex.DecodeString(item.Signature)
+	if err != nil || len(signature) != 64 {
+		return job, fmt.Errorf("signature is not 64 bytes of hex")
+	}
+	message := ""
+	if item.Message != nil {
+		message = *item.Message
+	}
+	h := newHash()
+	if err := hashInput(message, item.path, text, h); err != nil {
+		return job, err
+	}
+	job.PublicKey = publickey
+	copy(job.Message[:], h.Sum(nil))
+	copy(job.Signature[:], signature)
+	return job, nil
+}
+
+// verifyBatchHash is the hash of -hash
+func verifyBatchHash(name string) (func() hash.Hash, error) {
+	switch name {
+	case "blake256":
+		return blake256.New, nil
+	case "sha256":
+		return sha256.New, nil
+	}
+	return nil, fmt.Errorf("unknown hash %q, want blake256 or sha256", name)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ryohare/schnorr-go/pkg/dirverify"
+	"github.com/ryohare/schnorr-go/pkg/schnorr"
+)
+
+const testPrivateKey = "0000000000000000000000000000000000000000000000000000000000000003"
+
+// signTestMessage signs the message as sign does, returning the public key
+// and the signature in hex
+func signTestMessage(message string, t *testing.T) (string, string) {
+	signer, err := newMessageSigner(testPrivateKey, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error from newMessageSigner: %v", err)
+	}
+	signature, publickey, err := signer.sign(message, "")
+	if err != nil {
+		t.Fatalf("Unexpected error from sign: %v", err)
+	}
+	return hex.EncodeToString(publickey), hex.EncodeToString(signature)
+}
+
+func writeTestFile(dir, name, content string, t *testing.T) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Unexpected error from os.WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestReadVerifyBatch(t *testing.T) {
+	pubkey, sig := signTestMessage("hello", t)
+	manifest := `{"items": [{"id": "greeting", "message": "hello"}, {"file": "a.txt"}]}`
+
+	for _, tt := range []struct {
+		name     string
+		list     string
+		content  string
+		manifest string
+		wantIDs  []string
+		wantErr  string
+	}{
+		{
+			name:    "json items",
+			list:    "list.json",
+			content: `{"items": [{"id": "greeting", "pubkey": "` + pubkey + `", "message": "hello", "signature": "` + sig + `"}, {"pubkey": "` + pubkey + `", "file": "a.txt", "signature": "` + sig + `"}]}`,
+			wantIDs: []string{"greeting", "a.txt"},
+		},
+		{
+			name:    "csv with an empty message",
+			list:    "list.csv",
+			content: "id,pubkey,message,signature\ngreeting," + pubkey + ",hello," + sig + "\n," + pubkey + ",," + sig + "\n",
+			wantIDs: []string{"greeting", "1"},
+		},
+		{
+			name:    "csv columns in any case and order",
+			list:    "list.CSV",
+			content: "Signature, File ,PUBKEY\n" + sig + ",a.txt," + pubkey + "\n",
+			wantIDs: []string{"a.txt"},
+		},
+		{
+			name:    "csv with an unknown column",
+			list:    "list.csv",
+			content: "id,pubkey,msg,signature\ngreeting," + pubkey + ",hello," + sig + "\n",
+			wantErr: `unknown column "msg"`,
+		},
+		{
+			name:    "missing signature",
+			list:    "list.json",
+			content: `{"items": [{"pubkey": "` + pubkey + `", "message": "hello"}]}`,
+			wantErr: "must have a pubkey and a signature",
+		},
+		{
+			name:    "message and file",
+			list:    "list.json",
+			content: `{"items": [{"pubkey": "` + pubkey + `", "message": "hello", "file": "a.txt", "signature": "` + sig + `"}]}`,
+			wantErr: "must have a message or a file",
+		},
+		{
+			name:    "duplicate ids",
+			list:    "list.json",
+			content: `{"items": [{"id": "x", "pubkey": "` + pubkey + `", "message": "a", "signature": "` + sig + `"}, {"id": "x", "pubkey": "` + pubkey + `", "message": "b", "signature": "` + sig + `"}]}`,
+			wantErr: `item id "x"`,
+		},
+		{
+			name:    "empty list",
+			list:    "list.json",
+			content: `{"items": []}`,
+			wantErr: "lists no signatures",
+		},
+		{
+			name:    "not json",
+			list:    "list.json",
+			content: "id,pubkey\n",
+			wantErr: "is not json",
+		},
+		{
+			name:    "sign -batch results of files",
+			list:    "results.json",
+			content: `{"publicKey": "` + pubkey + `", "results": [{"id": "a.txt", "file": "a.txt", "signature": "` + sig + `"}, {"id": "b.txt", "file": "b.txt", "error": "no such file"}]}`,
+			wantIDs: []string{"a.txt", "b.txt"},
+		},
+		{
+			name:    "sign -batch results of a message without the manifest",
+			list:    "results.json",
+			content: `{"publicKey": "` + pubkey + `", "results": [{"id": "greeting", "signature": "` + sig + `"}]}`,
+			wantErr: "-manifest",
+		},
+		{
+			name:     "sign -batch results with the manifest",
+			list:     "results.json",
+			content:  `{"publicKey": "` + pubkey + `", "results": [{"id": "greeting", "signature": "` + sig + `"}, {"id": "a.txt", "file": "a.txt", "signature": "` + sig + `"}]}`,
+			manifest: manifest,
+			wantIDs:  []string{"greeting", "a.txt"},
+		},
+		{
+			name:     "sign -batch results not in the manifest",
+			list:     "results.json",
+			content:  `{"publicKey": "` + pubkey + `", "results": [{"id": "farewell", "signature": "` + sig + `"}]}`,
+			manifest: manifest,
+			wantErr:  "is not in manifest",
+		},
+		{
+			name:     "manifest with items",
+			list:     "list.json",
+			content:  `{"items": [{"pubkey": "` + pubkey + `", "message": "hello", "signature": "` + sig + `"}]}`,
+			manifest: manifest,
+			wantErr:  "-manifest is for sign -batch's results",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			dir := t.TempDir()
+			path := writeTestFile(dir, tt.list, tt.content, t)
+			manifestPath := ""
+			if tt.manifest != "" {
+				manifestPath = writeTestFile(dir, "manifest.json", tt.manifest, t)
+			}
+
+			// when
+			items, err := readVerifyBatch(path, manifestPath)
+
+			// then
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readVerifyBatch() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error from readVerifyBatch: %v", err)
+			}
+			var ids []string
+			for _, item := range items {
+				ids = append(ids, item.ID)
+				if item.File != "" && item.path != filepath.Join(dir, item.File) {
+					t.Fatalf("readVerifyBatch() resolved %s to %s, want it relative to the list", item.File, item.path)
+				}
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Fatalf("readVerifyBatch() ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestRunVerifyBatch(t *testing.T) {
+	pubkey, sig := signTestMessage("hello", t)
+	_, fileSig := signTestMessage("file contents\n", t)
+	_, otherSig := signTestMessage("goodbye", t)
+
+	for _, tt := range []struct {
+		name     string
+		list     string
+		content  string
+		manifest string
+		hash     string
+		want     int
+	}{
+		{
+			name:    "every signature verifies",
+			list:    "list.json",
+			content: `{"items": [{"pubkey": "` + pubkey + `", "message": "hello", "signature": "` + sig + `"}, {"pubkey": "` + pubkey + `", "file": "a.txt", "signature": "` + fileSig + `"}]}`,
+			want:    0,
+		},
+		{
+			name:    "csv",
+			list:    "list.csv",
+			content: "pubkey,message,file,signature\n" + pubkey + ",hello,," + sig + "\n" + pubkey + ",,a.txt," + fileSig + "\n",
+			want:    0,
+		},
+		{
+			name:    "a bad signature",
+			list:    "list.json",
+			content: `{"items": [{"pubkey": "` + pubkey + `", "message": "hello", "signature": "` + sig + `"}, {"pubkey": "` + pubkey + `", "message": "hello", "signature": "` + otherSig + `"}]}`,
+			want:    1,
+		},
+		{
+			name:    "a missing file",
+			list:    "list.json",
+			content: `{"items": [{"pubkey": "` + pubkey + `", "file": "missing.txt", "signature": "` + fileSig + `"}]}`,
+			want:    1,
+		},
+		{
+			name:    "a malformed signature",
+			list:    "list.json",
+			content: `{"items": [{"pubkey": "` + pubkey + `", "message": "hello", "signature": "zz"}]}`,
+			want:    1,
+		},
+		{
+			name:    "the wrong hash",
+			list:    "list.json",
+			content: `{"items": [{"pubkey": "` + pubkey + `", "message": "hello", "signature": "` + sig + `"}]}`,
+			hash:    "sha256",
+			want:    1,
+		},
+		{
+			name:     "sign -batch results with the manifest",
+			list:     "results.json",
+			content:  `{"publicKey": "` + pubkey + `", "results": [{"id": "greeting", "signature": "` + sig + `"}, {"id": "a.txt", "file": "a.txt", "signature": "` + fileSig + `"}]}`,
+			manifest: `{"items": [{"id": "greeting", "message": "hello"}, {"file": "a.txt"}]}`,
+			want:     0,
+		},
+		{
+			name:    "sign -batch results with an item it failed on",
+			list:    "results.json",
+			content: `{"publicKey": "` + pubkey + `", "results": [{"id": "a.txt", "file": "a.txt", "signature": "` + fileSig + `"}, {"id": "b.txt", "file": "b.txt", "error": "no such file"}]}`,
+			want:    1,
+		},
+		{
+			name:    "an unreadable list",
+			list:    "list.json",
+			content: `{"items": [`,
+			want:    2,
+		},
+		{
+			name:    "an unknown hash",
+			list:    "list.json",
+			content: `{"items": [{"pubkey": "` + pubkey + `", "message": "hello", "signature": "` + sig + `"}]}`,
+			hash:    "md5",
+			want:    2,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			dir := t.TempDir()
+			writeTestFile(dir, "a.txt", "file contents\n", t)
+			path := writeTestFile(dir, tt.list, tt.content, t)
+			manifestPath := ""
+			if tt.manifest != "" {
+				manifestPath = writeTestFile(dir, "manifest.json", tt.manifest, t)
+			}
+			hash := tt.hash
+			if hash == "" {
+				hash = "blake256"
+			}
+
+			// when
+			got := runVerifyBatch(path, manifestPath, string(schnorr.SchemeDecred), hash, nil, 2, true, nil)
+
+			// then
+			if got != tt.want {
+				t.Fatalf("runVerifyBatch() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyBatchOrder(t *testing.T) {
+	// given more items than workers, every other one signed badly
+	pubkey, sig := signTestMessage("hello", t)
+	var items []verifyBatchItem
+	for i := 0; i < 50; i++ {
+		message := "hello"
+		if i%2 == 1 {
+			message = "goodbye"
+		}
+		items = append(items, verifyBatchItem{ID: fmt.Sprint(i), PublicKey: pubkey, Message: &message, Signature: sig})
+	}
+	newHash, err := verifyBatchHash("blake256")
+	if err != nil {
+		t.Fatalf("Unexpected error from verifyBatchHash: %v", err)
+	}
+
+	// when
+	results := verifyBatch(items, schnorr.SchemeDecred, newHash, nil, 4)
+
+	// then
+	for i, r := range results {
+		if r.Path != items[i].ID {
+			t.Fatalf("verifyBatch() result %d is for %s, want %s", i, r.Path, items[i].ID)
+		}
+		if ok := r.Status == dirverify.StatusOK; ok != (i%2 == 0) {
+			t.Fatalf("verifyBatch() result %d = %s, want the list's order", i, r.Status)
+		}
+	}
+}